@@ -0,0 +1,89 @@
+package auth_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"bettermt/main/client"
+	"bettermt/main/meshbuilder"
+	"bettermt/main/network"
+	"bettermt/main/server"
+)
+
+const loginTimeout = 10 * time.Second
+
+func startServer(t *testing.T) *server.Server {
+	t.Helper()
+	srv, err := server.New(server.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Listen("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	return srv
+}
+
+// login joins the server and disconnects again. The server may still be dropping an earlier session of the
+// same player, so logins it refuses as already connected are retried.
+func login(t *testing.T, srv *server.Server, name, password string) error {
+	t.Helper()
+	deadline := time.Now().Add(loginTimeout)
+	for {
+		c := client.New(name, password, meshbuilder.NewWorld(16))
+		err := c.Connect(srv.Addr().String(), loginTimeout)
+		c.Close()
+		var denied *client.AccessDeniedError
+		if errors.As(err, &denied) && denied.Code == network.AccessDeniedAlreadyConnected && time.Now().Before(deadline) {
+			time.Sleep(50 * time.Millisecond)
+			continue
+		}
+		return err
+	}
+}
+
+// wantDenied checks that a login failed with an access denied code
+func wantDenied(t *testing.T, err error, code uint8) {
+	t.Helper()
+	var denied *client.AccessDeniedError
+	if !errors.As(err, &denied) || denied.Code != code {
+		t.Fatalf("got %v, want access denied with code %d", err, code)
+	}
+}
+
+func TestLoginRegistersThenUsesSRP(t *testing.T) {
+	srv := startServer(t)
+	// The first login registers the account with TOSERVER_FIRST_SRP
+	if err := login(t, srv, "Alice", "secret"); err != nil {
+		t.Fatalf("first login: %v", err)
+	}
+	// Later ones run the SRP exchange against the stored verifier
+	if err := login(t, srv, "Alice", "secret"); err != nil {
+		t.Fatalf("SRP login: %v", err)
+	}
+	wantDenied(t, login(t, srv, "Alice", "wrong"), network.AccessDeniedWrongPassword)
+}
+
+func TestLoginEmptyPassword(t *testing.T) {
+	srv := startServer(t)
+	if err := login(t, srv, "Bob", ""); err != nil {
+		t.Fatalf("first login: %v", err)
+	}
+	if err := login(t, srv, "Bob", ""); err != nil {
+		t.Fatalf("SRP login: %v", err)
+	}
+	wantDenied(t, login(t, srv, "Bob", "guess"), network.AccessDeniedWrongPassword)
+}
+
+func TestLoginLegacyAccount(t *testing.T) {
+	srv := startServer(t)
+	if err := srv.AddLegacyAccount("Carol", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if err := login(t, srv, "Carol", "secret"); err != nil {
+		t.Fatalf("legacy login: %v", err)
+	}
+	wantDenied(t, login(t, srv, "Carol", "wrong"), network.AccessDeniedWrongPassword)
+}
@@ -0,0 +1,261 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"io"
+	"math/big"
+	"strings"
+)
+
+// SRP-6a as implemented by Minetest's csrp: SHA-256 with the 2048 bit group from RFC 5054
+
+const (
+	// SaltSize is the length of the random salt stored with a verifier
+	SaltSize = 16
+	// secretSize is the length of the random private values a and b
+	secretSize = 32
+)
+
+// random is the source of salts and private values, replaced by tests to get known values
+var random io.Reader = rand.Reader
+
+var (
+	ErrInvalidChallenge = errors.New("invalid SRP challenge")
+	ErrInvalidA         = errors.New("invalid SRP public value A")
+)
+
+// Group is a safe prime and generator used by SRP
+type Group struct {
+	N *big.Int
+	G *big.Int
+}
+
+// NG2048 is the 2048 bit group Minetest uses for authentication
+var NG2048 = newGroup(
+	"AC6BDB41324A9A9BF166DE5E1389582FAF72B6651987EE07FC3192943DB56050"+
+		"A37329CBB4A099ED8193E0757767A13DD52312AB4B03310DCD7F48A9DA04FD50"+
+		"E8083969EDB767B0CF6095179A163AB3661A05FBD5FAAAE82918A9962F0B93B8"+
+		"55F97993EC975EEAA80D740ADBF4FF747359D041D5C33EA71D281E446B14773B"+
+		"CA97B43A23FB801676BD207A436C6481F1D2B9078717461A5B9D32E688F87748"+
+		"544523B524B0D57D5EA77A2775D2ECFA032CFBDBF52FB3786160279004E57AE6"+
+		"AF874E7303CE53299CCC041C7BC308D82A5698F3A8D0C38271AE35F8E9DBFBB6"+
+		"94B5C803D89F7AE435DE236D525F54759B65E372FCD68EF20FA7111F9E4AFF73",
+	"2",
+)
+
+func newGroup(n, g string) *Group {
+	group := &Group{N: new(big.Int), G: new(big.Int)}
+	group.N.SetString(n, 16)
+	group.G.SetString(g, 16)
+	return group
+}
+
+// hash returns the SHA-256 digest of the concatenated inputs
+func hash(parts ...[]byte) []byte {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write(p)
+	}
+	return h.Sum(nil)
+}
+
+// hashPadded hashes two numbers left-padded to the length of N
+func (g *Group) hashPadded(a, b *big.Int) *big.Int {
+	size := len(g.N.Bytes())
+	buf := make([]byte, 2*size)
+	a.FillBytes(buf[:size])
+	b.FillBytes(buf[size:])
+	return new(big.Int).SetBytes(hash(buf))
+}
+
+// multiplier computes k = H(N | PAD(g))
+func (g *Group) multiplier() *big.Int {
+	return g.hashPadded(g.N, g.G)
+}
+
+// calculateX computes x = H(s | H(I | ":" | P))
+func calculateX(salt []byte, username string, password []byte) *big.Int {
+	inner := hash([]byte(username), []byte(":"), password)
+	return new(big.Int).SetBytes(hash(salt, inner))
+}
+
+// calculateM computes the client proof M = H(H(N) xor H(g) | H(I) | s | A | B | K)
+func (g *Group) calculateM(username string, salt []byte, A, B *big.Int, K []byte) []byte {
+	hN := hash(g.N.Bytes())
+	hG := hash(g.G.Bytes())
+	hXor := make([]byte, len(hN))
+	for i := range hXor {
+		hXor[i] = hN[i] ^ hG[i]
+	}
+	return hash(hXor, hash([]byte(username)), salt, A.Bytes(), B.Bytes(), K)
+}
+
+// calculateHAMK computes the server proof H(A | M | K)
+func calculateHAMK(A *big.Int, M, K []byte) []byte {
+	return hash(A.Bytes(), M, K)
+}
+
+// randomSecret reads a private SRP value
+func randomSecret() (*big.Int, error) {
+	buf := make([]byte, secretSize)
+	if _, err := io.ReadFull(random, buf); err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(buf), nil
+}
+
+// ComputeVerifier derives the password verifier v = g^x for a name, password and salt
+func ComputeVerifier(name, password string, salt []byte) []byte {
+	x := calculateX(salt, strings.ToLower(name), []byte(password))
+	return new(big.Int).Exp(NG2048.G, x, NG2048.N).Bytes()
+}
+
+// CreateVerifier generates a random salt and the matching verifier, as sent in TOSERVER_FIRST_SRP
+func CreateVerifier(name, password string) (salt, verifier []byte, err error) {
+	salt = make([]byte, SaltSize)
+	if _, err := io.ReadFull(random, salt); err != nil {
+		return nil, nil, err
+	}
+	return salt, ComputeVerifier(name, password, salt), nil
+}
+
+// LegacyPassword converts a password to the form used by servers that migrated pre-SRP accounts
+func LegacyPassword(name, password string) string {
+	if password == "" {
+		return ""
+	}
+	sum := sha1.Sum([]byte(name + password))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// Client is the client side of one SRP exchange
+type Client struct {
+	group    *Group
+	username string
+	password []byte
+	a, A     *big.Int
+	M, K     []byte
+	hamk     []byte
+}
+
+// NewClient starts an exchange for a player; the verifier is computed from the lowercase name
+func NewClient(username, password string) (*Client, error) {
+	a, err := randomSecret()
+	if err != nil {
+		return nil, err
+	}
+	g := NG2048
+	return &Client{
+		group:    g,
+		username: username,
+		password: []byte(password),
+		a:        a,
+		A:        new(big.Int).Exp(g.G, a, g.N),
+	}, nil
+}
+
+// BytesA returns the public value sent in TOSERVER_SRP_BYTES_A
+func (c *Client) BytesA() []byte {
+	return c.A.Bytes()
+}
+
+// ProcessChallenge takes the salt and B from TOCLIENT_SRP_BYTES_S_B and returns the proof M
+func (c *Client) ProcessChallenge(salt, bytesB []byte) ([]byte, error) {
+	g := c.group
+	B := new(big.Int).SetBytes(bytesB)
+	if new(big.Int).Mod(B, g.N).Sign() == 0 {
+		return nil, ErrInvalidChallenge
+	}
+	u := g.hashPadded(c.A, B)
+	if u.Sign() == 0 {
+		return nil, ErrInvalidChallenge
+	}
+
+	x := calculateX(salt, strings.ToLower(c.username), c.password)
+	k := g.multiplier()
+
+	// S = (B - k * g^x) ^ (a + u * x) mod N
+	base := new(big.Int).Exp(g.G, x, g.N)
+	base.Mul(base, k)
+	base.Sub(B, base)
+	base.Mod(base, g.N)
+	exp := new(big.Int).Mul(u, x)
+	exp.Add(exp, c.a)
+	S := new(big.Int).Exp(base, exp, g.N)
+
+	c.K = hash(S.Bytes())
+	c.M = g.calculateM(c.username, salt, c.A, B, c.K)
+	c.hamk = calculateHAMK(c.A, c.M, c.K)
+	return c.M, nil
+}
+
+// VerifySession checks the server's proof of the shared key
+func (c *Client) VerifySession(hamk []byte) bool {
+	return c.hamk != nil && subtle.ConstantTimeCompare(c.hamk, hamk) == 1
+}
+
+// SessionKey returns the shared key once the challenge has been processed
+func (c *Client) SessionKey() []byte {
+	return c.K
+}
+
+// Verifier is the server side of one SRP exchange
+type Verifier struct {
+	B    *big.Int
+	M, K []byte
+	hamk []byte
+}
+
+// NewVerifier answers a client's A using the stored salt and verifier
+func NewVerifier(username string, salt, verifier, bytesA []byte) (*Verifier, error) {
+	g := NG2048
+	A := new(big.Int).SetBytes(bytesA)
+	if new(big.Int).Mod(A, g.N).Sign() == 0 {
+		return nil, ErrInvalidA
+	}
+	b, err := randomSecret()
+	if err != nil {
+		return nil, err
+	}
+	v := new(big.Int).SetBytes(verifier)
+
+	// B = k * v + g^b mod N
+	B := new(big.Int).Mul(g.multiplier(), v)
+	B.Add(B, new(big.Int).Exp(g.G, b, g.N))
+	B.Mod(B, g.N)
+
+	// S = (A * v^u) ^ b mod N
+	u := g.hashPadded(A, B)
+	S := new(big.Int).Exp(v, u, g.N)
+	S.Mul(S, A)
+	S.Exp(S, b, g.N)
+
+	s := &Verifier{B: B}
+	s.K = hash(S.Bytes())
+	s.M = g.calculateM(username, salt, A, B, s.K)
+	s.hamk = calculateHAMK(A, s.M, s.K)
+	return s, nil
+}
+
+// BytesB returns the public value sent in TOCLIENT_SRP_BYTES_S_B
+func (s *Verifier) BytesB() []byte {
+	return s.B.Bytes()
+}
+
+// Verify checks the client's proof M and returns the server proof if it matches
+func (s *Verifier) Verify(M []byte) ([]byte, bool) {
+	if subtle.ConstantTimeCompare(s.M, M) != 1 {
+		return nil, false
+	}
+	return s.hamk, true
+}
+
+// SessionKey returns the shared key
+func (s *Verifier) SessionKey() []byte {
+	return s.K
+}
@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"testing"
+)
+
+// Values of an exchange between Alice and a server with a fixed salt, a and b, computed independently the
+// way csrp does with the 2048 bit group and SHA-256: N, g, A and B padded in k and u but not in M
+const (
+	knownName     = "Alice"
+	knownPassword = "password123"
+	knownVerifier = "21b37591ff48766be9b93d18d34ccace802c37f8889c61f2bc374809458f0c4c" +
+		"42baf45cae4d48366006abb25ddc3ac5aa651a9779e3e9fe3916f2be28f86884" +
+		"610d75ade8b08508483adb9fef1ba28eec56322f356cea145047c7d3c1309dac" +
+		"1253aabe08446f23b63c7062ae9cf0bee503bcd781536f4b21d27dc96d787e2a" +
+		"31dc74c30a7544e779429f3ee9fd508e52c8ddf02d311b79682870f44053b79b" +
+		"62198f780178f571f42e9a546cb8507a92aa2542103474ae63564f1194c144f4" +
+		"44a008936a031c959e1d4b5dddea40ead43a75ee654b5179ce29cb7d5e6a1124" +
+		"e582c6240822eca10fb727eee198456f4c3f95b6b579f57dff0904347e6467c9"
+	knownBytesA = "24d1e3e550122e1dc571bcefd01f494de5ca82c5ff005ac469a843e5c5a2898b" +
+		"4c3ea0bac3b9b8e552cef73254dceb5496f05eb1d82a97523ce07a43e4268468" +
+		"328741403099f4f0f7a4f28c79a75d2d2b9c27744582063df5d31e5ff586fe1e" +
+		"0266151a23549e9b61d93c8b575d28b188d045f7b97511afb36d73e6f8f8bc19" +
+		"605ff47c2440fd378d4bb53580d81f01f6bd1c608d9def0b7fefe662b2d4a669" +
+		"dcaceba2a2d8b3979371c0b74027231060f640a922b4374190333c4a102c6e76" +
+		"0e5208f75c88b396af912509427875e9649ff390e3a19488157c1593cb951401" +
+		"ccd848fc4bf779f86e5c06cf66f9b2ce0e8fbd26c96ac9b4d4662155f89b5d0f"
+	knownBytesB = "4203de368d701e78a4bea3d87a893e60b8bfc085d70f84b4231ac5936bda30c6" +
+		"bdfe065487f77eb9c0fd9dcba3a7406444a9859fe2d5b1bc0734e3c760bb620c" +
+		"58ede12bfd9c869b6a56d343a77b97e5de8c9d0bcc8da83729e0bff159dc45ae" +
+		"7ca9ef24a046bc809359db228d7f4265da10ae30b2a5148ee61f02fdac13a5d9" +
+		"fb8765c76eb862f67494b2d039bd837a2e476b553c6a1081c85fd3e5d8e9b305" +
+		"e5b07b6bef9cfa35de39a5733c98ebaa4ca11368aca9c51d8a3b337a325833c4" +
+		"0fceb3fe53d74fb0f7c7025c75fb3ac75c028b49ddb86e1f7d57291cd13885b2" +
+		"e9ad0fd615f5c45c7cace786ddbedfcf8826160a68c60411210e671827e0f665"
+	knownK    = "9720381b7f66d6ad618bb8086ed10c22a2e863e52744ae93e3e5591ae8dd2579"
+	knownM    = "d119076bbb37dff7dd7cb077fb49220c2cce842dc17e68e46a2f3fe85494617c"
+	knownHAMK = "35e084aa837f37c654d3bf2c953c32acf7aff946a92b36b989ff28fe604f84e1"
+)
+
+var (
+	knownSalt    = []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+	knownSecretA = bytes.Repeat([]byte{0x11}, secretSize)
+	knownSecretB = bytes.Repeat([]byte{0x22}, secretSize)
+)
+
+// useRandom makes the exchanges of a test read their salts and private values from data
+func useRandom(t *testing.T, data ...[]byte) {
+	t.Helper()
+	random = bytes.NewReader(bytes.Join(data, nil))
+	t.Cleanup(func() { random = rand.Reader })
+}
+
+func unhex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestKnownExchange(t *testing.T) {
+	useRandom(t, knownSalt, knownSecretA, knownSecretB)
+
+	salt, verifier, err := CreateVerifier(knownName, knownPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(salt, knownSalt) {
+		t.Fatalf("salt %x, want %x", salt, knownSalt)
+	}
+	if want := unhex(t, knownVerifier); !bytes.Equal(verifier, want) {
+		t.Fatalf("verifier %x, want %x", verifier, want)
+	}
+
+	c, err := NewClient(knownName, knownPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := unhex(t, knownBytesA); !bytes.Equal(c.BytesA(), want) {
+		t.Fatalf("A %x, want %x", c.BytesA(), want)
+	}
+	s, err := NewVerifier(knownName, salt, verifier, c.BytesA())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := unhex(t, knownBytesB); !bytes.Equal(s.BytesB(), want) {
+		t.Fatalf("B %x, want %x", s.BytesB(), want)
+	}
+
+	M, err := c.ProcessChallenge(salt, s.BytesB())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := unhex(t, knownM); !bytes.Equal(M, want) {
+		t.Fatalf("M %x, want %x", M, want)
+	}
+	for side, K := range map[string][]byte{"client": c.SessionKey(), "server": s.SessionKey()} {
+		if want := unhex(t, knownK); !bytes.Equal(K, want) {
+			t.Fatalf("%s K %x, want %x", side, K, want)
+		}
+	}
+
+	hamk, ok := s.Verify(M)
+	if !ok {
+		t.Fatal("server rejected the proof of the right password")
+	}
+	if want := unhex(t, knownHAMK); !bytes.Equal(hamk, want) {
+		t.Fatalf("H(A | M | K) %x, want %x", hamk, want)
+	}
+	if !c.VerifySession(hamk) {
+		t.Fatal("client rejected the server proof")
+	}
+}
+
+func TestVerifierIgnoresNameCase(t *testing.T) {
+	if !bytes.Equal(ComputeVerifier("ALICE", knownPassword, knownSalt), unhex(t, knownVerifier)) {
+		t.Fatal("verifier depends on the case of the name")
+	}
+}
+
+func TestWrongPassword(t *testing.T) {
+	salt, verifier, err := CreateVerifier(knownName, knownPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient(knownName, "wrong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewVerifier(knownName, salt, verifier, c.BytesA())
+	if err != nil {
+		t.Fatal(err)
+	}
+	M, err := c.ProcessChallenge(salt, s.BytesB())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hamk, ok := s.Verify(M); ok || hamk != nil {
+		t.Fatal("server accepted the proof of a wrong password")
+	}
+	if c.VerifySession(nil) {
+		t.Fatal("client accepted a missing server proof")
+	}
+}
+
+func TestInvalidPublicValues(t *testing.T) {
+	_, verifier, err := CreateVerifier(knownName, knownPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewVerifier(knownName, knownSalt, verifier, NG2048.N.Bytes()); err != ErrInvalidA {
+		t.Fatalf("A = N: got %v, want %v", err, ErrInvalidA)
+	}
+	c, err := NewClient(knownName, knownPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ProcessChallenge(knownSalt, []byte{0}); err != ErrInvalidChallenge {
+		t.Fatalf("B = 0: got %v, want %v", err, ErrInvalidChallenge)
+	}
+}
@@ -2,6 +2,11 @@ package blocktypes
 
 import (
 	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"bettermt/main/media"
 
 	"github.com/g3n/engine/gls"
 	"github.com/g3n/engine/material"
@@ -9,51 +14,95 @@ import (
 	"github.com/g3n/engine/texture"
 )
 
-// Material map to hold block materials
-var blockMaterials map[uint8]*material.Standard
+// Node definitions materials are built from
+var nodeDefs = DefaultNodeDefManager()
+
+// Filesystem textures are loaded from
+var textureSource fs.FS
+
+// Material map to hold block materials, keyed by MaterialID
+var blockMaterials = make(map[uint32]*material.Standard)
 
-// initializeBlockMaterials initializes the materials for all block types
+// Loaded textures by file name, nil for textures that failed to load
+var textures = make(map[string]*texture.Texture2D)
+
+// initializeBlockMaterials sets up material loading with the textures shipped in parentDir
 func InitializeBlockMaterials(parentDir string) {
-	blockMaterials = make(map[uint8]*material.Standard)
+	SetTextureSource(os.DirFS(parentDir + "/textures"))
+}
 
-	// Example for air block
-	blockMaterials[0] = material.NewStandard(math32.NewColor("White"))
+// SetTextureSource loads textures from fsys from now on, such as the media sent by a server
+func SetTextureSource(fsys fs.FS) {
+	textureSource = fsys
+	blockMaterials = make(map[uint32]*material.Standard)
+	textures = make(map[string]*texture.Texture2D)
+}
+
+// SetNodeDefManager replaces the node definitions, such as with the ones received from a server
+func SetNodeDefManager(m *NodeDefManager) {
+	nodeDefs = m
+	blockMaterials = make(map[uint32]*material.Standard)
+}
+
+// NodeDefs returns the node definitions in use
+func NodeDefs() *NodeDefManager {
+	return nodeDefs
+}
 
-	// Example for grass block
-	grassMaterial := material.NewStandard(math32.NewColor("White"))
-	grassTexture, err := texture.NewTexture2DFromImage(parentDir + "/textures/grass.png")
-	if err == nil {
-		grassTexture.SetMagFilter(gls.NEAREST)
-		grassMaterial.AddTexture(grassTexture)
+// MaterialID combines a content ID with one of its tile indices
+func MaterialID(contentID uint16, tile int) uint32 {
+	return uint32(contentID)*TileCount + uint32(tile)
+}
+
+// GetBlockMaterial returns the material for a given MaterialID, building it on first use
+func GetBlockMaterial(materialID uint32) (*material.Standard, error) {
+	if material, exists := blockMaterials[materialID]; exists {
+		return material, nil
 	}
-	blockMaterials[1] = grassMaterial
 
-	// Example for dirt block
-	dirtMaterial := material.NewStandard(math32.NewColor("White"))
-	dirtTexture, err := texture.NewTexture2DFromImage(parentDir + "/textures/dirt.png")
-	if err == nil {
-		dirtTexture.SetMagFilter(gls.NEAREST)
-		dirtMaterial.AddTexture(dirtTexture)
+	contentID := uint16(materialID / TileCount)
+	def, exists := nodeDefs.Lookup(contentID)
+	if !exists {
+		return nil, fmt.Errorf("unknown block ID: %d", contentID)
 	}
-	blockMaterials[2] = dirtMaterial
+	tile := def.Tiles[materialID%TileCount]
 
-	// Example for stone block
-	stoneMaterial := material.NewStandard(math32.NewColor("White"))
-	stoneTexture, err := texture.NewTexture2DFromImage(parentDir + "/textures/stone.png")
-	if err == nil {
-		stoneTexture.SetMagFilter(gls.NEAREST)
-		stoneMaterial.AddTexture(stoneTexture)
+	tint := def.Color
+	if tile.Flags&TileFlagHasColor != 0 {
+		tint = tile.Color
+	}
+	blockMaterial := material.NewStandard(&math32.Color{R: float32(tint.R) / 255, G: float32(tint.G) / 255, B: float32(tint.B) / 255})
+	if tex := loadTexture(tile.Name); tex != nil {
+		blockMaterial.AddTexture(tex)
+	}
+	if tile.Flags&TileFlagBackfaceCulling == 0 {
+		blockMaterial.SetSide(material.SideDouble)
+	}
+	switch def.Drawtype {
+	case DrawtypeLiquid, DrawtypeFlowingLiquid, DrawtypeGlasslike, DrawtypeGlasslikeFramed,
+		DrawtypeGlasslikeFramedOptional, DrawtypeAllfaces, DrawtypeAllfacesOptional:
+		blockMaterial.SetTransparent(true)
 	}
-	blockMaterials[3] = stoneMaterial
 
-	// Add more block types as needed
+	blockMaterials[materialID] = blockMaterial
+	return blockMaterial, nil
 }
 
-// GetBlockMaterial returns the material for a given block ID
-func GetBlockMaterial(blockID uint8) (*material.Standard, error) {
-	if material, exists := blockMaterials[blockID]; exists {
-		return material, nil
+// loadTexture returns the texture a tile refers to, ignoring texture modifiers
+func loadTexture(name string) *texture.Texture2D {
+	name, _, _ = strings.Cut(name, "^")
+	if name == "" || strings.HasPrefix(name, "[") {
+		return nil
+	}
+	if tex, exists := textures[name]; exists || textureSource == nil {
+		return tex
 	}
 
-	return nil, fmt.Errorf("unknown block ID: %d", blockID)
+	var tex *texture.Texture2D
+	if rgba, err := media.DecodeImage(textureSource, name); err == nil {
+		tex = texture.NewTexture2DFromRGBA(rgba)
+		tex.SetMagFilter(gls.NEAREST)
+	}
+	textures[name] = tex
+	return tex
 }
@@ -0,0 +1,148 @@
+package blocktypes
+
+import (
+	"image"
+	"image/color"
+)
+
+// Brightness of the sides of inventory cubes, like Minetest's [inventorycube
+const (
+	cubeLeftShade  = 0.836660
+	cubeRightShade = 0.670820
+)
+
+// cubeFace is a face of an inventory cube as a parallelogram in an image of size 1: the top left corner of
+// the texture and the directions its x and y axes go in
+type cubeFace struct {
+	origin, x, y [2]float64
+}
+
+// Faces of an inventory cube seen from above the front right edge
+var (
+	cubeTop   = cubeFace{origin: [2]float64{0.5, 0}, x: [2]float64{0.5, 0.25}, y: [2]float64{-0.5, 0.25}}
+	cubeLeft  = cubeFace{origin: [2]float64{0, 0.25}, x: [2]float64{0.5, 0.25}, y: [2]float64{0, 0.5}}
+	cubeRight = cubeFace{origin: [2]float64{0.5, 0.5}, x: [2]float64{0.5, -0.25}, y: [2]float64{0, 0.5}}
+)
+
+// InventoryIcon draws the inventory image of a node from its tiles, which load decodes: a cube for nodes
+// drawn as blocks and the top tile for plants, torches and other flat nodes. It returns nil for airlike
+// nodes and nodes whose tiles fail to load.
+func InventoryIcon(def *NodeDefinition, load func(name string) *image.RGBA, size int) *image.RGBA {
+	if def == nil || def.Drawtype == DrawtypeAirlike {
+		return nil
+	}
+	faces := make([]*image.RGBA, TileCount)
+	for i, tile := range def.Tiles {
+		if img := load(tile.Name); img != nil {
+			tint := def.Color
+			if tile.Flags&TileFlagHasColor != 0 {
+				tint = tile.Color
+			}
+			faces[i] = tintImage(firstFrame(img, tile.Animation), tint, 1)
+		}
+	}
+	if faces[TileTop] == nil {
+		return nil
+	}
+	for i := range faces {
+		if faces[i] == nil {
+			faces[i] = faces[TileTop]
+		}
+	}
+
+	switch def.Drawtype {
+	case DrawtypeTorchlike, DrawtypeSignlike, DrawtypePlantlike, DrawtypeFirelike, DrawtypeRaillike:
+		return scaleImage(faces[TileTop], size)
+	}
+	return InventoryCube(faces[TileTop], faces[TileFront], faces[TileRight], size)
+}
+
+// InventoryCube draws a cube seen from above its front right edge into a square image of size pixels, with top
+// on the top face, left on the front face and right on the right face. Textures are sampled without
+// filtering, and the sides are darkened like Minetest's [inventorycube.
+func InventoryCube(top, left, right *image.RGBA, size int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	white := color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+	if top != nil {
+		drawFace(dst, top, cubeTop)
+	}
+	if left != nil {
+		drawFace(dst, tintImage(left, white, cubeLeftShade), cubeLeft)
+	}
+	if right != nil {
+		drawFace(dst, tintImage(right, white, cubeRightShade), cubeRight)
+	}
+	return dst
+}
+
+// drawFace draws the pixels of dst that fall on a face with the texel of src they show
+func drawFace(dst, src *image.RGBA, f cubeFace) {
+	size := float64(dst.Bounds().Dx())
+	b := src.Bounds()
+	det := f.x[0]*f.y[1] - f.y[0]*f.x[1]
+	for py := range dst.Bounds().Dy() {
+		for px := range dst.Bounds().Dx() {
+			dx := (float64(px)+0.5)/size - f.origin[0]
+			dy := (float64(py)+0.5)/size - f.origin[1]
+			u := (dx*f.y[1] - f.y[0]*dy) / det
+			v := (f.x[0]*dy - dx*f.x[1]) / det
+			if u < 0 || u >= 1 || v < 0 || v >= 1 {
+				continue
+			}
+			sx := b.Min.X + int(u*float64(b.Dx()))
+			sy := b.Min.Y + int(v*float64(b.Dy()))
+			dst.SetRGBA(px, py, src.RGBAAt(sx, sy))
+		}
+	}
+}
+
+// firstFrame returns the first frame of an animated texture, or the texture if it is not animated
+func firstFrame(img *image.RGBA, a TileAnimation) *image.RGBA {
+	b := img.Bounds()
+	frame := b
+	switch a.Type {
+	case TileAnimationVerticalFrames:
+		if a.AspectW == 0 || a.AspectH == 0 {
+			return img
+		}
+		frame.Max.Y = b.Min.Y + min(b.Dy(), max(1, b.Dx()*int(a.AspectH)/int(a.AspectW)))
+	case TileAnimationSheet2D:
+		frame.Max.X = b.Min.X + max(1, b.Dx()/max(1, int(a.FramesW)))
+		frame.Max.Y = b.Min.Y + max(1, b.Dy()/max(1, int(a.FramesH)))
+	default:
+		return img
+	}
+	return img.SubImage(frame).(*image.RGBA)
+}
+
+// tintImage returns a copy of img with its colors multiplied by tint and by shade, keeping the alpha
+func tintImage(img *image.RGBA, tint color.NRGBA, shade float64) *image.RGBA {
+	if img == nil {
+		return nil
+	}
+	b := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	scale := func(c uint8, t uint8) uint8 {
+		return uint8(float64(c) * float64(t) / 255 * shade)
+	}
+	for y := range b.Dy() {
+		for x := range b.Dx() {
+			// Pixels are premultiplied by alpha, which multiplying the colors down keeps valid
+			c := img.RGBAAt(b.Min.X+x, b.Min.Y+y)
+			dst.SetRGBA(x, y, color.RGBA{R: scale(c.R, tint.R), G: scale(c.G, tint.G), B: scale(c.B, tint.B), A: c.A})
+		}
+	}
+	return dst
+}
+
+// scaleImage stretches img to a square of size pixels without filtering
+func scaleImage(img *image.RGBA, size int) *image.RGBA {
+	b := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := range size {
+		for x := range size {
+			dst.SetRGBA(x, y, img.RGBAAt(b.Min.X+x*b.Dx()/size, b.Min.Y+y*b.Dy()/size))
+		}
+	}
+	return dst
+}
@@ -0,0 +1,145 @@
+package blocktypes
+
+import (
+	"bytes"
+	"flag"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden icons in testdata")
+
+// loadTile decodes a tile of testdata/tiles, and returns nil for tiles that do not exist like a texture loader
+func loadTile(t *testing.T) func(name string) *image.RGBA {
+	return func(name string) *image.RGBA {
+		if name == "" {
+			return nil
+		}
+		f, err := os.Open(filepath.Join("testdata", "tiles", name))
+		if err != nil {
+			return nil
+		}
+		defer f.Close()
+		img, err := png.Decode(f)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		rgba := image.NewRGBA(img.Bounds())
+		draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+		return rgba
+	}
+}
+
+// toNRGBA converts an image to unpremultiplied colors, as PNG files store them
+func toNRGBA(img image.Image) *image.NRGBA {
+	b := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+	return dst
+}
+
+// checkGolden compares an icon with testdata/name.png, or writes it there with -update
+func checkGolden(t *testing.T, name string, icon *image.RGBA) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".png")
+	if *update {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, icon); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("%v (run with -update to create it)", err)
+	}
+	defer f.Close()
+	golden, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, got := toNRGBA(golden), toNRGBA(icon)
+	if want.Bounds() != got.Bounds() {
+		t.Fatalf("icon is %v, golden %v", got.Bounds(), want.Bounds())
+	}
+	for y := range want.Bounds().Dy() {
+		for x := range want.Bounds().Dx() {
+			if g, w := got.NRGBAAt(x, y), want.NRGBAAt(x, y); g != w {
+				t.Fatalf("pixel %d,%d is %v, golden %v", x, y, g, w)
+			}
+		}
+	}
+}
+
+// tiledNode returns a node of a drawtype with the tiles named, from top to front
+func tiledNode(drawtype Drawtype, tiles ...string) *NodeDefinition {
+	def := NewNodeDefinition("test:node")
+	def.Drawtype = drawtype
+	for i, name := range tiles {
+		def.Tiles[i].Name = name
+	}
+	return def
+}
+
+func TestInventoryIcon(t *testing.T) {
+	sides := tiledNode(DrawtypeNormal, "top.png", "side.png", "side.png", "side.png", "side.png", "front.png")
+
+	tinted := tiledNode(DrawtypeNormal, "gray.png", "gray.png", "gray.png", "gray.png", "gray.png", "gray.png")
+	tinted.Color = color.NRGBA{R: 255, G: 128, A: 255}
+	tinted.Tiles[TileRight].Flags |= TileFlagHasColor
+	tinted.Tiles[TileRight].Color = color.NRGBA{R: 64, G: 128, B: 255, A: 255}
+
+	animated := tiledNode(DrawtypeNormal, "anim.png", "anim.png", "anim.png", "anim.png", "anim.png", "anim.png")
+	for i := range animated.Tiles {
+		animated.Tiles[i].Animation = TileAnimation{Type: TileAnimationVerticalFrames, AspectW: 1, AspectH: 1, Length: 1}
+	}
+	sheet := tiledNode(DrawtypeAllfaces, "sheet.png", "sheet.png", "sheet.png", "sheet.png", "sheet.png", "sheet.png")
+	for i := range sheet.Tiles {
+		sheet.Tiles[i].Animation = TileAnimation{Type: TileAnimationSheet2D, FramesW: 2, FramesH: 2, Length: 1}
+	}
+
+	for _, test := range []struct {
+		name string
+		def  *NodeDefinition
+		size int
+	}{
+		{"cube_grid", tiledNode(DrawtypeNormal, "grid.png", "grid.png", "grid.png", "grid.png", "grid.png", "grid.png"), 32},
+		{"cube_grid_small", tiledNode(DrawtypeNormal, "grid.png", "grid.png", "grid.png", "grid.png", "grid.png", "grid.png"), 16},
+		{"cube_sides", sides, 32},
+		{"cube_top_only", tiledNode(DrawtypeNormal, "top.png", "missing.png"), 32}, // Missing tiles show the top
+		{"cube_tinted", tinted, 32},
+		{"cube_vertical_frames", animated, 32},
+		{"cube_sheet", sheet, 32},
+		{"plantlike", tiledNode(DrawtypePlantlike, "plant.png"), 16},
+		{"torchlike", tiledNode(DrawtypeTorchlike, "front.png"), 12},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			icon := InventoryIcon(test.def, loadTile(t), test.size)
+			if icon == nil {
+				t.Fatal("no icon")
+			}
+			checkGolden(t, "icon_"+test.name, icon)
+		})
+	}
+}
+
+func TestInventoryIconMissing(t *testing.T) {
+	for name, def := range map[string]*NodeDefinition{
+		"airlike":          tiledNode(DrawtypeAirlike, "grid.png"),
+		"missing top tile": tiledNode(DrawtypeNormal, "missing.png", "grid.png", "grid.png"),
+		"no definition":    nil,
+	} {
+		if icon := InventoryIcon(def, loadTile(t), 32); icon != nil {
+			t.Errorf("%s: got an icon", name)
+		}
+	}
+}
@@ -0,0 +1,600 @@
+package blocktypes
+
+import (
+	"fmt"
+	"image/color"
+	"sort"
+
+	"bettermt/main/network"
+)
+
+// Version of the serialized node definition format
+const ContentFeaturesVersion = 13
+
+// Drawtype selects how a node is meshed
+type Drawtype uint8
+
+const (
+	DrawtypeNormal Drawtype = iota
+	DrawtypeAirlike
+	DrawtypeLiquid
+	DrawtypeFlowingLiquid
+	DrawtypeGlasslike
+	DrawtypeAllfaces
+	DrawtypeAllfacesOptional
+	DrawtypeTorchlike
+	DrawtypeSignlike
+	DrawtypePlantlike
+	DrawtypeFencelike
+	DrawtypeRaillike
+	DrawtypeNodebox
+	DrawtypeGlasslikeFramed
+	DrawtypeFirelike
+	DrawtypeGlasslikeFramedOptional
+	DrawtypeMesh
+	DrawtypePlantlikeRooted
+)
+
+// How param1 is used
+const (
+	ParamTypeNone  = uint8(0)
+	ParamTypeLight = uint8(1)
+)
+
+// What kind of liquid a node is
+const (
+	LiquidNone = uint8(iota)
+	LiquidFlowing
+	LiquidSource
+)
+
+// How param2 is used
+const (
+	ParamType2None = uint8(iota)
+	ParamType2Full
+	ParamType2FlowingLiquid
+	ParamType2Facedir
+	ParamType2Wallmounted
+	ParamType2Leveled
+	ParamType2Degrotate
+	ParamType2MeshOptions
+	ParamType2Color
+	ParamType2ColoredFacedir
+	ParamType2ColoredWallmounted
+	ParamType2GlasslikeLiquidLevel
+	ParamType2ColoredDegrotate
+	ParamType2FourDir
+	ParamType2ColoredFourDir
+)
+
+// Node box types
+const (
+	NodeBoxRegular = uint8(iota)
+	NodeBoxFixed
+	NodeBoxWallmounted
+	NodeBoxLeveled
+	NodeBoxConnected
+)
+
+// Tile animation types
+const (
+	TileAnimationNone = uint8(iota)
+	TileAnimationVerticalFrames
+	TileAnimationSheet2D
+)
+
+// Tile flags
+const (
+	TileFlagBackfaceCulling    = uint16(1 << 0)
+	TileFlagTileableHorizontal = uint16(1 << 1)
+	TileFlagTileableVertical   = uint16(1 << 2)
+	TileFlagHasColor           = uint16(1 << 3)
+	TileFlagHasScale           = uint16(1 << 4)
+	TileFlagHasAlignStyle      = uint16(1 << 5)
+)
+
+// Number of tiles and special tiles in a definition
+const (
+	TileCount        = 6
+	SpecialTileCount = 6
+)
+
+// Tile indices, in the order tiles are defined
+const (
+	TileTop = iota
+	TileBottom
+	TileRight
+	TileLeft
+	TileBack
+	TileFront
+)
+
+// Box is an axis aligned box in node units
+type Box struct {
+	Min [3]float32
+	Max [3]float32
+}
+
+// NodeBox describes the shape used for drawing, selection or collision
+type NodeBox struct {
+	Type uint8
+	// Fixed and leveled boxes, or the always present part of connected boxes
+	Fixed []Box
+	// Wallmounted boxes
+	WallTop, WallBottom, WallSide Box
+	// Connected boxes, in the order top, bottom, front, left, back, right
+	Connect      [6][]Box
+	Disconnected [6][]Box
+	// Connected boxes drawn with no or only vertical connections
+	DisconnectedAll   []Box
+	DisconnectedSides []Box
+}
+
+// TileAnimation describes an animated texture
+type TileAnimation struct {
+	Type uint8
+	// Vertical frames
+	AspectW, AspectH uint16
+	// Sheet
+	FramesW, FramesH uint8
+	Length           float32
+}
+
+// TileDef is one texture of a node
+type TileDef struct {
+	Name       string
+	Animation  TileAnimation
+	Flags      uint16
+	Color      color.NRGBA
+	Scale      uint8
+	AlignStyle uint8
+}
+
+// SoundSpec names a sound with its parameters
+type SoundSpec struct {
+	Name  string
+	Gain  float32
+	Pitch float32
+	Fade  float32
+}
+
+// NodeDefinition holds the properties of a node type, the ContentFeatures of the protocol
+type NodeDefinition struct {
+	Name        string
+	Groups      map[string]int16
+	ParamType   uint8
+	ParamType2  uint8
+	Drawtype    Drawtype
+	Mesh        string
+	VisualScale float32
+
+	Tiles        [TileCount]TileDef
+	OverlayTiles [TileCount]TileDef
+	SpecialTiles [SpecialTileCount]TileDef
+
+	Alpha           uint8
+	Color           color.NRGBA
+	PaletteName     string
+	Waving          uint8
+	ConnectSides    uint8
+	ConnectsTo      []uint16
+	PostEffectColor color.NRGBA
+	Leveled         uint8
+
+	LightPropagates    bool
+	SunlightPropagates bool
+	LightSource        uint8
+	IsGroundContent    bool
+
+	Walkable        bool
+	Pointable       bool
+	Diggable        bool
+	Climbable       bool
+	BuildableTo     bool
+	Rightclickable  bool
+	DamagePerSecond uint32
+
+	LiquidType        uint8
+	LiquidFlowing     string
+	LiquidSource      string
+	LiquidViscosity   uint8
+	LiquidRenewable   bool
+	LiquidRange       uint8
+	Drowning          uint8
+	Floodable         bool
+	NodeBox           NodeBox
+	SelectionBox      NodeBox
+	CollisionBox      NodeBox
+	SoundFootstep     SoundSpec
+	SoundDig          SoundSpec
+	SoundDug          SoundSpec
+	LegacyFacedir     bool
+	LegacyWallmounted bool
+
+	// Attributes added in later versions, absent from older servers
+	NodeDigPrediction     string
+	LeveledMax            uint8
+	AlphaMode             uint8
+	MoveResistance        uint8
+	LiquidMovePhysics     bool
+	PostEffectColorShaded bool
+}
+
+// NewNodeDefinition returns a definition with the defaults of a regular solid node
+func NewNodeDefinition(name string) *NodeDefinition {
+	def := &NodeDefinition{
+		Name:            name,
+		Groups:          make(map[string]int16),
+		VisualScale:     1,
+		Alpha:           255,
+		Color:           color.NRGBA{R: 255, G: 255, B: 255, A: 255},
+		IsGroundContent: true,
+		Walkable:        true,
+		Pointable:       true,
+		Diggable:        true,
+		LiquidRange:     8,
+		NodeBox:         NodeBox{Type: NodeBoxRegular},
+		SelectionBox:    NodeBox{Type: NodeBoxRegular},
+		CollisionBox:    NodeBox{Type: NodeBoxRegular},
+		LeveledMax:      127,
+	}
+	for i := range def.Tiles {
+		def.Tiles[i].Flags = TileFlagBackfaceCulling
+	}
+	return def
+}
+
+// DeserializeNodeDefinition reads one ContentFeatures entry
+func DeserializeNodeDefinition(r *network.Reader) (*NodeDefinition, error) {
+	if version := r.U8(); r.Err() == nil && version < ContentFeaturesVersion {
+		return nil, fmt.Errorf("unsupported node definition version %d", version)
+	}
+
+	def := &NodeDefinition{Groups: make(map[string]int16)}
+	def.Name = r.String16()
+	groupCount := int(r.U16())
+	for i := 0; i < groupCount && r.Err() == nil; i++ {
+		name := r.String16()
+		def.Groups[name] = r.S16()
+	}
+	def.ParamType = r.U8()
+	def.ParamType2 = r.U8()
+
+	// Visual
+	def.Drawtype = Drawtype(r.U8())
+	def.Mesh = r.String16()
+	def.VisualScale = r.F32()
+	if tileCount := r.U8(); r.Err() == nil && tileCount != TileCount {
+		return nil, fmt.Errorf("unexpected tile count %d", tileCount)
+	}
+	for i := range def.Tiles {
+		def.Tiles[i] = readTileDef(r)
+	}
+	for i := range def.OverlayTiles {
+		def.OverlayTiles[i] = readTileDef(r)
+	}
+	if specialCount := r.U8(); r.Err() == nil && specialCount != SpecialTileCount {
+		return nil, fmt.Errorf("unexpected special tile count %d", specialCount)
+	}
+	for i := range def.SpecialTiles {
+		def.SpecialTiles[i] = readTileDef(r)
+	}
+	def.Alpha = r.U8()
+	def.Color = color.NRGBA{R: r.U8(), G: r.U8(), B: r.U8(), A: 255}
+	def.PaletteName = r.String16()
+	def.Waving = r.U8()
+	def.ConnectSides = r.U8()
+	connectsCount := int(r.U16())
+	for i := 0; i < connectsCount && r.Err() == nil; i++ {
+		def.ConnectsTo = append(def.ConnectsTo, r.U16())
+	}
+	def.PostEffectColor = readARGB8(r)
+	def.Leveled = r.U8()
+
+	// Lighting
+	def.LightPropagates = r.Bool()
+	def.SunlightPropagates = r.Bool()
+	def.LightSource = r.U8()
+
+	// Map generation
+	def.IsGroundContent = r.Bool()
+
+	// Interaction
+	def.Walkable = r.Bool()
+	def.Pointable = r.Bool()
+	def.Diggable = r.Bool()
+	def.Climbable = r.Bool()
+	def.BuildableTo = r.Bool()
+	def.Rightclickable = r.Bool()
+	def.DamagePerSecond = r.U32()
+
+	// Liquid
+	def.LiquidType = r.U8()
+	def.LiquidFlowing = r.String16()
+	def.LiquidSource = r.String16()
+	def.LiquidViscosity = r.U8()
+	def.LiquidRenewable = r.Bool()
+	def.LiquidRange = r.U8()
+	def.Drowning = r.U8()
+	def.Floodable = r.Bool()
+
+	// Node boxes
+	def.NodeBox = readNodeBox(r)
+	def.SelectionBox = readNodeBox(r)
+	def.CollisionBox = readNodeBox(r)
+
+	// Sounds
+	def.SoundFootstep = ReadSoundSpec(r)
+	def.SoundDig = ReadSoundSpec(r)
+	def.SoundDug = ReadSoundSpec(r)
+
+	// Legacy
+	def.LegacyFacedir = r.Bool()
+	def.LegacyWallmounted = r.Bool()
+	if r.Err() != nil {
+		return nil, r.Err()
+	}
+
+	// Newer attributes are only present if the server sends them
+	def.LeveledMax = 127
+	if r.Len() > 0 {
+		def.NodeDigPrediction = r.String16()
+	}
+	if r.Len() > 0 {
+		def.LeveledMax = r.U8()
+	}
+	if r.Len() > 0 {
+		def.AlphaMode = r.U8()
+	}
+	if r.Len() > 0 {
+		def.MoveResistance = r.U8()
+	}
+	if r.Len() > 0 {
+		def.LiquidMovePhysics = r.Bool()
+	}
+	if r.Len() > 0 {
+		def.PostEffectColorShaded = r.Bool()
+	}
+	return def, r.Err()
+}
+
+// Serialize writes the definition as a ContentFeatures entry
+func (def *NodeDefinition) Serialize(w *network.Writer) {
+	w.U8(ContentFeaturesVersion)
+	w.String16(def.Name)
+	w.U16(uint16(len(def.Groups)))
+	for _, name := range sortedKeys(def.Groups) {
+		w.String16(name)
+		w.S16(def.Groups[name])
+	}
+	w.U8(def.ParamType)
+	w.U8(def.ParamType2)
+
+	w.U8(uint8(def.Drawtype))
+	w.String16(def.Mesh)
+	w.F32(def.VisualScale)
+	w.U8(TileCount)
+	for _, tile := range def.Tiles {
+		writeTileDef(w, tile)
+	}
+	for _, tile := range def.OverlayTiles {
+		writeTileDef(w, tile)
+	}
+	w.U8(SpecialTileCount)
+	for _, tile := range def.SpecialTiles {
+		writeTileDef(w, tile)
+	}
+	w.U8(def.Alpha)
+	w.U8(def.Color.R).U8(def.Color.G).U8(def.Color.B)
+	w.String16(def.PaletteName)
+	w.U8(def.Waving)
+	w.U8(def.ConnectSides)
+	w.U16(uint16(len(def.ConnectsTo)))
+	for _, id := range def.ConnectsTo {
+		w.U16(id)
+	}
+	writeARGB8(w, def.PostEffectColor)
+	w.U8(def.Leveled)
+
+	w.Bool(def.LightPropagates)
+	w.Bool(def.SunlightPropagates)
+	w.U8(def.LightSource)
+
+	w.Bool(def.IsGroundContent)
+
+	w.Bool(def.Walkable)
+	w.Bool(def.Pointable)
+	w.Bool(def.Diggable)
+	w.Bool(def.Climbable)
+	w.Bool(def.BuildableTo)
+	w.Bool(def.Rightclickable)
+	w.U32(def.DamagePerSecond)
+
+	w.U8(def.LiquidType)
+	w.String16(def.LiquidFlowing)
+	w.String16(def.LiquidSource)
+	w.U8(def.LiquidViscosity)
+	w.Bool(def.LiquidRenewable)
+	w.U8(def.LiquidRange)
+	w.U8(def.Drowning)
+	w.Bool(def.Floodable)
+
+	writeNodeBox(w, def.NodeBox)
+	writeNodeBox(w, def.SelectionBox)
+	writeNodeBox(w, def.CollisionBox)
+
+	WriteSoundSpec(w, def.SoundFootstep)
+	WriteSoundSpec(w, def.SoundDig)
+	WriteSoundSpec(w, def.SoundDug)
+
+	w.Bool(def.LegacyFacedir)
+	w.Bool(def.LegacyWallmounted)
+
+	w.String16(def.NodeDigPrediction)
+	w.U8(def.LeveledMax)
+	w.U8(def.AlphaMode)
+	w.U8(def.MoveResistance)
+	w.Bool(def.LiquidMovePhysics)
+	w.Bool(def.PostEffectColorShaded)
+}
+
+func readTileDef(r *network.Reader) TileDef {
+	var tile TileDef
+	r.U8() // Version
+	tile.Name = r.String16()
+	tile.Animation = ReadTileAnimation(r)
+	tile.Flags = r.U16()
+	if tile.Flags&TileFlagHasColor != 0 {
+		tile.Color = color.NRGBA{R: r.U8(), G: r.U8(), B: r.U8(), A: 255}
+	}
+	if tile.Flags&TileFlagHasScale != 0 {
+		tile.Scale = r.U8()
+	}
+	if tile.Flags&TileFlagHasAlignStyle != 0 {
+		tile.AlignStyle = r.U8()
+	}
+	return tile
+}
+
+func writeTileDef(w *network.Writer, tile TileDef) {
+	w.U8(6) // Version
+	w.String16(tile.Name)
+	WriteTileAnimation(w, tile.Animation)
+	w.U16(tile.Flags)
+	if tile.Flags&TileFlagHasColor != 0 {
+		w.U8(tile.Color.R).U8(tile.Color.G).U8(tile.Color.B)
+	}
+	if tile.Flags&TileFlagHasScale != 0 {
+		w.U8(tile.Scale)
+	}
+	if tile.Flags&TileFlagHasAlignStyle != 0 {
+		w.U8(tile.AlignStyle)
+	}
+}
+
+// ReadTileAnimation reads animation parameters, shared by tiles, particles and HUD images
+func ReadTileAnimation(r *network.Reader) TileAnimation {
+	anim := TileAnimation{Type: r.U8()}
+	switch anim.Type {
+	case TileAnimationVerticalFrames:
+		anim.AspectW = r.U16()
+		anim.AspectH = r.U16()
+		anim.Length = r.F32()
+	case TileAnimationSheet2D:
+		anim.FramesW = r.U8()
+		anim.FramesH = r.U8()
+		anim.Length = r.F32()
+	}
+	return anim
+}
+
+// WriteTileAnimation writes animation parameters
+func WriteTileAnimation(w *network.Writer, anim TileAnimation) {
+	w.U8(anim.Type)
+	switch anim.Type {
+	case TileAnimationVerticalFrames:
+		w.U16(anim.AspectW).U16(anim.AspectH).F32(anim.Length)
+	case TileAnimationSheet2D:
+		w.U8(anim.FramesW).U8(anim.FramesH).F32(anim.Length)
+	}
+}
+
+func readBoxes(r *network.Reader) []Box {
+	count := int(r.U16())
+	var boxes []Box
+	for i := 0; i < count && r.Err() == nil; i++ {
+		boxes = append(boxes, readBox(r))
+	}
+	return boxes
+}
+
+func writeBoxes(w *network.Writer, boxes []Box) {
+	w.U16(uint16(len(boxes)))
+	for _, box := range boxes {
+		writeBox(w, box)
+	}
+}
+
+func readBox(r *network.Reader) Box {
+	return Box{Min: r.V3F32(), Max: r.V3F32()}
+}
+
+func writeBox(w *network.Writer, box Box) {
+	w.V3F32(box.Min).V3F32(box.Max)
+}
+
+func readNodeBox(r *network.Reader) NodeBox {
+	r.U8() // Version
+	box := NodeBox{Type: r.U8()}
+	switch box.Type {
+	case NodeBoxFixed, NodeBoxLeveled:
+		box.Fixed = readBoxes(r)
+	case NodeBoxWallmounted:
+		box.WallTop = readBox(r)
+		box.WallBottom = readBox(r)
+		box.WallSide = readBox(r)
+	case NodeBoxConnected:
+		box.Fixed = readBoxes(r)
+		for i := range box.Connect {
+			box.Connect[i] = readBoxes(r)
+		}
+		for i := range box.Disconnected {
+			box.Disconnected[i] = readBoxes(r)
+		}
+		box.DisconnectedAll = readBoxes(r)
+		box.DisconnectedSides = readBoxes(r)
+	}
+	return box
+}
+
+func writeNodeBox(w *network.Writer, box NodeBox) {
+	w.U8(6) // Version
+	w.U8(box.Type)
+	switch box.Type {
+	case NodeBoxFixed, NodeBoxLeveled:
+		writeBoxes(w, box.Fixed)
+	case NodeBoxWallmounted:
+		writeBox(w, box.WallTop)
+		writeBox(w, box.WallBottom)
+		writeBox(w, box.WallSide)
+	case NodeBoxConnected:
+		writeBoxes(w, box.Fixed)
+		for _, boxes := range box.Connect {
+			writeBoxes(w, boxes)
+		}
+		for _, boxes := range box.Disconnected {
+			writeBoxes(w, boxes)
+		}
+		writeBoxes(w, box.DisconnectedAll)
+		writeBoxes(w, box.DisconnectedSides)
+	}
+}
+
+// ReadSoundSpec reads a sound name with its gain, pitch and fade
+func ReadSoundSpec(r *network.Reader) SoundSpec {
+	return SoundSpec{Name: r.String16(), Gain: r.F32(), Pitch: r.F32(), Fade: r.F32()}
+}
+
+// WriteSoundSpec writes a sound name with its gain, pitch and fade
+func WriteSoundSpec(w *network.Writer, sound SoundSpec) {
+	w.String16(sound.Name).F32(sound.Gain).F32(sound.Pitch).F32(sound.Fade)
+}
+
+func readARGB8(r *network.Reader) color.NRGBA {
+	a := r.U8()
+	return color.NRGBA{R: r.U8(), G: r.U8(), B: r.U8(), A: a}
+}
+
+func writeARGB8(w *network.Writer, c color.NRGBA) {
+	w.U8(c.A).U8(c.R).U8(c.G).U8(c.B)
+}
+
+// sortedKeys returns the keys of a group map in a stable order
+func sortedKeys(groups map[string]int16) []string {
+	keys := make([]string, 0, len(groups))
+	for name := range groups {
+		keys = append(keys, name)
+	}
+	sort.Strings(keys)
+	return keys
+}
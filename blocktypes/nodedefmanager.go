@@ -0,0 +1,217 @@
+package blocktypes
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"sort"
+
+	"bettermt/main/network"
+)
+
+// Content IDs reserved by the Minetest protocol
+const (
+	ContentUnknown = uint16(125)
+	ContentAir     = uint16(126)
+	ContentIgnore  = uint16(127)
+)
+
+// Content IDs of the nodes placed by the built in world generator
+const (
+	ContentGrass = uint16(1)
+	ContentDirt  = uint16(2)
+	ContentStone = uint16(3)
+)
+
+// Version of the serialized node definition list
+const nodeDefListVersion = 1
+
+// NodeDefManager maps content IDs to node definitions
+type NodeDefManager struct {
+	defs map[uint16]*NodeDefinition
+	ids  map[string]uint16
+}
+
+// NewNodeDefManager creates a registry holding only the reserved unknown, air and ignore nodes
+func NewNodeDefManager() *NodeDefManager {
+	m := &NodeDefManager{
+		defs: make(map[uint16]*NodeDefinition),
+		ids:  make(map[string]uint16),
+	}
+
+	unknown := NewNodeDefinition("unknown")
+	for i := range unknown.Tiles {
+		unknown.Tiles[i].Name = "unknown_node.png"
+	}
+	m.Set(ContentUnknown, unknown)
+
+	air := NewNodeDefinition("air")
+	air.Drawtype = DrawtypeAirlike
+	air.ParamType = ParamTypeLight
+	air.LightPropagates = true
+	air.SunlightPropagates = true
+	air.Walkable = false
+	air.Pointable = false
+	air.Diggable = false
+	air.BuildableTo = true
+	air.Floodable = true
+	m.Set(ContentAir, air)
+
+	ignore := NewNodeDefinition("ignore")
+	ignore.Drawtype = DrawtypeAirlike
+	ignore.Walkable = false
+	ignore.Pointable = false
+	ignore.Diggable = false
+	ignore.BuildableTo = true
+	m.Set(ContentIgnore, ignore)
+	return m
+}
+
+// DefaultNodeDefManager creates a registry with the nodes used by the built in world generator
+func DefaultNodeDefManager() *NodeDefManager {
+	m := NewNodeDefManager()
+	for id, node := range map[uint16]struct{ texture, group string }{
+		ContentGrass: {"grass", "crumbly"},
+		ContentDirt:  {"dirt", "crumbly"},
+		ContentStone: {"stone", "cracky"},
+	} {
+		def := NewNodeDefinition("bettermt:" + node.texture)
+		for i := range def.Tiles {
+			def.Tiles[i].Name = node.texture + ".png"
+		}
+		def.Groups[node.group] = 3
+		m.Set(id, def)
+	}
+	return m
+}
+
+// Set registers a definition under a content ID, replacing any previous one
+func (m *NodeDefManager) Set(id uint16, def *NodeDefinition) {
+	if old, exists := m.defs[id]; exists && m.ids[old.Name] == id {
+		delete(m.ids, old.Name)
+	}
+	m.defs[id] = def
+	m.ids[def.Name] = id
+}
+
+// Get returns the definition of a content ID, or the unknown node if there is none
+func (m *NodeDefManager) Get(id uint16) *NodeDefinition {
+	if def, exists := m.defs[id]; exists {
+		return def
+	}
+	return m.defs[ContentUnknown]
+}
+
+// Lookup returns the definition of a content ID and whether it is registered
+func (m *NodeDefManager) Lookup(id uint16) (*NodeDefinition, bool) {
+	def, exists := m.defs[id]
+	return def, exists
+}
+
+// GetID returns the content ID registered for a node name
+func (m *NodeDefManager) GetID(name string) (uint16, bool) {
+	id, exists := m.ids[name]
+	return id, exists
+}
+
+// IDs returns every registered content ID in ascending order
+func (m *NodeDefManager) IDs() []uint16 {
+	ids := make([]uint16, 0, len(m.defs))
+	for id := range m.defs {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// IsDrawnAsCube reports whether a node is meshed as a full cube
+func (m *NodeDefManager) IsDrawnAsCube(id uint16) bool {
+	switch m.Get(id).Drawtype {
+	case DrawtypeNormal, DrawtypeLiquid, DrawtypeFlowingLiquid, DrawtypeGlasslike, DrawtypeGlasslikeFramed,
+		DrawtypeGlasslikeFramedOptional, DrawtypeAllfaces, DrawtypeAllfacesOptional:
+		return true
+	}
+	return false
+}
+
+// HidesFace reports whether the face of node id touching neighbour is hidden by it
+func (m *NodeDefManager) HidesFace(id, neighbour uint16) bool {
+	switch m.Get(neighbour).Drawtype {
+	case DrawtypeNormal:
+		return true
+	case DrawtypeLiquid, DrawtypeFlowingLiquid, DrawtypeGlasslike, DrawtypeGlasslikeFramed, DrawtypeGlasslikeFramedOptional:
+		// Faces between two nodes of the same see-through kind are not drawn
+		return id == neighbour
+	}
+	return false
+}
+
+// Deserialize replaces the registry with the zlib compressed definitions sent in TOCLIENT_NODEDEF
+func (m *NodeDefManager) Deserialize(compressed []byte) error {
+	zr, err := zlib.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return err
+	}
+
+	r := network.NewReader(data)
+	if version := r.U8(); r.Err() == nil && version != nodeDefListVersion {
+		return fmt.Errorf("unsupported node definition list version %d", version)
+	}
+	count := int(r.U16())
+	list := network.NewReader(r.Bytes(int(r.U32())))
+	if r.Err() != nil {
+		return r.Err()
+	}
+
+	for i := 0; i < count; i++ {
+		id := list.U16()
+		entry := network.NewReader(list.Bytes(int(list.U16())))
+		if list.Err() != nil {
+			return list.Err()
+		}
+		def, err := DeserializeNodeDefinition(entry)
+		if err != nil {
+			return fmt.Errorf("node %d: %w", id, err)
+		}
+		m.Set(id, def)
+	}
+	return nil
+}
+
+// Serialize writes the definitions in the zlib compressed form of TOCLIENT_NODEDEF, leaving out the reserved nodes
+func (m *NodeDefManager) Serialize() ([]byte, error) {
+	var list network.Writer
+	count := 0
+	for _, id := range m.IDs() {
+		if id == ContentUnknown || id == ContentAir || id == ContentIgnore {
+			continue
+		}
+		var entry network.Writer
+		m.defs[id].Serialize(&entry)
+		list.U16(id)
+		list.U16(uint16(len(entry.Bytes())))
+		list.Raw(entry.Bytes())
+		count++
+	}
+
+	var w network.Writer
+	w.U8(nodeDefListVersion)
+	w.U16(uint16(count))
+	w.U32(uint32(len(list.Bytes())))
+	w.Raw(list.Bytes())
+
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	if _, err := zw.Write(w.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,173 @@
+package capture
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Header identifying capture files
+const (
+	magic   = "BMTCAP"
+	version = 1
+)
+
+// Record flags
+const (
+	flagOutbound = 1 << 0
+	flagReliable = 1 << 1
+)
+
+var ErrNotCapture = errors.New("not a capture file")
+
+// Direction tells whether a packet was received or sent by the side that recorded it
+type Direction uint8
+
+const (
+	Inbound Direction = iota
+	Outbound
+)
+
+func (d Direction) String() string {
+	if d == Outbound {
+		return "out"
+	}
+	return "in"
+}
+
+// Record is one captured packet
+type Record struct {
+	Time      time.Time
+	Direction Direction
+	Channel   uint8
+	Reliable  bool
+	Data      []byte
+}
+
+// Writer appends records to a capture file. It is safe for concurrent use.
+type Writer struct {
+	mu   sync.Mutex
+	w    *bufio.Writer
+	last time.Time
+}
+
+// NewWriter writes the capture header and returns a writer for the records
+func NewWriter(w io.Writer) (*Writer, error) {
+	start := time.Now()
+	bw := bufio.NewWriter(w)
+	bw.WriteString(magic)
+	bw.WriteByte(version)
+	binary.Write(bw, binary.BigEndian, start.UnixMicro())
+	if err := bw.Flush(); err != nil {
+		return nil, err
+	}
+	return &Writer{w: bw, last: time.UnixMicro(start.UnixMicro())}, nil
+}
+
+// Write appends a record. Each record stores the microseconds elapsed since the previous one.
+func (cw *Writer) Write(rec Record) error {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+
+	flags := byte(0)
+	if rec.Direction == Outbound {
+		flags |= flagOutbound
+	}
+	if rec.Reliable {
+		flags |= flagReliable
+	}
+	delta := max(rec.Time.Sub(cw.last).Microseconds(), 0)
+	cw.last = cw.last.Add(time.Duration(delta) * time.Microsecond)
+
+	var buf [2 + 2*binary.MaxVarintLen64]byte
+	buf[0] = flags
+	buf[1] = rec.Channel
+	n := 2 + binary.PutUvarint(buf[2:], uint64(delta))
+	n += binary.PutUvarint(buf[n:], uint64(len(rec.Data)))
+	cw.w.Write(buf[:n])
+	cw.w.Write(rec.Data)
+	return cw.w.Flush()
+}
+
+// Reader reads the records of a capture file in order
+type Reader struct {
+	r    *bufio.Reader
+	last time.Time
+}
+
+// NewReader checks the capture header
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, len(magic)+1+8)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, ErrNotCapture
+	}
+	if string(header[:len(magic)]) != magic {
+		return nil, ErrNotCapture
+	}
+	if header[len(magic)] != version {
+		return nil, fmt.Errorf("unsupported capture version %d", header[len(magic)])
+	}
+	start := int64(binary.BigEndian.Uint64(header[len(magic)+1:]))
+	return &Reader{r: br, last: time.UnixMicro(start)}, nil
+}
+
+// Next returns the next record, or io.EOF after the last one
+func (cr *Reader) Next() (Record, error) {
+	flags, err := cr.r.ReadByte()
+	if err != nil {
+		return Record{}, err
+	}
+	channel, err := cr.r.ReadByte()
+	if err != nil {
+		return Record{}, io.ErrUnexpectedEOF
+	}
+	delta, err := binary.ReadUvarint(cr.r)
+	if err != nil {
+		return Record{}, io.ErrUnexpectedEOF
+	}
+	length, err := binary.ReadUvarint(cr.r)
+	if err != nil {
+		return Record{}, io.ErrUnexpectedEOF
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(cr.r, data); err != nil {
+		return Record{}, io.ErrUnexpectedEOF
+	}
+
+	cr.last = cr.last.Add(time.Duration(delta) * time.Microsecond)
+	rec := Record{
+		Time:      cr.last,
+		Direction: Inbound,
+		Channel:   channel,
+		Reliable:  flags&flagReliable != 0,
+		Data:      data,
+	}
+	if flags&flagOutbound != 0 {
+		rec.Direction = Outbound
+	}
+	return rec, nil
+}
+
+// ReadAll reads every record of a capture file
+func ReadAll(r io.Reader) ([]Record, error) {
+	cr, err := NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	var records []Record
+	for {
+		rec, err := cr.Next()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return records, err
+		}
+		records = append(records, rec)
+	}
+}
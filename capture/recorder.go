@@ -0,0 +1,41 @@
+package capture
+
+import (
+	"fmt"
+	"time"
+
+	"bettermt/main/network"
+)
+
+// Recorder is a transport that writes every packet passing through it to a capture
+type Recorder struct {
+	network.Transport
+	w *Writer
+}
+
+// NewRecorder records the packets sent and received over t
+func NewRecorder(t network.Transport, w *Writer) *Recorder {
+	return &Recorder{Transport: t, w: w}
+}
+
+// Send records and sends a packet
+func (r *Recorder) Send(channel uint8, data []byte, reliable bool) error {
+	r.record(Record{Time: time.Now(), Direction: Outbound, Channel: channel, Reliable: reliable, Data: data})
+	return r.Transport.Send(channel, data, reliable)
+}
+
+// Recv receives and records a packet
+func (r *Recorder) Recv() (network.Packet, error) {
+	p, err := r.Transport.Recv()
+	if err == nil {
+		r.record(Record{Time: time.Now(), Direction: Inbound, Channel: p.Channel, Reliable: p.Reliable, Data: p.Data})
+	}
+	return p, err
+}
+
+// record writes a record, reporting failures without disturbing the session
+func (r *Recorder) record(rec Record) {
+	if err := r.w.Write(rec); err != nil {
+		fmt.Printf("Failed to record packet: %v\n", err)
+	}
+}
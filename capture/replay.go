@@ -0,0 +1,57 @@
+package capture
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"bettermt/main/network"
+)
+
+var ErrReplayClosed = errors.New("replay transport has no packets to receive")
+
+// Handler processes the packets a server sent, like a client.Client fed by hand
+type Handler interface {
+	// Attach sets the transport the handler sends its answers on
+	Attach(conn network.Transport)
+	// HandlePacket processes one packet, starting with its command ID
+	HandlePacket(data []byte) error
+}
+
+// discard is the transport of a replayed client: what it sends goes nowhere
+type discard struct {
+	once sync.Once
+	done chan struct{}
+}
+
+func (d *discard) Send(channel uint8, data []byte, reliable bool) error { return nil }
+
+func (d *discard) Recv() (network.Packet, error) {
+	<-d.done
+	return network.Packet{}, ErrReplayClosed
+}
+
+func (d *discard) Close() error {
+	d.once.Do(func() { close(d.done) })
+	return nil
+}
+
+func (d *discard) Done() <-chan struct{} { return d.done }
+
+func (d *discard) Err() error { return nil }
+
+// Replay feeds the inbound packets of a capture to h, in recorded order and without timing. Packets h sends
+// in response are discarded. Errors of single packets do not stop the replay; they are all returned together.
+func Replay(h Handler, records []Record) error {
+	h.Attach(&discard{done: make(chan struct{})})
+	var errs []error
+	for i, rec := range records {
+		if rec.Direction != Inbound {
+			continue
+		}
+		if err := h.HandlePacket(rec.Data); err != nil {
+			errs = append(errs, fmt.Errorf("record %d: %w", i, err))
+		}
+	}
+	return errors.Join(errs...)
+}
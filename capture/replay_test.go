@@ -0,0 +1,131 @@
+package capture_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"bettermt/main/blocktypes"
+	"bettermt/main/capture"
+	"bettermt/main/client"
+	"bettermt/main/meshbuilder"
+	"bettermt/main/network"
+)
+
+// readFixture reads testdata/blocks.bmtcap: the INIT a client sent, then the HELLO, NODEDEF and BLOCKDATA it got
+// back. The block at (1,-1,2) holds test:stone below y 4, air above and one test:glass node at (3,4,5).
+func readFixture(t *testing.T) []capture.Record {
+	t.Helper()
+	f, err := os.Open("testdata/blocks.bmtcap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	records, err := capture.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func TestReplayIntoClient(t *testing.T) {
+	records := readFixture(t)
+	if len(records) != 4 || records[0].Direction != capture.Outbound {
+		t.Fatalf("read %d records, want the INIT and three answers", len(records))
+	}
+
+	world := meshbuilder.NewWorld(16)
+	c := client.New("tester", "", world)
+	if err := capture.Replay(c, records); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.SerializationVersion != 29 || c.ProtocolVersion != 44 {
+		t.Fatalf("versions %d, %d from the HELLO, want 29, 44", c.SerializationVersion, c.ProtocolVersion)
+	}
+	defs := c.NodeDefs()
+	if defs == nil {
+		t.Fatal("no node definitions")
+	}
+	for name, want := range map[string]uint16{"test:stone": 10, "test:glass": 11, "air": blocktypes.ContentAir} {
+		if id, ok := defs.GetID(name); !ok || id != want {
+			t.Errorf("%s: id %d, %v, want %d", name, id, ok, want)
+		}
+	}
+	if def, _ := defs.Lookup(11); def == nil || def.Drawtype != blocktypes.DrawtypeGlasslike {
+		t.Errorf("test:glass definition %+v", def)
+	}
+
+	if changed := world.ApplyPending(); len(changed) != 1 || changed[0] != [3]int32{16, -16, 32} {
+		t.Fatalf("changed chunks %v, want the one at (16,-16,32)", changed)
+	}
+	origin := [3]int32{16, -16, 32}
+	for _, test := range []struct {
+		pos  [3]int32
+		want uint16
+	}{
+		{[3]int32{0, 0, 0}, 10},
+		{[3]int32{15, 3, 15}, 10},
+		{[3]int32{0, 4, 0}, blocktypes.ContentAir},
+		{[3]int32{3, 4, 5}, 11},
+		{[3]int32{15, 15, 15}, blocktypes.ContentAir},
+	} {
+		got, err := meshbuilder.GetBlockInWorld(world, origin[0]+test.pos[0], origin[1]+test.pos[1], origin[2]+test.pos[2])
+		if err != nil || uint16(got) != test.want {
+			t.Errorf("node %v: %d, %v, want %d", test.pos, got, err, test.want)
+		}
+	}
+}
+
+func TestReplayGoesOnAfterErrors(t *testing.T) {
+	records := readFixture(t)
+	// A truncated copy of the NODEDEF packet fails, but the packets after it are still handled
+	broken := records[2]
+	broken.Data = broken.Data[:8]
+	records = append(records[:2], append([]capture.Record{broken}, records[2:]...)...)
+
+	world := meshbuilder.NewWorld(16)
+	c := client.New("tester", "", world)
+	err := capture.Replay(c, records)
+	if err == nil || !strings.Contains(err.Error(), "record 2") {
+		t.Fatalf("got %v, want an error for record 2", err)
+	}
+	if c.NodeDefs() == nil {
+		t.Fatal("node definitions after the broken packet not handled")
+	}
+	if len(world.ApplyPending()) != 1 {
+		t.Fatal("block after the broken packet not handled")
+	}
+}
+
+// recorder is a Handler that keeps the commands of the packets it gets
+type recorder struct {
+	attached bool
+	commands []uint16
+}
+
+func (r *recorder) Attach(conn network.Transport) { r.attached = conn != nil }
+
+func (r *recorder) HandlePacket(data []byte) error {
+	r.commands = append(r.commands, uint16(data[0])<<8|uint16(data[1]))
+	return nil
+}
+
+func TestReplaySkipsOutbound(t *testing.T) {
+	var h recorder
+	if err := capture.Replay(&h, readFixture(t)); err != nil {
+		t.Fatal(err)
+	}
+	if !h.attached {
+		t.Fatal("handler not attached to a transport")
+	}
+	want := []uint16{network.ToClientHello, network.ToClientNodeDef, network.ToClientBlockData}
+	if len(h.commands) != len(want) {
+		t.Fatalf("handled %#x, want %#x", h.commands, want)
+	}
+	for i := range want {
+		if h.commands[i] != want[i] {
+			t.Fatalf("handled %#x, want %#x", h.commands, want)
+		}
+	}
+}
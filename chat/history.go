@@ -0,0 +1,120 @@
+package chat
+
+import (
+	"sort"
+	"sync"
+)
+
+const (
+	// Number of messages kept for scrollback by default
+	DefaultHistoryLimit = 500
+	// Number of sent lines that can be recalled by default
+	DefaultInputHistoryLimit = 100
+)
+
+// History is a bounded, thread-safe buffer of received messages
+type History struct {
+	mu       sync.Mutex
+	messages []Message
+	limit    int
+	nextID   uint64
+	version  uint64
+}
+
+// NewHistory creates a history keeping at most limit messages
+func NewHistory(limit int) *History {
+	return &History{limit: limit, nextID: 1}
+}
+
+// Add appends a message, dropping the oldest one once the history is full
+func (h *History) Add(m Message) Message {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	m.ID = h.nextID
+	h.nextID++
+	h.messages = append(h.messages, m)
+	if h.limit > 0 && len(h.messages) > h.limit {
+		h.messages = append(h.messages[:0], h.messages[len(h.messages)-h.limit:]...)
+	}
+	h.version++
+	return m
+}
+
+// Messages returns a copy of the messages, oldest first
+func (h *History) Messages() []Message {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]Message(nil), h.messages...)
+}
+
+// MessagesAfter returns the messages added after the one with the given ID, oldest first
+func (h *History) MessagesAfter(id uint64) []Message {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	start := sort.Search(len(h.messages), func(i int) bool { return h.messages[i].ID > id })
+	return append([]Message(nil), h.messages[start:]...)
+}
+
+// Len returns the number of messages kept
+func (h *History) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.messages)
+}
+
+// Version changes every time a message is added, so readers can tell when to redraw
+func (h *History) Version() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.version
+}
+
+// InputHistory holds the lines typed by the player for recall with the arrow keys
+type InputHistory struct {
+	entries []string
+	limit   int
+	index   int    // Entry being shown, len(entries) while editing a new line
+	draft   string // Line being typed before browsing the history
+}
+
+// NewInputHistory creates an input history keeping at most limit lines
+func NewInputHistory(limit int) *InputHistory {
+	return &InputHistory{limit: limit}
+}
+
+// Add records a sent line and goes back to editing a new one
+func (h *InputHistory) Add(line string) {
+	if line != "" && (len(h.entries) == 0 || h.entries[len(h.entries)-1] != line) {
+		h.entries = append(h.entries, line)
+		if h.limit > 0 && len(h.entries) > h.limit {
+			h.entries = h.entries[len(h.entries)-h.limit:]
+		}
+	}
+	h.index = len(h.entries)
+	h.draft = ""
+}
+
+// Prev returns the line sent before the one shown, current is the text being edited
+func (h *InputHistory) Prev(current string) string {
+	if h.index == len(h.entries) {
+		h.draft = current
+	}
+	if h.index == 0 {
+		if len(h.entries) == 0 {
+			return current
+		}
+		return h.entries[0]
+	}
+	h.index--
+	return h.entries[h.index]
+}
+
+// Next returns the line sent after the one shown, or the draft once past the newest
+func (h *InputHistory) Next() string {
+	if h.index >= len(h.entries)-1 {
+		h.index = len(h.entries)
+		return h.draft
+	}
+	h.index++
+	return h.entries[h.index]
+}
@@ -0,0 +1,74 @@
+package chat
+
+import (
+	"strings"
+	"time"
+)
+
+// Type is the kind of a chat message, deciding how it is displayed
+type Type uint8
+
+const (
+	TypeRaw Type = iota
+	TypeNormal
+	TypeAnnounce
+	TypeSystem
+)
+
+func (t Type) String() string {
+	switch t {
+	case TypeRaw:
+		return "raw"
+	case TypeNormal:
+		return "normal"
+	case TypeAnnounce:
+		return "announce"
+	case TypeSystem:
+		return "system"
+	default:
+		return "unknown"
+	}
+}
+
+// Message is one entry of the chat
+type Message struct {
+	ID        uint64 // Assigned by the History the message is added to
+	Type      Type
+	Sender    string
+	Text      string
+	Timestamp time.Time
+}
+
+// String formats a message the way it is shown in the console, without escape sequences
+func (m Message) String() string {
+	text := StripEscapes(m.Text)
+	if m.Type == TypeNormal && m.Sender != "" {
+		return "<" + StripEscapes(m.Sender) + "> " + text
+	}
+	return text
+}
+
+// StripEscapes removes the color and translation escape sequences servers embed in text
+func StripEscapes(text string) string {
+	if !strings.ContainsRune(text, '\x1b') {
+		return text
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] != '\x1b' {
+			b.WriteByte(text[i])
+			continue
+		}
+		i++
+		if i < len(text) && text[i] == '(' {
+			// Sequences with arguments such as (c@#ff0000) or (T@domain) run up to the closing parenthesis
+			end := strings.IndexByte(text[i:], ')')
+			if end < 0 {
+				break
+			}
+			i += end
+		}
+	}
+	return b.String()
+}
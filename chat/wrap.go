@@ -0,0 +1,38 @@
+package chat
+
+import "strings"
+
+// Wrap splits text into lines no wider than width, breaking at spaces where possible.
+// measure returns the width of a piece of text in the same unit as width.
+func Wrap(text string, width float32, measure func(string) float32) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		lines = append(lines, wrapParagraph(paragraph, width, measure)...)
+	}
+	return lines
+}
+
+// wrapParagraph wraps a single line of text
+func wrapParagraph(text string, width float32, measure func(string) float32) []string {
+	var lines []string
+	for measure(text) > width {
+		runes := []rune(text)
+
+		// Find the longest prefix that fits, always taking at least one character
+		fit := 1
+		for fit < len(runes) && measure(string(runes[:fit+1])) <= width {
+			fit++
+		}
+
+		// Prefer breaking at a space, unless the prefix already ends at one
+		cut := fit
+		if fit < len(runes) && runes[fit] != ' ' {
+			if space := strings.LastIndexByte(string(runes[:fit]), ' '); space > 0 {
+				cut = len([]rune(string(runes[:fit])[:space]))
+			}
+		}
+		lines = append(lines, strings.TrimRight(string(runes[:cut]), " "))
+		text = strings.TrimLeft(string(runes[cut:]), " ")
+	}
+	return append(lines, text)
+}
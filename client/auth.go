@@ -0,0 +1,76 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+
+	"bettermt/main/auth"
+	"bettermt/main/network"
+	"bettermt/main/protocol"
+)
+
+var ErrUnexpectedChallenge = errors.New("SRP challenge received without an exchange in progress")
+
+// chooseAuthMechanism picks a mechanism in the same order of preference as the reference client
+func chooseAuthMechanism(mechanisms uint32) uint32 {
+	switch {
+	case mechanisms&network.AuthMechanismSRP != 0:
+		return network.AuthMechanismSRP
+	case mechanisms&network.AuthMechanismFirstSRP != 0:
+		return network.AuthMechanismFirstSRP
+	case mechanisms&network.AuthMechanismLegacyPassword != 0:
+		return network.AuthMechanismLegacyPassword
+	default:
+		return network.AuthMechanismNone
+	}
+}
+
+// startAuth begins authenticating with one of the mechanisms offered by the server
+func (c *Client) startAuth(mechanisms uint32) error {
+	c.authMechanism = chooseAuthMechanism(mechanisms)
+
+	switch c.authMechanism {
+	case network.AuthMechanismFirstSRP:
+		// The account does not exist yet, so register it by sending a verifier for our password
+		salt, verifier, err := auth.CreateVerifier(c.Name, c.password)
+		if err != nil {
+			return c.fail(err)
+		}
+		return c.send(protocol.FirstSRP{Salt: salt, Verifier: verifier, EmptyPassword: c.password == ""}.Write())
+
+	case network.AuthMechanismSRP, network.AuthMechanismLegacyPassword:
+		password := c.password
+		basedOn := uint8(1)
+		if c.authMechanism == network.AuthMechanismLegacyPassword {
+			// Migrated accounts derived their verifier from the legacy password hash
+			password = auth.LegacyPassword(c.Name, c.password)
+			basedOn = 0
+		}
+		srp, err := auth.NewClient(c.Name, password)
+		if err != nil {
+			return c.fail(err)
+		}
+		c.srp = srp
+		return c.send(protocol.SRPBytesA{BytesA: srp.BytesA(), BasedOn: basedOn}.Write())
+
+	default:
+		return c.fail(fmt.Errorf("no supported authentication mechanism in %#x", mechanisms))
+	}
+}
+
+// handleSRPBytesSB answers the server's SRP challenge with our proof of the password
+func handleSRPBytesSB(c *Client, r *network.Reader) error {
+	challenge, err := protocol.ReadSRPBytesSB(r)
+	if err != nil {
+		return err
+	}
+	if c.srp == nil || c.State() != StateAuthenticating {
+		return ErrUnexpectedChallenge
+	}
+
+	M, err := c.srp.ProcessChallenge(challenge.Salt, challenge.BytesB)
+	if err != nil {
+		return c.fail(err)
+	}
+	return c.send(protocol.SRPBytesM{M: M}.Write())
+}
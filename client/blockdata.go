@@ -0,0 +1,103 @@
+package client
+
+import (
+	"math"
+	"sort"
+
+	"bettermt/main/meshbuilder"
+	"bettermt/main/network"
+	"bettermt/main/protocol"
+)
+
+// Default number of blocks kept before the farthest ones are dropped, like client_mapblock_limit
+const DefaultBlockLimit = 7500
+
+// handleBlockData decodes a block sent by the server and hands it to the world
+func handleBlockData(c *Client, r *network.Reader) error {
+	data, err := protocol.ReadBlockData(r, c.SerializationVersion)
+	if err != nil {
+		return err
+	}
+	pos := data.Position
+	if c.World != nil {
+		c.World.QueueChunk(data.Block)
+	}
+
+	c.blocksMu.Lock()
+	c.loadedBlocks[pos] = true
+	c.gotBlocks = append(c.gotBlocks, pos)
+	c.blocksMu.Unlock()
+	return nil
+}
+
+// flushGotBlocks acknowledges every block received since the last flush
+func (c *Client) flushGotBlocks() error {
+	c.blocksMu.Lock()
+	positions := c.gotBlocks
+	c.gotBlocks = nil
+	c.blocksMu.Unlock()
+
+	return c.sendBlockList(network.ToServerGotBlocks, positions)
+}
+
+// evictBlocks drops the blocks farthest from the player once more than BlockLimit are loaded
+func (c *Client) evictBlocks() error {
+	if c.BlockLimit <= 0 {
+		return nil
+	}
+
+	c.blocksMu.Lock()
+	excess := len(c.loadedBlocks) - c.BlockLimit
+	if excess <= 0 {
+		c.blocksMu.Unlock()
+		return nil
+	}
+	positions := make([][3]int16, 0, len(c.loadedBlocks))
+	for pos := range c.loadedBlocks {
+		positions = append(positions, pos)
+	}
+	center := c.playerBlockPosition()
+	sort.Slice(positions, func(i, j int) bool {
+		return blockDistanceSq(positions[i], center) > blockDistanceSq(positions[j], center)
+	})
+	positions = positions[:excess]
+	for _, pos := range positions {
+		delete(c.loadedBlocks, pos)
+	}
+	c.blocksMu.Unlock()
+
+	if c.World != nil {
+		for _, pos := range positions {
+			c.World.QueueRemoval(int32(pos[0])*meshbuilder.ChunkSize, int32(pos[1])*meshbuilder.ChunkSize, int32(pos[2])*meshbuilder.ChunkSize)
+		}
+	}
+	return c.sendBlockList(network.ToServerDeletedBlocks, positions)
+}
+
+// sendBlockList sends block positions in as many packets as needed
+func (c *Client) sendBlockList(command uint16, positions [][3]int16) error {
+	for len(positions) > 0 {
+		count := min(len(positions), protocol.MaxBlocksPerPacket)
+		if err := c.send(protocol.BlockList{Positions: positions[:count]}.Write(command)); err != nil {
+			return err
+		}
+		positions = positions[count:]
+	}
+	return nil
+}
+
+// playerBlockPosition returns the block the player is in
+func (c *Client) playerBlockPosition() [3]int16 {
+	var pos [3]int16
+	for i, v := range c.Player.Position() {
+		node := int32(math.Floor(float64(v)))
+		pos[i] = int16(node >> 4)
+	}
+	return pos
+}
+
+// blockDistanceSq returns the squared distance between two block positions
+func blockDistanceSq(a, b [3]int16) int {
+	dx, dy, dz := int(a[0])-int(b[0]), int(a[1])-int(b[1]), int(a[2])-int(b[2])
+	return dx*dx + dy*dy + dz*dz
+}
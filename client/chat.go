@@ -0,0 +1,31 @@
+package client
+
+import (
+	"errors"
+	"strings"
+
+	"bettermt/main/network"
+	"bettermt/main/protocol"
+)
+
+var ErrEmptyMessage = errors.New("empty chat message")
+
+// handleChatMessage adds a message from the server to the chat history
+func handleChatMessage(c *Client, r *network.Reader) error {
+	msg, err := protocol.ReadChatMessage(r)
+	if err != nil {
+		return err
+	}
+
+	c.Chat.Add(msg)
+	return nil
+}
+
+// SendChatMessage sends a line typed by the player, commands included
+func (c *Client) SendChatMessage(text string) error {
+	text = strings.TrimRight(text, " \r\n")
+	if text == "" {
+		return ErrEmptyMessage
+	}
+	return c.send(protocol.PlayerChatMessage{Text: text}.Write())
+}
@@ -0,0 +1,402 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"bettermt/main/auth"
+	"bettermt/main/blocktypes"
+	"bettermt/main/chat"
+	"bettermt/main/formspec"
+	"bettermt/main/hud"
+	"bettermt/main/inventory"
+	"bettermt/main/media"
+	"bettermt/main/meshbuilder"
+	"bettermt/main/network"
+	"bettermt/main/object"
+	"bettermt/main/particle"
+	"bettermt/main/player"
+	"bettermt/main/protocol"
+	"bettermt/main/sky"
+	"bettermt/main/sound"
+)
+
+// Version information reported to the server in TOSERVER_CLIENT_READY
+const (
+	VersionMajor  = 0
+	VersionMinor  = 1
+	VersionPatch  = 0
+	VersionString = "BetterMT 0.1.0"
+)
+
+const (
+	// How often TOSERVER_INIT is repeated until the server answers
+	initResendInterval = time.Second
+	// How often periodic work such as block acknowledgements runs
+	tickInterval = 50 * time.Millisecond
+)
+
+var ErrJoinTimeout = errors.New("timed out while joining the server")
+
+// State is a step of the login sequence
+type State int
+
+const (
+	StateCreated State = iota
+	StateInitSent
+	StateAuthenticating
+	StateJoining
+	StateReady
+	StateDisconnected
+)
+
+func (s State) String() string {
+	switch s {
+	case StateCreated:
+		return "created"
+	case StateInitSent:
+		return "init sent"
+	case StateAuthenticating:
+		return "authenticating"
+	case StateJoining:
+		return "joining"
+	case StateReady:
+		return "ready"
+	case StateDisconnected:
+		return "disconnected"
+	default:
+		return fmt.Sprintf("State(%d)", int(s))
+	}
+}
+
+// AccessDeniedError is returned when the server refuses the connection
+type AccessDeniedError struct {
+	Code      uint8
+	Reason    string
+	Reconnect bool
+}
+
+func (e *AccessDeniedError) Error() string {
+	return "access denied: " + e.Reason
+}
+
+// handler processes the body of one command received from the server
+type handler func(c *Client, r *network.Reader) error
+
+// handlers maps server commands to the function that processes them
+var handlers = map[uint16]handler{
+	network.ToClientHello:                 handleHello,
+	network.ToClientAuthAccept:            handleAuthAccept,
+	network.ToClientAccessDenied:          handleAccessDenied,
+	network.ToClientSRPBytesSB:            handleSRPBytesSB,
+	network.ToClientAnnounceMedia:         handleAnnounceMedia,
+	network.ToClientBlockData:             handleBlockData,
+	network.ToClientNodeDef:               handleNodeDef,
+	network.ToClientMedia:                 handleMedia,
+	network.ToClientChatMessage:           handleChatMessage,
+	network.ToClientMovePlayer:            handleMovePlayer,
+	network.ToClientMovement:              handleMovement,
+	network.ToClientPrivileges:            handlePrivileges,
+	network.ToClientItemDef:               handleItemDef,
+	network.ToClientInventory:             handleInventory,
+	network.ToClientHudAdd:                handleHudAdd,
+	network.ToClientHudRm:                 handleHudRm,
+	network.ToClientHudChange:             handleHudChange,
+	network.ToClientHudSetFlags:           handleHudSetFlags,
+	network.ToClientHudSetParam:           handleHudSetParam,
+	network.ToClientTimeOfDay:             handleTimeOfDay,
+	network.ToClientSetSky:                handleSetSky,
+	network.ToClientSetSun:                handleSetSun,
+	network.ToClientSetMoon:               handleSetMoon,
+	network.ToClientSetStars:              handleSetStars,
+	network.ToClientCloudParams:           handleCloudParams,
+	network.ToClientOverrideDayNightRatio: handleOverrideDayNightRatio,
+	network.ToClientActiveObjectRemoveAdd: handleActiveObjectRemoveAdd,
+	network.ToClientActiveObjectMessages:  handleActiveObjectMessages,
+	network.ToClientSpawnParticle:         handleSpawnParticle,
+	network.ToClientAddParticleSpawner:    handleAddParticleSpawner,
+	network.ToClientDeleteParticleSpawner: handleDeleteParticleSpawner,
+	network.ToClientPlaySound:             handlePlaySound,
+	network.ToClientStopSound:             handleStopSound,
+	network.ToClientFadeSound:             handleFadeSound,
+	network.ToClientShowFormspec:          handleShowFormspec,
+	network.ToClientInventoryFormspec:     handleInventoryFormspec,
+	network.ToClientFormspecPrepend:       handleFormspecPrepend,
+}
+
+// HandledCommands returns the server commands the client processes, in ascending order
+func HandledCommands() []uint16 {
+	commands := make([]uint16, 0, len(handlers))
+	for command := range handlers {
+		commands = append(commands, command)
+	}
+	sort.Slice(commands, func(i, j int) bool { return commands[i] < commands[j] })
+	return commands
+}
+
+// Client is a connection to a Minetest server on behalf of one player
+type Client struct {
+	Name     string
+	password string
+
+	// World receives the blocks sent by the server, it may be nil
+	World *meshbuilder.World
+	// Number of blocks kept before the farthest ones are dropped, 0 for no limit
+	BlockLimit int
+
+	conn network.Transport
+
+	mu    sync.Mutex
+	state State
+
+	// Negotiated in TOCLIENT_HELLO
+	SerializationVersion uint8
+	ProtocolVersion      uint16
+
+	// Authentication in progress
+	authMechanism uint32
+	srp           *auth.Client
+
+	// Received in TOCLIENT_AUTH_ACCEPT
+	SpawnPosition [3]float32
+	MapSeed       uint64
+	SendInterval  float32
+
+	// Received in TOCLIENT_PRIVILEGES
+	privileges map[string]bool
+
+	// Received in TOCLIENT_NODEDEF and TOCLIENT_ITEMDEF
+	nodeDefs *blocktypes.NodeDefManager
+	itemDefs *inventory.ItemDefManager
+
+	// Inventory of the player, the number of hotbar slots and the slot it wields
+	inventory  *inventory.Inventory
+	wieldIndex int
+	hotbarSize int
+
+	// Media announced by the server, kept in memory unless replaced before joining
+	Media     *media.Manager
+	mediaOnce sync.Once
+	// Requested files not yet received, guarded by mu
+	mediaPending int
+
+	// Messages received from the server
+	Chat *chat.History
+	// Elements and settings of the HUD controlled by the server
+	HUD *hud.HUD
+	// Time of day and sky settings controlled by the server
+	Sky *sky.Sky
+	// Formspecs the server asked to show and the one of the player's inventory
+	Formspecs *formspec.State
+
+	// Player controlled on this client and what was last reported of it
+	Player            *player.LocalPlayer
+	lastPlayerPos     protocol.PlayerPos
+	lastPlayerPosTime time.Time
+	// Active objects in range of the player
+	Objects *object.Manager
+	// Active object of the local player, which receives its physics overrides
+	playerObjectID  uint16
+	hasPlayerObject bool
+	// Particles and particle spawners, including the particles of nodes being dug
+	Particles *particle.Manager
+	// Sounds of the server and of the player, it may be nil
+	Sounds *sound.Manager
+
+	// Blocks received from the server and not yet acknowledged
+	blocksMu     sync.Mutex
+	loadedBlocks map[[3]int16]bool
+	gotBlocks    [][3]int16
+
+	joined   chan error
+	joinOnce sync.Once
+}
+
+// New creates a client for the given player that streams blocks into world
+func New(name, password string, world *meshbuilder.World) *Client {
+	objects := object.NewManager()
+	return &Client{
+		Name:         name,
+		password:     password,
+		World:        world,
+		Media:        media.NewManager(nil, nil),
+		Chat:         chat.NewHistory(chat.DefaultHistoryLimit),
+		HUD:          hud.New(inventory.DefaultHotbarSize),
+		Sky:          sky.New(),
+		Formspecs:    formspec.NewState(),
+		Objects:      objects,
+		Particles:    particle.NewManager(objects),
+		Player:       player.NewLocalPlayer(),
+		inventory:    inventory.New(),
+		hotbarSize:   inventory.DefaultHotbarSize,
+		BlockLimit:   DefaultBlockLimit,
+		state:        StateCreated,
+		loadedBlocks: make(map[[3]int16]bool),
+		joined:       make(chan error, 1),
+	}
+}
+
+// State returns the current step of the login sequence
+func (c *Client) State() State {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
+
+func (c *Client) setState(s State) {
+	c.mu.Lock()
+	c.state = s
+	c.mu.Unlock()
+}
+
+// Connect dials a server and blocks until the client is in game or the login failed
+func (c *Client) Connect(address string, timeout time.Duration) error {
+	conn, err := network.Dial(address)
+	if err != nil {
+		return err
+	}
+	return c.Join(conn, timeout)
+}
+
+// Join runs the login sequence over an established connection
+func (c *Client) Join(conn network.Transport, timeout time.Duration) error {
+	c.conn = conn
+	go c.receiveLoop()
+	go c.tickLoop()
+
+	deadline := time.After(timeout)
+	resend := time.NewTicker(initResendInterval)
+	defer resend.Stop()
+
+	c.setState(StateInitSent)
+	if err := c.sendInit(); err != nil {
+		return c.fail(err)
+	}
+	for {
+		select {
+		case err := <-c.joined:
+			return err
+		case <-resend.C:
+			// TOSERVER_INIT is unreliable, so it is repeated until the server says hello
+			if c.State() == StateInitSent {
+				if err := c.sendInit(); err != nil {
+					return c.fail(err)
+				}
+			}
+		case <-deadline:
+			return c.fail(ErrJoinTimeout)
+		}
+	}
+}
+
+// Attach sets the transport packets are sent on without starting the receive loop,
+// so that received packets can be fed in by hand with HandlePacket. The client then
+// waits for TOCLIENT_HELLO as if it had sent TOSERVER_INIT.
+func (c *Client) Attach(conn network.Transport) {
+	c.conn = conn
+	c.setState(StateInitSent)
+}
+
+// Close disconnects from the server
+func (c *Client) Close() error {
+	c.setState(StateDisconnected)
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn.Close()
+}
+
+// Done is closed once the connection to the server has ended
+func (c *Client) Done() <-chan struct{} {
+	return c.conn.Done()
+}
+
+// Err returns why the connection to the server ended, or nil while it is open
+func (c *Client) Err() error {
+	return c.conn.Err()
+}
+
+// send transmits a packet on the channel its command is meant for
+func (c *Client) send(w *network.Writer) error {
+	return network.SendCommand(c.conn, network.ToServerCommands, w)
+}
+
+// finishJoin reports the outcome of the login sequence to Join
+func (c *Client) finishJoin(err error) {
+	c.joinOnce.Do(func() {
+		c.joined <- err
+	})
+}
+
+// fail aborts the session with an error
+func (c *Client) fail(err error) error {
+	c.finishJoin(err)
+	c.Close()
+	return err
+}
+
+// receiveLoop dispatches packets from the server until the connection ends
+func (c *Client) receiveLoop() {
+	for {
+		p, err := c.conn.Recv()
+		if err != nil {
+			c.setState(StateDisconnected)
+			c.finishJoin(err)
+			return
+		}
+		if err := c.HandlePacket(p.Data); err != nil {
+			fmt.Printf("Error handling packet: %v\n", err)
+		}
+	}
+}
+
+// tickLoop runs periodic work until the connection ends
+func (c *Client) tickLoop() {
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.conn.Done():
+			return
+		case <-ticker.C:
+			if err := c.step(); err != nil {
+				fmt.Printf("Error in client step: %v\n", err)
+			}
+		}
+	}
+}
+
+// step acknowledges received blocks, drops the ones beyond the block limit, reports the sounds that ended and
+// reports the player
+func (c *Client) step() error {
+	if err := c.flushGotBlocks(); err != nil {
+		return err
+	}
+	if err := c.evictBlocks(); err != nil {
+		return err
+	}
+	if err := c.sendRemovedSounds(); err != nil {
+		return err
+	}
+	return c.sendPlayerPos(time.Now())
+}
+
+// HandlePacket decodes the command ID of a packet and runs its handler
+func (c *Client) HandlePacket(data []byte) error {
+	r := network.NewReader(data)
+	command := r.U16()
+	if r.Err() != nil {
+		return r.Err()
+	}
+	h, exists := handlers[command]
+	if !exists {
+		return nil
+	}
+	if err := h(c, r); err != nil {
+		return fmt.Errorf("%s: %w", network.ToClientCommands[command].Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package client
+
+import (
+	"bettermt/main/blocktypes"
+	"bettermt/main/network"
+	"bettermt/main/protocol"
+)
+
+// handleNodeDef replaces the node definitions with the ones the server uses
+func handleNodeDef(c *Client, r *network.Reader) error {
+	nodeDefs, err := protocol.ReadNodeDef(r)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.nodeDefs = nodeDefs
+	c.mu.Unlock()
+	return nil
+}
+
+// NodeDefs returns the node definitions received from the server, or nil before they arrive
+func (c *Client) NodeDefs() *blocktypes.NodeDefManager {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.nodeDefs
+}
@@ -0,0 +1,64 @@
+package client
+
+import (
+	"bettermt/main/formspec"
+	"bettermt/main/inventory"
+	"bettermt/main/network"
+	"bettermt/main/protocol"
+)
+
+// handleShowFormspec opens a formspec, or closes it if it is empty
+func handleShowFormspec(c *Client, r *network.Reader) error {
+	s, err := protocol.ReadShowFormspec(r)
+	if err != nil {
+		return err
+	}
+	c.Formspecs.Show(formspec.Request{FormName: s.FormName, Formspec: s.Formspec})
+	return nil
+}
+
+// handleInventoryFormspec changes the formspec the inventory key opens
+func handleInventoryFormspec(c *Client, r *network.Reader) error {
+	spec, err := protocol.ReadInventoryFormspec(r)
+	if err != nil {
+		return err
+	}
+	c.Formspecs.SetInventory(spec)
+	return nil
+}
+
+// handleFormspecPrepend changes the elements put in front of every formspec
+func handleFormspecPrepend(c *Client, r *network.Reader) error {
+	prepend, err := protocol.ReadFormspecPrepend(r)
+	if err != nil {
+		return err
+	}
+	c.Formspecs.SetPrepend(prepend)
+	return nil
+}
+
+// SendFields submits the fields of a formspec, which has an empty name for the inventory formspec
+func (c *Client) SendFields(formName string, fields map[string]string) error {
+	return c.send(protocol.InventoryFields{FormName: formName, Fields: fields}.Write())
+}
+
+// MoveItem asks the server to move items between two slots. Moves within the inventory of the player are
+// applied to it right away, the server corrects them if it disagrees.
+func (c *Client) MoveItem(m inventory.Move) error {
+	if c.OwnsLocation(m.From.Location) && c.OwnsLocation(m.To.Location) {
+		c.mu.Lock()
+		m.Apply(c.inventory, c.itemDefs)
+		c.mu.Unlock()
+	}
+	return c.send(protocol.WriteInventoryAction(m.String()))
+}
+
+// DropItem asks the server to drop the items of a slot in front of the player
+func (c *Client) DropItem(d inventory.Drop) error {
+	return c.send(protocol.WriteInventoryAction(d.String()))
+}
+
+// OwnsLocation reports whether an inventory location is the inventory of the player
+func (c *Client) OwnsLocation(location string) bool {
+	return location == inventory.LocationCurrentPlayer || location == "player:"+c.Name
+}
@@ -0,0 +1,88 @@
+package client
+
+import (
+	"fmt"
+
+	"bettermt/main/network"
+	"bettermt/main/protocol"
+)
+
+// sendInit opens the login sequence with the versions we support
+func (c *Client) sendInit() error {
+	return c.send(protocol.Init{
+		MaxSerializationVersion: network.SerializationVersionMax,
+		MinProtocolVersion:      network.ProtocolVersionMin,
+		MaxProtocolVersion:      network.ProtocolVersionMax,
+		Name:                    c.Name,
+	}.Write())
+}
+
+// handleHello stores the negotiated versions and starts authentication
+func handleHello(c *Client, r *network.Reader) error {
+	hello, err := protocol.ReadHello(r)
+	if err != nil {
+		return err
+	}
+	serializationVersion, protocolVersion := hello.SerializationVersion, hello.ProtocolVersion
+
+	if c.State() != StateInitSent {
+		return nil
+	}
+	if serializationVersion < network.SerializationVersionMin || serializationVersion > network.SerializationVersionMax {
+		return c.fail(fmt.Errorf("unsupported serialization version %d", serializationVersion))
+	}
+	if protocolVersion < network.ProtocolVersionMin || protocolVersion > network.ProtocolVersionMax {
+		return c.fail(fmt.Errorf("unsupported protocol version %d", protocolVersion))
+	}
+
+	c.SerializationVersion = serializationVersion
+	c.ProtocolVersion = protocolVersion
+	c.setState(StateAuthenticating)
+	return c.startAuth(hello.AuthMechanisms)
+}
+
+// handleAuthAccept completes authentication and asks the server for the game data
+func handleAuthAccept(c *Client, r *network.Reader) error {
+	accept, err := protocol.ReadAuthAccept(r)
+	if err != nil {
+		return err
+	}
+
+	c.srp = nil
+	c.SpawnPosition = accept.Position
+	c.MapSeed = accept.MapSeed
+	c.SendInterval = accept.SendInterval
+	c.Player.SetPosition(fromProtocolUnits(accept.Position))
+	c.setState(StateJoining)
+
+	return c.send(protocol.Init2{}.Write())
+}
+
+// handleAccessDenied ends the session with the reason given by the server
+func handleAccessDenied(c *Client, r *network.Reader) error {
+	denied, err := protocol.ReadAccessDenied(r)
+	if err != nil {
+		return err
+	}
+
+	c.fail(&AccessDeniedError{Code: denied.Code, Reason: denied.Reason, Reconnect: denied.Reconnect})
+	return nil
+}
+
+// sendClientReady tells the server the client has loaded everything and is in game
+func (c *Client) sendClientReady() error {
+	ready := protocol.ClientReady{
+		VersionMajor:    VersionMajor,
+		VersionMinor:    VersionMinor,
+		VersionPatch:    VersionPatch,
+		VersionString:   VersionString,
+		FormspecVersion: network.FormspecVersion,
+	}
+	if err := c.send(ready.Write()); err != nil {
+		return c.fail(err)
+	}
+
+	c.setState(StateReady)
+	c.finishJoin(nil)
+	return nil
+}
@@ -0,0 +1,71 @@
+package client
+
+import (
+	"bettermt/main/hud"
+	"bettermt/main/network"
+	"bettermt/main/protocol"
+)
+
+// handleHudAdd shows a new HUD element
+func handleHudAdd(c *Client, r *network.Reader) error {
+	e, err := protocol.ReadHudAdd(r)
+	if err != nil {
+		return err
+	}
+	c.HUD.Add(e)
+	return nil
+}
+
+// handleHudRm removes a HUD element
+func handleHudRm(c *Client, r *network.Reader) error {
+	id, err := protocol.ReadHudRm(r)
+	if err != nil {
+		return err
+	}
+	c.HUD.Remove(id)
+	return nil
+}
+
+// handleHudChange changes one field of a HUD element
+func handleHudChange(c *Client, r *network.Reader) error {
+	change, err := protocol.ReadHudChange(r)
+	if err != nil {
+		return err
+	}
+	return c.HUD.Change(change)
+}
+
+// handleHudSetFlags shows and hides the built in parts of the HUD
+func handleHudSetFlags(c *Client, r *network.Reader) error {
+	f, err := protocol.ReadHudSetFlags(r)
+	if err != nil {
+		return err
+	}
+	c.HUD.SetFlags(f.Flags, f.Mask)
+	return nil
+}
+
+// handleHudSetParam changes the hotbar. A new slot count also limits the slots the player can wield.
+func handleHudSetParam(c *Client, r *network.Reader) error {
+	p, err := protocol.ReadHudSetParam(r)
+	if err != nil {
+		return err
+	}
+	switch p.Param {
+	case hud.ParamHotbarItemCount:
+		count, err := p.HotbarItemCount()
+		if err != nil {
+			return err
+		}
+		c.HUD.SetHotbarItemCount(count)
+		c.mu.Lock()
+		c.hotbarSize = c.HUD.Hotbar().ItemCount
+		c.wieldIndex = min(c.wieldIndex, c.hotbarSize-1)
+		c.mu.Unlock()
+	case hud.ParamHotbarImage:
+		c.HUD.SetHotbarImage(p.Value)
+	case hud.ParamHotbarSelectedImage:
+		c.HUD.SetHotbarSelectedImage(p.Value)
+	}
+	return nil
+}
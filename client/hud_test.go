@@ -0,0 +1,58 @@
+package client
+
+import (
+	"testing"
+
+	"bettermt/main/hud"
+	"bettermt/main/protocol"
+)
+
+func TestHudPackets(t *testing.T) {
+	c := New("tester", "", nil)
+	c.Attach(newFakeTransport())
+	for _, w := range []interface{ Bytes() []byte }{
+		protocol.WriteHudAdd(hud.Element{ID: 1, Type: hud.TypeText, Text: "a"}),
+		protocol.WriteHudAdd(hud.Element{ID: 2, Type: hud.TypeImage, ZIndex: -1}),
+		protocol.WriteHudChange(hud.Change{ID: 1, Stat: hud.StatText, String: "b"}),
+		protocol.WriteHudRm(2),
+		protocol.HudSetFlags{Flags: 0, Mask: hud.FlagCrosshair}.Write(),
+		protocol.HudSetParam{Param: hud.ParamHotbarImage, Value: "bar.png"}.Write(),
+	} {
+		if err := c.HandlePacket(w.Bytes()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elements := c.HUD.Elements(); len(elements) != 1 || elements[0].Text != "b" {
+		t.Errorf("elements %+v", elements)
+	}
+	if c.HUD.Flags()&hud.FlagCrosshair != 0 {
+		t.Error("crosshair not hidden")
+	}
+	if c.HUD.Hotbar().Image != "bar.png" {
+		t.Errorf("hotbar %+v", c.HUD.Hotbar())
+	}
+	if err := c.HandlePacket(protocol.WriteHudChange(hud.Change{ID: 9, Stat: hud.StatText}).Bytes()); err == nil {
+		t.Error("no error changing an unknown element")
+	}
+}
+
+func TestHotbarItemCountLimitsWieldIndex(t *testing.T) {
+	c := New("tester", "", nil)
+	c.Attach(newFakeTransport())
+	if err := c.SetWieldIndex(6); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct{ count, size, wield int }{
+		{4, 4, 3},    // The wielded slot went away
+		{16, 16, 3},  // Growing keeps the slot
+		{0, 1, 0},    // At least one slot
+		{100, 32, 0}, // At most 32
+	} {
+		if err := c.HandlePacket(protocol.HotbarItemCountParam(test.count).Write().Bytes()); err != nil {
+			t.Fatal(err)
+		}
+		if c.HotbarSize() != test.size || c.WieldIndex() != test.wield {
+			t.Errorf("count %d: %d slots wielding %d, want %d wielding %d", test.count, c.HotbarSize(), c.WieldIndex(), test.size, test.wield)
+		}
+	}
+}
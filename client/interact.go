@@ -0,0 +1,15 @@
+package client
+
+import (
+	"bettermt/main/protocol"
+)
+
+// Interact tells the server the player digs, places or uses the pointed thing with the wielded item
+func (c *Client) Interact(action protocol.InteractAction, pointed protocol.PointedThing) error {
+	return c.send(protocol.Interact{
+		Action:    action,
+		ItemIndex: uint16(c.WieldIndex()),
+		Pointed:   pointed,
+		Player:    playerPos(c.Player.State()),
+	}.Write())
+}
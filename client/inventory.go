@@ -0,0 +1,94 @@
+package client
+
+import (
+	"bettermt/main/inventory"
+	"bettermt/main/network"
+	"bettermt/main/protocol"
+)
+
+// handleItemDef replaces the item definitions with the ones the server uses
+func handleItemDef(c *Client, r *network.Reader) error {
+	itemDefs, err := protocol.ReadItemDef(r)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.itemDefs = itemDefs
+	c.mu.Unlock()
+	return nil
+}
+
+// handleInventory applies a full or incremental update of the inventory of the player
+func handleInventory(c *Client, r *network.Reader) error {
+	data, err := protocol.ReadInventory(r)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.inventory.Deserialize(data)
+}
+
+// ItemDefs returns the item definitions received from the server, or nil before they arrive
+func (c *Client) ItemDefs() *inventory.ItemDefManager {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.itemDefs
+}
+
+// Inventory returns a copy of the inventory of the player
+func (c *Client) Inventory() *inventory.Inventory {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.inventory.Clone()
+}
+
+// Hotbar returns the items in the hotbar slots
+func (c *Client) Hotbar() []inventory.ItemStack {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	hotbar := c.inventory.Hotbar(c.hotbarSize)
+	items := make([]inventory.ItemStack, len(hotbar))
+	for i, item := range hotbar {
+		items[i] = item.Clone()
+	}
+	return items
+}
+
+// HotbarSize returns the number of hotbar slots
+func (c *Client) HotbarSize() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hotbarSize
+}
+
+// WieldIndex returns the hotbar slot the player holds in its hand
+func (c *Client) WieldIndex() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.wieldIndex
+}
+
+// WieldedItem returns the item the player holds, which is empty for the hand
+func (c *Client) WieldedItem() inventory.ItemStack {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if hotbar := c.inventory.Hotbar(c.hotbarSize); c.wieldIndex < len(hotbar) {
+		return hotbar[c.wieldIndex].Clone()
+	}
+	return inventory.ItemStack{}
+}
+
+// SetWieldIndex selects the hotbar slot the player holds and tells the server
+func (c *Client) SetWieldIndex(index int) error {
+	c.mu.Lock()
+	if index < 0 || index >= c.hotbarSize || index == c.wieldIndex {
+		c.mu.Unlock()
+		return nil
+	}
+	c.wieldIndex = index
+	c.mu.Unlock()
+	return c.send(protocol.PlayerItem{Index: uint16(index)}.Write())
+}
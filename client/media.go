@@ -0,0 +1,105 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"bettermt/main/media"
+	"bettermt/main/network"
+	"bettermt/main/protocol"
+)
+
+// How long fetching from one remote media server may take before falling back to the server itself
+const remoteMediaTimeout = 60 * time.Second
+
+// handleAnnounceMedia records the media the server offers and fetches whatever is not cached
+func handleAnnounceMedia(c *Client, r *network.Reader) error {
+	announced, err := protocol.ReadAnnounceMedia(r)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range announced.Files {
+		c.Media.Announce(file.Name, file.SHA1)
+	}
+	remotes := announced.RemoteServers
+	if len(remotes) == 0 || c.Media.Complete() {
+		return c.requestMedia()
+	}
+
+	// HTTP downloads would hold up the receive loop, so they run on their own
+	go func() {
+		c.fetchRemoteMedia(remotes)
+		if err := c.requestMedia(); err != nil {
+			fmt.Printf("Error requesting media: %v\n", err)
+		}
+	}()
+	return nil
+}
+
+// fetchRemoteMedia downloads missing files from the remote media servers, stopping once nothing is missing
+func (c *Client) fetchRemoteMedia(remotes []string) {
+	for _, url := range remotes {
+		ctx, cancel := context.WithTimeout(context.Background(), remoteMediaTimeout)
+		fetched, err := media.FetchRemote(ctx, http.DefaultClient, url, c.Media)
+		cancel()
+		if err != nil {
+			fmt.Printf("Remote media %s failed: %v\n", url, err)
+		}
+		if fetched > 0 {
+			fmt.Printf("Fetched %d media files from %s\n", fetched, url)
+		}
+		if c.Media.Complete() {
+			return
+		}
+	}
+}
+
+// requestMedia asks the server for every file still missing, or finishes joining if there is none
+func (c *Client) requestMedia() error {
+	missing := c.Media.Missing()
+	if len(missing) == 0 {
+		return c.mediaReceived()
+	}
+
+	fmt.Printf("Requesting %d media files\n", len(missing))
+	c.mu.Lock()
+	c.mediaPending = len(missing)
+	c.mu.Unlock()
+	return c.send(protocol.RequestMedia{Files: missing}.Write())
+}
+
+// handleMedia stores one bunch of requested files. Like in Minetest, files that fail to load are left missing
+// and the game goes on without them.
+func handleMedia(c *Client, r *network.Reader) error {
+	bunch, err := protocol.ReadMedia(r)
+	for _, file := range bunch.Files {
+		if err := c.Media.Add(file.Name, file.Data); err != nil {
+			fmt.Printf("Error loading media: %v\n", err)
+		}
+	}
+
+	c.mu.Lock()
+	c.mediaPending -= len(bunch.Files)
+	received := c.mediaPending <= 0
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if received || c.Media.Complete() {
+		return c.mediaReceived()
+	}
+	return nil
+}
+
+// mediaReceived tells the server the client is ready once all media is loaded
+func (c *Client) mediaReceived() error {
+	var err error
+	c.mediaOnce.Do(func() {
+		err = c.sendClientReady()
+	})
+	return err
+}
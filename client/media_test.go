@@ -0,0 +1,108 @@
+package client
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"io/fs"
+	"sync"
+	"testing"
+
+	"bettermt/main/network"
+	"bettermt/main/protocol"
+)
+
+// fakeTransport records the commands a client sends and never receives anything
+type fakeTransport struct {
+	mu     sync.Mutex
+	sent   []uint16
+	closed bool
+	done   chan struct{}
+}
+
+func newFakeTransport() *fakeTransport {
+	return &fakeTransport{done: make(chan struct{})}
+}
+
+func (t *fakeTransport) Send(channel uint8, data []byte, reliable bool) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sent = append(t.sent, binary.BigEndian.Uint16(data))
+	return nil
+}
+
+func (t *fakeTransport) Recv() (network.Packet, error) {
+	<-t.done
+	return network.Packet{}, network.ErrClosed
+}
+
+func (t *fakeTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.closed {
+		t.closed = true
+		close(t.done)
+	}
+	return nil
+}
+
+func (t *fakeTransport) Done() <-chan struct{} { return t.done }
+func (t *fakeTransport) Err() error            { return nil }
+
+// commands returns the commands sent so far
+func (t *fakeTransport) commands() []uint16 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]uint16(nil), t.sent...)
+}
+
+func shaOf(data []byte) []byte {
+	sum := sha1.Sum(data)
+	return sum[:]
+}
+
+func TestCorruptMediaIsSkipped(t *testing.T) {
+	c := New("tester", "", nil)
+	conn := newFakeTransport()
+	c.Attach(conn)
+
+	good, bad := []byte("good texture"), []byte("bad texture")
+	announce := protocol.AnnounceMedia{Files: []protocol.MediaAnnouncement{
+		{Name: "good.png", SHA1: shaOf(good)},
+		{Name: "bad.png", SHA1: shaOf(bad)},
+	}}
+	if err := c.HandlePacket(announce.Write().Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if sent := conn.commands(); len(sent) != 1 || sent[0] != network.ToServerRequestMedia {
+		t.Fatalf("sent %#x, want a media request", sent)
+	}
+
+	// The files come in two bunches, the second of them with content that does not match its hash
+	first := protocol.Media{Bunches: 2, Bunch: 0, Files: []protocol.MediaFile{{Name: "good.png", Data: good}}}
+	second := protocol.Media{Bunches: 2, Bunch: 1, Files: []protocol.MediaFile{{Name: "bad.png", Data: []byte("corrupt")}}}
+	if err := c.HandlePacket(first.Write().Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if len(conn.commands()) != 1 {
+		t.Fatal("client went on before all media arrived")
+	}
+	if err := c.HandlePacket(second.Write().Bytes()); err != nil {
+		t.Fatal(err)
+	}
+
+	if conn.closed {
+		t.Fatal("corrupt media file ended the session")
+	}
+	if sent := conn.commands(); len(sent) != 2 || sent[1] != network.ToServerClientReady {
+		t.Fatalf("sent %#x, want the client to get ready", sent)
+	}
+	if c.State() != StateReady {
+		t.Fatalf("state %v, want %v", c.State(), StateReady)
+	}
+	if missing := c.Media.Missing(); len(missing) != 1 || missing[0] != "bad.png" {
+		t.Fatalf("missing %v, want bad.png", missing)
+	}
+	if _, err := fs.ReadFile(c.Media, "good.png"); err != nil {
+		t.Fatal(err)
+	}
+}
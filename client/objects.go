@@ -0,0 +1,74 @@
+package client
+
+import (
+	"fmt"
+
+	"bettermt/main/network"
+	"bettermt/main/object"
+	"bettermt/main/protocol"
+)
+
+// handleActiveObjectRemoveAdd updates the objects in range. The object of the local player is remembered,
+// since the server sends its physics overrides to that object.
+func handleActiveObjectRemoveAdd(c *Client, r *network.Reader) error {
+	objects, err := protocol.ReadActiveObjectRemoveAdd(r)
+	if err != nil {
+		return err
+	}
+	for _, id := range objects.Removed {
+		c.Objects.Remove(id)
+		if c.hasPlayerObject && id == c.playerObjectID {
+			c.hasPlayerObject = false
+		}
+	}
+	for _, o := range objects.Added {
+		if o.Type != protocol.ObjectTypeGeneric {
+			continue
+		}
+		init, err := protocol.ReadGenericInit(o.InitData)
+		if err != nil {
+			return fmt.Errorf("active object %d: %w", o.ID, err)
+		}
+		obj := object.New(o.ID, init.Name, init.IsPlayer, fromProtocolUnits(init.Position), init.Rotation, init.HP)
+		obj.IsLocal = init.IsPlayer && init.Name == c.Name
+		c.Objects.Add(obj)
+		if obj.IsLocal {
+			c.playerObjectID, c.hasPlayerObject = o.ID, true
+		}
+		for _, msg := range init.Messages {
+			if err := c.handleObjectMessage(o.ID, msg); err != nil {
+				return fmt.Errorf("active object %d: %w", o.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// handleActiveObjectMessages applies the messages to the objects in range
+func handleActiveObjectMessages(c *Client, r *network.Reader) error {
+	messages, err := protocol.ReadActiveObjectMessages(r)
+	if err != nil {
+		return err
+	}
+	for _, msg := range messages {
+		if err := c.handleObjectMessage(msg.ID, msg.Data); err != nil {
+			return fmt.Errorf("active object %d: %w", msg.ID, err)
+		}
+	}
+	return nil
+}
+
+// handleObjectMessage applies one message to an object. Messages to objects out of range are dropped.
+func (c *Client) handleObjectMessage(id uint16, data []byte) error {
+	if c.hasPlayerObject && id == c.playerObjectID {
+		if err := c.handlePlayerObjectMessage(data); err != nil {
+			return err
+		}
+	}
+	msg, err := protocol.ReadObjectMessage(data)
+	if err != nil || msg == nil {
+		return err
+	}
+	c.Objects.Apply(id, msg)
+	return nil
+}
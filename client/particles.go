@@ -0,0 +1,36 @@
+package client
+
+import (
+	"bettermt/main/network"
+	"bettermt/main/protocol"
+)
+
+// handleSpawnParticle creates a single particle
+func handleSpawnParticle(c *Client, r *network.Reader) error {
+	p, err := protocol.ReadSpawnParticle(r)
+	if err != nil {
+		return err
+	}
+	c.Particles.Add(p)
+	return nil
+}
+
+// handleAddParticleSpawner creates a particle spawner, whose format depends on the protocol version
+func handleAddParticleSpawner(c *Client, r *network.Reader) error {
+	s, err := protocol.ReadAddParticleSpawner(r, c.ProtocolVersion)
+	if err != nil {
+		return err
+	}
+	c.Particles.AddSpawner(s.ID, s.Params)
+	return nil
+}
+
+// handleDeleteParticleSpawner stops a particle spawner
+func handleDeleteParticleSpawner(c *Client, r *network.Reader) error {
+	id, err := protocol.ReadDeleteParticleSpawner(r)
+	if err != nil {
+		return err
+	}
+	c.Particles.RemoveSpawner(id)
+	return nil
+}
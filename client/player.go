@@ -0,0 +1,117 @@
+package client
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"bettermt/main/network"
+	"bettermt/main/player"
+	"bettermt/main/protocol"
+)
+
+// Interval the player position is sent at until the server recommends one
+const defaultSendInterval = 0.1
+
+// handleMovePlayer puts the local player where the server wants it
+func handleMovePlayer(c *Client, r *network.Reader) error {
+	move, err := protocol.ReadMovePlayer(r)
+	if err != nil {
+		return err
+	}
+	c.Player.MoveTo(fromProtocolUnits(move.Position), move.Pitch, move.Yaw)
+	return nil
+}
+
+// sendPlayerPos reports the local player at the send interval of the server, if it changed
+func (c *Client) sendPlayerPos(now time.Time) error {
+	if c.State() != StateReady {
+		return nil
+	}
+	interval := c.SendInterval
+	if interval <= 0 {
+		interval = defaultSendInterval
+	}
+	if now.Sub(c.lastPlayerPosTime).Seconds() < float64(interval) {
+		return nil
+	}
+
+	pos := playerPos(c.Player.State())
+	if pos == c.lastPlayerPos {
+		return nil
+	}
+	c.lastPlayerPos = pos
+	c.lastPlayerPosTime = now
+	return c.send(pos.Write())
+}
+
+// playerPos converts the state of the local player to what TOSERVER_PLAYERPOS carries
+func playerPos(s player.State) protocol.PlayerPos {
+	return protocol.PlayerPos{
+		Position:    toProtocolUnits(s.Position),
+		Speed:       toProtocolUnits(s.Velocity),
+		Pitch:       s.Pitch,
+		Yaw:         s.Yaw,
+		Keys:        s.Controls.Bits(),
+		FOV:         s.FOV * math.Pi / 180,
+		WantedRange: uint8(min(math.Ceil(float64(s.ViewRange)/16), 255)),
+	}
+}
+
+// toProtocolUnits scales a vector in nodes to the units used on the wire
+func toProtocolUnits(v [3]float32) [3]float32 {
+	return [3]float32{v[0] * network.BS, v[1] * network.BS, v[2] * network.BS}
+}
+
+// fromProtocolUnits scales a vector used on the wire to nodes
+func fromProtocolUnits(v [3]float32) [3]float32 {
+	return [3]float32{v[0] / network.BS, v[1] / network.BS, v[2] / network.BS}
+}
+
+// handleMovement applies the movement settings of the server to the local player
+func handleMovement(c *Client, r *network.Reader) error {
+	m, err := protocol.ReadMovement(r)
+	if err != nil {
+		return err
+	}
+	c.Player.SetMovementSettings(m)
+	return nil
+}
+
+// handlePrivileges records the privileges of the player and lets it move fast if it has the fast privilege
+func handlePrivileges(c *Client, r *network.Reader) error {
+	names, err := protocol.ReadPrivileges(r)
+	if err != nil {
+		return err
+	}
+	privileges := make(map[string]bool, len(names))
+	for _, name := range names {
+		privileges[name] = true
+	}
+	c.mu.Lock()
+	c.privileges = privileges
+	c.mu.Unlock()
+	c.Player.SetFastMove(privileges["fast"])
+	return nil
+}
+
+// HasPrivilege reports whether the server granted the player a privilege
+func (c *Client) HasPrivilege(name string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.privileges[name]
+}
+
+// handlePlayerObjectMessage applies a message to the object of the local player that affects its movement
+func (c *Client) handlePlayerObjectMessage(data []byte) error {
+	r := network.NewReader(data)
+	if r.U8() != protocol.AOCmdSetPhysicsOverride {
+		return nil
+	}
+	o, err := protocol.ReadPhysicsOverride(r)
+	if err != nil {
+		return fmt.Errorf("physics override: %w", err)
+	}
+	c.Player.SetPhysicsOverride(o)
+	return nil
+}
@@ -0,0 +1,143 @@
+package client
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"bettermt/main/network"
+	"bettermt/main/player"
+	"bettermt/main/protocol"
+)
+
+func TestPlayerPos(t *testing.T) {
+	s := player.State{
+		Position:  [3]float32{1.5, -2, 30},
+		Velocity:  [3]float32{0, -9.5, 4},
+		Pitch:     -30,
+		Yaw:       180,
+		Controls:  player.Controls{Forward: true, Jump: true},
+		FOV:       72,
+		ViewRange: 100,
+	}
+	p := playerPos(s)
+	// Positions and speeds go to protocol units of a tenth of a node
+	if p.Position != [3]float32{15, -20, 300} || p.Speed != [3]float32{0, -95, 40} {
+		t.Errorf("position %v, speed %v", p.Position, p.Speed)
+	}
+	if p.Pitch != -30 || p.Yaw != 180 || p.Keys != s.Controls.Bits() {
+		t.Errorf("pitch %v, yaw %v, keys %#x", p.Pitch, p.Yaw, p.Keys)
+	}
+	if want := float32(72 * math.Pi / 180); math.Abs(float64(p.FOV-want)) > 1e-6 {
+		t.Errorf("fov %v radians, want %v", p.FOV, want)
+	}
+}
+
+func TestWantedRange(t *testing.T) {
+	// The view range in nodes is rounded up to whole blocks of 16 nodes
+	for _, test := range []struct {
+		viewRange float32
+		want      uint8
+	}{
+		{0, 0},
+		{1, 1},
+		{16, 1},
+		{16.5, 2},
+		{100, 7},
+		{240, 15},
+		{4080, 255},
+		{10000, 255}, // Clamped to a byte
+	} {
+		if got := playerPos(player.State{ViewRange: test.viewRange}).WantedRange; got != test.want {
+			t.Errorf("view range %v: wanted range %d, want %d", test.viewRange, got, test.want)
+		}
+	}
+}
+
+func TestMovePlayer(t *testing.T) {
+	c := New("tester", "", nil)
+	c.Attach(newFakeTransport())
+	c.Player.SetPosition([3]float32{5, 5, 5})
+
+	for _, test := range []struct {
+		move       protocol.MovePlayer
+		pos        [3]float32
+		pitch, yaw float32
+	}{
+		{protocol.MovePlayer{Position: [3]float32{100, 205, -30}, Pitch: 10, Yaw: 90}, [3]float32{10, 20.5, -3}, 10, 90},
+		// Angles are kept in range like the player's own turns
+		{protocol.MovePlayer{Position: [3]float32{0, 0, 0}, Pitch: 120, Yaw: -90}, [3]float32{0, 0, 0}, 89.5, 270},
+		{protocol.MovePlayer{Position: [3]float32{-5, 10, 5}, Pitch: -100, Yaw: 400}, [3]float32{-0.5, 1, 0.5}, -89.5, 40},
+	} {
+		if err := c.HandlePacket(test.move.Write().Bytes()); err != nil {
+			t.Fatal(err)
+		}
+		s := c.Player.State()
+		if s.Position != test.pos || s.Pitch != test.pitch || s.Yaw != test.yaw {
+			t.Errorf("moved to %v, pitch %v, yaw %v; want %v, %v, %v", s.Position, s.Pitch, s.Yaw, test.pos, test.pitch, test.yaw)
+		}
+		if s.Velocity != [3]float32{} {
+			t.Errorf("velocity %v kept after the move", s.Velocity)
+		}
+	}
+}
+
+func TestSendPlayerPos(t *testing.T) {
+	c := New("tester", "", nil)
+	conn := newFakeTransport()
+	c.Attach(conn)
+	c.SendInterval = 0.1
+	now := time.Now()
+
+	if err := c.sendPlayerPos(now); err != nil || len(conn.commands()) != 0 {
+		t.Fatalf("sent %#x, %v before the client was ready", conn.commands(), err)
+	}
+	c.setState(StateReady)
+	count := func() int {
+		n := 0
+		for _, command := range conn.commands() {
+			if command == network.ToServerPlayerPos {
+				n++
+			}
+		}
+		return n
+	}
+
+	c.sendPlayerPos(now)
+	if count() != 1 {
+		t.Fatal("position not sent")
+	}
+	c.Player.SetPosition([3]float32{1, 2, 3})
+	c.sendPlayerPos(now.Add(50 * time.Millisecond))
+	if count() != 1 {
+		t.Fatal("position sent before the send interval passed")
+	}
+	c.sendPlayerPos(now.Add(120 * time.Millisecond))
+	if count() != 2 {
+		t.Fatal("moved position not sent after the send interval")
+	}
+	c.sendPlayerPos(now.Add(300 * time.Millisecond))
+	if count() != 2 {
+		t.Fatal("unchanged position sent again")
+	}
+}
+
+func TestPrivileges(t *testing.T) {
+	c := New("tester", "", nil)
+	c.Attach(newFakeTransport())
+	if c.HasPrivilege("interact") {
+		t.Fatal("privilege before TOCLIENT_PRIVILEGES")
+	}
+	for _, privileges := range [][]string{{"interact", "fast"}, {"shout"}} {
+		if err := c.HandlePacket(protocol.WritePrivileges(privileges).Bytes()); err != nil {
+			t.Fatal(err)
+		}
+		// Each packet replaces the privileges the player had
+		for _, name := range []string{"interact", "fast", "shout"} {
+			if want := slices.Contains(privileges, name); c.HasPrivilege(name) != want {
+				t.Errorf("privileges %q: has %s %v, want %v", privileges, name, !want, want)
+			}
+		}
+	}
+}
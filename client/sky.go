@@ -0,0 +1,76 @@
+package client
+
+import (
+	"bettermt/main/network"
+	"bettermt/main/protocol"
+)
+
+// handleTimeOfDay sets the game time, which then advances locally until the next update
+func handleTimeOfDay(c *Client, r *network.Reader) error {
+	t, err := protocol.ReadTimeOfDay(r)
+	if err != nil {
+		return err
+	}
+	c.Sky.SetTime(t.Time, t.Speed)
+	return nil
+}
+
+// handleSetSky changes the sky
+func handleSetSky(c *Client, r *network.Reader) error {
+	p, err := protocol.ReadSetSky(r)
+	if err != nil {
+		return err
+	}
+	c.Sky.SetParams(p)
+	return nil
+}
+
+// handleSetSun changes the sun
+func handleSetSun(c *Client, r *network.Reader) error {
+	p, err := protocol.ReadSetSun(r)
+	if err != nil {
+		return err
+	}
+	c.Sky.SetSun(p)
+	return nil
+}
+
+// handleSetMoon changes the moon
+func handleSetMoon(c *Client, r *network.Reader) error {
+	p, err := protocol.ReadSetMoon(r)
+	if err != nil {
+		return err
+	}
+	c.Sky.SetMoon(p)
+	return nil
+}
+
+// handleSetStars changes the stars
+func handleSetStars(c *Client, r *network.Reader) error {
+	p, err := protocol.ReadSetStars(r)
+	if err != nil {
+		return err
+	}
+	c.Sky.SetStars(p)
+	return nil
+}
+
+// handleCloudParams changes the clouds
+func handleCloudParams(c *Client, r *network.Reader) error {
+	p, err := protocol.ReadCloudParams(r)
+	if err != nil {
+		return err
+	}
+	c.Sky.SetClouds(p)
+	return nil
+}
+
+// handleOverrideDayNightRatio forces the brightness of the day or returns it to the time of day
+func handleOverrideDayNightRatio(c *Client, r *network.Reader) error {
+	o, err := protocol.ReadOverrideDayNightRatio(r)
+	if err != nil {
+		return err
+	}
+	c.Sky.OverrideDayNightRatio(o.Override, o.Ratio)
+	return nil
+}
@@ -0,0 +1,49 @@
+package client
+
+import (
+	"bettermt/main/network"
+	"bettermt/main/protocol"
+	"bettermt/main/sound"
+)
+
+// handlePlaySound starts a sound of the server
+func handlePlaySound(c *Client, r *network.Reader) error {
+	s, err := protocol.ReadPlaySound(r)
+	if err != nil || c.Sounds == nil {
+		return err
+	}
+	loc := sound.Location{Type: s.Type, Position: fromProtocolUnits(s.Position), ObjectID: s.ObjectID}
+	return c.Sounds.PlayServer(s.ID, s.Spec, loc, s.Ephemeral)
+}
+
+// handleStopSound stops a sound of the server
+func handleStopSound(c *Client, r *network.Reader) error {
+	id, err := protocol.ReadStopSound(r)
+	if err != nil || c.Sounds == nil {
+		return err
+	}
+	c.Sounds.Stop(id)
+	return nil
+}
+
+// handleFadeSound fades a sound of the server to another gain
+func handleFadeSound(c *Client, r *network.Reader) error {
+	f, err := protocol.ReadFadeSound(r)
+	if err != nil || c.Sounds == nil {
+		return err
+	}
+	c.Sounds.Fade(f.ID, f.Step, f.Gain)
+	return nil
+}
+
+// sendRemovedSounds tells the server which of its sounds ended, so that it forgets them
+func (c *Client) sendRemovedSounds() error {
+	if c.Sounds == nil {
+		return nil
+	}
+	ids := c.Sounds.RemovedSounds()
+	if len(ids) == 0 {
+		return nil
+	}
+	return c.send(protocol.WriteRemovedSounds(ids))
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 )
 
@@ -61,3 +62,23 @@ func (c *Config) GetOrDefault(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// GetIntOrDefault retrieves a configuration value as an integer or returns a default value if it is missing or invalid
+func (c *Config) GetIntOrDefault(key string, defaultValue int) int {
+	if value, exists := c.settings[key]; exists {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+// GetFloatOrDefault retrieves a configuration value as a float or returns a default value if it is missing or invalid
+func (c *Config) GetFloatOrDefault(key string, defaultValue float32) float32 {
+	if value, exists := c.settings[key]; exists {
+		if parsed, err := strconv.ParseFloat(value, 32); err == nil {
+			return float32(parsed)
+		}
+	}
+	return defaultValue
+}
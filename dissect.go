@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"bettermt/main/capture"
+	"bettermt/main/dissect"
+)
+
+// runDissect prints the packets of a capture file in readable form, as `bettermt dissect <capture>`
+func runDissect(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: bettermt dissect <capture>")
+	}
+	file, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	records, err := capture.ReadAll(file)
+	if err != nil && len(records) == 0 {
+		return err
+	}
+	if werr := dissect.New().Write(os.Stdout, records); werr != nil {
+		return werr
+	}
+	if err != nil {
+		// A capture cut short by a crash still has its complete records printed
+		return fmt.Errorf("capture truncated after %d records: %w", len(records), err)
+	}
+	return nil
+}
@@ -0,0 +1,988 @@
+package dissect
+
+import (
+	"encoding/hex"
+	"fmt"
+	"image/color"
+	"math"
+	"sort"
+	"strconv"
+
+	"bettermt/main/formspec"
+	"bettermt/main/hud"
+	"bettermt/main/inventory"
+	"bettermt/main/meshbuilder"
+	"bettermt/main/network"
+	"bettermt/main/object"
+	"bettermt/main/particle"
+	"bettermt/main/protocol"
+	"bettermt/main/sky"
+	"bettermt/main/sound"
+)
+
+// Number of names listed before the rest of a list is only counted
+const listLimit = 10
+
+// toClientDecoders maps server commands to their decoder
+var toClientDecoders = map[uint16]decoder{
+	network.ToClientHello:                 decodeHello,
+	network.ToClientAuthAccept:            decodeAuthAccept,
+	network.ToClientAccessDenied:          decodeAccessDenied,
+	network.ToClientSRPBytesSB:            decodeSRPBytesSB,
+	network.ToClientAnnounceMedia:         decodeAnnounceMedia,
+	network.ToClientMedia:                 decodeMedia,
+	network.ToClientNodeDef:               decodeNodeDef,
+	network.ToClientBlockData:             decodeBlockData,
+	network.ToClientChatMessage:           decodeChatMessage,
+	network.ToClientMovePlayer:            decodeMovePlayer,
+	network.ToClientMovement:              decodeMovement,
+	network.ToClientPrivileges:            decodePrivileges,
+	network.ToClientItemDef:               decodeItemDef,
+	network.ToClientInventory:             decodeInventory,
+	network.ToClientHudAdd:                decodeHudAdd,
+	network.ToClientHudRm:                 decodeHudRm,
+	network.ToClientHudChange:             decodeHudChange,
+	network.ToClientHudSetFlags:           decodeHudSetFlags,
+	network.ToClientHudSetParam:           decodeHudSetParam,
+	network.ToClientTimeOfDay:             decodeTimeOfDay,
+	network.ToClientSetSky:                decodeSetSky,
+	network.ToClientSetSun:                decodeSetSun,
+	network.ToClientSetMoon:               decodeSetMoon,
+	network.ToClientSetStars:              decodeSetStars,
+	network.ToClientCloudParams:           decodeCloudParams,
+	network.ToClientOverrideDayNightRatio: decodeOverrideDayNightRatio,
+	network.ToClientActiveObjectRemoveAdd: decodeActiveObjectRemoveAdd,
+	network.ToClientActiveObjectMessages:  decodeActiveObjectMessages,
+	network.ToClientSpawnParticle:         decodeSpawnParticle,
+	network.ToClientAddParticleSpawner:    decodeAddParticleSpawner,
+	network.ToClientDeleteParticleSpawner: decodeDeleteParticleSpawner,
+	network.ToClientPlaySound:             decodePlaySound,
+	network.ToClientStopSound:             decodeStopSound,
+	network.ToClientFadeSound:             decodeFadeSound,
+	network.ToClientShowFormspec:          decodeShowFormspec,
+	network.ToClientInventoryFormspec:     decodeInventoryFormspec,
+	network.ToClientFormspecPrepend:       decodeFormspecPrepend,
+}
+
+// toServerDecoders maps client commands to their decoder
+var toServerDecoders = map[uint16]decoder{
+	network.ToServerInit:            decodeInit,
+	network.ToServerInit2:           decodeInit2,
+	network.ToServerFirstSRP:        decodeFirstSRP,
+	network.ToServerSRPBytesA:       decodeSRPBytesA,
+	network.ToServerSRPBytesM:       decodeSRPBytesM,
+	network.ToServerRequestMedia:    decodeRequestMedia,
+	network.ToServerGotBlocks:       decodeBlockList,
+	network.ToServerDeletedBlocks:   decodeBlockList,
+	network.ToServerChatMessage:     decodePlayerChatMessage,
+	network.ToServerClientReady:     decodeClientReady,
+	network.ToServerPlayerPos:       decodePlayerPos,
+	network.ToServerPlayerItem:      decodePlayerItem,
+	network.ToServerInteract:        decodeInteract,
+	network.ToServerRemovedSounds:   decodeRemovedSounds,
+	network.ToServerInventoryFields: decodeInventoryFields,
+	network.ToServerInventoryAction: decodeInventoryAction,
+}
+
+func decodeHello(d *Dissector, r *network.Reader) ([]Field, error) {
+	h, err := protocol.ReadHello(r)
+	if err != nil {
+		return nil, err
+	}
+	d.serializationVersion = h.SerializationVersion
+	d.protocolVersion = h.ProtocolVersion
+	return []Field{
+		{"serialization_version", strconv.Itoa(int(h.SerializationVersion))},
+		{"protocol_version", strconv.Itoa(int(h.ProtocolVersion))},
+		{"auth_mechanisms", authMechanisms(h.AuthMechanisms)},
+		{"legacy_name", strconv.Quote(h.LegacyName)},
+	}, nil
+}
+
+func decodeAuthAccept(d *Dissector, r *network.Reader) ([]Field, error) {
+	a, err := protocol.ReadAuthAccept(r)
+	if err != nil {
+		return nil, err
+	}
+	return []Field{
+		{"position", nodePosition(a.Position)},
+		{"map_seed", strconv.FormatUint(a.MapSeed, 10)},
+		{"send_interval", fmt.Sprintf("%gs", a.SendInterval)},
+		{"sudo_auth_mechanisms", authMechanisms(a.SudoAuthMechanisms)},
+	}, nil
+}
+
+func decodeAccessDenied(d *Dissector, r *network.Reader) ([]Field, error) {
+	a, err := protocol.ReadAccessDenied(r)
+	if err != nil {
+		return nil, err
+	}
+	return []Field{
+		{"code", strconv.Itoa(int(a.Code))},
+		{"reason", strconv.Quote(a.Reason)},
+		{"reconnect", strconv.FormatBool(a.Reconnect)},
+	}, nil
+}
+
+func decodeSRPBytesSB(d *Dissector, r *network.Reader) ([]Field, error) {
+	s, err := protocol.ReadSRPBytesSB(r)
+	if err != nil {
+		return nil, err
+	}
+	return []Field{
+		{"salt", hex.EncodeToString(s.Salt)},
+		{"bytes_B", fmt.Sprintf("%d bytes", len(s.BytesB))},
+	}, nil
+}
+
+func decodeAnnounceMedia(d *Dissector, r *network.Reader) ([]Field, error) {
+	a, err := protocol.ReadAnnounceMedia(r)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(a.Files))
+	for i, file := range a.Files {
+		names[i] = file.Name
+	}
+	return []Field{
+		{"files", countedList(names)},
+		{"remote_servers", countedList(a.RemoteServers)},
+	}, nil
+}
+
+func decodeMedia(d *Dissector, r *network.Reader) ([]Field, error) {
+	m, err := protocol.ReadMedia(r)
+	files := make([]string, len(m.Files))
+	for i, file := range m.Files {
+		files[i] = fmt.Sprintf("%s (%d bytes)", file.Name, len(file.Data))
+	}
+	return []Field{
+		{"bunch", fmt.Sprintf("%d of %d", m.Bunch+1, m.Bunches)},
+		{"files", countedList(files)},
+	}, err
+}
+
+func decodeNodeDef(d *Dissector, r *network.Reader) ([]Field, error) {
+	nodeDefs, err := protocol.ReadNodeDef(r)
+	if err != nil {
+		return nil, err
+	}
+	d.nodeDefs = nodeDefs
+	ids := nodeDefs.IDs()
+	names := make([]string, len(ids))
+	for i, id := range ids {
+		names[i] = fmt.Sprintf("%d=%s", id, nodeDefs.Get(id).Name)
+	}
+	return []Field{{"nodes", countedList(names)}}, nil
+}
+
+func decodeItemDef(d *Dissector, r *network.Reader) ([]Field, error) {
+	itemDefs, err := protocol.ReadItemDef(r)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, name := range itemDefs.Names() {
+		if name != inventory.HandItem {
+			names = append(names, fmt.Sprintf("%s (%s)", name, itemDefs.Get(name).Type))
+		}
+	}
+	var aliases []string
+	for name, convertTo := range itemDefs.Aliases() {
+		aliases = append(aliases, name+"="+convertTo)
+	}
+	sort.Strings(aliases)
+	return []Field{
+		{"items", countedList(names)},
+		{"aliases", countedList(aliases)},
+	}, nil
+}
+
+func decodeInventory(d *Dissector, r *network.Reader) ([]Field, error) {
+	data, err := protocol.ReadInventory(r)
+	if err != nil {
+		return nil, err
+	}
+	inv := inventory.New()
+	if err := inv.Deserialize(data); err != nil {
+		return nil, err
+	}
+	fields := make([]Field, 0, len(inv.Lists))
+	for _, l := range inv.Lists {
+		var items []string
+		for _, item := range l.Items {
+			if !item.IsEmpty() {
+				items = append(items, item.String())
+			}
+		}
+		fields = append(fields, Field{"list " + l.Name, fmt.Sprintf("%d slots, %s", len(l.Items), countedList(items))})
+	}
+	return fields, nil
+}
+
+func decodeBlockData(d *Dissector, r *network.Reader) ([]Field, error) {
+	b, err := protocol.ReadBlockData(r, d.serializationVersion)
+	if err != nil {
+		return nil, err
+	}
+	block := b.Block
+	return []Field{
+		{"position", fmt.Sprintf("(%d, %d, %d)", b.Position[0], b.Position[1], b.Position[2])},
+		{"flags", fmt.Sprintf("%#02x", block.Flags)},
+		{"lighting_complete", fmt.Sprintf("%#04x", block.LightingComplete)},
+		{"node_metadata", strconv.Itoa(len(block.NodeMetadata))},
+		{"nodes", d.nodeHistogram(block)},
+	}, nil
+}
+
+func decodeChatMessage(d *Dissector, r *network.Reader) ([]Field, error) {
+	m, err := protocol.ReadChatMessage(r)
+	if err != nil {
+		return nil, err
+	}
+	return []Field{
+		{"type", m.Type.String()},
+		{"sender", strconv.Quote(m.Sender)},
+		{"text", strconv.Quote(m.Text)},
+		{"timestamp", m.Timestamp.UTC().Format("2006-01-02 15:04:05")},
+	}, nil
+}
+
+func decodeMovePlayer(d *Dissector, r *network.Reader) ([]Field, error) {
+	m, err := protocol.ReadMovePlayer(r)
+	if err != nil {
+		return nil, err
+	}
+	return []Field{
+		{"position", nodePosition(m.Position)},
+		{"pitch", fmt.Sprintf("%.1f", m.Pitch)},
+		{"yaw", fmt.Sprintf("%.1f", m.Yaw)},
+	}, nil
+}
+
+func decodeMovement(d *Dissector, r *network.Reader) ([]Field, error) {
+	m, err := protocol.ReadMovement(r)
+	if err != nil {
+		return nil, err
+	}
+	return []Field{
+		{"acceleration", fmt.Sprintf("default %g, air %g, fast %g", m.AccelerationDefault, m.AccelerationAir, m.AccelerationFast)},
+		{"speed", fmt.Sprintf("walk %g, crouch %g, fast %g, climb %g, jump %g", m.SpeedWalk, m.SpeedCrouch, m.SpeedFast, m.SpeedClimb, m.SpeedJump)},
+		{"liquid", fmt.Sprintf("fluidity %g, smooth %g, sink %g", m.LiquidFluidity, m.LiquidFluiditySmooth, m.LiquidSink)},
+		{"gravity", fmt.Sprintf("%g", m.Gravity)},
+	}, nil
+}
+
+func decodePrivileges(d *Dissector, r *network.Reader) ([]Field, error) {
+	privileges, err := protocol.ReadPrivileges(r)
+	if err != nil {
+		return nil, err
+	}
+	return []Field{{"privileges", countedList(privileges)}}, nil
+}
+
+func decodeHudAdd(d *Dissector, r *network.Reader) ([]Field, error) {
+	e, err := protocol.ReadHudAdd(r)
+	if err != nil {
+		return nil, err
+	}
+	fields := []Field{
+		{"id", strconv.FormatUint(uint64(e.ID), 10)},
+		{"type", e.Type.String()},
+		{"position", fmt.Sprintf("(%g, %g)", e.Pos[0], e.Pos[1])},
+	}
+	if e.Name != "" {
+		fields = append(fields, Field{"name", strconv.Quote(e.Name)})
+	}
+	if e.Text != "" {
+		fields = append(fields, Field{"text", strconv.Quote(e.Text)})
+	}
+	fields = append(fields,
+		Field{"number", strconv.FormatUint(uint64(e.Number), 10)},
+		Field{"item", strconv.FormatUint(uint64(e.Item), 10)},
+		Field{"alignment", fmt.Sprintf("(%g, %g)", e.Align[0], e.Align[1])},
+		Field{"offset", fmt.Sprintf("(%g, %g)", e.Offset[0], e.Offset[1])},
+		Field{"z_index", strconv.Itoa(int(e.ZIndex))},
+	)
+	return fields, nil
+}
+
+func decodeHudRm(d *Dissector, r *network.Reader) ([]Field, error) {
+	id, err := protocol.ReadHudRm(r)
+	if err != nil {
+		return nil, err
+	}
+	return []Field{{"id", strconv.FormatUint(uint64(id), 10)}}, nil
+}
+
+func decodeHudChange(d *Dissector, r *network.Reader) ([]Field, error) {
+	c, err := protocol.ReadHudChange(r)
+	if err != nil {
+		return nil, err
+	}
+	var value string
+	switch c.Stat {
+	case hud.StatPos, hud.StatScale, hud.StatAlign, hud.StatOffset:
+		value = fmt.Sprintf("(%g, %g)", c.V2F[0], c.V2F[1])
+	case hud.StatName, hud.StatText, hud.StatText2:
+		value = strconv.Quote(c.String)
+	case hud.StatWorldPos:
+		value = fmt.Sprintf("(%g, %g, %g)", c.V3F[0], c.V3F[1], c.V3F[2])
+	case hud.StatSize:
+		value = fmt.Sprintf("(%d, %d)", c.V2S32[0], c.V2S32[1])
+	default:
+		value = strconv.FormatUint(uint64(c.U32), 10)
+	}
+	return []Field{
+		{"id", strconv.FormatUint(uint64(c.ID), 10)},
+		{"stat", c.Stat.String()},
+		{"value", value},
+	}, nil
+}
+
+func decodeHudSetFlags(d *Dissector, r *network.Reader) ([]Field, error) {
+	f, err := protocol.ReadHudSetFlags(r)
+	if err != nil {
+		return nil, err
+	}
+	return []Field{
+		{"flags", fmt.Sprintf("%#x", uint32(f.Flags))},
+		{"mask", fmt.Sprintf("%#x", uint32(f.Mask))},
+	}, nil
+}
+
+func decodeHudSetParam(d *Dissector, r *network.Reader) ([]Field, error) {
+	p, err := protocol.ReadHudSetParam(r)
+	if err != nil {
+		return nil, err
+	}
+	switch p.Param {
+	case hud.ParamHotbarItemCount:
+		count, err := p.HotbarItemCount()
+		if err != nil {
+			return nil, err
+		}
+		return []Field{{"hotbar_itemcount", strconv.Itoa(count)}}, nil
+	case hud.ParamHotbarImage:
+		return []Field{{"hotbar_image", strconv.Quote(p.Value)}}, nil
+	case hud.ParamHotbarSelectedImage:
+		return []Field{{"hotbar_selected_image", strconv.Quote(p.Value)}}, nil
+	}
+	return []Field{{"param", strconv.Itoa(int(p.Param))}, {"value", strconv.Quote(p.Value)}}, nil
+}
+
+func decodeTimeOfDay(d *Dissector, r *network.Reader) ([]Field, error) {
+	t, err := protocol.ReadTimeOfDay(r)
+	if err != nil {
+		return nil, err
+	}
+	return []Field{
+		{"time", fmt.Sprintf("%d (%02d:%02d)", t.Time, t.Time/1000, int(t.Time%1000)*60/1000)},
+		{"speed", fmt.Sprintf("%g", t.Speed)},
+	}, nil
+}
+
+func decodeSetSky(d *Dissector, r *network.Reader) ([]Field, error) {
+	p, err := protocol.ReadSetSky(r)
+	if err != nil {
+		return nil, err
+	}
+	fields := []Field{
+		{"type", p.Type},
+		{"bgcolor", formatColor(p.BgColor)},
+		{"clouds", strconv.FormatBool(p.Clouds)},
+	}
+	switch p.Type {
+	case sky.TypeSkybox:
+		quoted := make([]string, len(p.Textures))
+		for i, texture := range p.Textures {
+			quoted[i] = strconv.Quote(texture)
+		}
+		fields = append(fields, Field{"textures", list(quoted, listLimit)})
+	case sky.TypeRegular:
+		fields = append(fields,
+			Field{"day", formatColor(p.Colors.DaySky) + " / " + formatColor(p.Colors.DayHorizon)},
+			Field{"dawn", formatColor(p.Colors.DawnSky) + " / " + formatColor(p.Colors.DawnHorizon)},
+			Field{"night", formatColor(p.Colors.NightSky) + " / " + formatColor(p.Colors.NightHorizon)},
+		)
+	}
+	if p.BodyOrbitTilt != 0 {
+		fields = append(fields, Field{"body_orbit_tilt", fmt.Sprintf("%g", p.BodyOrbitTilt)})
+	}
+	return fields, nil
+}
+
+func decodeSetSun(d *Dissector, r *network.Reader) ([]Field, error) {
+	p, err := protocol.ReadSetSun(r)
+	if err != nil {
+		return nil, err
+	}
+	return []Field{
+		{"visible", strconv.FormatBool(p.Visible)},
+		{"texture", strconv.Quote(p.Texture)},
+		{"sunrise_visible", strconv.FormatBool(p.SunriseVisible)},
+		{"scale", fmt.Sprintf("%g", p.Scale)},
+	}, nil
+}
+
+func decodeSetMoon(d *Dissector, r *network.Reader) ([]Field, error) {
+	p, err := protocol.ReadSetMoon(r)
+	if err != nil {
+		return nil, err
+	}
+	return []Field{
+		{"visible", strconv.FormatBool(p.Visible)},
+		{"texture", strconv.Quote(p.Texture)},
+		{"scale", fmt.Sprintf("%g", p.Scale)},
+	}, nil
+}
+
+func decodeSetStars(d *Dissector, r *network.Reader) ([]Field, error) {
+	p, err := protocol.ReadSetStars(r)
+	if err != nil {
+		return nil, err
+	}
+	return []Field{
+		{"visible", strconv.FormatBool(p.Visible)},
+		{"count", strconv.FormatUint(uint64(p.Count), 10)},
+		{"color", formatColor(p.Color)},
+		{"scale", fmt.Sprintf("%g", p.Scale)},
+	}, nil
+}
+
+func decodeCloudParams(d *Dissector, r *network.Reader) ([]Field, error) {
+	p, err := protocol.ReadCloudParams(r)
+	if err != nil {
+		return nil, err
+	}
+	return []Field{
+		{"density", fmt.Sprintf("%g", p.Density)},
+		{"color", formatColor(p.ColorBright)},
+		{"height", fmt.Sprintf("%g", p.Height)},
+		{"thickness", fmt.Sprintf("%g", p.Thickness)},
+		{"speed", fmt.Sprintf("(%g, %g)", p.Speed[0], p.Speed[1])},
+	}, nil
+}
+
+func decodeOverrideDayNightRatio(d *Dissector, r *network.Reader) ([]Field, error) {
+	o, err := protocol.ReadOverrideDayNightRatio(r)
+	if err != nil {
+		return nil, err
+	}
+	if !o.Override {
+		return []Field{{"override", "false"}}, nil
+	}
+	return []Field{{"override", "true"}, {"ratio", fmt.Sprintf("%.3f", o.Ratio)}}, nil
+}
+
+func decodeActiveObjectRemoveAdd(d *Dissector, r *network.Reader) ([]Field, error) {
+	a, err := protocol.ReadActiveObjectRemoveAdd(r)
+	if err != nil {
+		return nil, err
+	}
+	removed := make([]string, len(a.Removed))
+	for i, id := range a.Removed {
+		removed[i] = strconv.FormatUint(uint64(id), 10)
+	}
+	added := make([]string, len(a.Added))
+	for i, o := range a.Added {
+		if o.Type != protocol.ObjectTypeGeneric {
+			added[i] = fmt.Sprintf("%d (type %d)", o.ID, o.Type)
+			continue
+		}
+		init, err := protocol.ReadGenericInit(o.InitData)
+		if err != nil {
+			return nil, err
+		}
+		added[i] = fmt.Sprintf("%d (%s)", o.ID, strconv.Quote(init.Name))
+	}
+	return []Field{{"removed", countedList(removed)}, {"added", countedList(added)}}, nil
+}
+
+func decodeActiveObjectMessages(d *Dissector, r *network.Reader) ([]Field, error) {
+	messages, err := protocol.ReadActiveObjectMessages(r)
+	if err != nil {
+		return nil, err
+	}
+	fields := make([]Field, 0, len(messages))
+	for _, m := range messages {
+		msg, err := protocol.ReadObjectMessage(m.Data)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, Field{"object " + strconv.FormatUint(uint64(m.ID), 10), formatObjectMessage(m.Data[0], msg)})
+	}
+	return fields, nil
+}
+
+func decodeSpawnParticle(d *Dissector, r *network.Reader) ([]Field, error) {
+	p, err := protocol.ReadSpawnParticle(r)
+	if err != nil {
+		return nil, err
+	}
+	fields := []Field{
+		{"pos", formatVec(p.Position)},
+		{"vel", formatVec(p.Velocity)},
+		{"acc", formatVec(p.Acceleration)},
+		{"expirationtime", fmt.Sprintf("%g", p.ExpirationTime)},
+		{"size", fmt.Sprintf("%g", p.Size)},
+		{"texture", strconv.Quote(p.Texture.Name)},
+		{"collisiondetection", strconv.FormatBool(p.CollisionDetection)},
+		{"glow", strconv.Itoa(int(p.Glow))},
+	}
+	if p.Node.IsSet() {
+		fields = append(fields, Field{"node", d.nodeName(p.Node.Content)})
+	}
+	return fields, nil
+}
+
+func decodeAddParticleSpawner(d *Dissector, r *network.Reader) ([]Field, error) {
+	s, err := protocol.ReadAddParticleSpawner(r, d.protocolVersion)
+	if err != nil {
+		return nil, err
+	}
+	p := &s.Params
+	fields := []Field{
+		{"id", strconv.FormatUint(uint64(s.ID), 10)},
+		{"amount", strconv.Itoa(int(p.Amount))},
+		{"time", fmt.Sprintf("%g", p.Time)},
+		{"pos", formatVec(p.Position.Start.Min) + " - " + formatVec(p.Position.Start.Max)},
+		{"texture", strconv.Quote(p.Texture.Name)},
+	}
+	if len(p.TexturePool) > 0 {
+		names := make([]string, len(p.TexturePool))
+		for i, t := range p.TexturePool {
+			names[i] = strconv.Quote(t.Name)
+		}
+		fields = append(fields, Field{"texpool", list(names, listLimit)})
+	}
+	if p.Node.IsSet() {
+		fields = append(fields, Field{"node", d.nodeName(p.Node.Content)})
+	}
+	if p.AttachedID != 0 {
+		fields = append(fields, Field{"attached_id", strconv.Itoa(int(p.AttachedID))})
+	}
+	if p.AttractorKind != particle.AttractorNone {
+		fields = append(fields, Field{"attractor", attractorKinds[p.AttractorKind]})
+	}
+	return fields, nil
+}
+
+func decodeDeleteParticleSpawner(d *Dissector, r *network.Reader) ([]Field, error) {
+	id, err := protocol.ReadDeleteParticleSpawner(r)
+	if err != nil {
+		return nil, err
+	}
+	return []Field{{"id", strconv.FormatUint(uint64(id), 10)}}, nil
+}
+
+func decodePlaySound(d *Dissector, r *network.Reader) ([]Field, error) {
+	s, err := protocol.ReadPlaySound(r)
+	if err != nil {
+		return nil, err
+	}
+	fields := []Field{
+		{"id", strconv.Itoa(int(s.ID))},
+		{"name", strconv.Quote(s.Spec.Name)},
+		{"gain", fmt.Sprintf("%g", s.Spec.Gain)},
+	}
+	switch s.Type {
+	case sound.LocationPosition:
+		fields = append(fields, Field{"pos", nodePosition(s.Position)})
+	case sound.LocationObject:
+		fields = append(fields, Field{"object", strconv.Itoa(int(s.ObjectID))})
+	}
+	if s.Spec.Loop {
+		fields = append(fields, Field{"loop", "true"})
+	}
+	if s.Spec.Fade != 0 {
+		fields = append(fields, Field{"fade", fmt.Sprintf("%g", s.Spec.Fade)})
+	}
+	if s.Spec.Pitch != 1 {
+		fields = append(fields, Field{"pitch", fmt.Sprintf("%g", s.Spec.Pitch)})
+	}
+	if s.Ephemeral {
+		fields = append(fields, Field{"ephemeral", "true"})
+	}
+	if s.Spec.StartTime != 0 {
+		fields = append(fields, Field{"start_time", fmt.Sprintf("%g", s.Spec.StartTime)})
+	}
+	return fields, nil
+}
+
+func decodeStopSound(d *Dissector, r *network.Reader) ([]Field, error) {
+	id, err := protocol.ReadStopSound(r)
+	if err != nil {
+		return nil, err
+	}
+	return []Field{{"id", strconv.Itoa(int(id))}}, nil
+}
+
+func decodeFadeSound(d *Dissector, r *network.Reader) ([]Field, error) {
+	f, err := protocol.ReadFadeSound(r)
+	if err != nil {
+		return nil, err
+	}
+	return []Field{
+		{"id", strconv.Itoa(int(f.ID))},
+		{"step", fmt.Sprintf("%g", f.Step)},
+		{"gain", fmt.Sprintf("%g", f.Gain)},
+	}, nil
+}
+
+func decodeShowFormspec(d *Dissector, r *network.Reader) ([]Field, error) {
+	s, err := protocol.ReadShowFormspec(r)
+	if err != nil {
+		return nil, err
+	}
+	return []Field{
+		{"formname", strconv.Quote(s.FormName)},
+		{"formspec", formspecSummary(s.Formspec)},
+	}, nil
+}
+
+func decodeInventoryFormspec(d *Dissector, r *network.Reader) ([]Field, error) {
+	spec, err := protocol.ReadInventoryFormspec(r)
+	if err != nil {
+		return nil, err
+	}
+	return []Field{{"formspec", formspecSummary(spec)}}, nil
+}
+
+func decodeFormspecPrepend(d *Dissector, r *network.Reader) ([]Field, error) {
+	prepend, err := protocol.ReadFormspecPrepend(r)
+	if err != nil {
+		return nil, err
+	}
+	return []Field{{"prepend", formspecSummary(prepend)}}, nil
+}
+
+// attractorKinds are the names Minetest's Lua API gives particle attractors
+var attractorKinds = map[particle.AttractorKind]string{
+	particle.AttractorPoint: "point",
+	particle.AttractorLine:  "line",
+	particle.AttractorPlane: "plane",
+}
+
+// formatObjectMessage describes an active object message with the values most worth seeing
+func formatObjectMessage(command uint8, msg object.Message) string {
+	switch m := msg.(type) {
+	case object.SetProperties:
+		return fmt.Sprintf("set_properties visual=%s textures=%s", m.Properties.Visual, countedList(m.Properties.Textures))
+	case object.UpdatePosition:
+		p := m.Position
+		return fmt.Sprintf("update_position (%g, %g, %g)", p[0], p[1], p[2])
+	case object.SetTextureMod:
+		return "set_texture_mod " + strconv.Quote(m.Mod)
+	case object.Punched:
+		return fmt.Sprintf("punched hp=%d", m.HP)
+	case object.SetAnimation:
+		return fmt.Sprintf("set_animation frames=%g-%g speed=%g", m.Animation.Frames[0], m.Animation.Frames[1], m.Animation.Speed)
+	case object.SetBone:
+		return "set_bone " + strconv.Quote(m.Bone)
+	case object.AttachTo:
+		return fmt.Sprintf("attach_to parent=%d bone=%s", m.Attachment.ParentID, strconv.Quote(m.Attachment.Bone))
+	}
+	if int(command) < len(objectCommandNames) {
+		return objectCommandNames[command]
+	}
+	return fmt.Sprintf("command %d", command)
+}
+
+// Names of the active object commands by their number
+var objectCommandNames = []string{
+	protocol.AOCmdSetProperties:      "set_properties",
+	protocol.AOCmdUpdatePosition:     "update_position",
+	protocol.AOCmdSetTextureMod:      "set_texture_mod",
+	protocol.AOCmdSetSprite:          "set_sprite",
+	protocol.AOCmdPunched:            "punched",
+	protocol.AOCmdUpdateArmorGroups:  "update_armor_groups",
+	protocol.AOCmdSetAnimation:       "set_animation",
+	protocol.AOCmdSetBonePosition:    "set_bone_position",
+	protocol.AOCmdAttachTo:           "attach_to",
+	protocol.AOCmdSetPhysicsOverride: "set_physics_override",
+	protocol.AOCmdObsolete1:          "obsolete",
+	protocol.AOCmdSpawnInfant:        "spawn_infant",
+	protocol.AOCmdSetAnimationSpeed:  "set_animation_speed",
+}
+
+func decodePlayerItem(d *Dissector, r *network.Reader) ([]Field, error) {
+	p, err := protocol.ReadPlayerItem(r)
+	if err != nil {
+		return nil, err
+	}
+	return []Field{{"index", strconv.Itoa(int(p.Index))}}, nil
+}
+
+func decodeInit(d *Dissector, r *network.Reader) ([]Field, error) {
+	i, err := protocol.ReadInit(r)
+	if err != nil {
+		return nil, err
+	}
+	return []Field{
+		{"max_serialization_version", strconv.Itoa(int(i.MaxSerializationVersion))},
+		{"protocol_versions", fmt.Sprintf("%d to %d", i.MinProtocolVersion, i.MaxProtocolVersion)},
+		{"name", strconv.Quote(i.Name)},
+	}, nil
+}
+
+func decodeInit2(d *Dissector, r *network.Reader) ([]Field, error) {
+	i, err := protocol.ReadInit2(r)
+	if err != nil {
+		return nil, err
+	}
+	return []Field{{"language", strconv.Quote(i.Language)}}, nil
+}
+
+func decodeFirstSRP(d *Dissector, r *network.Reader) ([]Field, error) {
+	f, err := protocol.ReadFirstSRP(r)
+	if err != nil {
+		return nil, err
+	}
+	return []Field{
+		{"salt", hex.EncodeToString(f.Salt)},
+		{"verifier", fmt.Sprintf("%d bytes", len(f.Verifier))},
+		{"empty_password", strconv.FormatBool(f.EmptyPassword)},
+	}, nil
+}
+
+func decodeSRPBytesA(d *Dissector, r *network.Reader) ([]Field, error) {
+	s, err := protocol.ReadSRPBytesA(r)
+	if err != nil {
+		return nil, err
+	}
+	return []Field{
+		{"bytes_A", fmt.Sprintf("%d bytes", len(s.BytesA))},
+		{"based_on", strconv.Itoa(int(s.BasedOn))},
+	}, nil
+}
+
+func decodeSRPBytesM(d *Dissector, r *network.Reader) ([]Field, error) {
+	s, err := protocol.ReadSRPBytesM(r)
+	if err != nil {
+		return nil, err
+	}
+	return []Field{{"bytes_M", fmt.Sprintf("%d bytes", len(s.M))}}, nil
+}
+
+func decodeRequestMedia(d *Dissector, r *network.Reader) ([]Field, error) {
+	m, err := protocol.ReadRequestMedia(r)
+	if err != nil {
+		return nil, err
+	}
+	return []Field{{"files", countedList(m.Files)}}, nil
+}
+
+func decodeBlockList(d *Dissector, r *network.Reader) ([]Field, error) {
+	b, err := protocol.ReadBlockList(r)
+	if err != nil {
+		return nil, err
+	}
+	positions := make([]string, len(b.Positions))
+	for i, pos := range b.Positions {
+		positions[i] = fmt.Sprintf("(%d, %d, %d)", pos[0], pos[1], pos[2])
+	}
+	return []Field{{"blocks", countedList(positions)}}, nil
+}
+
+func decodePlayerChatMessage(d *Dissector, r *network.Reader) ([]Field, error) {
+	m, err := protocol.ReadPlayerChatMessage(r)
+	if err != nil {
+		return nil, err
+	}
+	return []Field{{"text", strconv.Quote(m.Text)}}, nil
+}
+
+func decodeClientReady(d *Dissector, r *network.Reader) ([]Field, error) {
+	c, err := protocol.ReadClientReady(r)
+	if err != nil {
+		return nil, err
+	}
+	return []Field{
+		{"version", fmt.Sprintf("%d.%d.%d", c.VersionMajor, c.VersionMinor, c.VersionPatch)},
+		{"version_string", strconv.Quote(c.VersionString)},
+		{"formspec_version", strconv.Itoa(int(c.FormspecVersion))},
+	}, nil
+}
+
+func decodePlayerPos(d *Dissector, r *network.Reader) ([]Field, error) {
+	p, err := protocol.ReadPlayerPos(r)
+	if err != nil {
+		return nil, err
+	}
+	return []Field{
+		{"position", nodePosition(p.Position)},
+		{"speed", nodePosition(p.Speed)},
+		{"pitch", fmt.Sprintf("%.1f", p.Pitch)},
+		{"yaw", fmt.Sprintf("%.1f", p.Yaw)},
+		{"keys", keys(p.Keys)},
+		{"fov", fmt.Sprintf("%.1f", p.FOV*180/math.Pi)},
+		{"wanted_range", strconv.Itoa(int(p.WantedRange))},
+	}, nil
+}
+
+func decodeInteract(d *Dissector, r *network.Reader) ([]Field, error) {
+	i, err := protocol.ReadInteract(r)
+	if err != nil {
+		return nil, err
+	}
+	return []Field{
+		{"action", i.Action.String()},
+		{"item_index", strconv.Itoa(int(i.ItemIndex))},
+		{"pointed", i.Pointed.String()},
+		{"position", nodePosition(i.Player.Position)},
+	}, nil
+}
+
+func decodeRemovedSounds(d *Dissector, r *network.Reader) ([]Field, error) {
+	ids, err := protocol.ReadRemovedSounds(r)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(ids))
+	for i, id := range ids {
+		names[i] = strconv.Itoa(int(id))
+	}
+	return []Field{{"ids", countedList(names)}}, nil
+}
+
+func decodeInventoryFields(d *Dissector, r *network.Reader) ([]Field, error) {
+	f, err := protocol.ReadInventoryFields(r)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(f.Fields))
+	for name := range f.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fields := []Field{{"formname", strconv.Quote(f.FormName)}}
+	for _, name := range names {
+		fields = append(fields, Field{"field " + name, strconv.Quote(f.Fields[name])})
+	}
+	return fields, nil
+}
+
+func decodeInventoryAction(d *Dissector, r *network.Reader) ([]Field, error) {
+	action, err := protocol.ReadInventoryAction(r)
+	if err != nil {
+		return nil, err
+	}
+	return []Field{{"action", strconv.Quote(action)}}, nil
+}
+
+// nodeHistogram counts the nodes of a block by name, most common first
+func (d *Dissector) nodeHistogram(block *meshbuilder.MapBlock) string {
+	counts := make(map[uint16]int)
+	for x := int32(0); x < meshbuilder.ChunkSize; x++ {
+		for y := int32(0); y < meshbuilder.ChunkSize; y++ {
+			for z := int32(0); z < meshbuilder.ChunkSize; z++ {
+				id, _ := block.GetBlock(x, y, z)
+				counts[id]++
+			}
+		}
+	}
+	ids := make([]uint16, 0, len(counts))
+	for id := range counts {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if counts[ids[i]] != counts[ids[j]] {
+			return counts[ids[i]] > counts[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+
+	entries := make([]string, len(ids))
+	for i, id := range ids {
+		entries[i] = fmt.Sprintf("%s %d", d.nodeName(id), counts[id])
+	}
+	return list(entries, listLimit)
+}
+
+// nodeName returns the name of a content ID, or the ID itself for nodes not defined yet
+func (d *Dissector) nodeName(id uint16) string {
+	if def, exists := d.nodeDefs.Lookup(id); exists {
+		return def.Name
+	}
+	return fmt.Sprintf("#%d", id)
+}
+
+// authMechanisms names the mechanisms set in a bit field
+func authMechanisms(mechanisms uint32) string {
+	names := []string{}
+	for _, m := range []struct {
+		bit  uint32
+		name string
+	}{
+		{network.AuthMechanismLegacyPassword, "legacy_password"},
+		{network.AuthMechanismSRP, "srp"},
+		{network.AuthMechanismFirstSRP, "first_srp"},
+	} {
+		if mechanisms&m.bit != 0 {
+			names = append(names, m.name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return list(names, len(names))
+}
+
+// keys names the keys set in the bit field of TOSERVER_PLAYERPOS
+func keys(bits uint32) string {
+	names := []string{}
+	for i, name := range []string{"forward", "backward", "left", "right", "jump", "aux1", "sneak", "dig", "place", "zoom"} {
+		if bits&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return list(names, len(names))
+}
+
+// nodePosition formats a position sent on the wire in nodes
+func nodePosition(pos [3]float32) string {
+	return fmt.Sprintf("(%.1f, %.1f, %.1f)", pos[0]/network.BS, pos[1]/network.BS, pos[2]/network.BS)
+}
+
+// formspecSummary lists the elements of a formspec by type, or says why it does not parse
+func formspecSummary(s string) string {
+	if s == "" {
+		return "empty"
+	}
+	f, err := formspec.Parse(s)
+	if err != nil {
+		return fmt.Sprintf("%d bytes, %v", len(s), err)
+	}
+	var types []string
+	var add func(elements []formspec.Element)
+	add = func(elements []formspec.Element) {
+		for _, e := range elements {
+			types = append(types, e.Type())
+			switch c := e.(type) {
+			case *formspec.Container:
+				add(c.Elements)
+			case *formspec.ScrollContainer:
+				add(c.Elements)
+			}
+		}
+	}
+	add(f.Elements)
+	return fmt.Sprintf("version %d, %s", f.Version, countedList(types))
+}
+
+// formatColor formats a color like a ColorString, as #RRGGBB or #RRGGBBAA if it is not opaque
+func formatColor(c color.NRGBA) string {
+	if c.A == 0xff {
+		return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+	}
+	return fmt.Sprintf("#%02x%02x%02x%02x", c.R, c.G, c.B, c.A)
+}
+
+// formatVec formats a vector as (x, y, z)
+func formatVec(v [3]float32) string {
+	return fmt.Sprintf("(%g, %g, %g)", v[0], v[1], v[2])
+}
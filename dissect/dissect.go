@@ -0,0 +1,137 @@
+package dissect
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"bettermt/main/blocktypes"
+	"bettermt/main/capture"
+	"bettermt/main/network"
+)
+
+// Number of bytes shown for packets without a decoder
+const hexPreviewLength = 64
+
+// Field is one decoded value of a packet
+type Field struct {
+	Name  string
+	Value string
+}
+
+// Packet is the readable form of one captured packet
+type Packet struct {
+	Command uint16
+	Name    string
+	Size    int // Length of the packet in bytes, command included
+	Fields  []Field
+	Err     error // Set if the body could not be decoded, Fields then holds what was read before
+}
+
+// decoder turns the body of one command into fields
+type decoder func(d *Dissector, r *network.Reader) ([]Field, error)
+
+// Dissector decodes the packets of one session in order. It follows the session the way a client
+// would, so that later packets such as blocks are decoded with the versions and nodes sent before them.
+type Dissector struct {
+	serializationVersion uint8
+	protocolVersion      uint16
+	nodeDefs             *blocktypes.NodeDefManager
+}
+
+// New creates a dissector for a session that has not exchanged any packet yet
+func New() *Dissector {
+	return &Dissector{
+		serializationVersion: network.SerializationVersionMax,
+		protocolVersion:      network.ProtocolVersionMax,
+		nodeDefs:             blocktypes.NewNodeDefManager(),
+	}
+}
+
+// Dissect decodes one packet. Inbound packets are the ones a client received from the server.
+func (d *Dissector) Dissect(rec capture.Record) Packet {
+	commands, decoders := network.ToClientCommands, toClientDecoders
+	if rec.Direction == capture.Outbound {
+		commands, decoders = network.ToServerCommands, toServerDecoders
+	}
+
+	r := network.NewReader(rec.Data)
+	p := Packet{Command: r.U16(), Size: len(rec.Data)}
+	if r.Err() != nil {
+		p.Name = "(empty)"
+		p.Err = r.Err()
+		return p
+	}
+	p.Name = commands[p.Command].Name
+	if p.Name == "" {
+		p.Name = fmt.Sprintf("unknown command %#04x", p.Command)
+	}
+
+	decode, exists := decoders[p.Command]
+	if !exists {
+		if r.Len() > 0 {
+			p.Fields = []Field{{"data", hexPreview(r.Remaining())}}
+		}
+		return p
+	}
+	p.Fields, p.Err = decode(d, r)
+	return p
+}
+
+// Write prints every record of a capture with its decoded packet
+func (d *Dissector) Write(w io.Writer, records []capture.Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	start := records[0].Time
+	for i, rec := range records {
+		header := fmt.Sprintf("#%d %+.3fs %-3s ch%d", i, rec.Time.Sub(start).Seconds(), rec.Direction, rec.Channel)
+		if err := d.Dissect(rec).Write(w, header); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Write prints the packet name after a header, followed by one line per field
+func (p Packet) Write(w io.Writer, header string) error {
+	if _, err := fmt.Fprintf(w, "%s %s (%d bytes)\n", header, p.Name, p.Size); err != nil {
+		return err
+	}
+	for _, f := range p.Fields {
+		if _, err := fmt.Fprintf(w, "    %s: %s\n", f.Name, f.Value); err != nil {
+			return err
+		}
+	}
+	if p.Err != nil {
+		if _, err := fmt.Fprintf(w, "    error: %v\n", p.Err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hexPreview formats the start of raw data
+func hexPreview(data []byte) string {
+	if len(data) > hexPreviewLength {
+		return hex.EncodeToString(data[:hexPreviewLength]) + "..."
+	}
+	return hex.EncodeToString(data)
+}
+
+// list joins at most limit items, telling how many were left out
+func list(items []string, limit int) string {
+	if len(items) > limit {
+		return strings.Join(items[:limit], ", ") + fmt.Sprintf(", ... %d more", len(items)-limit)
+	}
+	return strings.Join(items, ", ")
+}
+
+// countedList tells how many items there are, followed by the first of them
+func countedList(items []string) string {
+	if len(items) == 0 {
+		return "0"
+	}
+	return fmt.Sprintf("%d: %s", len(items), list(items, listLimit))
+}
@@ -0,0 +1,164 @@
+package dissect
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"bettermt/main/capture"
+	"bettermt/main/chat"
+	"bettermt/main/client"
+	"bettermt/main/network"
+	"bettermt/main/protocol"
+)
+
+// readSession reads the capture of the capture package tests, the INIT a client sent and the HELLO, NODEDEF and
+// BLOCKDATA it got back, and appends a chat message received after them
+func readSession(t *testing.T) []capture.Record {
+	t.Helper()
+	f, err := os.Open("../capture/testdata/blocks.bmtcap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	records, err := capture.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := chat.Message{Type: chat.TypeNormal, Sender: "alice", Text: "hello world", Timestamp: time.Unix(1700000000, 0)}
+	return append(records, capture.Record{
+		Time:      records[len(records)-1].Time.Add(time.Millisecond),
+		Direction: capture.Inbound,
+		Reliable:  true,
+		Data:      protocol.WriteChatMessage(msg).Bytes(),
+	})
+}
+
+func TestWrite(t *testing.T) {
+	var out bytes.Buffer
+	if err := New().Write(&out, readSession(t)); err != nil {
+		t.Fatal(err)
+	}
+	want := `#0 +0.000s out ch1 TOSERVER_INIT (17 bytes)
+    max_serialization_version: 29
+    protocol_versions: 37 to 44
+    name: "tester"
+#1 +0.003s in  ch0 TOCLIENT_HELLO (19 bytes)
+    serialization_version: 29
+    protocol_version: 44
+    auth_mechanisms: first_srp
+    legacy_name: "tester"
+#2 +0.006s in  ch0 TOCLIENT_NODEDEF (125 bytes)
+    nodes: 5: 10=test:stone, 11=test:glass, 125=unknown, 126=air, 127=ignore
+#3 +0.009s in  ch0 TOCLIENT_BLOCKDATA (114 bytes)
+    position: (1, -1, 2)
+    flags: 0x00
+    lighting_complete: 0x0000
+    node_metadata: 0
+    nodes: air 3071, test:stone 1024, test:glass 1
+#4 +0.010s in  ch0 TOCLIENT_CHAT_MESSAGE (48 bytes)
+    type: normal
+    sender: "alice"
+    text: "hello world"
+    timestamp: 2023-11-14 22:13:20
+`
+	if got := out.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestDissect(t *testing.T) {
+	records := readSession(t)
+	d := New()
+	tests := []struct {
+		name   string
+		fields map[string]string
+	}{
+		{"TOSERVER_INIT", map[string]string{"name": `"tester"`}},
+		{"TOCLIENT_HELLO", map[string]string{"serialization_version": "29", "protocol_version": "44"}},
+		{"TOCLIENT_NODEDEF", nil},
+		{"TOCLIENT_BLOCKDATA", map[string]string{
+			"position": "(1, -1, 2)",
+			"nodes":    "air 3071, test:stone 1024, test:glass 1",
+		}},
+		{"TOCLIENT_CHAT_MESSAGE", map[string]string{"sender": `"alice"`, "text": `"hello world"`}},
+	}
+	for i, test := range tests {
+		p := d.Dissect(records[i])
+		if p.Err != nil {
+			t.Fatalf("#%d %s: %v", i, p.Name, p.Err)
+		}
+		if p.Name != test.name || p.Size != len(records[i].Data) {
+			t.Errorf("#%d: %s (%d bytes), want %s (%d bytes)", i, p.Name, p.Size, test.name, len(records[i].Data))
+		}
+		values := make(map[string]string)
+		for _, f := range p.Fields {
+			values[f.Name] = f.Value
+		}
+		for name, want := range test.fields {
+			if values[name] != want {
+				t.Errorf("#%d %s: %s %q, want %q", i, p.Name, name, values[name], want)
+			}
+		}
+	}
+	if d.serializationVersion != 29 || d.protocolVersion != 44 {
+		t.Errorf("versions %d, %d after the HELLO, want 29, 44", d.serializationVersion, d.protocolVersion)
+	}
+}
+
+func TestBlockBeforeNodeDefinitions(t *testing.T) {
+	records := readSession(t)
+	d := New()
+	d.Dissect(records[1])
+	p := d.Dissect(records[3])
+	if p.Err != nil {
+		t.Fatal(p.Err)
+	}
+	// Without the NODEDEF the nodes the server defined are only known by their ID
+	if nodes := p.Fields[len(p.Fields)-1]; nodes.Name != "nodes" || !strings.Contains(nodes.Value, "#10 1024, #11 1") {
+		t.Errorf("%s: %s, want the IDs of the undefined nodes", nodes.Name, nodes.Value)
+	}
+}
+
+func TestUndecodable(t *testing.T) {
+	chatMessage := protocol.WriteChatMessage(chat.Message{Text: "cut off"}).Bytes()
+	tests := []struct {
+		name   string
+		data   []byte
+		want   string
+		fields []Field
+		err    bool
+	}{
+		{"empty", nil, "(empty)", nil, true},
+		{"unknown command", []byte{0xff, 0xfe, 1, 2}, "unknown command 0xfffe", []Field{{"data", "0102"}}, false},
+		{"truncated", chatMessage[:len(chatMessage)-2], "TOCLIENT_CHAT_MESSAGE", nil, true},
+	}
+	for _, test := range tests {
+		p := New().Dissect(capture.Record{Direction: capture.Inbound, Data: test.data})
+		if p.Name != test.want || (p.Err != nil) != test.err || len(p.Fields) != len(test.fields) {
+			t.Errorf("%s: %s, fields %v, error %v", test.name, p.Name, p.Fields, p.Err)
+			continue
+		}
+		for i := range test.fields {
+			if p.Fields[i] != test.fields[i] {
+				t.Errorf("%s: field %v, want %v", test.name, p.Fields[i], test.fields[i])
+			}
+		}
+	}
+}
+
+func TestDecodersCoverClientHandlers(t *testing.T) {
+	for _, command := range client.HandledCommands() {
+		name := network.ToClientCommands[command].Name
+		t.Run(name, func(t *testing.T) {
+			if name == "" {
+				t.Fatalf("client handles command %#04x, which has no name", command)
+			}
+			if _, exists := toClientDecoders[command]; !exists {
+				t.Errorf("client handles %s, the dissector cannot decode it", name)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package formspec
+
+import (
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// namedColors are the color names formspecs take besides #RGB, #RGBA, #RRGGBB and #RRGGBBAA. Minetest knows
+// every CSS color name; these are the common ones.
+var namedColors = map[string]color.NRGBA{
+	"transparent": {0, 0, 0, 0},
+	"black":       {0, 0, 0, 255},
+	"white":       {255, 255, 255, 255},
+	"gray":        {128, 128, 128, 255},
+	"grey":        {128, 128, 128, 255},
+	"darkgray":    {169, 169, 169, 255},
+	"darkgrey":    {169, 169, 169, 255},
+	"lightgray":   {211, 211, 211, 255},
+	"lightgrey":   {211, 211, 211, 255},
+	"silver":      {192, 192, 192, 255},
+	"red":         {255, 0, 0, 255},
+	"darkred":     {139, 0, 0, 255},
+	"maroon":      {128, 0, 0, 255},
+	"orange":      {255, 165, 0, 255},
+	"gold":        {255, 215, 0, 255},
+	"yellow":      {255, 255, 0, 255},
+	"olive":       {128, 128, 0, 255},
+	"lime":        {0, 255, 0, 255},
+	"green":       {0, 128, 0, 255},
+	"darkgreen":   {0, 100, 0, 255},
+	"cyan":        {0, 255, 255, 255},
+	"aqua":        {0, 255, 255, 255},
+	"teal":        {0, 128, 128, 255},
+	"blue":        {0, 0, 255, 255},
+	"darkblue":    {0, 0, 139, 255},
+	"navy":        {0, 0, 128, 255},
+	"magenta":     {255, 0, 255, 255},
+	"fuchsia":     {255, 0, 255, 255},
+	"purple":      {128, 0, 128, 255},
+	"pink":        {255, 192, 203, 255},
+	"brown":       {165, 42, 42, 255},
+}
+
+// ParseColor reads a color of a formspec, such as #f00, #ff000080 or red. A name may be followed by # and
+// two hex digits of alpha, like red#80.
+func ParseColor(s string) (color.NRGBA, bool) {
+	s = strings.TrimSpace(s)
+	if hex, found := strings.CutPrefix(s, "#"); found {
+		return parseHexColor(hex)
+	}
+	name, alpha, hasAlpha := strings.Cut(strings.ToLower(s), "#")
+	c, known := namedColors[name]
+	if !known {
+		return color.NRGBA{}, false
+	}
+	if hasAlpha {
+		a, err := strconv.ParseUint(alpha, 16, 8)
+		if err != nil || len(alpha) != 2 {
+			return color.NRGBA{}, false
+		}
+		c.A = uint8(a)
+	}
+	return c, true
+}
+
+// parseHexColor reads the digits of RGB, RGBA, RRGGBB or RRGGBBAA
+func parseHexColor(hex string) (color.NRGBA, bool) {
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, false
+	}
+	switch len(hex) {
+	case 3:
+		return color.NRGBA{uint8(v>>8&0xf) * 0x11, uint8(v>>4&0xf) * 0x11, uint8(v&0xf) * 0x11, 255}, true
+	case 4:
+		return color.NRGBA{uint8(v>>12&0xf) * 0x11, uint8(v>>8&0xf) * 0x11, uint8(v>>4&0xf) * 0x11, uint8(v&0xf) * 0x11}, true
+	case 6:
+		return color.NRGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 255}, true
+	case 8:
+		return color.NRGBA{uint8(v >> 24), uint8(v >> 16), uint8(v >> 8), uint8(v)}, true
+	}
+	return color.NRGBA{}, false
+}
@@ -0,0 +1,538 @@
+package formspec
+
+import "strings"
+
+// parsers maps the names of the known elements to the function reading their parameters
+var parsers = map[string]func(p *params) Element{
+	"size":                 parseSize,
+	"position":             parsePosition,
+	"anchor":               parseAnchor,
+	"padding":              parsePadding,
+	"no_prepend":           func(p *params) Element { return &NoPrepend{} },
+	"real_coordinates":     parseRealCoordinates,
+	"container":            parseContainer,
+	"scroll_container":     parseScrollContainer,
+	"list":                 parseList,
+	"listring":             parseListRing,
+	"listcolors":           parseListColors,
+	"tooltip":              parseTooltip,
+	"image":                parseImage,
+	"item_image":           parseItemImage,
+	"bgcolor":              parseBgColor,
+	"background":           parseBackground,
+	"background9":          parseBackground,
+	"box":                  parseBox,
+	"label":                parseLabel,
+	"vertlabel":            parseLabel,
+	"hypertext":            parseHypertext,
+	"style":                parseStyle,
+	"style_type":           parseStyle,
+	"set_focus":            parseSetFocus,
+	"button":               parseButton,
+	"button_exit":          parseButton,
+	"image_button":         parseImageButton,
+	"image_button_exit":    parseImageButton,
+	"item_image_button":    parseItemImageButton,
+	"field":                parseField,
+	"pwdfield":             parseField,
+	"field_close_on_enter": parseFieldCloseOnEnter,
+	"textarea":             parseTextArea,
+	"textlist":             parseTextList,
+	"tabheader":            parseTabHeader,
+	"dropdown":             parseDropdown,
+	"checkbox":             parseCheckbox,
+	"scrollbar":            parseScrollbar,
+	"scrollbaroptions":     parseScrollbarOptions,
+	"table":                parseTable,
+	"tableoptions":         parseTableOptions,
+	"tablecolumns":         parseTableColumns,
+}
+
+// Size is size[], the size of the formspec
+type Size struct {
+	Size      [2]float32
+	FixedSize bool // The formspec is not scaled to the window
+}
+
+func parseSize(p *params) Element {
+	s := &Size{}
+	parts := p.list()
+	if p.err == nil && len(parts) != 2 && len(parts) != 3 {
+		p.fail("expected W,H or W,H,fixed_size, got %q", strings.Join(parts, ","))
+	}
+	if p.err == nil {
+		s.Size = [2]float32{p.number(parts[0]), p.number(parts[1])}
+		s.FixedSize = len(parts) == 3 && isYes(parts[2])
+	}
+	return s
+}
+
+func (s *Size) Type() string { return "size" }
+
+func (s *Size) write(w *writer) {
+	if s.FixedSize {
+		w.list([]string{formatFloat(s.Size[0]), formatFloat(s.Size[1]), "true"})
+		return
+	}
+	w.vec(s.Size)
+}
+
+// Position is position[], where the formspec is on the screen from 0,0 for the top left to 1,1 for the bottom
+// right corner
+type Position struct {
+	Pos [2]float32
+}
+
+func parsePosition(p *params) Element { return &Position{Pos: p.vec()} }
+func (e *Position) Type() string      { return "position" }
+func (e *Position) write(w *writer)   { w.vec(e.Pos) }
+
+// Anchor is anchor[], the point of the formspec put at its position, from 0,0 for its top left corner
+type Anchor struct {
+	Pos [2]float32
+}
+
+func parseAnchor(p *params) Element { return &Anchor{Pos: p.vec()} }
+func (e *Anchor) Type() string      { return "anchor" }
+func (e *Anchor) write(w *writer)   { w.vec(e.Pos) }
+
+// Padding is padding[], the space kept free around the formspec as a fraction of the screen
+type Padding struct {
+	Padding [2]float32
+}
+
+func parsePadding(p *params) Element { return &Padding{Padding: p.vec()} }
+func (e *Padding) Type() string      { return "padding" }
+func (e *Padding) write(w *writer)   { w.vec(e.Padding) }
+
+// NoPrepend is no_prepend[], which leaves out the formspec prepend of the server
+type NoPrepend struct{}
+
+func (e *NoPrepend) Type() string    { return "no_prepend" }
+func (e *NoPrepend) write(w *writer) {}
+
+// RealCoordinates is real_coordinates[], which switches the coordinates of the following elements
+type RealCoordinates struct {
+	Enabled bool
+}
+
+func parseRealCoordinates(p *params) Element { return &RealCoordinates{Enabled: p.bool()} }
+func (e *RealCoordinates) Type() string      { return "real_coordinates" }
+func (e *RealCoordinates) write(w *writer)   { w.bool(e.Enabled) }
+
+// Container is container[] up to container_end[], which moves the elements within by its position
+type Container struct {
+	Pos      [2]float32
+	Elements []Element
+}
+
+func parseContainer(p *params) Element { return &Container{Pos: p.vec()} }
+func (e *Container) Type() string      { return "container" }
+func (e *Container) write(w *writer)   { w.vec(e.Pos) }
+
+// ScrollContainer is scroll_container[] up to scroll_container_end[], which shows the part of the elements within
+// its area that the scrollbar of a name scrolled to
+type ScrollContainer struct {
+	Pos, Size      [2]float32
+	Scrollbar      string
+	Orientation    string  // "vertical" or "horizontal"
+	Factor         float32 // Distance scrolled per step of the scrollbar, 0.1 unless given
+	ContentPadding float32 // Space after the elements, since version 7
+	Elements       []Element
+}
+
+func parseScrollContainer(p *params) Element {
+	s := &ScrollContainer{Factor: 0.1}
+	s.Pos, s.Size = p.rect()
+	s.Scrollbar = p.text()
+	s.Orientation = p.text()
+	if p.more() {
+		s.Factor = p.float()
+	}
+	if p.more() {
+		s.ContentPadding = p.float()
+	}
+	return s
+}
+
+func (e *ScrollContainer) Type() string { return "scroll_container" }
+
+func (e *ScrollContainer) write(w *writer) {
+	w.rect(e.Pos, e.Size)
+	w.text(e.Scrollbar)
+	w.text(e.Orientation)
+	w.floats(e.Factor)
+	if e.ContentPadding != 0 {
+		w.floats(e.ContentPadding)
+	}
+}
+
+// List is list[], the slots of an inventory list
+type List struct {
+	Location  string // Inventory the list belongs to, such as current_player or nodemeta:X,Y,Z, never escaped
+	ListName  string
+	Pos, Size [2]float32 // Size in slots
+	Start     int        // Index of the first slot shown
+}
+
+func parseList(p *params) Element {
+	l := &List{Location: p.take(), ListName: p.text()}
+	l.Pos, l.Size = p.rect()
+	if p.more() {
+		l.Start = p.int()
+	}
+	return l
+}
+
+func (e *List) Type() string { return "list" }
+
+func (e *List) write(w *writer) {
+	w.raw(e.Location)
+	w.text(e.ListName)
+	w.rect(e.Pos, e.Size)
+	if e.Start != 0 {
+		w.int(e.Start)
+	}
+}
+
+// ListRing is listring[], a list that shift clicked items move to, or the last two lists shown if it is empty
+type ListRing struct {
+	Location string // Never escaped, like the location of List
+	ListName string
+}
+
+func parseListRing(p *params) Element {
+	if !p.more() {
+		return &ListRing{}
+	}
+	return &ListRing{Location: p.take(), ListName: p.text()}
+}
+
+func (e *ListRing) Type() string { return "listring" }
+
+func (e *ListRing) write(w *writer) {
+	if e.Location != "" || e.ListName != "" {
+		w.raw(e.Location)
+		w.text(e.ListName)
+	}
+}
+
+// ListColors is listcolors[], the colors of the slots of lists and of their tooltips
+type ListColors struct {
+	SlotBg      string
+	SlotBgHover string
+	SlotBorder  string // Empty for no border
+	TooltipBg   string
+	TooltipFont string
+}
+
+func parseListColors(p *params) Element {
+	c := &ListColors{SlotBg: p.text(), SlotBgHover: p.text()}
+	if p.more() {
+		c.SlotBorder = p.text()
+	}
+	if p.more() {
+		c.TooltipBg, c.TooltipFont = p.text(), p.text()
+	}
+	return c
+}
+
+func (e *ListColors) Type() string { return "listcolors" }
+
+func (e *ListColors) write(w *writer) {
+	w.text(e.SlotBg)
+	w.text(e.SlotBgHover)
+	if e.SlotBorder != "" || e.TooltipBg != "" || e.TooltipFont != "" {
+		w.text(e.SlotBorder)
+	}
+	if e.TooltipBg != "" || e.TooltipFont != "" {
+		w.text(e.TooltipBg)
+		w.text(e.TooltipFont)
+	}
+}
+
+// Tooltip is tooltip[], the text shown when hovering an element or an area
+type Tooltip struct {
+	Element   string     // Name of the element, empty for a tooltip of the area
+	Pos, Size [2]float32 // Area of the tooltip if it has no element
+	Text      string
+	Bg        string // Colors, empty for the ones of listcolors[]
+	Font      string
+}
+
+func parseTooltip(p *params) Element {
+	t := &Tooltip{}
+	if p.left() >= 3 && isVec(p.raw[0]) && isVec(p.raw[1]) {
+		t.Pos, t.Size = p.rect()
+	} else {
+		t.Element = p.text()
+	}
+	t.Text = p.text()
+	if p.more() {
+		t.Bg, t.Font = p.text(), p.text()
+	}
+	return t
+}
+
+func (e *Tooltip) Type() string { return "tooltip" }
+
+func (e *Tooltip) write(w *writer) {
+	if e.Element == "" {
+		w.rect(e.Pos, e.Size)
+	} else {
+		w.text(e.Element)
+	}
+	w.text(e.Text)
+	if e.Bg != "" || e.Font != "" {
+		w.text(e.Bg)
+		w.text(e.Font)
+	}
+}
+
+// isVec reports whether a parameter is a pair of numbers
+func isVec(raw string) bool {
+	p := &params{raw: []string{raw}}
+	p.vec()
+	return p.err == nil
+}
+
+// Image is image[], a texture stretched over an area
+type Image struct {
+	Pos, Size [2]float32
+	Texture   string
+	Middle    []float32 // Border drawn unstretched as 9-slice, since version 6: one, two or four numbers
+}
+
+func parseImage(p *params) Element {
+	i := &Image{}
+	i.Pos, i.Size = p.rect()
+	i.Texture = p.text()
+	if p.more() {
+		i.Middle = p.floats(1, 4)
+	}
+	return i
+}
+
+func (e *Image) Type() string { return "image" }
+
+func (e *Image) write(w *writer) {
+	w.rect(e.Pos, e.Size)
+	w.text(e.Texture)
+	if len(e.Middle) > 0 {
+		w.floats(e.Middle...)
+	}
+}
+
+// ItemImage is item_image[], the inventory image of an item
+type ItemImage struct {
+	Pos, Size [2]float32
+	Item      string
+}
+
+func parseItemImage(p *params) Element {
+	i := &ItemImage{}
+	i.Pos, i.Size = p.rect()
+	i.Item = p.text()
+	return i
+}
+
+func (e *ItemImage) Type() string { return "item_image" }
+
+func (e *ItemImage) write(w *writer) {
+	w.rect(e.Pos, e.Size)
+	w.text(e.Item)
+}
+
+// BgColor is bgcolor[], the color behind the formspec and behind the rest of the screen
+type BgColor struct {
+	Color           string
+	Fullscreen      string // "true", "false", "both" or "neither", empty if not given
+	FullscreenColor string
+}
+
+func parseBgColor(p *params) Element {
+	b := &BgColor{Color: p.text()}
+	if p.more() {
+		b.Fullscreen = p.text()
+	}
+	if p.more() {
+		b.FullscreenColor = p.text()
+	}
+	return b
+}
+
+func (e *BgColor) Type() string { return "bgcolor" }
+
+func (e *BgColor) write(w *writer) {
+	w.text(e.Color)
+	if e.Fullscreen != "" || e.FullscreenColor != "" {
+		w.text(e.Fullscreen)
+	}
+	if e.FullscreenColor != "" {
+		w.text(e.FullscreenColor)
+	}
+}
+
+// Background is background[] or background9[], a texture behind the formspec
+type Background struct {
+	Pos, Size [2]float32
+	Texture   string
+	AutoClip  bool      // Cover the whole formspec, with Pos and Size added to its edges
+	Middle    []float32 // Border drawn unstretched as 9-slice, which makes it background9[]
+}
+
+func parseBackground(p *params) Element {
+	b := &Background{}
+	b.Pos, b.Size = p.rect()
+	b.Texture = p.text()
+	if p.more() {
+		b.AutoClip = p.bool()
+	}
+	if p.name == "background9" {
+		b.Middle = p.floats(1, 4)
+	}
+	return b
+}
+
+func (e *Background) Type() string {
+	if len(e.Middle) > 0 {
+		return "background9"
+	}
+	return "background"
+}
+
+func (e *Background) write(w *writer) {
+	w.rect(e.Pos, e.Size)
+	w.text(e.Texture)
+	if len(e.Middle) > 0 {
+		w.bool(e.AutoClip)
+		w.floats(e.Middle...)
+	} else if e.AutoClip {
+		w.bool(true)
+	}
+}
+
+// Box is box[], a colored rectangle
+type Box struct {
+	Pos, Size [2]float32
+	Color     string
+}
+
+func parseBox(p *params) Element {
+	b := &Box{}
+	b.Pos, b.Size = p.rect()
+	b.Color = p.text()
+	return b
+}
+
+func (e *Box) Type() string { return "box" }
+
+func (e *Box) write(w *writer) {
+	w.rect(e.Pos, e.Size)
+	w.text(e.Color)
+}
+
+// Label is label[] or vertlabel[], text without a background
+type Label struct {
+	Pos      [2]float32
+	Text     string
+	Vertical bool // One character below the other, which makes it vertlabel[]
+}
+
+func parseLabel(p *params) Element {
+	return &Label{Pos: p.vec(), Text: p.text(), Vertical: p.name == "vertlabel"}
+}
+
+func (e *Label) Type() string {
+	if e.Vertical {
+		return "vertlabel"
+	}
+	return "label"
+}
+
+func (e *Label) write(w *writer) {
+	w.vec(e.Pos)
+	w.text(e.Text)
+}
+
+// Hypertext is hypertext[], text with markup such as <b> and <action>
+type Hypertext struct {
+	Pos, Size [2]float32
+	Name      string
+	Text      string
+}
+
+func parseHypertext(p *params) Element {
+	h := &Hypertext{}
+	h.Pos, h.Size = p.rect()
+	h.Name, h.Text = p.text(), p.text()
+	return h
+}
+
+func (e *Hypertext) Type() string { return "hypertext" }
+
+func (e *Hypertext) write(w *writer) {
+	w.rect(e.Pos, e.Size)
+	w.text(e.Name)
+	w.text(e.Text)
+}
+
+// Style is style[] or style_type[], properties of the elements of some names or types that follow it
+type Style struct {
+	ByType     bool     // The selectors are element types, which makes it style_type[]
+	Selectors  []string // Names or types, each optionally followed by states such as :hovered
+	Properties []Property
+}
+
+func parseStyle(p *params) Element {
+	return &Style{ByType: p.name == "style_type", Selectors: p.list(), Properties: p.props()}
+}
+
+func (e *Style) Type() string {
+	if e.ByType {
+		return "style_type"
+	}
+	return "style"
+}
+
+func (e *Style) write(w *writer) {
+	w.list(e.Selectors)
+	w.props(e.Properties)
+}
+
+// SetFocus is set_focus[], the element that has the keyboard when the formspec opens
+type SetFocus struct {
+	Name  string
+	Force bool // Take the focus when the formspec is shown again too
+}
+
+func parseSetFocus(p *params) Element {
+	s := &SetFocus{Name: p.text()}
+	if p.more() {
+		s.Force = p.bool()
+	}
+	return s
+}
+
+func (e *SetFocus) Type() string { return "set_focus" }
+
+func (e *SetFocus) write(w *writer) {
+	w.text(e.Name)
+	if e.Force {
+		w.bool(true)
+	}
+}
+
+// Unknown is an element this package does not know, kept as it was written
+type Unknown struct {
+	Name   string
+	Params []string // Parameters separated by semicolons, still escaped
+}
+
+func (e *Unknown) Type() string { return e.Name }
+
+func (e *Unknown) write(w *writer) {
+	for _, param := range e.Params {
+		w.raw(param)
+	}
+}
@@ -0,0 +1,52 @@
+package formspec
+
+import "strings"
+
+// Characters with a meaning in formspecs, which text has to escape with a backslash
+const specialChars = `\[];,`
+
+// Escape escapes text so that it can be put into a formspec as it is, like minetest.formspec_escape
+func Escape(s string) string {
+	if !strings.ContainsAny(s, specialChars) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(specialChars, s[i]) >= 0 {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// Unescape removes the backslashes escaping characters of text taken from a formspec
+func Unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// split cuts s at every sep that is not escaped, keeping the escapes in the parts
+func split(s string, sep byte) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
@@ -0,0 +1,215 @@
+// Package formspec parses the formspec strings Minetest describes its menus with into a tree of typed elements,
+// and writes such trees back into strings. Elements the package does not know are kept as they are written.
+package formspec
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Newest formspec_version understood
+const MaxVersion = 7
+
+// First formspec_version whose coordinates are real coordinates unless real_coordinates[] says otherwise
+const realCoordinatesVersion = 2
+
+// Formspec is a parsed formspec
+type Formspec struct {
+	Version  int // formspec_version, 1 if the string did not give one
+	Elements []Element
+}
+
+// Element is one element of a formspec, such as a *Button
+type Element interface {
+	// Type returns the name the element is written with, such as "button_exit"
+	Type() string
+	write(w *writer)
+}
+
+// Error is an element that could not be parsed
+type Error struct {
+	Index int    // Position of the element in the formspec, counting from 1
+	Type  string // Name of the element
+	Err   error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("formspec element %d (%s): %v", e.Index, e.Type, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// RealCoordinates reports whether positions and sizes at the top level of the formspec are in real coordinates
+// of one unit per inventory slot including the spacing, rather than the older coordinates with spacing added.
+// It depends on the version and on the real_coordinates[] element after size[].
+func (f *Formspec) RealCoordinates() bool {
+	enabled := f.Version >= realCoordinatesVersion
+	for _, e := range f.Elements {
+		if rc, ok := e.(*RealCoordinates); ok {
+			enabled = rc.Enabled
+		}
+	}
+	return enabled
+}
+
+// Parse parses a formspec. Whitespace between elements is ignored, and so are parameters past the ones an element
+// takes, which newer versions may add.
+func Parse(s string) (*Formspec, error) {
+	f := &Formspec{Version: 1}
+	// Elements of the open containers, innermost last
+	open := []*[]Element{&f.Elements}
+	var containers []Element
+	var starts []int // Index of each open container
+
+	// Every element ends in ], so what follows the last one is left over
+	sources := split(s, ']')
+	index := 0
+	for _, source := range sources[:len(sources)-1] {
+		if strings.TrimSpace(source) == "" {
+			continue
+		}
+		index++
+		name, rest, found := strings.Cut(source, "[")
+		name = strings.TrimSpace(name)
+		if !found {
+			return nil, &Error{Index: index, Type: name, Err: fmt.Errorf("missing [ or ] in %q", source)}
+		}
+
+		var err error
+		switch name {
+		case "formspec_version":
+			if index != 1 {
+				err = fmt.Errorf("must be the first element")
+				break
+			}
+			f.Version, err = parseVersion(rest)
+		case "container_end", "scroll_container_end":
+			if len(containers) == 0 || endOf(containers[len(containers)-1]) != name {
+				err = fmt.Errorf("no open container to end")
+				break
+			}
+			containers, starts = containers[:len(containers)-1], starts[:len(starts)-1]
+			open = open[:len(open)-1]
+		default:
+			var e Element
+			e, err = parseElement(name, rest)
+			if err != nil {
+				break
+			}
+			elements := open[len(open)-1]
+			*elements = append(*elements, e)
+			switch c := e.(type) {
+			case *Container:
+				containers, starts, open = append(containers, c), append(starts, index), append(open, &c.Elements)
+			case *ScrollContainer:
+				containers, starts, open = append(containers, c), append(starts, index), append(open, &c.Elements)
+			}
+		}
+		if err != nil {
+			return nil, &Error{Index: index, Type: name, Err: err}
+		}
+	}
+
+	if last := strings.TrimSpace(sources[len(sources)-1]); last != "" {
+		name, _, _ := strings.Cut(last, "[")
+		return nil, &Error{Index: index + 1, Type: strings.TrimSpace(name), Err: fmt.Errorf("missing ]")}
+	}
+	if len(containers) > 0 {
+		c := containers[len(containers)-1]
+		return nil, &Error{Index: starts[len(starts)-1], Type: c.Type(), Err: fmt.Errorf("missing %s[]", endOf(c))}
+	}
+	return f, nil
+}
+
+// parseVersion reads the version of formspec_version[]
+func parseVersion(rest string) (int, error) {
+	v, err := strconv.Atoi(strings.TrimSpace(Unescape(rest)))
+	if err != nil {
+		return 0, fmt.Errorf("%q is not an integer", rest)
+	}
+	if v < 1 || v > MaxVersion {
+		return 0, fmt.Errorf("version %d not supported", v)
+	}
+	return v, nil
+}
+
+// parseElement parses the parameters of an element of a name
+func parseElement(name, rest string) (Element, error) {
+	p := &params{name: name}
+	if rest != "" {
+		p.raw = split(rest, ';')
+	}
+	parse, known := parsers[name]
+	if !known {
+		return &Unknown{Name: name, Params: p.raw}, nil
+	}
+	e := parse(p)
+	if p.err != nil {
+		return nil, p.err
+	}
+	return e, nil
+}
+
+// Prepend puts the elements of a formspec prepend, as the server sends it, in front of the elements of f.
+// They follow the elements placing the formspec, such as size[], and are left out if f has no_prepend[].
+func (f *Formspec) Prepend(prepend string) error {
+	if strings.TrimSpace(prepend) == "" {
+		return nil
+	}
+	p, err := Parse(prepend)
+	if err != nil {
+		return fmt.Errorf("prepend: %w", err)
+	}
+	at := 0
+	for ; at < len(f.Elements); at++ {
+		switch f.Elements[at].(type) {
+		case *NoPrepend:
+			return nil
+		case *Size, *Position, *Anchor, *Padding:
+			continue
+		}
+		break
+	}
+	f.Elements = append(f.Elements[:at:at], append(p.Elements, f.Elements[at:]...)...)
+	return nil
+}
+
+// endOf returns the name of the element ending a container
+func endOf(container Element) string {
+	return container.Type() + "_end"
+}
+
+// String writes the formspec as a string, with formspec_version[] first if it is above 1
+func (f *Formspec) String() string {
+	var b strings.Builder
+	if f.Version > 1 {
+		fmt.Fprintf(&b, "formspec_version[%d]", f.Version)
+	}
+	writeElements(&b, f.Elements)
+	return b.String()
+}
+
+func writeElements(b *strings.Builder, elements []Element) {
+	for _, e := range elements {
+		b.WriteString(e.Type())
+		b.WriteByte('[')
+		e.write(&writer{b: b})
+		b.WriteByte(']')
+
+		var children []Element
+		switch c := e.(type) {
+		case *Container:
+			children = c.Elements
+		case *ScrollContainer:
+			children = c.Elements
+		default:
+			continue
+		}
+		writeElements(b, children)
+		b.WriteString(endOf(e))
+		b.WriteString("[]")
+	}
+}
@@ -0,0 +1,286 @@
+package formspec
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestEscape(t *testing.T) {
+	for _, test := range []struct {
+		text, escaped string
+	}{
+		{"plain text", "plain text"},
+		{"a]b", `a\]b`},
+		{"a[b", `a\[b`},
+		{"a;b", `a\;b`},
+		{"a,b", `a\,b`},
+		{`a\b`, `a\\b`},
+		{`\]`, `\\\]`},
+		{`C:\;]`, `C:\\\;\]`},
+	} {
+		if got := Escape(test.text); got != test.escaped {
+			t.Errorf("Escape(%q) = %q, want %q", test.text, got, test.escaped)
+		}
+		if got := Unescape(test.escaped); got != test.text {
+			t.Errorf("Unescape(%q) = %q, want %q", test.escaped, got, test.text)
+		}
+	}
+	// A backslash at the end escapes nothing and is kept
+	if got := Unescape(`a\`); got != `a\` {
+		t.Errorf(`Unescape("a\\") = %q`, got)
+	}
+}
+
+func TestEscapedParameters(t *testing.T) {
+	for _, test := range []struct {
+		source string
+		want   Element
+	}{
+		{`label[0,0;a\]b\;c\\d\,e\[f]`, &Label{Text: `a]b;c\d,e[f`}},
+		{`label[0,0;ends in \\]`, &Label{Text: `ends in \`}},
+		{`textlist[0,0;4,3;tl;one\,two,th\]ree,\\]`, &TextList{Size: [2]float32{4, 3}, Name: "tl", Items: []string{"one,two", "th]ree", `\`}}},
+		{`style[btn;bgimg=a.png^\[colorize:red]`, &Style{Selectors: []string{"btn"}, Properties: []Property{{"bgimg", "a.png^[colorize:red"}}}},
+		// Unknown elements keep their parameters escaped
+		{`model[0,0;1,1;m;m.b3d;a\;b.png,c\].png]`, &Unknown{Name: "model", Params: []string{"0,0", "1,1", "m", "m.b3d", `a\;b.png,c\].png`}}},
+	} {
+		f, err := Parse(test.source)
+		if err != nil {
+			t.Errorf("%s: %v", test.source, err)
+			continue
+		}
+		if len(f.Elements) != 1 || !reflect.DeepEqual(f.Elements[0], test.want) {
+			t.Errorf("%s: parsed %#v, want %#v", test.source, f.Elements, test.want)
+		}
+		if got := f.String(); got != test.source {
+			t.Errorf("%s: written back as %s", test.source, got)
+		}
+	}
+}
+
+// elementTests holds every element the package knows in the form Parse(s).String() writes it back in
+var elementTests = []struct {
+	source string
+	want   Element
+}{
+	{"size[8,9]", &Size{Size: [2]float32{8, 9}}},
+	{"size[8,9.5,true]", &Size{Size: [2]float32{8, 9.5}, FixedSize: true}},
+	{"position[0.5,0.25]", &Position{Pos: [2]float32{0.5, 0.25}}},
+	{"anchor[0,1]", &Anchor{Pos: [2]float32{0, 1}}},
+	{"padding[0.125,0.25]", &Padding{Padding: [2]float32{0.125, 0.25}}},
+	{"no_prepend[]", &NoPrepend{}},
+	{"real_coordinates[true]", &RealCoordinates{Enabled: true}},
+	{"real_coordinates[false]", &RealCoordinates{}},
+	{"container[1,2]container_end[]", &Container{Pos: [2]float32{1, 2}}},
+	{"scroll_container[0,0;5,4;sb;vertical;0.1]scroll_container_end[]", &ScrollContainer{Size: [2]float32{5, 4}, Scrollbar: "sb", Orientation: "vertical", Factor: 0.1}},
+	{"scroll_container[0,0;5,4;sb;horizontal;0.5;2]scroll_container_end[]", &ScrollContainer{Size: [2]float32{5, 4}, Scrollbar: "sb", Orientation: "horizontal", Factor: 0.5, ContentPadding: 2}},
+	{"list[current_player;main;0,1;8,4]", &List{Location: "current_player", ListName: "main", Pos: [2]float32{0, 1}, Size: [2]float32{8, 4}}},
+	{"list[nodemeta:1,2,3;src;0,1;8,4;8]", &List{Location: "nodemeta:1,2,3", ListName: "src", Pos: [2]float32{0, 1}, Size: [2]float32{8, 4}, Start: 8}},
+	{"listring[]", &ListRing{}},
+	{"listring[current_player;main]", &ListRing{Location: "current_player", ListName: "main"}},
+	{"listring[nodemeta:-1,0,2;dst]", &ListRing{Location: "nodemeta:-1,0,2", ListName: "dst"}},
+	{"listcolors[#777;#888]", &ListColors{SlotBg: "#777", SlotBgHover: "#888"}},
+	{"listcolors[#777;#888;#000;#111;#fff]", &ListColors{SlotBg: "#777", SlotBgHover: "#888", SlotBorder: "#000", TooltipBg: "#111", TooltipFont: "#fff"}},
+	{"tooltip[btn;Click me]", &Tooltip{Element: "btn", Text: "Click me"}},
+	{"tooltip[0,0;2,1;Area;#000;#fff]", &Tooltip{Size: [2]float32{2, 1}, Text: "Area", Bg: "#000", Font: "#fff"}},
+	{"image[0,0;1,1;default_stone.png]", &Image{Size: [2]float32{1, 1}, Texture: "default_stone.png"}},
+	{"image[0,0;1,1;frame.png;2,3]", &Image{Size: [2]float32{1, 1}, Texture: "frame.png", Middle: []float32{2, 3}}},
+	{"item_image[1,1;1,1;default:dirt]", &ItemImage{Pos: [2]float32{1, 1}, Size: [2]float32{1, 1}, Item: "default:dirt"}},
+	{"bgcolor[#0008]", &BgColor{Color: "#0008"}},
+	{"bgcolor[#0008;both;#0004]", &BgColor{Color: "#0008", Fullscreen: "both", FullscreenColor: "#0004"}},
+	{"background[0,0;8,9;bg.png]", &Background{Size: [2]float32{8, 9}, Texture: "bg.png"}},
+	{"background[0,0;0,0;bg.png;true]", &Background{Texture: "bg.png", AutoClip: true}},
+	{"background9[0,0;8,9;bg.png;false;10]", &Background{Size: [2]float32{8, 9}, Texture: "bg.png", Middle: []float32{10}}},
+	{"box[0,0;1,1;#f00]", &Box{Size: [2]float32{1, 1}, Color: "#f00"}},
+	{"label[0.5,0.5;Hello]", &Label{Pos: [2]float32{0.5, 0.5}, Text: "Hello"}},
+	{"vertlabel[1,1;Up]", &Label{Pos: [2]float32{1, 1}, Text: "Up", Vertical: true}},
+	{"hypertext[0,0;6,4;info;<b>Bold</b>]", &Hypertext{Size: [2]float32{6, 4}, Name: "info", Text: "<b>Bold</b>"}},
+	{"style[btn,btn2:hovered;bgcolor=red;textcolor=#fff]", &Style{Selectors: []string{"btn", "btn2:hovered"}, Properties: []Property{{"bgcolor", "red"}, {"textcolor", "#fff"}}}},
+	{"style_type[button;border=false]", &Style{ByType: true, Selectors: []string{"button"}, Properties: []Property{{"border", "false"}}}},
+	{"set_focus[name]", &SetFocus{Name: "name"}},
+	{"set_focus[name;true]", &SetFocus{Name: "name", Force: true}},
+	{"button[1,2;3,1;ok;OK]", &Button{Pos: [2]float32{1, 2}, Size: [2]float32{3, 1}, Name: "ok", Label: "OK"}},
+	{"button_exit[1,2;3,1;quit;Quit]", &Button{Pos: [2]float32{1, 2}, Size: [2]float32{3, 1}, Name: "quit", Label: "Quit", Exit: true}},
+	{"image_button[0,0;1,1;img.png;ib;Go]", &ImageButton{Size: [2]float32{1, 1}, Texture: "img.png", Name: "ib", Label: "Go", DrawBorder: true}},
+	{"image_button_exit[0,0;1,1;img.png;ib;Go;true;false;pressed.png]", &ImageButton{Size: [2]float32{1, 1}, Texture: "img.png", Name: "ib", Label: "Go", NoClip: true, PressedTexture: "pressed.png", Exit: true}},
+	{"item_image_button[0,0;1,1;default:stone;iib;]", &ItemImageButton{Size: [2]float32{1, 1}, Item: "default:stone", Name: "iib"}},
+	{"field[1,1;4,1;name;Name;default]", &Field{Pos: [2]float32{1, 1}, Size: [2]float32{4, 1}, Positioned: true, Name: "name", Label: "Name", Default: "default"}},
+	{"field[name;Name;]", &Field{Name: "name", Label: "Name"}},
+	{"pwdfield[1,1;4,1;pw;Password]", &Field{Pos: [2]float32{1, 1}, Size: [2]float32{4, 1}, Positioned: true, Name: "pw", Label: "Password", Password: true}},
+	{"field_close_on_enter[name;false]", &FieldCloseOnEnter{Name: "name"}},
+	{"textarea[0,0;6,3;ta;Notes;text]", &TextArea{Size: [2]float32{6, 3}, Name: "ta", Label: "Notes", Default: "text"}},
+	{"textlist[0,0;4,3;tl;one,two,three]", &TextList{Size: [2]float32{4, 3}, Name: "tl", Items: []string{"one", "two", "three"}}},
+	{"textlist[0,0;4,3;tl;one,two;2;true]", &TextList{Size: [2]float32{4, 3}, Name: "tl", Items: []string{"one", "two"}, Selected: 2, Transparent: true}},
+	{"tabheader[0,0;tabs;One,Two;1]", &TabHeader{Name: "tabs", Captions: []string{"One", "Two"}, Current: 1, DrawBorder: true}},
+	{"tabheader[0,0;0.5;tabs;One,Two;1]", &TabHeader{Size: [2]float32{0, 0.5}, Name: "tabs", Captions: []string{"One", "Two"}, Current: 1, DrawBorder: true}},
+	{"tabheader[0,0;6,0.5;tabs;One,Two;2;true;false]", &TabHeader{Size: [2]float32{6, 0.5}, Name: "tabs", Captions: []string{"One", "Two"}, Current: 2, Transparent: true}},
+	{"dropdown[0,0;3;dd;a,b,c;2]", &Dropdown{Size: [2]float32{3, 0}, Name: "dd", Items: []string{"a", "b", "c"}, Selected: 2}},
+	{"dropdown[0,0;3,1;dd;a,b;1;true]", &Dropdown{Size: [2]float32{3, 1}, Name: "dd", Items: []string{"a", "b"}, Selected: 1, IndexEvent: true}},
+	{"checkbox[0,0;cb;Check]", &Checkbox{Name: "cb", Label: "Check"}},
+	{"checkbox[0,0;cb;Check;true]", &Checkbox{Name: "cb", Label: "Check", Selected: true}},
+	{"scrollbar[0,0;0.5,4;vertical;sb;10]", &Scrollbar{Size: [2]float32{0.5, 4}, Orientation: "vertical", Name: "sb", Value: 10}},
+	{"scrollbaroptions[min=0;max=100]", &ScrollbarOptions{Options: []Property{{"min", "0"}, {"max", "100"}}}},
+	{"table[0,0;6,4;tbl;a,b,c,d;0]", &Table{Size: [2]float32{6, 4}, Name: "tbl", Cells: []string{"a", "b", "c", "d"}}},
+	{"tableoptions[background=#000;border=false]", &TableOptions{Options: []Property{{"background", "#000"}, {"border", "false"}}}},
+	{"tablecolumns[text,align=left;image,0=a.png,1=b.png;color]", &TableColumns{Columns: []TableColumn{
+		{Type: "text", Options: []Property{{"align", "left"}}},
+		{Type: "image", Options: []Property{{"0", "a.png"}, {"1", "b.png"}}},
+		{Type: "color"},
+	}}},
+	{"model[0,0;1,1;m;m.b3d;tex.png]", &Unknown{Name: "model", Params: []string{"0,0", "1,1", "m", "m.b3d", "tex.png"}}},
+}
+
+func TestElements(t *testing.T) {
+	tested := make(map[string]bool)
+	for _, test := range elementTests {
+		f, err := Parse(test.source)
+		if err != nil {
+			t.Errorf("%s: %v", test.source, err)
+			continue
+		}
+		if len(f.Elements) != 1 || !reflect.DeepEqual(f.Elements[0], test.want) {
+			t.Errorf("%s: parsed %#v, want %#v", test.source, f.Elements, test.want)
+			continue
+		}
+		if got := f.String(); got != test.source {
+			t.Errorf("%s: written back as %s", test.source, got)
+		}
+		tested[f.Elements[0].Type()] = true
+	}
+	for name := range parsers {
+		if !tested[name] {
+			t.Errorf("element %s not tested", name)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	// Whitespace, parameters added by newer versions and defaults written out are dropped
+	for _, test := range []struct {
+		source, want string
+	}{
+		{" size[ 8 , 9 ]\n\tlabel[0,0;x;extra] ", "size[8,9]label[0,0;x]"},
+		{"formspec_version[1]size[8,9,false]", "size[8,9]"},
+		{"formspec_version[6]image_button[0,0;1,1;a.png;b;c;false;true;]", "formspec_version[6]image_button[0,0;1,1;a.png;b;c]"},
+		{"listring[]bgcolor[#000;;]checkbox[1,1;c;C;false]", "listring[]bgcolor[#000]checkbox[1,1;c;C]"},
+		{"tabheader[0,0;0,0.5;t;a;1;false;true]", "tabheader[0,0;0.5;t;a;1]"},
+		{"size[1e1,2]", "size[10,2]"},
+	} {
+		f, err := Parse(test.source)
+		if err != nil {
+			t.Errorf("%q: %v", test.source, err)
+			continue
+		}
+		got := f.String()
+		if got != test.want {
+			t.Errorf("%q: written as %q, want %q", test.source, got, test.want)
+		}
+		// What was written reads back the same
+		if again, err := Parse(got); err != nil || again.String() != got {
+			t.Errorf("%q: read back as %v, %v", got, again, err)
+		}
+	}
+}
+
+func TestVersions(t *testing.T) {
+	for version := 1; version <= MaxVersion; version++ {
+		for _, test := range []struct {
+			elements string
+			real     bool
+		}{
+			{"size[8,9]", version >= realCoordinatesVersion},
+			{"size[8,9]real_coordinates[true]", true},
+			{"size[8,9]real_coordinates[false]", false},
+		} {
+			source := "formspec_version[" + string(rune('0'+version)) + "]" + test.elements
+			f, err := Parse(source)
+			if err != nil {
+				t.Fatalf("%s: %v", source, err)
+			}
+			if f.Version != version {
+				t.Errorf("%s: version %d", source, f.Version)
+			}
+			if f.RealCoordinates() != test.real {
+				t.Errorf("%s: real coordinates %v, want %v", source, f.RealCoordinates(), test.real)
+			}
+			want := test.elements
+			if version > 1 {
+				want = source
+			}
+			if got := f.String(); got != want {
+				t.Errorf("%s: written back as %s", source, got)
+			}
+		}
+	}
+
+	if f, err := Parse("size[8,9]"); err != nil || f.Version != 1 || f.RealCoordinates() {
+		t.Errorf("formspec without version: %+v, %v", f, err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	for _, test := range []struct {
+		source string
+		index  int
+		name   string
+	}{
+		{"formspec_version[0]", 1, "formspec_version"},
+		{"formspec_version[8]", 1, "formspec_version"},
+		{"formspec_version[x]", 1, "formspec_version"},
+		{"size[8,9]formspec_version[2]", 2, "formspec_version"},
+		{"size8,9]", 1, "size8,9"},
+		{"size[8]", 1, "size"},
+		{"size[8,9]\n\nbox[x;1,1;#f00]", 2, "box"},
+		{"size[8,9]label[0,0;x", 2, "label"},
+		{"list[current_player;main;0,0]", 1, "list"},
+		{"image[0,0;1,1;a.png;1,2,3,4,5]", 1, "image"},
+		{"container_end[]", 1, "container_end"},
+
+		// Containers report the element that opened them, counting the elements inside
+		{"container[0,0]label[0,0;a]container[1,1]box[0,0;1,1;#f00]container_end[]", 1, "container"},
+		{"container[0,0]container[1,1]label[0,0;x]container_end[]", 1, "container"},
+		{"container[0,0]container[1,1]label[0,0;x]", 2, "container"},
+		{"container[0,0]scroll_container[0,0;1,1;s;vertical]label[0,0;x]", 2, "scroll_container"},
+		{"container[0,0]scroll_container[0,0;1,1;s;vertical]container_end[]", 3, "container_end"},
+		{"container[0,0]container[1,1]button[0,0;1;b;c]container_end[]container_end[]", 3, "button"},
+		{"scroll_container[0,0;1,1;s;vertical]scroll_container_end[]scroll_container_end[]", 3, "scroll_container_end"},
+	} {
+		_, err := Parse(test.source)
+		var ferr *Error
+		if !errors.As(err, &ferr) {
+			t.Errorf("%q: got %v, want an *Error", test.source, err)
+			continue
+		}
+		if ferr.Index != test.index || ferr.Type != test.name {
+			t.Errorf("%q: error at element %d (%s), want %d (%s): %v", test.source, ferr.Index, ferr.Type, test.index, test.name, err)
+		}
+	}
+}
+
+func TestNestedContainers(t *testing.T) {
+	source := "size[8,9]container[1,1]label[0,0;a]scroll_container[0,0;2,2;sb;vertical;0.1]" +
+		"button[0,0;1,1;b;B]container[0.5,0.5]box[0,0;1,1;#000]container_end[]scroll_container_end[]" +
+		"container_end[]label[0,0;b]"
+	f, err := Parse(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Elements) != 3 {
+		t.Fatalf("%d top level elements, want 3", len(f.Elements))
+	}
+	outer, ok := f.Elements[1].(*Container)
+	if !ok || len(outer.Elements) != 2 {
+		t.Fatalf("outer container %#v", f.Elements[1])
+	}
+	scroll, ok := outer.Elements[1].(*ScrollContainer)
+	if !ok || len(scroll.Elements) != 2 {
+		t.Fatalf("scroll container %#v", outer.Elements[1])
+	}
+	inner, ok := scroll.Elements[1].(*Container)
+	if !ok || !reflect.DeepEqual(inner.Elements, []Element{&Box{Size: [2]float32{1, 1}, Color: "#000"}}) {
+		t.Fatalf("inner container %#v", scroll.Elements[1])
+	}
+	if got := f.String(); got != source {
+		t.Fatalf("written back as %s", got)
+	}
+}
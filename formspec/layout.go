@@ -0,0 +1,245 @@
+package formspec
+
+// Pixels of an inventory slot in a formspec of fixed size: 0.5555 inch at 96 DPI
+const FixedSlotSize = 0.5555 * 96
+
+// Pixels of a formspec without size[], which only holds fields put below each other
+const (
+	simpleWidth  = 580
+	simpleHeight = 300
+)
+
+// Defaults of position[], anchor[] and padding[]
+var (
+	defaultPosition = [2]float32{0.5, 0.5}
+	defaultAnchor   = [2]float32{0.5, 0.5}
+	defaultPadding  = [2]float32{0.05, 0.05}
+)
+
+// Rect is an area in pixels. Areas of elements have their origin in the top left corner of the formspec.
+type Rect struct {
+	X, Y, Width, Height float32
+}
+
+// Layout converts the positions and sizes of elements to pixels the way Minetest does. In real coordinates a
+// unit is an inventory slot. In the older coordinates a unit adds the spacing between slots, which elements
+// count differently, so each kind of element has its own method.
+type Layout struct {
+	Real         bool       // Real coordinates, as real_coordinates[] switches them
+	ImgSize      float32    // Pixels of an inventory slot, like imgsize
+	Spacing      [2]float32 // Pixels from a slot to the next one in older coordinates
+	Padding      [2]float32 // Pixels from the edge of the formspec to the first slot in older coordinates
+	ButtonHeight float32    // Half the height of buttons and fields, like m_btn_height
+	Offset       [2]float32 // Position of the container the elements are in
+	Bounds       Rect       // Where the formspec is on the screen
+}
+
+// NewLayout places a formspec on a screen of the given size. The inventory slots are as big as the formspec
+// fits into the screen, up to a fifteenth of the screen times scale, like gui_scaling.
+func NewLayout(f *Formspec, width, height, scale float32) Layout {
+	pos, anchor, padding := defaultPosition, defaultAnchor, defaultPadding
+	var size *Size
+	for _, e := range f.Elements {
+		switch e := e.(type) {
+		case *Size:
+			size = e
+		case *Position:
+			pos = e.Pos
+		case *Anchor:
+			anchor = e.Pos
+		case *Padding:
+			padding = e.Padding
+		}
+	}
+
+	l := Layout{Real: f.RealCoordinates()}
+	padded := [2]float32{width * (1 - 2*padding[0]), height * (1 - 2*padding[1])}
+	switch {
+	case size != nil && size.FixedSize:
+		l.ImgSize = FixedSlotSize * scale
+	default:
+		l.ImgSize = min(width, height) / 15 * scale
+		if size != nil {
+			var fit [2]float32
+			if l.Real {
+				fit = [2]float32{padded[0] / size.Size[0], padded[1] / size.Size[1]}
+			} else {
+				fit = [2]float32{padded[0] / (5.0 / 4 * (0.5 + size.Size[0])), padded[1] / (15.0 / 13 * (0.85 + size.Size[1]))}
+			}
+			for _, f := range fit {
+				if f > 0 {
+					l.ImgSize = min(l.ImgSize, f)
+				}
+			}
+		}
+	}
+	l.Spacing = [2]float32{l.ImgSize * 5 / 4, l.ImgSize * 15 / 13}
+	l.Padding = [2]float32{l.ImgSize * 3 / 8, l.ImgSize * 3 / 8}
+	l.ButtonHeight = l.ImgSize * 15 / 13 * 0.35
+
+	switch {
+	case size == nil:
+		l.Bounds.Width, l.Bounds.Height = simpleWidth, simpleHeight
+	case l.Real:
+		l.Bounds.Width, l.Bounds.Height = size.Size[0]*l.ImgSize, size.Size[1]*l.ImgSize
+	default:
+		l.Bounds.Width = l.Padding[0]*2 + l.Spacing[0]*(size.Size[0]-1) + l.ImgSize
+		l.Bounds.Height = l.Padding[1]*2 + l.Spacing[1]*(size.Size[1]-1) + l.ImgSize + l.ButtonHeight*2/3
+	}
+	l.Bounds.X = width*pos[0] - anchor[0]*l.Bounds.Width
+	l.Bounds.Y = height*pos[1] - anchor[1]*l.Bounds.Height
+	return l
+}
+
+// Within returns the layout of the elements of a container at pos
+func (l Layout) Within(pos [2]float32) Layout {
+	l.Offset = [2]float32{l.Offset[0] + pos[0], l.Offset[1] + pos[1]}
+	return l
+}
+
+// ScrollContent returns the layout of the elements of a scroll container, whose areas start at the container
+func (l Layout) ScrollContent() Layout {
+	l.Offset = [2]float32{}
+	return l
+}
+
+// WithReal returns the layout of the elements after real_coordinates[]
+func (l Layout) WithReal(real bool) Layout {
+	l.Real = real
+	return l
+}
+
+// Point converts a position to pixels
+func (l Layout) Point(pos [2]float32) [2]float32 {
+	x, y := pos[0]+l.Offset[0], pos[1]+l.Offset[1]
+	if l.Real {
+		return [2]float32{x * l.ImgSize, y * l.ImgSize}
+	}
+	return [2]float32{l.Padding[0] + x*l.Spacing[0], l.Padding[1] + y*l.Spacing[1]}
+}
+
+// Image returns the area of an image or item image, which counts slots without spacing
+func (l Layout) Image(pos, size [2]float32) Rect {
+	p := l.Point(pos)
+	return Rect{p[0], p[1], size[0] * l.ImgSize, size[1] * l.ImgSize}
+}
+
+// Area returns the area of a box, text list, table, scrollbar, scroll container or tooltip, which in older
+// coordinates counts the spacing of every slot
+func (l Layout) Area(pos, size [2]float32) Rect {
+	if l.Real {
+		return l.Image(pos, size)
+	}
+	p := l.Point(pos)
+	return Rect{p[0], p[1], size[0] * l.Spacing[0], size[1] * l.Spacing[1]}
+}
+
+// Widget returns the area of an image button or text area, which in older coordinates leaves out the spacing
+// after the last slot
+func (l Layout) Widget(pos, size [2]float32) Rect {
+	if l.Real {
+		return l.Image(pos, size)
+	}
+	p := l.Point(pos)
+	return Rect{p[0], p[1], l.legacyWidth(size[0]), size[1]*l.Spacing[1] - (l.Spacing[1] - l.ImgSize)}
+}
+
+// Button returns the area of a button or field, which in older coordinates is of a fixed height centered on
+// the area it was given
+func (l Layout) Button(pos, size [2]float32) Rect {
+	if l.Real {
+		return l.Image(pos, size)
+	}
+	p := l.Point(pos)
+	center := p[1] + size[1]*l.ImgSize/2
+	return Rect{p[0], center - l.ButtonHeight, l.legacyWidth(size[0]), l.ButtonHeight * 2}
+}
+
+// Dropdown returns the area of a dropdown, which has the height of a button unless real coordinates give one
+func (l Layout) Dropdown(pos, size [2]float32) Rect {
+	p := l.Point(pos)
+	if l.Real {
+		r := Rect{p[0], p[1], size[0] * l.ImgSize, size[1] * l.ImgSize}
+		if size[1] == 0 {
+			r.Height = l.ButtonHeight * 2
+		}
+		return r
+	}
+	return Rect{p[0], p[1], l.legacyWidth(size[0]), l.ButtonHeight * 2}
+}
+
+// TabHeader returns the area of a tab header, which sits above its position. The width is 0 unless it is
+// given, for tabs as wide as their captions.
+func (l Layout) TabHeader(pos, size [2]float32) Rect {
+	p := l.Point(pos)
+	r := Rect{p[0], 0, size[0] * l.ImgSize, l.ButtonHeight * 2}
+	if l.Real && size[1] != 0 {
+		r.Height = size[1] * l.ImgSize
+	}
+	r.Y = p[1] - r.Height
+	return r
+}
+
+// Background returns the area of a background. With auto clip it covers the formspec, grown by its position
+// in pixels.
+func (l Layout) Background(b *Background) Rect {
+	if b.AutoClip {
+		return Rect{-b.Pos[0], -b.Pos[1], l.Bounds.Width + 2*b.Pos[0], l.Bounds.Height + 2*b.Pos[1]}
+	}
+	if l.Real {
+		return l.Image(b.Pos, b.Size)
+	}
+	p := l.Point(b.Pos)
+	return Rect{
+		p[0] - (l.Spacing[0]-l.ImgSize)/2,
+		p[1] - (l.Spacing[1]-l.ImgSize)/2,
+		b.Size[0] * l.Spacing[0],
+		b.Size[1] * l.Spacing[1],
+	}
+}
+
+// Label returns where a line of a label starts: the left end of the text and its vertical center. Lines are
+// half a slot apart in real coordinates and two fifths of the spacing in older ones.
+func (l Layout) Label(pos [2]float32, line int) [2]float32 {
+	if l.Real {
+		p := l.Point(pos)
+		return [2]float32{p[0], p[1] + float32(line)*l.ImgSize/2}
+	}
+	p := l.Point([2]float32{pos[0], pos[1] + 7.0/30})
+	return [2]float32{p[0], p[1] + float32(line)*l.Spacing[1]*2/5}
+}
+
+// Checkbox returns the left end and the vertical center of a checkbox
+func (l Layout) Checkbox(pos [2]float32) [2]float32 {
+	p := l.Point(pos)
+	if l.Real {
+		return p
+	}
+	return [2]float32{p[0], p[1] + l.ImgSize/2}
+}
+
+// Slot returns the area of a slot of a list, counting columns and rows from its top left slot. In real
+// coordinates slots are a quarter of a slot apart.
+func (l Layout) Slot(pos [2]float32, column, row int) Rect {
+	p := l.Point(pos)
+	step := l.Spacing
+	if l.Real {
+		step = [2]float32{l.ImgSize * 5 / 4, l.ImgSize * 5 / 4}
+	}
+	return Rect{p[0] + float32(column)*step[0], p[1] + float32(row)*step[1], l.ImgSize, l.ImgSize}
+}
+
+// SimpleField returns the area of a field without position, of which there may be several below each other
+func (l Layout) SimpleField(index int) Rect {
+	return Rect{l.Bounds.Width/2 - 150, float32(index+2) * 60, 300, l.ButtonHeight * 2}
+}
+
+// Proceed returns the area of the button submitting the fields without position
+func (l Layout) Proceed(fields int) Rect {
+	return Rect{l.Bounds.Width/2 - 70, float32(fields+2) * 60, 140, l.ButtonHeight * 2}
+}
+
+// legacyWidth returns the width of a button or field in older coordinates
+func (l Layout) legacyWidth(width float32) float32 {
+	return width*l.Spacing[0] - (l.Spacing[0] - l.ImgSize)
+}
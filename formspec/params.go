@@ -0,0 +1,249 @@
+package formspec
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Property is a key=value option, as of styles and tables
+type Property struct {
+	Key   string
+	Value string
+}
+
+// params reads the parameters of an element in order. Like network.Reader it keeps the first error, so that
+// parsers read every parameter and check once at the end.
+type params struct {
+	name string   // Name of the element, for parsers of several elements
+	raw  []string // Parameters separated by semicolons, still escaped
+	next int
+	err  error
+}
+
+// more reports whether there are parameters left to read
+func (p *params) more() bool {
+	return p.err == nil && p.next < len(p.raw)
+}
+
+// left returns the number of parameters not read yet
+func (p *params) left() int {
+	return len(p.raw) - p.next
+}
+
+// take returns the next parameter as written, failing if there is none
+func (p *params) take() string {
+	if p.err != nil {
+		return ""
+	}
+	if p.next >= len(p.raw) {
+		p.err = fmt.Errorf("missing parameter %d", p.next+1)
+		return ""
+	}
+	p.next++
+	return p.raw[p.next-1]
+}
+
+// fail records an error in the parameter read last
+func (p *params) fail(format string, args ...any) {
+	if p.err == nil {
+		p.err = fmt.Errorf("parameter %d: %s", p.next, fmt.Sprintf(format, args...))
+	}
+}
+
+// text reads a parameter as text, which may contain escaped commas
+func (p *params) text() string {
+	return Unescape(p.take())
+}
+
+// list reads a parameter of comma separated values
+func (p *params) list() []string {
+	parts := split(p.take(), ',')
+	for i, part := range parts {
+		parts[i] = Unescape(part)
+	}
+	return parts
+}
+
+// floats reads a parameter of between least and most comma separated numbers
+func (p *params) floats(least, most int) []float32 {
+	parts := p.list()
+	if p.err != nil {
+		return nil
+	}
+	if len(parts) < least || len(parts) > most {
+		if least == most {
+			p.fail("expected %d numbers, got %q", least, strings.Join(parts, ","))
+		} else {
+			p.fail("expected %d to %d numbers, got %q", least, most, strings.Join(parts, ","))
+		}
+		return nil
+	}
+	values := make([]float32, len(parts))
+	for i, part := range parts {
+		values[i] = p.number(part)
+	}
+	if p.err != nil {
+		return nil
+	}
+	return values
+}
+
+// number parses one of the numbers of the parameter read last
+func (p *params) number(s string) float32 {
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 32)
+	if err != nil {
+		p.fail("%q is not a number", s)
+	}
+	return float32(v)
+}
+
+// vec reads a parameter of two numbers, such as a position or size
+func (p *params) vec() [2]float32 {
+	v := p.floats(2, 2)
+	if v == nil {
+		return [2]float32{}
+	}
+	return [2]float32{v[0], v[1]}
+}
+
+// rect reads a position and a size from two parameters
+func (p *params) rect() (pos, size [2]float32) {
+	return p.vec(), p.vec()
+}
+
+func (p *params) float() float32 {
+	if v := p.floats(1, 1); v != nil {
+		return v[0]
+	}
+	return 0
+}
+
+// int reads a parameter as an integer, which is 0 if the parameter is empty
+func (p *params) int() int {
+	s := strings.TrimSpace(p.take())
+	if p.err != nil || s == "" {
+		return 0
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		p.fail("%q is not an integer", s)
+	}
+	return v
+}
+
+// bool reads a parameter as true unless it is empty, false or a zero, like Minetest's is_yes
+func (p *params) bool() bool {
+	return isYes(p.text())
+}
+
+// props reads every parameter left as key=value properties
+func (p *params) props() []Property {
+	var props []Property
+	for p.more() {
+		raw := p.take()
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+		if prop, ok := parseProperty(raw); ok {
+			props = append(props, prop)
+		} else {
+			p.fail("expected key=value")
+		}
+	}
+	return props
+}
+
+// parseProperty splits an escaped key=value pair
+func parseProperty(s string) (Property, bool) {
+	key, value, found := strings.Cut(s, "=")
+	return Property{Key: strings.TrimSpace(Unescape(key)), Value: Unescape(value)}, found
+}
+
+func isYes(s string) bool {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "y", "yes", "true":
+		return true
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	return err == nil && v != 0
+}
+
+// writer writes the parameters of an element, separating them with semicolons
+type writer struct {
+	b      *strings.Builder
+	params int
+}
+
+// param starts the next parameter
+func (w *writer) param() {
+	if w.params > 0 {
+		w.b.WriteByte(';')
+	}
+	w.params++
+}
+
+func (w *writer) text(s string) {
+	w.param()
+	w.b.WriteString(Escape(s))
+}
+
+func (w *writer) list(values []string) {
+	w.param()
+	for i, v := range values {
+		if i > 0 {
+			w.b.WriteByte(',')
+		}
+		w.b.WriteString(Escape(v))
+	}
+}
+
+func (w *writer) floats(values ...float32) {
+	w.param()
+	for i, v := range values {
+		if i > 0 {
+			w.b.WriteByte(',')
+		}
+		w.b.WriteString(formatFloat(v))
+	}
+}
+
+func (w *writer) vec(v [2]float32) {
+	w.floats(v[0], v[1])
+}
+
+func (w *writer) rect(pos, size [2]float32) {
+	w.vec(pos)
+	w.vec(size)
+}
+
+func (w *writer) int(v int) {
+	w.param()
+	w.b.WriteString(strconv.Itoa(v))
+}
+
+func (w *writer) bool(v bool) {
+	w.param()
+	w.b.WriteString(strconv.FormatBool(v))
+}
+
+// raw writes a parameter that is escaped already
+func (w *writer) raw(s string) {
+	w.param()
+	w.b.WriteString(s)
+}
+
+// props writes each property as a parameter
+func (w *writer) props(props []Property) {
+	for _, prop := range props {
+		w.param()
+		w.b.WriteString(Escape(prop.Key))
+		w.b.WriteByte('=')
+		w.b.WriteString(Escape(prop.Value))
+	}
+}
+
+// formatFloat writes a number without an exponent, which older clients cannot read
+func formatFloat(v float32) string {
+	return strconv.FormatFloat(float64(v), 'f', -1, 32)
+}
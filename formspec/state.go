@@ -0,0 +1,86 @@
+package formspec
+
+import "sync"
+
+// DefaultInventory is the inventory formspec of players the server gives none, like Minetest's: the main list
+// below a crafting grid and its result
+const DefaultInventory = "size[8,7.5]" +
+	"list[current_player;main;0,3.5;8,4;]" +
+	"list[current_player;craft;3,0;3,3;]" +
+	"listring[]" +
+	"list[current_player;craftpreview;7,1;1,1;]"
+
+// Request is a formspec the server asked to show, or to close if the formspec is empty
+type Request struct {
+	FormName string
+	Formspec string
+}
+
+// State is the thread-safe state of the formspecs controlled by the server: the one it asked to show last, the
+// formspec of the player's inventory and the prepend put in front of every formspec
+type State struct {
+	mu        sync.Mutex
+	request   Request
+	requests  uint64 // Number of requests so far, so that a renderer notices each one
+	inventory string
+	prepend   string
+	version   uint64
+}
+
+// NewState creates a state without formspecs
+func NewState() *State {
+	return &State{}
+}
+
+// Show asks for a formspec to be shown, or closed
+func (s *State) Show(r Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.request = r
+	s.requests++
+}
+
+// Request returns the last formspec the server asked for and the number of requests so far, which is 0 before
+// the first
+func (s *State) Request() (Request, uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.request, s.requests
+}
+
+// SetInventory changes the formspec of the player's inventory
+func (s *State) SetInventory(formspec string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inventory = formspec
+	s.version++
+}
+
+// Inventory returns the formspec of the player's inventory, which is empty until the server sends one
+func (s *State) Inventory() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.inventory
+}
+
+// SetPrepend changes the elements put in front of every formspec
+func (s *State) SetPrepend(prepend string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prepend = prepend
+	s.version++
+}
+
+// Prepend returns the elements put in front of every formspec
+func (s *State) Prepend() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.prepend
+}
+
+// Version changes whenever the inventory formspec or the prepend changes
+func (s *State) Version() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.version
+}
@@ -0,0 +1,441 @@
+package formspec
+
+import "strings"
+
+// Button is button[] or button_exit[], a button sending its name when clicked
+type Button struct {
+	Pos, Size [2]float32
+	Name      string
+	Label     string
+	Exit      bool // Close the formspec when clicked, which makes it button_exit[]
+}
+
+func parseButton(p *params) Element {
+	b := &Button{Exit: p.name == "button_exit"}
+	b.Pos, b.Size = p.rect()
+	b.Name, b.Label = p.text(), p.text()
+	return b
+}
+
+func (e *Button) Type() string {
+	if e.Exit {
+		return "button_exit"
+	}
+	return "button"
+}
+
+func (e *Button) write(w *writer) {
+	w.rect(e.Pos, e.Size)
+	w.text(e.Name)
+	w.text(e.Label)
+}
+
+// ImageButton is image_button[] or image_button_exit[], a button showing a texture
+type ImageButton struct {
+	Pos, Size      [2]float32
+	Texture        string
+	Name           string
+	Label          string
+	NoClip         bool // Drawn outside the formspec too
+	DrawBorder     bool // True unless given
+	PressedTexture string
+	Exit           bool // Close the formspec when clicked, which makes it image_button_exit[]
+}
+
+func parseImageButton(p *params) Element {
+	b := &ImageButton{DrawBorder: true, Exit: p.name == "image_button_exit"}
+	b.Pos, b.Size = p.rect()
+	b.Texture, b.Name, b.Label = p.text(), p.text(), p.text()
+	if p.more() {
+		b.NoClip, b.DrawBorder, b.PressedTexture = p.bool(), p.bool(), p.text()
+	}
+	return b
+}
+
+func (e *ImageButton) Type() string {
+	if e.Exit {
+		return "image_button_exit"
+	}
+	return "image_button"
+}
+
+func (e *ImageButton) write(w *writer) {
+	w.rect(e.Pos, e.Size)
+	w.text(e.Texture)
+	w.text(e.Name)
+	w.text(e.Label)
+	if e.NoClip || !e.DrawBorder || e.PressedTexture != "" {
+		w.bool(e.NoClip)
+		w.bool(e.DrawBorder)
+		w.text(e.PressedTexture)
+	}
+}
+
+// ItemImageButton is item_image_button[], a button showing the inventory image of an item
+type ItemImageButton struct {
+	Pos, Size [2]float32
+	Item      string
+	Name      string
+	Label     string
+}
+
+func parseItemImageButton(p *params) Element {
+	b := &ItemImageButton{}
+	b.Pos, b.Size = p.rect()
+	b.Item, b.Name, b.Label = p.text(), p.text(), p.text()
+	return b
+}
+
+func (e *ItemImageButton) Type() string { return "item_image_button" }
+
+func (e *ItemImageButton) write(w *writer) {
+	w.rect(e.Pos, e.Size)
+	w.text(e.Item)
+	w.text(e.Name)
+	w.text(e.Label)
+}
+
+// Field is field[] or pwdfield[], a single line text input
+type Field struct {
+	Pos, Size  [2]float32
+	Positioned bool // Pos and Size were given, fields without are put below each other in the middle
+	Name       string
+	Label      string
+	Default    string
+	Password   bool // Hide the text, which makes it pwdfield[] without a default
+}
+
+func parseField(p *params) Element {
+	f := &Field{Password: p.name == "pwdfield"}
+	if f.Password || p.left() != 3 {
+		f.Pos, f.Size = p.rect()
+		f.Positioned = true
+	}
+	f.Name, f.Label = p.text(), p.text()
+	if !f.Password {
+		f.Default = p.text()
+	}
+	return f
+}
+
+func (e *Field) Type() string {
+	if e.Password {
+		return "pwdfield"
+	}
+	return "field"
+}
+
+func (e *Field) write(w *writer) {
+	if e.Positioned || e.Password {
+		w.rect(e.Pos, e.Size)
+	}
+	w.text(e.Name)
+	w.text(e.Label)
+	if !e.Password {
+		w.text(e.Default)
+	}
+}
+
+// FieldCloseOnEnter is field_close_on_enter[], whether pressing enter in a field closes the formspec
+type FieldCloseOnEnter struct {
+	Name  string
+	Close bool
+}
+
+func parseFieldCloseOnEnter(p *params) Element {
+	return &FieldCloseOnEnter{Name: p.text(), Close: p.bool()}
+}
+
+func (e *FieldCloseOnEnter) Type() string { return "field_close_on_enter" }
+
+func (e *FieldCloseOnEnter) write(w *writer) {
+	w.text(e.Name)
+	w.bool(e.Close)
+}
+
+// TextArea is textarea[], a text input of several lines, or text that cannot be edited if it has no name
+type TextArea struct {
+	Pos, Size [2]float32
+	Name      string
+	Label     string
+	Default   string
+}
+
+func parseTextArea(p *params) Element {
+	t := &TextArea{}
+	t.Pos, t.Size = p.rect()
+	t.Name, t.Label, t.Default = p.text(), p.text(), p.text()
+	return t
+}
+
+func (e *TextArea) Type() string { return "textarea" }
+
+func (e *TextArea) write(w *writer) {
+	w.rect(e.Pos, e.Size)
+	w.text(e.Name)
+	w.text(e.Label)
+	w.text(e.Default)
+}
+
+// TextList is textlist[], a list of lines to pick one of
+type TextList struct {
+	Pos, Size   [2]float32
+	Name        string
+	Items       []string
+	Selected    int // Counting from 1, 0 for none
+	Transparent bool
+}
+
+func parseTextList(p *params) Element {
+	t := &TextList{}
+	t.Pos, t.Size = p.rect()
+	t.Name, t.Items = p.text(), p.list()
+	if p.more() {
+		t.Selected = p.int()
+	}
+	if p.more() {
+		t.Transparent = p.bool()
+	}
+	return t
+}
+
+func (e *TextList) Type() string { return "textlist" }
+
+func (e *TextList) write(w *writer) {
+	w.rect(e.Pos, e.Size)
+	w.text(e.Name)
+	w.list(e.Items)
+	if e.Selected != 0 || e.Transparent {
+		w.int(e.Selected)
+	}
+	if e.Transparent {
+		w.bool(true)
+	}
+}
+
+// TabHeader is tabheader[], a row of tabs above the formspec
+type TabHeader struct {
+	Pos         [2]float32
+	Size        [2]float32 // Zero if not given, only the height if the width is zero
+	Name        string
+	Captions    []string
+	Current     int // Counting from 1
+	Transparent bool
+	DrawBorder  bool // True unless given
+}
+
+func parseTabHeader(p *params) Element {
+	t := &TabHeader{DrawBorder: true}
+	hasSize := len(p.raw) == 5 || len(p.raw) >= 7
+	t.Pos = p.vec()
+	if hasSize {
+		if size := p.floats(1, 2); len(size) == 1 {
+			t.Size = [2]float32{0, size[0]}
+		} else if len(size) == 2 {
+			t.Size = [2]float32{size[0], size[1]}
+		}
+	}
+	t.Name, t.Captions, t.Current = p.text(), p.list(), p.int()
+	if p.more() {
+		t.Transparent, t.DrawBorder = p.bool(), p.bool()
+	}
+	return t
+}
+
+func (e *TabHeader) Type() string { return "tabheader" }
+
+func (e *TabHeader) write(w *writer) {
+	w.vec(e.Pos)
+	switch {
+	case e.Size[0] != 0:
+		w.vec(e.Size)
+	case e.Size[1] != 0:
+		w.floats(e.Size[1])
+	}
+	w.text(e.Name)
+	w.list(e.Captions)
+	w.int(e.Current)
+	if e.Transparent || !e.DrawBorder {
+		w.bool(e.Transparent)
+		w.bool(e.DrawBorder)
+	}
+}
+
+// Dropdown is dropdown[], a button opening a list of items to pick one of
+type Dropdown struct {
+	Pos        [2]float32
+	Size       [2]float32 // Only the width if the height is zero
+	Name       string
+	Items      []string
+	Selected   int  // Counting from 1
+	IndexEvent bool // Send the index of the item picked rather than the item, since version 4
+}
+
+func parseDropdown(p *params) Element {
+	d := &Dropdown{Pos: p.vec()}
+	if size := p.floats(1, 2); len(size) == 1 {
+		d.Size = [2]float32{size[0], 0}
+	} else if len(size) == 2 {
+		d.Size = [2]float32{size[0], size[1]}
+	}
+	d.Name, d.Items, d.Selected = p.text(), p.list(), p.int()
+	if p.more() {
+		d.IndexEvent = p.bool()
+	}
+	return d
+}
+
+func (e *Dropdown) Type() string { return "dropdown" }
+
+func (e *Dropdown) write(w *writer) {
+	w.vec(e.Pos)
+	if e.Size[1] != 0 {
+		w.vec(e.Size)
+	} else {
+		w.floats(e.Size[0])
+	}
+	w.text(e.Name)
+	w.list(e.Items)
+	w.int(e.Selected)
+	if e.IndexEvent {
+		w.bool(true)
+	}
+}
+
+// Checkbox is checkbox[], a box to tick with a label on its right
+type Checkbox struct {
+	Pos      [2]float32
+	Name     string
+	Label    string
+	Selected bool
+}
+
+func parseCheckbox(p *params) Element {
+	c := &Checkbox{Pos: p.vec(), Name: p.text(), Label: p.text()}
+	if p.more() {
+		c.Selected = p.bool()
+	}
+	return c
+}
+
+func (e *Checkbox) Type() string { return "checkbox" }
+
+func (e *Checkbox) write(w *writer) {
+	w.vec(e.Pos)
+	w.text(e.Name)
+	w.text(e.Label)
+	if e.Selected {
+		w.bool(true)
+	}
+}
+
+// Scrollbar is scrollbar[], a scrollbar of its own or of a scroll container with the same name
+type Scrollbar struct {
+	Pos, Size   [2]float32
+	Orientation string // "vertical" or "horizontal"
+	Name        string
+	Value       int
+}
+
+func parseScrollbar(p *params) Element {
+	s := &Scrollbar{}
+	s.Pos, s.Size = p.rect()
+	s.Orientation, s.Name, s.Value = p.text(), p.text(), p.int()
+	return s
+}
+
+func (e *Scrollbar) Type() string { return "scrollbar" }
+
+func (e *Scrollbar) write(w *writer) {
+	w.rect(e.Pos, e.Size)
+	w.text(e.Orientation)
+	w.text(e.Name)
+	w.int(e.Value)
+}
+
+// ScrollbarOptions is scrollbaroptions[], the range and steps of the scrollbars that follow it
+type ScrollbarOptions struct {
+	Options []Property // Such as min, max, smallstep, largestep, thumbsize and arrows
+}
+
+func parseScrollbarOptions(p *params) Element { return &ScrollbarOptions{Options: p.props()} }
+func (e *ScrollbarOptions) Type() string      { return "scrollbaroptions" }
+func (e *ScrollbarOptions) write(w *writer)   { w.props(e.Options) }
+
+// Table is table[], cells in rows and columns to pick a row of. The columns are set by the tablecolumns[]
+// before it, or else each cell is a row.
+type Table struct {
+	Pos, Size [2]float32
+	Name      string
+	Cells     []string // Row by row
+	Selected  int      // Row counting from 1, 0 for none
+}
+
+func parseTable(p *params) Element {
+	t := &Table{}
+	t.Pos, t.Size = p.rect()
+	t.Name, t.Cells = p.text(), p.list()
+	if p.more() {
+		t.Selected = p.int()
+	}
+	return t
+}
+
+func (e *Table) Type() string { return "table" }
+
+func (e *Table) write(w *writer) {
+	w.rect(e.Pos, e.Size)
+	w.text(e.Name)
+	w.list(e.Cells)
+	w.int(e.Selected)
+}
+
+// TableOptions is tableoptions[], the colors and behavior of the table that follows it
+type TableOptions struct {
+	Options []Property // Such as color, background, border, highlight and opendepth
+}
+
+func parseTableOptions(p *params) Element { return &TableOptions{Options: p.props()} }
+func (e *TableOptions) Type() string      { return "tableoptions" }
+func (e *TableOptions) write(w *writer)   { w.props(e.Options) }
+
+// TableColumns is tablecolumns[], the columns of the table that follows it
+type TableColumns struct {
+	Columns []TableColumn
+}
+
+// TableColumn is a column of a table
+type TableColumn struct {
+	Type    string     // text, image, color, indent or tree
+	Options []Property // Such as align, width and the images of an image column
+}
+
+func parseTableColumns(p *params) Element {
+	t := &TableColumns{}
+	for p.more() {
+		parts := p.list()
+		c := TableColumn{Type: strings.TrimSpace(parts[0])}
+		for _, option := range parts[1:] {
+			key, value, _ := strings.Cut(option, "=")
+			c.Options = append(c.Options, Property{Key: strings.TrimSpace(key), Value: value})
+		}
+		t.Columns = append(t.Columns, c)
+	}
+	return t
+}
+
+func (e *TableColumns) Type() string { return "tablecolumns" }
+
+func (e *TableColumns) write(w *writer) {
+	for _, c := range e.Columns {
+		values := []string{c.Type}
+		for _, option := range c.Options {
+			if option.Value == "" {
+				values = append(values, option.Key)
+			} else {
+				values = append(values, option.Key+"="+option.Value)
+			}
+		}
+		w.list(values)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	"bettermt/main/client"
+	"bettermt/main/meshbuilder"
+)
+
+const (
+	// How often the headless client applies received blocks and prints chat
+	headlessTickInterval = 100 * time.Millisecond
+	// How often the headless client prints its status
+	headlessStatusInterval = 10 * time.Second
+)
+
+// runHeadless keeps the world in sync and relays chat between the server and the terminal, without a window.
+// Lines read from input are sent as chat messages. It returns once the connection ends, duration elapsed
+// or the process is interrupted; a duration of 0 runs until disconnected.
+func runHeadless(cl *client.Client, world *meshbuilder.World, input io.Reader, duration time.Duration) error {
+	go func() {
+		scanner := bufio.NewScanner(input)
+		for scanner.Scan() {
+			if err := cl.SendChatMessage(scanner.Text()); err != nil && err != client.ErrEmptyMessage {
+				fmt.Printf("Failed to send chat message: %v\n", err)
+			}
+		}
+	}()
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	var deadline <-chan time.Time
+	if duration > 0 {
+		deadline = time.After(duration)
+	}
+	ticker := time.NewTicker(headlessTickInterval)
+	defer ticker.Stop()
+	status := time.NewTicker(headlessStatusInterval)
+	defer status.Stop()
+
+	var lastMessage uint64
+	printStatus := func() {
+		pos := cl.Player.Position()
+		fmt.Printf("Position: (%.1f, %.1f, %.1f), blocks loaded: %d\n", pos[0], pos[1], pos[2], len(world.Chunks))
+	}
+	printStatus()
+	for {
+		select {
+		case <-cl.Done():
+			return cl.Err()
+		case <-deadline:
+			return nil
+		case <-interrupt:
+			return nil
+		case <-status.C:
+			printStatus()
+		case <-ticker.C:
+			world.ApplyPending()
+			for _, msg := range cl.Chat.MessagesAfter(lastMessage) {
+				fmt.Printf("[chat] %s\n", msg.String())
+				lastMessage = msg.ID
+			}
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package hud
+
+import "fmt"
+
+// ElementType selects how an element is drawn and what its fields mean
+type ElementType uint8
+
+const (
+	TypeImage ElementType = iota
+	TypeText
+	TypeStatbar
+	TypeInventory
+	TypeWaypoint
+	TypeImageWaypoint
+	TypeCompass
+	TypeMinimap
+	TypeHotbar
+)
+
+func (t ElementType) String() string {
+	switch t {
+	case TypeImage:
+		return "image"
+	case TypeText:
+		return "text"
+	case TypeStatbar:
+		return "statbar"
+	case TypeInventory:
+		return "inventory"
+	case TypeWaypoint:
+		return "waypoint"
+	case TypeImageWaypoint:
+		return "image_waypoint"
+	case TypeCompass:
+		return "compass"
+	case TypeMinimap:
+		return "minimap"
+	case TypeHotbar:
+		return "hotbar"
+	}
+	return fmt.Sprintf("unknown(%d)", uint8(t))
+}
+
+// Stat is a field of an element changed by TOCLIENT_HUDCHANGE
+type Stat uint8
+
+const (
+	StatPos Stat = iota
+	StatName
+	StatScale
+	StatText
+	StatNumber
+	StatItem
+	StatDir
+	StatAlign
+	StatOffset
+	StatWorldPos
+	StatSize
+	StatZIndex
+	StatText2
+	StatStyle
+)
+
+func (s Stat) String() string {
+	switch s {
+	case StatPos:
+		return "position"
+	case StatName:
+		return "name"
+	case StatScale:
+		return "scale"
+	case StatText:
+		return "text"
+	case StatNumber:
+		return "number"
+	case StatItem:
+		return "item"
+	case StatDir:
+		return "direction"
+	case StatAlign:
+		return "alignment"
+	case StatOffset:
+		return "offset"
+	case StatWorldPos:
+		return "world_pos"
+	case StatSize:
+		return "size"
+	case StatZIndex:
+		return "z_index"
+	case StatText2:
+		return "text2"
+	case StatStyle:
+		return "style"
+	}
+	return fmt.Sprintf("unknown(%d)", uint8(s))
+}
+
+// Direction is the way statbars and inventories grow, and how compasses turn
+type Direction uint32
+
+const (
+	DirLeftRight Direction = iota
+	DirRightLeft
+	DirTopBottom
+	DirBottomTop
+)
+
+// Compass modes, stored in the direction of a compass
+const (
+	CompassRotate Direction = iota
+	CompassRotateReverse
+	CompassTranslate
+	CompassTranslateReverse
+)
+
+// Text styles, combined in the style of a text or waypoint
+const (
+	StyleBold   = 1 << 0
+	StyleItalic = 1 << 1
+	StyleMono   = 1 << 2
+)
+
+// Largest z-index, the range is symmetric around zero
+const maxZIndex = 1000
+
+// Element is one thing the server draws on the screen. Positions are fractions of the screen,
+// offsets and sizes are pixels before HUD scaling and world positions are in nodes.
+type Element struct {
+	ID       uint32
+	Type     ElementType
+	Pos      [2]float32
+	Name     string
+	Scale    [2]float32
+	Text     string
+	Number   uint32
+	Item     uint32
+	Dir      Direction
+	Align    [2]float32
+	Offset   [2]float32
+	WorldPos [3]float32
+	Size     [2]int32
+	ZIndex   int16
+	Text2    string
+	Style    uint32
+}
+
+// Change is the new value of one field of an element. Only the field matching the stat is used.
+type Change struct {
+	ID     uint32
+	Stat   Stat
+	V2F    [2]float32 // Position, scale, alignment and offset
+	V3F    [3]float32 // World position
+	V2S32  [2]int32   // Size
+	String string     // Name, text and text2
+	U32    uint32     // Number, item, direction, z-index and style
+}
+
+// Apply sets the field a change is for
+func (e *Element) Apply(c Change) error {
+	switch c.Stat {
+	case StatPos:
+		e.Pos = c.V2F
+	case StatName:
+		e.Name = c.String
+	case StatScale:
+		e.Scale = c.V2F
+	case StatText:
+		e.Text = c.String
+	case StatNumber:
+		e.Number = c.U32
+	case StatItem:
+		e.Item = c.U32
+	case StatDir:
+		e.Dir = Direction(c.U32)
+	case StatAlign:
+		e.Align = c.V2F
+	case StatOffset:
+		e.Offset = c.V2F
+	case StatWorldPos:
+		e.WorldPos = c.V3F
+	case StatSize:
+		e.Size = c.V2S32
+	case StatZIndex:
+		e.ZIndex = clampZIndex(int32(c.U32))
+	case StatText2:
+		e.Text2 = c.String
+	case StatStyle:
+		e.Style = c.U32
+	default:
+		return fmt.Errorf("unknown HUD stat %d", uint8(c.Stat))
+	}
+	return nil
+}
+
+// clampZIndex limits a z-index to the range Minetest allows
+func clampZIndex(z int32) int16 {
+	return int16(max(-maxZIndex, min(maxZIndex, z)))
+}
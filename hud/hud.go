@@ -0,0 +1,179 @@
+package hud
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Flags show and hide the built in parts of the HUD
+type Flags uint32
+
+const (
+	FlagHotbar Flags = 1 << iota
+	FlagHealthbar
+	FlagCrosshair
+	FlagWielditem
+	FlagBreathbar
+	FlagMinimap
+	FlagMinimapRadar
+	FlagBasicDebug
+	FlagChat
+)
+
+// Flags set before the server changes any
+const DefaultFlags = FlagHotbar | FlagHealthbar | FlagCrosshair | FlagWielditem | FlagBreathbar |
+	FlagMinimap | FlagMinimapRadar | FlagBasicDebug | FlagChat
+
+// Param is a hotbar setting sent in TOCLIENT_HUD_SET_PARAM
+type Param uint16
+
+const (
+	ParamHotbarItemCount Param = iota + 1
+	ParamHotbarImage
+	ParamHotbarSelectedImage
+)
+
+// Slots a hotbar can have
+const (
+	MinHotbarItemCount = 1
+	MaxHotbarItemCount = 32
+)
+
+// Hotbar holds the settings of the hotbar
+type Hotbar struct {
+	ItemCount     int
+	Image         string // Background, stretched over all slots
+	SelectedImage string // Drawn behind the wielded slot
+}
+
+// HUD is the thread-safe state of the elements and settings sent by the server
+type HUD struct {
+	mu       sync.Mutex
+	elements map[uint32]*Element
+	flags    Flags
+	hotbar   Hotbar
+	version  uint64
+}
+
+// New creates a HUD without elements and with the default flags
+func New(hotbarItemCount int) *HUD {
+	return &HUD{
+		elements: make(map[uint32]*Element),
+		flags:    DefaultFlags,
+		hotbar:   Hotbar{ItemCount: hotbarItemCount},
+	}
+}
+
+// Add shows a new element, replacing any element with the same ID
+func (h *HUD) Add(e Element) {
+	e.ZIndex = clampZIndex(int32(e.ZIndex))
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.elements[e.ID] = &e
+	h.version++
+}
+
+// Change updates one field of an element
+func (h *HUD) Change(c Change) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	e, exists := h.elements[c.ID]
+	if !exists {
+		return fmt.Errorf("unknown HUD element %d", c.ID)
+	}
+	if err := e.Apply(c); err != nil {
+		return err
+	}
+	h.version++
+	return nil
+}
+
+// Remove hides an element
+func (h *HUD) Remove(id uint32) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.elements, id)
+	h.version++
+}
+
+// Element returns a copy of the element with the given ID, and false if there is none
+func (h *HUD) Element(id uint32) (Element, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	e, exists := h.elements[id]
+	if !exists {
+		return Element{}, false
+	}
+	return *e, true
+}
+
+// Elements returns copies of the elements in drawing order: by z-index, then by ID, which servers hand out in order
+func (h *HUD) Elements() []Element {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	elements := make([]Element, 0, len(h.elements))
+	for _, e := range h.elements {
+		elements = append(elements, *e)
+	}
+	sort.Slice(elements, func(i, j int) bool {
+		if elements[i].ZIndex != elements[j].ZIndex {
+			return elements[i].ZIndex < elements[j].ZIndex
+		}
+		return elements[i].ID < elements[j].ID
+	})
+	return elements
+}
+
+// SetFlags changes the flags selected by mask to the ones in flags
+func (h *HUD) SetFlags(flags, mask Flags) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.flags = h.flags&^mask | flags&mask
+	h.version++
+}
+
+// Flags returns the flags in effect
+func (h *HUD) Flags() Flags {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.flags
+}
+
+// SetHotbarItemCount changes the number of hotbar slots, limited to the range Minetest allows
+func (h *HUD) SetHotbarItemCount(count int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.hotbar.ItemCount = max(MinHotbarItemCount, min(MaxHotbarItemCount, count))
+	h.version++
+}
+
+// SetHotbarImage changes the background of the hotbar
+func (h *HUD) SetHotbarImage(image string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.hotbar.Image = image
+	h.version++
+}
+
+// SetHotbarSelectedImage changes the image behind the wielded slot
+func (h *HUD) SetHotbarSelectedImage(image string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.hotbar.SelectedImage = image
+	h.version++
+}
+
+// Hotbar returns the hotbar settings
+func (h *HUD) Hotbar() Hotbar {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.hotbar
+}
+
+// Version changes every time the HUD changes, so readers can tell when to redraw
+func (h *HUD) Version() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.version
+}
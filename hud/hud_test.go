@@ -0,0 +1,216 @@
+package hud
+
+import (
+	"slices"
+	"testing"
+)
+
+// ids returns the IDs of elements in their order
+func ids(elements []Element) []uint32 {
+	var ids []uint32
+	for _, e := range elements {
+		ids = append(ids, e.ID)
+	}
+	return ids
+}
+
+func TestAddChangeRemove(t *testing.T) {
+	h := New(8)
+	h.Add(Element{ID: 1, Type: TypeText, Text: "hello"})
+	h.Add(Element{ID: 2, Type: TypeImage, Text: "a.png"})
+
+	for _, test := range []struct {
+		change Change
+		check  func(e Element) bool
+	}{
+		{Change{ID: 1, Stat: StatPos, V2F: [2]float32{0.5, 0.25}}, func(e Element) bool { return e.Pos == [2]float32{0.5, 0.25} }},
+		{Change{ID: 1, Stat: StatName, String: "greeting"}, func(e Element) bool { return e.Name == "greeting" }},
+		{Change{ID: 1, Stat: StatScale, V2F: [2]float32{2, 3}}, func(e Element) bool { return e.Scale == [2]float32{2, 3} }},
+		{Change{ID: 1, Stat: StatText, String: "bye"}, func(e Element) bool { return e.Text == "bye" }},
+		{Change{ID: 1, Stat: StatNumber, U32: 0xFF0000}, func(e Element) bool { return e.Number == 0xFF0000 }},
+		{Change{ID: 1, Stat: StatItem, U32: 4}, func(e Element) bool { return e.Item == 4 }},
+		{Change{ID: 1, Stat: StatDir, U32: 2}, func(e Element) bool { return e.Dir == DirTopBottom }},
+		{Change{ID: 1, Stat: StatAlign, V2F: [2]float32{-1, 1}}, func(e Element) bool { return e.Align == [2]float32{-1, 1} }},
+		{Change{ID: 1, Stat: StatOffset, V2F: [2]float32{10, -20}}, func(e Element) bool { return e.Offset == [2]float32{10, -20} }},
+		{Change{ID: 1, Stat: StatWorldPos, V3F: [3]float32{1, 2, 3}}, func(e Element) bool { return e.WorldPos == [3]float32{1, 2, 3} }},
+		{Change{ID: 1, Stat: StatSize, V2S32: [2]int32{-50, 32}}, func(e Element) bool { return e.Size == [2]int32{-50, 32} }},
+		{Change{ID: 1, Stat: StatZIndex, U32: 5}, func(e Element) bool { return e.ZIndex == 5 }},
+		{Change{ID: 1, Stat: StatText2, String: "sub"}, func(e Element) bool { return e.Text2 == "sub" }},
+		{Change{ID: 1, Stat: StatStyle, U32: StyleBold | StyleMono}, func(e Element) bool { return e.Style == StyleBold|StyleMono }},
+	} {
+		if err := h.Change(test.change); err != nil {
+			t.Fatalf("%v: %v", test.change.Stat, err)
+		}
+		if e, _ := h.Element(1); !test.check(e) {
+			t.Errorf("%v: element %+v after %+v", test.change.Stat, e, test.change)
+		}
+	}
+	if e, _ := h.Element(2); e.Text != "a.png" || e.Pos != [2]float32{} {
+		t.Errorf("other element changed to %+v", e)
+	}
+
+	// Adding with an ID in use replaces the element
+	h.Add(Element{ID: 2, Type: TypeStatbar, Number: 20})
+	if e, _ := h.Element(2); e.Type != TypeStatbar || e.Text != "" {
+		t.Errorf("replaced element %+v", e)
+	}
+
+	h.Remove(1)
+	if _, exists := h.Element(1); exists {
+		t.Error("removed element still there")
+	}
+	if got := ids(h.Elements()); !slices.Equal(got, []uint32{2}) {
+		t.Errorf("elements %v after removing 1", got)
+	}
+}
+
+func TestUnknownElements(t *testing.T) {
+	h := New(8)
+	h.Add(Element{ID: 1, Text: "kept"})
+	version := h.Version()
+
+	if err := h.Change(Change{ID: 7, Stat: StatText, String: "x"}); err == nil {
+		t.Error("changed an element that does not exist")
+	}
+	if err := h.Change(Change{ID: 1, Stat: Stat(99)}); err == nil {
+		t.Error("applied an unknown stat")
+	}
+	if h.Version() != version {
+		t.Error("failed changes changed the version")
+	}
+	h.Remove(7)
+	if _, exists := h.Element(7); exists {
+		t.Error("removing an unknown element created it")
+	}
+	if e, _ := h.Element(1); e.Text != "kept" || len(h.Elements()) != 1 {
+		t.Errorf("elements %+v after unknown changes", h.Elements())
+	}
+}
+
+func TestSetFlags(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		flags, mask Flags
+		want        Flags
+	}{
+		{"hide crosshair", 0, FlagCrosshair, DefaultFlags &^ FlagCrosshair},
+		{"outside mask ignored", 0, 0, DefaultFlags},
+		{"only masked bits", FlagMinimap, FlagHotbar | FlagMinimap, DefaultFlags &^ FlagHotbar},
+		{"hide everything", 0, ^Flags(0), 0},
+	} {
+		h := New(8)
+		h.SetFlags(test.flags, test.mask)
+		if got := h.Flags(); got != test.want {
+			t.Errorf("%s: flags %#x, want %#x", test.name, got, test.want)
+		}
+	}
+
+	// Flags the mask leaves out keep earlier changes
+	h := New(8)
+	h.SetFlags(0, FlagHealthbar|FlagBreathbar)
+	h.SetFlags(FlagHealthbar, FlagHealthbar)
+	if got, want := h.Flags(), DefaultFlags&^FlagBreathbar; got != want {
+		t.Errorf("flags %#x, want %#x", got, want)
+	}
+}
+
+func TestSetHotbarItemCount(t *testing.T) {
+	for _, test := range []struct{ count, want int }{
+		{1, 1},
+		{8, 8},
+		{32, 32},
+		{0, MinHotbarItemCount},
+		{-5, MinHotbarItemCount},
+		{33, MaxHotbarItemCount},
+		{1 << 30, MaxHotbarItemCount},
+	} {
+		h := New(8)
+		h.SetHotbarItemCount(test.count)
+		if got := h.Hotbar().ItemCount; got != test.want {
+			t.Errorf("count %d: %d slots, want %d", test.count, got, test.want)
+		}
+	}
+}
+
+func TestZIndex(t *testing.T) {
+	h := New(8)
+	for _, test := range []struct {
+		set  int32
+		want int16
+	}{
+		{0, 0},
+		{-1000, -1000},
+		{1000, 1000},
+		{1001, 1000},
+		{-32768, -1000},
+	} {
+		h.Add(Element{ID: 1, ZIndex: int16(test.set)})
+		if e, _ := h.Element(1); e.ZIndex != test.want {
+			t.Errorf("added with z-index %d: %d, want %d", test.set, e.ZIndex, test.want)
+		}
+	}
+	// Changes carry the z-index in 32 bits, signed
+	for _, test := range []struct {
+		set  int32
+		want int16
+	}{
+		{-5, -5},
+		{5000, 1000},
+		{-5000, -1000},
+		{1 << 20, 1000},
+	} {
+		if err := h.Change(Change{ID: 1, Stat: StatZIndex, U32: uint32(test.set)}); err != nil {
+			t.Fatal(err)
+		}
+		if e, _ := h.Element(1); e.ZIndex != test.want {
+			t.Errorf("changed to z-index %d: %d, want %d", test.set, e.ZIndex, test.want)
+		}
+	}
+}
+
+func TestDrawOrder(t *testing.T) {
+	h := New(8)
+	for _, e := range []Element{
+		{ID: 5, ZIndex: 0},
+		{ID: 1, ZIndex: 10},
+		{ID: 3, ZIndex: -10},
+		{ID: 2, ZIndex: 0},
+		{ID: 4, ZIndex: 2000}, // Clamped to 1000, still above 10
+		{ID: 6, ZIndex: 1000},
+	} {
+		h.Add(e)
+	}
+	if got, want := ids(h.Elements()), []uint32{3, 2, 5, 1, 4, 6}; !slices.Equal(got, want) {
+		t.Errorf("drawn in order %v, want %v", got, want)
+	}
+
+	// Moving an element to the back puts it before the others of its z-index
+	h.Change(Change{ID: 6, Stat: StatZIndex, U32: uint32(0xFFFFFFF6)}) // -10
+	if got, want := ids(h.Elements()), []uint32{3, 6, 2, 5, 1, 4}; !slices.Equal(got, want) {
+		t.Errorf("drawn in order %v, want %v", got, want)
+	}
+}
+
+func TestVersion(t *testing.T) {
+	h := New(8)
+	version := h.Version()
+	for _, test := range []struct {
+		name   string
+		change func()
+	}{
+		{"add", func() { h.Add(Element{ID: 1}) }},
+		{"change", func() { h.Change(Change{ID: 1, Stat: StatText, String: "x"}) }},
+		{"flags", func() { h.SetFlags(0, FlagChat) }},
+		{"hotbar item count", func() { h.SetHotbarItemCount(4) }},
+		{"hotbar image", func() { h.SetHotbarImage("bar.png") }},
+		{"hotbar selected image", func() { h.SetHotbarSelectedImage("sel.png") }},
+		{"remove", func() { h.Remove(1) }},
+	} {
+		test.change()
+		if v := h.Version(); v == version {
+			t.Errorf("%s did not change the version", test.name)
+		} else {
+			version = v
+		}
+	}
+}
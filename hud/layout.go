@@ -0,0 +1,233 @@
+package hud
+
+import (
+	"math"
+	"strconv"
+)
+
+// Pixels of a hotbar or inventory slot before scaling, like HOTBAR_IMAGE_SIZE
+const SlotSize = 48
+
+// Rect is an area of the screen in pixels, with the origin in the top left corner
+type Rect struct {
+	X, Y, Width, Height float32
+}
+
+// Layout places elements on a screen of the given size. Scale multiplies offsets and sizes given in pixels,
+// like hud_scaling.
+type Layout struct {
+	Width, Height float32
+	Scale         float32
+}
+
+// Anchor returns the point an element is aligned around: its position on the screen moved by its offset
+func (l Layout) Anchor(e *Element) [2]float32 {
+	return l.anchorAt([2]float32{e.Pos[0] * l.Width, e.Pos[1] * l.Height}, e)
+}
+
+// anchorAt moves a point on the screen by the offset of an element
+func (l Layout) anchorAt(p [2]float32, e *Element) [2]float32 {
+	return [2]float32{p[0] + e.Offset[0]*l.Scale, p[1] + e.Offset[1]*l.Scale}
+}
+
+// Align places an area of the given size at an anchor. An alignment of -1 puts the area left of or above
+// the anchor, 0 centers it and 1 puts it right of or below the anchor.
+func Align(anchor, size, align [2]float32) Rect {
+	return Rect{
+		X:      anchor[0] + (align[0]-1)*size[0]/2,
+		Y:      anchor[1] + (align[1]-1)*size[1]/2,
+		Width:  size[0],
+		Height: size[1],
+	}
+}
+
+// ImageSize returns the size an image with the given texture size is drawn at. Positive scales multiply
+// the texture size, negative ones are percentages of the screen.
+func (l Layout) ImageSize(scale [2]float32, textureSize [2]float32) [2]float32 {
+	screen := [2]float32{l.Width, l.Height}
+	var size [2]float32
+	for i := range size {
+		if scale[i] < 0 {
+			size[i] = screen[i] * -scale[i] / 100
+		} else {
+			size[i] = textureSize[i] * scale[i] * l.Scale
+		}
+	}
+	return size
+}
+
+// FixedSize returns the size of a compass or minimap, where negative sizes are percentages of the screen
+func (l Layout) FixedSize(size [2]int32) [2]float32 {
+	screen := [2]float32{l.Width, l.Height}
+	var result [2]float32
+	for i, v := range size {
+		if v < 0 {
+			result[i] = screen[i] * float32(-v) / 100
+		} else {
+			result[i] = float32(v) * l.Scale
+		}
+	}
+	return result
+}
+
+// Image returns where an image element with the given texture size goes
+func (l Layout) Image(e *Element, textureSize [2]float32) Rect {
+	return Align(l.Anchor(e), l.ImageSize(e.Scale, textureSize), e.Align)
+}
+
+// Wielded returns where the wielded item goes: a square of a third of the screen height in the lower right
+// corner, reaching below the edge like an item held in the right hand
+func (l Layout) Wielded() Rect {
+	size := l.Height / 3
+	return Rect{X: l.Width - size*1.2, Y: l.Height - size*0.8, Width: size, Height: size}
+}
+
+// Text returns where a text element of the given measured size goes
+func (l Layout) Text(e *Element, textSize [2]float32) Rect {
+	return Align(l.Anchor(e), textSize, e.Align)
+}
+
+// TextScale returns the factor the default font size is multiplied by for a text element
+func TextScale(e *Element) float32 {
+	if e.Size[0] <= 0 {
+		return 1
+	}
+	return float32(e.Size[0])
+}
+
+// StatbarIcon is one icon of a statbar. Half icons show the left or top half of the texture.
+type StatbarIcon struct {
+	Rect
+	Half bool
+}
+
+// Statbar returns the icons of a statbar showing count halves, each icon being iconSize pixels unless the
+// element sets a size. Statbars ignore the alignment and grow in their direction from the anchor.
+func (l Layout) Statbar(e *Element, count uint32, iconSize [2]float32) []StatbarIcon {
+	if e.Size[0] > 0 && e.Size[1] > 0 {
+		iconSize = [2]float32{float32(e.Size[0]), float32(e.Size[1])}
+	}
+	iconSize[0] *= l.Scale
+	iconSize[1] *= l.Scale
+
+	var step [2]float32
+	switch e.Dir {
+	case DirRightLeft:
+		step = [2]float32{-iconSize[0], 0}
+	case DirTopBottom:
+		step = [2]float32{0, iconSize[1]}
+	case DirBottomTop:
+		step = [2]float32{0, -iconSize[1]}
+	default:
+		step = [2]float32{iconSize[0], 0}
+	}
+
+	anchor := l.Anchor(e)
+	icons := make([]StatbarIcon, 0, (count+1)/2)
+	for i := uint32(0); i < (count+1)/2; i++ {
+		icon := StatbarIcon{Rect: Rect{
+			X:      anchor[0] + step[0]*float32(i),
+			Y:      anchor[1] + step[1]*float32(i),
+			Width:  iconSize[0],
+			Height: iconSize[1],
+		}}
+		if i == count/2 {
+			// The last icon of an odd count is cut in half across the direction of the bar
+			icon.Half = true
+			if step[0] == 0 {
+				icon.Height /= 2
+			} else {
+				icon.Width /= 2
+			}
+		}
+		icons = append(icons, icon)
+	}
+	return icons
+}
+
+// Slots returns the slots of an inventory or hotbar element showing count items. The row or column of slots
+// is aligned around the anchor like an image.
+func (l Layout) Slots(e *Element, count int) []Rect {
+	return l.SlotsAt(l.Anchor(e), e.Align, e.Dir, count)
+}
+
+// SlotsAt returns count slots in a row or column aligned around anchor, growing in the direction dir
+func (l Layout) SlotsAt(anchor [2]float32, align [2]float32, dir Direction, count int) []Rect {
+	slot := float32(math.Floor(float64(SlotSize*l.Scale) + 0.5))
+	padding := float32(math.Floor(float64(slot / 12)))
+	pitch := slot + 2*padding
+
+	vertical := dir == DirTopBottom || dir == DirBottomTop
+	size := [2]float32{pitch * float32(count), pitch}
+	if vertical {
+		size = [2]float32{pitch, pitch * float32(count)}
+	}
+	area := Align(anchor, size, align)
+
+	slots := make([]Rect, count)
+	for i := range slots {
+		index := i
+		if dir == DirRightLeft || dir == DirBottomTop {
+			index = count - 1 - i
+		}
+		r := Rect{X: area.X + padding, Y: area.Y + padding, Width: slot, Height: slot}
+		if vertical {
+			r.Y += pitch * float32(index)
+		} else {
+			r.X += pitch * float32(index)
+		}
+		slots[i] = r
+	}
+	return slots
+}
+
+// WaypointText returns the label of a waypoint: its name and the distance to it in nodes, rounded to the
+// precision set in the item of the element, followed by the unit in its text
+func WaypointText(e *Element, distance float32) string {
+	precision := float64(e.Item)
+	if precision == 0 {
+		precision = 10
+	}
+	rounded := math.Floor(float64(distance)*precision) / precision
+	return e.Name + " (" + strconv.FormatFloat(rounded, 'f', -1, 64) + e.Text + ")"
+}
+
+// CompassAngle returns the angle in degrees a rotating compass turns its image by for a player looking
+// towards yaw, and the fraction of its width a translating compass shifts its image by
+func CompassAngle(e *Element, yaw float32) (angle, shift float32) {
+	switch e.Dir {
+	case CompassRotate:
+		return yaw, 0
+	case CompassRotateReverse:
+		return -yaw, 0
+	case CompassTranslate:
+		return 0, yaw / 360
+	case CompassTranslateReverse:
+		return 0, -yaw / 360
+	}
+	return 0, 0
+}
+
+// ProjectWaypoint turns a point projected into normalized device coordinates into a screen position.
+// It reports false for points behind the camera.
+func (l Layout) ProjectWaypoint(ndc [3]float32) ([2]float32, bool) {
+	if ndc[2] < -1 || ndc[2] > 1 {
+		return [2]float32{}, false
+	}
+	return [2]float32{(ndc[0] + 1) / 2 * l.Width, (1 - ndc[1]) / 2 * l.Height}, true
+}
+
+// Waypoint returns where the label of a waypoint of the given text size goes for a screen position
+func (l Layout) Waypoint(e *Element, screen [2]float32, textSize [2]float32) Rect {
+	return Align(l.anchorAt(screen, e), textSize, e.Align)
+}
+
+// ImageWaypoint returns where the image of a waypoint goes for a screen position
+func (l Layout) ImageWaypoint(e *Element, screen [2]float32, textureSize [2]float32) Rect {
+	return Align(l.anchorAt(screen, e), l.ImageSize(e.Scale, textureSize), e.Align)
+}
+
+// Fixed returns where a compass or minimap goes
+func (l Layout) Fixed(e *Element) Rect {
+	return Align(l.Anchor(e), l.FixedSize(e.Size), e.Align)
+}
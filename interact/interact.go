@@ -0,0 +1,289 @@
+package interact
+
+import (
+	"bettermt/main/blocktypes"
+	"bettermt/main/client"
+	"bettermt/main/inventory"
+	"bettermt/main/meshbuilder"
+	"bettermt/main/player"
+	"bettermt/main/protocol"
+)
+
+const (
+	// Pause after digging a node before the next one starts, in seconds
+	digRepeatDelay = 0.15
+	// Time between placements while the place control is held, in seconds
+	placeRepeatDelay = 0.25
+	// Time the server has to send the block of a predicted change before it is undone, in seconds
+	predictionTimeout = 2
+	// Bits of a node thrown out when it is punched and when it is dug, like in Minetest
+	punchParticles = 1
+	digParticles   = 16
+)
+
+// prediction is a node changed locally before the server confirmed it
+type prediction struct {
+	pos   [3]int32
+	block *meshbuilder.MapBlock // Replaced once the server sends the block again
+	old   uint16
+	age   float32
+}
+
+// Controller turns the dig and place controls of the local player into interactions with the node it points at.
+// Dug and placed nodes are changed in the world right away and changed back if the server does not follow.
+type Controller struct {
+	client *client.Client
+	world  *meshbuilder.World
+
+	pointed protocol.PointedThing
+
+	digging     bool
+	diggable    bool
+	digTarget   [3]int16
+	digTime     float32
+	digProgress float32
+	digDelay    float32
+	placeDelay  float32
+
+	// Sound of digging the node being dug and the time until it plays again
+	digSound      blocktypes.SoundSpec
+	digSoundDelay float32
+
+	// Controls of the previous update, to tell clicks from held buttons
+	wasDigging bool
+	wasPlacing bool
+
+	predictions []prediction
+}
+
+// NewController creates a controller for the local player of c acting on world
+func NewController(c *client.Client, world *meshbuilder.World) *Controller {
+	return &Controller{client: c, world: world}
+}
+
+// Pointed returns the thing the player pointed at during the last update
+func (ic *Controller) Pointed() protocol.PointedThing {
+	return ic.pointed
+}
+
+// DigProgress returns how much of the pointed node is dug, from 0 to 1
+func (ic *Controller) DigProgress() float32 {
+	if !ic.digging || !ic.diggable || ic.digTime <= 0 {
+		return 0
+	}
+	return min(1, ic.digProgress/ic.digTime)
+}
+
+// Update finds the pointed thing and acts on it according to the controls of the player.
+// Call it from the render thread after the player moved.
+func (ic *Controller) Update(dtime float32) error {
+	ic.updatePredictions(dtime)
+
+	itemDefs, nodeDefs := ic.client.ItemDefs(), ic.client.NodeDefs()
+	if ic.client.State() != client.StateReady || itemDefs == nil || nodeDefs == nil {
+		return nil
+	}
+	p := ic.client.Player
+	controls := p.State().Controls
+	item := ic.client.WieldedItem()
+	itemDef := itemDefs.Get(item.Name)
+	objects := ic.client.Objects.Objects()
+	ic.pointed = Raycast(ic.world, objects, p.EyePosition(), p.LookDirection(), itemDefs.Range(item.Name), itemDef.LiquidsPointable)
+
+	err := ic.updateDigging(dtime, controls.Dig, itemDefs, item)
+	if placeErr := ic.updatePlacing(dtime, controls, itemDef, nodeDefs); err == nil {
+		err = placeErr
+	}
+	ic.wasDigging, ic.wasPlacing = controls.Dig, controls.Place
+	return err
+}
+
+// updateDigging starts, advances and finishes digging the pointed node, or uses the wielded item
+func (ic *Controller) updateDigging(dtime float32, dig bool, itemDefs *inventory.ItemDefManager, item inventory.ItemStack) error {
+	ic.digDelay -= dtime
+	pointed := ic.pointed
+	if ic.digging && (!dig || pointed.Type != protocol.PointedNode || pointed.Under != ic.digTarget) {
+		ic.digging = false
+		if err := ic.client.Interact(protocol.InteractStopDigging, protocol.PointedThing{Type: protocol.PointedNode, Under: ic.digTarget}); err != nil {
+			return err
+		}
+	}
+	if !dig {
+		return nil
+	}
+
+	clicked := !ic.wasDigging
+	switch {
+	case itemDefs.Get(item.Name).Usable:
+		// Items with on_use are used instead of digging, once per click
+		if clicked {
+			return ic.client.Interact(protocol.InteractUse, pointed)
+		}
+		return nil
+	case pointed.Type == protocol.PointedObject:
+		// Objects are punched, once per click
+		if clicked {
+			return ic.client.Interact(protocol.InteractStartDigging, pointed)
+		}
+		return nil
+	case pointed.Type != protocol.PointedNode || ic.digDelay > 0:
+		return nil
+	}
+
+	under := toV3S32(pointed.Under)
+	def, _ := ic.world.Node(under[0], under[1], under[2])
+	if !ic.digging {
+		params := inventory.GetDigParams(def.Groups, itemDefs.ToolCapabilities(item.Name))
+		ic.digging, ic.diggable = true, params.Diggable
+		ic.digTarget, ic.digTime, ic.digProgress = pointed.Under, params.Time, 0
+		ic.digSound, ic.digSoundDelay = digSound(def, params), 0
+		if err := ic.client.Interact(protocol.InteractStartDigging, pointed); err != nil {
+			return err
+		}
+		ic.addNodeParticles(under, def, punchParticles)
+	}
+	if !ic.diggable {
+		return nil
+	}
+
+	// The node is hit again and again while it is dug
+	var soundErr error
+	ic.digSoundDelay -= dtime
+	if ic.digSoundDelay <= 0 {
+		ic.digSoundDelay = digSoundInterval
+		soundErr = playSound(ic.client, ic.digSound)
+	}
+
+	ic.digProgress += dtime
+	if ic.digProgress < ic.digTime {
+		return soundErr
+	}
+	ic.digging = false
+	ic.digDelay = digRepeatDelay
+	if err := ic.client.Interact(protocol.InteractDiggingCompleted, pointed); err != nil {
+		return err
+	}
+	ic.addNodeParticles(under, def, digParticles)
+	ic.predictDig(under, def)
+	if err := playSound(ic.client, def.SoundDug); err != nil {
+		return err
+	}
+	return soundErr
+}
+
+// addNodeParticles throws count bits of a node out of it, falling with the gravity of the player
+func (ic *Controller) addNodeParticles(pos [3]int32, def *blocktypes.NodeDefinition, count int) {
+	if id, exists := blocktypes.NodeDefs().GetID(def.Name); exists {
+		ic.client.Particles.AddNodeParticles(pos, id, count, ic.client.Player.Physics().Effective().Gravity)
+	}
+}
+
+// updatePlacing places the wielded item on the pointed thing, repeating while the place control is held
+func (ic *Controller) updatePlacing(dtime float32, controls player.Controls, itemDef *inventory.ItemDefinition, nodeDefs *blocktypes.NodeDefManager) error {
+	ic.placeDelay -= dtime
+	if !controls.Place || (ic.wasPlacing && ic.placeDelay > 0) {
+		return nil
+	}
+	ic.placeDelay = placeRepeatDelay
+
+	pointed := ic.pointed
+	switch pointed.Type {
+	case protocol.PointedNothing:
+		return ic.client.Interact(protocol.InteractActivate, pointed)
+	case protocol.PointedObject:
+		return ic.client.Interact(protocol.InteractPlace, pointed)
+	}
+	if err := ic.client.Interact(protocol.InteractPlace, pointed); err != nil {
+		return err
+	}
+
+	// Right clicking a node that reacts to it uses the node instead, unless the player sneaks
+	under := toV3S32(pointed.Under)
+	if def, _ := ic.world.Node(under[0], under[1], under[2]); def.Rightclickable && !controls.Sneak {
+		return nil
+	}
+	return ic.predictPlace(itemDef, nodeDefs)
+}
+
+// predictDig replaces a dug node with what its definition says digging leaves behind
+func (ic *Controller) predictDig(pos [3]int32, def *blocktypes.NodeDefinition) {
+	name := def.NodeDigPrediction
+	if name == "" {
+		name = "air"
+	}
+	if id, exists := blocktypes.NodeDefs().GetID(name); exists {
+		ic.predict(pos, id)
+	}
+}
+
+// predictPlace puts down the node the wielded item places where the server is expected to put it, playing the
+// sound of the item placing it or failing to
+func (ic *Controller) predictPlace(itemDef *inventory.ItemDefinition, nodeDefs *blocktypes.NodeDefManager) error {
+	id, exists := nodeDefs.GetID(itemDef.NodePlacementPrediction)
+	if itemDef.NodePlacementPrediction == "" || !exists {
+		return nil
+	}
+
+	// Nodes such as grass are replaced instead of built upon
+	target := toV3S32(ic.pointed.Above)
+	under := toV3S32(ic.pointed.Under)
+	if def, _ := ic.world.Node(under[0], under[1], under[2]); def.BuildableTo {
+		target = under
+	}
+	if def, loaded := ic.world.Node(target[0], target[1], target[2]); !loaded || !def.BuildableTo {
+		return playSound(ic.client, itemDef.SoundPlaceFailed)
+	}
+	if nodeDefs.Get(id).Walkable && overlapsPlayer(target, ic.client.Player.Position()) {
+		return playSound(ic.client, itemDef.SoundPlaceFailed)
+	}
+	ic.predict(target, id)
+	return playSound(ic.client, itemDef.SoundPlace)
+}
+
+// predict changes a node and remembers what it was in case the server disagrees
+func (ic *Controller) predict(pos [3]int32, content uint16) {
+	old, ok := ic.world.SetNode(pos[0], pos[1], pos[2], content)
+	if !ok {
+		return
+	}
+	ic.predictions = append(ic.predictions, prediction{
+		pos:   pos,
+		block: ic.world.ChunkAt(pos[0], pos[1], pos[2]),
+		old:   old,
+	})
+}
+
+// updatePredictions forgets predictions whose block the server sent again and undoes the ones it never confirmed
+func (ic *Controller) updatePredictions(dtime float32) {
+	kept := ic.predictions[:0]
+	// Newest first, so that several changes of one node are undone in order
+	for i := len(ic.predictions) - 1; i >= 0; i-- {
+		p := ic.predictions[i]
+		p.age += dtime
+		switch {
+		case ic.world.ChunkAt(p.pos[0], p.pos[1], p.pos[2]) != p.block:
+			// The server sent the block, which holds what it decided
+		case p.age >= predictionTimeout:
+			ic.world.SetNode(p.pos[0], p.pos[1], p.pos[2], p.old)
+		default:
+			kept = append(kept, p)
+		}
+	}
+	// Restore the oldest first order
+	for i, j := 0, len(kept)-1; i < j; i, j = i+1, j-1 {
+		kept[i], kept[j] = kept[j], kept[i]
+	}
+	ic.predictions = kept
+}
+
+// overlapsPlayer reports whether a node at pos would be inside a player standing at feet
+func overlapsPlayer(pos [3]int32, feet [3]float32) bool {
+	const radius, height = 0.3, 1.77
+	return float32(pos[0])+0.5 > feet[0]-radius && float32(pos[0])-0.5 < feet[0]+radius &&
+		float32(pos[1])+0.5 > feet[1] && float32(pos[1])-0.5 < feet[1]+height &&
+		float32(pos[2])+0.5 > feet[2]-radius && float32(pos[2])-0.5 < feet[2]+radius
+}
+
+func toV3S32(v [3]int16) [3]int32 {
+	return [3]int32{int32(v[0]), int32(v[1]), int32(v[2])}
+}
@@ -0,0 +1,123 @@
+package interact
+
+import (
+	"math"
+
+	"bettermt/main/blocktypes"
+	"bettermt/main/meshbuilder"
+	"bettermt/main/object"
+	"bettermt/main/player"
+	"bettermt/main/protocol"
+)
+
+// Raycast returns the first pointable node or object a ray from origin along the unit vector dir hits within
+// distance nodes. Nodes come with the node in front of the face the ray entered through. Liquids are pointable
+// only with liquids set, and the object of the local player never is.
+func Raycast(env player.Environment, objects []object.Object, origin, dir [3]float32, distance float32, liquids bool) protocol.PointedThing {
+	pointed, hit := raycastNodes(env, origin, dir, distance, liquids)
+	for _, o := range objects {
+		if t, ok := hitObject(&o, origin, dir); ok && t <= float64(distance) && (pointed.Type == protocol.PointedNothing || t < hit) {
+			pointed, hit = protocol.PointedThing{Type: protocol.PointedObject, ObjectID: o.ID}, t
+		}
+	}
+	return pointed
+}
+
+// raycastNodes returns the first pointable node along a ray and how far along the ray it was entered
+func raycastNodes(env player.Environment, origin, dir [3]float32, distance float32, liquids bool) (protocol.PointedThing, float64) {
+	// Walk the nodes along the ray one face crossing at a time
+	var node, step [3]int32
+	var next, delta [3]float64
+	for i := range node {
+		node[i] = meshbuilder.NodeCoord(origin[i])
+		switch {
+		case dir[i] > 0:
+			step[i] = 1
+			next[i] = (float64(node[i]) + 0.5 - float64(origin[i])) / float64(dir[i])
+			delta[i] = 1 / float64(dir[i])
+		case dir[i] < 0:
+			step[i] = -1
+			next[i] = (float64(node[i]) - 0.5 - float64(origin[i])) / float64(dir[i])
+			delta[i] = -1 / float64(dir[i])
+		default:
+			next[i] = math.Inf(1)
+			delta[i] = math.Inf(1)
+		}
+	}
+
+	above := node
+	for t := 0.0; t <= float64(distance); {
+		if pointable(env, node, liquids) {
+			return protocol.PointedThing{Type: protocol.PointedNode, Under: toV3S16(node), Above: toV3S16(above)}, t
+		}
+		axis := 0
+		if next[1] < next[axis] {
+			axis = 1
+		}
+		if next[2] < next[axis] {
+			axis = 2
+		}
+		t = next[axis]
+		above = node
+		node[axis] += step[axis]
+		next[axis] += delta[axis]
+	}
+	return protocol.PointedThing{}, 0
+}
+
+// hitObject returns how far along a ray it enters the selection box of a pointable object, turned with the
+// object if its properties say so
+func hitObject(o *object.Object, origin, dir [3]float32) (float64, bool) {
+	if o.IsLocal || !o.Props.Pointable {
+		return 0, false
+	}
+	var rel [3]float32
+	for i := range rel {
+		rel[i] = origin[i] - o.VisualPosition[i]
+	}
+	if o.Props.RotateSelectionBox {
+		rel = object.Unrotate(rel, o.VisualRotation)
+		dir = object.Unrotate(dir, o.VisualRotation)
+	}
+	return hitBox(o.Props.SelectionBox, rel, dir)
+}
+
+// hitBox intersects a ray with a box, both relative to the same point. It returns the distance along the ray
+// to where it enters the box, 0 if it starts inside.
+func hitBox(box object.Box, origin, dir [3]float32) (float64, bool) {
+	enter, leave := 0.0, math.Inf(1)
+	for i := range origin {
+		lo, hi, o := float64(box.Min[i]), float64(box.Max[i]), float64(origin[i])
+		if dir[i] == 0 {
+			if o < lo || o > hi {
+				return 0, false
+			}
+			continue
+		}
+		t0, t1 := (lo-o)/float64(dir[i]), (hi-o)/float64(dir[i])
+		if t0 > t1 {
+			t0, t1 = t1, t0
+		}
+		enter, leave = max(enter, t0), min(leave, t1)
+		if enter > leave {
+			return 0, false
+		}
+	}
+	return enter, true
+}
+
+// pointable reports whether the ray stops at a node
+func pointable(env player.Environment, pos [3]int32, liquids bool) bool {
+	def, loaded := env.Node(pos[0], pos[1], pos[2])
+	if !loaded || def == nil {
+		return false
+	}
+	if def.LiquidType != blocktypes.LiquidNone {
+		return liquids
+	}
+	return def.Pointable
+}
+
+func toV3S16(v [3]int32) [3]int16 {
+	return [3]int16{int16(v[0]), int16(v[1]), int16(v[2])}
+}
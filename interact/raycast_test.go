@@ -0,0 +1,90 @@
+package interact
+
+import (
+	"testing"
+
+	"bettermt/main/blocktypes"
+	"bettermt/main/object"
+	"bettermt/main/protocol"
+)
+
+// testEnv is a world of loaded nodes, air where it holds no definition
+type testEnv map[[3]int32]*blocktypes.NodeDefinition
+
+func (e testEnv) Node(x, y, z int32) (*blocktypes.NodeDefinition, bool) {
+	return e[[3]int32{x, y, z}], true
+}
+
+var stone = &blocktypes.NodeDefinition{Name: "default:stone", Pointable: true}
+
+// testObject returns an object with the default selection box, one node wide, at a position
+func testObject(id uint16, pos [3]float32) object.Object {
+	return *object.New(id, "test:mob", false, pos, [3]float32{}, 10)
+}
+
+func TestRaycastNearerOfNodeAndObject(t *testing.T) {
+	env := testEnv{{0, 0, 5}: stone}
+	origin, dir := [3]float32{0, 0, 0}, [3]float32{0, 0, 1}
+
+	tests := []struct {
+		name    string
+		objects []object.Object
+		want    protocol.PointedThing
+	}{
+		{"node", nil, protocol.PointedThing{Type: protocol.PointedNode, Under: [3]int16{0, 0, 5}, Above: [3]int16{0, 0, 4}}},
+		{"object in front", []object.Object{testObject(7, [3]float32{0, 0, 2})}, protocol.PointedThing{Type: protocol.PointedObject, ObjectID: 7}},
+		{"nearest object", []object.Object{testObject(7, [3]float32{0, 0, 3}), testObject(8, [3]float32{0, 0, 2})}, protocol.PointedThing{Type: protocol.PointedObject, ObjectID: 8}},
+		{"object behind the node", []object.Object{testObject(7, [3]float32{0, 0, 7})}, protocol.PointedThing{Type: protocol.PointedNode, Under: [3]int16{0, 0, 5}, Above: [3]int16{0, 0, 4}}},
+		{"object beside the ray", []object.Object{testObject(7, [3]float32{2, 0, 2})}, protocol.PointedThing{Type: protocol.PointedNode, Under: [3]int16{0, 0, 5}, Above: [3]int16{0, 0, 4}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Raycast(env, test.objects, origin, dir, 10, false); got != test.want {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRaycastSkipsObjects(t *testing.T) {
+	origin, dir := [3]float32{0, 0, 0}, [3]float32{0, 0, 1}
+
+	local := testObject(1, [3]float32{0, 0, 0})
+	local.IsLocal = true
+	unpointable := testObject(2, [3]float32{0, 0, 2})
+	unpointable.Props.Pointable = false
+	far := testObject(3, [3]float32{0, 0, 6})
+
+	if got := Raycast(testEnv{}, []object.Object{local, unpointable, far}, origin, dir, 4, false); got.Type != protocol.PointedNothing {
+		t.Fatalf("got %v, want nothing", got)
+	}
+}
+
+func TestRaycastRotatedSelectionBox(t *testing.T) {
+	// A long box across the ray misses it until the object turns it by 90 degrees of yaw
+	o := testObject(5, [3]float32{0, 0, 0})
+	o.Props.SelectionBox = object.Box{Min: [3]float32{-0.1, -0.1, 1}, Max: [3]float32{0.1, 0.1, 3}}
+	origin, dir := [3]float32{2, 0, -5}, [3]float32{0, 0, 1}
+
+	if got := Raycast(testEnv{}, []object.Object{o}, origin, dir, 10, false); got.Type != protocol.PointedNothing {
+		t.Fatalf("unrotated box: got %v, want nothing", got)
+	}
+	// Objects turn with negated angles, so a yaw of -90 degrees swings +z round to +x
+	o.Props.RotateSelectionBox = true
+	for yaw, want := range map[float32]protocol.PointedThingType{-90: protocol.PointedObject, 90: protocol.PointedNothing} {
+		o.VisualRotation = [3]float32{0, yaw, 0}
+		if got := Raycast(testEnv{}, []object.Object{o}, origin, dir, 10, false); got.Type != want {
+			t.Fatalf("yaw %v: got %v", yaw, got)
+		}
+	}
+}
+
+func TestUnrotate(t *testing.T) {
+	v := [3]float32{1, 2, 3}
+	got := object.Unrotate(object.Rotate(v, [3]float32{30, 60, 90}), [3]float32{30, 60, 90})
+	for i := range v {
+		if d := got[i] - v[i]; d > 1e-5 || d < -1e-5 {
+			t.Fatalf("got %v back, want %v", got, v)
+		}
+	}
+}
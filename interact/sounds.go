@@ -0,0 +1,116 @@
+package interact
+
+import (
+	"math"
+
+	"bettermt/main/blocktypes"
+	"bettermt/main/client"
+	"bettermt/main/inventory"
+	"bettermt/main/meshbuilder"
+	"bettermt/main/sound"
+)
+
+const (
+	// Time between dig sounds while a node is being dug, in seconds
+	digSoundInterval = 0.3
+	// Gain of the dig sounds of the main dig group, for nodes that ask for them
+	groupDigSoundGain = 0.5
+	// Sound name of nodes whose dig sound depends on the dig group
+	groupDigSound = "__group"
+	// Distance walked on the ground per footstep, in nodes
+	footstepDistance = 1.4
+	// Movement within one update above which the player is taken to have been teleported, in nodes
+	teleportDistance = 2
+)
+
+// playSound plays a sound of a node or item at the player
+func playSound(c *client.Client, spec blocktypes.SoundSpec) error {
+	if c.Sounds == nil || spec.Name == "" {
+		return nil
+	}
+	return c.Sounds.Play(sound.NodeSpec(spec), sound.Location{Type: sound.LocationLocal})
+}
+
+// digSound returns the sound of digging a node with the given parameters. Nodes with the group sound sound like
+// the main group they are dug by, if there is one.
+func digSound(def *blocktypes.NodeDefinition, params inventory.DigParams) blocktypes.SoundSpec {
+	if def == nil || !params.Diggable {
+		return blocktypes.SoundSpec{}
+	}
+	if def.SoundDig.Name != groupDigSound {
+		return def.SoundDig
+	}
+	if params.Group == "" {
+		return blocktypes.SoundSpec{}
+	}
+	return blocktypes.SoundSpec{Name: "default_dig_" + params.Group, Gain: groupDigSoundGain, Pitch: 1}
+}
+
+// Footsteps plays the footstep sounds of the local player while it walks, jumps and lands on nodes
+type Footsteps struct {
+	client *client.Client
+	world  *meshbuilder.World
+
+	lastPos  [3]float32
+	onGround bool
+	walked   float32 // Distance walked since the last footstep
+	started  bool    // Whether lastPos is known
+}
+
+// NewFootsteps creates the footsteps of the local player of c walking on world
+func NewFootsteps(c *client.Client, world *meshbuilder.World) *Footsteps {
+	return &Footsteps{client: c, world: world}
+}
+
+// Update plays a footstep if the player walked far enough, jumped or landed since the last update. Call it
+// from the render thread after the player moved.
+func (f *Footsteps) Update() error {
+	p := f.client.Player
+	pos, onGround := p.Position(), p.TouchingGround()
+	wasOnGround, lastPos, known := f.onGround, f.lastPos, f.started
+	f.lastPos, f.onGround, f.started = pos, onGround, true
+	if !known {
+		return nil
+	}
+
+	dx, dz := pos[0]-lastPos[0], pos[2]-lastPos[2]
+	moved := float32(math.Sqrt(float64(dx*dx + dz*dz)))
+	if moved > teleportDistance {
+		return nil
+	}
+	switch {
+	case wasOnGround && !onGround:
+		// Jumping off the ground sounds like the node left
+		f.walked = 0
+		return f.step(lastPos)
+	case onGround && !wasOnGround:
+		f.walked = 0
+		return f.step(pos)
+	case onGround:
+		f.walked += moved
+		if f.walked >= footstepDistance {
+			f.walked -= footstepDistance
+			return f.step(pos)
+		}
+	}
+	return nil
+}
+
+// step plays the footstep of the node below the feet at pos, or of the node at the feet if the one below has
+// none, such as for a layer of snow on top of dirt
+func (f *Footsteps) step(pos [3]float32) error {
+	x, z := meshbuilder.NodeCoord(pos[0]), meshbuilder.NodeCoord(pos[2])
+	spec := f.footstep(x, meshbuilder.NodeCoord(pos[1]-0.05), z)
+	if spec.Name == "" {
+		spec = f.footstep(x, meshbuilder.NodeCoord(pos[1]+0.05), z)
+	}
+	return playSound(f.client, spec)
+}
+
+// footstep returns the footstep sound of the node at a position, empty if it is not loaded
+func (f *Footsteps) footstep(x, y, z int32) blocktypes.SoundSpec {
+	if def, _ := f.world.Node(x, y, z); def != nil {
+		return def.SoundFootstep
+	}
+	return blocktypes.SoundSpec{}
+}
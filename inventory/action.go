@@ -0,0 +1,195 @@
+package inventory
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Locations of inventories as list[] elements and inventory actions write them. Others are player:NAME,
+// nodemeta:X,Y,Z and detached:NAME.
+const (
+	LocationCurrentPlayer = "current_player"
+	LocationContext       = "context" // The node whose formspec is shown
+)
+
+// Action is an inventory action, a Move or a Drop, as sent in TOSERVER_INVENTORY_ACTION
+type Action interface {
+	String() string
+}
+
+// Slot is one slot of a list of an inventory
+type Slot struct {
+	Location string
+	List     string
+	Index    int
+}
+
+// Move is the inventory action moving items from one slot to another, as sent in TOSERVER_INVENTORY_ACTION
+type Move struct {
+	Count     uint16 // 0 for the whole stack
+	From, To  Slot
+	Somewhere bool // Into whichever slots of the list of To take the items, as shift clicks move them
+}
+
+func (m Move) String() string {
+	if m.Somewhere {
+		return fmt.Sprintf("MoveSomewhere %d %s %s %d %s %s", m.Count,
+			m.From.Location, m.From.List, m.From.Index, m.To.Location, m.To.List)
+	}
+	return fmt.Sprintf("Move %d %s %s %d %s %s %d", m.Count,
+		m.From.Location, m.From.List, m.From.Index, m.To.Location, m.To.List, m.To.Index)
+}
+
+// Apply does to inv what the server is expected to do with the move, so that the player sees the result before
+// the server confirms it. Items are added to a stack of the same item up to its stack_max, and a whole stack is
+// swapped with a stack of another item. Moves somewhere fill stacks of the same item first, then empty slots.
+// It reports whether inv changed.
+func (m Move) Apply(inv *Inventory, itemDefs *ItemDefManager) bool {
+	if m.Somewhere {
+		return m.applySomewhere(inv, itemDefs)
+	}
+	from, to := inv.slot(m.From), inv.slot(m.To)
+	if from == nil || to == nil || from == to || from.IsEmpty() {
+		return false
+	}
+	count := m.Count
+	if count == 0 || count > from.Count {
+		count = from.Count
+	}
+
+	switch {
+	case to.IsEmpty():
+		*to = from.Clone()
+		to.Count = count
+	case to.Name == from.Name && to.Wear == from.Wear && sameMeta(to.Meta, from.Meta):
+		room := uint16(max(0, int(stackMax(itemDefs, to.Name))-int(to.Count)))
+		count = min(count, room)
+		if count == 0 {
+			return false
+		}
+		to.Count += count
+	case count == from.Count:
+		*from, *to = *to, *from
+		return true
+	default:
+		return false
+	}
+	from.Count -= count
+	if from.Count == 0 {
+		*from = ItemStack{}
+	}
+	return true
+}
+
+func (m Move) applySomewhere(inv *Inventory, itemDefs *ItemDefManager) bool {
+	from, list := inv.slot(m.From), inv.List(m.To.List)
+	if from == nil || list == nil || from.IsEmpty() {
+		return false
+	}
+	left := from.Count
+	if m.Count != 0 && m.Count < left {
+		left = m.Count
+	}
+	moved := false
+	for _, emptyOnly := range []bool{false, true} {
+		for i := range list.Items {
+			to := &list.Items[i]
+			if left == 0 || to == from || to.IsEmpty() != emptyOnly || !emptyOnly && to.Name != from.Name {
+				continue
+			}
+			single := m
+			single.Somewhere, single.Count, single.To.Index = false, left, i
+			before := from.Count
+			if single.Apply(inv, itemDefs) && from.Count < before {
+				left -= before - from.Count
+				moved = true
+			}
+		}
+	}
+	return moved
+}
+
+// Drop is the inventory action dropping items of a slot into the world
+type Drop struct {
+	Count uint16 // 0 for the whole stack
+	From  Slot
+}
+
+func (d Drop) String() string {
+	return fmt.Sprintf("Drop %d %s %s %d", d.Count, d.From.Location, d.From.List, d.From.Index)
+}
+
+// ParseAction parses a move or drop as String writes it. Craft actions are not supported.
+func ParseAction(s string) (Action, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty inventory action")
+	}
+	want := map[string]int{"Move": 8, "MoveSomewhere": 7, "Drop": 5}[fields[0]]
+	if want == 0 {
+		return nil, fmt.Errorf("unsupported inventory action %q", fields[0])
+	}
+	if len(fields) < want {
+		return nil, fmt.Errorf("%s: got %d fields, want %d", fields[0], len(fields), want)
+	}
+	count, err := strconv.ParseUint(fields[1], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("%s: count: %w", fields[0], err)
+	}
+	from, err := parseSlot(fields[2:5])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fields[0], err)
+	}
+	switch fields[0] {
+	case "Drop":
+		return Drop{Count: uint16(count), From: from}, nil
+	case "MoveSomewhere":
+		return Move{Count: uint16(count), From: from, To: Slot{Location: fields[5], List: fields[6]}, Somewhere: true}, nil
+	}
+	to, err := parseSlot(fields[5:8])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fields[0], err)
+	}
+	return Move{Count: uint16(count), From: from, To: to}, nil
+}
+
+// parseSlot parses the location, list and index of a slot
+func parseSlot(fields []string) (Slot, error) {
+	index, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return Slot{}, fmt.Errorf("slot index: %w", err)
+	}
+	return Slot{Location: fields[0], List: fields[1], Index: index}, nil
+}
+
+// slot returns the stack in a slot of the inventory, ignoring its location, or nil if there is no such slot
+func (inv *Inventory) slot(s Slot) *ItemStack {
+	l := inv.List(s.List)
+	if l == nil || s.Index < 0 || s.Index >= len(l.Items) {
+		return nil
+	}
+	return &l.Items[s.Index]
+}
+
+// stackMax returns how many items of a name fit into one slot
+func stackMax(itemDefs *ItemDefManager, name string) int16 {
+	if itemDefs != nil {
+		if def := itemDefs.Get(name); def != nil {
+			return def.StackMax
+		}
+	}
+	return NewItemDefinition(name, ItemTypeNone).StackMax
+}
+
+func sameMeta(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, value := range a {
+		if other, exists := b[key]; !exists || other != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,189 @@
+package inventory
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Name of the list of a player the hotbar shows the start of
+const MainList = "main"
+
+// Number of slots in the hotbar unless the server sets another
+const DefaultHotbarSize = 8
+
+// List is a named row of item slots, shown as a grid Width slots wide
+type List struct {
+	Name  string
+	Width int // 0 if the list has no preferred shape
+	Items []ItemStack
+}
+
+// NewList creates a list of empty slots
+func NewList(name string, size, width int) *List {
+	return &List{Name: name, Width: width, Items: make([]ItemStack, size)}
+}
+
+// Resize grows the list with empty slots or drops the slots past size
+func (l *List) Resize(size int) {
+	if size <= len(l.Items) {
+		l.Items = l.Items[:size]
+		return
+	}
+	l.Items = append(l.Items, make([]ItemStack, size-len(l.Items))...)
+}
+
+// Inventory is a set of lists, such as the main list and crafting grid of a player
+type Inventory struct {
+	Lists []*List
+}
+
+// New creates an inventory without lists
+func New() *Inventory {
+	return &Inventory{}
+}
+
+// List returns the list with the given name, or nil if there is none
+func (inv *Inventory) List(name string) *List {
+	for _, l := range inv.Lists {
+		if l.Name == name {
+			return l
+		}
+	}
+	return nil
+}
+
+// AddList adds a list, replacing any list with the same name
+func (inv *Inventory) AddList(l *List) {
+	for i, old := range inv.Lists {
+		if old.Name == l.Name {
+			inv.Lists[i] = l
+			return
+		}
+	}
+	inv.Lists = append(inv.Lists, l)
+}
+
+// Hotbar returns the first size slots of the main list
+func (inv *Inventory) Hotbar(size int) []ItemStack {
+	main := inv.List(MainList)
+	if main == nil {
+		return nil
+	}
+	return main.Items[:min(size, len(main.Items))]
+}
+
+// Clone returns a deep copy of the inventory
+func (inv *Inventory) Clone() *Inventory {
+	clone := &Inventory{Lists: make([]*List, len(inv.Lists))}
+	for i, l := range inv.Lists {
+		items := make([]ItemStack, len(l.Items))
+		for j, item := range l.Items {
+			items[j] = item.Clone()
+		}
+		clone.Lists[i] = &List{Name: l.Name, Width: l.Width, Items: items}
+	}
+	return clone
+}
+
+// Deserialize applies the text form of an inventory, as sent in TOCLIENT_INVENTORY. Servers send only the
+// lists and slots that changed, marking the others with KeepList and Keep. Lists that are not mentioned are removed.
+// The lists are read into copies, so the inventory is left as it was if the data is invalid.
+func (inv *Inventory) Deserialize(data string) error {
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	// Item metadata can make lines longer than the default limit
+	scanner.Buffer(nil, max(len(data), bufio.MaxScanTokenSize))
+	var lists []*List
+	for scanner.Scan() {
+		keyword, args, _ := strings.Cut(strings.TrimSpace(scanner.Text()), " ")
+		switch keyword {
+		case "EndInventory", "end":
+			inv.Lists = lists
+			return nil
+		case "List":
+			name, sizeArg, _ := strings.Cut(args, " ")
+			size, err := strconv.Atoi(strings.TrimSpace(sizeArg))
+			if err != nil || size < 0 {
+				return fmt.Errorf("list %q: invalid size %q", name, sizeArg)
+			}
+			l := NewList(name, size, 0)
+			if old := inv.List(name); old != nil {
+				for i := range min(size, len(old.Items)) {
+					l.Items[i] = old.Items[i].Clone()
+				}
+			}
+			if err := l.deserialize(scanner); err != nil {
+				return fmt.Errorf("list %q: %w", name, err)
+			}
+			lists = append(lists, l)
+		case "KeepList":
+			if l := inv.List(args); l != nil {
+				lists = append(lists, l)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("inventory ends without EndInventory")
+}
+
+// deserialize reads the slots of a list up to EndInventoryList. Like in Minetest, the slots after the last one
+// sent are emptied.
+func (l *List) deserialize(scanner *bufio.Scanner) error {
+	slot := 0
+	l.Width = 0
+	for scanner.Scan() {
+		keyword, args, _ := strings.Cut(strings.TrimSpace(scanner.Text()), " ")
+		switch keyword {
+		case "EndInventoryList", "end":
+			clear(l.Items[slot:])
+			return nil
+		case "Width":
+			width, err := strconv.Atoi(args)
+			if err != nil {
+				return fmt.Errorf("invalid width %q", args)
+			}
+			l.Width = width
+		case "Item", "Empty", "Keep":
+			if slot >= len(l.Items) {
+				return fmt.Errorf("more than %d items", len(l.Items))
+			}
+			switch keyword {
+			case "Item":
+				stack, err := ParseItemStack(args)
+				if err != nil {
+					return err
+				}
+				l.Items[slot] = stack
+			case "Empty":
+				l.Items[slot] = ItemStack{}
+			}
+			slot++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("list ends without EndInventoryList")
+}
+
+// Serialize writes every list of the inventory in its text form
+func (inv *Inventory) Serialize() string {
+	var b strings.Builder
+	for _, l := range inv.Lists {
+		fmt.Fprintf(&b, "List %s %d\n", l.Name, len(l.Items))
+		fmt.Fprintf(&b, "Width %d\n", l.Width)
+		for _, item := range l.Items {
+			if item.IsEmpty() {
+				b.WriteString("Empty\n")
+			} else {
+				b.WriteString("Item " + item.String() + "\n")
+			}
+		}
+		b.WriteString("EndInventoryList\n")
+	}
+	b.WriteString("EndInventory\n")
+	return b.String()
+}
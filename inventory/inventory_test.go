@@ -0,0 +1,187 @@
+package inventory
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// lines joins the lines of a serialized inventory
+func lines(l ...string) string {
+	return strings.Join(l, "\n") + "\n"
+}
+
+// names returns the item names of a list, "" for empty slots
+func names(l *List) []string {
+	var names []string
+	for _, item := range l.Items {
+		names = append(names, item.Name)
+	}
+	return names
+}
+
+// testInventory returns an inventory with a main list of four slots and a craft list of two
+func testInventory(t *testing.T) *Inventory {
+	t.Helper()
+	inv := New()
+	err := inv.Deserialize(lines(
+		"List main 4",
+		"Width 0",
+		"Item default:dirt 10",
+		"Empty",
+		"Item default:stone 99",
+		"Item default:pick 1 300",
+		"EndInventoryList",
+		"List craft 2",
+		"Width 2",
+		"Item default:wood 4",
+		"Item default:stick 8",
+		"EndInventoryList",
+		"EndInventory",
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return inv
+}
+
+func TestDeserialize(t *testing.T) {
+	inv := testInventory(t)
+	main, craft := inv.List("main"), inv.List("craft")
+	if main == nil || craft == nil || len(inv.Lists) != 2 {
+		t.Fatalf("lists %+v", inv.Lists)
+	}
+	if got := names(main); !reflect.DeepEqual(got, []string{"default:dirt", "", "default:stone", "default:pick"}) {
+		t.Errorf("main %q", got)
+	}
+	if main.Items[0].Count != 10 || main.Items[3].Wear != 300 {
+		t.Errorf("main %+v", main.Items)
+	}
+	if craft.Width != 2 || !reflect.DeepEqual(craft.Items[1], ItemStack{Name: "default:stick", Count: 8}) {
+		t.Errorf("craft %+v", craft)
+	}
+}
+
+func TestDeserializeKeep(t *testing.T) {
+	inv := testInventory(t)
+	// Only the second slot of main changed, craft did not change at all
+	err := inv.Deserialize(lines(
+		"List main 4",
+		"Width 0",
+		"Keep",
+		"Item default:torch 5",
+		"Keep",
+		"Keep",
+		"EndInventoryList",
+		"KeepList craft",
+		"EndInventory",
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := names(inv.List("main")); !reflect.DeepEqual(got, []string{"default:dirt", "default:torch", "default:stone", "default:pick"}) {
+		t.Errorf("main %q", got)
+	}
+	if craft := inv.List("craft"); craft == nil || !reflect.DeepEqual(names(craft), []string{"default:wood", "default:stick"}) || craft.Width != 2 {
+		t.Errorf("craft %+v", craft)
+	}
+}
+
+func TestDeserializeRemovesUnmentionedLists(t *testing.T) {
+	inv := testInventory(t)
+	err := inv.Deserialize(lines(
+		"KeepList main",
+		"KeepList missing", // Kept lists the inventory does not have are ignored
+		"EndInventory",
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inv.Lists) != 1 || inv.List("main") == nil || inv.List("craft") != nil {
+		t.Fatalf("lists %+v, want only main", inv.Lists)
+	}
+	if err := inv.Deserialize("EndInventory\n"); err != nil {
+		t.Fatal(err)
+	}
+	if len(inv.Lists) != 0 {
+		t.Fatalf("lists %+v, want none", inv.Lists)
+	}
+}
+
+func TestDeserializeShortList(t *testing.T) {
+	inv := testInventory(t)
+	// A list with fewer lines than slots empties the slots after the last line, kept or not
+	err := inv.Deserialize(lines(
+		"List main 4",
+		"Width 0",
+		"Keep",
+		"Item default:sand",
+		"EndInventoryList",
+		"KeepList craft",
+		"EndInventory",
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	main := inv.List("main")
+	if got := names(main); !reflect.DeepEqual(got, []string{"default:dirt", "default:sand", "", ""}) {
+		t.Errorf("main %q, want the last two slots emptied", got)
+	}
+
+	// Growing a list adds empty slots, shrinking drops the last ones
+	err = inv.Deserialize(lines("List main 6", "Keep", "EndInventoryList", "List craft 1", "Keep", "EndInventoryList", "EndInventory"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := names(inv.List("main")); !reflect.DeepEqual(got, []string{"default:dirt", "", "", "", "", ""}) {
+		t.Errorf("grown main %q", got)
+	}
+	if got := names(inv.List("craft")); !reflect.DeepEqual(got, []string{"default:wood"}) {
+		t.Errorf("shrunk craft %q", got)
+	}
+}
+
+func TestDeserializeErrorsKeepInventory(t *testing.T) {
+	for name, data := range map[string]string{
+		"bad item":       lines("List main 4", "Item default:dirt 10", "Item default:dirt lots", "EndInventoryList", "EndInventory"),
+		"too many items": lines("List main 1", "Item default:dirt", "Item default:dirt", "EndInventoryList", "EndInventory"),
+		"bad size":       lines("List main -1", "EndInventoryList", "EndInventory"),
+		"bad width":      lines("List main 4", "Width wide", "EndInventoryList", "EndInventory"),
+		"unended list":   lines("List main 4", "Empty", "Empty"),
+		"unended":        lines("List main 4", "Empty", "EndInventoryList"),
+	} {
+		inv := testInventory(t)
+		want := inv.Clone()
+		if err := inv.Deserialize(data); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+		if !reflect.DeepEqual(inv, want) {
+			t.Errorf("%s: inventory changed to %s", name, inv.Serialize())
+		}
+	}
+}
+
+func TestSerializeRoundTrip(t *testing.T) {
+	inv := testInventory(t)
+	inv.List("main").Items[1] = ItemStack{Name: "default:book", Count: 1, Meta: map[string]string{"title": "A \"good\" book"}}
+	parsed := New()
+	if err := parsed.Deserialize(inv.Serialize()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed, inv) {
+		t.Fatalf("got %s\nwant %s", parsed.Serialize(), inv.Serialize())
+	}
+}
+
+func TestHotbar(t *testing.T) {
+	inv := testInventory(t)
+	if got := inv.Hotbar(2); len(got) != 2 || got[0].Name != "default:dirt" {
+		t.Errorf("hotbar of 2: %+v", got)
+	}
+	if got := inv.Hotbar(8); len(got) != 4 {
+		t.Errorf("hotbar of 8 with 4 slots: %d slots", len(got))
+	}
+	if got := New().Hotbar(8); got != nil {
+		t.Errorf("hotbar without a main list: %+v", got)
+	}
+}
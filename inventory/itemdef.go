@@ -0,0 +1,252 @@
+package inventory
+
+import (
+	"fmt"
+	"image/color"
+	"sort"
+
+	"bettermt/main/blocktypes"
+	"bettermt/main/network"
+)
+
+// Version of the serialized item definition format
+const itemDefinitionVersion = 6
+
+// Version of the serialized tool capabilities, the newest one adds punch attack uses
+const toolCapabilitiesVersion = 5
+
+// ItemType tells what an item is used for
+type ItemType uint8
+
+const (
+	ItemTypeNone ItemType = iota
+	ItemTypeNode
+	ItemTypeCraft
+	ItemTypeTool
+)
+
+func (t ItemType) String() string {
+	switch t {
+	case ItemTypeNone:
+		return "none"
+	case ItemTypeNode:
+		return "node"
+	case ItemTypeCraft:
+		return "craft"
+	case ItemTypeTool:
+		return "tool"
+	}
+	return fmt.Sprintf("unknown(%d)", uint8(t))
+}
+
+// ToolGroupCap is how a tool digs the nodes of one group
+type ToolGroupCap struct {
+	Uses     int16
+	MaxLevel int16
+	Times    map[int16]float32 // Seconds to dig a node, by its rating in the group
+}
+
+// ToolCapabilities describe how a tool digs and punches
+type ToolCapabilities struct {
+	FullPunchInterval float32
+	MaxDropLevel      int16
+	GroupCaps         map[string]ToolGroupCap
+	DamageGroups      map[string]int16
+	PunchAttackUses   uint16
+}
+
+// ItemDefinition holds the properties of an item type
+type ItemDefinition struct {
+	Type             ItemType
+	Name             string
+	Description      string
+	InventoryImage   string
+	WieldImage       string
+	WieldScale       [3]float32
+	StackMax         int16
+	Usable           bool
+	LiquidsPointable bool
+	ToolCapabilities *ToolCapabilities // Nil for items that are not tools
+	Groups           map[string]int16
+
+	NodePlacementPrediction string
+	SoundPlace              blocktypes.SoundSpec
+	SoundPlaceFailed        blocktypes.SoundSpec
+	Range                   float32 // Nodes the item can point at, negative for the default
+	PaletteImage            string
+	Color                   color.NRGBA
+	InventoryOverlay        string
+	WieldOverlay            string
+	ShortDescription        string
+}
+
+// NewItemDefinition returns a definition with the defaults Minetest gives a registered item
+func NewItemDefinition(name string, itemType ItemType) *ItemDefinition {
+	return &ItemDefinition{
+		Type:       itemType,
+		Name:       name,
+		WieldScale: [3]float32{1, 1, 1},
+		StackMax:   99,
+		Groups:     make(map[string]int16),
+		Range:      -1,
+		Color:      color.NRGBA{R: 255, G: 255, B: 255, A: 255},
+	}
+}
+
+// DeserializeItemDefinition reads one item definition
+func DeserializeItemDefinition(r *network.Reader) (*ItemDefinition, error) {
+	if version := r.U8(); r.Err() == nil && version < itemDefinitionVersion {
+		return nil, fmt.Errorf("unsupported item definition version %d", version)
+	}
+
+	def := &ItemDefinition{Groups: make(map[string]int16)}
+	def.Type = ItemType(r.U8())
+	def.Name = r.String16()
+	def.Description = r.String16()
+	def.InventoryImage = r.String16()
+	def.WieldImage = r.String16()
+	def.WieldScale = r.V3F32()
+	def.StackMax = r.S16()
+	def.Usable = r.Bool()
+	def.LiquidsPointable = r.Bool()
+	if caps := r.String16(); r.Err() == nil && caps != "" {
+		toolCaps, err := deserializeToolCapabilities(network.NewReader([]byte(caps)))
+		if err != nil {
+			return nil, fmt.Errorf("tool capabilities: %w", err)
+		}
+		def.ToolCapabilities = toolCaps
+	}
+	groupCount := int(r.U16())
+	for i := 0; i < groupCount && r.Err() == nil; i++ {
+		name := r.String16()
+		def.Groups[name] = r.S16()
+	}
+	def.NodePlacementPrediction = r.String16()
+	def.SoundPlace = blocktypes.ReadSoundSpec(r)
+	def.SoundPlaceFailed = blocktypes.ReadSoundSpec(r)
+	def.Range = r.F32()
+	def.PaletteImage = r.String16()
+	a := r.U8()
+	def.Color = color.NRGBA{R: r.U8(), G: r.U8(), B: r.U8(), A: a}
+	def.InventoryOverlay = r.String16()
+	def.WieldOverlay = r.String16()
+	if r.Err() != nil {
+		return nil, r.Err()
+	}
+
+	// Newer attributes are only present if the server sends them, the ones after the short description are ignored
+	if r.Len() > 0 {
+		def.ShortDescription = r.String16()
+	}
+	return def, r.Err()
+}
+
+// Serialize writes the definition in the form of TOCLIENT_ITEMDEF
+func (def *ItemDefinition) Serialize(w *network.Writer) {
+	w.U8(itemDefinitionVersion)
+	w.U8(uint8(def.Type))
+	w.String16(def.Name)
+	w.String16(def.Description)
+	w.String16(def.InventoryImage)
+	w.String16(def.WieldImage)
+	w.V3F32(def.WieldScale)
+	w.S16(def.StackMax)
+	w.Bool(def.Usable)
+	w.Bool(def.LiquidsPointable)
+	var caps network.Writer
+	if def.ToolCapabilities != nil {
+		def.ToolCapabilities.serialize(&caps)
+	}
+	w.String16(string(caps.Bytes()))
+	w.U16(uint16(len(def.Groups)))
+	for _, name := range sortedKeys(def.Groups) {
+		w.String16(name)
+		w.S16(def.Groups[name])
+	}
+	w.String16(def.NodePlacementPrediction)
+	blocktypes.WriteSoundSpec(w, def.SoundPlace)
+	blocktypes.WriteSoundSpec(w, def.SoundPlaceFailed)
+	w.F32(def.Range)
+	w.String16(def.PaletteImage)
+	w.U8(def.Color.A).U8(def.Color.R).U8(def.Color.G).U8(def.Color.B)
+	w.String16(def.InventoryOverlay)
+	w.String16(def.WieldOverlay)
+	w.String16(def.ShortDescription)
+}
+
+func deserializeToolCapabilities(r *network.Reader) (*ToolCapabilities, error) {
+	version := r.U8()
+	if r.Err() == nil && version < 4 {
+		return nil, fmt.Errorf("unsupported tool capabilities version %d", version)
+	}
+	caps := &ToolCapabilities{
+		FullPunchInterval: r.F32(),
+		MaxDropLevel:      r.S16(),
+		GroupCaps:         make(map[string]ToolGroupCap),
+		DamageGroups:      make(map[string]int16),
+	}
+	groupCount := int(r.U32())
+	for i := 0; i < groupCount && r.Err() == nil; i++ {
+		name := r.String16()
+		cap := ToolGroupCap{Uses: r.S16(), MaxLevel: r.S16(), Times: make(map[int16]float32)}
+		timeCount := int(r.U32())
+		for j := 0; j < timeCount && r.Err() == nil; j++ {
+			rating := r.S16()
+			cap.Times[rating] = r.F32()
+		}
+		caps.GroupCaps[name] = cap
+	}
+	damageCount := int(r.U32())
+	for i := 0; i < damageCount && r.Err() == nil; i++ {
+		name := r.String16()
+		caps.DamageGroups[name] = r.S16()
+	}
+	if version >= 5 {
+		caps.PunchAttackUses = r.U16()
+	}
+	return caps, r.Err()
+}
+
+func (caps *ToolCapabilities) serialize(w *network.Writer) {
+	w.U8(toolCapabilitiesVersion)
+	w.F32(caps.FullPunchInterval)
+	w.S16(caps.MaxDropLevel)
+	groups := make([]string, 0, len(caps.GroupCaps))
+	for name := range caps.GroupCaps {
+		groups = append(groups, name)
+	}
+	sort.Strings(groups)
+	w.U32(uint32(len(groups)))
+	for _, name := range groups {
+		cap := caps.GroupCaps[name]
+		w.String16(name)
+		w.S16(cap.Uses)
+		w.S16(cap.MaxLevel)
+		ratings := make([]int16, 0, len(cap.Times))
+		for rating := range cap.Times {
+			ratings = append(ratings, rating)
+		}
+		sort.Slice(ratings, func(i, j int) bool { return ratings[i] < ratings[j] })
+		w.U32(uint32(len(ratings)))
+		for _, rating := range ratings {
+			w.S16(rating)
+			w.F32(cap.Times[rating])
+		}
+	}
+	w.U32(uint32(len(caps.DamageGroups)))
+	for _, name := range sortedKeys(caps.DamageGroups) {
+		w.String16(name)
+		w.S16(caps.DamageGroups[name])
+	}
+	w.U16(caps.PunchAttackUses)
+}
+
+// sortedKeys returns the keys of a group map in a stable order
+func sortedKeys(groups map[string]int16) []string {
+	keys := make([]string, 0, len(groups))
+	for name := range groups {
+		keys = append(keys, name)
+	}
+	sort.Strings(keys)
+	return keys
+}
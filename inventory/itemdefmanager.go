@@ -0,0 +1,204 @@
+package inventory
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"sort"
+
+	"bettermt/main/blocktypes"
+	"bettermt/main/network"
+)
+
+// Name of the item used when nothing is wielded
+const HandItem = ""
+
+// Name of the item standing in for items without a definition
+const UnknownItem = "unknown"
+
+// Version of the serialized item definition list
+const itemDefListVersion = 0
+
+// Longest alias chain followed before giving up
+const maxAliasDepth = 16
+
+// ItemDefManager maps item names to item definitions
+type ItemDefManager struct {
+	defs    map[string]*ItemDefinition
+	aliases map[string]string
+}
+
+// NewItemDefManager creates a registry holding only the hand and the unknown item
+func NewItemDefManager() *ItemDefManager {
+	m := &ItemDefManager{
+		defs:    make(map[string]*ItemDefinition),
+		aliases: make(map[string]string),
+	}
+
+	hand := NewItemDefinition(HandItem, ItemTypeNone)
+	hand.WieldImage = "wieldhand.png"
+	hand.ToolCapabilities = &ToolCapabilities{
+		FullPunchInterval: 0.9,
+		GroupCaps: map[string]ToolGroupCap{
+			"crumbly": {MaxLevel: 1, Times: map[int16]float32{1: 2, 2: 1, 3: 0.5}},
+			"snappy":  {MaxLevel: 1, Times: map[int16]float32{3: 0.4}},
+			"oddly_breakable_by_hand": {
+				Times: map[int16]float32{1: 3.5, 2: 2, 3: 0.7},
+			},
+		},
+		DamageGroups: map[string]int16{"fleshy": 1},
+	}
+	m.Set(hand)
+
+	unknown := NewItemDefinition(UnknownItem, ItemTypeNone)
+	unknown.InventoryImage = "unknown_item.png"
+	m.Set(unknown)
+	return m
+}
+
+// DefaultItemDefManager creates a registry with an item for every node of nodeDefs except the reserved ones
+func DefaultItemDefManager(nodeDefs *blocktypes.NodeDefManager) *ItemDefManager {
+	m := NewItemDefManager()
+	for _, id := range nodeDefs.IDs() {
+		if id == blocktypes.ContentUnknown || id == blocktypes.ContentAir || id == blocktypes.ContentIgnore {
+			continue
+		}
+		node := nodeDefs.Get(id)
+		def := NewItemDefinition(node.Name, ItemTypeNode)
+		def.Description = node.Name
+		def.NodePlacementPrediction = node.Name
+		for group, rating := range node.Groups {
+			def.Groups[group] = rating
+		}
+		m.Set(def)
+	}
+
+	// Let the hand dig every node of the built in world
+	hand := m.Get(HandItem)
+	hand.ToolCapabilities.GroupCaps["cracky"] = ToolGroupCap{MaxLevel: 1, Times: map[int16]float32{3: 3}}
+	return m
+}
+
+// Set registers a definition under its name, replacing any previous one
+func (m *ItemDefManager) Set(def *ItemDefinition) {
+	m.defs[def.Name] = def
+}
+
+// SetAlias makes name refer to the item convertTo
+func (m *ItemDefManager) SetAlias(name, convertTo string) {
+	m.aliases[name] = convertTo
+}
+
+// Resolve follows the aliases of an item name to the name it is registered under
+func (m *ItemDefManager) Resolve(name string) string {
+	for i := 0; i < maxAliasDepth; i++ {
+		convertTo, exists := m.aliases[name]
+		if !exists {
+			break
+		}
+		name = convertTo
+	}
+	return name
+}
+
+// Get returns the definition of an item, or the unknown item if there is none
+func (m *ItemDefManager) Get(name string) *ItemDefinition {
+	if def, exists := m.Lookup(name); exists {
+		return def
+	}
+	return m.defs[UnknownItem]
+}
+
+// Lookup returns the definition of an item, following aliases, and whether it is registered
+func (m *ItemDefManager) Lookup(name string) (*ItemDefinition, bool) {
+	def, exists := m.defs[m.Resolve(name)]
+	return def, exists
+}
+
+// Names returns the name of every registered item in ascending order
+func (m *ItemDefManager) Names() []string {
+	names := make([]string, 0, len(m.defs))
+	for name := range m.defs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Aliases returns a copy of the registered aliases
+func (m *ItemDefManager) Aliases() map[string]string {
+	aliases := make(map[string]string, len(m.aliases))
+	for name, convertTo := range m.aliases {
+		aliases[name] = convertTo
+	}
+	return aliases
+}
+
+// Deserialize adds the zlib compressed definitions sent in TOCLIENT_ITEMDEF to the registry
+func (m *ItemDefManager) Deserialize(compressed []byte) error {
+	zr, err := zlib.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return err
+	}
+
+	r := network.NewReader(data)
+	if version := r.U8(); r.Err() == nil && version != itemDefListVersion {
+		return fmt.Errorf("unsupported item definition list version %d", version)
+	}
+	count := int(r.U16())
+	for i := 0; i < count; i++ {
+		entry := network.NewReader([]byte(r.String16()))
+		if r.Err() != nil {
+			return r.Err()
+		}
+		def, err := DeserializeItemDefinition(entry)
+		if err != nil {
+			return fmt.Errorf("item %d: %w", i, err)
+		}
+		m.Set(def)
+	}
+	aliasCount := int(r.U16())
+	for i := 0; i < aliasCount && r.Err() == nil; i++ {
+		name := r.String16()
+		m.SetAlias(name, r.String16())
+	}
+	return r.Err()
+}
+
+// Serialize writes the definitions and aliases in the zlib compressed form of TOCLIENT_ITEMDEF
+func (m *ItemDefManager) Serialize() ([]byte, error) {
+	var w network.Writer
+	w.U8(itemDefListVersion)
+	w.U16(uint16(len(m.defs)))
+	for _, name := range m.Names() {
+		var entry network.Writer
+		m.defs[name].Serialize(&entry)
+		w.String16(string(entry.Bytes()))
+	}
+
+	aliases := make([]string, 0, len(m.aliases))
+	for name := range m.aliases {
+		aliases = append(aliases, name)
+	}
+	sort.Strings(aliases)
+	w.U16(uint16(len(aliases)))
+	for _, name := range aliases {
+		w.String16(name)
+		w.String16(m.aliases[name])
+	}
+
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	if _, err := zw.Write(w.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,248 @@
+package inventory
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Separators of the serialized item metadata
+const (
+	metadataStart     = '\x01'
+	metadataSeparator = '\x02'
+	metadataEnd       = '\x03'
+)
+
+// Largest wear a tool takes before it breaks
+const MaxWear = 65535
+
+// ItemStack is a number of items of one kind in an inventory slot. The zero value is an empty slot.
+type ItemStack struct {
+	Name  string
+	Count uint16
+	Wear  uint16
+	Meta  map[string]string
+}
+
+// IsEmpty reports whether the slot holds nothing
+func (s ItemStack) IsEmpty() bool {
+	return s.Name == "" || s.Count == 0
+}
+
+// ParseItemStack decodes an item string such as `default:dirt 20` or `default:pick_stone 1 4096 "\u0001..."`
+func ParseItemStack(s string) (ItemStack, error) {
+	rest := strings.TrimSpace(s)
+	name, rest, err := nextField(rest)
+	if err != nil {
+		return ItemStack{}, fmt.Errorf("item %q: %w", s, err)
+	}
+	stack := ItemStack{Name: name, Count: 1}
+	if name == "" {
+		return ItemStack{}, nil
+	}
+
+	var count, wear string
+	count, rest, _ = nextField(rest)
+	if count != "" {
+		n, err := strconv.ParseUint(count, 10, 16)
+		if err != nil {
+			return ItemStack{}, fmt.Errorf("item %q: count: %w", s, err)
+		}
+		stack.Count = uint16(n)
+	}
+	wear, rest, _ = nextField(rest)
+	if wear != "" {
+		n, err := strconv.ParseUint(wear, 10, 16)
+		if err != nil {
+			return ItemStack{}, fmt.Errorf("item %q: wear: %w", s, err)
+		}
+		stack.Wear = uint16(n)
+	}
+	if rest != "" {
+		meta, _, err := nextField(rest)
+		if err != nil {
+			return ItemStack{}, fmt.Errorf("item %q: metadata: %w", s, err)
+		}
+		stack.Meta = parseMetadata(meta)
+	}
+	if stack.Count == 0 {
+		return ItemStack{}, nil
+	}
+	return stack, nil
+}
+
+// String encodes the stack as an item string, leaving out the parts that hold their default
+func (s ItemStack) String() string {
+	if s.IsEmpty() {
+		return ""
+	}
+	parts := 1
+	switch {
+	case len(s.Meta) > 0:
+		parts = 4
+	case s.Wear != 0:
+		parts = 3
+	case s.Count != 1:
+		parts = 2
+	}
+
+	var b strings.Builder
+	b.WriteString(quoteIfNeeded(s.Name))
+	if parts >= 2 {
+		fmt.Fprintf(&b, " %d", s.Count)
+	}
+	if parts >= 3 {
+		fmt.Fprintf(&b, " %d", s.Wear)
+	}
+	if parts >= 4 {
+		b.WriteByte(' ')
+		b.WriteString(quoteIfNeeded(serializeMetadata(s.Meta)))
+	}
+	return b.String()
+}
+
+// Clone returns a copy of the stack that does not share its metadata
+func (s ItemStack) Clone() ItemStack {
+	if s.Meta != nil {
+		meta := make(map[string]string, len(s.Meta))
+		for key, value := range s.Meta {
+			meta[key] = value
+		}
+		s.Meta = meta
+	}
+	return s
+}
+
+func parseMetadata(s string) map[string]string {
+	meta := make(map[string]string)
+	if s == "" || s[0] != metadataStart {
+		// Metadata of old servers is a plain string
+		if s != "" {
+			meta[""] = s
+		}
+		return meta
+	}
+	for _, pair := range strings.Split(s[1:], string(metadataEnd)) {
+		if key, value, found := strings.Cut(pair, string(metadataSeparator)); found {
+			meta[key] = value
+		}
+	}
+	return meta
+}
+
+func serializeMetadata(meta map[string]string) string {
+	keys := make([]string, 0, len(meta))
+	for key := range meta {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteByte(metadataStart)
+	for _, key := range keys {
+		b.WriteString(key)
+		b.WriteByte(metadataSeparator)
+		b.WriteString(meta[key])
+		b.WriteByte(metadataEnd)
+	}
+	return b.String()
+}
+
+// nextField splits off the first space separated field, which may be a JSON string in quotes
+func nextField(s string) (field, rest string, err error) {
+	s = strings.TrimLeft(s, " ")
+	if strings.HasPrefix(s, `"`) {
+		return unquote(s)
+	}
+	field, rest, _ = strings.Cut(s, " ")
+	return field, strings.TrimLeft(rest, " "), nil
+}
+
+// quoteIfNeeded writes s as a JSON string when it would not survive being split at spaces, as Minetest does
+func quoteIfNeeded(s string) string {
+	needsQuotes := s == ""
+	for i := 0; i < len(s) && !needsQuotes; i++ {
+		needsQuotes = s[i] <= ' ' || s[i] >= 0x7f || s[i] == '"'
+	}
+	if !needsQuotes {
+		return s
+	}
+
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if c < ' ' || c >= 0x7f {
+				fmt.Fprintf(&b, `\u%04x`, c)
+			} else {
+				b.WriteByte(c)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// unquote reads the JSON string at the start of s. Like Minetest, \u escapes stand for single bytes.
+func unquote(s string) (value, rest string, err error) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"':
+			return b.String(), strings.TrimLeft(s[i+1:], " "), nil
+		case c != '\\':
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		if i >= len(s) {
+			break
+		}
+		switch s[i] {
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'u':
+			if i+4 >= len(s) {
+				return "", "", fmt.Errorf("truncated escape in %q", s)
+			}
+			code, err := strconv.ParseUint(s[i+1:i+5], 16, 16)
+			if err != nil {
+				return "", "", fmt.Errorf("invalid escape in %q", s)
+			}
+			if code < utf8.RuneSelf*2 {
+				b.WriteByte(byte(code))
+			} else {
+				b.WriteRune(rune(code))
+			}
+			i += 4
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return "", "", fmt.Errorf("unterminated string %q", s)
+}
@@ -0,0 +1,119 @@
+package inventory
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseItemStack(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want ItemStack
+	}{
+		{"", ItemStack{}},
+		{"default:dirt", ItemStack{Name: "default:dirt", Count: 1}},
+		{"default:dirt 20", ItemStack{Name: "default:dirt", Count: 20}},
+		{"  default:dirt   20  ", ItemStack{Name: "default:dirt", Count: 20}},
+		{"default:dirt 0", ItemStack{}}, // No items is an empty slot
+		{"default:pick_stone 1 4096", ItemStack{Name: "default:pick_stone", Count: 1, Wear: 4096}},
+		{"default:pick_stone 1 65535", ItemStack{Name: "default:pick_stone", Count: 1, Wear: MaxWear}},
+		{`"default:dirt" 5`, ItemStack{Name: "default:dirt", Count: 5}},
+		{`"odd \"name\"" 2`, ItemStack{Name: `odd "name"`, Count: 2}},
+		{`"tab\there" 3`, ItemStack{Name: "tab\there", Count: 3}},
+		{
+			`default:book 1 0 "\u0001title\u0002My book\u0003owner\u0002Alice\u0003"`,
+			ItemStack{Name: "default:book", Count: 1, Meta: map[string]string{"title": "My book", "owner": "Alice"}},
+		},
+		{
+			"default:book 1 0 \"\\u0001\\u0003\"",
+			ItemStack{Name: "default:book", Count: 1, Meta: map[string]string{}},
+		},
+		{
+			// Metadata of old servers is a plain string
+			`default:sign 1 0 "hello"`,
+			ItemStack{Name: "default:sign", Count: 1, Meta: map[string]string{"": "hello"}},
+		},
+		{
+			// \u escapes are single bytes, so UTF-8 comes through byte by byte
+			`default:book 1 0 "\u0001t\u0002\u00e2\u0098\u0083\u0003"`,
+			ItemStack{Name: "default:book", Count: 1, Meta: map[string]string{"t": "☃"}},
+		},
+	} {
+		got, err := ParseItemStack(test.in)
+		if err != nil {
+			t.Errorf("%q: %v", test.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: got %+v, want %+v", test.in, got, test.want)
+		}
+	}
+}
+
+func TestParseItemStackErrors(t *testing.T) {
+	for _, in := range []string{
+		"default:dirt many",
+		"default:dirt 65536",
+		"default:dirt -1",
+		"default:pick 1 70000",
+		`"default:dirt`,
+		`default:book 1 0 "\u00`,
+		`default:book 1 0 "\uzzzz"`,
+	} {
+		if got, err := ParseItemStack(in); err == nil {
+			t.Errorf("%q: got %+v, want an error", in, got)
+		}
+	}
+}
+
+func TestItemStackString(t *testing.T) {
+	for _, test := range []struct {
+		stack ItemStack
+		want  string
+	}{
+		{ItemStack{}, ""},
+		{ItemStack{Name: "default:dirt", Count: 0}, ""},
+		{ItemStack{Name: "default:dirt", Count: 1}, "default:dirt"},
+		{ItemStack{Name: "default:dirt", Count: 99}, "default:dirt 99"},
+		{ItemStack{Name: "default:pick", Count: 1, Wear: 10}, "default:pick 1 10"},
+		{ItemStack{Name: "with space", Count: 2}, `"with space" 2`},
+		{ItemStack{Name: `q"uote`, Count: 1}, `"q\"uote"`},
+		{
+			ItemStack{Name: "default:book", Count: 1, Meta: map[string]string{"b": "2", "a": "x y"}},
+			`default:book 1 0 "\u0001a\u0002x y\u0003b\u00022\u0003"`,
+		},
+	} {
+		if got := test.stack.String(); got != test.want {
+			t.Errorf("%+v: got %q, want %q", test.stack, got, test.want)
+		}
+	}
+}
+
+func TestItemStackRoundTrip(t *testing.T) {
+	for _, stack := range []ItemStack{
+		{Name: "default:dirt", Count: 1},
+		{Name: "default:dirt", Count: 65535},
+		{Name: "default:pick", Count: 1, Wear: 1},
+		{Name: "name with \"quotes\" and \\ slashes\n", Count: 3},
+		{Name: "default:book", Count: 2, Wear: 7, Meta: map[string]string{
+			"title": "Ünïcødé ☃", "empty": "", "text": "line 1\nline 2\t\"quoted\"",
+		}},
+	} {
+		got, err := ParseItemStack(stack.String())
+		if err != nil {
+			t.Fatalf("%q: %v", stack.String(), err)
+		}
+		if !reflect.DeepEqual(got, stack) {
+			t.Errorf("%q: got %+v, want %+v", stack.String(), got, stack)
+		}
+	}
+}
+
+func TestItemStackClone(t *testing.T) {
+	stack := ItemStack{Name: "default:book", Count: 1, Meta: map[string]string{"title": "a"}}
+	clone := stack.Clone()
+	clone.Meta["title"] = "b"
+	if stack.Meta["title"] != "a" {
+		t.Fatal("clone shares its metadata")
+	}
+}
@@ -0,0 +1,90 @@
+package inventory
+
+import (
+	"math"
+	"sort"
+)
+
+// Distance in nodes items reach when neither they nor the hand set one
+const DefaultRange = 4
+
+// DigParams tell whether and how fast a tool digs a node
+type DigParams struct {
+	Diggable bool
+	Time     float32 // Seconds
+	Wear     uint16  // Wear the tool takes per node, as far as the client can tell
+	Group    string  // Group of the node the time comes from
+}
+
+// GetDigParams computes how a tool with caps digs a node in groups, the way Minetest does:
+// the fastest group the tool can dig at the level of the node wins.
+func GetDigParams(groups map[string]int16, caps *ToolCapabilities) DigParams {
+	if caps == nil {
+		return DigParams{}
+	}
+	// Nodes in dig_immediate take a fixed time unless the tool says otherwise
+	if _, exists := caps.GroupCaps["dig_immediate"]; !exists {
+		switch groups["dig_immediate"] {
+		case 2:
+			return DigParams{Diggable: true, Time: 0.5, Group: "dig_immediate"}
+		case 3:
+			return DigParams{Diggable: true, Time: 0, Group: "dig_immediate"}
+		}
+	}
+
+	var result DigParams
+	level := groups["level"]
+	for _, group := range sortedGroupCaps(caps.GroupCaps) {
+		cap := caps.GroupCaps[group]
+		levelDiff := cap.MaxLevel - level
+		if levelDiff < 0 {
+			continue
+		}
+		time, exists := cap.Times[groups[group]]
+		if !exists {
+			continue
+		}
+		if levelDiff > 1 {
+			time /= float32(levelDiff)
+		}
+		if result.Diggable && time >= result.Time {
+			continue
+		}
+		result = DigParams{Diggable: true, Time: time, Group: group}
+		if cap.Uses != 0 {
+			wear := float64(MaxWear) / float64(cap.Uses) / math.Pow(3, float64(levelDiff))
+			result.Wear = uint16(min(wear, MaxWear))
+		}
+	}
+	return result
+}
+
+// sortedGroupCaps returns the groups of a tool in a stable order, so ties are broken the same way every time
+func sortedGroupCaps(caps map[string]ToolGroupCap) []string {
+	groups := make([]string, 0, len(caps))
+	for group := range caps {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	return groups
+}
+
+// ToolCapabilities returns the capabilities an item digs with, which are the ones of the hand
+// for items that are not tools
+func (m *ItemDefManager) ToolCapabilities(item string) *ToolCapabilities {
+	if caps := m.Get(item).ToolCapabilities; caps != nil {
+		return caps
+	}
+	return m.Get(HandItem).ToolCapabilities
+}
+
+// Range returns how far an item reaches in nodes, falling back to the hand and then to the default of Minetest
+func (m *ItemDefManager) Range(item string) float32 {
+	if r := m.Get(item).Range; r >= 0 {
+		return r
+	}
+	if r := m.Get(HandItem).Range; r >= 0 {
+		return r
+	}
+	return DefaultRange
+}
@@ -1,30 +1,62 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"time"
 
 	"bettermt/main/blocktypes"
+	"bettermt/main/capture"
+	"bettermt/main/client"
 	"bettermt/main/config"
+	"bettermt/main/interact"
+	"bettermt/main/media"
 	"bettermt/main/meshbuilder"
+	"bettermt/main/network"
+	"bettermt/main/player"
+	"bettermt/main/server"
+	"bettermt/main/sound"
+	"bettermt/main/ui"
 	"bettermt/main/util"
 
 	"github.com/g3n/engine/app"
 	"github.com/g3n/engine/camera"
 	"github.com/g3n/engine/core"
 	"github.com/g3n/engine/gls"
-	"github.com/g3n/engine/graphic"
 	"github.com/g3n/engine/gui"
-	"github.com/g3n/engine/light"
-	"github.com/g3n/engine/math32"
 	"github.com/g3n/engine/renderer"
 	"github.com/g3n/engine/util/helper"
 	"github.com/g3n/engine/window"
 )
 
 func main() {
+	// Subcommands that run tools instead of joining a game
+	if len(os.Args) > 1 {
+		subcommands := map[string]func(args []string) error{
+			"dissect": runDissect,
+			"proxy":   runProxy,
+		}
+		if run, exists := subcommands[os.Args[1]]; exists {
+			if err := run(os.Args[2:]); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	// Command line flags override the config file
+	serverFlag := flag.String("server", "", "Minetest server to join (host:port)")
+	nameFlag := flag.String("name", "", "Player name")
+	passwordFlag := flag.String("password", "", "Password, new accounts are registered with it")
+	headlessFlag := flag.Bool("headless", false, "Run without a window, relaying chat between the server and the terminal")
+	durationFlag := flag.Duration("duration", 0, "Disconnect after this long in headless mode, 0 to stay until disconnected")
+	recordFlag := flag.String("record", "", "Write every packet sent and received to a capture file")
+	flag.Parse()
+
 	// Get basic directories for file loading
 
 	ex, err := os.Executable()
@@ -43,6 +75,76 @@ func main() {
 	logLevel := config.GetOrDefault("log_level", "info") // Default to "info" if not found
 	fmt.Printf("Log Level: %s\n", logLevel)
 
+	serverAddress := config.GetOrDefault("server_address", "")
+	if *serverFlag != "" {
+		serverAddress = *serverFlag
+	}
+	playerName := config.GetOrDefault("player_name", "singleplayer")
+	if *nameFlag != "" {
+		playerName = *nameFlag
+	}
+
+	// Create the world blocks are loaded into
+	world := meshbuilder.NewWorld(128) // Set world size to 160
+
+	// Textures shipped with the game, server media takes precedence once it is loaded
+	var textures fs.FS = os.DirFS(parentDir + "/textures")
+
+	// Singleplayer runs a server in this process and joins it like any other
+	if serverAddress == "" {
+		srv, err := server.New(server.Config{
+			Media:     textures,
+			TimeSpeed: config.GetFloatOrDefault("time_speed", server.DefaultTimeSpeed),
+			Movement:  movementSettings(config),
+		})
+		if err != nil {
+			panic(err)
+		}
+		if err := srv.Listen("127.0.0.1:0"); err != nil {
+			panic(err)
+		}
+		defer srv.Close()
+		serverAddress = srv.Addr().String()
+	}
+
+	// Join the server before opening the window so login errors are reported right away
+	cl := client.New(playerName, *passwordFlag, world)
+	cl.BlockLimit = config.GetIntOrDefault("client_mapblock_limit", client.DefaultBlockLimit)
+	cl.Player.SetViewRange(float32(config.GetIntOrDefault("viewing_range", player.DefaultViewRange)))
+	cacheDir := config.GetOrDefault("media_cache_dir", "")
+	if cacheDir == "" {
+		cacheDir = parentDir + "/cache/media"
+	}
+	cache, err := media.NewCache(cacheDir)
+	if err != nil {
+		panic(err)
+	}
+	cl.Media = media.NewManager(cache, textures)
+	textures = cl.Media
+	if !*headlessFlag {
+		// Sounds are only mixed along with the frames of the window
+		sounds, err := newSoundManager(config, cl)
+		if err != nil {
+			panic(err)
+		}
+		cl.Sounds = sounds
+		defer sounds.Close()
+	}
+	fmt.Printf("Connecting to %s as %s\n", serverAddress, playerName)
+	if err := joinServer(cl, serverAddress, *recordFlag); err != nil {
+		fmt.Printf("Failed to join %s: %v\n", serverAddress, err)
+		os.Exit(1)
+	}
+	defer cl.Close()
+
+	if *headlessFlag {
+		if err := runHeadless(cl, world, os.Stdin, *durationFlag); err != nil {
+			fmt.Printf("Disconnected: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Create application and scene
 	var a *app.Application = app.App()
 	var scene *core.Node = core.NewNode()
@@ -51,11 +153,12 @@ func main() {
 	// Set the scene to be managed by the gui manager
 	gui.Manager().Set(scene)
 
-	// Create perspective camera and add to scene
+	// Create perspective camera at the eyes of the player and add to scene
 	cam := camera.New(1)
-	cam.SetPosition(0, 0, 3)
 	scene.Add(cam)
-	camera.NewOrbitControl(cam)
+	playerControl := ui.NewPlayerControl(cl.Player, world, cam)
+	interaction := interact.NewController(cl, world)
+	footsteps := interact.NewFootsteps(cl, world)
 
 	// Set up callback to update viewport and camera aspect ratio when the window is resized
 	var onResize (func(evname string, ev interface{})) = func(evname string, ev interface{}) {
@@ -69,46 +172,104 @@ func main() {
 	a.Subscribe(window.OnWindowSize, onResize)
 	onResize("", nil)
 
-	a.Gls().ClearColor(0.5, 0.5, 0.7, 1)
-
-	// Point lights for testing
-
-	// Create vertical point light
-	vl := util.NewPointLightMesh(&math32.Color{R: 1, G: 1, B: 1}, 10000.0)
-	vl.SetPosition(100, 100, 100)
-	scene.Add(vl.Mesh)
-
-	// Create and add lights to the scene
-	scene.Add(light.NewAmbient(&math32.Color{R: 1.0, G: 1.0, B: 1.0}, 0.8))
-
-	skybox, err := graphic.NewSkybox(graphic.SkyboxData{DirAndPrefix: parentDir + "/textures/", Extension: "jpg", Suffixes: [6]string{"east", "west", "top", "bottom", "north", "south"}})
+	// Create the sky, which also lights the scene for the time of day
+	skyView, err := util.NewSky(cl.Sky, textures, [6]string{"east.jpg", "west.jpg", "top.jpg", "bottom.jpg", "north.jpg", "south.jpg"})
 	if err != nil {
 		panic(err)
 	}
-	scene.Add(skybox)
+	scene.Add(skyView)
 
 	// Create and add an axis helper to the scene
 	scene.Add(helper.NewAxes(1))
-	blocktypes.InitializeBlockMaterials(parentDir)
+	blocktypes.SetTextureSource(textures)
+	if cl.NodeDefs() != nil {
+		// Draw blocks with the nodes the server defined
+		blocktypes.SetNodeDefManager(cl.NodeDefs())
+	}
+
+	// Draw the active objects of the server with their nametags
+	objectView := ui.NewObjects(cl, cam, textures)
+	scene.Add(objectView)
 
-	// Create a text label for displaying FPS
-	fpsLabel := gui.NewLabel("")
-	fpsLabel.SetPosition(10, 10) // Position in window
-	fpsLabel.SetFontSize(20)     // Font size
-	scene.Add(fpsLabel)          // Add to GUI manager
+	// Draw the particles of the server and of the nodes being dug
+	particleView := ui.NewParticles(cl, cam, textures, a.Renderer())
+	scene.Add(particleView)
+
+	// Create the HUD showing the elements of the server, which also displays the FPS
+	hudView := ui.NewHUD(cl, world, cam, textures)
+	hudView.SetScaling(config.GetFloatOrDefault("hud_scaling", 1))
+	scene.Add(hudView)
+	resizeHUD := func(evname string, ev interface{}) {
+		width, height := a.GetSize()
+		objectView.Resize(float32(width), float32(height))
+		hudView.Resize(float32(width), float32(height))
+	}
+	a.Subscribe(window.OnWindowSize, resizeHUD)
+	resizeHUD("", nil)
+
+	// Create the chat console in the bottom left corner
+	chatConsole := ui.NewChatConsole(cl.Chat, cl.SendChatMessage)
+	scene.Add(chatConsole)
+	placeChat := func(evname string, ev interface{}) {
+		_, height := a.GetSize()
+		chatConsole.SetPosition(10, float32(height)-chatConsole.Height()-10)
+	}
+	a.Subscribe(window.OnWindowSize, placeChat)
+	placeChat("", nil)
+
+	// Create the view showing the formspecs of the server above everything else
+	formspecView := ui.NewFormspecView(cl, textures)
+	formspecView.SetScaling(config.GetFloatOrDefault("gui_scaling", 1))
+	scene.Add(formspecView)
+	resizeFormspec := func(evname string, ev interface{}) {
+		width, height := a.GetSize()
+		formspecView.Resize(float32(width), float32(height))
+	}
+	a.Subscribe(window.OnWindowSize, resizeFormspec)
+	resizeFormspec("", nil)
+	inventoryControl := ui.NewInventoryControl(cl, formspecView)
 
 	// Initialize variables for tracking time and FPS
 	var lastTime time.Time = time.Now()
 	var frameCount int = 0
 
 	// Create and render the chunk mesh
-	world := meshbuilder.NewWorld(128) // Set world size to 160
-	world.GenerateChunks()
 	world.Render(scene)
 
 	fmt.Println("Number of faces", meshbuilder.NumFaces)
 	// Run the application and update FPS label each frame
 	a.Run(func(renderer *renderer.Renderer, deltaTime time.Duration) {
+		// Mesh blocks that arrived from the server
+		world.Update(scene)
+		chatConsole.Update()
+		if err := formspecView.Update(); err != nil {
+			fmt.Println("Formspec failed:", err)
+		}
+		playerControl.Enabled = !chatConsole.IsOpen() && !formspecView.IsOpen()
+		inventoryControl.Enabled = playerControl.Enabled
+		playerControl.Update(float32(deltaTime.Seconds()))
+		if err := footsteps.Update(); err != nil {
+			fmt.Println("Sound failed:", err)
+		}
+		objectView.Update(float32(deltaTime.Seconds()))
+		hudView.Update(float32(deltaTime.Seconds()))
+		if err := interaction.Update(float32(deltaTime.Seconds())); err != nil {
+			fmt.Println("Interaction failed:", err)
+		}
+		particleView.Update(float32(deltaTime.Seconds()))
+
+		// Hear the world from the camera, with the right ear where the right of the screen is
+		m := cam.MatrixWorld()
+		cl.Sounds.SetListener(cl.Player.EyePosition(), [3]float32{m[0], m[1], m[2]})
+		if err := cl.Sounds.Step(float32(deltaTime.Seconds())); err != nil {
+			fmt.Println("Sound failed:", err)
+		}
+
+		if err := skyView.Update(); err != nil {
+			fmt.Println("Sky failed:", err)
+		}
+		bg := skyView.ClearColor()
+		a.Gls().ClearColor(bg.R, bg.G, bg.B, 1)
 		a.Gls().Clear(gls.DEPTH_BUFFER_BIT | gls.STENCIL_BUFFER_BIT | gls.COLOR_BUFFER_BIT)
 		renderer.Render(scene, cam)
 
@@ -119,9 +280,77 @@ func main() {
 
 		if elapsed >= 1.0 {
 			fps := float64(frameCount) / elapsed
-			fpsLabel.SetText(fmt.Sprintf("FPS: %.2f", fps)) // Update FPS text
+			hudView.SetDebugText(fmt.Sprintf("FPS: %.2f", fps)) // Update FPS text
 			lastTime = currentTime
 			frameCount = 0
 		}
 	})
 }
+
+// joinServer connects the client, recording the session to a capture file if one is given
+func joinServer(cl *client.Client, address string, capturePath string) error {
+	if capturePath == "" {
+		return cl.Connect(address, 30*time.Second)
+	}
+	file, err := os.Create(capturePath)
+	if err != nil {
+		return err
+	}
+	w, err := capture.NewWriter(file)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	conn, err := network.Dial(address)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	fmt.Printf("Recording packets to %s\n", capturePath)
+	go func() {
+		<-conn.Done()
+		file.Close()
+	}()
+	return cl.Join(capture.NewRecorder(conn, w), 30*time.Second)
+}
+
+// newSoundManager creates the sound manager of a client, recording into the WAV file of the sound_output
+// setting if there is one
+func newSoundManager(cfg *config.Config, cl *client.Client) (*sound.Manager, error) {
+	var output sound.Output = sound.NewNullOutput(sound.DefaultSampleRate)
+	if path := cfg.GetOrDefault("sound_output", ""); path != "" {
+		file, err := os.Create(path)
+		if err != nil {
+			return nil, err
+		}
+		if output, err = sound.NewWAVOutput(file, sound.DefaultSampleRate); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	sounds := sound.NewManager(cl.Media, cl.Objects, output)
+	sounds.SetVolume(cfg.GetFloatOrDefault("sound_volume", 1))
+	return sounds, nil
+}
+
+// movementSettings reads the movement settings the built in server sends to clients
+func movementSettings(cfg *config.Config) player.MovementSettings {
+	m := player.DefaultMovementSettings()
+	for key, value := range map[string]*float32{
+		"movement_acceleration_default":   &m.AccelerationDefault,
+		"movement_acceleration_air":       &m.AccelerationAir,
+		"movement_acceleration_fast":      &m.AccelerationFast,
+		"movement_speed_walk":             &m.SpeedWalk,
+		"movement_speed_crouch":           &m.SpeedCrouch,
+		"movement_speed_fast":             &m.SpeedFast,
+		"movement_speed_climb":            &m.SpeedClimb,
+		"movement_speed_jump":             &m.SpeedJump,
+		"movement_liquid_fluidity":        &m.LiquidFluidity,
+		"movement_liquid_fluidity_smooth": &m.LiquidFluiditySmooth,
+		"movement_liquid_sink":            &m.LiquidSink,
+		"movement_gravity":                &m.Gravity,
+	} {
+		*value = cfg.GetFloatOrDefault(key, *value)
+	}
+	return m
+}
@@ -0,0 +1,82 @@
+package media
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+var ErrHashMismatch = errors.New("media hash mismatch")
+
+// Cache stores media files on disk named after the hex encoded SHA1 of their content
+type Cache struct {
+	Dir string
+}
+
+// NewCache creates the cache directory if needed
+func NewCache(dir string) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Cache{Dir: dir}, nil
+}
+
+// Path returns where the file with the given hash is stored
+func (c *Cache) Path(sha []byte) string {
+	return filepath.Join(c.Dir, hex.EncodeToString(sha))
+}
+
+// Has reports whether a file with the given hash is cached and intact
+func (c *Cache) Has(sha []byte) bool {
+	_, err := c.Load(sha)
+	return err == nil
+}
+
+// Load reads a cached file and checks it still matches its hash
+func (c *Cache) Load(sha []byte) ([]byte, error) {
+	data, err := os.ReadFile(c.Path(sha))
+	if err != nil {
+		return nil, err
+	}
+	if !hashMatches(data, sha) {
+		return nil, ErrHashMismatch
+	}
+	return data, nil
+}
+
+// Open opens a cached file for reading
+func (c *Cache) Open(sha []byte) (fs.File, error) {
+	return os.Open(c.Path(sha))
+}
+
+// Store writes a file to the cache after checking it matches its hash
+func (c *Cache) Store(sha, data []byte) error {
+	if !hashMatches(data, sha) {
+		return ErrHashMismatch
+	}
+
+	// Write to a temporary file first so a crash never leaves a truncated file behind
+	tmp, err := os.CreateTemp(c.Dir, "tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), c.Path(sha))
+}
+
+// hashMatches reports whether data has the given SHA1
+func hashMatches(data, sha []byte) bool {
+	sum := sha1.Sum(data)
+	return string(sum[:]) == string(sha)
+}
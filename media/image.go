@@ -0,0 +1,26 @@
+package media
+
+import (
+	"image"
+	"image/draw"
+	_ "image/jpeg" // Register decoders for the formats textures come in
+	_ "image/png"
+	"io/fs"
+)
+
+// DecodeImage reads an image from a filesystem and converts it to RGBA
+func DecodeImage(fsys fs.FS, name string) (*image.RGBA, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, err
+	}
+	rgba := image.NewRGBA(img.Bounds())
+	draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	return rgba, nil
+}
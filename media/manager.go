@@ -0,0 +1,139 @@
+package media
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Manager tracks the media a server announced and serves it as a filesystem.
+// Names the server did not announce are looked up in the fallback filesystem.
+type Manager struct {
+	cache    *Cache
+	fallback fs.FS
+
+	mu     sync.Mutex
+	hashes map[string][]byte // Announced file names to their SHA1
+	have   map[string]bool   // Announced files available locally
+	memory map[string][]byte // File contents by hex SHA1, used when there is no cache
+}
+
+// NewManager creates a manager storing files in cache, or in memory if cache is nil
+func NewManager(cache *Cache, fallback fs.FS) *Manager {
+	return &Manager{
+		cache:    cache,
+		fallback: fallback,
+		hashes:   make(map[string][]byte),
+		have:     make(map[string]bool),
+		memory:   make(map[string][]byte),
+	}
+}
+
+// Announce records a file the server offers, reusing a cached copy if there is one
+func (m *Manager) Announce(name string, sha []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hashes[name] = sha
+	m.have[name] = m.available(sha)
+}
+
+// available reports whether the content with the given hash is stored
+func (m *Manager) available(sha []byte) bool {
+	if m.cache != nil {
+		return m.cache.Has(sha)
+	}
+	_, exists := m.memory[hex.EncodeToString(sha)]
+	return exists
+}
+
+// Hash returns the SHA1 announced for a file
+func (m *Manager) Hash(name string) ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sha, exists := m.hashes[name]
+	return sha, exists
+}
+
+// Missing returns the announced files that still have to be fetched, sorted by name
+func (m *Manager) Missing() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var names []string
+	for name := range m.hashes {
+		if !m.have[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Complete reports whether every announced file is available
+func (m *Manager) Complete() bool {
+	return len(m.Missing()) == 0
+}
+
+// Add stores the content of an announced file after checking its hash
+func (m *Manager) Add(name string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sha, exists := m.hashes[name]
+	if !exists {
+		return fmt.Errorf("media file %q was not announced", name)
+	}
+	if m.cache != nil {
+		if err := m.cache.Store(sha, data); err != nil {
+			return fmt.Errorf("media file %q: %w", name, err)
+		}
+	} else {
+		if !hashMatches(data, sha) {
+			return fmt.Errorf("media file %q: %w", name, ErrHashMismatch)
+		}
+		m.memory[hex.EncodeToString(sha)] = data
+	}
+	m.have[name] = true
+	return nil
+}
+
+// Open opens an announced file, or a file of the fallback filesystem
+func (m *Manager) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	m.mu.Lock()
+	sha, announced := m.hashes[name]
+	have := m.have[name]
+	data := m.memory[hex.EncodeToString(sha)]
+	m.mu.Unlock()
+
+	switch {
+	case announced && have && m.cache != nil:
+		return m.cache.Open(sha)
+	case announced && have:
+		return &memFile{Reader: bytes.NewReader(data), name: name, size: int64(len(data))}, nil
+	case m.fallback != nil:
+		return m.fallback.Open(name)
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// memFile is a media file held in memory
+type memFile struct {
+	*bytes.Reader
+	name string
+	size int64
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) { return f, nil }
+func (f *memFile) Close() error               { return nil }
+func (f *memFile) Name() string               { return f.name }
+func (f *memFile) Size() int64                { return f.size }
+func (f *memFile) Mode() fs.FileMode          { return 0o444 }
+func (f *memFile) ModTime() time.Time         { return time.Time{} }
+func (f *memFile) IsDir() bool                { return false }
+func (f *memFile) Sys() any                   { return nil }
@@ -0,0 +1,106 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Header of the hash lists exchanged with a remote media server
+const (
+	remoteIndexMagic   = "MTHS"
+	remoteIndexVersion = 1
+)
+
+var ErrInvalidIndex = errors.New("invalid remote media index")
+
+// FetchRemote downloads missing files from a remote media server, like the remote_media setting of a server.
+// It returns the number of files fetched; files the server does not have are left missing.
+func FetchRemote(ctx context.Context, client *http.Client, baseURL string, m *Manager) (int, error) {
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+
+	// Ask which of the missing files the remote server has
+	wanted := make(map[string]string)
+	body := bytes.NewBufferString(remoteIndexMagic)
+	binary.Write(body, binary.BigEndian, uint16(remoteIndexVersion))
+	for _, name := range m.Missing() {
+		sha, _ := m.Hash(name)
+		wanted[string(sha)] = name
+		body.Write(sha)
+	}
+	if len(wanted) == 0 {
+		return 0, nil
+	}
+	index, err := httpRequest(ctx, client, http.MethodPost, baseURL+"index.mth", body)
+	if err != nil {
+		return 0, err
+	}
+	available, err := parseRemoteIndex(index)
+	if err != nil {
+		return 0, err
+	}
+
+	fetched := 0
+	for _, sha := range available {
+		name, exists := wanted[string(sha)]
+		if !exists {
+			continue
+		}
+		data, err := httpRequest(ctx, client, http.MethodGet, baseURL+hex.EncodeToString(sha), nil)
+		if err != nil {
+			return fetched, err
+		}
+		if err := m.Add(name, data); err != nil {
+			return fetched, err
+		}
+		fetched++
+	}
+	return fetched, nil
+}
+
+// parseRemoteIndex returns the hashes listed in an index.mth response
+func parseRemoteIndex(data []byte) ([][]byte, error) {
+	header := len(remoteIndexMagic) + 2
+	if len(data) < header || string(data[:len(remoteIndexMagic)]) != remoteIndexMagic {
+		return nil, ErrInvalidIndex
+	}
+	if version := binary.BigEndian.Uint16(data[len(remoteIndexMagic):]); version != remoteIndexVersion {
+		return nil, fmt.Errorf("%w: version %d", ErrInvalidIndex, version)
+	}
+	data = data[header:]
+	if len(data)%sha1.Size != 0 {
+		return nil, ErrInvalidIndex
+	}
+	var hashes [][]byte
+	for len(data) > 0 {
+		hashes = append(hashes, data[:sha1.Size])
+		data = data[sha1.Size:]
+	}
+	return hashes, nil
+}
+
+// httpRequest performs a request and returns the body of a successful response
+func httpRequest(ctx context.Context, client *http.Client, method, url string, body io.Reader) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s %s: %s", method, url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,171 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// remoteServer is a remote media server holding some files, recording which hashes clients asked for
+type remoteServer struct {
+	files map[string][]byte // By hex SHA1
+
+	mu    sync.Mutex
+	asked [][]byte
+	index func(w http.ResponseWriter) // Replaces the index.mth response if set
+	gets  []string
+}
+
+func (s *remoteServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/media/")
+	if path == "index.mth" {
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if string(body[:4]) != remoteIndexMagic || binary.BigEndian.Uint16(body[4:]) != remoteIndexVersion || r.Method != http.MethodPost {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if s.index != nil {
+			s.index(w)
+			return
+		}
+		reply := bytes.NewBufferString(remoteIndexMagic)
+		binary.Write(reply, binary.BigEndian, uint16(remoteIndexVersion))
+		for sha := body[6:]; len(sha) > 0; sha = sha[sha1.Size:] {
+			s.asked = append(s.asked, sha[:sha1.Size])
+			if _, exists := s.files[hex.EncodeToString(sha[:sha1.Size])]; exists {
+				reply.Write(sha[:sha1.Size])
+			}
+		}
+		w.Write(reply.Bytes())
+		return
+	}
+
+	s.mu.Lock()
+	s.gets = append(s.gets, path)
+	s.mu.Unlock()
+	data, exists := s.files[path]
+	if !exists {
+		http.NotFound(w, r)
+		return
+	}
+	w.Write(data)
+}
+
+func sum(data []byte) []byte {
+	s := sha1.Sum(data)
+	return s[:]
+}
+
+// serveRemote starts a remote media server under /media/ with the given files
+func serveRemote(t *testing.T, files ...[]byte) (*remoteServer, string) {
+	t.Helper()
+	s := &remoteServer{files: make(map[string][]byte)}
+	for _, data := range files {
+		s.files[hex.EncodeToString(sum(data))] = data
+	}
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	return s, srv.URL + "/media" // Without the trailing slash FetchRemote adds
+}
+
+func TestFetchRemote(t *testing.T) {
+	stone, dirt, sound := []byte("stone texture"), []byte("dirt texture"), []byte("sound")
+	remote, url := serveRemote(t, stone, dirt)
+
+	m := NewManager(nil, nil)
+	m.Announce("stone.png", sum(stone))
+	m.Announce("dirt.png", sum(dirt))
+	m.Announce("dig.ogg", sum(sound)) // Not on the remote server
+	m.Announce("cached.png", sum([]byte("cached")))
+	if err := m.Add("cached.png", []byte("cached")); err != nil {
+		t.Fatal(err)
+	}
+
+	fetched, err := FetchRemote(context.Background(), http.DefaultClient, url, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fetched != 2 {
+		t.Fatalf("fetched %d files, want 2", fetched)
+	}
+	if missing := m.Missing(); len(missing) != 1 || missing[0] != "dig.ogg" {
+		t.Fatalf("missing %v, want only dig.ogg", missing)
+	}
+	if data, err := fs.ReadFile(m, "stone.png"); err != nil || !bytes.Equal(data, stone) {
+		t.Fatalf("stone.png: %q, %v", data, err)
+	}
+
+	// Only missing files are asked for, and only available ones downloaded
+	if len(remote.asked) != 3 {
+		t.Fatalf("asked for %d hashes, want the 3 missing ones", len(remote.asked))
+	}
+	if len(remote.gets) != 2 {
+		t.Fatalf("downloaded %v, want 2 files", remote.gets)
+	}
+}
+
+func TestFetchRemoteNothingMissing(t *testing.T) {
+	remote, url := serveRemote(t)
+	m := NewManager(nil, nil)
+	if fetched, err := FetchRemote(context.Background(), http.DefaultClient, url, m); fetched != 0 || err != nil {
+		t.Fatalf("got %d, %v", fetched, err)
+	}
+	if remote.asked != nil {
+		t.Fatal("index requested with nothing missing")
+	}
+}
+
+func TestFetchRemoteCorruptFile(t *testing.T) {
+	good := []byte("good")
+	remote, url := serveRemote(t, good)
+	// The server lists the file but sends different content
+	remote.files[hex.EncodeToString(sum(good))] = []byte("corrupt")
+
+	m := NewManager(nil, nil)
+	m.Announce("good.png", sum(good))
+	_, err := FetchRemote(context.Background(), http.DefaultClient, url, m)
+	if !errors.Is(err, ErrHashMismatch) {
+		t.Fatalf("got %v, want %v", err, ErrHashMismatch)
+	}
+	if m.Complete() {
+		t.Fatal("corrupt file stored")
+	}
+}
+
+func TestFetchRemoteBadIndex(t *testing.T) {
+	remote, url := serveRemote(t)
+	m := NewManager(nil, nil)
+	m.Announce("a.png", sum([]byte("a")))
+
+	for name, index := range map[string][]byte{
+		"magic":   []byte("XXXX\x00\x01"),
+		"version": []byte("MTHS\x00\x02"),
+		"length":  []byte("MTHS\x00\x01short"),
+	} {
+		remote.mu.Lock()
+		remote.index = func(w http.ResponseWriter) { w.Write(index) }
+		remote.mu.Unlock()
+		if _, err := FetchRemote(context.Background(), http.DefaultClient, url, m); !errors.Is(err, ErrInvalidIndex) {
+			t.Errorf("%s: got %v, want %v", name, err, ErrInvalidIndex)
+		}
+	}
+
+	remote.mu.Lock()
+	remote.index = func(w http.ResponseWriter) { http.Error(w, "gone", http.StatusGone) }
+	remote.mu.Unlock()
+	if _, err := FetchRemote(context.Background(), http.DefaultClient, url, m); err == nil {
+		t.Error("error status accepted")
+	}
+}
@@ -3,6 +3,8 @@ package meshbuilder
 import (
 	"errors"
 
+	"bettermt/main/blocktypes"
+
 	"github.com/ojrac/opensimplex-go"
 )
 
@@ -12,16 +14,27 @@ const (
 	// Default block type (e.g., 0 for air)
 	DefaultBlockType = 1
 	// Block types
-	BlockAir   = 0
-	BlockGrass = 1
-	BlockDirt  = 2
-	BlockStone = 3
+	BlockAir   = blocktypes.ContentAir
+	BlockGrass = blocktypes.ContentGrass
+	BlockDirt  = blocktypes.ContentDirt
+	BlockStone = blocktypes.ContentStone
 )
 
 // MapBlock represents a single chunk of blocks in a 3D space.
 type MapBlock struct {
-	x, y, z int32                                  // Chunk coordinates
-	blocks  [ChunkSize][ChunkSize][ChunkSize]uint8 // Block data (0 for air, other values for different blocks)
+	x, y, z int32                                   // Chunk coordinates
+	blocks  [ChunkSize][ChunkSize][ChunkSize]uint16 // Block data, content IDs defined by the node definitions
+	param1  [ChunkSize][ChunkSize][ChunkSize]uint8  // Light levels
+	param2  [ChunkSize][ChunkSize][ChunkSize]uint8  // Rotation, color and other drawtype specific data
+
+	// Data carried along by the Minetest block format
+	Flags            uint8
+	LightingComplete uint16
+	Timestamp        uint32
+	NodeMetadata     []NodeMetadata
+	NodeTimers       []NodeTimer
+	StaticObjects    []StaticObject
+	NameIDMapping    map[uint16]string // Names of the content IDs used in the block, only set for blocks read from disk
 }
 
 // New creates a new MapBlock at the specified coordinates, initializing all blocks to a default value.
@@ -61,7 +74,7 @@ func (mb *MapBlock) generateChunk() {
 }
 
 // GetBlock returns the block type at the specified coordinates within the chunk.
-func (mb *MapBlock) GetBlock(x, y, z int32) (uint8, error) {
+func (mb *MapBlock) GetBlock(x, y, z int32) (uint16, error) {
 	if x < 0 || x >= ChunkSize || y < 0 || y >= ChunkSize || z < 0 || z >= ChunkSize {
 		return 0, nil
 	}
@@ -69,7 +82,7 @@ func (mb *MapBlock) GetBlock(x, y, z int32) (uint8, error) {
 }
 
 // SetBlock sets the block type at the specified coordinates within the chunk.
-func (mb *MapBlock) SetBlock(x, y, z int32, blockType uint8) error {
+func (mb *MapBlock) SetBlock(x, y, z int32, blockType uint16) error {
 	if x < 0 || x >= ChunkSize || y < 0 || y >= ChunkSize || z < 0 || z >= ChunkSize {
 		return errors.New("coordinates out of bounds")
 	}
@@ -77,6 +90,24 @@ func (mb *MapBlock) SetBlock(x, y, z int32, blockType uint8) error {
 	return nil
 }
 
+// GetParams returns the param1 and param2 values at the specified coordinates within the chunk.
+func (mb *MapBlock) GetParams(x, y, z int32) (uint8, uint8) {
+	if x < 0 || x >= ChunkSize || y < 0 || y >= ChunkSize || z < 0 || z >= ChunkSize {
+		return 0, 0
+	}
+	return mb.param1[x][y][z], mb.param2[x][y][z]
+}
+
+// SetParams sets the param1 and param2 values at the specified coordinates within the chunk.
+func (mb *MapBlock) SetParams(x, y, z int32, param1, param2 uint8) error {
+	if x < 0 || x >= ChunkSize || y < 0 || y >= ChunkSize || z < 0 || z >= ChunkSize {
+		return errors.New("coordinates out of bounds")
+	}
+	mb.param1[x][y][z] = param1
+	mb.param2[x][y][z] = param2
+	return nil
+}
+
 // GetCoordinates returns the chunk's coordinates.
 func (mb *MapBlock) GetCoordinates() (int32, int32, int32) {
 	return mb.x, mb.y, mb.z
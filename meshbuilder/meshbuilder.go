@@ -13,10 +13,10 @@ import (
 // Credit to jordan4ibanez for writing a very helpful tutorial on how to use custom meshes with G3N
 var NumFaces int = 0
 
-func BuildChunkMesh(scene *core.Node, world *World, chunk *MapBlock) {
+func BuildChunkMesh(scene *core.Node, world *World, chunk *MapBlock) []*graphic.Mesh {
 	chunkMesh := NewChunkMeshes()
 	RenderMapBlock(world, chunkMesh, chunk)
-	FinalizeChunkMeshes(chunkMesh, scene)
+	return FinalizeChunkMeshes(chunkMesh, scene)
 }
 
 type ChunkMesh struct {
@@ -131,14 +131,23 @@ func AddFaceToChunkMeshes(chunkMeshes *ChunkMeshes, position *math32.Vector3, fa
 	AddFaceToChunkMesh(targetMesh, position, facedir, materialID)
 }
 
-func FinalizeChunkMeshes(chunkMeshes *ChunkMeshes, scene *core.Node) {
+func FinalizeChunkMeshes(chunkMeshes *ChunkMeshes, scene *core.Node) []*graphic.Mesh {
 	// Finalize and add each mesh to the scene separately
-	FinalizeChunkMesh(chunkMeshes.TopBottom, scene)
-	FinalizeChunkMesh(chunkMeshes.FrontBack, scene)
-	FinalizeChunkMesh(chunkMeshes.LeftRight, scene)
+	var meshes []*graphic.Mesh
+	for _, chunkMesh := range []*ChunkMesh{chunkMeshes.TopBottom, chunkMeshes.FrontBack, chunkMeshes.LeftRight} {
+		if faceMesh := FinalizeChunkMesh(chunkMesh, scene); faceMesh != nil {
+			meshes = append(meshes, faceMesh)
+		}
+	}
+	return meshes
 }
 
-func FinalizeChunkMesh(chunkMesh *ChunkMesh, scene *core.Node) {
+func FinalizeChunkMesh(chunkMesh *ChunkMesh, scene *core.Node) *graphic.Mesh {
+	// Nothing to draw, e.g. for chunks that are all air
+	if chunkMesh.Indices.Len() == 0 {
+		return nil
+	}
+
 	// Create the geometry object
 	faceGeometry := geometry.NewGeometry()
 
@@ -157,54 +166,59 @@ func FinalizeChunkMesh(chunkMesh *ChunkMesh, scene *core.Node) {
 		start := chunkMesh.MatStarts[i]
 		count := chunkMesh.MatCounts[i]
 
-		blockMaterial, err := blocktypes.GetBlockMaterial(uint8(materialID))
+		blockMaterial, err := blocktypes.GetBlockMaterial(materialID)
 		if err != nil {
-			panic(err)
+			// Blocks may use IDs without a definition, leave them undrawn
+			continue
 		}
 		faceMesh.AddMaterial(blockMaterial, int(start), int(count))
 	}
 
 	// Add the final mesh to the scene
 	scene.Add(faceMesh)
+	return faceMesh
 }
 
 func RenderMapBlock(world *World, chunkMeshes *ChunkMeshes, mb *MapBlock) {
+	nodeDefs := blocktypes.NodeDefs()
+
 	// Loop through all blocks in the MapBlock
 	for x := int32(0); x < ChunkSize; x++ {
 		for y := int32(0); y < ChunkSize; y++ {
 			for z := int32(0); z < ChunkSize; z++ {
 				blockType, _ := mb.GetBlock(x, y, z)
-				if blockType == 0 {
-					// Skip air blocks
+				if !nodeDefs.IsDrawnAsCube(blockType) {
+					// Skip air and shapes the mesher cannot draw yet
 					continue
 				}
+				position := &math32.Vector3{X: float32(mb.x + x), Y: float32(mb.y + y), Z: float32(mb.z + z)}
 
-				// Check each face and add it if the adjacent block is air (or out of bounds)
-				if shouldRenderFace(world, mb, x, y, z+1) { // Front face
-					AddFaceToChunkMeshes(chunkMeshes, &math32.Vector3{X: float32(mb.x + x), Y: float32(mb.y + y), Z: float32(mb.z + z)}, &FaceDirs.FRONT, uint32(blockType))
+				// Check each face and add it unless the adjacent block hides it
+				if shouldRenderFace(world, mb, blockType, x, y, z+1) { // Front face
+					AddFaceToChunkMeshes(chunkMeshes, position, &FaceDirs.FRONT, blocktypes.MaterialID(blockType, blocktypes.TileBack))
 				}
-				if shouldRenderFace(world, mb, x, y, z-1) { // Back face
-					AddFaceToChunkMeshes(chunkMeshes, &math32.Vector3{X: float32(mb.x + x), Y: float32(mb.y + y), Z: float32(mb.z + z)}, &FaceDirs.BACK, uint32(blockType))
+				if shouldRenderFace(world, mb, blockType, x, y, z-1) { // Back face
+					AddFaceToChunkMeshes(chunkMeshes, position, &FaceDirs.BACK, blocktypes.MaterialID(blockType, blocktypes.TileFront))
 				}
-				if shouldRenderFace(world, mb, x, y+1, z) { // Top face
-					AddFaceToChunkMeshes(chunkMeshes, &math32.Vector3{X: float32(mb.x + x), Y: float32(mb.y + y), Z: float32(mb.z + z)}, &FaceDirs.UP, uint32(blockType))
+				if shouldRenderFace(world, mb, blockType, x, y+1, z) { // Top face
+					AddFaceToChunkMeshes(chunkMeshes, position, &FaceDirs.UP, blocktypes.MaterialID(blockType, blocktypes.TileTop))
 				}
-				if shouldRenderFace(world, mb, x, y-1, z) { // Bottom face
-					AddFaceToChunkMeshes(chunkMeshes, &math32.Vector3{X: float32(mb.x + x), Y: float32(mb.y + y), Z: float32(mb.z + z)}, &FaceDirs.DOWN, uint32(blockType))
+				if shouldRenderFace(world, mb, blockType, x, y-1, z) { // Bottom face
+					AddFaceToChunkMeshes(chunkMeshes, position, &FaceDirs.DOWN, blocktypes.MaterialID(blockType, blocktypes.TileBottom))
 				}
-				if shouldRenderFace(world, mb, x-1, y, z) { // Left face
-					AddFaceToChunkMeshes(chunkMeshes, &math32.Vector3{X: float32(mb.x + x), Y: float32(mb.y + y), Z: float32(mb.z + z)}, &FaceDirs.LEFT, uint32(blockType))
+				if shouldRenderFace(world, mb, blockType, x-1, y, z) { // Left face
+					AddFaceToChunkMeshes(chunkMeshes, position, &FaceDirs.LEFT, blocktypes.MaterialID(blockType, blocktypes.TileLeft))
 				}
-				if shouldRenderFace(world, mb, x+1, y, z) { // Right face
-					AddFaceToChunkMeshes(chunkMeshes, &math32.Vector3{X: float32(mb.x + x), Y: float32(mb.y + y), Z: float32(mb.z + z)}, &FaceDirs.RIGHT, uint32(blockType))
+				if shouldRenderFace(world, mb, blockType, x+1, y, z) { // Right face
+					AddFaceToChunkMeshes(chunkMeshes, position, &FaceDirs.RIGHT, blocktypes.MaterialID(blockType, blocktypes.TileRight))
 				}
 			}
 		}
 	}
 }
 
-// Helper function to check if a face should be rendered (i.e., the neighboring block is air or out of bounds)
-func shouldRenderFace(world *World, mb *MapBlock, x, y, z int32) bool {
+// Helper function to check if a face should be rendered (i.e., the neighboring block does not hide it)
+func shouldRenderFace(world *World, mb *MapBlock, blockType uint16, x, y, z int32) bool {
 	block, _ := GetBlockInWorld(world, x+mb.x, y+mb.y, z+mb.z)
-	return block == 0
+	return !blocktypes.NodeDefs().HidesFace(blockType, uint16(block))
 }
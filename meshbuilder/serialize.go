@@ -0,0 +1,500 @@
+package meshbuilder
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"bettermt/main/network"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// Range of serialization versions the MapBlock format is supported for
+	MinSerializationVersion = uint8(25)
+	MaxSerializationVersion = uint8(29)
+
+	// Number of nodes in a MapBlock
+	NodeCount = int(ChunkSize * ChunkSize * ChunkSize)
+
+	contentWidth = 2
+	paramsWidth  = 2
+)
+
+// Flags stored in the first byte of a serialized MapBlock
+const (
+	FlagIsUnderground   = uint8(0x01)
+	FlagDayNightDiffers = uint8(0x02)
+	FlagLightingExpired = uint8(0x04)
+	FlagNotGenerated    = uint8(0x08)
+)
+
+var ErrUnsupportedVersion = errors.New("unsupported MapBlock serialization version")
+
+var (
+	zstdDecoder, _ = zstd.NewReader(nil)
+	zstdEncoder, _ = zstd.NewWriter(nil)
+)
+
+// MetadataField is one key/value pair of node metadata
+type MetadataField struct {
+	Name    string
+	Value   string
+	Private bool
+}
+
+// NodeMetadata holds the metadata of one node in the block
+type NodeMetadata struct {
+	Position  uint16 // Index of the node, see NodeIndex
+	Fields    []MetadataField
+	Inventory string // Serialized inventory, including the closing EndInventory line
+}
+
+// NodeTimer is a pending node timer
+type NodeTimer struct {
+	Position uint16
+	Timeout  float32
+	Elapsed  float32
+}
+
+// StaticObject is an inactive object stored in the block
+type StaticObject struct {
+	Type     uint8
+	Position [3]float32
+	Data     string
+}
+
+// NodeIndex returns the index of a node in the serialized block
+func NodeIndex(x, y, z int32) uint16 {
+	return uint16(z*ChunkSize*ChunkSize + y*ChunkSize + x)
+}
+
+// IndexPosition converts a serialized node index back to block relative coordinates
+func IndexPosition(index uint16) (int32, int32, int32) {
+	i := int32(index)
+	return i % ChunkSize, (i / ChunkSize) % ChunkSize, i / (ChunkSize * ChunkSize)
+}
+
+// DeserializeMapBlockBlob reads a block as stored in a map database, starting with its version byte
+func DeserializeMapBlockBlob(blob []byte) (*MapBlock, error) {
+	if len(blob) < 1 {
+		return nil, network.ErrShortPacket
+	}
+	return DeserializeMapBlock(blob[1:], blob[0], true)
+}
+
+// SerializeBlob writes the block in the map database format, starting with its version byte
+func (mb *MapBlock) SerializeBlob(version uint8) ([]byte, error) {
+	data, err := mb.Serialize(version, true)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{version}, data...), nil
+}
+
+// DeserializeMapBlock reads a block in one of the supported serialization versions.
+// Blocks from disk keep their local content IDs until ResolveNames is called.
+func DeserializeMapBlock(data []byte, version uint8, disk bool) (*MapBlock, error) {
+	if version < MinSerializationVersion || version > MaxSerializationVersion {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, version)
+	}
+
+	if version >= 29 {
+		var err error
+		if data, err = zstdDecoder.DecodeAll(data, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	mb := &MapBlock{LightingComplete: 0xFFFF}
+	r := network.NewReader(data)
+	mb.Flags = r.U8()
+	if version >= 27 {
+		mb.LightingComplete = r.U16()
+	}
+	if disk && version >= 29 {
+		mb.Timestamp = r.U32()
+		mb.NameIDMapping = readNameIDMapping(r)
+	}
+
+	if cw, pw := r.U8(), r.U8(); r.Err() == nil && (cw != contentWidth || pw != paramsWidth) {
+		return nil, fmt.Errorf("unsupported content width %d or params width %d", cw, pw)
+	}
+
+	// Bulk node data
+	var nodes []byte
+	if version >= 29 {
+		nodes = r.Bytes(NodeCount * (contentWidth + paramsWidth))
+	} else {
+		var err error
+		if nodes, r, err = decompressSection(r); err != nil {
+			return nil, err
+		}
+		if len(nodes) != NodeCount*(contentWidth+paramsWidth) {
+			return nil, fmt.Errorf("node data has wrong size %d", len(nodes))
+		}
+	}
+	if r.Err() != nil {
+		return nil, r.Err()
+	}
+	mb.setBulk(nodes)
+
+	// Node metadata
+	metadataReader := r
+	if version < 29 {
+		var metadata []byte
+		var err error
+		if metadata, r, err = decompressSection(r); err != nil {
+			return nil, err
+		}
+		metadataReader = network.NewReader(metadata)
+	}
+	if err := mb.readNodeMetadata(metadataReader); err != nil {
+		return nil, err
+	}
+
+	// Data that only goes to disk
+	if disk {
+		mb.StaticObjects = readStaticObjects(r)
+		if version < 29 {
+			mb.Timestamp = r.U32()
+			mb.NameIDMapping = readNameIDMapping(r)
+		}
+		if err := mb.readNodeTimers(r); err != nil {
+			return nil, err
+		}
+	}
+
+	if r.Err() != nil {
+		return nil, r.Err()
+	}
+	return mb, nil
+}
+
+// Serialize writes the block in one of the supported serialization versions.
+// Blocks written for disk need a name for every content ID they use in NameIDMapping.
+func (mb *MapBlock) Serialize(version uint8, disk bool) ([]byte, error) {
+	if version < MinSerializationVersion || version > MaxSerializationVersion {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, version)
+	}
+
+	var nimap map[uint16]string
+	if disk {
+		var err error
+		if nimap, err = mb.usedNames(); err != nil {
+			return nil, err
+		}
+	}
+
+	w := new(network.Writer)
+	w.U8(mb.Flags)
+	if version >= 27 {
+		w.U16(mb.LightingComplete)
+	}
+	if disk && version >= 29 {
+		w.U32(mb.Timestamp)
+		writeNameIDMapping(w, nimap)
+	}
+
+	w.U8(contentWidth).U8(paramsWidth)
+	metadata := new(network.Writer)
+	mb.writeNodeMetadata(metadata, version)
+	if version >= 29 {
+		w.Raw(mb.bulk())
+		w.Raw(metadata.Bytes())
+	} else {
+		// Prior to 29 node data and metadata were compressed individually
+		if err := compressSection(w, mb.bulk()); err != nil {
+			return nil, err
+		}
+		if err := compressSection(w, metadata.Bytes()); err != nil {
+			return nil, err
+		}
+	}
+
+	if disk {
+		writeStaticObjects(w, mb.StaticObjects)
+		if version < 29 {
+			w.U32(mb.Timestamp)
+			writeNameIDMapping(w, nimap)
+		}
+		mb.writeNodeTimers(w)
+	}
+
+	if version >= 29 {
+		return zstdEncoder.EncodeAll(w.Bytes(), nil), nil
+	}
+	return w.Bytes(), nil
+}
+
+// ResolveNames replaces the content IDs of a block read from disk with the IDs lookup returns for their names
+func (mb *MapBlock) ResolveNames(lookup func(name string) uint16) error {
+	if mb.NameIDMapping == nil {
+		return nil
+	}
+	if _, err := mb.usedNames(); err != nil {
+		return err
+	}
+	ids := make(map[uint16]uint16, len(mb.NameIDMapping))
+	for local, name := range mb.NameIDMapping {
+		ids[local] = lookup(name)
+	}
+	for x := range mb.blocks {
+		for y := range mb.blocks[x] {
+			for z, local := range mb.blocks[x][y] {
+				global, ok := ids[local]
+				if !ok {
+					return fmt.Errorf("content ID %d has no name", local)
+				}
+				mb.blocks[x][y][z] = global
+			}
+		}
+	}
+	mb.NameIDMapping = nil
+	return nil
+}
+
+// usedNames returns the name mapping of every content ID in the block
+func (mb *MapBlock) usedNames() (map[uint16]string, error) {
+	used := make(map[uint16]string)
+	for x := range mb.blocks {
+		for y := range mb.blocks[x] {
+			for _, id := range mb.blocks[x][y] {
+				if _, done := used[id]; done {
+					continue
+				}
+				name, ok := mb.NameIDMapping[id]
+				if !ok {
+					return nil, fmt.Errorf("content ID %d has no name", id)
+				}
+				used[id] = name
+			}
+		}
+	}
+	return used, nil
+}
+
+// setBulk fills the node arrays from serialized content, param1 and param2 runs
+func (mb *MapBlock) setBulk(nodes []byte) {
+	for i := 0; i < NodeCount; i++ {
+		x, y, z := IndexPosition(uint16(i))
+		mb.blocks[x][y][z] = uint16(nodes[i*2])<<8 | uint16(nodes[i*2+1])
+		mb.param1[x][y][z] = nodes[NodeCount*2+i]
+		mb.param2[x][y][z] = nodes[NodeCount*3+i]
+	}
+}
+
+// bulk serializes the node arrays as content, param1 and param2 runs
+func (mb *MapBlock) bulk() []byte {
+	nodes := make([]byte, NodeCount*(contentWidth+paramsWidth))
+	for i := 0; i < NodeCount; i++ {
+		x, y, z := IndexPosition(uint16(i))
+		nodes[i*2] = byte(mb.blocks[x][y][z] >> 8)
+		nodes[i*2+1] = byte(mb.blocks[x][y][z])
+		nodes[NodeCount*2+i] = mb.param1[x][y][z]
+		nodes[NodeCount*3+i] = mb.param2[x][y][z]
+	}
+	return nodes
+}
+
+// readNodeMetadata reads the metadata list; version 2 lists, used from block version 28, carry private flags
+func (mb *MapBlock) readNodeMetadata(r *network.Reader) error {
+	mb.NodeMetadata = nil
+	version := r.U8()
+	if version == 0 || r.Err() != nil {
+		return r.Err()
+	}
+	if version > 2 {
+		return fmt.Errorf("unsupported node metadata version %d", version)
+	}
+
+	count := int(r.U16())
+	for i := 0; i < count && r.Err() == nil; i++ {
+		meta := NodeMetadata{Position: r.U16()}
+		numVars := int(r.U32())
+		for j := 0; j < numVars && r.Err() == nil; j++ {
+			field := MetadataField{Name: r.String16(), Value: r.String32()}
+			if version >= 2 {
+				field.Private = r.Bool()
+			}
+			meta.Fields = append(meta.Fields, field)
+		}
+		meta.Inventory = readInventoryText(r)
+		mb.NodeMetadata = append(mb.NodeMetadata, meta)
+	}
+	return r.Err()
+}
+
+// writeNodeMetadata writes the metadata list in the format matching the block version
+func (mb *MapBlock) writeNodeMetadata(w *network.Writer, blockVersion uint8) {
+	if len(mb.NodeMetadata) == 0 {
+		w.U8(0)
+		return
+	}
+	version := uint8(1)
+	if blockVersion > 27 {
+		version = 2
+	}
+	w.U8(version)
+	w.U16(uint16(len(mb.NodeMetadata)))
+	for _, meta := range mb.NodeMetadata {
+		w.U16(meta.Position)
+		w.U32(uint32(len(meta.Fields)))
+		for _, field := range meta.Fields {
+			w.String16(field.Name)
+			w.String32(field.Value)
+			if version >= 2 {
+				w.Bool(field.Private)
+			}
+		}
+		inventory := meta.Inventory
+		if inventory == "" {
+			inventory = "EndInventory\n"
+		}
+		w.Raw([]byte(inventory))
+	}
+}
+
+// readInventoryText reads the line based inventory serialization up to and including its EndInventory line
+func readInventoryText(r *network.Reader) string {
+	var text strings.Builder
+	for r.Err() == nil && r.Len() > 0 {
+		line := readLine(r)
+		text.WriteString(line)
+		if strings.TrimSpace(line) == "EndInventory" {
+			break
+		}
+	}
+	return text.String()
+}
+
+// readLine reads bytes up to and including the next newline
+func readLine(r *network.Reader) string {
+	var line []byte
+	for r.Len() > 0 {
+		b := r.U8()
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	return string(line)
+}
+
+// readNodeTimers reads the node timer list
+func (mb *MapBlock) readNodeTimers(r *network.Reader) error {
+	mb.NodeTimers = nil
+	dataLen := r.U8()
+	count := int(r.U16())
+	if r.Err() != nil {
+		return r.Err()
+	}
+	if count > 0 && dataLen != 2+4+4 {
+		return fmt.Errorf("unsupported node timer data length %d", dataLen)
+	}
+	for i := 0; i < count && r.Err() == nil; i++ {
+		mb.NodeTimers = append(mb.NodeTimers, NodeTimer{
+			Position: r.U16(),
+			Timeout:  float32(r.S32()) / 1000,
+			Elapsed:  float32(r.S32()) / 1000,
+		})
+	}
+	return r.Err()
+}
+
+// writeNodeTimers writes the node timer list
+func (mb *MapBlock) writeNodeTimers(w *network.Writer) {
+	w.U8(2 + 4 + 4)
+	w.U16(uint16(len(mb.NodeTimers)))
+	for _, timer := range mb.NodeTimers {
+		w.U16(timer.Position)
+		w.S32(int32(timer.Timeout * 1000))
+		w.S32(int32(timer.Elapsed * 1000))
+	}
+}
+
+// readStaticObjects reads the list of stored inactive objects
+func readStaticObjects(r *network.Reader) []StaticObject {
+	r.U8() // Version
+	count := int(r.U16())
+	var objects []StaticObject
+	for i := 0; i < count && r.Err() == nil; i++ {
+		obj := StaticObject{Type: r.U8()}
+		for j := range obj.Position {
+			obj.Position[j] = float32(r.S32()) / 1000
+		}
+		obj.Data = r.String16()
+		objects = append(objects, obj)
+	}
+	return objects
+}
+
+// writeStaticObjects writes the list of stored inactive objects
+func writeStaticObjects(w *network.Writer, objects []StaticObject) {
+	w.U8(0) // Version
+	w.U16(uint16(len(objects)))
+	for _, obj := range objects {
+		w.U8(obj.Type)
+		for _, v := range obj.Position {
+			w.S32(int32(v * 1000))
+		}
+		w.String16(obj.Data)
+	}
+}
+
+// readNameIDMapping reads the table of content names used by a block
+func readNameIDMapping(r *network.Reader) map[uint16]string {
+	r.U8() // Version
+	count := int(r.U16())
+	mapping := make(map[uint16]string, count)
+	for i := 0; i < count && r.Err() == nil; i++ {
+		id := r.U16()
+		mapping[id] = r.String16()
+	}
+	return mapping
+}
+
+// writeNameIDMapping writes the table of content names used by a block, sorted by ID
+func writeNameIDMapping(w *network.Writer, mapping map[uint16]string) {
+	w.U8(0) // Version
+	w.U16(uint16(len(mapping)))
+	for id := 0; id <= 0xFFFF; id++ {
+		if name, ok := mapping[uint16(id)]; ok {
+			w.U16(uint16(id))
+			w.String16(name)
+		}
+	}
+}
+
+// decompressSection inflates a zlib stream at the reader's position and returns a reader for the data after it
+func decompressSection(r *network.Reader) ([]byte, *network.Reader, error) {
+	rest := r.Remaining()
+	br := bytes.NewReader(rest)
+	zr, err := zlib.NewReader(br)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, network.NewReader(rest[len(rest)-br.Len():]), nil
+}
+
+// compressSection deflates data with zlib into the writer
+func compressSection(w *network.Writer, data []byte) error {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	w.Raw(buf.Bytes())
+	return nil
+}
@@ -0,0 +1,195 @@
+package meshbuilder
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+
+	"bettermt/main/network"
+)
+
+// The blocks in testdata were written by an encoder independent of this package. Each holds stone in the
+// lower half and air in the upper half of every z layer, a chest with metadata and a node timer at chestIndex,
+// param1 = index * 7 and param2 = index / 3 % 32, and one static object.
+const chestIndex = 0x123
+
+// Content IDs of the test blocks, local to them until resolved
+var testNames = map[uint16]string{0: "air", 1: "default:stone", 2: "default:chest"}
+
+func readTestBlob(t *testing.T, version uint8) []byte {
+	t.Helper()
+	blob, err := os.ReadFile(fmt.Sprintf("testdata/block_v%d.bin", version))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return blob
+}
+
+// inflate returns a blob with its compressed parts decompressed, so that blobs compressed by different
+// encoders can be compared
+func inflate(t *testing.T, blob []byte) []byte {
+	t.Helper()
+	version, data := blob[0], blob[1:]
+	if version >= 29 {
+		raw, err := zstdDecoder.DecodeAll(data, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return append([]byte{version}, raw...)
+	}
+
+	header := 1 + 2 // Flags, content width and params width
+	if version >= 27 {
+		header += 2 // Lighting complete
+	}
+	out := append([]byte{version}, data[:header]...)
+	r := network.NewReader(data[header:])
+	for range 2 { // Node data and node metadata
+		section, rest, err := decompressSection(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, section...)
+		r = rest
+	}
+	return append(out, r.Remaining()...)
+}
+
+func checkTestBlock(t *testing.T, mb *MapBlock, version uint8, disk bool) {
+	t.Helper()
+	if mb.Flags != FlagDayNightDiffers|FlagLightingExpired {
+		t.Errorf("flags %#x", mb.Flags)
+	}
+	wantLighting := uint16(0xFFFF)
+	if version >= 27 {
+		wantLighting = 0xF0F0
+	}
+	if mb.LightingComplete != wantLighting {
+		t.Errorf("lighting complete %#x, want %#x", mb.LightingComplete, wantLighting)
+	}
+
+	for i := range NodeCount {
+		x, y, z := IndexPosition(uint16(i))
+		wantContent := uint16(0)
+		switch {
+		case i == chestIndex:
+			wantContent = 2
+		case y < 8:
+			wantContent = 1
+		}
+		content, _ := mb.GetBlock(x, y, z)
+		param1, param2 := mb.GetParams(x, y, z)
+		if content != wantContent || param1 != uint8(i*7) || param2 != uint8(i/3%32) {
+			t.Fatalf("node %d: content %d params %d %d", i, content, param1, param2)
+		}
+	}
+
+	wantMetadata := []NodeMetadata{{
+		Position: chestIndex,
+		Fields: []MetadataField{
+			{Name: "infotext", Value: "Chest"},
+			{Name: "owner", Value: "sam", Private: version >= 28},
+		},
+		Inventory: "List main 2\nWidth 0\nItem default:stone 5\nEmpty\nEndInventoryList\nEndInventory\n",
+	}}
+	if !reflect.DeepEqual(mb.NodeMetadata, wantMetadata) {
+		t.Errorf("metadata %+v, want %+v", mb.NodeMetadata, wantMetadata)
+	}
+
+	if !disk {
+		return
+	}
+	if mb.Timestamp != 123456 {
+		t.Errorf("timestamp %d", mb.Timestamp)
+	}
+	if !reflect.DeepEqual(mb.NameIDMapping, testNames) {
+		t.Errorf("name-id mapping %v, want %v", mb.NameIDMapping, testNames)
+	}
+	wantTimers := []NodeTimer{{Position: chestIndex, Timeout: 2.5, Elapsed: 0.75}}
+	if !reflect.DeepEqual(mb.NodeTimers, wantTimers) {
+		t.Errorf("node timers %+v, want %+v", mb.NodeTimers, wantTimers)
+	}
+	wantObjects := []StaticObject{{Type: 7, Position: [3]float32{1.5, -2, 15.25}, Data: "mob data"}}
+	if !reflect.DeepEqual(mb.StaticObjects, wantObjects) {
+		t.Errorf("static objects %+v, want %+v", mb.StaticObjects, wantObjects)
+	}
+}
+
+func TestMapBlockBlobs(t *testing.T) {
+	for version := MinSerializationVersion; version <= MaxSerializationVersion; version++ {
+		t.Run(fmt.Sprintf("v%d", version), func(t *testing.T) {
+			blob := readTestBlob(t, version)
+			mb, err := DeserializeMapBlockBlob(blob)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkTestBlock(t, mb, version, true)
+
+			again, err := mb.SerializeBlob(version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(inflate(t, again), inflate(t, blob)) {
+				t.Fatal("re-encoded block differs from the original")
+			}
+		})
+	}
+}
+
+func TestMapBlockNetworkFormat(t *testing.T) {
+	mb, err := DeserializeMapBlockBlob(readTestBlob(t, MaxSerializationVersion))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for version := MinSerializationVersion; version <= MaxSerializationVersion; version++ {
+		data, err := mb.Serialize(version, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sent, err := DeserializeMapBlock(data, version, false)
+		if err != nil {
+			t.Fatalf("v%d: %v", version, err)
+		}
+		// Metadata lists before version 2 drop the private flag, which checkTestBlock expects
+		checkTestBlock(t, sent, version, false)
+		if sent.NameIDMapping != nil || sent.NodeTimers != nil || sent.StaticObjects != nil {
+			t.Errorf("v%d: data kept on disk was sent", version)
+		}
+	}
+}
+
+func TestResolveNames(t *testing.T) {
+	mb, err := DeserializeMapBlockBlob(readTestBlob(t, 28))
+	if err != nil {
+		t.Fatal(err)
+	}
+	global := map[string]uint16{"air": 126, "default:stone": 7, "default:chest": 42}
+	if err := mb.ResolveNames(func(name string) uint16 { return global[name] }); err != nil {
+		t.Fatal(err)
+	}
+	if mb.NameIDMapping != nil {
+		t.Error("name-id mapping kept after resolving")
+	}
+	x, y, z := IndexPosition(chestIndex)
+	if content, _ := mb.GetBlock(x, y, z); content != 42 {
+		t.Errorf("chest has content %d, want 42", content)
+	}
+	if content, _ := mb.GetBlock(0, 15, 0); content != 126 {
+		t.Errorf("air has content %d, want 126", content)
+	}
+
+	// Resolved blocks have no names to write to disk with
+	if _, err := mb.SerializeBlob(MaxSerializationVersion); err == nil {
+		t.Error("block without names serialized for disk")
+	}
+}
+
+func TestUnsupportedVersion(t *testing.T) {
+	blob := readTestBlob(t, 25)
+	blob[0] = 24
+	if _, err := DeserializeMapBlockBlob(blob); err == nil {
+		t.Error("version 24 accepted")
+	}
+}
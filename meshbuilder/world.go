@@ -1,20 +1,46 @@
 package meshbuilder
 
 import (
+	"math"
+	"sync"
+
+	"bettermt/main/blocktypes"
+
 	"github.com/g3n/engine/core"
+	"github.com/g3n/engine/graphic"
 )
 
+// Maximum number of chunk meshes rebuilt by a single call to Update
+const MaxMeshUpdatesPerFrame = 32
+
+// Offsets of the six chunks sharing a face with a chunk
+var neighbourOffsets = [6][3]int32{
+	{ChunkSize, 0, 0}, {-ChunkSize, 0, 0},
+	{0, ChunkSize, 0}, {0, -ChunkSize, 0},
+	{0, 0, ChunkSize}, {0, 0, -ChunkSize},
+}
+
 // World represents the entire 3D world, storing chunks and managing their creation and rendering.
 type World struct {
 	Chunks map[[3]int32]*MapBlock
 	Size   int32
+
+	// Chunks handed over by other goroutines, applied on the render thread. A nil chunk removes the entry.
+	mu      sync.Mutex
+	pending map[[3]int32]*MapBlock
+
+	meshes map[[3]int32][]*graphic.Mesh
+	dirty  map[[3]int32]bool
 }
 
 // NewWorld creates a new World with the given size.
 func NewWorld(size int32) *World {
 	return &World{
-		Chunks: make(map[[3]int32]*MapBlock),
-		Size:   size,
+		Chunks:  make(map[[3]int32]*MapBlock),
+		Size:    size,
+		pending: make(map[[3]int32]*MapBlock),
+		meshes:  make(map[[3]int32][]*graphic.Mesh),
+		dirty:   make(map[[3]int32]bool),
 	}
 }
 
@@ -38,21 +64,153 @@ func (w *World) GenerateChunks() {
 	}
 }
 
+// QueueChunk hands a chunk to the world, replacing any chunk at its coordinates. Safe to call from any goroutine.
+func (w *World) QueueChunk(chunk *MapBlock) {
+	x, y, z := chunk.GetCoordinates()
+	w.mu.Lock()
+	w.pending[[3]int32{x, y, z}] = chunk
+	w.mu.Unlock()
+}
+
+// QueueRemoval schedules the chunk at the given coordinates for removal. Safe to call from any goroutine.
+func (w *World) QueueRemoval(x, y, z int32) {
+	w.mu.Lock()
+	w.pending[[3]int32{x, y, z}] = nil
+	w.mu.Unlock()
+}
+
+// ApplyPending moves queued chunks into the world and returns the coordinates that changed.
+// It does not touch meshes, so it can keep a world up to date without rendering it.
+func (w *World) ApplyPending() [][3]int32 {
+	w.mu.Lock()
+	pending := w.pending
+	w.pending = make(map[[3]int32]*MapBlock)
+	w.mu.Unlock()
+
+	changed := make([][3]int32, 0, len(pending))
+	for pos, chunk := range pending {
+		if chunk == nil {
+			delete(w.Chunks, pos)
+		} else {
+			w.Chunks[pos] = chunk
+		}
+		changed = append(changed, pos)
+	}
+	return changed
+}
+
+// MarkDirty schedules a remesh of the chunk at the given coordinates and of its six neighbours
+func (w *World) MarkDirty(x, y, z int32) {
+	w.dirty[[3]int32{x, y, z}] = true
+	for _, offset := range neighbourOffsets {
+		pos := [3]int32{x + offset[0], y + offset[1], z + offset[2]}
+		if _, exists := w.Chunks[pos]; exists {
+			w.dirty[pos] = true
+		}
+	}
+}
+
+// Update applies queued chunks and rebuilds a limited number of outdated meshes. Call it from the render thread.
+func (w *World) Update(scene *core.Node) {
+	for _, pos := range w.ApplyPending() {
+		w.MarkDirty(pos[0], pos[1], pos[2])
+	}
+	w.rebuildDirty(scene, MaxMeshUpdatesPerFrame)
+}
+
 // Render renders all chunks in the world to the scene.
 func (w *World) Render(scene *core.Node) {
-	for _, chunk := range w.Chunks {
-		BuildChunkMesh(scene, w, chunk)
+	for pos := range w.Chunks {
+		w.dirty[pos] = true
 	}
+	w.rebuildDirty(scene, 0)
+}
+
+// rebuildDirty replaces the meshes of up to limit dirty chunks, or all of them if limit is 0
+func (w *World) rebuildDirty(scene *core.Node, limit int) {
+	rebuilt := 0
+	for pos := range w.dirty {
+		if limit > 0 && rebuilt >= limit {
+			return
+		}
+		delete(w.dirty, pos)
+		rebuilt++
+
+		for _, mesh := range w.meshes[pos] {
+			scene.Remove(mesh)
+			mesh.Dispose()
+		}
+		delete(w.meshes, pos)
+
+		if chunk, exists := w.Chunks[pos]; exists {
+			w.meshes[pos] = BuildChunkMesh(scene, w, chunk)
+		}
+	}
+}
+
+// NodeCoord returns the coordinate of the node containing a position along one axis. Nodes are centered on
+// whole numbers, so a node spans from half a node below its coordinate to half a node above.
+func NodeCoord(v float32) int32 {
+	return int32(math.Floor(float64(v) + 0.5))
+}
+
+// floorDiv splits a node coordinate into a chunk origin and an offset inside the chunk
+func floorDiv(v int32) (int32, int32) {
+	offset := v % ChunkSize
+	if offset < 0 {
+		offset += ChunkSize
+	}
+	return v - offset, offset
 }
 
 func GetBlockInWorld(world *World, x, y, z int32) (blockType int32, err error) {
-	chunkX, blockX := (x/16)*16, x%16
-	chunkY, blockY := (y/16)*16, y%16
-	chunkZ, blockZ := (z/16)*16, z%16
+	chunkX, blockX := floorDiv(x)
+	chunkY, blockY := floorDiv(y)
+	chunkZ, blockZ := floorDiv(z)
 	neighboringChunk, exists := world.Chunks[[3]int32{chunkX, chunkY, chunkZ}]
 	if !exists {
-		return 0, nil // If chunk doesn't exist, treat it as air
+		return int32(BlockAir), nil // If chunk doesn't exist, treat it as air
 	}
 	block, err := neighboringChunk.GetBlock(blockX, blockY, blockZ)
 	return int32(block), err
 }
+
+// ChunkAt returns the chunk containing the node at the given coordinates, or nil if it is not loaded
+func (w *World) ChunkAt(x, y, z int32) *MapBlock {
+	chunkX, _ := floorDiv(x)
+	chunkY, _ := floorDiv(y)
+	chunkZ, _ := floorDiv(z)
+	return w.Chunks[[3]int32{chunkX, chunkY, chunkZ}]
+}
+
+// Node returns the definition of the node at the given coordinates, and false if its chunk is not loaded.
+// Call it from the render thread.
+func (w *World) Node(x, y, z int32) (*blocktypes.NodeDefinition, bool) {
+	chunk := w.ChunkAt(x, y, z)
+	if chunk == nil {
+		return nil, false
+	}
+	_, blockX := floorDiv(x)
+	_, blockY := floorDiv(y)
+	_, blockZ := floorDiv(z)
+	block, _ := chunk.GetBlock(blockX, blockY, blockZ)
+	return blocktypes.NodeDefs().Get(block), true
+}
+
+// SetNode replaces the node at the given coordinates and schedules the remesh of its chunk. It returns the
+// previous content, and false if the chunk is not loaded. Call it from the render thread.
+func (w *World) SetNode(x, y, z int32, content uint16) (uint16, bool) {
+	chunk := w.ChunkAt(x, y, z)
+	if chunk == nil {
+		return 0, false
+	}
+	_, blockX := floorDiv(x)
+	_, blockY := floorDiv(y)
+	_, blockZ := floorDiv(z)
+	old, _ := chunk.GetBlock(blockX, blockY, blockZ)
+	if err := chunk.SetBlock(blockX, blockY, blockZ, content); err != nil {
+		return 0, false
+	}
+	w.MarkDirty(chunk.GetCoordinates())
+	return old, true
+}
@@ -0,0 +1,25 @@
+package meshbuilder
+
+import "testing"
+
+func TestNodeCoord(t *testing.T) {
+	// Nodes span from half a node below their coordinate, included, to half a node above
+	for _, test := range []struct {
+		v    float32
+		want int32
+	}{
+		{0, 0},
+		{0.49, 0},
+		{0.5, 1},
+		{-0.5, 0},
+		{-0.51, -1},
+		{1.5, 2},
+		{-1.5, -1},
+		{31000.4, 31000},
+		{-31000.6, -31001},
+	} {
+		if got := NodeCoord(test.v); got != test.want {
+			t.Errorf("%v: node %d, want %d", test.v, got, test.want)
+		}
+	}
+}
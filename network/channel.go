@@ -0,0 +1,183 @@
+package network
+
+import (
+	"time"
+)
+
+const (
+	// Maximum number of reliable packets waiting for an ACK on one channel
+	reliableWindowSize = 64
+	// Reliable packets further ahead than this are treated as duplicates of old ones
+	incomingWindowSize = 0x4000
+
+	resendTimeoutInitial = 500 * time.Millisecond
+	resendTimeoutMax     = 4 * time.Second
+	splitTimeout         = 30 * time.Second
+)
+
+// reliablePacket is an outgoing reliable packet that has not been acknowledged yet
+type reliablePacket struct {
+	body    []byte
+	sent    time.Time
+	timeout time.Duration
+	resends int
+}
+
+// splitBuffer collects the chunks of a split payload
+type splitBuffer struct {
+	chunks   [][]byte
+	received int
+	reliable bool
+	updated  time.Time
+}
+
+// channel holds the sequence numbers, resend queue and reassembly buffers of a single channel
+type channel struct {
+	nextOutgoingSeqnum uint16
+	outgoing           map[uint16]*reliablePacket
+	queue              [][]byte
+
+	nextIncomingSeqnum uint16
+	incoming           map[uint16][]byte
+
+	nextSplitSeqnum uint16
+	splits          map[uint16]*splitBuffer
+}
+
+// newChannel initializes a channel with the protocol's starting sequence numbers
+func newChannel() *channel {
+	return &channel{
+		nextOutgoingSeqnum: SeqnumInitial,
+		outgoing:           make(map[uint16]*reliablePacket),
+		nextIncomingSeqnum: SeqnumInitial,
+		incoming:           make(map[uint16][]byte),
+		nextSplitSeqnum:    SeqnumInitial,
+		splits:             make(map[uint16]*splitBuffer),
+	}
+}
+
+// queueReliable assigns sequence numbers to bodies and returns those that fit in the window
+func (ch *channel) queueReliable(bodies ...[]byte) [][]byte {
+	ch.queue = append(ch.queue, bodies...)
+	return ch.flushQueue(time.Now())
+}
+
+// flushQueue moves queued bodies into the resend window while there is room
+func (ch *channel) flushQueue(now time.Time) [][]byte {
+	var ready [][]byte
+	for len(ch.queue) > 0 && len(ch.outgoing) < reliableWindowSize {
+		seqnum := ch.nextOutgoingSeqnum
+		ch.nextOutgoingSeqnum++
+		body := makeReliable(seqnum, ch.queue[0])
+		ch.queue = ch.queue[1:]
+		ch.outgoing[seqnum] = &reliablePacket{body: body, sent: now, timeout: resendTimeoutInitial}
+		ready = append(ready, body)
+	}
+	return ready
+}
+
+// ack removes an acknowledged packet from the window and returns newly sendable bodies
+func (ch *channel) ack(seqnum uint16, now time.Time) [][]byte {
+	delete(ch.outgoing, seqnum)
+	return ch.flushQueue(now)
+}
+
+// timedOut returns the bodies whose resend timer has expired and rearms their timers
+func (ch *channel) timedOut(now time.Time) [][]byte {
+	var resend [][]byte
+	for _, p := range ch.outgoing {
+		if now.Sub(p.sent) < p.timeout {
+			continue
+		}
+		p.sent = now
+		p.resends++
+		p.timeout *= 2
+		if p.timeout > resendTimeoutMax {
+			p.timeout = resendTimeoutMax
+		}
+		resend = append(resend, p.body)
+	}
+	return resend
+}
+
+// receiveReliable stores an incoming reliable body and returns the bodies that are now in order
+func (ch *channel) receiveReliable(seqnum uint16, body []byte) [][]byte {
+	if seqnum != ch.nextIncomingSeqnum {
+		if seqnum-ch.nextIncomingSeqnum < incomingWindowSize {
+			ch.incoming[seqnum] = body
+		}
+		return nil
+	}
+
+	ready := [][]byte{body}
+	ch.nextIncomingSeqnum++
+	for {
+		next, ok := ch.incoming[ch.nextIncomingSeqnum]
+		if !ok {
+			break
+		}
+		delete(ch.incoming, ch.nextIncomingSeqnum)
+		ready = append(ready, next)
+		ch.nextIncomingSeqnum++
+	}
+	return ready
+}
+
+// makeSplits cuts a payload into split packet bodies no larger than chunkSizeMax
+func (ch *channel) makeSplits(data []byte, chunkSizeMax int) [][]byte {
+	payloadMax := chunkSizeMax - SplitHeaderSize
+	chunkCount := (len(data) + payloadMax - 1) / payloadMax
+
+	seqnum := ch.nextSplitSeqnum
+	ch.nextSplitSeqnum++
+
+	bodies := make([][]byte, 0, chunkCount)
+	for i := 0; i < chunkCount; i++ {
+		end := min((i+1)*payloadMax, len(data))
+		bodies = append(bodies, makeSplit(seqnum, uint16(chunkCount), uint16(i), data[i*payloadMax:end]))
+	}
+	return bodies
+}
+
+// receiveSplit stores one chunk and returns the reassembled payload once every chunk arrived
+func (ch *channel) receiveSplit(seqnum, chunkCount, chunkNum uint16, data []byte, reliable bool, now time.Time) []byte {
+	if chunkCount == 0 || chunkNum >= chunkCount {
+		return nil
+	}
+
+	buf, ok := ch.splits[seqnum]
+	if !ok {
+		buf = &splitBuffer{chunks: make([][]byte, chunkCount), reliable: reliable}
+		ch.splits[seqnum] = buf
+	}
+	if len(buf.chunks) != int(chunkCount) || buf.chunks[chunkNum] != nil {
+		return nil
+	}
+
+	buf.chunks[chunkNum] = append([]byte(nil), data...)
+	buf.received++
+	buf.updated = now
+	if buf.received < len(buf.chunks) {
+		return nil
+	}
+
+	delete(ch.splits, seqnum)
+	size := 0
+	for _, c := range buf.chunks {
+		size += len(c)
+	}
+	payload := make([]byte, 0, size)
+	for _, c := range buf.chunks {
+		payload = append(payload, c...)
+	}
+	return payload
+}
+
+// dropStaleSplits discards unreliable split payloads that never completed
+func (ch *channel) dropStaleSplits(now time.Time) {
+	for seqnum, buf := range ch.splits {
+		if !buf.reliable && now.Sub(buf.updated) > splitTimeout {
+			delete(ch.splits, seqnum)
+		}
+	}
+}
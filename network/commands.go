@@ -0,0 +1,198 @@
+package network
+
+// Commands sent from the server to the client
+const (
+	ToClientHello                 = uint16(0x02)
+	ToClientAuthAccept            = uint16(0x03)
+	ToClientAcceptSudoMode        = uint16(0x04)
+	ToClientDenySudoMode          = uint16(0x05)
+	ToClientAccessDenied          = uint16(0x0A)
+	ToClientBlockData             = uint16(0x20)
+	ToClientAddNode               = uint16(0x21)
+	ToClientRemoveNode            = uint16(0x22)
+	ToClientInventory             = uint16(0x27)
+	ToClientTimeOfDay             = uint16(0x29)
+	ToClientCSMRestrictionFlags   = uint16(0x2A)
+	ToClientPlayerSpeed           = uint16(0x2B)
+	ToClientMediaPush             = uint16(0x2C)
+	ToClientChatMessage           = uint16(0x2F)
+	ToClientActiveObjectRemoveAdd = uint16(0x31)
+	ToClientActiveObjectMessages  = uint16(0x32)
+	ToClientHP                    = uint16(0x33)
+	ToClientMovePlayer            = uint16(0x34)
+	ToClientAccessDeniedLegacy    = uint16(0x35)
+	ToClientFOV                   = uint16(0x36)
+	ToClientDeathScreen           = uint16(0x37)
+	ToClientMedia                 = uint16(0x38)
+	ToClientNodeDef               = uint16(0x3a)
+	ToClientAnnounceMedia         = uint16(0x3c)
+	ToClientItemDef               = uint16(0x3d)
+	ToClientPlaySound             = uint16(0x3f)
+	ToClientStopSound             = uint16(0x40)
+	ToClientPrivileges            = uint16(0x41)
+	ToClientInventoryFormspec     = uint16(0x42)
+	ToClientDetachedInventory     = uint16(0x43)
+	ToClientShowFormspec          = uint16(0x44)
+	ToClientMovement              = uint16(0x45)
+	ToClientSpawnParticle         = uint16(0x46)
+	ToClientAddParticleSpawner    = uint16(0x47)
+	ToClientHudAdd                = uint16(0x49)
+	ToClientHudRm                 = uint16(0x4a)
+	ToClientHudChange             = uint16(0x4b)
+	ToClientHudSetFlags           = uint16(0x4c)
+	ToClientHudSetParam           = uint16(0x4d)
+	ToClientBreath                = uint16(0x4e)
+	ToClientSetSky                = uint16(0x4f)
+	ToClientOverrideDayNightRatio = uint16(0x50)
+	ToClientLocalPlayerAnimations = uint16(0x51)
+	ToClientEyeOffset             = uint16(0x52)
+	ToClientDeleteParticleSpawner = uint16(0x53)
+	ToClientCloudParams           = uint16(0x54)
+	ToClientFadeSound             = uint16(0x55)
+	ToClientUpdatePlayerList      = uint16(0x56)
+	ToClientModChannelMsg         = uint16(0x57)
+	ToClientModChannelSignal      = uint16(0x58)
+	ToClientNodeMetaChanged       = uint16(0x59)
+	ToClientSetSun                = uint16(0x5a)
+	ToClientSetMoon               = uint16(0x5b)
+	ToClientSetStars              = uint16(0x5c)
+	ToClientSRPBytesSB            = uint16(0x60)
+	ToClientFormspecPrepend       = uint16(0x61)
+	ToClientMinimapModes          = uint16(0x62)
+	ToClientSetLighting           = uint16(0x63)
+)
+
+// Commands sent from the client to the server
+const (
+	ToServerInit             = uint16(0x02)
+	ToServerInit2            = uint16(0x11)
+	ToServerModChannelJoin   = uint16(0x17)
+	ToServerModChannelLeave  = uint16(0x18)
+	ToServerModChannelMsg    = uint16(0x19)
+	ToServerPlayerPos        = uint16(0x23)
+	ToServerGotBlocks        = uint16(0x24)
+	ToServerDeletedBlocks    = uint16(0x25)
+	ToServerInventoryAction  = uint16(0x31)
+	ToServerChatMessage      = uint16(0x32)
+	ToServerDamage           = uint16(0x35)
+	ToServerPlayerItem       = uint16(0x37)
+	ToServerRespawn          = uint16(0x38)
+	ToServerInteract         = uint16(0x39)
+	ToServerRemovedSounds    = uint16(0x3a)
+	ToServerNodeMetaFields   = uint16(0x3b)
+	ToServerInventoryFields  = uint16(0x3c)
+	ToServerRequestMedia     = uint16(0x40)
+	ToServerHaveMedia        = uint16(0x41)
+	ToServerClientReady      = uint16(0x43)
+	ToServerFirstSRP         = uint16(0x50)
+	ToServerSRPBytesA        = uint16(0x51)
+	ToServerSRPBytesM        = uint16(0x52)
+	ToServerUpdateClientInfo = uint16(0x53)
+)
+
+// CommandInfo describes a command and the channel it travels on
+type CommandInfo struct {
+	Name     string
+	Channel  uint8
+	Reliable bool
+}
+
+// ToClientCommands lists every known server to client command
+var ToClientCommands = map[uint16]CommandInfo{
+	ToClientHello:                 {"TOCLIENT_HELLO", 0, true},
+	ToClientAuthAccept:            {"TOCLIENT_AUTH_ACCEPT", 0, true},
+	ToClientAcceptSudoMode:        {"TOCLIENT_ACCEPT_SUDO_MODE", 0, true},
+	ToClientDenySudoMode:          {"TOCLIENT_DENY_SUDO_MODE", 0, true},
+	ToClientAccessDenied:          {"TOCLIENT_ACCESS_DENIED", 0, true},
+	ToClientBlockData:             {"TOCLIENT_BLOCKDATA", 2, true},
+	ToClientAddNode:               {"TOCLIENT_ADDNODE", 0, true},
+	ToClientRemoveNode:            {"TOCLIENT_REMOVENODE", 0, true},
+	ToClientInventory:             {"TOCLIENT_INVENTORY", 0, true},
+	ToClientTimeOfDay:             {"TOCLIENT_TIME_OF_DAY", 0, true},
+	ToClientCSMRestrictionFlags:   {"TOCLIENT_CSM_RESTRICTION_FLAGS", 0, true},
+	ToClientPlayerSpeed:           {"TOCLIENT_PLAYER_SPEED", 0, true},
+	ToClientMediaPush:             {"TOCLIENT_MEDIA_PUSH", 0, true},
+	ToClientChatMessage:           {"TOCLIENT_CHAT_MESSAGE", 0, true},
+	ToClientActiveObjectRemoveAdd: {"TOCLIENT_ACTIVE_OBJECT_REMOVE_ADD", 0, true},
+	ToClientActiveObjectMessages:  {"TOCLIENT_ACTIVE_OBJECT_MESSAGES", 0, true},
+	ToClientHP:                    {"TOCLIENT_HP", 0, true},
+	ToClientMovePlayer:            {"TOCLIENT_MOVE_PLAYER", 0, true},
+	ToClientAccessDeniedLegacy:    {"TOCLIENT_ACCESS_DENIED_LEGACY", 0, true},
+	ToClientFOV:                   {"TOCLIENT_FOV", 0, true},
+	ToClientDeathScreen:           {"TOCLIENT_DEATHSCREEN", 0, true},
+	ToClientMedia:                 {"TOCLIENT_MEDIA", 2, true},
+	ToClientNodeDef:               {"TOCLIENT_NODEDEF", 0, true},
+	ToClientAnnounceMedia:         {"TOCLIENT_ANNOUNCE_MEDIA", 0, true},
+	ToClientItemDef:               {"TOCLIENT_ITEMDEF", 0, true},
+	ToClientPlaySound:             {"TOCLIENT_PLAY_SOUND", 0, true},
+	ToClientStopSound:             {"TOCLIENT_STOP_SOUND", 0, true},
+	ToClientPrivileges:            {"TOCLIENT_PRIVILEGES", 0, true},
+	ToClientInventoryFormspec:     {"TOCLIENT_INVENTORY_FORMSPEC", 0, true},
+	ToClientDetachedInventory:     {"TOCLIENT_DETACHED_INVENTORY", 0, true},
+	ToClientShowFormspec:          {"TOCLIENT_SHOW_FORMSPEC", 0, true},
+	ToClientMovement:              {"TOCLIENT_MOVEMENT", 0, true},
+	ToClientSpawnParticle:         {"TOCLIENT_SPAWN_PARTICLE", 0, true},
+	ToClientAddParticleSpawner:    {"TOCLIENT_ADD_PARTICLESPAWNER", 0, true},
+	ToClientHudAdd:                {"TOCLIENT_HUDADD", 1, true},
+	ToClientHudRm:                 {"TOCLIENT_HUDRM", 1, true},
+	ToClientHudChange:             {"TOCLIENT_HUDCHANGE", 1, true},
+	ToClientHudSetFlags:           {"TOCLIENT_HUD_SET_FLAGS", 1, true},
+	ToClientHudSetParam:           {"TOCLIENT_HUD_SET_PARAM", 1, true},
+	ToClientBreath:                {"TOCLIENT_BREATH", 0, true},
+	ToClientSetSky:                {"TOCLIENT_SET_SKY", 0, true},
+	ToClientOverrideDayNightRatio: {"TOCLIENT_OVERRIDE_DAY_NIGHT_RATIO", 0, true},
+	ToClientLocalPlayerAnimations: {"TOCLIENT_LOCAL_PLAYER_ANIMATIONS", 0, true},
+	ToClientEyeOffset:             {"TOCLIENT_EYE_OFFSET", 0, true},
+	ToClientDeleteParticleSpawner: {"TOCLIENT_DELETE_PARTICLESPAWNER", 0, true},
+	ToClientCloudParams:           {"TOCLIENT_CLOUD_PARAMS", 0, true},
+	ToClientFadeSound:             {"TOCLIENT_FADE_SOUND", 0, true},
+	ToClientUpdatePlayerList:      {"TOCLIENT_UPDATE_PLAYER_LIST", 0, true},
+	ToClientModChannelMsg:         {"TOCLIENT_MODCHANNEL_MSG", 0, true},
+	ToClientModChannelSignal:      {"TOCLIENT_MODCHANNEL_SIGNAL", 0, true},
+	ToClientNodeMetaChanged:       {"TOCLIENT_NODEMETA_CHANGED", 0, true},
+	ToClientSetSun:                {"TOCLIENT_SET_SUN", 0, true},
+	ToClientSetMoon:               {"TOCLIENT_SET_MOON", 0, true},
+	ToClientSetStars:              {"TOCLIENT_SET_STARS", 0, true},
+	ToClientSRPBytesSB:            {"TOCLIENT_SRP_BYTES_S_B", 0, true},
+	ToClientFormspecPrepend:       {"TOCLIENT_FORMSPEC_PREPEND", 0, true},
+	ToClientMinimapModes:          {"TOCLIENT_MINIMAP_MODES", 0, true},
+	ToClientSetLighting:           {"TOCLIENT_SET_LIGHTING", 0, true},
+}
+
+// ToServerCommands lists every known client to server command
+var ToServerCommands = map[uint16]CommandInfo{
+	ToServerInit:             {"TOSERVER_INIT", 1, false},
+	ToServerInit2:            {"TOSERVER_INIT2", 1, true},
+	ToServerModChannelJoin:   {"TOSERVER_MODCHANNEL_JOIN", 0, true},
+	ToServerModChannelLeave:  {"TOSERVER_MODCHANNEL_LEAVE", 0, true},
+	ToServerModChannelMsg:    {"TOSERVER_MODCHANNEL_MSG", 0, true},
+	ToServerPlayerPos:        {"TOSERVER_PLAYERPOS", 0, false},
+	ToServerGotBlocks:        {"TOSERVER_GOTBLOCKS", 2, true},
+	ToServerDeletedBlocks:    {"TOSERVER_DELETEDBLOCKS", 2, true},
+	ToServerInventoryAction:  {"TOSERVER_INVENTORY_ACTION", 0, true},
+	ToServerChatMessage:      {"TOSERVER_CHAT_MESSAGE", 0, true},
+	ToServerDamage:           {"TOSERVER_DAMAGE", 0, true},
+	ToServerPlayerItem:       {"TOSERVER_PLAYERITEM", 0, true},
+	ToServerRespawn:          {"TOSERVER_RESPAWN", 0, true},
+	ToServerInteract:         {"TOSERVER_INTERACT", 0, true},
+	ToServerRemovedSounds:    {"TOSERVER_REMOVED_SOUNDS", 2, true},
+	ToServerNodeMetaFields:   {"TOSERVER_NODEMETA_FIELDS", 0, true},
+	ToServerInventoryFields:  {"TOSERVER_INVENTORY_FIELDS", 0, true},
+	ToServerRequestMedia:     {"TOSERVER_REQUEST_MEDIA", 1, true},
+	ToServerHaveMedia:        {"TOSERVER_HAVE_MEDIA", 2, true},
+	ToServerClientReady:      {"TOSERVER_CLIENT_READY", 1, true},
+	ToServerFirstSRP:         {"TOSERVER_FIRST_SRP", 1, true},
+	ToServerSRPBytesA:        {"TOSERVER_SRP_BYTES_A", 1, true},
+	ToServerSRPBytesM:        {"TOSERVER_SRP_BYTES_M", 1, true},
+	ToServerUpdateClientInfo: {"TOSERVER_UPDATE_CLIENT_INFO", 1, true},
+}
+
+// SendCommand sends a packet built with NewWriter using the channel and reliability of its command
+func SendCommand(c Transport, commands map[uint16]CommandInfo, w *Writer) error {
+	data := w.Bytes()
+	info, ok := commands[uint16(data[0])<<8|uint16(data[1])]
+	if !ok {
+		info = CommandInfo{Channel: 0, Reliable: true}
+	}
+	return c.Send(info.Channel, data, info.Reliable)
+}
@@ -0,0 +1,367 @@
+package network
+
+import (
+	"encoding/binary"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	peerTimeout   = 30 * time.Second
+	pingInterval  = 5 * time.Second
+	timerInterval = 100 * time.Millisecond
+	incomingQueue = 1024
+)
+
+// outgoing is a packet body waiting to be written on a channel
+type outgoing struct {
+	channel uint8
+	body    []byte
+}
+
+// Conn is a connection to a single remote peer over the Minetest UDP transport
+type Conn struct {
+	pc     net.PacketConn
+	addr   net.Addr
+	ownsPC bool
+
+	mu           sync.Mutex
+	localPeerID  uint16
+	remotePeerID uint16
+	channels     [ChannelCount]*channel
+	lastRecv     time.Time
+	lastSend     time.Time
+
+	incoming  chan Packet
+	closed    chan struct{}
+	closeOnce sync.Once
+	closeErr  error
+	onClose   func()
+}
+
+// newConn creates the shared state of client and server side connections
+func newConn(pc net.PacketConn, addr net.Addr, localPeerID, remotePeerID uint16) *Conn {
+	c := &Conn{
+		pc:           pc,
+		addr:         addr,
+		localPeerID:  localPeerID,
+		remotePeerID: remotePeerID,
+		lastRecv:     time.Now(),
+		lastSend:     time.Now(),
+		incoming:     make(chan Packet, incomingQueue),
+		closed:       make(chan struct{}),
+	}
+	for i := range c.channels {
+		c.channels[i] = newChannel()
+	}
+	return c
+}
+
+// Dial connects to a Minetest server at the given host:port address
+func Dial(address string) (*Conn, error) {
+	raddr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	pc, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		return nil, err
+	}
+	c, err := NewClientConn(pc, raddr)
+	if err != nil {
+		pc.Close()
+		return nil, err
+	}
+	c.ownsPC = true
+	return c, nil
+}
+
+// NewClientConn starts a client connection over an existing packet connection, which lets callers
+// substitute loopback or fault injecting transports
+func NewClientConn(pc net.PacketConn, addr net.Addr) (*Conn, error) {
+	c := newConn(pc, addr, PeerIDInexistent, PeerIDServer)
+	go c.readLoop()
+	go c.timerLoop()
+
+	// An empty reliable packet makes the server assign us a peer ID
+	if err := c.Send(0, nil, true); err != nil {
+		c.shutdown(err)
+		return nil, err
+	}
+	return c, nil
+}
+
+// PeerID returns the peer ID the remote side knows us by
+func (c *Conn) PeerID() uint16 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.localPeerID
+}
+
+// RemotePeerID returns the peer ID of the remote side
+func (c *Conn) RemotePeerID() uint16 {
+	return c.remotePeerID
+}
+
+// RemoteAddr returns the address of the remote peer
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.addr
+}
+
+// Done is closed once the connection has shut down
+func (c *Conn) Done() <-chan struct{} {
+	return c.closed
+}
+
+// Err returns the reason the connection shut down, or nil while it is open
+func (c *Conn) Err() error {
+	select {
+	case <-c.closed:
+		return c.closeErr
+	default:
+		return nil
+	}
+}
+
+// Send queues a payload on a channel, splitting it into several datagrams if necessary
+func (c *Conn) Send(channelNum uint8, data []byte, reliable bool) error {
+	if channelNum >= ChannelCount {
+		return ErrInvalidChan
+	}
+
+	chunkSizeMax := MaxPacketSize - BaseHeaderSize
+	if reliable {
+		chunkSizeMax -= ReliableHeaderSize
+	}
+
+	c.mu.Lock()
+	ch := c.channels[channelNum]
+	var bodies [][]byte
+	if len(data)+OriginalHeaderSize <= chunkSizeMax {
+		bodies = [][]byte{makeOriginal(data)}
+	} else {
+		bodies = ch.makeSplits(data, chunkSizeMax)
+	}
+	if reliable {
+		bodies = ch.queueReliable(bodies...)
+	}
+	c.mu.Unlock()
+
+	return c.write(toOutgoing(channelNum, bodies))
+}
+
+// Recv blocks until a complete payload has been received
+func (c *Conn) Recv() (Packet, error) {
+	select {
+	case p := <-c.incoming:
+		return p, nil
+	case <-c.closed:
+		select {
+		case p := <-c.incoming:
+			return p, nil
+		default:
+			return Packet{}, c.closeErr
+		}
+	}
+}
+
+// Close notifies the remote peer and shuts the connection down
+func (c *Conn) Close() error {
+	if c.Err() != nil {
+		return nil
+	}
+	err := c.write([]outgoing{{channel: 0, body: makeControl(controlDisco)}})
+	c.shutdown(ErrClosed)
+	return err
+}
+
+// shutdown releases the connection's resources exactly once
+func (c *Conn) shutdown(err error) {
+	c.closeOnce.Do(func() {
+		c.closeErr = err
+		close(c.closed)
+		if c.ownsPC {
+			c.pc.Close()
+		}
+		if c.onClose != nil {
+			c.onClose()
+		}
+	})
+}
+
+// write sends packet bodies to the remote peer with the current peer ID in the header
+func (c *Conn) write(packets []outgoing) error {
+	if len(packets) == 0 {
+		return nil
+	}
+	c.mu.Lock()
+	peerID := c.localPeerID
+	c.lastSend = time.Now()
+	c.mu.Unlock()
+
+	for _, p := range packets {
+		if _, err := c.pc.WriteTo(buildDatagram(peerID, p.channel, p.body), c.addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readLoop reads datagrams for connections that own their packet connection
+func (c *Conn) readLoop() {
+	buf := make([]byte, 0x10000)
+	for {
+		n, addr, err := c.pc.ReadFrom(buf)
+		if err != nil {
+			c.shutdown(err)
+			return
+		}
+		if addr.String() != c.addr.String() {
+			continue
+		}
+		d, err := parseDatagram(buf[:n])
+		if err != nil {
+			continue
+		}
+		// The body is retained by reassembly buffers, so it must not alias the read buffer
+		d.body = append([]byte(nil), d.body...)
+		c.handleDatagram(d)
+	}
+}
+
+// timerLoop resends unacknowledged packets, keeps the connection alive and detects timeouts
+func (c *Conn) timerLoop() {
+	ticker := time.NewTicker(timerInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.closed:
+			return
+		case now := <-ticker.C:
+			c.mu.Lock()
+			var packets []outgoing
+			for i, ch := range c.channels {
+				packets = append(packets, toOutgoing(uint8(i), ch.timedOut(now))...)
+				ch.dropStaleSplits(now)
+			}
+			timedOut := now.Sub(c.lastRecv) > peerTimeout
+			needPing := now.Sub(c.lastSend) > pingInterval
+			if needPing {
+				packets = append(packets, toOutgoing(0, c.channels[0].queueReliable(makeControl(controlPing)))...)
+			}
+			c.mu.Unlock()
+
+			if timedOut {
+				c.shutdown(ErrTimeout)
+				return
+			}
+			if err := c.write(packets); err != nil {
+				c.shutdown(err)
+				return
+			}
+		}
+	}
+}
+
+// handleDatagram processes one datagram addressed to this connection
+func (c *Conn) handleDatagram(d datagram) {
+	now := time.Now()
+	c.mu.Lock()
+	c.lastRecv = now
+	var packets []outgoing
+	var payloads []Packet
+	disconnected := c.processBody(d.channel, d.body, false, now, &packets, &payloads)
+	c.mu.Unlock()
+
+	if err := c.write(packets); err != nil {
+		c.shutdown(err)
+		return
+	}
+	for _, p := range payloads {
+		select {
+		case c.incoming <- p:
+		case <-c.closed:
+			return
+		}
+	}
+	if disconnected {
+		c.shutdown(ErrClosed)
+	}
+}
+
+// processBody decodes a packet body, collecting replies and completed payloads; c.mu must be held.
+// It returns true if the peer asked to disconnect.
+func (c *Conn) processBody(channelNum uint8, body []byte, reliable bool, now time.Time, packets *[]outgoing, payloads *[]Packet) bool {
+	if len(body) < 1 {
+		return false
+	}
+	ch := c.channels[channelNum]
+
+	switch body[0] {
+	case packetControl:
+		if len(body) < 2 {
+			return false
+		}
+		switch body[1] {
+		case controlAck:
+			if len(body) < 4 {
+				return false
+			}
+			seqnum := binary.BigEndian.Uint16(body[2:4])
+			*packets = append(*packets, toOutgoing(channelNum, ch.ack(seqnum, now))...)
+		case controlSetPeerID:
+			if len(body) < 4 {
+				return false
+			}
+			if c.localPeerID == PeerIDInexistent {
+				c.localPeerID = binary.BigEndian.Uint16(body[2:4])
+			}
+		case controlPing:
+			// Nothing to do, receiving it already refreshed the timeout
+		case controlDisco:
+			return true
+		}
+
+	case packetOriginal:
+		if len(body) > OriginalHeaderSize {
+			*payloads = append(*payloads, Packet{Channel: channelNum, Data: body[OriginalHeaderSize:], Reliable: reliable})
+		}
+
+	case packetSplit:
+		if len(body) < SplitHeaderSize {
+			return false
+		}
+		seqnum := binary.BigEndian.Uint16(body[1:3])
+		chunkCount := binary.BigEndian.Uint16(body[3:5])
+		chunkNum := binary.BigEndian.Uint16(body[5:7])
+		if data := ch.receiveSplit(seqnum, chunkCount, chunkNum, body[SplitHeaderSize:], reliable, now); data != nil {
+			*payloads = append(*payloads, Packet{Channel: channelNum, Data: data, Reliable: reliable})
+		}
+
+	case packetReliable:
+		if reliable || len(body) < ReliableHeaderSize {
+			return false
+		}
+		seqnum := binary.BigEndian.Uint16(body[1:3])
+		*packets = append(*packets, outgoing{channel: channelNum, body: makeControl(controlAck, seqnum)})
+
+		disconnected := false
+		for _, inner := range ch.receiveReliable(seqnum, body[ReliableHeaderSize:]) {
+			if c.processBody(channelNum, inner, true, now, packets, payloads) {
+				disconnected = true
+			}
+		}
+		return disconnected
+	}
+	return false
+}
+
+// toOutgoing tags packet bodies with the channel they are sent on
+func toOutgoing(channelNum uint8, bodies [][]byte) []outgoing {
+	packets := make([]outgoing, len(bodies))
+	for i, b := range bodies {
+		packets[i] = outgoing{channel: channelNum, body: b}
+	}
+	return packets
+}
@@ -0,0 +1,265 @@
+package network
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// faultyConn is a packet connection that drops the datagrams drop returns true for
+type faultyConn struct {
+	net.PacketConn
+	mu      sync.Mutex
+	drop    func(b []byte) bool
+	written [][]byte
+}
+
+func (f *faultyConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	f.mu.Lock()
+	f.written = append(f.written, append([]byte(nil), b...))
+	drop := f.drop != nil && f.drop(b)
+	f.mu.Unlock()
+	if drop {
+		return len(b), nil
+	}
+	return f.PacketConn.WriteTo(b, addr)
+}
+
+// count returns how many datagrams match reports true for were written, dropped or not
+func (f *faultyConn) count(match func(b []byte) bool) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, b := range f.written {
+		if match(b) {
+			n++
+		}
+	}
+	return n
+}
+
+func listenLoopback(t *testing.T) net.PacketConn {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pc
+}
+
+// connect connects a client to a listener over loopback, each writing through its own faultyConn
+func connect(t *testing.T) (client, server *Conn, clientPC, serverPC *faultyConn) {
+	t.Helper()
+	serverPC = &faultyConn{PacketConn: listenLoopback(t)}
+	l := NewListener(serverPC)
+	t.Cleanup(func() { l.Close() })
+
+	clientPC = &faultyConn{PacketConn: listenLoopback(t)}
+	client, err := NewClientConn(clientPC, serverPC.LocalAddr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.ownsPC = true
+	t.Cleanup(func() { client.Close() })
+
+	accepted := make(chan *Conn, 1)
+	go func() {
+		c, err := l.Accept()
+		if err == nil {
+			accepted <- c
+		}
+	}()
+	select {
+	case server = <-accepted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no connection accepted")
+	}
+
+	// The client knows its peer ID once the server's SET_PEER_ID arrived
+	deadline := time.Now().Add(5 * time.Second)
+	for client.PeerID() == PeerIDInexistent {
+		if time.Now().After(deadline) {
+			t.Fatal("client got no peer ID")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if client.PeerID() != server.RemotePeerID() {
+		t.Fatalf("client peer ID %d, server knows it as %d", client.PeerID(), server.RemotePeerID())
+	}
+	return client, server, clientPC, serverPC
+}
+
+// recv receives a packet or fails the test after a timeout
+func recv(t *testing.T, c *Conn) Packet {
+	t.Helper()
+	got := make(chan Packet, 1)
+	errs := make(chan error, 1)
+	go func() {
+		p, err := c.Recv()
+		if err != nil {
+			errs <- err
+			return
+		}
+		got <- p
+	}()
+	select {
+	case p := <-got:
+		return p
+	case err := <-errs:
+		t.Fatal(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a packet")
+	}
+	return Packet{}
+}
+
+// payload returns data unique to the index i, of the given size
+func payload(i, size int) []byte {
+	data := make([]byte, size)
+	for j := range data {
+		data[j] = byte(i + j)
+	}
+	binary.BigEndian.PutUint16(data, uint16(i))
+	return data
+}
+
+// isAck reports whether a datagram is an ACK control packet
+func isAck(b []byte) bool {
+	return len(b) > BaseHeaderSize+1 && b[BaseHeaderSize] == packetControl && b[BaseHeaderSize+1] == controlAck
+}
+
+func TestReliableInOrder(t *testing.T) {
+	client, server, _, _ := connect(t)
+	const count = 200
+	for i := range count {
+		if err := client.Send(1, payload(i, 20), true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := range count {
+		p := recv(t, server)
+		if p.Channel != 1 || !p.Reliable || !bytes.Equal(p.Data, payload(i, 20)) {
+			t.Fatalf("packet %d: got channel %d reliable %v data %x", i, p.Channel, p.Reliable, p.Data[:2])
+		}
+	}
+}
+
+func TestResendAfterDroppedAck(t *testing.T) {
+	client, server, clientPC, serverPC := connect(t)
+
+	var dropped bool
+	serverPC.mu.Lock()
+	serverPC.drop = func(b []byte) bool {
+		if !dropped && isAck(b) {
+			dropped = true
+			return true
+		}
+		return false
+	}
+	serverPC.mu.Unlock()
+
+	data := payload(7, 30)
+	if err := client.Send(2, data, true); err != nil {
+		t.Fatal(err)
+	}
+	if p := recv(t, server); !bytes.Equal(p.Data, data) {
+		t.Fatalf("got %x, want %x", p.Data, data)
+	}
+
+	// The client resends until the ACK of the resent packet arrives
+	isData := func(b []byte) bool {
+		return len(b) > BaseHeaderSize && b[BaseHeaderSize] == packetReliable && bytes.Contains(b, data)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		client.mu.Lock()
+		pending := len(client.channels[2].outgoing)
+		client.mu.Unlock()
+		if pending == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("packet never acknowledged")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if n := clientPC.count(isData); n < 2 {
+		t.Fatalf("packet sent %d times, want a resend", n)
+	}
+
+	// The resent copy must not be delivered a second time
+	next := payload(8, 30)
+	if err := client.Send(2, next, true); err != nil {
+		t.Fatal(err)
+	}
+	if p := recv(t, server); !bytes.Equal(p.Data, next) {
+		t.Fatalf("got %x after the resend, want the next packet %x", p.Data, next)
+	}
+}
+
+func TestSplitReassembly(t *testing.T) {
+	client, server, clientPC, _ := connect(t)
+	for _, reliable := range []bool{true, false} {
+		for _, size := range []int{MaxPacketSize, 3000, 70000} {
+			data := payload(size, size)
+			before := clientPC.count(func([]byte) bool { return true })
+			if err := client.Send(0, data, reliable); err != nil {
+				t.Fatal(err)
+			}
+			if sent := clientPC.count(func([]byte) bool { return true }) - before; sent < 2 {
+				t.Fatalf("%d bytes went out in %d datagram, want them split", size, sent)
+			}
+			p := recv(t, server)
+			if p.Reliable != reliable || !bytes.Equal(p.Data, data) {
+				t.Fatalf("%d bytes reliable %v: got %d bytes reliable %v", size, reliable, len(p.Data), p.Reliable)
+			}
+		}
+	}
+}
+
+func TestSeqnumWraparound(t *testing.T) {
+	client, server, _, _ := connect(t)
+	// Sequence numbers start at 65500, so these wrap past 65535 while more are queued than the window holds
+	const count = 300
+	for i := range count {
+		if err := client.Send(1, payload(i, 10), true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := range count {
+		if p := recv(t, server); !bytes.Equal(p.Data, payload(i, 10)) {
+			t.Fatalf("packet %d out of order across the wrap-around", i)
+		}
+	}
+
+	server.mu.Lock()
+	next := server.channels[1].nextIncomingSeqnum
+	server.mu.Unlock()
+	want := uint16(SeqnumInitial)
+	want += count
+	if next != want {
+		t.Fatalf("next incoming seqnum %d, want %d", next, want)
+	}
+}
+
+func TestReceiveReliableReordered(t *testing.T) {
+	ch := newChannel()
+	first := uint16(SeqnumInitial + 35) // 65535
+	second := first + 1
+	if ready := ch.receiveReliable(second, []byte("b")); ready != nil {
+		t.Fatalf("delivered %q ahead of its predecessor", ready)
+	}
+	for s := SeqnumInitial; s != first; s++ {
+		ch.receiveReliable(s, nil)
+	}
+	ready := ch.receiveReliable(first, []byte("a"))
+	if len(ready) != 2 || string(ready[0]) != "a" || string(ready[1]) != "b" {
+		t.Fatalf("got %q, want a then b", ready)
+	}
+	if !seqnumHigher(0, 65535) || seqnumHigher(65535, 0) {
+		t.Fatal("seqnumHigher ignores the wrap-around")
+	}
+}
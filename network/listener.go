@@ -0,0 +1,162 @@
+package network
+
+import (
+	"net"
+	"sync"
+)
+
+// Listener accepts Minetest client connections on a single UDP socket
+type Listener struct {
+	pc net.PacketConn
+
+	mu         sync.Mutex
+	peers      map[string]*Conn
+	nextPeerID uint16
+
+	accept    chan *Conn
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// Listen starts accepting connections on the given UDP address
+func Listen(address string) (*Listener, error) {
+	pc, err := net.ListenPacket("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	return NewListener(pc), nil
+}
+
+// NewListener accepts connections on an existing packet connection
+func NewListener(pc net.PacketConn) *Listener {
+	l := &Listener{
+		pc:         pc,
+		peers:      make(map[string]*Conn),
+		nextPeerID: PeerIDServer + 1,
+		accept:     make(chan *Conn, 16),
+		closed:     make(chan struct{}),
+	}
+	go l.readLoop()
+	return l
+}
+
+// Addr returns the local address the listener is bound to
+func (l *Listener) Addr() net.Addr {
+	return l.pc.LocalAddr()
+}
+
+// Accept waits for the next client to connect
+func (l *Listener) Accept() (*Conn, error) {
+	select {
+	case c := <-l.accept:
+		return c, nil
+	case <-l.closed:
+		return nil, ErrClosed
+	}
+}
+
+// Close disconnects every peer and releases the socket
+func (l *Listener) Close() error {
+	var err error
+	l.closeOnce.Do(func() {
+		close(l.closed)
+
+		l.mu.Lock()
+		peers := make([]*Conn, 0, len(l.peers))
+		for _, c := range l.peers {
+			peers = append(peers, c)
+		}
+		l.mu.Unlock()
+
+		for _, c := range peers {
+			c.Close()
+		}
+		err = l.pc.Close()
+	})
+	return err
+}
+
+// readLoop dispatches datagrams to their connection, creating one for new peers
+func (l *Listener) readLoop() {
+	buf := make([]byte, 0x10000)
+	for {
+		n, addr, err := l.pc.ReadFrom(buf)
+		if err != nil {
+			l.Close()
+			return
+		}
+		d, err := parseDatagram(buf[:n])
+		if err != nil {
+			continue
+		}
+		d.body = append([]byte(nil), d.body...)
+
+		l.mu.Lock()
+		c, exists := l.peers[addr.String()]
+		l.mu.Unlock()
+
+		if !exists {
+			if d.peerID != PeerIDInexistent {
+				continue
+			}
+			c = l.newPeer(addr)
+			if c == nil {
+				continue
+			}
+		}
+		c.handleDatagram(d)
+	}
+}
+
+// newPeer registers a connection for a new address and tells the client its peer ID
+func (l *Listener) newPeer(addr net.Addr) *Conn {
+	l.mu.Lock()
+	peerID := l.allocPeerID()
+	if peerID == PeerIDInexistent {
+		l.mu.Unlock()
+		return nil
+	}
+	c := newConn(l.pc, addr, PeerIDServer, peerID)
+	key := addr.String()
+	c.onClose = func() {
+		l.mu.Lock()
+		delete(l.peers, key)
+		l.mu.Unlock()
+	}
+	l.peers[key] = c
+	l.mu.Unlock()
+
+	go c.timerLoop()
+
+	c.mu.Lock()
+	packets := toOutgoing(0, c.channels[0].queueReliable(makeControl(controlSetPeerID, peerID)))
+	c.mu.Unlock()
+	c.write(packets)
+
+	select {
+	case l.accept <- c:
+	case <-l.closed:
+		c.shutdown(ErrClosed)
+		return nil
+	}
+	return c
+}
+
+// allocPeerID finds an unused peer ID; l.mu must be held
+func (l *Listener) allocPeerID() uint16 {
+	used := make(map[uint16]bool, len(l.peers))
+	for _, c := range l.peers {
+		used[c.remotePeerID] = true
+	}
+	for i := 0; i < 0xFFFF; i++ {
+		id := l.nextPeerID
+		l.nextPeerID++
+		if l.nextPeerID <= PeerIDServer {
+			l.nextPeerID = PeerIDServer + 1
+		}
+		if id > PeerIDServer && !used[id] {
+			return id
+		}
+	}
+	return PeerIDInexistent
+}
@@ -0,0 +1,136 @@
+package network
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+const (
+	// ProtocolID is the magic number at the start of every Minetest datagram
+	ProtocolID = uint32(0x4f457403)
+
+	// Well-known peer IDs
+	PeerIDInexistent = uint16(0)
+	PeerIDServer     = uint16(1)
+
+	// ChannelCount is the number of independent channels per peer
+	ChannelCount = 3
+
+	// Sizes used when splitting payloads into datagrams
+	MaxPacketSize      = 512
+	BaseHeaderSize     = 7
+	ReliableHeaderSize = 3
+	SplitHeaderSize    = 7
+	OriginalHeaderSize = 1
+
+	// Sequence numbers start just below the wrap-around point, like the reference implementation
+	SeqnumInitial = uint16(65500)
+)
+
+// Packet types, stored in the first byte after the base header
+const (
+	packetControl  = uint8(0)
+	packetOriginal = uint8(1)
+	packetSplit    = uint8(2)
+	packetReliable = uint8(3)
+)
+
+// Control packet types
+const (
+	controlAck       = uint8(0)
+	controlSetPeerID = uint8(1)
+	controlPing      = uint8(2)
+	controlDisco     = uint8(3)
+)
+
+var (
+	ErrInvalidPacket = errors.New("invalid packet")
+	ErrClosed        = errors.New("connection closed")
+	ErrTimeout       = errors.New("connection timed out")
+	ErrInvalidChan   = errors.New("invalid channel")
+)
+
+// Packet is a complete payload received on one of the channels
+type Packet struct {
+	Channel  uint8
+	Data     []byte
+	Reliable bool // Whether the sender had the payload delivered reliably
+}
+
+// datagram is a decoded base header plus the remaining body
+type datagram struct {
+	peerID  uint16
+	channel uint8
+	body    []byte
+}
+
+// parseDatagram checks the base header of a raw datagram
+func parseDatagram(b []byte) (datagram, error) {
+	if len(b) < BaseHeaderSize+1 {
+		return datagram{}, ErrInvalidPacket
+	}
+	if binary.BigEndian.Uint32(b[0:4]) != ProtocolID {
+		return datagram{}, ErrInvalidPacket
+	}
+	d := datagram{
+		peerID:  binary.BigEndian.Uint16(b[4:6]),
+		channel: b[6],
+		body:    b[BaseHeaderSize:],
+	}
+	if d.channel >= ChannelCount {
+		return datagram{}, ErrInvalidChan
+	}
+	return d, nil
+}
+
+// buildDatagram prepends the base header to a packet body
+func buildDatagram(peerID uint16, channel uint8, body []byte) []byte {
+	b := make([]byte, BaseHeaderSize+len(body))
+	binary.BigEndian.PutUint32(b[0:4], ProtocolID)
+	binary.BigEndian.PutUint16(b[4:6], peerID)
+	b[6] = channel
+	copy(b[BaseHeaderSize:], body)
+	return b
+}
+
+// makeReliable wraps a packet body in a reliable header
+func makeReliable(seqnum uint16, body []byte) []byte {
+	b := make([]byte, ReliableHeaderSize+len(body))
+	b[0] = packetReliable
+	binary.BigEndian.PutUint16(b[1:3], seqnum)
+	copy(b[ReliableHeaderSize:], body)
+	return b
+}
+
+// makeOriginal wraps a payload in an original header
+func makeOriginal(data []byte) []byte {
+	b := make([]byte, OriginalHeaderSize+len(data))
+	b[0] = packetOriginal
+	copy(b[OriginalHeaderSize:], data)
+	return b
+}
+
+// makeSplit builds one chunk of a split payload
+func makeSplit(seqnum, chunkCount, chunkNum uint16, data []byte) []byte {
+	b := make([]byte, SplitHeaderSize+len(data))
+	b[0] = packetSplit
+	binary.BigEndian.PutUint16(b[1:3], seqnum)
+	binary.BigEndian.PutUint16(b[3:5], chunkCount)
+	binary.BigEndian.PutUint16(b[5:7], chunkNum)
+	copy(b[SplitHeaderSize:], data)
+	return b
+}
+
+// makeControl builds a control packet with an optional 16 bit argument
+func makeControl(controlType uint8, arg ...uint16) []byte {
+	b := []byte{packetControl, controlType}
+	for _, a := range arg {
+		b = binary.BigEndian.AppendUint16(b, a)
+	}
+	return b
+}
+
+// seqnumHigher reports whether a is newer than b, taking wrap-around into account
+func seqnumHigher(a, b uint16) bool {
+	return a != b && a-b < 0x8000
+}
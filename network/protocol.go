@@ -0,0 +1,56 @@
+package network
+
+const (
+	// Range of MapBlock serialization versions we can read
+	SerializationVersionMin = uint8(25)
+	SerializationVersionMax = uint8(29)
+
+	// Range of network protocol versions we speak
+	ProtocolVersionMin = uint16(37)
+	ProtocolVersionMax = uint16(44)
+
+	// Highest formspec version the client understands
+	FormspecVersion = uint16(7)
+)
+
+// Authentication mechanisms offered in TOCLIENT_HELLO
+const (
+	AuthMechanismNone           = uint32(0)
+	AuthMechanismLegacyPassword = uint32(1 << 0)
+	AuthMechanismSRP            = uint32(1 << 1)
+	AuthMechanismFirstSRP       = uint32(1 << 2)
+)
+
+// Reasons sent with TOCLIENT_ACCESS_DENIED
+const (
+	AccessDeniedWrongPassword = iota
+	AccessDeniedUnexpectedData
+	AccessDeniedSingleplayer
+	AccessDeniedWrongVersion
+	AccessDeniedWrongCharsInName
+	AccessDeniedWrongName
+	AccessDeniedTooManyUsers
+	AccessDeniedEmptyPassword
+	AccessDeniedAlreadyConnected
+	AccessDeniedServerFail
+	AccessDeniedCustomString
+	AccessDeniedShutdown
+	AccessDeniedCrash
+)
+
+// AccessDeniedStrings holds the default message for each access denied reason
+var AccessDeniedStrings = []string{
+	"Invalid password",
+	"Your client sent something the server didn't expect. Try reconnecting or updating your client.",
+	"The server is running in simple singleplayer mode. You cannot connect.",
+	"Your client's version is not supported.\nPlease contact the server administrator.",
+	"Player name contains disallowed characters",
+	"Player name not allowed",
+	"Too many users",
+	"Empty passwords are disallowed. Set a password and try again.",
+	"Another client is connected with this name. If your client closed unexpectedly, try again in a minute.",
+	"Internal server error",
+	"",
+	"Server shutting down",
+	"The server has experienced an internal error. You will now be disconnected.",
+}
@@ -0,0 +1,240 @@
+package network
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"unicode/utf16"
+)
+
+// BS is the number of position units per node used by positions on the wire
+const BS = 10.0
+
+var ErrShortPacket = errors.New("packet too short")
+
+// Reader decodes big-endian Minetest packet fields, remembering the first error it hits
+type Reader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+// NewReader creates a reader over a packet payload
+func NewReader(data []byte) *Reader {
+	return &Reader{data: data}
+}
+
+// Err returns the first error encountered while reading
+func (r *Reader) Err() error {
+	return r.err
+}
+
+// Len returns the number of unread bytes
+func (r *Reader) Len() int {
+	return len(r.data) - r.pos
+}
+
+// Bytes reads n raw bytes
+func (r *Reader) Bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || r.Len() < n {
+		r.err = ErrShortPacket
+		r.pos = len(r.data)
+		return nil
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+// Remaining reads every unread byte
+func (r *Reader) Remaining() []byte {
+	return r.Bytes(r.Len())
+}
+
+func (r *Reader) U8() uint8 {
+	if b := r.Bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *Reader) U16() uint16 {
+	if b := r.Bytes(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *Reader) U32() uint32 {
+	if b := r.Bytes(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *Reader) U64() uint64 {
+	if b := r.Bytes(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (r *Reader) S16() int16 { return int16(r.U16()) }
+func (r *Reader) S32() int32 { return int32(r.U32()) }
+func (r *Reader) F32() float32 {
+	return math.Float32frombits(r.U32())
+}
+func (r *Reader) Bool() bool { return r.U8() != 0 }
+
+// String16 reads a string prefixed with a 16 bit length
+func (r *Reader) String16() string {
+	return string(r.Bytes(int(r.U16())))
+}
+
+// String32 reads a string prefixed with a 32 bit length
+func (r *Reader) String32() string {
+	return string(r.Bytes(int(r.U32())))
+}
+
+// WideString reads UTF-16 text prefixed with its 16 bit code unit count
+func (r *Reader) WideString() string {
+	n := int(r.U16())
+	units := make([]uint16, 0, n)
+	for i := 0; i < n && r.err == nil; i++ {
+		units = append(units, r.U16())
+	}
+	return string(utf16.Decode(units))
+}
+
+// V3F32 reads three floats
+func (r *Reader) V3F32() [3]float32 {
+	return [3]float32{r.F32(), r.F32(), r.F32()}
+}
+
+// V2F32 reads two floats
+func (r *Reader) V2F32() [2]float32 {
+	return [2]float32{r.F32(), r.F32()}
+}
+
+// V3S16 reads three signed 16 bit integers
+func (r *Reader) V3S16() [3]int16 {
+	return [3]int16{r.S16(), r.S16(), r.S16()}
+}
+
+// V3S32 reads three signed 32 bit integers
+func (r *Reader) V3S32() [3]int32 {
+	return [3]int32{r.S32(), r.S32(), r.S32()}
+}
+
+// V2S32 reads two signed 32 bit integers
+func (r *Reader) V2S32() [2]int32 {
+	return [2]int32{r.S32(), r.S32()}
+}
+
+// Writer encodes big-endian Minetest packet fields; the zero value writes a bare payload without a command
+type Writer struct {
+	buf []byte
+}
+
+// NewWriter starts a packet with the given command ID
+func NewWriter(command uint16) *Writer {
+	w := &Writer{}
+	w.U16(command)
+	return w
+}
+
+// Bytes returns the encoded packet
+func (w *Writer) Bytes() []byte {
+	return w.buf
+}
+
+func (w *Writer) Raw(b []byte) *Writer {
+	w.buf = append(w.buf, b...)
+	return w
+}
+
+func (w *Writer) U8(v uint8) *Writer {
+	w.buf = append(w.buf, v)
+	return w
+}
+
+func (w *Writer) U16(v uint16) *Writer {
+	w.buf = binary.BigEndian.AppendUint16(w.buf, v)
+	return w
+}
+
+func (w *Writer) U32(v uint32) *Writer {
+	w.buf = binary.BigEndian.AppendUint32(w.buf, v)
+	return w
+}
+
+func (w *Writer) U64(v uint64) *Writer {
+	w.buf = binary.BigEndian.AppendUint64(w.buf, v)
+	return w
+}
+
+func (w *Writer) S16(v int16) *Writer { return w.U16(uint16(v)) }
+func (w *Writer) S32(v int32) *Writer { return w.U32(uint32(v)) }
+func (w *Writer) F32(v float32) *Writer {
+	return w.U32(math.Float32bits(v))
+}
+
+func (w *Writer) Bool(v bool) *Writer {
+	if v {
+		return w.U8(1)
+	}
+	return w.U8(0)
+}
+
+// String16 writes a string prefixed with a 16 bit length, truncating it if necessary
+func (w *Writer) String16(s string) *Writer {
+	if len(s) > math.MaxUint16 {
+		s = s[:math.MaxUint16]
+	}
+	w.U16(uint16(len(s)))
+	w.buf = append(w.buf, s...)
+	return w
+}
+
+// String32 writes a string prefixed with a 32 bit length
+func (w *Writer) String32(s string) *Writer {
+	w.U32(uint32(len(s)))
+	w.buf = append(w.buf, s...)
+	return w
+}
+
+// WideString writes text as UTF-16 prefixed with its 16 bit code unit count
+func (w *Writer) WideString(s string) *Writer {
+	units := utf16.Encode([]rune(s))
+	if len(units) > math.MaxUint16 {
+		units = units[:math.MaxUint16]
+	}
+	w.U16(uint16(len(units)))
+	for _, u := range units {
+		w.U16(u)
+	}
+	return w
+}
+
+func (w *Writer) V3F32(v [3]float32) *Writer {
+	return w.F32(v[0]).F32(v[1]).F32(v[2])
+}
+
+func (w *Writer) V2F32(v [2]float32) *Writer {
+	return w.F32(v[0]).F32(v[1])
+}
+
+func (w *Writer) V3S16(v [3]int16) *Writer {
+	return w.S16(v[0]).S16(v[1]).S16(v[2])
+}
+
+func (w *Writer) V3S32(v [3]int32) *Writer {
+	return w.S32(v[0]).S32(v[1]).S32(v[2])
+}
+
+func (w *Writer) V2S32(v [2]int32) *Writer {
+	return w.S32(v[0]).S32(v[1])
+}
@@ -0,0 +1,13 @@
+package network
+
+// Transport carries packets between two peers. Conn is the real implementation;
+// wrappers such as recorders and replayers stand in for it.
+type Transport interface {
+	Send(channel uint8, data []byte, reliable bool) error
+	Recv() (Packet, error)
+	Close() error
+	Done() <-chan struct{}
+	Err() error
+}
+
+var _ Transport = (*Conn)(nil)
@@ -0,0 +1,126 @@
+package object
+
+import (
+	"sort"
+	"sync"
+)
+
+// Parents followed at most to place an attached object, which also stops attachment loops
+const maxAttachmentDepth = 8
+
+// Manager is the thread-safe set of active objects in range of the player
+type Manager struct {
+	mu      sync.Mutex
+	objects map[uint16]*Object
+	version uint64
+}
+
+// NewManager creates a manager without objects
+func NewManager() *Manager {
+	return &Manager{objects: make(map[uint16]*Object)}
+}
+
+// Add puts an object into range, replacing any object with the same ID
+func (m *Manager) Add(o *Object) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[o.ID] = o
+	m.version++
+}
+
+// Remove takes an object out of range. Objects attached to it stay where they were drawn.
+func (m *Manager) Remove(id uint16) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objects, id)
+	m.version++
+}
+
+// Clear removes every object, such as after leaving a server
+func (m *Manager) Clear() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	clear(m.objects)
+	m.version++
+}
+
+// Apply changes an object, reporting false if there is no object with the ID
+func (m *Manager) Apply(id uint16, msg Message) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	o, exists := m.objects[id]
+	if !exists {
+		return false
+	}
+	msg.apply(o)
+	switch msg.(type) {
+	case UpdatePosition, SetAnimationSpeed, Punched:
+		// Changes the drawing follows every frame anyway
+	default:
+		m.version++
+	}
+	return true
+}
+
+// Step moves and animates the objects by dtime seconds and places attached objects at their parents
+func (m *Manager) Step(dtime float32) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, o := range m.objects {
+		o.step(dtime)
+	}
+	for _, o := range m.objects {
+		if o.Attached() {
+			o.VisualPosition, o.VisualRotation = m.attachedPose(o, 0)
+		}
+	}
+}
+
+// attachedPose returns where an attached object is drawn: at its offset from its parent, turned with it
+func (m *Manager) attachedPose(o *Object, depth int) (position, rotation [3]float32) {
+	parent, exists := m.objects[o.Attachment.ParentID]
+	if !o.Attached() || !exists || depth >= maxAttachmentDepth {
+		return o.VisualPosition, o.VisualRotation
+	}
+	position, rotation = parent.VisualPosition, parent.VisualRotation
+	if parent.Attached() {
+		position, rotation = m.attachedPose(parent, depth+1)
+	}
+	offset := Rotate(fromProtocolUnits(o.Attachment.Position), rotation)
+	for i := range position {
+		position[i] += offset[i]
+		rotation[i] = wrapDegrees(rotation[i] + o.Attachment.Rotation[i])
+	}
+	return position, rotation
+}
+
+// Object returns a copy of the object with the ID, and false if there is none
+func (m *Manager) Object(id uint16) (Object, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	o, exists := m.objects[id]
+	if !exists {
+		return Object{}, false
+	}
+	return o.clone(), true
+}
+
+// Objects returns copies of the objects ordered by ID
+func (m *Manager) Objects() []Object {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	objects := make([]Object, 0, len(m.objects))
+	for _, o := range m.objects {
+		objects = append(objects, o.clone())
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].ID < objects[j].ID })
+	return objects
+}
+
+// Version returns a number that changes whenever objects are added or removed or change how they look.
+// Movement does not change it.
+func (m *Manager) Version() uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.version
+}
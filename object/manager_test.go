@@ -0,0 +1,252 @@
+package object
+
+import (
+	"math"
+	"testing"
+)
+
+func near(a, b [3]float32) bool {
+	for i := range a {
+		if math.Abs(float64(a[i]-b[i])) > 1e-4 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestAddRemove(t *testing.T) {
+	m := NewManager()
+	m.Add(New(2, "mob", false, [3]float32{1, 2, 3}, [3]float32{}, 10))
+	m.Add(New(1, "singleplayer", true, [3]float32{}, [3]float32{}, 20))
+	m.Add(New(3, "item", false, [3]float32{}, [3]float32{}, 1))
+
+	objects := m.Objects()
+	if len(objects) != 3 || objects[0].ID != 1 || objects[1].ID != 2 || objects[2].ID != 3 {
+		t.Fatalf("objects %+v, want IDs 1, 2, 3", objects)
+	}
+	if o, exists := m.Object(2); !exists || o.Name != "mob" || o.VisualPosition != [3]float32{1, 2, 3} || o.HP != 10 {
+		t.Errorf("object 2: %+v, %v", o, exists)
+	}
+
+	// Adding an ID in use replaces the object
+	m.Add(New(2, "other", false, [3]float32{}, [3]float32{}, 5))
+	if o, _ := m.Object(2); o.Name != "other" || len(m.Objects()) != 3 {
+		t.Errorf("replaced object %+v", o)
+	}
+
+	m.Remove(2)
+	m.Remove(99) // Unknown IDs are ignored
+	if _, exists := m.Object(2); exists {
+		t.Error("removed object still there")
+	}
+	if len(m.Objects()) != 2 {
+		t.Errorf("%d objects after removing one", len(m.Objects()))
+	}
+	if m.Apply(2, Punched{HP: 1}) || m.Apply(99, SetTextureMod{Mod: "x"}) {
+		t.Error("applied a message to an object that is not there")
+	}
+
+	m.Clear()
+	if len(m.Objects()) != 0 {
+		t.Error("objects left after clearing")
+	}
+}
+
+func TestCopies(t *testing.T) {
+	m := NewManager()
+	m.Add(New(1, "mob", false, [3]float32{}, [3]float32{}, 10))
+	m.Apply(1, UpdateArmorGroups{Groups: map[string]int16{"fleshy": 100}})
+	m.Apply(1, SetBone{Bone: "Head", Override: BoneOverride{Scale: [3]float32{2, 2, 2}}})
+
+	o, _ := m.Object(1)
+	o.ArmorGroups["fleshy"] = 0
+	o.Bones["Head"] = BoneOverride{}
+	o.Props.Textures[0] = "changed.png"
+	if o, _ := m.Object(1); o.ArmorGroups["fleshy"] != 100 || o.Bones["Head"].Scale != [3]float32{2, 2, 2} || o.Props.Textures[0] == "changed.png" {
+		t.Errorf("copy shares state with the manager: %+v", o)
+	}
+}
+
+func TestVersion(t *testing.T) {
+	m := NewManager()
+	v := m.Version()
+	changed := func(what string, want bool) {
+		t.Helper()
+		if got := m.Version() != v; got != want {
+			t.Errorf("%s: version changed %v, want %v", what, got, want)
+		}
+		v = m.Version()
+	}
+	m.Add(New(1, "mob", false, [3]float32{}, [3]float32{}, 10))
+	changed("add", true)
+	m.Apply(1, UpdatePosition{Position: [3]float32{10, 0, 0}})
+	changed("position", false)
+	m.Apply(1, SetAnimationSpeed{Speed: 2})
+	changed("animation speed", false)
+	m.Apply(1, Punched{HP: 5})
+	changed("punch", false)
+	m.Step(0.1)
+	changed("step", false)
+	m.Apply(1, SetTextureMod{Mod: "^[brighten"})
+	changed("texture mod", true)
+	m.Apply(1, SetProperties{Properties: DefaultProperties()})
+	changed("properties", true)
+	m.Remove(1)
+	changed("remove", true)
+}
+
+func TestInterpolation(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		update UpdatePosition
+		steps  []float32
+		want   [][3]float32 // Visual position after each step
+	}{
+		{
+			// Each step covers the part of the way the step is of the interval, from where the object is drawn
+			"interpolated",
+			UpdatePosition{Position: [3]float32{10, 0, -20}, Interpolate: true, Interval: 0.2},
+			[]float32{0.1, 0.1},
+			[][3]float32{{0.5, 0, -1}, {0.75, 0, -1.5}},
+		},
+		{
+			// Until an update is known to be the end, objects carry on up to half the way further
+			"overshoot",
+			UpdatePosition{Position: [3]float32{10, 0, 0}, Interpolate: true, Interval: 0.1},
+			[]float32{1},
+			[][3]float32{{1.5, 0, 0}},
+		},
+		{
+			"end",
+			UpdatePosition{Position: [3]float32{10, 0, 0}, Interpolate: true, IsEnd: true, Interval: 0.1},
+			[]float32{1},
+			[][3]float32{{1, 0, 0}},
+		},
+		{
+			"jump",
+			UpdatePosition{Position: [3]float32{10, 20, 30}, Interval: 0.2},
+			[]float32{0, 0.1},
+			[][3]float32{{1, 2, 3}, {1, 2, 3}},
+		},
+		{
+			// Velocity and acceleration move the object on between updates, in protocol units per second
+			"velocity",
+			UpdatePosition{Position: [3]float32{0, 0, 0}, Velocity: [3]float32{10, 0, 0}, Acceleration: [3]float32{0, -20, 0}},
+			[]float32{0.5, 0.5},
+			[][3]float32{{0.5, -0.25, 0}, {1, -1, 0}},
+		},
+	} {
+		m := NewManager()
+		m.Add(New(1, "mob", false, [3]float32{}, [3]float32{}, 10))
+		m.Apply(1, test.update)
+		for i, dtime := range test.steps {
+			m.Step(dtime)
+			if o, _ := m.Object(1); !near(o.VisualPosition, test.want[i]) {
+				t.Errorf("%s: drawn at %v after step %d, want %v", test.name, o.VisualPosition, i, test.want[i])
+			}
+		}
+	}
+}
+
+func TestRotationInterpolation(t *testing.T) {
+	m := NewManager()
+	m.Add(New(1, "mob", false, [3]float32{}, [3]float32{0, 350, 0}, 10))
+	// Turning from 350 to 10 degrees goes the short way, through 0
+	m.Apply(1, UpdatePosition{Rotation: [3]float32{0, 370, 0}, Interpolate: true, Interval: 0.2})
+	m.Step(0.1)
+	if o, _ := m.Object(1); math.Abs(float64(o.VisualRotation[1])) > 1e-3 && math.Abs(float64(o.VisualRotation[1]-360)) > 1e-3 {
+		t.Errorf("yaw drawn at %v halfway, want 0", o.VisualRotation[1])
+	}
+	if o, _ := m.Object(1); o.Rotation[1] != 10 {
+		t.Errorf("yaw %v, want 10", o.Rotation[1])
+	}
+}
+
+func TestAttachment(t *testing.T) {
+	m := NewManager()
+	m.Add(New(1, "boat", false, [3]float32{1, 0, 0}, [3]float32{0, 90, 0}, 10))
+	m.Add(New(2, "player", true, [3]float32{5, 5, 5}, [3]float32{}, 20))
+	m.Apply(2, AttachTo{Attachment: Attachment{ParentID: 1, Position: [3]float32{10, 0, 0}, Rotation: [3]float32{0, 10, 0}}})
+	m.Step(0.1)
+
+	// The offset turns with the parent
+	child, _ := m.Object(2)
+	if !near(child.VisualPosition, [3]float32{1, 0, 1}) || !near(child.VisualRotation, [3]float32{0, 100, 0}) {
+		t.Errorf("attached object drawn at %v turned %v", child.VisualPosition, child.VisualRotation)
+	}
+
+	// Position updates of attached objects do not move them
+	m.Apply(2, UpdatePosition{Position: [3]float32{100, 100, 100}})
+	m.Step(0.1)
+	if child, _ := m.Object(2); !near(child.VisualPosition, [3]float32{1, 0, 1}) {
+		t.Errorf("attached object moved to %v", child.VisualPosition)
+	}
+
+	// Without its parent the object stays where it was drawn, and detaching carries on from there
+	m.Remove(1)
+	m.Step(0.1)
+	if child, _ := m.Object(2); !near(child.VisualPosition, [3]float32{1, 0, 1}) {
+		t.Errorf("object of a removed parent drawn at %v", child.VisualPosition)
+	}
+	m.Apply(2, AttachTo{})
+	m.Step(0.1)
+	if child, _ := m.Object(2); child.Attached() || !near(child.VisualPosition, [3]float32{1, 0, 1}) {
+		t.Errorf("detached object drawn at %v", child.VisualPosition)
+	}
+}
+
+func TestAttachmentLoop(t *testing.T) {
+	m := NewManager()
+	m.Add(New(1, "a", false, [3]float32{}, [3]float32{}, 1))
+	m.Add(New(2, "b", false, [3]float32{}, [3]float32{}, 1))
+	m.Apply(1, AttachTo{Attachment: Attachment{ParentID: 2, Position: [3]float32{10, 0, 0}}})
+	m.Apply(2, AttachTo{Attachment: Attachment{ParentID: 1, Position: [3]float32{10, 0, 0}}})
+	m.Step(0.1) // Does not recurse forever
+}
+
+func TestAnimation(t *testing.T) {
+	for _, test := range []struct {
+		loop  bool
+		frame float32
+	}{
+		{true, 25},
+		{false, 30},
+	} {
+		m := NewManager()
+		m.Add(New(1, "mob", false, [3]float32{}, [3]float32{}, 10))
+		m.Apply(1, SetAnimation{Animation: Animation{Frames: [2]float32{20, 30}, Speed: 5, Loop: test.loop}})
+		m.Step(3) // 15 frames from the first
+		if o, _ := m.Object(1); math.Abs(float64(o.AnimationFrame-test.frame)) > 1e-3 {
+			t.Errorf("loop %v: frame %v, want %v", test.loop, o.AnimationFrame, test.frame)
+		}
+	}
+
+	m := NewManager()
+	m.Add(New(1, "mob", false, [3]float32{}, [3]float32{}, 10))
+	m.Apply(1, SetSprite{Sprite: Sprite{BasePos: [2]int16{2, 1}, Frames: 4, FrameLength: 0.25}})
+	m.Step(0.6)
+	o, _ := m.Object(1)
+	if col, row := o.SpriteCell([3]float32{}); col != 2 || row != 3 {
+		t.Errorf("sprite cell %d, %d after 0.6s, want 2, 3", col, row)
+	}
+}
+
+func TestPunched(t *testing.T) {
+	m := NewManager()
+	m.Add(New(1, "mob", false, [3]float32{}, [3]float32{}, 10))
+	m.Apply(1, Punched{HP: 7})
+	m.Step(0.1)
+	if o, _ := m.Object(1); o.HP != 7 || !o.Damaged {
+		t.Errorf("hp %d, damaged %v after a punch", o.HP, o.Damaged)
+	}
+	m.Step(0.15)
+	if o, _ := m.Object(1); o.Damaged {
+		t.Error("still damaged after the flash")
+	}
+	// Healing does not flash
+	m.Apply(1, Punched{HP: 10})
+	m.Step(0.01)
+	if o, _ := m.Object(1); o.HP != 10 || o.Damaged {
+		t.Errorf("hp %d, damaged %v after healing", o.HP, o.Damaged)
+	}
+}
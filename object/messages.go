@@ -0,0 +1,132 @@
+package object
+
+// Message changes an object. The messages mirror the commands of TOCLIENT_ACTIVE_OBJECT_MESSAGES.
+type Message interface {
+	apply(o *Object)
+}
+
+// SetProperties replaces the properties of an object
+type SetProperties struct {
+	Properties Properties
+}
+
+func (m SetProperties) apply(o *Object) {
+	o.Props = m.Properties.clone()
+}
+
+// UpdatePosition places an object. Positions are in protocol units and the rotation in degrees. Interpolated
+// updates move the object there over the interval until the next update.
+type UpdatePosition struct {
+	Position     [3]float32
+	Velocity     [3]float32
+	Acceleration [3]float32
+	Rotation     [3]float32
+	Interpolate  bool
+	IsEnd        bool // The object stops at the position
+	Interval     float32
+}
+
+// apply updates the position, velocity and rotation of an object from the server
+func (u UpdatePosition) apply(o *Object) {
+	o.Position = fromProtocolUnits(u.Position)
+	o.Velocity = fromProtocolUnits(u.Velocity)
+	o.Acceleration = fromProtocolUnits(u.Acceleration)
+	for i, angle := range u.Rotation {
+		o.Rotation[i] = wrapDegrees(angle)
+	}
+	if o.Attached() {
+		return
+	}
+	if u.Interpolate {
+		o.pos.update(o.Position, u.IsEnd, u.Interval)
+	} else {
+		o.pos.init(o.Position)
+		o.VisualPosition = o.Position
+	}
+	o.rot.update(o.Rotation, false, u.Interval)
+}
+
+// SetTextureMod appends a texture modifier to the textures of an object
+type SetTextureMod struct {
+	Mod string
+}
+
+func (m SetTextureMod) apply(o *Object) {
+	o.TextureMod = m.Mod
+}
+
+// SetSprite picks the frames of a sprite
+type SetSprite struct {
+	Sprite Sprite
+}
+
+func (m SetSprite) apply(o *Object) {
+	o.Sprite = m.Sprite
+	o.spriteTime = 0
+}
+
+// Punched reports the health of an object after it was hit
+type Punched struct {
+	HP uint16
+}
+
+func (m Punched) apply(o *Object) {
+	o.punch(m.HP)
+}
+
+// UpdateArmorGroups replaces the armor groups of an object
+type UpdateArmorGroups struct {
+	Groups map[string]int16
+}
+
+func (m UpdateArmorGroups) apply(o *Object) {
+	o.ArmorGroups = make(map[string]int16, len(m.Groups))
+	for name, rating := range m.Groups {
+		o.ArmorGroups[name] = rating
+	}
+}
+
+// SetAnimation starts playing an animation of a mesh
+type SetAnimation struct {
+	Animation Animation
+}
+
+func (m SetAnimation) apply(o *Object) {
+	if m.Animation != o.Animation {
+		o.AnimationFrame = m.Animation.Frames[0]
+	}
+	o.Animation = m.Animation
+}
+
+// SetAnimationSpeed changes the speed of the playing animation
+type SetAnimationSpeed struct {
+	Speed float32
+}
+
+func (m SetAnimationSpeed) apply(o *Object) {
+	o.Animation.Speed = m.Speed
+}
+
+// SetBone overrides a bone of a mesh
+type SetBone struct {
+	Bone     string
+	Override BoneOverride
+}
+
+func (m SetBone) apply(o *Object) {
+	o.Bones[m.Bone] = m.Override
+}
+
+// AttachTo fixes an object to a parent, or detaches it if the parent ID is 0
+type AttachTo struct {
+	Attachment Attachment
+}
+
+func (m AttachTo) apply(o *Object) {
+	if o.Attached() && m.Attachment.ParentID == 0 {
+		// Carry on from where the parent left the object
+		o.Position = o.VisualPosition
+		o.pos.init(o.Position)
+	}
+	o.Attachment = m.Attachment
+}
@@ -0,0 +1,357 @@
+// Package object keeps the active objects the server sent in range of the player: their properties, where they
+// are drawn between position updates and their animations and attachments.
+package object
+
+import (
+	"math"
+
+	"bettermt/main/network"
+)
+
+// Seconds the damage texture modifier is shown for after an object is punched
+const damageFlashTime = 0.2
+
+// Object is an active object, such as a mob, a dropped item or another player
+type Object struct {
+	ID       uint16
+	Name     string
+	IsPlayer bool
+	IsLocal  bool // The object of the player controlled on this client, which is not drawn
+	HP       uint16
+	Props    Properties
+
+	// Where the server last placed the object, carried on by its velocity and acceleration, in nodes
+	Position     [3]float32
+	Velocity     [3]float32
+	Acceleration [3]float32
+	Rotation     [3]float32 // Pitch, yaw and roll in degrees
+
+	// Where the object is drawn, following Position and Rotation smoothly or following its parent
+	VisualPosition [3]float32
+	VisualRotation [3]float32
+
+	TextureMod     string
+	Sprite         Sprite
+	SpriteFrame    int // Row of the current frame of a sprite animation, counted from the base position
+	ArmorGroups    map[string]int16
+	Animation      Animation
+	AnimationFrame float32
+	Bones          map[string]BoneOverride
+	Attachment     Attachment
+	Damaged        bool // Punched moments ago, drawn with the damage texture modifier
+
+	pos         translator
+	rot         translator
+	spriteTime  float32
+	damageTimer float32
+}
+
+// Sprite selects frames of a sprite texture divided into a grid by the sprite div of the properties
+type Sprite struct {
+	BasePos     [2]int16 // Column and row of the first frame
+	Frames      uint16   // Frames below the base position played in a loop
+	FrameLength float32  // Seconds
+	// Pick the column from the direction the object is seen from, like the sides of a 2D mob
+	SelectByYawPitch bool
+}
+
+// Animation plays a range of frames of a mesh
+type Animation struct {
+	Frames [2]float32 // First and last frame
+	Speed  float32    // Frames per second
+	Blend  float32
+	Loop   bool
+}
+
+// BoneOverride moves, turns and scales a bone of a mesh. Positions are in protocol units and rotations in
+// degrees. Absolute overrides replace the pose of the animation instead of adding to it.
+type BoneOverride struct {
+	Position         [3]float32
+	Rotation         [3]float32
+	Scale            [3]float32
+	Interpolation    [3]float32 // Seconds the position, rotation and scale take to change
+	AbsolutePosition bool
+	AbsoluteRotation bool
+	AbsoluteScale    bool
+}
+
+// Attachment fixes an object to another, optionally to a bone of its mesh. The position is in protocol units
+// relative to the parent and the rotation in degrees.
+type Attachment struct {
+	ParentID     uint16 // 0 while the object is not attached
+	Bone         string
+	Position     [3]float32
+	Rotation     [3]float32
+	ForceVisible bool // Draw the object even while the parent is the local player in first person
+}
+
+// New creates an object in its state when it came into range
+func New(id uint16, name string, isPlayer bool, position, rotation [3]float32, hp uint16) *Object {
+	o := &Object{
+		ID:          id,
+		Name:        name,
+		IsPlayer:    isPlayer,
+		HP:          hp,
+		Props:       DefaultProperties(),
+		Position:    position,
+		Rotation:    rotation,
+		ArmorGroups: make(map[string]int16),
+		Bones:       make(map[string]BoneOverride),
+	}
+	o.pos.init(position)
+	o.rot.init(rotation)
+	o.VisualPosition = position
+	o.VisualRotation = rotation
+	return o
+}
+
+// Attached reports whether the object follows a parent
+func (o *Object) Attached() bool {
+	return o.Attachment.ParentID != 0
+}
+
+// step moves an unattached object on by its velocity and acceleration, turns it the way its properties ask and
+// plays its animations
+func (o *Object) step(dtime float32) {
+	if !o.Attached() {
+		for i := range o.Position {
+			o.Position[i] += dtime*o.Velocity[i] + 0.5*dtime*dtime*o.Acceleration[i]
+			o.Velocity[i] += dtime * o.Acceleration[i]
+		}
+		o.pos.update(o.Position, o.pos.isEnd, o.pos.duration)
+		o.pos.translate(dtime)
+		o.VisualPosition = o.pos.current
+	}
+
+	if o.Props.AutomaticRotate != 0 {
+		o.Rotation[1] = wrapDegrees(o.Rotation[1] + dtime*o.Props.AutomaticRotate*180/math.Pi)
+		o.rot.current[1] = o.Rotation[1]
+		o.rot.target[1] = o.Rotation[1]
+	}
+	if o.Props.AutomaticFaceMovementDir && (abs(o.Velocity[0]) > 0.001 || abs(o.Velocity[2]) > 0.001) {
+		target := float32(math.Atan2(float64(o.Velocity[2]), float64(o.Velocity[0]))*180/math.Pi) +
+			o.Props.AutomaticFaceMovementDirOffset
+		if limit := o.Props.AutomaticFaceMovementMaxRotate; limit > 0 {
+			o.Rotation[1] = approachAngle(o.Rotation[1], target, dtime*limit)
+		} else {
+			o.Rotation[1] = wrapDegrees(target)
+		}
+		o.rot.current = o.Rotation
+		o.rot.target = o.Rotation
+	}
+	o.rot.translateAngles(dtime)
+	o.VisualRotation = o.rot.current
+
+	o.stepAnimation(dtime)
+	if o.damageTimer > 0 {
+		o.damageTimer -= dtime
+	}
+	o.Damaged = o.damageTimer > 0
+}
+
+// stepAnimation advances the frame of the mesh animation and of the sprite animation
+func (o *Object) stepAnimation(dtime float32) {
+	a := o.Animation
+	if a.Frames[1] > a.Frames[0] {
+		o.AnimationFrame += dtime * a.Speed
+		if length := a.Frames[1] - a.Frames[0]; a.Loop {
+			o.AnimationFrame = a.Frames[0] + float32(math.Mod(float64(o.AnimationFrame-a.Frames[0]), float64(length)))
+			if o.AnimationFrame < a.Frames[0] {
+				o.AnimationFrame += length
+			}
+		} else {
+			o.AnimationFrame = max(a.Frames[0], min(a.Frames[1], o.AnimationFrame))
+		}
+	} else {
+		o.AnimationFrame = a.Frames[0]
+	}
+
+	if o.Sprite.Frames > 1 && o.Sprite.FrameLength > 0 {
+		o.spriteTime += dtime
+		o.SpriteFrame = int(o.spriteTime/o.Sprite.FrameLength) % int(o.Sprite.Frames)
+	} else {
+		o.SpriteFrame = 0
+	}
+}
+
+// punch shows the damage the object took
+func (o *Object) punch(hp uint16) {
+	if hp < o.HP {
+		o.damageTimer = damageFlashTime
+	}
+	o.HP = hp
+}
+
+func (o *Object) clone() Object {
+	c := *o
+	c.Props = o.Props.clone()
+	c.ArmorGroups = make(map[string]int16, len(o.ArmorGroups))
+	for name, rating := range o.ArmorGroups {
+		c.ArmorGroups[name] = rating
+	}
+	c.Bones = make(map[string]BoneOverride, len(o.Bones))
+	for name, bone := range o.Bones {
+		c.Bones[name] = bone
+	}
+	return c
+}
+
+// translator moves a value smoothly from where it was drawn towards a target over the interval between updates
+type translator struct {
+	start, target, current [3]float32
+	elapsed, duration      float32
+	isEnd                  bool // The target is where the object stops
+}
+
+// init jumps to a value
+func (t *translator) init(v [3]float32) {
+	t.start, t.target, t.current = v, v, v
+	t.elapsed, t.duration = 0, 0
+}
+
+// update starts moving from the current value towards a new target
+func (t *translator) update(target [3]float32, isEnd bool, duration float32) {
+	t.start = t.current
+	t.target = target
+	t.isEnd = isEnd
+	t.duration = duration
+	t.elapsed = 0
+}
+
+// progress returns how far the value has come from the start to the target. Until the target is known to be
+// where the object stops, the value carries on past it by up to half the distance.
+func (t *translator) progress(dtime float32) float32 {
+	t.elapsed += dtime
+	if t.duration <= 0.001 {
+		return 1
+	}
+	limit := float32(1.5)
+	if t.isEnd {
+		limit = 1
+	}
+	return min(limit, t.elapsed/t.duration)
+}
+
+// translate moves the current value on by dtime seconds
+func (t *translator) translate(dtime float32) {
+	f := t.progress(dtime)
+	for i := range t.current {
+		t.current[i] = t.start[i] + (t.target[i]-t.start[i])*f
+	}
+}
+
+// translateAngles moves the current angles on by dtime seconds, turning the short way around
+func (t *translator) translateAngles(dtime float32) {
+	f := t.progress(dtime)
+	for i := range t.current {
+		diff := wrapDegrees180(t.target[i] - t.start[i])
+		t.current[i] = wrapDegrees(t.start[i] + diff*f)
+	}
+}
+
+// Rotate turns a vector by pitch, yaw and roll in degrees the way Minetest turns objects: roll first, then
+// pitch, then yaw, all negated
+func Rotate(v [3]float32, rotation [3]float32) [3]float32 {
+	pitch := -float64(rotation[0]) * math.Pi / 180
+	yaw := -float64(rotation[1]) * math.Pi / 180
+	roll := -float64(rotation[2]) * math.Pi / 180
+	x, y, z := float64(v[0]), float64(v[1]), float64(v[2])
+
+	x, y = x*math.Cos(roll)-y*math.Sin(roll), x*math.Sin(roll)+y*math.Cos(roll)
+	y, z = y*math.Cos(pitch)-z*math.Sin(pitch), y*math.Sin(pitch)+z*math.Cos(pitch)
+	x, z = x*math.Cos(yaw)+z*math.Sin(yaw), -x*math.Sin(yaw)+z*math.Cos(yaw)
+	return [3]float32{float32(x), float32(y), float32(z)}
+}
+
+// Unrotate undoes Rotate, turning a vector from the frame of a rotated object back into its own
+func Unrotate(v [3]float32, rotation [3]float32) [3]float32 {
+	pitch := float64(rotation[0]) * math.Pi / 180
+	yaw := float64(rotation[1]) * math.Pi / 180
+	roll := float64(rotation[2]) * math.Pi / 180
+	x, y, z := float64(v[0]), float64(v[1]), float64(v[2])
+
+	x, z = x*math.Cos(yaw)+z*math.Sin(yaw), -x*math.Sin(yaw)+z*math.Cos(yaw)
+	y, z = y*math.Cos(pitch)-z*math.Sin(pitch), y*math.Sin(pitch)+z*math.Cos(pitch)
+	x, y = x*math.Cos(roll)-y*math.Sin(roll), x*math.Sin(roll)+y*math.Cos(roll)
+	return [3]float32{float32(x), float32(y), float32(z)}
+}
+
+// fromProtocolUnits scales a vector used on the wire to nodes
+func fromProtocolUnits(v [3]float32) [3]float32 {
+	return [3]float32{v[0] / network.BS, v[1] / network.BS, v[2] / network.BS}
+}
+
+// wrapDegrees brings an angle into [0, 360)
+func wrapDegrees(angle float32) float32 {
+	angle = float32(math.Mod(float64(angle), 360))
+	if angle < 0 {
+		angle += 360
+	}
+	return angle
+}
+
+// wrapDegrees180 brings an angle into [-180, 180)
+func wrapDegrees180(angle float32) float32 {
+	return wrapDegrees(angle+180) - 180
+}
+
+// approachAngle turns an angle towards a target by at most step degrees, the short way around
+func approachAngle(angle, target, step float32) float32 {
+	diff := wrapDegrees180(target - angle)
+	if abs(diff) <= step {
+		return wrapDegrees(target)
+	}
+	if diff < 0 {
+		step = -step
+	}
+	return wrapDegrees(angle + step)
+}
+
+func abs(v float32) float32 {
+	return float32(math.Abs(float64(v)))
+}
+
+// NametagText returns the text shown above an object. Players without a nametag show their name.
+func (o *Object) NametagText() string {
+	if o.Props.Nametag == "" && o.IsPlayer {
+		return o.Name
+	}
+	return o.Props.Nametag
+}
+
+// SpriteCell returns the column and row of the sprite frame to draw for a camera at eye. Sprites selected by
+// yaw and pitch show a different column for each side the object is seen from.
+func (o *Object) SpriteCell(eye [3]float32) (col, row int) {
+	col = int(o.Sprite.BasePos[0])
+	row = int(o.Sprite.BasePos[1]) + o.SpriteFrame
+	if !o.Sprite.SelectByYawPitch {
+		return col, row
+	}
+
+	var toObject [3]float32
+	var length float32
+	for i := range toObject {
+		toObject[i] = o.VisualPosition[i] - eye[i]
+		length += toObject[i] * toObject[i]
+	}
+	if length == 0 {
+		return col, row
+	}
+	length = float32(math.Sqrt(float64(length)))
+	switch y := toObject[1] / length; {
+	case y > 0.75:
+		return col + 5, row
+	case y < -0.75:
+		return col + 4, row
+	}
+	dir := float32(math.Atan2(float64(toObject[2]), float64(toObject[0]))*180/math.Pi) - o.VisualRotation[1]
+	for _, side := range [...]struct {
+		angle  float32
+		column int
+	}{{0, 2}, {90, 3}, {180, 0}, {-90, 1}} {
+		if abs(wrapDegrees180(dir-side.angle)) <= 45.1 {
+			return col + side.column, row
+		}
+	}
+	return col + 4, row
+}
@@ -0,0 +1,109 @@
+package object
+
+import "image/color"
+
+// Visuals an object can be drawn with
+const (
+	VisualCube          = "cube"           // A box with one texture per face
+	VisualSprite        = "sprite"         // A flat image that always faces the camera
+	VisualUprightSprite = "upright_sprite" // A flat image that stands upright and turns with the object
+	VisualMesh          = "mesh"           // A model file
+	VisualWielditem     = "wielditem"      // An item as it is drawn when wielded
+	VisualItem          = "item"           // An item as it is drawn when dropped
+)
+
+// Box is an axis-aligned box around the position of an object, in nodes
+type Box struct {
+	Min, Max [3]float32
+}
+
+// Properties describe how an object looks and behaves, as set by the server in AOCmdSetProperties
+type Properties struct {
+	HPMax              uint16
+	Physical           bool
+	CollisionBox       Box
+	SelectionBox       Box
+	Pointable          bool
+	Visual             string
+	VisualSize         [3]float32 // Nodes
+	Textures           []string
+	SpriteDiv          [2]int16 // Columns and rows of frames in a sprite texture
+	InitialSpritePos   [2]int16
+	IsVisible          bool
+	MakesFootstepSound bool
+	AutomaticRotate    float32 // Radians per second the object turns by on its own
+	Mesh               string
+	Colors             []color.NRGBA
+	CollideWithObjects bool
+	StepHeight         float32
+	// Turn the object to face the direction it moves in, offset by some degrees and at a limited speed
+	AutomaticFaceMovementDir       bool
+	AutomaticFaceMovementDirOffset float32
+	AutomaticFaceMovementMaxRotate float32 // Degrees per second, negative for no limit
+	BackfaceCulling                bool
+	Nametag                        string
+	NametagColor                   color.NRGBA
+	NametagBgColor                 color.NRGBA
+	HasNametagBgColor              bool // The default background is drawn while false
+	Infotext                       string
+	WieldItem                      string
+	Glow                           int8
+	BreathMax                      uint16
+	EyeHeight                      float32
+	ZoomFOV                        float32
+	UseTextureAlpha                bool
+	DamageTextureModifier          string
+	Shaded                         bool
+	ShowOnMinimap                  bool
+	RotateSelectionBox             bool
+}
+
+// DefaultProperties returns the properties of an object before the server sets any, like in Minetest
+func DefaultProperties() Properties {
+	box := Box{Min: [3]float32{-0.5, -0.5, -0.5}, Max: [3]float32{0.5, 0.5, 0.5}}
+	return Properties{
+		HPMax:                          1,
+		CollisionBox:                   box,
+		SelectionBox:                   box,
+		Pointable:                      true,
+		Visual:                         VisualSprite,
+		VisualSize:                     [3]float32{1, 1, 1},
+		Textures:                       []string{"no_texture.png"},
+		SpriteDiv:                      [2]int16{1, 1},
+		IsVisible:                      true,
+		Colors:                         []color.NRGBA{{R: 255, G: 255, B: 255, A: 255}},
+		CollideWithObjects:             true,
+		AutomaticFaceMovementMaxRotate: -1,
+		BackfaceCulling:                true,
+		NametagColor:                   color.NRGBA{R: 255, G: 255, B: 255, A: 255},
+		EyeHeight:                      1.625,
+		DamageTextureModifier:          "^[brighten",
+		Shaded:                         true,
+	}
+}
+
+// ItemName returns the item drawn by a wielditem or item visual, which is the wield item or else the first
+// texture
+func (p *Properties) ItemName() string {
+	if p.WieldItem != "" {
+		return p.WieldItem
+	}
+	if len(p.Textures) > 0 {
+		return p.Textures[0]
+	}
+	return ""
+}
+
+// Texture returns the texture at an index, or the last one if there are fewer
+func (p *Properties) Texture(i int) string {
+	if len(p.Textures) == 0 {
+		return ""
+	}
+	return p.Textures[min(i, len(p.Textures)-1)]
+}
+
+func (p Properties) clone() Properties {
+	p.Textures = append([]string(nil), p.Textures...)
+	p.Colors = append([]color.NRGBA(nil), p.Colors...)
+	return p
+}
@@ -0,0 +1,231 @@
+package particle
+
+import (
+	"math/rand/v2"
+	"sync"
+
+	"bettermt/main/blocktypes"
+	"bettermt/main/meshbuilder"
+	"bettermt/main/object"
+)
+
+// Particles beyond which new ones are dropped, so that a busy server cannot slow the client down without end
+const maxParticles = 16384
+
+// Manager is the thread-safe set of particles and spawners in the world
+type Manager struct {
+	mu        sync.Mutex
+	objects   *object.Manager // Objects spawners and attractors are attached to, may be nil
+	particles []*Particle
+	spawners  map[uint32]*spawner
+}
+
+// NewManager creates a manager without particles. Spawners and attractors attached to objects follow the
+// objects of objects.
+func NewManager(objects *object.Manager) *Manager {
+	return &Manager{objects: objects, spawners: make(map[uint32]*spawner)}
+}
+
+// Add creates a single particle
+func (m *Manager) Add(p Params) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.add(p)
+}
+
+func (m *Manager) add(p Params) {
+	if len(m.particles) < maxParticles {
+		m.particles = append(m.particles, newParticle(p))
+	}
+}
+
+// AddSpawner creates a spawner, replacing any spawner with the same ID
+func (m *Manager) AddSpawner(id uint32, p SpawnerParams) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.spawners[id] = newSpawner(p)
+}
+
+// RemoveSpawner deletes a spawner. The particles it spawned live on.
+func (m *Manager) RemoveSpawner(id uint32) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.spawners, id)
+}
+
+// Clear removes every particle and spawner, such as after leaving a server
+func (m *Manager) Clear() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.particles = nil
+	clear(m.spawners)
+}
+
+// AddNodeParticles throws count bits of a node out of the node at pos, the way Minetest shows a node being
+// punched or dug. Gravity pulls them down in nodes per second squared.
+func (m *Manager) AddNodeParticles(pos [3]int32, content uint16, count int, gravity float32) {
+	if blocktypes.NodeDefs().Get(content).Drawtype == blocktypes.DrawtypeAirlike {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for range count {
+		p := DefaultParams()
+		for i := range p.Position {
+			p.Position[i] = float32(pos[i]) + float32(rand.IntN(100))/200 - 0.25
+		}
+		p.Velocity = [3]float32{
+			float32(rand.IntN(150))/50 - 1.5,
+			float32(rand.IntN(150)) / 50,
+			float32(rand.IntN(150))/50 - 1.5,
+		}
+		p.Acceleration = [3]float32{0, -gravity, 0}
+		p.ExpirationTime = float32(rand.IntN(100)) / 100
+		p.CollisionDetection = true
+		p.Node = Node{Content: content}
+		m.add(p)
+	}
+}
+
+// Step moves the particles on by dtime seconds, colliding with the nodes of env, and lets the spawners spawn.
+// Expired particles and finished spawners are removed.
+func (m *Manager) Step(dtime float32, env Environment) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := &surroundings{env: env, objects: m.objects}
+	for id, sp := range m.spawners {
+		if sp.step(dtime, s, m.add) {
+			delete(m.spawners, id)
+		}
+	}
+
+	kept := m.particles[:0]
+	for _, p := range m.particles {
+		p.step(dtime, s)
+		if !p.Expired() {
+			kept = append(kept, p)
+		}
+	}
+	clear(m.particles[len(kept):])
+	m.particles = kept
+}
+
+// Each calls fn with every living particle. fn must not call other methods of the manager.
+func (m *Manager) Each(fn func(p *Particle)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, p := range m.particles {
+		fn(p)
+	}
+}
+
+// Len returns the number of living particles
+func (m *Manager) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.particles)
+}
+
+// surroundings is what particles meet during one step. The objects are only copied once something needs them.
+type surroundings struct {
+	env     Environment
+	objects *object.Manager
+	byID    map[uint16]*object.Object
+}
+
+// object returns an object in range by ID
+func (s *surroundings) object(id uint16) (*object.Object, bool) {
+	if s.objects == nil {
+		return nil, false
+	}
+	if s.byID == nil {
+		objects := s.objects.Objects()
+		s.byID = make(map[uint16]*object.Object, len(objects))
+		for i := range objects {
+			s.byID[objects[i].ID] = &objects[i]
+		}
+	}
+	o, exists := s.byID[id]
+	return o, exists
+}
+
+// collide checks whether a particle with a box of half size half, moving d along axis from from to to, runs
+// into a walkable node or, with objects set, a physical object. If it does it returns where along the axis the
+// particle stops instead. What the particle already overlapped at from does not stop it.
+func (s *surroundings) collide(from, to [3]float32, half float32, axis int, d float32, objects bool) (float32, bool) {
+	stop, blocked := to[axis], false
+	block := func(lo, hi [3]float32) {
+		for i := range lo {
+			if to[i]+half <= lo[i] || to[i]-half >= hi[i] {
+				return
+			}
+		}
+		if from[axis]+half > lo[axis] && from[axis]-half < hi[axis] {
+			return
+		}
+		blocked = true
+		if d > 0 {
+			stop = min(stop, lo[axis]-half-collisionMargin)
+		} else {
+			stop = max(stop, hi[axis]+half+collisionMargin)
+		}
+	}
+
+	if s.env != nil {
+		var lo, hi [3]int32
+		for i := range to {
+			lo[i], hi[i] = meshbuilder.NodeCoord(to[i]-half), meshbuilder.NodeCoord(to[i]+half)
+		}
+		for x := lo[0]; x <= hi[0]; x++ {
+			for y := lo[1]; y <= hi[1]; y++ {
+				for z := lo[2]; z <= hi[2]; z++ {
+					if def, _ := s.env.Node(x, y, z); def != nil && def.Walkable {
+						n := [3]float32{float32(x), float32(y), float32(z)}
+						block([3]float32{n[0] - 0.5, n[1] - 0.5, n[2] - 0.5}, [3]float32{n[0] + 0.5, n[1] + 0.5, n[2] + 0.5})
+					}
+				}
+			}
+		}
+	}
+
+	if objects {
+		// Loads the objects
+		s.object(0)
+		for _, o := range s.byID {
+			if !o.Props.Physical {
+				continue
+			}
+			box := o.Props.CollisionBox
+			var lo, hi [3]float32
+			for i := range lo {
+				lo[i], hi[i] = o.VisualPosition[i]+box.Min[i], o.VisualPosition[i]+box.Max[i]
+			}
+			block(lo, hi)
+		}
+	}
+	return stop, blocked
+}
+
+// attractor returns where the origin of an attractor is and where its direction points, following the objects
+// they are attached to. It reports false while one of the objects is out of range.
+func (s *surroundings) attractor(a Attractor) (origin, direction [3]float32, ok bool) {
+	origin, direction = a.Origin, a.Direction
+	if a.OriginAttachment != 0 {
+		o, exists := s.object(a.OriginAttachment)
+		if !exists {
+			return origin, direction, false
+		}
+		origin = object.Rotate(origin, o.VisualRotation)
+		for i := range origin {
+			origin[i] += o.VisualPosition[i]
+		}
+	}
+	if a.DirectionAttachment != 0 {
+		o, exists := s.object(a.DirectionAttachment)
+		if !exists {
+			return origin, direction, false
+		}
+		direction = object.Rotate(direction, o.VisualRotation)
+	}
+	return origin, direction, true
+}
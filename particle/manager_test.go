@@ -0,0 +1,391 @@
+package particle
+
+import (
+	"math"
+	"testing"
+
+	"bettermt/main/blocktypes"
+	"bettermt/main/network"
+	"bettermt/main/object"
+)
+
+// ground is an environment whose walkable nodes fill everything below y = 0, so its surface is at y = -0.5
+type ground struct{}
+
+func (ground) Node(x, y, z int32) (*blocktypes.NodeDefinition, bool) {
+	return &blocktypes.NodeDefinition{Walkable: y < 0}, true
+}
+
+func near(a, b [3]float32) bool {
+	for i := range a {
+		if math.Abs(float64(a[i]-b[i])) > 1e-4 {
+			return false
+		}
+	}
+	return true
+}
+
+// only returns the single particle of a manager
+func only(t *testing.T, m *Manager) Particle {
+	t.Helper()
+	var particles []Particle
+	m.Each(func(p *Particle) { particles = append(particles, *p) })
+	if len(particles) != 1 {
+		t.Fatalf("%d particles, want 1", len(particles))
+	}
+	return particles[0]
+}
+
+func TestMoveAndExpire(t *testing.T) {
+	m := NewManager(nil)
+	p := DefaultParams()
+	p.Velocity = [3]float32{1, 0, 0}
+	p.Acceleration = [3]float32{0, 2, 0}
+	p.ExpirationTime = 1
+	m.Add(p)
+
+	m.Step(0.25, nil)
+	got := only(t, m)
+	if !near(got.Velocity, [3]float32{1, 0.5, 0}) || !near(got.Position, [3]float32{0.25, 0.125, 0}) || got.Age != 0.25 {
+		t.Errorf("after 0.25 s: position %v, velocity %v, age %v", got.Position, got.Velocity, got.Age)
+	}
+
+	m.Step(0.5, nil)
+	if m.Len() != 1 {
+		t.Fatal("particle expired early")
+	}
+	m.Step(0.25, nil)
+	if m.Len() != 0 {
+		t.Error("particle outlived its expiration time")
+	}
+}
+
+func TestDrag(t *testing.T) {
+	m := NewManager(nil)
+	p := DefaultParams()
+	p.Velocity = [3]float32{4, 4, 0}
+	p.Drag = [3]float32{2, 0, 0}
+	p.ExpirationTime = 10
+	m.Add(p)
+
+	m.Step(0.25, nil)
+	if got := only(t, m); !near(got.Velocity, [3]float32{2, 4, 0}) {
+		t.Errorf("velocity %v, want half the speed along x only", got.Velocity)
+	}
+
+	// Drag never turns a particle around
+	m.Clear()
+	p.Drag = [3]float32{10, 0, 0}
+	m.Add(p)
+	m.Step(0.5, nil)
+	if got := only(t, m); got.Velocity[0] != 0 {
+		t.Errorf("velocity %v, want a stop along x", got.Velocity)
+	}
+}
+
+func fallingParticle() Params {
+	p := DefaultParams()
+	p.Position = [3]float32{0, 1, 0}
+	p.Velocity = [3]float32{0, -10, 0}
+	p.Size = 0.2 * network.BS
+	p.ExpirationTime = 10
+	p.CollisionDetection = true
+	return p
+}
+
+func TestCollision(t *testing.T) {
+	m := NewManager(nil)
+	m.Add(fallingParticle())
+	m.Step(0.5, ground{})
+
+	// Resting on the surface, half its size and the margin above it
+	got := only(t, m)
+	if want := float32(-0.5 + 0.1 + collisionMargin); math.Abs(float64(got.Position[1]-want)) > 1e-4 || got.Velocity[1] != 0 {
+		t.Errorf("position %v, velocity %v, want stopped at y = %v", got.Position, got.Velocity, want)
+	}
+
+	// Without collision detection it falls through
+	m.Clear()
+	p := fallingParticle()
+	p.CollisionDetection = false
+	m.Add(p)
+	m.Step(0.5, ground{})
+	if got := only(t, m); got.Position[1] != -4 {
+		t.Errorf("position %v, want fallen through the ground", got.Position)
+	}
+}
+
+func TestBounce(t *testing.T) {
+	m := NewManager(nil)
+	p := fallingParticle()
+	p.Bounce = Fixed(0.5)
+	m.Add(p)
+	m.Step(0.2, ground{})
+
+	got := only(t, m)
+	if got.Velocity[1] != 5 {
+		t.Errorf("velocity %v, want half the speed upwards", got.Velocity)
+	}
+	if got.Position[1] < -0.5+0.1 {
+		t.Errorf("position %v inside the ground", got.Position)
+	}
+}
+
+func TestCollisionRemoval(t *testing.T) {
+	m := NewManager(nil)
+	p := fallingParticle()
+	p.CollisionRemoval = true
+	m.Add(p)
+
+	m.Step(0.05, ground{})
+	if m.Len() != 1 {
+		t.Fatal("particle removed before colliding")
+	}
+	m.Step(0.5, ground{})
+	if m.Len() != 0 {
+		t.Error("particle kept after colliding")
+	}
+}
+
+func TestAttractor(t *testing.T) {
+	m := NewManager(nil)
+	p := DefaultParams()
+	p.Position = [3]float32{5, 0, 0}
+	p.ExpirationTime = 10
+	p.Attractor = Attractor{Kind: AttractorPoint, Strength: 10, KillOnContact: true}
+	m.Add(p)
+
+	m.Step(0.1, nil)
+	if got := only(t, m); !near(got.Position, [3]float32{4, 0, 0}) {
+		t.Errorf("position %v, want pulled one node towards the origin", got.Position)
+	}
+	m.Step(0.5, nil)
+	if m.Len() != 0 {
+		t.Error("particle reaching the attractor not killed")
+	}
+
+	// Lines only pull across their direction
+	m.Clear()
+	p.Position = [3]float32{2, 3, 0}
+	p.Attractor = Attractor{Kind: AttractorLine, Strength: 1, Direction: [3]float32{0, 2, 0}}
+	m.Add(p)
+	m.Step(1, nil)
+	if got := only(t, m); !near(got.Position, [3]float32{1, 3, 0}) {
+		t.Errorf("position %v, want pulled towards the y axis", got.Position)
+	}
+
+	// Planes only pull along their normal, and particles stop at them without a kill
+	m.Clear()
+	p.Attractor = Attractor{Kind: AttractorPlane, Strength: 10, Direction: [3]float32{0, 1, 0}}
+	m.Add(p)
+	m.Step(1, nil)
+	if got := only(t, m); !near(got.Position, [3]float32{2, 0, 0}) {
+		t.Errorf("position %v, want stopped at the plane y = 0", got.Position)
+	}
+}
+
+func TestAttractorFollowsObject(t *testing.T) {
+	objects := object.NewManager()
+	objects.Add(object.New(4, "mob", false, [3]float32{0, 0, 10}, [3]float32{}, 10))
+	m := NewManager(objects)
+	p := DefaultParams()
+	p.ExpirationTime = 10
+	p.Attractor = Attractor{Kind: AttractorPoint, Strength: 2, OriginAttachment: 4}
+	m.Add(p)
+
+	m.Step(1, nil)
+	if got := only(t, m); !near(got.Position, [3]float32{0, 0, 2}) {
+		t.Errorf("position %v, want pulled towards the object", got.Position)
+	}
+
+	// Attractors of objects out of range do nothing
+	objects.Remove(4)
+	m.Step(1, nil)
+	if got := only(t, m); !near(got.Position, [3]float32{0, 0, 2}) {
+		t.Errorf("position %v, want unmoved", got.Position)
+	}
+}
+
+func timedSpawner() SpawnerParams {
+	p := DefaultSpawnerParams()
+	p.Amount = 10
+	p.Time = 1
+	p.Position = FixedVecTween([3]float32{1, 2, 3})
+	p.ExpirationTime = FixedTween(5)
+	p.Glow = 3
+	return p
+}
+
+func TestSpawner(t *testing.T) {
+	m := NewManager(nil)
+	m.AddSpawner(1, timedSpawner())
+
+	m.Step(1, nil)
+	if m.Len() != 10 {
+		t.Fatalf("%d particles, want all 10 spawned within the time", m.Len())
+	}
+	if len(m.spawners) != 0 {
+		t.Error("spawner kept after its time")
+	}
+	m.Each(func(p *Particle) {
+		if p.Position != [3]float32{1, 2, 3} || p.ExpirationTime != 5 || p.Glow != 3 {
+			t.Errorf("spawned %+v", p.Params)
+		}
+	})
+
+	// The particles outlive their spawner. They were spawned and moved within the first step.
+	m.Step(3.5, nil)
+	if m.Len() != 10 {
+		t.Errorf("%d particles, want 10", m.Len())
+	}
+	m.Step(1, nil)
+	if m.Len() != 0 {
+		t.Errorf("%d particles left after their expiration time", m.Len())
+	}
+}
+
+func TestRemoveSpawner(t *testing.T) {
+	m := NewManager(nil)
+	m.AddSpawner(1, timedSpawner())
+	m.RemoveSpawner(1)
+	m.RemoveSpawner(2) // Unknown IDs are ignored
+	m.Step(1, nil)
+	if m.Len() != 0 {
+		t.Errorf("removed spawner spawned %d particles", m.Len())
+	}
+}
+
+func TestAttachedSpawner(t *testing.T) {
+	objects := object.NewManager()
+	objects.Add(object.New(4, "mob", false, [3]float32{10, 0, 0}, [3]float32{}, 10))
+	m := NewManager(objects)
+	p := timedSpawner()
+	p.AttachedID = 4
+	m.AddSpawner(1, p)
+
+	m.Step(1, nil)
+	if m.Len() != 10 {
+		t.Fatalf("%d particles, want 10", m.Len())
+	}
+	m.Each(func(p *Particle) {
+		if !near(p.Position, [3]float32{11, 2, 3}) {
+			t.Errorf("position %v, want relative to the object", p.Position)
+		}
+	})
+
+	// Spawners of objects out of range skip the particles due meanwhile
+	m.Clear()
+	m.AddSpawner(1, p)
+	objects.Remove(4)
+	m.Step(1, nil)
+	if m.Len() != 0 || len(m.spawners) != 0 {
+		t.Errorf("%d particles and %d spawners, want none", m.Len(), len(m.spawners))
+	}
+}
+
+func TestClear(t *testing.T) {
+	m := NewManager(nil)
+	m.Add(DefaultParams())
+	p := timedSpawner()
+	p.Time = 0
+	m.AddSpawner(1, p)
+	m.Clear()
+	if m.Len() != 0 || len(m.spawners) != 0 {
+		t.Errorf("%d particles and %d spawners after clearing", m.Len(), len(m.spawners))
+	}
+}
+
+func TestMaxParticles(t *testing.T) {
+	m := NewManager(nil)
+	for range maxParticles + 10 {
+		m.Add(DefaultParams())
+	}
+	if m.Len() != maxParticles {
+		t.Errorf("%d particles, want at most %d", m.Len(), maxParticles)
+	}
+}
+
+func TestTweenFactor(t *testing.T) {
+	tests := []struct {
+		tween Tween
+		at    float32
+		want  float32
+	}{
+		{defaultTween, 0, 0},
+		{defaultTween, 0.25, 0.25},
+		{defaultTween, 1, 1},
+		{Tween{Style: TweenReverse, Reps: 1}, 0, 1},
+		{Tween{Style: TweenReverse, Reps: 1}, 0.25, 0.75},
+		{Tween{Style: TweenPulse, Reps: 1}, 0.25, 0.5},
+		{Tween{Style: TweenPulse, Reps: 1}, 0.5, 1},
+		{Tween{Style: TweenPulse, Reps: 1}, 0.75, 0.5},
+		{Tween{Style: TweenForward, Reps: 2}, 0.25, 0.5},
+		{Tween{Style: TweenForward, Reps: 2}, 0.75, 0.5},
+		{Tween{Style: TweenForward, Reps: 1, Beginning: 0.5}, 0.25, 0},
+		{Tween{Style: TweenForward, Reps: 1, Beginning: 0.5}, 0.75, 0.5},
+		{Tween{Style: TweenReverse, Reps: 1, Beginning: 0.5}, 0.25, 1},
+	}
+	for _, test := range tests {
+		if got := test.tween.factor(test.at); math.Abs(float64(got-test.want)) > 1e-6 {
+			t.Errorf("%+v at %v: %v, want %v", test.tween, test.at, got, test.want)
+		}
+	}
+
+	// Flickering only ever dims a pulse
+	flicker := Tween{Style: TweenFlicker, Reps: 1}
+	for range 100 {
+		if got := flicker.factor(0.5); got < 0.7 || got > 1 {
+			t.Fatalf("flicker at its peak: %v", got)
+		}
+	}
+}
+
+func TestTextureTweens(t *testing.T) {
+	m := NewManager(nil)
+	p := DefaultParams()
+	p.ExpirationTime = 2
+	p.Size = 2 * network.BS
+	p.Texture.Alpha = FloatTween{Tween: defaultTween, Start: 1, End: 0}
+	p.Texture.Scale = Vec2Tween{Tween: defaultTween, Start: [2]float32{1, 1}, End: [2]float32{2, 3}}
+	m.Add(p)
+
+	m.Step(1, nil)
+	got := only(t, m)
+	if a := got.Alpha(); a != 0.5 {
+		t.Errorf("alpha %v halfway, want 0.5", a)
+	}
+	if d := got.Dimensions(); d != [2]float32{3, 4} {
+		t.Errorf("dimensions %v halfway, want 3 by 4", d)
+	}
+}
+
+func TestRangePick(t *testing.T) {
+	r := FloatRange{Min: 2, Max: 4, Bias: 3}
+	v := VecRange{Min: [3]float32{-1, 0, 1}, Max: [3]float32{1, 0, 2}, Bias: -3}
+	var low, high int
+	for range 1000 {
+		f := r.Pick()
+		if f < 2 || f > 4 {
+			t.Fatalf("picked %v out of [2, 4]", f)
+		}
+		if f < 3 {
+			low++
+		}
+		picked := v.Pick()
+		for i := range picked {
+			if picked[i] < v.Min[i] || picked[i] > v.Max[i] {
+				t.Fatalf("picked %v out of %v to %v", picked, v.Min, v.Max)
+			}
+		}
+		if picked[2] > 1.5 {
+			high++
+		}
+	}
+	// A positive bias favours the minimum, a negative one the maximum
+	if low < 700 || high < 700 {
+		t.Errorf("%d of 1000 below the middle with a positive bias, %d above with a negative one", low, high)
+	}
+	if f := Fixed(7).Pick(); f != 7 {
+		t.Errorf("fixed range picked %v", f)
+	}
+}
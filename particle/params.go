@@ -0,0 +1,338 @@
+package particle
+
+import (
+	"math"
+	"math/rand/v2"
+
+	"bettermt/main/blocktypes"
+)
+
+// BlendMode is how a particle texture is drawn over what is behind it
+type BlendMode uint8
+
+const (
+	BlendAlpha  BlendMode = iota // Drawn over the background according to its alpha
+	BlendAdd                     // Added to the background, brightening it
+	BlendSub                     // Subtracted from the background, darkening it
+	BlendScreen                  // Brightens the background less than adding where it is already bright
+)
+
+// TweenStyle is how a tweened parameter moves between its start and end over the life of a spawner or particle
+type TweenStyle uint8
+
+const (
+	TweenForward TweenStyle = iota // From start to end
+	TweenReverse                   // From end to start
+	TweenPulse                     // From start to end and back
+	TweenFlicker                   // Like pulse, randomly dimmed towards the start
+)
+
+// AttractorKind is the shape particles are pulled towards
+type AttractorKind uint8
+
+const (
+	AttractorNone  AttractorKind = iota
+	AttractorPoint               // Towards the origin
+	AttractorLine                // Towards the line through the origin along the direction
+	AttractorPlane               // Towards the plane through the origin facing the direction
+)
+
+// Tween times the change of a parameter from its start to its end value
+type Tween struct {
+	Style     TweenStyle
+	Reps      uint16  // Times the change is played over the whole time
+	Beginning float32 // Part of the whole time the start value is kept before changing, from 0 to 1
+}
+
+// defaultTween plays the change once from start to end
+var defaultTween = Tween{Style: TweenForward, Reps: 1}
+
+// factor returns how far from the start value to the end value a parameter is at a point in time, where 0 is
+// the start and 1 the end of the whole time
+func (t Tween) factor(f float32) float32 {
+	if f <= t.Beginning {
+		if t.Style == TweenReverse {
+			return 1
+		}
+		return 0
+	}
+
+	f = (f - t.Beginning) / (1 - t.Beginning) * float32(t.Reps)
+	if f > 1 {
+		f -= float32(math.Floor(float64(f)))
+	}
+	switch t.Style {
+	case TweenReverse:
+		f = 1 - f
+	case TweenPulse, TweenFlicker:
+		if f > 0.5 {
+			f = 2 - 2*f
+		} else {
+			f *= 2
+		}
+		if t.Style == TweenFlicker {
+			f *= 0.7 + 0.3*rand.Float32()
+		}
+	}
+	return max(0, min(1, f))
+}
+
+// FloatRange is a number picked at random between a minimum and a maximum. A positive bias makes values near the
+// minimum more likely and a negative bias values near the maximum.
+type FloatRange struct {
+	Min, Max float32
+	Bias     float32
+}
+
+// Fixed returns a range that always picks v
+func Fixed(v float32) FloatRange {
+	return FloatRange{Min: v, Max: v}
+}
+
+// Pick returns a random number in the range
+func (r FloatRange) Pick() float32 {
+	return lerp(r.Min, r.Max, pickFactor(r.Bias))
+}
+
+func (r FloatRange) lerp(to FloatRange, f float32) FloatRange {
+	return FloatRange{Min: lerp(r.Min, to.Min, f), Max: lerp(r.Max, to.Max, f), Bias: lerp(r.Bias, to.Bias, f)}
+}
+
+// VecRange is a vector picked at random between a minimum and a maximum, each component on its own
+type VecRange struct {
+	Min, Max [3]float32
+	Bias     float32
+}
+
+// FixedVec returns a range that always picks v
+func FixedVec(v [3]float32) VecRange {
+	return VecRange{Min: v, Max: v}
+}
+
+// Pick returns a random vector in the range
+func (r VecRange) Pick() [3]float32 {
+	var v [3]float32
+	for i := range v {
+		v[i] = lerp(r.Min[i], r.Max[i], pickFactor(r.Bias))
+	}
+	return v
+}
+
+// IsZero reports whether the range only picks the zero vector
+func (r VecRange) IsZero() bool {
+	return r.Min == [3]float32{} && r.Max == [3]float32{}
+}
+
+func (r VecRange) lerp(to VecRange, f float32) VecRange {
+	return VecRange{Min: lerpVec(r.Min, to.Min, f), Max: lerpVec(r.Max, to.Max, f), Bias: lerp(r.Bias, to.Bias, f)}
+}
+
+// FloatTween is a number changing over time
+type FloatTween struct {
+	Tween
+	Start, End float32
+}
+
+// At returns the value at a point of the whole time, from 0 to 1
+func (t FloatTween) At(f float32) float32 {
+	return lerp(t.Start, t.End, t.factor(f))
+}
+
+// Vec2Tween is a pair of numbers changing over time
+type Vec2Tween struct {
+	Tween
+	Start, End [2]float32
+}
+
+// At returns the value at a point of the whole time, from 0 to 1
+func (t Vec2Tween) At(f float32) [2]float32 {
+	f = t.factor(f)
+	return [2]float32{lerp(t.Start[0], t.End[0], f), lerp(t.Start[1], t.End[1], f)}
+}
+
+// VecTween is a vector changing over time
+type VecTween struct {
+	Tween
+	Start, End [3]float32
+}
+
+// At returns the value at a point of the whole time, from 0 to 1
+func (t VecTween) At(f float32) [3]float32 {
+	return lerpVec(t.Start, t.End, t.factor(f))
+}
+
+// FloatRangeTween is a range of numbers changing over time
+type FloatRangeTween struct {
+	Tween
+	Start, End FloatRange
+}
+
+// FixedTween returns a tween whose range always picks v
+func FixedTween(v float32) FloatRangeTween {
+	return FloatRangeTween{Tween: defaultTween, Start: Fixed(v), End: Fixed(v)}
+}
+
+// At returns the range at a point of the whole time, from 0 to 1
+func (t FloatRangeTween) At(f float32) FloatRange {
+	return t.Start.lerp(t.End, t.factor(f))
+}
+
+// VecRangeTween is a range of vectors changing over time
+type VecRangeTween struct {
+	Tween
+	Start, End VecRange
+}
+
+// FixedVecTween returns a tween whose range always picks v
+func FixedVecTween(v [3]float32) VecRangeTween {
+	return VecRangeTween{Tween: defaultTween, Start: FixedVec(v), End: FixedVec(v)}
+}
+
+// At returns the range at a point of the whole time, from 0 to 1
+func (t VecRangeTween) At(f float32) VecRange {
+	return t.Start.lerp(t.End, t.factor(f))
+}
+
+// Texture is an image particles are drawn with, faded and scaled over their life
+type Texture struct {
+	Name      string
+	Blend     BlendMode
+	Alpha     FloatTween // Opacity from 0 to 1
+	Scale     Vec2Tween  // Width and height relative to the size of the particle
+	Animation blocktypes.TileAnimation
+}
+
+// DefaultTexture returns a texture drawn opaque at the size of its particle
+func DefaultTexture(name string) Texture {
+	return Texture{
+		Name:  name,
+		Alpha: FloatTween{Tween: defaultTween, Start: 1, End: 1},
+		Scale: Vec2Tween{Tween: defaultTween, Start: [2]float32{1, 1}, End: [2]float32{1, 1}},
+	}
+}
+
+// Node draws particles with a random part of a node texture instead of their own texture
+type Node struct {
+	Content uint16 // blocktypes.ContentIgnore for particles that are not made of a node
+	Param2  uint8
+	Tile    uint8 // 1 to 6 for a face of the node, 0 for a random face
+}
+
+// IsSet reports whether particles are made of the node
+func (n Node) IsSet() bool {
+	return n.Content != blocktypes.ContentIgnore
+}
+
+// Params describe a single particle. Positions are in nodes and the size in protocol units.
+type Params struct {
+	Position, Velocity, Acceleration [3]float32
+	Drag                             [3]float32 // Part of the velocity lost per second along each axis
+	ExpirationTime                   float32    // Seconds
+	Size                             float32
+
+	CollisionDetection bool // Stop at walkable nodes
+	CollisionRemoval   bool // Disappear when colliding
+	ObjectCollision    bool // Also stop at physical objects
+	Vertical           bool // Turn only around the vertical axis to face the camera
+
+	Texture Texture
+	Glow    uint8 // Light the particle gives off, from 0 to 14
+	Node    Node
+
+	Jitter VecRange   // Random offset added every second
+	Bounce FloatRange // Part of the speed kept when bouncing off a collision, 0 for no bounce
+
+	Attractor Attractor
+}
+
+// DefaultParams returns the parameters of a particle before the server sets any
+func DefaultParams() Params {
+	return Params{
+		ExpirationTime: 1,
+		Size:           1,
+		Texture:        DefaultTexture(""),
+		Node:           Node{Content: blocktypes.ContentIgnore},
+	}
+}
+
+// Attractor pulls particles towards a point, line or plane. The origin and direction may follow objects.
+type Attractor struct {
+	Kind                AttractorKind
+	Strength            float32 // Nodes per second, negative to push particles away
+	Origin              [3]float32
+	OriginAttachment    uint16 // Object the origin is relative to, 0 for none
+	Direction           [3]float32
+	DirectionAttachment uint16 // Object the direction turns with, 0 for none
+	KillOnContact       bool
+}
+
+// SpawnerParams describe a spawner that creates particles over time. Tweened parameters change over the time
+// of the spawner, ranges are picked again for every particle.
+type SpawnerParams struct {
+	Amount uint16  // Particles over the time, or per second for spawners without an end
+	Time   float32 // Seconds the spawner exists, 0 for until it is deleted
+
+	Position, Velocity, Acceleration VecRangeTween
+	Drag, Jitter, Radius             VecRangeTween
+	ExpirationTime, Size             FloatRangeTween
+	Bounce, Attract                  FloatRangeTween
+
+	CollisionDetection bool
+	CollisionRemoval   bool
+	ObjectCollision    bool
+	Vertical           bool
+
+	Texture     Texture   // Used when the pool is empty
+	TexturePool []Texture // Each particle picks one of these at random
+	Glow        uint8
+	Node        Node
+
+	AttractorKind       AttractorKind
+	AttractorOrigin     VecTween
+	AttractorDirection  VecTween
+	AttractorAttachment uint16 // Object the origin is relative to, 0 for none
+	DirectionAttachment uint16 // Object the direction turns with, 0 for none
+	AttractorKill       bool
+
+	AttachedID uint16 // Object the spawner moves and turns with, 0 for none
+}
+
+// DefaultSpawnerParams returns the parameters of a spawner before the server sets any
+func DefaultSpawnerParams() SpawnerParams {
+	zero := FixedVecTween([3]float32{})
+	return SpawnerParams{
+		Amount:             1,
+		Position:           zero,
+		Velocity:           zero,
+		Acceleration:       zero,
+		Drag:               zero,
+		Jitter:             zero,
+		Radius:             zero,
+		ExpirationTime:     FixedTween(1),
+		Size:               FixedTween(1),
+		Bounce:             FixedTween(0),
+		Attract:            FixedTween(0),
+		Texture:            DefaultTexture(""),
+		Node:               Node{Content: blocktypes.ContentIgnore},
+		AttractorOrigin:    VecTween{Tween: defaultTween},
+		AttractorDirection: VecTween{Tween: defaultTween},
+	}
+}
+
+// pickFactor returns a random factor from 0 to 1, skewed towards 0 by a positive bias and towards 1 by a
+// negative one
+func pickFactor(bias float32) float32 {
+	f := float32(math.Pow(rand.Float64(), math.Abs(float64(bias))+1))
+	if bias < 0 {
+		return 1 - f
+	}
+	return f
+}
+
+func lerp(a, b, f float32) float32 {
+	return a + (b-a)*f
+}
+
+func lerpVec(a, b [3]float32, f float32) [3]float32 {
+	return [3]float32{lerp(a[0], b[0], f), lerp(a[1], b[1], f), lerp(a[2], b[2], f)}
+}
@@ -0,0 +1,265 @@
+// Package particle simulates the particles and particle spawners the server sends and the particles the client
+// makes itself, such as the bits of a node being dug. Particles are moved on the CPU; drawing them is left to
+// the caller.
+package particle
+
+import (
+	"image/color"
+	"math"
+	"math/rand/v2"
+
+	"bettermt/main/blocktypes"
+	"bettermt/main/network"
+)
+
+const (
+	// Longest distance a particle moves before checking for collisions again
+	maxMoveDistance = 0.4
+	// Gap kept between a particle and what it collided with
+	collisionMargin = 0.001
+)
+
+// Environment is the world particles collide with
+type Environment interface {
+	// Node returns the definition of the node at a position, and false while it is not loaded
+	Node(x, y, z int32) (*blocktypes.NodeDefinition, bool)
+}
+
+// Particle is one particle alive in the world
+type Particle struct {
+	Params
+	Age     float32     // Seconds since the particle appeared
+	Color   color.NRGBA // Tint of the texture, from the tile of a node
+	texRect [4]float32  // Part of the texture drawn as left, top, right and bottom, from 0 to 1
+	bounce  float32
+	expired bool
+	// Show the first frame of an animated texture only, like the tiles of node particles
+	firstFrame bool
+}
+
+// newParticle creates a particle. Particles made of a node take a random part of one of its tiles as texture.
+func newParticle(p Params) *Particle {
+	pt := &Particle{
+		Params:  p,
+		Color:   color.NRGBA{R: 255, G: 255, B: 255, A: 255},
+		texRect: [4]float32{0, 0, 1, 1},
+		bounce:  p.Bounce.Pick(),
+	}
+	if p.Node.IsSet() {
+		pt.useNodeTexture()
+	}
+	return pt
+}
+
+// useNodeTexture draws the particle with a random part of a tile of its node, at a random size, the way Minetest
+// draws node particles
+func (p *Particle) useNodeTexture() {
+	def := blocktypes.NodeDefs().Get(p.Node.Content)
+	tile := def.Tiles[rand.IntN(len(def.Tiles))]
+	if p.Node.Tile >= 1 && int(p.Node.Tile) <= len(def.Tiles) {
+		tile = def.Tiles[p.Node.Tile-1]
+	}
+
+	size := float32(rand.IntN(8)) / 64
+	p.Size = network.BS * size
+	if tile.Scale > 0 {
+		size /= float32(tile.Scale)
+	}
+	texSize := 2 * size
+	left := max(0, float32(rand.IntN(64))/64-texSize)
+	top := max(0, float32(rand.IntN(64))/64-texSize)
+	p.texRect = [4]float32{left, top, left + texSize, top + texSize}
+
+	p.Texture.Name = tile.Name
+	// Only the first frame of animated tiles is used
+	p.Texture.Animation = tile.Animation
+	p.firstFrame = true
+	if tile.Flags&blocktypes.TileFlagHasColor != 0 {
+		p.Color = tile.Color
+	}
+}
+
+// Expired reports whether the particle is gone
+func (p *Particle) Expired() bool {
+	return p.expired || p.Age >= p.ExpirationTime
+}
+
+// life returns how much of its life the particle has lived, from 0 to 1
+func (p *Particle) life() float32 {
+	if p.ExpirationTime <= 0 {
+		return 1
+	}
+	return min(1, p.Age/p.ExpirationTime)
+}
+
+// Dimensions returns the width and height the particle is drawn with, in nodes
+func (p *Particle) Dimensions() [2]float32 {
+	scale := p.Texture.Scale.At(p.life())
+	size := p.Size / network.BS
+	return [2]float32{size * scale[0], size * scale[1]}
+}
+
+// Alpha returns the opacity the particle is drawn with, from 0 to 1
+func (p *Particle) Alpha() float32 {
+	return max(0, min(1, p.Texture.Alpha.At(p.life())))
+}
+
+// TextureRect returns the part of the texture to draw as left, top, right and bottom, from 0 to 1, for a
+// texture of a size in pixels. Animated textures show their current frame.
+func (p *Particle) TextureRect(width, height int) [4]float32 {
+	r := p.texRect
+	a := p.Texture.Animation
+	var frame, cols, rows int
+	switch a.Type {
+	case blocktypes.TileAnimationVerticalFrames:
+		if a.AspectW == 0 || a.AspectH == 0 || width <= 0 {
+			return r
+		}
+		frameHeight := max(1, width*int(a.AspectH)/int(a.AspectW))
+		cols, rows = 1, max(1, height/frameHeight)
+		if a.Length > 0 && !p.firstFrame {
+			frame = int(p.Age/(a.Length/float32(rows))) % rows
+		}
+	case blocktypes.TileAnimationSheet2D:
+		cols, rows = max(1, int(a.FramesW)), max(1, int(a.FramesH))
+		if a.Length > 0 && !p.firstFrame {
+			frame = int(p.Age/a.Length) % (cols * rows)
+		}
+	default:
+		return r
+	}
+	col, row := frame%cols, frame/cols
+	w, h := 1/float32(cols), 1/float32(rows)
+	return [4]float32{
+		(float32(col) + r[0]) * w, (float32(row) + r[1]) * h,
+		(float32(col) + r[2]) * w, (float32(row) + r[3]) * h,
+	}
+}
+
+// step moves the particle on by dtime seconds
+func (p *Particle) step(dtime float32, s *surroundings) {
+	p.Age += dtime
+	if p.Expired() {
+		return
+	}
+
+	for i := range p.Velocity {
+		p.Velocity[i] *= max(0, 1-p.Drag[i]*dtime)
+		p.Velocity[i] += p.Acceleration[i] * dtime
+	}
+	delta := [3]float32{p.Velocity[0] * dtime, p.Velocity[1] * dtime, p.Velocity[2] * dtime}
+	if p.CollisionDetection {
+		if p.move(delta, s) && p.CollisionRemoval {
+			p.expired = true
+			return
+		}
+	} else {
+		for i := range p.Position {
+			p.Position[i] += delta[i]
+		}
+	}
+
+	if !p.Jitter.IsZero() {
+		jitter := p.Jitter.Pick()
+		for i := range p.Position {
+			p.Position[i] += jitter[i] * dtime
+		}
+	}
+	p.attract(dtime, s)
+}
+
+// move moves the particle by delta one axis at a time, stopping or bouncing at walkable nodes and, if it
+// collides with objects, at physical objects. It reports whether the particle collided.
+func (p *Particle) move(delta [3]float32, s *surroundings) bool {
+	longest := max(abs(delta[0]), abs(delta[1]), abs(delta[2]))
+	steps := max(1, int(math.Ceil(float64(longest/maxMoveDistance))))
+	for i := range delta {
+		delta[i] /= float32(steps)
+	}
+
+	half := max(collisionMargin, p.Size/network.BS/2)
+	collided := false
+	for range steps {
+		for axis := range delta {
+			if delta[axis] == 0 {
+				continue
+			}
+			to := p.Position
+			to[axis] += delta[axis]
+			stop, blocked := s.collide(p.Position, to, half, axis, delta[axis], p.ObjectCollision)
+			if !blocked {
+				p.Position = to
+				continue
+			}
+			collided = true
+			p.Position[axis] = stop
+			p.Velocity[axis] = -p.Velocity[axis] * p.bounce
+			delta[axis] = -delta[axis] * p.bounce
+		}
+	}
+	return collided
+}
+
+// attract moves the particle towards its attractor, removing it on contact if the attractor asks for that
+func (p *Particle) attract(dtime float32, s *surroundings) {
+	a := p.Attractor
+	if a.Kind == AttractorNone || a.Strength == 0 {
+		return
+	}
+	origin, direction, ok := s.attractor(a)
+	if !ok {
+		return
+	}
+
+	var rel [3]float32
+	for i := range rel {
+		rel[i] = origin[i] - p.Position[i]
+	}
+	if a.Kind != AttractorPoint {
+		direction = normalize(direction)
+		d := dot(rel, direction)
+		for i := range rel {
+			if a.Kind == AttractorLine {
+				rel[i] -= direction[i] * d
+			} else {
+				rel[i] = direction[i] * d
+			}
+		}
+	}
+	dist := length(rel)
+	if dist == 0 {
+		return
+	}
+
+	move := a.Strength * dtime
+	if move > 0 && move >= dist {
+		if a.KillOnContact {
+			p.expired = true
+			return
+		}
+		move = dist
+	}
+	for i := range p.Position {
+		p.Position[i] += rel[i] / dist * move
+	}
+}
+
+func abs(v float32) float32 {
+	return float32(math.Abs(float64(v)))
+}
+
+func dot(a, b [3]float32) float32 {
+	return a[0]*b[0] + a[1]*b[1] + a[2]*b[2]
+}
+
+func length(v [3]float32) float32 {
+	return float32(math.Sqrt(float64(dot(v, v))))
+}
+
+func normalize(v [3]float32) [3]float32 {
+	l := length(v)
+	if l == 0 {
+		return v
+	}
+	return [3]float32{v[0] / l, v[1] / l, v[2] / l}
+}
@@ -0,0 +1,126 @@
+package particle
+
+import (
+	"math"
+	"math/rand/v2"
+
+	"bettermt/main/object"
+)
+
+// spawner creates particles over its time, or forever if it has none
+type spawner struct {
+	params  SpawnerParams
+	time    float32
+	pending []float32 // When the particles not spawned yet are due, for spawners with a time
+}
+
+// newSpawner creates a spawner whose particles come at random moments of its time
+func newSpawner(p SpawnerParams) *spawner {
+	sp := &spawner{params: p}
+	if p.Time > 0 {
+		sp.pending = make([]float32, p.Amount)
+		for i := range sp.pending {
+			sp.pending[i] = rand.Float32() * p.Time
+		}
+	}
+	return sp
+}
+
+// step spawns the particles due within dtime seconds and reports whether the spawner is done. Spawners
+// attached to an object that is not in range skip the particles due meanwhile.
+func (sp *spawner) step(dtime float32, s *surroundings, spawn func(Params)) bool {
+	sp.time += dtime
+	var parent *object.Object
+	if sp.params.AttachedID != 0 {
+		parent, _ = s.object(sp.params.AttachedID)
+	}
+	unloaded := sp.params.AttachedID != 0 && parent == nil
+
+	if sp.params.Time <= 0 {
+		// Without an end the amount is spawned every second on average
+		for range int(sp.params.Amount) + 1 {
+			if rand.Float32() < dtime && !unloaded {
+				spawn(sp.particle(parent))
+			}
+		}
+		return false
+	}
+
+	kept := sp.pending[:0]
+	for _, due := range sp.pending {
+		switch {
+		case due > sp.time:
+			kept = append(kept, due)
+		case !unloaded:
+			spawn(sp.particle(parent))
+		}
+	}
+	sp.pending = kept
+	return len(sp.pending) == 0
+}
+
+// particle picks the parameters of one particle for the current time of the spawner. Particles of attached
+// spawners start relative to the parent and turn with it.
+func (sp *spawner) particle(parent *object.Object) Params {
+	p := &sp.params
+	var f float32
+	if p.Time > 0 {
+		f = sp.time / max(0.01, p.Time)
+	}
+
+	pp := DefaultParams()
+	pp.Position = p.Position.At(f).Pick()
+	pp.Velocity = p.Velocity.At(f).Pick()
+	pp.Acceleration = p.Acceleration.At(f).Pick()
+	pp.Drag = p.Drag.At(f).Pick()
+	pp.ExpirationTime = p.ExpirationTime.At(f).Pick()
+	pp.Size = p.Size.At(f).Pick()
+	pp.Jitter = p.Jitter.At(f)
+	pp.Bounce = p.Bounce.At(f)
+	if radius := p.Radius.At(f); !radius.IsZero() {
+		offset, r := randomDirection(), radius.Pick()
+		for i := range pp.Position {
+			pp.Position[i] += offset[i] * r[i]
+		}
+	}
+	if parent != nil {
+		pp.Position = object.Rotate(pp.Position, parent.VisualRotation)
+		for i := range pp.Position {
+			pp.Position[i] += parent.VisualPosition[i]
+		}
+		pp.Velocity = object.Rotate(pp.Velocity, parent.VisualRotation)
+		pp.Acceleration = object.Rotate(pp.Acceleration, parent.VisualRotation)
+	}
+
+	pp.CollisionDetection = p.CollisionDetection
+	pp.CollisionRemoval = p.CollisionRemoval
+	pp.ObjectCollision = p.ObjectCollision
+	pp.Vertical = p.Vertical
+	pp.Texture = p.Texture
+	if len(p.TexturePool) > 0 {
+		pp.Texture = p.TexturePool[rand.IntN(len(p.TexturePool))]
+	}
+	pp.Glow = p.Glow
+	pp.Node = p.Node
+
+	if p.AttractorKind != AttractorNone {
+		pp.Attractor = Attractor{
+			Kind:                p.AttractorKind,
+			Strength:            p.Attract.At(f).Pick(),
+			Origin:              p.AttractorOrigin.At(f),
+			OriginAttachment:    p.AttractorAttachment,
+			Direction:           p.AttractorDirection.At(f),
+			DirectionAttachment: p.DirectionAttachment,
+			KillOnContact:       p.AttractorKill,
+		}
+	}
+	return pp
+}
+
+// randomDirection returns a unit vector pointing anywhere with equal chance
+func randomDirection() [3]float32 {
+	y := 2*rand.Float64() - 1
+	angle := 2 * math.Pi * rand.Float64()
+	r := math.Sqrt(1 - y*y)
+	return [3]float32{float32(r * math.Cos(angle)), float32(y), float32(r * math.Sin(angle))}
+}
@@ -0,0 +1,183 @@
+package player
+
+import (
+	"math"
+	"sync"
+)
+
+const (
+	// Height of the eyes above the feet, in nodes
+	EyeHeight = 1.625
+	// Field of view used when the server does not set one, in degrees
+	DefaultFOV = 72
+	// Distance the client asks the server to send blocks within, in nodes
+	DefaultViewRange = 100
+	// Largest angle the player can look up or down, in degrees
+	maxPitch = 89.5
+)
+
+// Controls are the keys the player holds, as reported to the server
+type Controls struct {
+	Forward  bool
+	Backward bool
+	Left     bool
+	Right    bool
+	Jump     bool
+	Aux1     bool
+	Sneak    bool
+	Dig      bool
+	Place    bool
+	Zoom     bool
+}
+
+// Bits packs the controls into the key bit field of TOSERVER_PLAYERPOS
+func (c Controls) Bits() uint32 {
+	var bits uint32
+	for i, pressed := range []bool{c.Forward, c.Backward, c.Left, c.Right, c.Jump, c.Aux1, c.Sneak, c.Dig, c.Place, c.Zoom} {
+		if pressed {
+			bits |= 1 << i
+		}
+	}
+	return bits
+}
+
+// State is a snapshot of the local player. Positions are in nodes, angles in degrees.
+type State struct {
+	Position  [3]float32 // Feet of the player
+	Velocity  [3]float32 // Nodes per second
+	Pitch     float32    // Positive looks down
+	Yaw       float32
+	Controls  Controls
+	FOV       float32
+	ViewRange float32 // In nodes
+}
+
+// LocalPlayer is the player controlled on this client. It is safe for concurrent use, so that
+// the render loop can move it while the network side reports it to the server.
+type LocalPlayer struct {
+	mu             sync.Mutex
+	state          State
+	physics        Physics
+	touchingGround bool
+	fastMove       bool // Aux1 moves at the fast speed, which takes the fast privilege
+}
+
+// NewLocalPlayer creates a player standing at the origin
+func NewLocalPlayer() *LocalPlayer {
+	return &LocalPlayer{
+		state:   State{FOV: DefaultFOV, ViewRange: DefaultViewRange},
+		physics: Physics{Movement: DefaultMovementSettings(), Override: DefaultPhysicsOverride()},
+	}
+}
+
+// State returns a snapshot of the player
+func (p *LocalPlayer) State() State {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.state
+}
+
+// Position returns where the feet of the player are
+func (p *LocalPlayer) Position() [3]float32 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.state.Position
+}
+
+// TouchingGround reports whether the player stood on a walkable node after its last move
+func (p *LocalPlayer) TouchingGround() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.touchingGround
+}
+
+// EyePosition returns where the camera of the player is
+func (p *LocalPlayer) EyePosition() [3]float32 {
+	pos := p.Position()
+	pos[1] += EyeHeight
+	return pos
+}
+
+// SetPosition moves the player without changing where it looks
+func (p *LocalPlayer) SetPosition(pos [3]float32) {
+	p.mu.Lock()
+	p.state.Position = pos
+	p.mu.Unlock()
+}
+
+// MoveTo puts the player where the server wants it, as in TOCLIENT_MOVE_PLAYER, and stops it
+func (p *LocalPlayer) MoveTo(pos [3]float32, pitch, yaw float32) {
+	p.mu.Lock()
+	p.state.Position = pos
+	p.state.Velocity = [3]float32{}
+	p.state.Pitch = clampPitch(pitch)
+	p.state.Yaw = wrapYaw(yaw)
+	p.mu.Unlock()
+}
+
+// Turn changes where the player looks by the given angles in degrees
+func (p *LocalPlayer) Turn(pitch, yaw float32) {
+	p.mu.Lock()
+	p.state.Pitch = clampPitch(p.state.Pitch + pitch)
+	p.state.Yaw = wrapYaw(p.state.Yaw + yaw)
+	p.mu.Unlock()
+}
+
+// SetControls replaces the keys the player holds
+func (p *LocalPlayer) SetControls(c Controls) {
+	p.mu.Lock()
+	p.state.Controls = c
+	p.mu.Unlock()
+}
+
+// SetFastMove sets whether holding aux1 moves the player at the fast speed of the server, as the fast
+// privilege allows
+func (p *LocalPlayer) SetFastMove(enabled bool) {
+	p.mu.Lock()
+	p.fastMove = enabled
+	p.mu.Unlock()
+}
+
+// SetFOV sets the field of view in degrees
+func (p *LocalPlayer) SetFOV(fov float32) {
+	p.mu.Lock()
+	p.state.FOV = fov
+	p.mu.Unlock()
+}
+
+// SetViewRange sets the distance in nodes the player wants to see
+func (p *LocalPlayer) SetViewRange(nodes float32) {
+	p.mu.Lock()
+	p.state.ViewRange = nodes
+	p.mu.Unlock()
+}
+
+// LookDirection returns the unit vector the player looks along
+func (p *LocalPlayer) LookDirection() [3]float32 {
+	p.mu.Lock()
+	pitch, yaw := radians(p.state.Pitch), radians(p.state.Yaw)
+	p.mu.Unlock()
+	return [3]float32{
+		float32(-math.Sin(yaw) * math.Cos(pitch)),
+		float32(-math.Sin(pitch)),
+		float32(math.Cos(yaw) * math.Cos(pitch)),
+	}
+}
+
+// clampPitch keeps the player from looking past straight up or down
+func clampPitch(pitch float32) float32 {
+	return max(-maxPitch, min(maxPitch, pitch))
+}
+
+// wrapYaw keeps the yaw within 0 to 360 degrees
+func wrapYaw(yaw float32) float32 {
+	yaw = float32(math.Mod(float64(yaw), 360))
+	if yaw < 0 {
+		yaw += 360
+	}
+	return yaw
+}
+
+func radians(degrees float32) float64 {
+	return float64(degrees) * math.Pi / 180
+}
@@ -0,0 +1,309 @@
+package player
+
+import (
+	"math"
+
+	"bettermt/main/blocktypes"
+	"bettermt/main/meshbuilder"
+)
+
+const (
+	// Longest time simulated at once, so that a slow frame does not let the player pass through nodes
+	maxStepTime = 0.05
+	// Longest distance moved before checking for collisions again
+	maxMoveDistance = 0.4
+	// Gap kept between the player and the nodes it touches
+	collisionMargin = 0.001
+	// Tolerance for deciding which node a face of the player lies in
+	faceEpsilon = 0.0001
+	// Minetest integrates gravity and liquid sinking at twice the configured rate, and servers tune
+	// their settings to that, such as the jump speed being just enough to climb one node
+	gravityFactor = 2
+	// How much the viscosity of a liquid slows the player down, from 0 to 1
+	viscosityFactor = 0.3
+)
+
+// Collision box of the player relative to its feet, as in the default player of Minetest
+var collisionBox = [2][3]float32{{-0.3, 0, -0.3}, {0.3, 1.77, 0.3}}
+
+// Environment is the world the local player moves through
+type Environment interface {
+	// Node returns the definition of the node at a position, and false while it is not loaded
+	Node(x, y, z int32) (*blocktypes.NodeDefinition, bool)
+}
+
+// SetMovementSettings replaces the movement settings of the server
+func (p *LocalPlayer) SetMovementSettings(m MovementSettings) {
+	p.mu.Lock()
+	p.physics.Movement = m
+	p.mu.Unlock()
+}
+
+// SetPhysicsOverride replaces the factors the server applies to the movement of this player
+func (p *LocalPlayer) SetPhysicsOverride(o PhysicsOverride) {
+	p.mu.Lock()
+	p.physics.Override = o
+	p.mu.Unlock()
+}
+
+// Physics returns the movement settings and overrides in use
+func (p *LocalPlayer) Physics() Physics {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.physics
+}
+
+// Step moves the player for dtime seconds according to its controls and physics, colliding with the walkable
+// nodes of env. The player waits in place while the nodes around it are not loaded.
+func (p *LocalPlayer) Step(dtime float32, env Environment) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for dtime > 0 {
+		part := min(dtime, maxStepTime)
+		dtime -= part
+		if !p.surroundingsLoaded(env) {
+			p.state.Velocity = [3]float32{}
+			return
+		}
+		p.step(part, env)
+	}
+}
+
+// step simulates one short part of Step
+func (p *LocalPlayer) step(dtime float32, env Environment) {
+	s := &p.state
+	m := p.physics.Effective()
+	c := s.Controls
+
+	feet := nodeAt(env, s.Position[0], s.Position[1]+0.1, s.Position[2])
+	inLiquid := feet.LiquidType != blocktypes.LiquidNone
+	climbing := !inLiquid && (feet.Climbable || nodeAt(env, s.Position[0], s.Position[1]+0.5, s.Position[2]).Climbable)
+
+	// Speed the controls ask for. Moving fast wins over sneaking, as in Minetest.
+	fast := p.fastMove && c.Aux1
+	speed := m.SpeedWalk
+	switch {
+	case fast:
+		speed = m.SpeedFast
+	case c.Sneak && !inLiquid && !climbing:
+		speed = m.SpeedCrouch
+	}
+	dir := moveDirection(c, s.Yaw)
+	target := [3]float32{dir[0] * speed, 0, dir[1] * speed}
+
+	// How fast the player can reach it. Swimming and climbing go up and down at the fast speed too.
+	accel, swim, climb := m.AccelerationDefault, m.SpeedWalk, m.SpeedClimb
+	if fast {
+		accel, swim, climb = m.AccelerationFast, m.SpeedFast, m.SpeedFast
+	}
+	var incH, incV float32
+	switch {
+	case inLiquid:
+		incH = m.AccelerationDefault * dtime
+		if c.Jump {
+			target[1], incV = swim, accel*dtime
+		} else if c.Sneak {
+			target[1], incV = -swim, accel*dtime
+		}
+	case climbing:
+		incH, incV = accel*dtime, accel*dtime
+		if c.Jump {
+			target[1] = climb
+		} else if c.Sneak {
+			target[1] = -climb
+		}
+	case p.touchingGround:
+		incH = accel * dtime
+		if c.Jump {
+			s.Velocity[1] = m.SpeedJump
+		}
+	default:
+		incH = m.AccelerationAir * dtime
+	}
+	s.Velocity = accelerate(s.Velocity, target, incH, incV)
+
+	switch {
+	case inLiquid:
+		s.Velocity[1] -= m.LiquidSink * dtime * gravityFactor
+		s.Velocity = liquidResistance(s.Velocity, m, feet.LiquidViscosity, dtime)
+	case !climbing:
+		s.Velocity[1] -= m.Gravity * dtime * gravityFactor
+	}
+
+	holdEdges := c.Sneak && p.physics.Override.Sneak && p.touchingGround && !inLiquid && !climbing
+	var delta [3]float32
+	for i := range delta {
+		delta[i] = s.Velocity[i] * dtime
+	}
+	p.move(env, delta, holdEdges)
+}
+
+// move moves the player by delta one axis at a time, stopping at walkable nodes. With holdEdges set the
+// player does not leave the nodes it stands on, as when sneaking.
+func (p *LocalPlayer) move(env Environment, delta [3]float32, holdEdges bool) {
+	s := &p.state
+	longest := max(abs(delta[0]), abs(delta[1]), abs(delta[2]))
+	steps := max(1, int(math.Ceil(float64(longest/maxMoveDistance))))
+	for i := range delta {
+		delta[i] /= float32(steps)
+	}
+
+	for range steps {
+		for _, axis := range [3]int{1, 0, 2} {
+			if delta[axis] == 0 {
+				continue
+			}
+			pos := s.Position
+			pos[axis] += delta[axis]
+			stop, blocked := collide(env, pos, axis, delta[axis])
+			switch {
+			case blocked:
+				s.Position[axis] = stop
+			case holdEdges && axis != 1 && !standsOnNode(env, pos):
+				// Stay on the edge instead of falling off
+			default:
+				s.Position = pos
+				continue
+			}
+			s.Velocity[axis] = 0
+			delta[axis] = 0
+		}
+	}
+	p.touchingGround = standsOnNode(env, s.Position)
+}
+
+// surroundingsLoaded reports whether every node the player can touch in one step is loaded
+func (p *LocalPlayer) surroundingsLoaded(env Environment) bool {
+	lo, hi := nodeRange(p.state.Position)
+	for x := lo[0] - 1; x <= hi[0]+1; x++ {
+		for y := lo[1] - 1; y <= hi[1]+1; y++ {
+			for z := lo[2] - 1; z <= hi[2]+1; z++ {
+				if _, loaded := env.Node(x, y, z); !loaded {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// collide checks the layer of nodes the leading face of the player entered by moving d along axis to pos.
+// If one of them is walkable it returns where the player stops instead.
+func collide(env Environment, pos [3]float32, axis int, d float32) (float32, bool) {
+	face, sign := collisionBox[1][axis], float32(1)
+	if d < 0 {
+		face, sign = collisionBox[0][axis], -1
+	}
+	layer := meshbuilder.NodeCoord(pos[axis] + face - sign*faceEpsilon)
+	if layer == meshbuilder.NodeCoord(pos[axis]-d+face-sign*faceEpsilon) {
+		return 0, false
+	}
+
+	lo, hi := nodeRange(pos)
+	lo[axis], hi[axis] = layer, layer
+	for x := lo[0]; x <= hi[0]; x++ {
+		for y := lo[1]; y <= hi[1]; y++ {
+			for z := lo[2]; z <= hi[2]; z++ {
+				if def, _ := env.Node(x, y, z); def != nil && def.Walkable {
+					return float32(layer) - sign*0.5 - face - sign*collisionMargin, true
+				}
+			}
+		}
+	}
+	return 0, false
+}
+
+// standsOnNode reports whether a walkable node is right below the feet of a player at pos
+func standsOnNode(env Environment, pos [3]float32) bool {
+	lo, hi := nodeRange(pos)
+	below := meshbuilder.NodeCoord(pos[1] - 2*collisionMargin)
+	for x := lo[0]; x <= hi[0]; x++ {
+		for z := lo[2]; z <= hi[2]; z++ {
+			if def, _ := env.Node(x, below, z); def != nil && def.Walkable {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// nodeRange returns the first and last nodes overlapped by a player at pos
+func nodeRange(pos [3]float32) (lo, hi [3]int32) {
+	for i := range pos {
+		lo[i] = meshbuilder.NodeCoord(pos[i] + collisionBox[0][i] + faceEpsilon)
+		hi[i] = meshbuilder.NodeCoord(pos[i] + collisionBox[1][i] - faceEpsilon)
+	}
+	return lo, hi
+}
+
+// nodeAt returns the node containing a point, or air if it is not loaded
+func nodeAt(env Environment, x, y, z float32) *blocktypes.NodeDefinition {
+	if def, loaded := env.Node(meshbuilder.NodeCoord(x), meshbuilder.NodeCoord(y), meshbuilder.NodeCoord(z)); loaded && def != nil {
+		return def
+	}
+	return blocktypes.NodeDefs().Get(blocktypes.ContentAir)
+}
+
+// moveDirection returns the horizontal unit vector the controls move along, or zero if they cancel out
+func moveDirection(c Controls, yawDegrees float32) [2]float32 {
+	yaw := radians(yawDegrees)
+	forward := [2]float32{float32(-math.Sin(yaw)), float32(math.Cos(yaw))}
+	right := [2]float32{float32(math.Cos(yaw)), float32(math.Sin(yaw))}
+
+	var move [2]float32
+	add := func(pressed bool, dir [2]float32, sign float32) {
+		if pressed {
+			move[0] += dir[0] * sign
+			move[1] += dir[1] * sign
+		}
+	}
+	add(c.Forward, forward, 1)
+	add(c.Backward, forward, -1)
+	add(c.Right, right, 1)
+	add(c.Left, right, -1)
+
+	// Diagonal movement is no faster than straight movement
+	if length := float32(math.Hypot(float64(move[0]), float64(move[1]))); length > 0 {
+		move[0] /= length
+		move[1] /= length
+	}
+	return move
+}
+
+// accelerate changes the velocity towards target by at most incH horizontally and incV vertically
+func accelerate(v, target [3]float32, incH, incV float32) [3]float32 {
+	dx, dz := target[0]-v[0], target[2]-v[2]
+	if length := float32(math.Hypot(float64(dx), float64(dz))); length > incH {
+		dx, dz = dx*incH/length, dz*incH/length
+	}
+	v[0] += dx
+	v[2] += dz
+	if incV > 0 {
+		v[1] += max(-incV, min(incV, target[1]-v[1]))
+	}
+	return v
+}
+
+// liquidResistance slows the player down in a liquid as Minetest does, more so the more viscous it is
+func liquidResistance(v [3]float32, m MovementSettings, viscosity uint8, dtime float32) [3]float32 {
+	if m.LiquidFluidity <= 0 {
+		return v
+	}
+	length := float32(math.Sqrt(float64(v[0]*v[0]+v[1]*v[1]+v[2]*v[2]))) / m.LiquidFluidity
+	if length == 0 {
+		return v
+	}
+	slowdown := min(length, m.LiquidFluiditySmooth)
+	slowdown *= float32(viscosity)*viscosityFactor + 1 - viscosityFactor
+	// Minetest applies the resistance per hundredth of a second
+	scale := min(1, slowdown*dtime*100/(length*m.LiquidFluidity))
+	for i := range v {
+		v[i] -= v[i] * scale
+	}
+	return v
+}
+
+func abs(v float32) float32 {
+	return float32(math.Abs(float64(v)))
+}
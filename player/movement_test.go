@@ -0,0 +1,122 @@
+package player
+
+import (
+	"math"
+	"testing"
+
+	"bettermt/main/blocktypes"
+)
+
+// flatEnv is a loaded world of stone below y 0 and air above, with a column of ladder nodes at the origin
+type flatEnv struct{ ladder bool }
+
+var (
+	testStone  = &blocktypes.NodeDefinition{Name: "test:stone", Walkable: true}
+	testLadder = &blocktypes.NodeDefinition{Name: "test:ladder", Climbable: true}
+)
+
+func (e flatEnv) Node(x, y, z int32) (*blocktypes.NodeDefinition, bool) {
+	switch {
+	case y < 0:
+		return testStone, true
+	case e.ladder && x == 0 && z == 0:
+		return testLadder, true
+	}
+	return blocktypes.NodeDefs().Get(blocktypes.ContentAir), true
+}
+
+// horizontalSpeed returns how fast the player moves along the ground
+func horizontalSpeed(p *LocalPlayer) float32 {
+	v := p.State().Velocity
+	return float32(math.Hypot(float64(v[0]), float64(v[2])))
+}
+
+// standingPlayer returns a player on the ground of env holding some controls
+func standingPlayer(env Environment, c Controls) *LocalPlayer {
+	p := NewLocalPlayer()
+	p.SetPosition([3]float32{0, -0.5, 0})
+	p.Step(0.1, env) // Settle onto the ground
+	p.SetControls(c)
+	return p
+}
+
+func near(got, want float32) bool {
+	return math.Abs(float64(got-want)) < 1e-3
+}
+
+func TestFastMove(t *testing.T) {
+	env := flatEnv{}
+	m := DefaultMovementSettings()
+	for _, test := range []struct {
+		name     string
+		controls Controls
+		fast     bool
+		speed    float32 // After 0.2 seconds
+		top      float32 // After long enough to stop accelerating
+	}{
+		{"walk", Controls{Forward: true}, false, 0.2 * m.AccelerationDefault, m.SpeedWalk},
+		{"aux1 without privilege", Controls{Forward: true, Aux1: true}, false, 0.2 * m.AccelerationDefault, m.SpeedWalk},
+		{"fast", Controls{Forward: true, Aux1: true}, true, 0.2 * m.AccelerationFast, m.SpeedFast},
+		{"fast without aux1", Controls{Forward: true}, true, 0.2 * m.AccelerationDefault, m.SpeedWalk},
+		{"sneak", Controls{Forward: true, Sneak: true}, false, 0.2 * m.AccelerationDefault, m.SpeedCrouch},
+		{"fast over sneak", Controls{Forward: true, Sneak: true, Aux1: true}, true, 0.2 * m.AccelerationFast, m.SpeedFast},
+	} {
+		p := standingPlayer(env, test.controls)
+		p.SetFastMove(test.fast)
+		p.Step(0.2, env)
+		if got := horizontalSpeed(p); !near(got, test.speed) {
+			t.Errorf("%s: speed %v after 0.2s, want %v", test.name, got, test.speed)
+		}
+		p.Step(3, env)
+		if got := horizontalSpeed(p); !near(got, test.top) {
+			t.Errorf("%s: top speed %v, want %v", test.name, got, test.top)
+		}
+	}
+}
+
+func TestFastMoveOverrides(t *testing.T) {
+	env := flatEnv{}
+	o := DefaultPhysicsOverride()
+	o.SpeedFast, o.AccelerationFast, o.Speed = 0.5, 2, 1.5
+	p := standingPlayer(env, Controls{Forward: true, Aux1: true})
+	p.SetFastMove(true)
+	p.SetPhysicsOverride(o)
+
+	m := DefaultMovementSettings()
+	p.Step(0.1, env)
+	if got, want := horizontalSpeed(p), 0.1*m.AccelerationFast*2; !near(got, want) {
+		t.Errorf("speed %v after 0.1s, want %v", got, want)
+	}
+	p.Step(3, env)
+	if got, want := horizontalSpeed(p), m.SpeedFast*0.5*1.5; !near(got, want) {
+		t.Errorf("top speed %v, want %v", got, want)
+	}
+}
+
+func TestFastClimb(t *testing.T) {
+	env := flatEnv{ladder: true}
+	m := DefaultMovementSettings()
+	for _, test := range []struct {
+		fast bool
+		top  float32
+	}{
+		{false, m.SpeedClimb},
+		{true, m.SpeedFast},
+	} {
+		p := standingPlayer(env, Controls{Jump: true, Aux1: true})
+		p.SetFastMove(test.fast)
+		p.Step(0.1, env)
+		// Climbing speeds up with the fast acceleration
+		accel := m.AccelerationDefault
+		if test.fast {
+			accel = m.AccelerationFast
+		}
+		if got := p.State().Velocity[1]; got <= 0 || got > 0.1*accel+1e-3 {
+			t.Errorf("fast %v: climbing at %v after 0.1s, want up to %v", test.fast, got, 0.1*accel)
+		}
+		p.Step(3, env)
+		if got := p.State().Velocity[1]; !near(got, test.top) {
+			t.Errorf("fast %v: climbing at %v, want %v", test.fast, got, test.top)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package player
+
+// MovementSettings are the movement parameters of a server, sent in TOCLIENT_MOVEMENT.
+// Speeds are in nodes per second and accelerations in nodes per second squared.
+type MovementSettings struct {
+	AccelerationDefault  float32
+	AccelerationAir      float32
+	AccelerationFast     float32
+	SpeedWalk            float32
+	SpeedCrouch          float32
+	SpeedFast            float32
+	SpeedClimb           float32
+	SpeedJump            float32
+	LiquidFluidity       float32
+	LiquidFluiditySmooth float32
+	LiquidSink           float32
+	Gravity              float32
+}
+
+// DefaultMovementSettings returns the movement settings a Minetest server uses unless configured otherwise
+func DefaultMovementSettings() MovementSettings {
+	return MovementSettings{
+		AccelerationDefault:  3,
+		AccelerationAir:      2,
+		AccelerationFast:     10,
+		SpeedWalk:            4,
+		SpeedCrouch:          1.35,
+		SpeedFast:            20,
+		SpeedClimb:           3,
+		SpeedJump:            6.5,
+		LiquidFluidity:       1,
+		LiquidFluiditySmooth: 0.5,
+		LiquidSink:           10,
+		Gravity:              9.81,
+	}
+}
+
+// PhysicsOverride holds the factors a server applies to the movement of one player with
+// set_physics_override. Newer servers send the factors after NewMove, older ones leave them at 1.
+type PhysicsOverride struct {
+	Speed       float32
+	Jump        float32
+	Gravity     float32
+	Sneak       bool // Sneaking keeps the player from falling off edges
+	SneakGlitch bool // Sneaking lets the player climb up ladders of nodes one node apart
+	NewMove     bool
+
+	SpeedClimb           float32
+	SpeedCrouch          float32
+	LiquidFluidity       float32
+	LiquidFluiditySmooth float32
+	LiquidSink           float32
+	AccelerationDefault  float32
+	AccelerationAir      float32
+	SpeedFast            float32
+	AccelerationFast     float32
+	SpeedWalk            float32
+}
+
+// DefaultPhysicsOverride returns the factors of a player without overrides
+func DefaultPhysicsOverride() PhysicsOverride {
+	return PhysicsOverride{
+		Speed:                1,
+		Jump:                 1,
+		Gravity:              1,
+		Sneak:                true,
+		SneakGlitch:          true,
+		NewMove:              true,
+		SpeedClimb:           1,
+		SpeedCrouch:          1,
+		LiquidFluidity:       1,
+		LiquidFluiditySmooth: 1,
+		LiquidSink:           1,
+		AccelerationDefault:  1,
+		AccelerationAir:      1,
+		SpeedFast:            1,
+		AccelerationFast:     1,
+		SpeedWalk:            1,
+	}
+}
+
+// Physics is what the movement simulation of the local player reads
+type Physics struct {
+	Movement MovementSettings
+	Override PhysicsOverride
+}
+
+// Effective returns the movement settings with the overrides of the player applied
+func (p Physics) Effective() MovementSettings {
+	m, o := p.Movement, p.Override
+	return MovementSettings{
+		AccelerationDefault:  m.AccelerationDefault * o.AccelerationDefault,
+		AccelerationAir:      m.AccelerationAir * o.AccelerationAir,
+		AccelerationFast:     m.AccelerationFast * o.AccelerationFast,
+		SpeedWalk:            m.SpeedWalk * o.SpeedWalk * o.Speed,
+		SpeedCrouch:          m.SpeedCrouch * o.SpeedCrouch * o.Speed,
+		SpeedFast:            m.SpeedFast * o.SpeedFast * o.Speed,
+		SpeedClimb:           m.SpeedClimb * o.SpeedClimb * o.Speed,
+		SpeedJump:            m.SpeedJump * o.Jump,
+		LiquidFluidity:       m.LiquidFluidity * o.LiquidFluidity,
+		LiquidFluiditySmooth: m.LiquidFluiditySmooth * o.LiquidFluiditySmooth,
+		LiquidSink:           m.LiquidSink * o.LiquidSink,
+		Gravity:              m.Gravity * o.Gravity,
+	}
+}
@@ -0,0 +1,90 @@
+package protocol
+
+import (
+	"sort"
+
+	"bettermt/main/network"
+)
+
+// ShowFormspec is TOCLIENT_SHOW_FORMSPEC, a formspec to show. An empty formspec closes the one of the form name.
+type ShowFormspec struct {
+	Formspec string
+	FormName string
+}
+
+func ReadShowFormspec(r *network.Reader) (ShowFormspec, error) {
+	s := ShowFormspec{Formspec: r.String32(), FormName: r.String16()}
+	return s, r.Err()
+}
+
+func (s ShowFormspec) Write() *network.Writer {
+	return network.NewWriter(network.ToClientShowFormspec).String32(s.Formspec).String16(s.FormName)
+}
+
+// ReadInventoryFormspec reads TOCLIENT_INVENTORY_FORMSPEC, the formspec the player's inventory key opens
+func ReadInventoryFormspec(r *network.Reader) (string, error) {
+	formspec := r.String32()
+	return formspec, r.Err()
+}
+
+func WriteInventoryFormspec(formspec string) *network.Writer {
+	return network.NewWriter(network.ToClientInventoryFormspec).String32(formspec)
+}
+
+// ReadFormspecPrepend reads TOCLIENT_FORMSPEC_PREPEND, the elements put in front of every formspec
+func ReadFormspecPrepend(r *network.Reader) (string, error) {
+	prepend := r.String16()
+	return prepend, r.Err()
+}
+
+func WriteFormspecPrepend(prepend string) *network.Writer {
+	return network.NewWriter(network.ToClientFormspecPrepend).String16(prepend)
+}
+
+// InventoryFields is TOSERVER_INVENTORY_FIELDS, the values of the fields of a formspec and the button that
+// submitted them. The inventory formspec has an empty form name.
+type InventoryFields struct {
+	FormName string
+	Fields   map[string]string
+}
+
+func ReadInventoryFields(r *network.Reader) (InventoryFields, error) {
+	f := InventoryFields{FormName: r.String16()}
+	count := r.U16()
+	f.Fields = make(map[string]string, count)
+	for i := 0; i < int(count) && r.Err() == nil; i++ {
+		name := r.String16()
+		f.Fields[name] = r.String32()
+	}
+	return f, r.Err()
+}
+
+// Write writes the fields sorted by name, so that the same fields always make the same packet
+func (f InventoryFields) Write() *network.Writer {
+	names := make([]string, 0, len(f.Fields))
+	for name := range f.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w := network.NewWriter(network.ToServerInventoryFields)
+	w.String16(f.FormName)
+	w.U16(uint16(len(names)))
+	for _, name := range names {
+		w.String16(name)
+		w.String32(f.Fields[name])
+	}
+	return w
+}
+
+// ReadInventoryAction returns the text form of the action sent in TOSERVER_INVENTORY_ACTION, which fills the
+// whole packet
+func ReadInventoryAction(r *network.Reader) (string, error) {
+	data := r.Remaining()
+	return string(data), r.Err()
+}
+
+// WriteInventoryAction writes TOSERVER_INVENTORY_ACTION, such as an inventory.Move
+func WriteInventoryAction(action string) *network.Writer {
+	return network.NewWriter(network.ToServerInventoryAction).Raw([]byte(action))
+}
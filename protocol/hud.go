@@ -0,0 +1,158 @@
+package protocol
+
+import (
+	"fmt"
+
+	"bettermt/main/hud"
+	"bettermt/main/network"
+)
+
+// ReadHudAdd reads TOCLIENT_HUDADD. The second text and the style are only sent by newer servers.
+func ReadHudAdd(r *network.Reader) (hud.Element, error) {
+	e := hud.Element{
+		ID:       r.U32(),
+		Type:     hud.ElementType(r.U8()),
+		Pos:      r.V2F32(),
+		Name:     r.String16(),
+		Scale:    r.V2F32(),
+		Text:     r.String16(),
+		Number:   r.U32(),
+		Item:     r.U32(),
+		Dir:      hud.Direction(r.U32()),
+		Align:    r.V2F32(),
+		Offset:   r.V2F32(),
+		WorldPos: r.V3F32(),
+		Size:     r.V2S32(),
+		ZIndex:   r.S16(),
+	}
+	if r.Err() == nil && r.Len() > 0 {
+		e.Text2 = r.String16()
+	}
+	if r.Err() == nil && r.Len() > 0 {
+		e.Style = r.U32()
+	}
+	return e, r.Err()
+}
+
+func WriteHudAdd(e hud.Element) *network.Writer {
+	w := network.NewWriter(network.ToClientHudAdd)
+	w.U32(e.ID)
+	w.U8(uint8(e.Type))
+	w.V2F32(e.Pos)
+	w.String16(e.Name)
+	w.V2F32(e.Scale)
+	w.String16(e.Text)
+	w.U32(e.Number)
+	w.U32(e.Item)
+	w.U32(uint32(e.Dir))
+	w.V2F32(e.Align)
+	w.V2F32(e.Offset)
+	w.V3F32(e.WorldPos)
+	w.V2S32(e.Size)
+	w.S16(e.ZIndex)
+	w.String16(e.Text2)
+	w.U32(e.Style)
+	return w
+}
+
+// ReadHudRm reads TOCLIENT_HUDRM, which holds the ID of the element to remove
+func ReadHudRm(r *network.Reader) (uint32, error) {
+	id := r.U32()
+	return id, r.Err()
+}
+
+func WriteHudRm(id uint32) *network.Writer {
+	w := network.NewWriter(network.ToClientHudRm)
+	w.U32(id)
+	return w
+}
+
+// ReadHudChange reads TOCLIENT_HUDCHANGE, whose value has the type of the stat it changes
+func ReadHudChange(r *network.Reader) (hud.Change, error) {
+	c := hud.Change{ID: r.U32(), Stat: hud.Stat(r.U8())}
+	switch c.Stat {
+	case hud.StatPos, hud.StatScale, hud.StatAlign, hud.StatOffset:
+		c.V2F = r.V2F32()
+	case hud.StatName, hud.StatText, hud.StatText2:
+		c.String = r.String16()
+	case hud.StatWorldPos:
+		c.V3F = r.V3F32()
+	case hud.StatSize:
+		c.V2S32 = r.V2S32()
+	default:
+		c.U32 = r.U32()
+	}
+	return c, r.Err()
+}
+
+func WriteHudChange(c hud.Change) *network.Writer {
+	w := network.NewWriter(network.ToClientHudChange)
+	w.U32(c.ID)
+	w.U8(uint8(c.Stat))
+	switch c.Stat {
+	case hud.StatPos, hud.StatScale, hud.StatAlign, hud.StatOffset:
+		w.V2F32(c.V2F)
+	case hud.StatName, hud.StatText, hud.StatText2:
+		w.String16(c.String)
+	case hud.StatWorldPos:
+		w.V3F32(c.V3F)
+	case hud.StatSize:
+		w.V2S32(c.V2S32)
+	default:
+		w.U32(c.U32)
+	}
+	return w
+}
+
+// HudSetFlags is TOCLIENT_HUD_SET_FLAGS, which changes the flags in Mask to the ones in Flags
+type HudSetFlags struct {
+	Flags hud.Flags
+	Mask  hud.Flags
+}
+
+func ReadHudSetFlags(r *network.Reader) (HudSetFlags, error) {
+	f := HudSetFlags{Flags: hud.Flags(r.U32()), Mask: hud.Flags(r.U32())}
+	return f, r.Err()
+}
+
+func (f HudSetFlags) Write() *network.Writer {
+	w := network.NewWriter(network.ToClientHudSetFlags)
+	w.U32(uint32(f.Flags))
+	w.U32(uint32(f.Mask))
+	return w
+}
+
+// HudSetParam is TOCLIENT_HUD_SET_PARAM. The hotbar item count is sent as a 32 bit integer inside the value.
+type HudSetParam struct {
+	Param hud.Param
+	Value string
+}
+
+func ReadHudSetParam(r *network.Reader) (HudSetParam, error) {
+	p := HudSetParam{Param: hud.Param(r.U16()), Value: r.String16()}
+	return p, r.Err()
+}
+
+func (p HudSetParam) Write() *network.Writer {
+	w := network.NewWriter(network.ToClientHudSetParam)
+	w.U16(uint16(p.Param))
+	w.String16(p.Value)
+	return w
+}
+
+// HotbarItemCount decodes the value of ParamHotbarItemCount
+func (p HudSetParam) HotbarItemCount() (int, error) {
+	r := network.NewReader([]byte(p.Value))
+	count := r.S32()
+	if r.Err() != nil {
+		return 0, fmt.Errorf("hotbar item count: %w", r.Err())
+	}
+	return int(count), nil
+}
+
+// HotbarItemCountParam creates the TOCLIENT_HUD_SET_PARAM changing the number of hotbar slots
+func HotbarItemCountParam(count int) HudSetParam {
+	var value network.Writer
+	value.S32(int32(count))
+	return HudSetParam{Param: hud.ParamHotbarItemCount, Value: string(value.Bytes())}
+}
@@ -0,0 +1,151 @@
+package protocol
+
+import (
+	"reflect"
+	"testing"
+
+	"bettermt/main/hud"
+	"bettermt/main/network"
+)
+
+func TestHudAddRoundTrip(t *testing.T) {
+	for _, e := range []hud.Element{
+		{ID: 1, Type: hud.TypeText, Pos: [2]float32{0.5, 0.1}, Name: "title", Scale: [2]float32{100, 20}, Text: "Hello",
+			Number: 0xFFFFFF, Align: [2]float32{0, 1}, Offset: [2]float32{0, 8}, ZIndex: -3, Text2: "", Style: hud.StyleBold},
+		{ID: 42, Type: hud.TypeStatbar, Pos: [2]float32{0.5, 1}, Text: "heart.png", Text2: "heart_gone.png", Number: 20, Item: 20,
+			Dir: hud.DirRightLeft, Size: [2]int32{24, 24}, Offset: [2]float32{-265, -88}},
+		{ID: 7, Type: hud.TypeWaypoint, Name: "Home", Text: "m", Number: 0x00FF00, WorldPos: [3]float32{10.5, -20, 300}, ZIndex: 1000},
+		{ID: 0xFFFFFFFF, Type: hud.TypeCompass, Dir: hud.CompassTranslateReverse, Size: [2]int32{-50, 10}, ZIndex: -1000},
+	} {
+		got, err := ReadHudAdd(body(t, WriteHudAdd(e), network.ToClientHudAdd))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != e {
+			t.Errorf("got %+v, want %+v", got, e)
+		}
+	}
+}
+
+func TestHudAddOlderServers(t *testing.T) {
+	e := hud.Element{ID: 3, Type: hud.TypeText, Text: "hi", Text2: "ignored", Style: hud.StyleMono}
+	data := WriteHudAdd(e).Bytes()
+	// Older servers stop after the z-index, or after the second text
+	style := len(data) - 4
+	text2 := style - 2 - len(e.Text2)
+	for _, test := range []struct {
+		length int
+		text2  string
+		style  uint32
+	}{
+		{text2, "", 0},
+		{style, "ignored", 0},
+		{len(data), "ignored", hud.StyleMono},
+	} {
+		r := network.NewReader(data[2:test.length])
+		got, err := ReadHudAdd(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Text != "hi" || got.Text2 != test.text2 || got.Style != test.style {
+			t.Errorf("%d bytes: %+v", test.length, got)
+		}
+	}
+	if _, err := ReadHudAdd(network.NewReader(data[2:20])); err == nil {
+		t.Error("no error for a truncated element")
+	}
+}
+
+func TestHudRmRoundTrip(t *testing.T) {
+	id, err := ReadHudRm(body(t, WriteHudRm(123456), network.ToClientHudRm))
+	if err != nil || id != 123456 {
+		t.Fatalf("got %d, %v", id, err)
+	}
+}
+
+func TestHudChangeRoundTrip(t *testing.T) {
+	// Each stat carries its value with its own type
+	for _, c := range []hud.Change{
+		{ID: 1, Stat: hud.StatPos, V2F: [2]float32{0.25, 0.75}},
+		{ID: 1, Stat: hud.StatName, String: "name"},
+		{ID: 1, Stat: hud.StatScale, V2F: [2]float32{-100, 2}},
+		{ID: 1, Stat: hud.StatText, String: "text ☃"},
+		{ID: 1, Stat: hud.StatNumber, U32: 0xABCDEF},
+		{ID: 1, Stat: hud.StatItem, U32: 3},
+		{ID: 1, Stat: hud.StatDir, U32: uint32(hud.DirBottomTop)},
+		{ID: 1, Stat: hud.StatAlign, V2F: [2]float32{-1, 0}},
+		{ID: 1, Stat: hud.StatOffset, V2F: [2]float32{5, -5}},
+		{ID: 2, Stat: hud.StatWorldPos, V3F: [3]float32{1.5, -2.5, 3}},
+		{ID: 2, Stat: hud.StatSize, V2S32: [2]int32{-100, 64}},
+		{ID: 2, Stat: hud.StatZIndex, U32: uint32(0xFFFFFFFF)},
+		{ID: 2, Stat: hud.StatText2, String: "second"},
+		{ID: 2, Stat: hud.StatStyle, U32: hud.StyleItalic},
+	} {
+		w := WriteHudChange(c)
+		got, err := ReadHudChange(body(t, w, network.ToClientHudChange))
+		if err != nil {
+			t.Fatalf("%v: %v", c.Stat, err)
+		}
+		if got != c {
+			t.Errorf("%v: got %+v, want %+v", c.Stat, got, c)
+		}
+	}
+}
+
+func TestHudChangeSizes(t *testing.T) {
+	// The header is the ID and the stat, then the value takes as many bytes as its type
+	for stat, size := range map[hud.Stat]int{
+		hud.StatPos:      8,
+		hud.StatText:     2 + 3,
+		hud.StatWorldPos: 12,
+		hud.StatSize:     8,
+		hud.StatNumber:   4,
+	} {
+		c := hud.Change{Stat: stat, String: "abc"}
+		if got := len(WriteHudChange(c).Bytes()) - 2 - 5; got != size {
+			t.Errorf("%v: %d value bytes, want %d", stat, got, size)
+		}
+	}
+}
+
+func TestHudSetFlagsRoundTrip(t *testing.T) {
+	f := HudSetFlags{Flags: hud.FlagHotbar | hud.FlagChat, Mask: hud.FlagHotbar | hud.FlagCrosshair | hud.FlagChat}
+	got, err := ReadHudSetFlags(body(t, f.Write(), network.ToClientHudSetFlags))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != f {
+		t.Fatalf("got %+v, want %+v", got, f)
+	}
+}
+
+func TestHudSetParamRoundTrip(t *testing.T) {
+	for _, p := range []HudSetParam{
+		HotbarItemCountParam(16),
+		{Param: hud.ParamHotbarImage, Value: "gui_hotbar.png"},
+		{Param: hud.ParamHotbarSelectedImage, Value: "gui_hotbar_selected.png"},
+	} {
+		got, err := ReadHudSetParam(body(t, p.Write(), network.ToClientHudSetParam))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, p) {
+			t.Errorf("got %+v, want %+v", got, p)
+		}
+	}
+}
+
+func TestHotbarItemCount(t *testing.T) {
+	for _, count := range []int{1, 8, 32, 0, -1, 1000} {
+		p := HotbarItemCountParam(count)
+		if len(p.Value) != 4 {
+			t.Fatalf("count %d sent in %d bytes, want 4", count, len(p.Value))
+		}
+		if got, err := p.HotbarItemCount(); err != nil || got != count {
+			t.Errorf("count %d: got %d, %v", count, got, err)
+		}
+	}
+	if _, err := (HudSetParam{Param: hud.ParamHotbarItemCount, Value: "\x00\x08"}).HotbarItemCount(); err == nil {
+		t.Error("no error for a short item count")
+	}
+}
@@ -0,0 +1,140 @@
+package protocol
+
+import (
+	"fmt"
+
+	"bettermt/main/network"
+)
+
+// Version of the serialized pointed thing
+const pointedThingVersion = 0
+
+// InteractAction is what the player does to the thing it points at
+type InteractAction uint8
+
+const (
+	InteractStartDigging InteractAction = iota
+	InteractStopDigging
+	InteractDiggingCompleted
+	InteractPlace // Right click, placing the wielded item or using the pointed node
+	InteractUse   // Left click with an item that has on_use
+	InteractActivate
+)
+
+func (a InteractAction) String() string {
+	switch a {
+	case InteractStartDigging:
+		return "start digging"
+	case InteractStopDigging:
+		return "stop digging"
+	case InteractDiggingCompleted:
+		return "digging completed"
+	case InteractPlace:
+		return "place"
+	case InteractUse:
+		return "use"
+	case InteractActivate:
+		return "activate"
+	}
+	return fmt.Sprintf("unknown(%d)", uint8(a))
+}
+
+// PointedThingType tells what the player points at
+type PointedThingType uint8
+
+const (
+	PointedNothing PointedThingType = iota
+	PointedNode
+	PointedObject
+)
+
+// PointedThing is the node face or object the player points at
+type PointedThing struct {
+	Type     PointedThingType
+	Under    [3]int16 // Node pointed at
+	Above    [3]int16 // Node in front of the pointed face, where an item is placed
+	ObjectID uint16
+}
+
+func (pt PointedThing) String() string {
+	switch pt.Type {
+	case PointedNothing:
+		return "nothing"
+	case PointedNode:
+		return fmt.Sprintf("node (%d, %d, %d) above (%d, %d, %d)",
+			pt.Under[0], pt.Under[1], pt.Under[2], pt.Above[0], pt.Above[1], pt.Above[2])
+	case PointedObject:
+		return fmt.Sprintf("object %d", pt.ObjectID)
+	}
+	return fmt.Sprintf("unknown(%d)", uint8(pt.Type))
+}
+
+func ReadPointedThing(r *network.Reader) (PointedThing, error) {
+	if version := r.U8(); r.Err() == nil && version != pointedThingVersion {
+		return PointedThing{}, fmt.Errorf("unsupported pointed thing version %d", version)
+	}
+	pt := PointedThing{Type: PointedThingType(r.U8())}
+	switch pt.Type {
+	case PointedNothing:
+	case PointedNode:
+		pt.Under = r.V3S16()
+		pt.Above = r.V3S16()
+	case PointedObject:
+		pt.ObjectID = r.U16()
+	default:
+		if r.Err() == nil {
+			return pt, fmt.Errorf("unknown pointed thing type %d", pt.Type)
+		}
+	}
+	return pt, r.Err()
+}
+
+func (pt PointedThing) write(w *network.Writer) {
+	w.U8(pointedThingVersion)
+	w.U8(uint8(pt.Type))
+	switch pt.Type {
+	case PointedNode:
+		w.V3S16(pt.Under)
+		w.V3S16(pt.Above)
+	case PointedObject:
+		w.U16(pt.ObjectID)
+	}
+}
+
+// Interact is TOSERVER_INTERACT, which digs, places and uses things. It carries the state of the player
+// as in TOSERVER_PLAYERPOS so the server checks the action against an up to date position.
+type Interact struct {
+	Action    InteractAction
+	ItemIndex uint16 // Hotbar slot wielded
+	Pointed   PointedThing
+	Player    PlayerPos
+}
+
+func ReadInteract(r *network.Reader) (Interact, error) {
+	i := Interact{
+		Action:    InteractAction(r.U8()),
+		ItemIndex: r.U16(),
+	}
+	pointed := network.NewReader([]byte(r.String32()))
+	if r.Err() != nil {
+		return i, r.Err()
+	}
+	var err error
+	if i.Pointed, err = ReadPointedThing(pointed); err != nil {
+		return i, fmt.Errorf("pointed thing: %w", err)
+	}
+	i.Player, err = readPlayerPosFields(r)
+	return i, err
+}
+
+func (i Interact) Write() *network.Writer {
+	var pointed network.Writer
+	i.Pointed.write(&pointed)
+
+	w := network.NewWriter(network.ToServerInteract)
+	w.U8(uint8(i.Action))
+	w.U16(i.ItemIndex)
+	w.String32(string(pointed.Bytes()))
+	i.Player.writeFields(w)
+	return w
+}
@@ -0,0 +1,450 @@
+package protocol
+
+import (
+	"fmt"
+	"image/color"
+	"sort"
+
+	"bettermt/main/network"
+	"bettermt/main/object"
+	"bettermt/main/player"
+)
+
+// Type of the active objects sent by current servers, whose behaviour is described by their properties
+const ObjectTypeGeneric = uint8(7)
+
+// Version of the generic active object init data
+const genericInitVersion = 1
+
+// Commands of active object messages
+const (
+	AOCmdSetProperties = uint8(iota)
+	AOCmdUpdatePosition
+	AOCmdSetTextureMod
+	AOCmdSetSprite
+	AOCmdPunched
+	AOCmdUpdateArmorGroups
+	AOCmdSetAnimation
+	AOCmdSetBonePosition
+	AOCmdAttachTo
+	AOCmdSetPhysicsOverride
+	AOCmdObsolete1
+	AOCmdSpawnInfant
+	AOCmdSetAnimationSpeed
+)
+
+// AddedObject is one active object that came into range of the player
+type AddedObject struct {
+	ID       uint16
+	Type     uint8
+	InitData []byte
+}
+
+// ActiveObjectRemoveAdd is TOCLIENT_ACTIVE_OBJECT_REMOVE_ADD, the objects that left and came into range
+type ActiveObjectRemoveAdd struct {
+	Removed []uint16
+	Added   []AddedObject
+}
+
+func ReadActiveObjectRemoveAdd(r *network.Reader) (ActiveObjectRemoveAdd, error) {
+	var a ActiveObjectRemoveAdd
+	removed := int(r.U16())
+	for i := 0; i < removed && r.Err() == nil; i++ {
+		a.Removed = append(a.Removed, r.U16())
+	}
+	added := int(r.U16())
+	for i := 0; i < added && r.Err() == nil; i++ {
+		o := AddedObject{ID: r.U16(), Type: r.U8(), InitData: []byte(r.String32())}
+		if r.Err() == nil {
+			a.Added = append(a.Added, o)
+		}
+	}
+	return a, r.Err()
+}
+
+// ActiveObjectMessage is one message to an active object in TOCLIENT_ACTIVE_OBJECT_MESSAGES.
+// Its data starts with one of the AOCmd commands.
+type ActiveObjectMessage struct {
+	ID   uint16
+	Data []byte
+}
+
+// ReadActiveObjectMessages decodes TOCLIENT_ACTIVE_OBJECT_MESSAGES, which packs messages until the packet ends
+func ReadActiveObjectMessages(r *network.Reader) ([]ActiveObjectMessage, error) {
+	var messages []ActiveObjectMessage
+	for r.Len() > 0 && r.Err() == nil {
+		m := ActiveObjectMessage{ID: r.U16(), Data: []byte(r.String16())}
+		if r.Err() == nil {
+			messages = append(messages, m)
+		}
+	}
+	return messages, r.Err()
+}
+
+// GenericInit is the init data of a generic active object
+type GenericInit struct {
+	Name     string
+	IsPlayer bool
+	ID       uint16
+	Position [3]float32 // In protocol units
+	Rotation [3]float32 // In degrees
+	HP       uint16
+	Messages [][]byte // Applied as if they were sent in TOCLIENT_ACTIVE_OBJECT_MESSAGES
+}
+
+func ReadGenericInit(data []byte) (GenericInit, error) {
+	r := network.NewReader(data)
+	if version := r.U8(); r.Err() == nil && version != genericInitVersion {
+		return GenericInit{}, fmt.Errorf("unsupported generic object init version %d", version)
+	}
+	g := GenericInit{
+		Name:     r.String16(),
+		IsPlayer: r.U8() != 0,
+		ID:       r.U16(),
+		Position: r.V3F32(),
+		Rotation: r.V3F32(),
+		HP:       r.U16(),
+	}
+	count := int(r.U8())
+	for i := 0; i < count && r.Err() == nil; i++ {
+		if m := r.String32(); r.Err() == nil {
+			g.Messages = append(g.Messages, []byte(m))
+		}
+	}
+	return g, r.Err()
+}
+
+// ReadPhysicsOverride decodes the data of an AOCmdSetPhysicsOverride message after the command.
+// Factors added in later versions are left at 1 when the server does not send them.
+func ReadPhysicsOverride(r *network.Reader) (player.PhysicsOverride, error) {
+	o := player.DefaultPhysicsOverride()
+	o.Speed = r.F32()
+	o.Jump = r.F32()
+	o.Gravity = r.F32()
+	// Sent negated for compatibility with older clients
+	o.Sneak = r.U8() == 0
+	o.SneakGlitch = r.U8() == 0
+	o.NewMove = r.U8() == 0
+	if r.Err() != nil || r.Len() == 0 {
+		return o, r.Err()
+	}
+
+	for _, f := range []*float32{
+		&o.SpeedClimb, &o.SpeedCrouch, &o.LiquidFluidity, &o.LiquidFluiditySmooth,
+		&o.LiquidSink, &o.AccelerationDefault, &o.AccelerationAir,
+	} {
+		*f = r.F32()
+	}
+	if r.Err() != nil || r.Len() == 0 {
+		return o, r.Err()
+	}
+	o.SpeedFast = r.F32()
+	o.AccelerationFast = r.F32()
+	o.SpeedWalk = r.F32()
+	return o, r.Err()
+}
+
+// WritePhysicsOverride encodes an AOCmdSetPhysicsOverride message, command included
+func WritePhysicsOverride(o player.PhysicsOverride) []byte {
+	w := &network.Writer{}
+	w.U8(AOCmdSetPhysicsOverride)
+	w.F32(o.Speed).F32(o.Jump).F32(o.Gravity)
+	w.Bool(!o.Sneak).Bool(!o.SneakGlitch).Bool(!o.NewMove)
+	for _, f := range []float32{
+		o.SpeedClimb, o.SpeedCrouch, o.LiquidFluidity, o.LiquidFluiditySmooth,
+		o.LiquidSink, o.AccelerationDefault, o.AccelerationAir,
+		o.SpeedFast, o.AccelerationFast, o.SpeedWalk,
+	} {
+		w.F32(f)
+	}
+	return w.Bytes()
+}
+
+// Version of the object properties layout
+const objectPropertiesVersion = 4
+
+// Background color servers send for nametags without one
+var nullNametagBgColor = color.NRGBA{R: 1, G: 1, B: 1, A: 0}
+
+// ReadObjectMessage decodes the data of an active object message. Messages that do not change how the object
+// looks or moves, such as physics overrides, decode to nil.
+func ReadObjectMessage(data []byte) (object.Message, error) {
+	r := network.NewReader(data)
+	cmd := r.U8()
+	if r.Err() != nil {
+		return nil, r.Err()
+	}
+	switch cmd {
+	case AOCmdSetProperties:
+		p, err := ReadObjectProperties(r)
+		if err != nil {
+			return nil, fmt.Errorf("object properties: %w", err)
+		}
+		return object.SetProperties{Properties: p}, nil
+	case AOCmdUpdatePosition:
+		u := object.UpdatePosition{
+			Position:     r.V3F32(),
+			Velocity:     r.V3F32(),
+			Acceleration: r.V3F32(),
+			Rotation:     r.V3F32(),
+			Interpolate:  r.Bool(),
+			IsEnd:        r.Bool(),
+			Interval:     r.F32(),
+		}
+		return u, r.Err()
+	case AOCmdSetTextureMod:
+		m := object.SetTextureMod{Mod: r.String16()}
+		return m, r.Err()
+	case AOCmdSetSprite:
+		s := object.Sprite{
+			BasePos:          readV2S16(r),
+			Frames:           r.U16(),
+			FrameLength:      r.F32(),
+			SelectByYawPitch: r.Bool(),
+		}
+		return object.SetSprite{Sprite: s}, r.Err()
+	case AOCmdPunched:
+		m := object.Punched{HP: r.U16()}
+		return m, r.Err()
+	case AOCmdUpdateArmorGroups:
+		count := int(r.U16())
+		groups := make(map[string]int16, count)
+		for i := 0; i < count && r.Err() == nil; i++ {
+			name := r.String16()
+			groups[name] = r.S16()
+		}
+		return object.UpdateArmorGroups{Groups: groups}, r.Err()
+	case AOCmdSetAnimation:
+		a := object.Animation{Frames: r.V2F32(), Speed: r.F32(), Blend: r.F32(), Loop: true}
+		if r.Err() == nil && r.Len() > 0 {
+			a.Loop = r.Bool()
+		}
+		return object.SetAnimation{Animation: a}, r.Err()
+	case AOCmdSetAnimationSpeed:
+		m := object.SetAnimationSpeed{Speed: r.F32()}
+		return m, r.Err()
+	case AOCmdSetBonePosition:
+		return readSetBone(r)
+	case AOCmdAttachTo:
+		a := object.Attachment{
+			ParentID: uint16(max(0, r.S16())),
+			Bone:     r.String16(),
+			Position: r.V3F32(),
+			Rotation: r.V3F32(),
+		}
+		if r.Err() == nil && r.Len() > 0 {
+			a.ForceVisible = r.Bool()
+		}
+		return object.AttachTo{Attachment: a}, r.Err()
+	}
+	return nil, nil
+}
+
+// WriteObjectMessage encodes an active object message, command included. Physics overrides, which do not decode
+// to a message, are encoded by WritePhysicsOverride.
+func WriteObjectMessage(msg object.Message) []byte {
+	w := &network.Writer{}
+	switch m := msg.(type) {
+	case object.SetProperties:
+		w.U8(AOCmdSetProperties)
+		writeObjectProperties(w, m.Properties)
+	case object.UpdatePosition:
+		w.U8(AOCmdUpdatePosition)
+		w.V3F32(m.Position).V3F32(m.Velocity).V3F32(m.Acceleration).V3F32(m.Rotation)
+		w.Bool(m.Interpolate).Bool(m.IsEnd).F32(m.Interval)
+	case object.SetTextureMod:
+		w.U8(AOCmdSetTextureMod).String16(m.Mod)
+	case object.SetSprite:
+		w.U8(AOCmdSetSprite)
+		writeV2S16(w, m.Sprite.BasePos)
+		w.U16(m.Sprite.Frames).F32(m.Sprite.FrameLength).Bool(m.Sprite.SelectByYawPitch)
+	case object.Punched:
+		w.U8(AOCmdPunched).U16(m.HP)
+	case object.UpdateArmorGroups:
+		w.U8(AOCmdUpdateArmorGroups).U16(uint16(len(m.Groups)))
+		names := make([]string, 0, len(m.Groups))
+		for name := range m.Groups {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			w.String16(name).S16(m.Groups[name])
+		}
+	case object.SetAnimation:
+		a := m.Animation
+		w.U8(AOCmdSetAnimation).V2F32(a.Frames).F32(a.Speed).F32(a.Blend).Bool(a.Loop)
+	case object.SetAnimationSpeed:
+		w.U8(AOCmdSetAnimationSpeed).F32(m.Speed)
+	case object.SetBone:
+		o := m.Override
+		w.U8(AOCmdSetBonePosition).String16(m.Bone)
+		w.V3F32(o.Position).V3F32(o.Rotation).V3F32(o.Scale)
+		w.F32(o.Interpolation[0]).F32(o.Interpolation[1]).F32(o.Interpolation[2])
+		var absolute uint8
+		for i, set := range []bool{o.AbsolutePosition, o.AbsoluteRotation, o.AbsoluteScale} {
+			if set {
+				absolute |= 1 << i
+			}
+		}
+		w.U8(absolute)
+	case object.AttachTo:
+		a := m.Attachment
+		w.U8(AOCmdAttachTo).S16(int16(a.ParentID)).String16(a.Bone)
+		w.V3F32(a.Position).V3F32(a.Rotation).Bool(a.ForceVisible)
+	}
+	return w.Bytes()
+}
+
+// readSetBone reads an AOCmdSetBonePosition message. Servers that only send a position and a rotation
+// replace the pose of the bone.
+func readSetBone(r *network.Reader) (object.Message, error) {
+	m := object.SetBone{Bone: r.String16()}
+	m.Override = object.BoneOverride{
+		Position:         r.V3F32(),
+		Rotation:         r.V3F32(),
+		Scale:            [3]float32{1, 1, 1},
+		AbsolutePosition: true,
+		AbsoluteRotation: true,
+	}
+	if r.Err() != nil || r.Len() == 0 {
+		return m, r.Err()
+	}
+	m.Override.Scale = r.V3F32()
+	m.Override.Interpolation = [3]float32{r.F32(), r.F32(), r.F32()}
+	absolute := r.U8()
+	m.Override.AbsolutePosition = absolute&1 != 0
+	m.Override.AbsoluteRotation = absolute&2 != 0
+	m.Override.AbsoluteScale = absolute&4 != 0
+	return m, r.Err()
+}
+
+// ReadObjectProperties decodes the data of an AOCmdSetProperties message after the command. Properties added
+// in later versions keep their defaults when the server does not send them.
+func ReadObjectProperties(r *network.Reader) (object.Properties, error) {
+	p := object.DefaultProperties()
+	if version := r.U8(); r.Err() == nil && version != objectPropertiesVersion {
+		return p, fmt.Errorf("unsupported object properties version %d", version)
+	}
+	p.HPMax = r.U16()
+	p.Physical = r.Bool()
+	r.U32() // Weight, no longer used
+	p.CollisionBox = object.Box{Min: r.V3F32(), Max: r.V3F32()}
+	p.SelectionBox = object.Box{Min: r.V3F32(), Max: r.V3F32()}
+	p.Pointable = r.Bool()
+	p.Visual = r.String16()
+	p.VisualSize = r.V3F32()
+	p.Textures = nil
+	count := int(r.U16())
+	for i := 0; i < count && r.Err() == nil; i++ {
+		p.Textures = append(p.Textures, r.String16())
+	}
+	p.SpriteDiv = readV2S16(r)
+	p.InitialSpritePos = readV2S16(r)
+	p.IsVisible = r.Bool()
+	p.MakesFootstepSound = r.Bool()
+	p.AutomaticRotate = r.F32()
+	p.Mesh = r.String16()
+	p.Colors = nil
+	count = int(r.U16())
+	for i := 0; i < count && r.Err() == nil; i++ {
+		p.Colors = append(p.Colors, readARGB(r))
+	}
+	p.CollideWithObjects = r.Bool()
+	p.StepHeight = r.F32()
+	p.AutomaticFaceMovementDir = r.Bool()
+	p.AutomaticFaceMovementDirOffset = r.F32()
+	p.BackfaceCulling = r.Bool()
+	p.Nametag = r.String16()
+	p.NametagColor = readARGB(r)
+	p.AutomaticFaceMovementMaxRotate = r.F32()
+	p.Infotext = r.String16()
+	p.WieldItem = r.String16()
+	p.Glow = int8(r.U8())
+	p.BreathMax = r.U16()
+	p.EyeHeight = r.F32()
+	p.ZoomFOV = r.F32()
+	p.UseTextureAlpha = r.Bool()
+	if r.Err() != nil || r.Len() == 0 {
+		return p, r.Err()
+	}
+
+	p.DamageTextureModifier = r.String16()
+	if r.Err() != nil || r.Len() == 0 {
+		return p, r.Err()
+	}
+	p.Shaded = r.Bool()
+	if r.Err() != nil || r.Len() == 0 {
+		return p, r.Err()
+	}
+	p.ShowOnMinimap = r.Bool()
+	if r.Err() != nil || r.Len() == 0 {
+		return p, r.Err()
+	}
+	if bg := readARGB(r); bg != nullNametagBgColor {
+		p.NametagBgColor, p.HasNametagBgColor = bg, true
+	}
+	if r.Err() != nil || r.Len() == 0 {
+		return p, r.Err()
+	}
+	p.RotateSelectionBox = r.Bool()
+	return p, r.Err()
+}
+
+// writeObjectProperties encodes the data of an AOCmdSetProperties message after the command, in the newest layout
+func writeObjectProperties(w *network.Writer, p object.Properties) {
+	w.U8(objectPropertiesVersion)
+	w.U16(p.HPMax)
+	w.Bool(p.Physical)
+	w.U32(0) // Weight
+	w.V3F32(p.CollisionBox.Min).V3F32(p.CollisionBox.Max)
+	w.V3F32(p.SelectionBox.Min).V3F32(p.SelectionBox.Max)
+	w.Bool(p.Pointable)
+	w.String16(p.Visual)
+	w.V3F32(p.VisualSize)
+	w.U16(uint16(len(p.Textures)))
+	for _, texture := range p.Textures {
+		w.String16(texture)
+	}
+	writeV2S16(w, p.SpriteDiv)
+	writeV2S16(w, p.InitialSpritePos)
+	w.Bool(p.IsVisible)
+	w.Bool(p.MakesFootstepSound)
+	w.F32(p.AutomaticRotate)
+	w.String16(p.Mesh)
+	w.U16(uint16(len(p.Colors)))
+	for _, c := range p.Colors {
+		writeARGB(w, c)
+	}
+	w.Bool(p.CollideWithObjects)
+	w.F32(p.StepHeight)
+	w.Bool(p.AutomaticFaceMovementDir)
+	w.F32(p.AutomaticFaceMovementDirOffset)
+	w.Bool(p.BackfaceCulling)
+	w.String16(p.Nametag)
+	writeARGB(w, p.NametagColor)
+	w.F32(p.AutomaticFaceMovementMaxRotate)
+	w.String16(p.Infotext)
+	w.String16(p.WieldItem)
+	w.U8(uint8(p.Glow))
+	w.U16(p.BreathMax)
+	w.F32(p.EyeHeight)
+	w.F32(p.ZoomFOV)
+	w.Bool(p.UseTextureAlpha)
+	w.String16(p.DamageTextureModifier)
+	w.Bool(p.Shaded)
+	w.Bool(p.ShowOnMinimap)
+	if p.HasNametagBgColor {
+		writeARGB(w, p.NametagBgColor)
+	} else {
+		writeARGB(w, nullNametagBgColor)
+	}
+	w.Bool(p.RotateSelectionBox)
+}
+
+func writeV2S16(w *network.Writer, v [2]int16) {
+	w.S16(v[0]).S16(v[1])
+}
+
+func readV2S16(r *network.Reader) [2]int16 {
+	return [2]int16{r.S16(), r.S16()}
+}
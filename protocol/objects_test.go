@@ -0,0 +1,245 @@
+package protocol
+
+import (
+	"image/color"
+	"reflect"
+	"testing"
+
+	"bettermt/main/network"
+	"bettermt/main/object"
+	"bettermt/main/player"
+)
+
+// readPhysicsOverride decodes an AOCmdSetPhysicsOverride message, command included
+func readPhysicsOverride(t *testing.T, data []byte) (player.PhysicsOverride, error) {
+	t.Helper()
+	r := network.NewReader(data)
+	if cmd := r.U8(); cmd != AOCmdSetPhysicsOverride {
+		t.Fatalf("command %d, want %d", cmd, AOCmdSetPhysicsOverride)
+	}
+	return ReadPhysicsOverride(r)
+}
+
+func TestPhysicsOverrideRoundTrip(t *testing.T) {
+	o := player.PhysicsOverride{
+		Speed: 1.5, Jump: 2, Gravity: 0.5,
+		Sneak: false, SneakGlitch: true, NewMove: false,
+		SpeedClimb: 2, SpeedCrouch: 3, LiquidFluidity: 0.5, LiquidFluiditySmooth: 0.25,
+		LiquidSink: 4, AccelerationDefault: 5, AccelerationAir: 6,
+		SpeedFast: 7, AccelerationFast: 8, SpeedWalk: 9,
+	}
+	for _, flags := range [][3]bool{{false, true, false}, {true, false, true}, {true, true, true}, {false, false, false}} {
+		o.Sneak, o.SneakGlitch, o.NewMove = flags[0], flags[1], flags[2]
+		got, err := readPhysicsOverride(t, WritePhysicsOverride(o))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != o {
+			t.Fatalf("got %+v, want %+v", got, o)
+		}
+	}
+}
+
+func TestPhysicsOverrideFlagsNegated(t *testing.T) {
+	// Servers send the flags negated, so that a zero byte keeps the default of older clients
+	data := WritePhysicsOverride(player.DefaultPhysicsOverride())
+	if flags := data[13:16]; flags[0] != 0 || flags[1] != 0 || flags[2] != 0 {
+		t.Fatalf("default flags sent as %v, want zeros", flags)
+	}
+}
+
+func TestPhysicsOverrideOlderServers(t *testing.T) {
+	o := player.DefaultPhysicsOverride()
+	o.Speed, o.SneakGlitch = 2, false
+	o.SpeedClimb, o.AccelerationAir, o.SpeedFast, o.SpeedWalk = 3, 4, 5, 6
+	data := WritePhysicsOverride(o)
+
+	// Servers before 5.8 stop after the flags, and before 5.9 after the air acceleration
+	for _, test := range []struct {
+		name   string
+		length int
+		want   func(o *player.PhysicsOverride)
+	}{
+		{"5.7", 16, func(o *player.PhysicsOverride) {
+			o.SpeedClimb, o.AccelerationAir, o.SpeedFast, o.SpeedWalk = 1, 1, 1, 1
+		}},
+		{"5.8", 16 + 7*4, func(o *player.PhysicsOverride) { o.SpeedFast, o.SpeedWalk = 1, 1 }},
+		{"5.9", len(data), func(o *player.PhysicsOverride) {}},
+	} {
+		got, err := readPhysicsOverride(t, data[:test.length])
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		want := o
+		test.want(&want)
+		if got != want {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, want)
+		}
+	}
+}
+
+// testProperties returns properties with every field away from its default
+func testProperties() object.Properties {
+	return object.Properties{
+		HPMax:                          20,
+		Physical:                       true,
+		CollisionBox:                   object.Box{Min: [3]float32{-0.3, 0, -0.3}, Max: [3]float32{0.3, 1.7, 0.3}},
+		SelectionBox:                   object.Box{Min: [3]float32{-0.4, 0, -0.4}, Max: [3]float32{0.4, 1.8, 0.4}},
+		Visual:                         object.VisualMesh,
+		VisualSize:                     [3]float32{1, 2, 1},
+		Textures:                       []string{"character.png", "armor.png^[colorize:red"},
+		SpriteDiv:                      [2]int16{4, 2},
+		InitialSpritePos:               [2]int16{1, -1},
+		MakesFootstepSound:             true,
+		AutomaticRotate:                1.5,
+		Mesh:                           "character.b3d",
+		Colors:                         []color.NRGBA{{R: 1, G: 2, B: 3, A: 4}, {R: 255, A: 255}},
+		StepHeight:                     0.6,
+		AutomaticFaceMovementDir:       true,
+		AutomaticFaceMovementDirOffset: -90,
+		Nametag:                        "Alice ☃",
+		NametagColor:                   color.NRGBA{R: 200, G: 100, B: 50, A: 128},
+		NametagBgColor:                 color.NRGBA{A: 100},
+		HasNametagBgColor:              true,
+		AutomaticFaceMovementMaxRotate: 360,
+		Infotext:                       "A player",
+		WieldItem:                      "default:pick_mese",
+		Glow:                           -3,
+		BreathMax:                      11,
+		EyeHeight:                      1.47,
+		ZoomFOV:                        15,
+		UseTextureAlpha:                true,
+		DamageTextureModifier:          "^[colorize:red:128",
+		ShowOnMinimap:                  true,
+		RotateSelectionBox:             true,
+	}
+}
+
+func TestObjectMessageRoundTrip(t *testing.T) {
+	defaults := object.DefaultProperties()
+	for _, test := range []struct {
+		name    string
+		command uint8
+		msg     object.Message
+	}{
+		{"properties", AOCmdSetProperties, object.SetProperties{Properties: testProperties()}},
+		{"default properties", AOCmdSetProperties, object.SetProperties{Properties: defaults}},
+		{"update position", AOCmdUpdatePosition, object.UpdatePosition{
+			Position: [3]float32{10, -20, 305}, Velocity: [3]float32{1, 0, -1}, Acceleration: [3]float32{0, -98.1, 0},
+			Rotation: [3]float32{0, 270, 5}, Interpolate: true, IsEnd: true, Interval: 0.2,
+		}},
+		{"texture mod", AOCmdSetTextureMod, object.SetTextureMod{Mod: "^[brighten"}},
+		{"sprite", AOCmdSetSprite, object.SetSprite{Sprite: object.Sprite{
+			BasePos: [2]int16{0, 3}, Frames: 4, FrameLength: 0.25, SelectByYawPitch: true,
+		}}},
+		{"punched", AOCmdPunched, object.Punched{HP: 7}},
+		{"armor groups", AOCmdUpdateArmorGroups, object.UpdateArmorGroups{Groups: map[string]int16{
+			"fleshy": 100, "immortal": 1, "punch_operable": -5,
+		}}},
+		{"no armor groups", AOCmdUpdateArmorGroups, object.UpdateArmorGroups{Groups: map[string]int16{}}},
+		{"animation", AOCmdSetAnimation, object.SetAnimation{Animation: object.Animation{
+			Frames: [2]float32{168, 187}, Speed: 30, Blend: 0.5, Loop: false,
+		}}},
+		{"animation speed", AOCmdSetAnimationSpeed, object.SetAnimationSpeed{Speed: 15}},
+		{"bone", AOCmdSetBonePosition, object.SetBone{Bone: "Head", Override: object.BoneOverride{
+			Position: [3]float32{0, 6.3, 0}, Rotation: [3]float32{-30, 0, 0}, Scale: [3]float32{1, 1.5, 1},
+			Interpolation: [3]float32{0.1, 0.2, 0.3}, AbsoluteRotation: true, AbsoluteScale: true,
+		}}},
+		{"attach", AOCmdAttachTo, object.AttachTo{Attachment: object.Attachment{
+			ParentID: 300, Bone: "Arm_Right", Position: [3]float32{0, 5, 2}, Rotation: [3]float32{90, 0, 0}, ForceVisible: true,
+		}}},
+		{"detach", AOCmdAttachTo, object.AttachTo{}},
+	} {
+		data := WriteObjectMessage(test.msg)
+		if len(data) == 0 || data[0] != test.command {
+			t.Fatalf("%s: encoded as %v, want command %d", test.name, data, test.command)
+		}
+		got, err := ReadObjectMessage(data)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(got, test.msg) {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, test.msg)
+		}
+	}
+}
+
+func TestObjectMessageOlderServers(t *testing.T) {
+	// Fields added to the end of messages keep their defaults when older servers leave them out
+	full := testProperties()
+	props := WriteObjectMessage(object.SetProperties{Properties: full})
+	tail := 2 + len(full.DamageTextureModifier) + 1 + 1 + 4 + 1
+	got, err := ReadObjectMessage(props[:len(props)-tail])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := full
+	defaults := object.DefaultProperties()
+	want.DamageTextureModifier, want.Shaded, want.ShowOnMinimap = defaults.DamageTextureModifier, defaults.Shaded, defaults.ShowOnMinimap
+	want.NametagBgColor, want.HasNametagBgColor, want.RotateSelectionBox = color.NRGBA{}, false, false
+	if !reflect.DeepEqual(got, object.SetProperties{Properties: want}) {
+		t.Errorf("properties of version 4 without additions: got %+v, want %+v", got, want)
+	}
+
+	animation := WriteObjectMessage(object.SetAnimation{Animation: object.Animation{Frames: [2]float32{0, 79}, Speed: 30}})
+	if got, err := ReadObjectMessage(animation[:len(animation)-1]); err != nil || !got.(object.SetAnimation).Animation.Loop {
+		t.Errorf("animation without the loop flag: %+v, %v, want a loop", got, err)
+	}
+
+	bone := WriteObjectMessage(object.SetBone{Bone: "Leg", Override: object.BoneOverride{Position: [3]float32{1, 2, 3}, Rotation: [3]float32{4, 5, 6}}})
+	got, err = ReadObjectMessage(bone[:1+2+3+24])
+	want2 := object.SetBone{Bone: "Leg", Override: object.BoneOverride{
+		Position: [3]float32{1, 2, 3}, Rotation: [3]float32{4, 5, 6}, Scale: [3]float32{1, 1, 1},
+		AbsolutePosition: true, AbsoluteRotation: true,
+	}}
+	if err != nil || !reflect.DeepEqual(got, want2) {
+		t.Errorf("bone position and rotation only: got %+v, %v, want %+v", got, err, want2)
+	}
+
+	attach := WriteObjectMessage(object.AttachTo{Attachment: object.Attachment{ParentID: 5, ForceVisible: true}})
+	if got, err := ReadObjectMessage(attach[:len(attach)-1]); err != nil || got.(object.AttachTo).Attachment.ForceVisible {
+		t.Errorf("attachment without force_visible: %+v, %v", got, err)
+	}
+}
+
+func TestObjectMessageErrors(t *testing.T) {
+	for _, data := range [][]byte{
+		{},
+		{AOCmdUpdatePosition, 0, 0},
+		{AOCmdSetProperties, 3}, // Unsupported properties version
+		WriteObjectMessage(object.SetProperties{Properties: testProperties()})[:40],
+	} {
+		if _, err := ReadObjectMessage(data); err == nil {
+			t.Errorf("%v: no error", data)
+		}
+	}
+	// Commands the client does not draw decode to nothing
+	for _, data := range [][]byte{WritePhysicsOverride(player.DefaultPhysicsOverride()), {AOCmdSpawnInfant, 0, 1}} {
+		if msg, err := ReadObjectMessage(data); msg != nil || err != nil {
+			t.Errorf("command %d: %+v, %v", data[0], msg, err)
+		}
+	}
+	// Negative parent IDs detach
+	attach := WriteObjectMessage(object.AttachTo{Attachment: object.Attachment{ParentID: 0xFFFF}})
+	if got, _ := ReadObjectMessage(attach); got.(object.AttachTo).Attachment.ParentID != 0 {
+		t.Errorf("parent -1 read as %+v", got)
+	}
+}
+
+func TestActiveObjectMessages(t *testing.T) {
+	var w network.Writer
+	messages := []ActiveObjectMessage{
+		{ID: 1, Data: WriteObjectMessage(object.Punched{HP: 3})},
+		{ID: 65535, Data: WriteObjectMessage(object.SetTextureMod{Mod: "^[invert:rgb"})},
+	}
+	for _, m := range messages {
+		w.U16(m.ID).String16(string(m.Data))
+	}
+	got, err := ReadActiveObjectMessages(network.NewReader(w.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, messages) {
+		t.Fatalf("got %+v, want %+v", got, messages)
+	}
+}
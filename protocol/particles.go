@@ -0,0 +1,321 @@
+package protocol
+
+import (
+	"bettermt/main/blocktypes"
+	"bettermt/main/network"
+	"bettermt/main/particle"
+)
+
+// First protocol version sending every spawner parameter as a tween
+const particleTweenProtocolVersion = 42
+
+// Texture flags of particles, followed by the blend mode in the bits above
+const particleTextureAnimated = uint8(1)
+
+// ReadSpawnParticle reads TOCLIENT_SPAWN_PARTICLE. Older servers stop before the node, the drag and the
+// texture properties, which then keep their defaults.
+func ReadSpawnParticle(r *network.Reader) (particle.Params, error) {
+	p := particle.DefaultParams()
+	p.Position = r.V3F32()
+	p.Velocity = r.V3F32()
+	p.Acceleration = r.V3F32()
+	p.ExpirationTime = r.F32()
+	p.Size = r.F32()
+	p.CollisionDetection = r.Bool()
+	p.Texture.Name = r.String32()
+	p.Vertical = r.Bool()
+	p.CollisionRemoval = r.Bool()
+	p.Texture.Animation = blocktypes.ReadTileAnimation(r)
+	p.Glow = r.U8()
+	p.ObjectCollision = r.Bool()
+
+	if r.Err() == nil && r.Len() > 0 {
+		p.Node = particle.Node{Content: r.U16(), Param2: r.U8(), Tile: r.U8()}
+	}
+	if r.Err() == nil && r.Len() > 0 {
+		p.Drag = r.V3F32()
+		p.Jitter = readVecRange(r)
+		p.Bounce = readFloatRange(r)
+		readParticleTexture(r, &p.Texture, false, false)
+	}
+	return p, r.Err()
+}
+
+func WriteSpawnParticle(p particle.Params) *network.Writer {
+	w := network.NewWriter(network.ToClientSpawnParticle)
+	w.V3F32(p.Position)
+	w.V3F32(p.Velocity)
+	w.V3F32(p.Acceleration)
+	w.F32(p.ExpirationTime)
+	w.F32(p.Size)
+	w.Bool(p.CollisionDetection)
+	w.String32(p.Texture.Name)
+	w.Bool(p.Vertical)
+	w.Bool(p.CollisionRemoval)
+	blocktypes.WriteTileAnimation(w, p.Texture.Animation)
+	w.U8(p.Glow)
+	w.Bool(p.ObjectCollision)
+	w.U16(p.Node.Content).U8(p.Node.Param2).U8(p.Node.Tile)
+	w.V3F32(p.Drag)
+	writeVecRange(w, p.Jitter)
+	writeFloatRange(w, p.Bounce)
+	writeParticleTexture(w, p.Texture, false, false)
+	return w
+}
+
+// ParticleSpawner is TOCLIENT_ADD_PARTICLESPAWNER
+type ParticleSpawner struct {
+	ID     uint32
+	Params particle.SpawnerParams
+}
+
+// ReadAddParticleSpawner reads TOCLIENT_ADD_PARTICLESPAWNER. Before protocol version 42 the position,
+// velocity, acceleration, expiration time and size come as ranges, with their biases and end values further
+// back. Older servers stop before the node and the newer parameters, which then keep their defaults and do not
+// change over time.
+func ReadAddParticleSpawner(r *network.Reader, protocolVersion uint16) (ParticleSpawner, error) {
+	var s ParticleSpawner
+	p := particle.DefaultSpawnerParams()
+	p.Amount = r.U16()
+	p.Time = r.F32()
+
+	tweened := protocolVersion >= particleTweenProtocolVersion
+	if tweened {
+		p.Position = readVecRangeTween(r)
+		p.Velocity = readVecRangeTween(r)
+		p.Acceleration = readVecRangeTween(r)
+		p.ExpirationTime = readFloatRangeTween(r)
+		p.Size = readFloatRangeTween(r)
+	} else {
+		for _, t := range []*particle.VecRangeTween{&p.Position, &p.Velocity, &p.Acceleration} {
+			t.Start.Min, t.Start.Max = r.V3F32(), r.V3F32()
+		}
+		for _, t := range []*particle.FloatRangeTween{&p.ExpirationTime, &p.Size} {
+			t.Start.Min, t.Start.Max = r.F32(), r.F32()
+		}
+	}
+
+	p.CollisionDetection = r.Bool()
+	p.Texture.Name = r.String32()
+	s.ID = r.U32()
+	p.Vertical = r.Bool()
+	p.CollisionRemoval = r.Bool()
+	p.AttachedID = r.U16()
+	p.Texture.Animation = blocktypes.ReadTileAnimation(r)
+	p.Glow = r.U8()
+	p.ObjectCollision = r.Bool()
+
+	complete := false
+	if r.Err() == nil && r.Len() > 0 {
+		p.Node = particle.Node{Content: r.U16(), Param2: r.U8(), Tile: r.U8()}
+		complete = tweened || r.Len() > 0
+	}
+	if complete && !tweened {
+		vecs := []*particle.VecRangeTween{&p.Position, &p.Velocity, &p.Acceleration}
+		floats := []*particle.FloatRangeTween{&p.ExpirationTime, &p.Size}
+		for _, t := range vecs {
+			t.Start.Bias = r.F32()
+		}
+		for _, t := range floats {
+			t.Start.Bias = r.F32()
+		}
+		for _, t := range vecs {
+			t.End = readVecRange(r)
+		}
+		for _, t := range floats {
+			t.End = readFloatRange(r)
+		}
+	}
+	if complete {
+		readParticleTexture(r, &p.Texture, false, true)
+		p.Drag = readVecRangeTween(r)
+		p.Jitter = readVecRangeTween(r)
+		p.Bounce = readFloatRangeTween(r)
+		p.AttractorKind = particle.AttractorKind(r.U8())
+		if p.AttractorKind != particle.AttractorNone {
+			p.Attract = readFloatRangeTween(r)
+			p.AttractorOrigin = readVecTween(r)
+			p.AttractorAttachment = r.U16()
+			p.AttractorKill = r.U8()&1 != 0
+			if p.AttractorKind != particle.AttractorPoint {
+				p.AttractorDirection = readVecTween(r)
+				p.DirectionAttachment = r.U16()
+			}
+		}
+		p.Radius = readVecRangeTween(r)
+		count := r.U16()
+		for i := uint16(0); i < count && r.Err() == nil; i++ {
+			t := particle.DefaultTexture("")
+			readParticleTexture(r, &t, true, true)
+			p.TexturePool = append(p.TexturePool, t)
+		}
+	} else {
+		// Without end values the ranges keep their start values instead of changing towards zero
+		for _, t := range []*particle.VecRangeTween{&p.Position, &p.Velocity, &p.Acceleration} {
+			t.End = t.Start
+		}
+		for _, t := range []*particle.FloatRangeTween{&p.ExpirationTime, &p.Size} {
+			t.End = t.Start
+		}
+	}
+
+	s.Params = p
+	return s, r.Err()
+}
+
+// Write writes the spawner in the format of protocol version 42 and later
+func (s ParticleSpawner) Write() *network.Writer {
+	p := &s.Params
+	w := network.NewWriter(network.ToClientAddParticleSpawner)
+	w.U16(p.Amount)
+	w.F32(p.Time)
+	writeVecRangeTween(w, p.Position)
+	writeVecRangeTween(w, p.Velocity)
+	writeVecRangeTween(w, p.Acceleration)
+	writeFloatRangeTween(w, p.ExpirationTime)
+	writeFloatRangeTween(w, p.Size)
+	w.Bool(p.CollisionDetection)
+	w.String32(p.Texture.Name)
+	w.U32(s.ID)
+	w.Bool(p.Vertical)
+	w.Bool(p.CollisionRemoval)
+	w.U16(p.AttachedID)
+	blocktypes.WriteTileAnimation(w, p.Texture.Animation)
+	w.U8(p.Glow)
+	w.Bool(p.ObjectCollision)
+	w.U16(p.Node.Content).U8(p.Node.Param2).U8(p.Node.Tile)
+	writeParticleTexture(w, p.Texture, false, true)
+	writeVecRangeTween(w, p.Drag)
+	writeVecRangeTween(w, p.Jitter)
+	writeFloatRangeTween(w, p.Bounce)
+	w.U8(uint8(p.AttractorKind))
+	if p.AttractorKind != particle.AttractorNone {
+		writeFloatRangeTween(w, p.Attract)
+		writeVecTween(w, p.AttractorOrigin)
+		w.U16(p.AttractorAttachment)
+		w.Bool(p.AttractorKill)
+		if p.AttractorKind != particle.AttractorPoint {
+			writeVecTween(w, p.AttractorDirection)
+			w.U16(p.DirectionAttachment)
+		}
+	}
+	writeVecRangeTween(w, p.Radius)
+	w.U16(uint16(len(p.TexturePool)))
+	for _, t := range p.TexturePool {
+		writeParticleTexture(w, t, true, true)
+	}
+	return w
+}
+
+// ReadDeleteParticleSpawner reads TOCLIENT_DELETE_PARTICLESPAWNER, the ID of the spawner to delete
+func ReadDeleteParticleSpawner(r *network.Reader) (uint32, error) {
+	id := r.U32()
+	return id, r.Err()
+}
+
+func WriteDeleteParticleSpawner(id uint32) *network.Writer {
+	return network.NewWriter(network.ToClientDeleteParticleSpawner).U32(id)
+}
+
+// readParticleTexture reads the blend mode, alpha and scale of a texture. Textures of spawner pools come with
+// their name, and textures of spawners and pools with their animation if it is animated.
+func readParticleTexture(r *network.Reader, t *particle.Texture, name, animation bool) {
+	flags := r.U8()
+	t.Blend = particle.BlendMode(flags >> 1)
+	t.Alpha = readFloatTween(r)
+	t.Scale = readVec2Tween(r)
+	if name {
+		t.Name = r.String32()
+	}
+	if animation && flags&particleTextureAnimated != 0 {
+		t.Animation = blocktypes.ReadTileAnimation(r)
+	}
+}
+
+func writeParticleTexture(w *network.Writer, t particle.Texture, name, animation bool) {
+	flags := uint8(t.Blend) << 1
+	animated := t.Animation.Type != blocktypes.TileAnimationNone
+	if animated {
+		flags |= particleTextureAnimated
+	}
+	w.U8(flags)
+	writeFloatTween(w, t.Alpha)
+	writeVec2Tween(w, t.Scale)
+	if name {
+		w.String32(t.Name)
+	}
+	if animation && animated {
+		blocktypes.WriteTileAnimation(w, t.Animation)
+	}
+}
+
+func readTween(r *network.Reader) particle.Tween {
+	return particle.Tween{Style: particle.TweenStyle(r.U8()), Reps: r.U16(), Beginning: r.F32()}
+}
+
+func writeTween(w *network.Writer, t particle.Tween) {
+	w.U8(uint8(t.Style)).U16(t.Reps).F32(t.Beginning)
+}
+
+func readFloatRange(r *network.Reader) particle.FloatRange {
+	return particle.FloatRange{Min: r.F32(), Max: r.F32(), Bias: r.F32()}
+}
+
+func writeFloatRange(w *network.Writer, v particle.FloatRange) {
+	w.F32(v.Min).F32(v.Max).F32(v.Bias)
+}
+
+func readVecRange(r *network.Reader) particle.VecRange {
+	return particle.VecRange{Min: r.V3F32(), Max: r.V3F32(), Bias: r.F32()}
+}
+
+func writeVecRange(w *network.Writer, v particle.VecRange) {
+	w.V3F32(v.Min).V3F32(v.Max).F32(v.Bias)
+}
+
+func readFloatTween(r *network.Reader) particle.FloatTween {
+	return particle.FloatTween{Tween: readTween(r), Start: r.F32(), End: r.F32()}
+}
+
+func writeFloatTween(w *network.Writer, t particle.FloatTween) {
+	writeTween(w, t.Tween)
+	w.F32(t.Start).F32(t.End)
+}
+
+func readVec2Tween(r *network.Reader) particle.Vec2Tween {
+	return particle.Vec2Tween{Tween: readTween(r), Start: r.V2F32(), End: r.V2F32()}
+}
+
+func writeVec2Tween(w *network.Writer, t particle.Vec2Tween) {
+	writeTween(w, t.Tween)
+	w.V2F32(t.Start).V2F32(t.End)
+}
+
+func readVecTween(r *network.Reader) particle.VecTween {
+	return particle.VecTween{Tween: readTween(r), Start: r.V3F32(), End: r.V3F32()}
+}
+
+func writeVecTween(w *network.Writer, t particle.VecTween) {
+	writeTween(w, t.Tween)
+	w.V3F32(t.Start).V3F32(t.End)
+}
+
+func readFloatRangeTween(r *network.Reader) particle.FloatRangeTween {
+	return particle.FloatRangeTween{Tween: readTween(r), Start: readFloatRange(r), End: readFloatRange(r)}
+}
+
+func writeFloatRangeTween(w *network.Writer, t particle.FloatRangeTween) {
+	writeTween(w, t.Tween)
+	writeFloatRange(w, t.Start)
+	writeFloatRange(w, t.End)
+}
+
+func readVecRangeTween(r *network.Reader) particle.VecRangeTween {
+	return particle.VecRangeTween{Tween: readTween(r), Start: readVecRange(r), End: readVecRange(r)}
+}
+
+func writeVecRangeTween(w *network.Writer, t particle.VecRangeTween) {
+	writeTween(w, t.Tween)
+	writeVecRange(w, t.Start)
+	writeVecRange(w, t.End)
+}
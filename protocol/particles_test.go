@@ -0,0 +1,294 @@
+package protocol
+
+import (
+	"reflect"
+	"testing"
+
+	"bettermt/main/blocktypes"
+	"bettermt/main/network"
+	"bettermt/main/particle"
+)
+
+// Bytes of a TOCLIENT_SPAWN_PARTICLE after the object collision flag: the node, then the drag, jitter, bounce and
+// texture properties of newer servers
+const (
+	spawnParticleNodeSize  = 4
+	spawnParticleNewerSize = 12 + 28 + 12 + 1 + 15 + 23
+)
+
+func testTexture(name string) particle.Texture {
+	return particle.Texture{
+		Name:  name,
+		Blend: particle.BlendScreen,
+		Alpha: particle.FloatTween{
+			Tween: particle.Tween{Style: particle.TweenPulse, Reps: 3, Beginning: 0.25},
+			Start: 0.2, End: 0.9,
+		},
+		Scale: particle.Vec2Tween{
+			Tween: particle.Tween{Style: particle.TweenReverse, Reps: 1},
+			Start: [2]float32{1, 2}, End: [2]float32{3, 4},
+		},
+		Animation: blocktypes.TileAnimation{Type: blocktypes.TileAnimationSheet2D, FramesW: 4, FramesH: 2, Length: 0.5},
+	}
+}
+
+func testParticle() particle.Params {
+	p := particle.DefaultParams()
+	p.Position = [3]float32{1, 2, 3}
+	p.Velocity = [3]float32{-1, 0.5, 2}
+	p.Acceleration = [3]float32{0, -9.81, 0}
+	p.ExpirationTime = 4
+	p.Size = 2.5
+	p.CollisionDetection = true
+	p.CollisionRemoval = true
+	p.ObjectCollision = true
+	p.Vertical = true
+	p.Texture = testTexture("spark.png")
+	p.Glow = 7
+	p.Node = particle.Node{Content: 42, Param2: 3, Tile: 2}
+	p.Drag = [3]float32{0.1, 0.2, 0.3}
+	p.Jitter = particle.VecRange{Min: [3]float32{-1, -2, -3}, Max: [3]float32{1, 2, 3}, Bias: 0.5}
+	p.Bounce = particle.FloatRange{Min: 0.2, Max: 0.8, Bias: -1}
+	return p
+}
+
+func TestSpawnParticleRoundTrip(t *testing.T) {
+	p := testParticle()
+	got, err := ReadSpawnParticle(body(t, WriteSpawnParticle(p), network.ToClientSpawnParticle))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, p) {
+		t.Fatalf("got %+v, want %+v", got, p)
+	}
+}
+
+func TestSpawnParticleOlderServers(t *testing.T) {
+	p := testParticle()
+	data := WriteSpawnParticle(p).Bytes()
+
+	withNode := p
+	withNode.Drag, withNode.Jitter, withNode.Bounce = [3]float32{}, particle.VecRange{}, particle.FloatRange{}
+	withNode.Texture = particle.DefaultTexture(p.Texture.Name)
+	withNode.Texture.Animation = p.Texture.Animation
+	withoutNode := withNode
+	withoutNode.Node = particle.DefaultParams().Node
+
+	for _, test := range []struct {
+		name   string
+		length int
+		want   particle.Params
+	}{
+		{"with node", len(data) - spawnParticleNewerSize, withNode},
+		{"without node", len(data) - spawnParticleNewerSize - spawnParticleNodeSize, withoutNode},
+	} {
+		r := network.NewReader(data[:test.length])
+		r.U16()
+		got, err := ReadSpawnParticle(r)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSpawnParticleTruncated(t *testing.T) {
+	data := WriteSpawnParticle(testParticle()).Bytes()
+	r := network.NewReader(data[:len(data)-1])
+	r.U16()
+	if _, err := ReadSpawnParticle(r); err == nil {
+		t.Fatal("no error for a truncated texture")
+	}
+}
+
+func vecRangeTween(style particle.TweenStyle, base float32) particle.VecRangeTween {
+	return particle.VecRangeTween{
+		Tween: particle.Tween{Style: style, Reps: 2, Beginning: 0.1},
+		Start: particle.VecRange{Min: [3]float32{base, base + 1, base + 2}, Max: [3]float32{base + 3, base + 4, base + 5}, Bias: 1},
+		End:   particle.VecRange{Min: [3]float32{-base, -base - 1, -base - 2}, Max: [3]float32{base, base, base}, Bias: -1},
+	}
+}
+
+func floatRangeTween(style particle.TweenStyle, base float32) particle.FloatRangeTween {
+	return particle.FloatRangeTween{
+		Tween: particle.Tween{Style: style, Reps: 1, Beginning: 0.5},
+		Start: particle.FloatRange{Min: base, Max: base + 1, Bias: 0.5},
+		End:   particle.FloatRange{Min: base + 2, Max: base + 3, Bias: -0.5},
+	}
+}
+
+// testSpawner returns a spawner setting every parameter its attractor kind sends
+func testSpawner(kind particle.AttractorKind) ParticleSpawner {
+	p := particle.DefaultSpawnerParams()
+	p.Amount = 20
+	p.Time = 2.5
+	p.Position = vecRangeTween(particle.TweenForward, 1)
+	p.Velocity = vecRangeTween(particle.TweenReverse, 2)
+	p.Acceleration = vecRangeTween(particle.TweenPulse, 3)
+	p.Drag = vecRangeTween(particle.TweenFlicker, 4)
+	p.Jitter = vecRangeTween(particle.TweenForward, 5)
+	p.Radius = vecRangeTween(particle.TweenReverse, 6)
+	p.ExpirationTime = floatRangeTween(particle.TweenForward, 1)
+	p.Size = floatRangeTween(particle.TweenReverse, 2)
+	p.Bounce = floatRangeTween(particle.TweenPulse, 0.25)
+	p.CollisionDetection = true
+	p.CollisionRemoval = true
+	p.ObjectCollision = true
+	p.Vertical = true
+	p.Texture = testTexture("smoke.png")
+	plain := particle.DefaultTexture("plain.png")
+	plain.Blend = particle.BlendSub
+	p.TexturePool = []particle.Texture{testTexture("pool.png"), plain}
+	p.Glow = 14
+	p.Node = particle.Node{Content: 9, Param2: 1, Tile: 6}
+	p.AttachedID = 12
+
+	p.AttractorKind = kind
+	if kind != particle.AttractorNone {
+		p.Attract = floatRangeTween(particle.TweenFlicker, -3)
+		p.AttractorOrigin = particle.VecTween{
+			Tween: particle.Tween{Style: particle.TweenPulse, Reps: 4},
+			Start: [3]float32{1, 2, 3}, End: [3]float32{4, 5, 6},
+		}
+		p.AttractorAttachment = 7
+		p.AttractorKill = true
+	}
+	if kind != particle.AttractorNone && kind != particle.AttractorPoint {
+		p.AttractorDirection = particle.VecTween{
+			Tween: particle.Tween{Style: particle.TweenReverse, Reps: 1, Beginning: 0.75},
+			Start: [3]float32{0, 1, 0}, End: [3]float32{1, 0, 0},
+		}
+		p.DirectionAttachment = 8
+	}
+	return ParticleSpawner{ID: 0x01020304, Params: p}
+}
+
+func TestAddParticleSpawnerRoundTrip(t *testing.T) {
+	for _, kind := range []particle.AttractorKind{
+		particle.AttractorNone, particle.AttractorPoint, particle.AttractorLine, particle.AttractorPlane,
+	} {
+		s := testSpawner(kind)
+		got, err := ReadAddParticleSpawner(body(t, s.Write(), network.ToClientAddParticleSpawner), particleTweenProtocolVersion)
+		if err != nil {
+			t.Fatalf("attractor %d: %v", kind, err)
+		}
+		if !reflect.DeepEqual(got, s) {
+			t.Errorf("attractor %d: got %+v, want %+v", kind, got, s)
+		}
+	}
+}
+
+func TestAddParticleSpawnerTruncated(t *testing.T) {
+	data := testSpawner(particle.AttractorLine).Write().Bytes()
+	r := network.NewReader(data[:len(data)-1])
+	r.U16()
+	if _, err := ReadAddParticleSpawner(r, particleTweenProtocolVersion); err == nil {
+		t.Fatal("no error for a truncated texture pool")
+	}
+}
+
+// writeOldSpawner writes a spawner the way servers before protocol version 42 do, up to the object collision
+// flag: ranges without biases or end values
+func writeOldSpawner(id uint32) *network.Writer {
+	w := &network.Writer{}
+	w.U16(5).F32(3)
+	w.V3F32([3]float32{1, 2, 3}).V3F32([3]float32{4, 5, 6})
+	w.V3F32([3]float32{0, 1, 0}).V3F32([3]float32{0, 2, 0})
+	w.V3F32([3]float32{0, -1, 0}).V3F32([3]float32{0, -1, 0})
+	w.F32(1).F32(2)
+	w.F32(0.5).F32(1.5)
+	w.Bool(true)
+	w.String32("bubble.png")
+	w.U32(id)
+	w.Bool(false).Bool(true)
+	w.U16(3)
+	blocktypes.WriteTileAnimation(w, blocktypes.TileAnimation{Type: blocktypes.TileAnimationVerticalFrames, AspectW: 16, AspectH: 16, Length: 1})
+	w.U8(2)
+	w.Bool(true)
+	return w
+}
+
+func TestAddParticleSpawnerOlderServers(t *testing.T) {
+	want := particle.DefaultSpawnerParams()
+	want.Amount, want.Time = 5, 3
+	want.Position.Start = particle.VecRange{Min: [3]float32{1, 2, 3}, Max: [3]float32{4, 5, 6}}
+	want.Velocity.Start = particle.VecRange{Min: [3]float32{0, 1, 0}, Max: [3]float32{0, 2, 0}}
+	want.Acceleration.Start = particle.FixedVec([3]float32{0, -1, 0})
+	want.ExpirationTime.Start = particle.FloatRange{Min: 1, Max: 2}
+	want.Size.Start = particle.FloatRange{Min: 0.5, Max: 1.5}
+	want.CollisionDetection = true
+	want.Texture.Name = "bubble.png"
+	want.CollisionRemoval = true
+	want.AttachedID = 3
+	want.Texture.Animation = blocktypes.TileAnimation{Type: blocktypes.TileAnimationVerticalFrames, AspectW: 16, AspectH: 16, Length: 1}
+	want.Glow = 2
+	want.ObjectCollision = true
+
+	// Without end values the ranges do not change over the time of the spawner
+	unchanging := want
+	for _, tw := range []*particle.VecRangeTween{&unchanging.Position, &unchanging.Velocity, &unchanging.Acceleration} {
+		tw.End = tw.Start
+	}
+	for _, tw := range []*particle.FloatRangeTween{&unchanging.ExpirationTime, &unchanging.Size} {
+		tw.End = tw.Start
+	}
+	withNode := unchanging
+	withNode.Node = particle.Node{Content: 30, Param2: 4, Tile: 1}
+
+	complete := withNode
+	complete.Position.Start.Bias, complete.Size.Start.Bias = 1, -1
+	complete.Position.End = particle.FixedVec([3]float32{7, 8, 9})
+	complete.Size.End = particle.Fixed(3)
+	complete.Texture.Blend = particle.BlendAdd
+	complete.Bounce = floatRangeTween(particle.TweenForward, 0.5)
+
+	old := func(extra func(w *network.Writer)) []byte {
+		w := writeOldSpawner(99)
+		if extra != nil {
+			extra(w)
+		}
+		return w.Bytes()
+	}
+	tests := []struct {
+		name string
+		data []byte
+		want particle.SpawnerParams
+	}{
+		{"without node", old(nil), unchanging},
+		{"with node", old(func(w *network.Writer) { w.U16(30).U8(4).U8(1) }), withNode},
+		{"complete", old(func(w *network.Writer) {
+			w.U16(30).U8(4).U8(1)
+			w.F32(1).F32(0).F32(0).F32(0).F32(-1)
+			for _, v := range []particle.VecRange{complete.Position.End, complete.Velocity.End, complete.Acceleration.End} {
+				writeVecRange(w, v)
+			}
+			writeFloatRange(w, complete.ExpirationTime.End)
+			writeFloatRange(w, complete.Size.End)
+			writeParticleTexture(w, complete.Texture, false, true)
+			writeVecRangeTween(w, complete.Drag)
+			writeVecRangeTween(w, complete.Jitter)
+			writeFloatRangeTween(w, complete.Bounce)
+			w.U8(uint8(particle.AttractorNone))
+			writeVecRangeTween(w, complete.Radius)
+			w.U16(0)
+		}), complete},
+	}
+	for _, test := range tests {
+		got, err := ReadAddParticleSpawner(network.NewReader(test.data), particleTweenProtocolVersion-1)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if got.ID != 99 || !reflect.DeepEqual(got.Params, test.want) {
+			t.Errorf("%s: got %d %+v, want %+v", test.name, got.ID, got.Params, test.want)
+		}
+	}
+}
+
+func TestDeleteParticleSpawnerRoundTrip(t *testing.T) {
+	id, err := ReadDeleteParticleSpawner(body(t, WriteDeleteParticleSpawner(0xdeadbeef), network.ToClientDeleteParticleSpawner))
+	if err != nil || id != 0xdeadbeef {
+		t.Fatalf("got %#x, %v", id, err)
+	}
+}
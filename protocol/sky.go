@@ -0,0 +1,227 @@
+package protocol
+
+import (
+	"image/color"
+
+	"bettermt/main/network"
+	"bettermt/main/sky"
+)
+
+// TimeOfDay is TOCLIENT_TIME_OF_DAY. Speed is how many times faster than real time the day passes.
+type TimeOfDay struct {
+	Time  uint16 // Thousandths of an hour
+	Speed float32
+}
+
+func ReadTimeOfDay(r *network.Reader) (TimeOfDay, error) {
+	t := TimeOfDay{Time: r.U16() % sky.DayLength}
+	if r.Err() == nil && r.Len() > 0 {
+		t.Speed = r.F32()
+	}
+	return t, r.Err()
+}
+
+func (t TimeOfDay) Write() *network.Writer {
+	w := network.NewWriter(network.ToClientTimeOfDay)
+	w.U16(t.Time)
+	w.F32(t.Speed)
+	return w
+}
+
+// ReadSetSky reads TOCLIENT_SET_SKY. Skyboxes list their textures, regular skies their colors. The orbit
+// tilt and the fog settings are only sent by newer servers.
+func ReadSetSky(r *network.Reader) (sky.Params, error) {
+	p := sky.DefaultParams()
+	p.BgColor = readARGB(r)
+	p.Type = r.String16()
+	p.Clouds = r.Bool()
+	p.FogSunTint = readARGB(r)
+	p.FogMoonTint = readARGB(r)
+	p.FogTintType = r.String16()
+	if r.Err() != nil {
+		return p, r.Err()
+	}
+
+	switch p.Type {
+	case sky.TypeSkybox:
+		count := r.U16()
+		for i := uint16(0); i < count && r.Err() == nil; i++ {
+			p.Textures = append(p.Textures, r.String16())
+		}
+	case sky.TypeRegular:
+		p.Colors = sky.Colors{
+			DaySky:       readARGB(r),
+			DayHorizon:   readARGB(r),
+			DawnSky:      readARGB(r),
+			DawnHorizon:  readARGB(r),
+			NightSky:     readARGB(r),
+			NightHorizon: readARGB(r),
+			Indoors:      readARGB(r),
+		}
+	}
+
+	if r.Err() == nil && r.Len() > 0 {
+		p.BodyOrbitTilt = r.F32()
+	}
+	if r.Err() == nil && r.Len() > 0 {
+		p.FogDistance = r.S16()
+		p.FogStart = r.F32()
+	}
+	if r.Err() == nil && r.Len() > 0 {
+		p.FogColor = readARGB(r)
+	}
+	return p, r.Err()
+}
+
+func WriteSetSky(p sky.Params) *network.Writer {
+	w := network.NewWriter(network.ToClientSetSky)
+	writeARGB(w, p.BgColor)
+	w.String16(p.Type)
+	w.Bool(p.Clouds)
+	writeARGB(w, p.FogSunTint)
+	writeARGB(w, p.FogMoonTint)
+	w.String16(p.FogTintType)
+	switch p.Type {
+	case sky.TypeSkybox:
+		w.U16(uint16(len(p.Textures)))
+		for _, texture := range p.Textures {
+			w.String16(texture)
+		}
+	case sky.TypeRegular:
+		for _, c := range []color.NRGBA{
+			p.Colors.DaySky, p.Colors.DayHorizon,
+			p.Colors.DawnSky, p.Colors.DawnHorizon,
+			p.Colors.NightSky, p.Colors.NightHorizon,
+			p.Colors.Indoors,
+		} {
+			writeARGB(w, c)
+		}
+	}
+	w.F32(p.BodyOrbitTilt)
+	w.S16(p.FogDistance)
+	w.F32(p.FogStart)
+	writeARGB(w, p.FogColor)
+	return w
+}
+
+func ReadSetSun(r *network.Reader) (sky.SunParams, error) {
+	p := sky.SunParams{
+		Visible:        r.Bool(),
+		Texture:        r.String16(),
+		Tonemap:        r.String16(),
+		Sunrise:        r.String16(),
+		SunriseVisible: r.Bool(),
+		Scale:          r.F32(),
+	}
+	return p, r.Err()
+}
+
+func WriteSetSun(p sky.SunParams) *network.Writer {
+	w := network.NewWriter(network.ToClientSetSun)
+	w.Bool(p.Visible)
+	w.String16(p.Texture)
+	w.String16(p.Tonemap)
+	w.String16(p.Sunrise)
+	w.Bool(p.SunriseVisible)
+	w.F32(p.Scale)
+	return w
+}
+
+func ReadSetMoon(r *network.Reader) (sky.MoonParams, error) {
+	p := sky.MoonParams{
+		Visible: r.Bool(),
+		Texture: r.String16(),
+		Tonemap: r.String16(),
+		Scale:   r.F32(),
+	}
+	return p, r.Err()
+}
+
+func WriteSetMoon(p sky.MoonParams) *network.Writer {
+	w := network.NewWriter(network.ToClientSetMoon)
+	w.Bool(p.Visible)
+	w.String16(p.Texture)
+	w.String16(p.Tonemap)
+	w.F32(p.Scale)
+	return w
+}
+
+// ReadSetStars reads TOCLIENT_SET_STARS. The opacity of the stars during the day is only sent by newer
+// servers.
+func ReadSetStars(r *network.Reader) (sky.StarParams, error) {
+	p := sky.StarParams{
+		Visible: r.Bool(),
+		Count:   r.U32(),
+		Color:   readARGB(r),
+		Scale:   r.F32(),
+	}
+	if r.Err() == nil && r.Len() > 0 {
+		p.DayOpacity = r.F32()
+	}
+	return p, r.Err()
+}
+
+func WriteSetStars(p sky.StarParams) *network.Writer {
+	w := network.NewWriter(network.ToClientSetStars)
+	w.Bool(p.Visible)
+	w.U32(p.Count)
+	writeARGB(w, p.Color)
+	w.F32(p.Scale)
+	w.F32(p.DayOpacity)
+	return w
+}
+
+// ReadCloudParams reads TOCLIENT_CLOUD_PARAMS. The shadow color is only sent by newer servers.
+func ReadCloudParams(r *network.Reader) (sky.CloudParams, error) {
+	p := sky.DefaultCloudParams()
+	p.Density = r.F32()
+	p.ColorBright = readARGB(r)
+	p.ColorAmbient = readARGB(r)
+	p.Height = r.F32()
+	p.Thickness = r.F32()
+	p.Speed = r.V2F32()
+	if r.Err() == nil && r.Len() > 0 {
+		p.ColorShadow = readARGB(r)
+	}
+	return p, r.Err()
+}
+
+func WriteCloudParams(p sky.CloudParams) *network.Writer {
+	w := network.NewWriter(network.ToClientCloudParams)
+	w.F32(p.Density)
+	writeARGB(w, p.ColorBright)
+	writeARGB(w, p.ColorAmbient)
+	w.F32(p.Height)
+	w.F32(p.Thickness)
+	w.V2F32(p.Speed)
+	writeARGB(w, p.ColorShadow)
+	return w
+}
+
+// OverrideDayNightRatio is TOCLIENT_OVERRIDE_DAY_NIGHT_RATIO. Ratio goes from 0 for night to 1 for day.
+type OverrideDayNightRatio struct {
+	Override bool
+	Ratio    float32
+}
+
+func ReadOverrideDayNightRatio(r *network.Reader) (OverrideDayNightRatio, error) {
+	o := OverrideDayNightRatio{Override: r.Bool(), Ratio: float32(r.U16()) / 1000}
+	return o, r.Err()
+}
+
+func (o OverrideDayNightRatio) Write() *network.Writer {
+	w := network.NewWriter(network.ToClientOverrideDayNightRatio)
+	w.Bool(o.Override)
+	w.U16(uint16(o.Ratio*1000 + 0.5))
+	return w
+}
+
+// readARGB reads a color sent as one byte each of alpha, red, green and blue
+func readARGB(r *network.Reader) color.NRGBA {
+	a := r.U8()
+	return color.NRGBA{R: r.U8(), G: r.U8(), B: r.U8(), A: a}
+}
+
+func writeARGB(w *network.Writer, c color.NRGBA) {
+	w.U8(c.A).U8(c.R).U8(c.G).U8(c.B)
+}
@@ -0,0 +1,104 @@
+package protocol
+
+import (
+	"bettermt/main/network"
+	"bettermt/main/sound"
+)
+
+// PlaySound is TOCLIENT_PLAY_SOUND
+type PlaySound struct {
+	ID        int32
+	Spec      sound.Spec
+	Type      sound.LocationType
+	Position  [3]float32 // In protocol units
+	ObjectID  uint16
+	Ephemeral bool // The server neither stops nor fades the sound, and is not told when it ends
+}
+
+// ReadPlaySound reads TOCLIENT_PLAY_SOUND. Older servers stop before the fade, the pitch, the ephemeral flag or
+// the start time, which then keep their defaults.
+func ReadPlaySound(r *network.Reader) (PlaySound, error) {
+	s := PlaySound{ID: r.S32()}
+	s.Spec.Name = r.String16()
+	s.Spec.Gain = r.F32()
+	s.Type = sound.LocationType(r.U8())
+	s.Position = r.V3F32()
+	s.ObjectID = r.U16()
+	s.Spec.Loop = r.Bool()
+	s.Spec.Pitch = 1
+
+	if r.Err() == nil && r.Len() > 0 {
+		s.Spec.Fade = r.F32()
+	}
+	if r.Err() == nil && r.Len() > 0 {
+		s.Spec.Pitch = r.F32()
+	}
+	if r.Err() == nil && r.Len() > 0 {
+		s.Ephemeral = r.Bool()
+	}
+	if r.Err() == nil && r.Len() > 0 {
+		s.Spec.StartTime = r.F32()
+	}
+	return s, r.Err()
+}
+
+func (s PlaySound) Write() *network.Writer {
+	w := network.NewWriter(network.ToClientPlaySound)
+	w.S32(s.ID)
+	w.String16(s.Spec.Name)
+	w.F32(s.Spec.Gain)
+	w.U8(uint8(s.Type))
+	w.V3F32(s.Position)
+	w.U16(s.ObjectID)
+	w.Bool(s.Spec.Loop)
+	w.F32(s.Spec.Fade)
+	w.F32(s.Spec.Pitch)
+	w.Bool(s.Ephemeral)
+	w.F32(s.Spec.StartTime)
+	return w
+}
+
+// ReadStopSound reads TOCLIENT_STOP_SOUND, returning the ID of the sound
+func ReadStopSound(r *network.Reader) (int32, error) {
+	id := r.S32()
+	return id, r.Err()
+}
+
+func WriteStopSound(id int32) *network.Writer {
+	return network.NewWriter(network.ToClientStopSound).S32(id)
+}
+
+// FadeSound is TOCLIENT_FADE_SOUND
+type FadeSound struct {
+	ID   int32
+	Step float32 // Gain per second
+	Gain float32 // Gain to fade to
+}
+
+func ReadFadeSound(r *network.Reader) (FadeSound, error) {
+	f := FadeSound{ID: r.S32(), Step: r.F32(), Gain: r.F32()}
+	return f, r.Err()
+}
+
+func (f FadeSound) Write() *network.Writer {
+	return network.NewWriter(network.ToClientFadeSound).S32(f.ID).F32(f.Step).F32(f.Gain)
+}
+
+// ReadRemovedSounds reads TOSERVER_REMOVED_SOUNDS, returning the IDs of the sounds
+func ReadRemovedSounds(r *network.Reader) ([]int32, error) {
+	ids := make([]int32, r.U16())
+	for i := range ids {
+		ids[i] = r.S32()
+	}
+	return ids, r.Err()
+}
+
+// WriteRemovedSounds writes TOSERVER_REMOVED_SOUNDS, telling the server which of its sounds ended
+func WriteRemovedSounds(ids []int32) *network.Writer {
+	w := network.NewWriter(network.ToServerRemovedSounds)
+	w.U16(uint16(len(ids)))
+	for _, id := range ids {
+		w.S32(id)
+	}
+	return w
+}
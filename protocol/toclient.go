@@ -0,0 +1,389 @@
+package protocol
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"bettermt/main/blocktypes"
+	"bettermt/main/chat"
+	"bettermt/main/inventory"
+	"bettermt/main/meshbuilder"
+	"bettermt/main/network"
+	"bettermt/main/player"
+)
+
+// Version of the TOCLIENT_CHAT_MESSAGE layout
+const chatMessageVersion = 1
+
+var ErrEmptyBlockData = errors.New("empty block data")
+
+// Hello is TOCLIENT_HELLO, the answer to TOSERVER_INIT
+type Hello struct {
+	SerializationVersion uint8
+	CompressionMode      uint16 // Unused
+	ProtocolVersion      uint16
+	AuthMechanisms       uint32
+	LegacyName           string
+}
+
+func ReadHello(r *network.Reader) (Hello, error) {
+	h := Hello{
+		SerializationVersion: r.U8(),
+		CompressionMode:      r.U16(),
+		ProtocolVersion:      r.U16(),
+		AuthMechanisms:       r.U32(),
+		LegacyName:           r.String16(),
+	}
+	return h, r.Err()
+}
+
+func (h Hello) Write() *network.Writer {
+	w := network.NewWriter(network.ToClientHello)
+	w.U8(h.SerializationVersion)
+	w.U16(h.CompressionMode)
+	w.U16(h.ProtocolVersion)
+	w.U32(h.AuthMechanisms)
+	w.String16(h.LegacyName)
+	return w
+}
+
+// AuthAccept is TOCLIENT_AUTH_ACCEPT, sent once the player is authenticated
+type AuthAccept struct {
+	Position           [3]float32
+	MapSeed            uint64
+	SendInterval       float32
+	SudoAuthMechanisms uint32
+}
+
+func ReadAuthAccept(r *network.Reader) (AuthAccept, error) {
+	a := AuthAccept{
+		Position:           r.V3F32(),
+		MapSeed:            r.U64(),
+		SendInterval:       r.F32(),
+		SudoAuthMechanisms: r.U32(),
+	}
+	return a, r.Err()
+}
+
+func (a AuthAccept) Write() *network.Writer {
+	w := network.NewWriter(network.ToClientAuthAccept)
+	w.V3F32(a.Position)
+	w.U64(a.MapSeed)
+	w.F32(a.SendInterval)
+	w.U32(a.SudoAuthMechanisms)
+	return w
+}
+
+// AccessDenied is TOCLIENT_ACCESS_DENIED. Reason is filled in from the code when the server gives none.
+type AccessDenied struct {
+	Code      uint8
+	Reason    string
+	Reconnect bool
+}
+
+func ReadAccessDenied(r *network.Reader) (AccessDenied, error) {
+	d := AccessDenied{Code: r.U8()}
+	if r.Err() != nil {
+		return d, r.Err()
+	}
+	if r.Len() > 0 {
+		d.Reason = r.String16()
+	}
+	if d.Reason == "" {
+		if int(d.Code) < len(network.AccessDeniedStrings) {
+			d.Reason = network.AccessDeniedStrings[d.Code]
+		} else {
+			d.Reason = "Unknown"
+		}
+	}
+	if d.Code == network.AccessDeniedTooManyUsers {
+		d.Reconnect = true
+	} else if r.Len() > 0 {
+		d.Reconnect = r.U8()&1 != 0
+	}
+	return d, r.Err()
+}
+
+func (d AccessDenied) Write() *network.Writer {
+	w := network.NewWriter(network.ToClientAccessDenied)
+	w.U8(d.Code)
+	w.String16(d.Reason)
+	w.Bool(d.Reconnect)
+	return w
+}
+
+// SRPBytesSB is TOCLIENT_SRP_BYTES_S_B, the server's SRP challenge
+type SRPBytesSB struct {
+	Salt   []byte
+	BytesB []byte
+}
+
+func ReadSRPBytesSB(r *network.Reader) (SRPBytesSB, error) {
+	s := SRPBytesSB{
+		Salt:   []byte(r.String16()),
+		BytesB: []byte(r.String16()),
+	}
+	return s, r.Err()
+}
+
+func (s SRPBytesSB) Write() *network.Writer {
+	w := network.NewWriter(network.ToClientSRPBytesSB)
+	w.String16(string(s.Salt))
+	w.String16(string(s.BytesB))
+	return w
+}
+
+// MediaAnnouncement names one file offered in TOCLIENT_ANNOUNCE_MEDIA
+type MediaAnnouncement struct {
+	Name string
+	SHA1 []byte
+}
+
+// AnnounceMedia is TOCLIENT_ANNOUNCE_MEDIA, the list of media the server has
+type AnnounceMedia struct {
+	Files         []MediaAnnouncement
+	RemoteServers []string // Not sent by older servers
+}
+
+func ReadAnnounceMedia(r *network.Reader) (AnnounceMedia, error) {
+	var a AnnounceMedia
+	count := int(r.U16())
+	for i := 0; i < count && r.Err() == nil; i++ {
+		name := r.String16()
+		sha, err := base64.StdEncoding.DecodeString(r.String16())
+		if err != nil {
+			return a, fmt.Errorf("media file %q: %w", name, err)
+		}
+		a.Files = append(a.Files, MediaAnnouncement{Name: name, SHA1: sha})
+	}
+	if r.Err() != nil {
+		return a, r.Err()
+	}
+
+	if r.Len() > 0 {
+		for _, url := range strings.Split(r.String16(), ",") {
+			if url = strings.TrimSpace(url); url != "" {
+				a.RemoteServers = append(a.RemoteServers, url)
+			}
+		}
+	}
+	return a, r.Err()
+}
+
+func (a AnnounceMedia) Write() *network.Writer {
+	w := network.NewWriter(network.ToClientAnnounceMedia)
+	w.U16(uint16(len(a.Files)))
+	for _, f := range a.Files {
+		w.String16(f.Name)
+		w.String16(base64.StdEncoding.EncodeToString(f.SHA1))
+	}
+	w.String16(strings.Join(a.RemoteServers, ","))
+	return w
+}
+
+// MediaFile is one file sent in TOCLIENT_MEDIA
+type MediaFile struct {
+	Name string
+	Data []byte
+}
+
+// Media is TOCLIENT_MEDIA, one bunch of requested files
+type Media struct {
+	Bunches uint16
+	Bunch   uint16
+	Files   []MediaFile
+}
+
+func ReadMedia(r *network.Reader) (Media, error) {
+	m := Media{
+		Bunches: r.U16(),
+		Bunch:   r.U16(),
+	}
+	count := int(r.U32())
+	for i := 0; i < count && r.Err() == nil; i++ {
+		name := r.String16()
+		data := r.String32()
+		if r.Err() != nil {
+			break
+		}
+		m.Files = append(m.Files, MediaFile{Name: name, Data: []byte(data)})
+	}
+	return m, r.Err()
+}
+
+func (m Media) Write() *network.Writer {
+	w := network.NewWriter(network.ToClientMedia)
+	w.U16(m.Bunches)
+	w.U16(m.Bunch)
+	w.U32(uint32(len(m.Files)))
+	for _, f := range m.Files {
+		w.String16(f.Name)
+		w.String32(string(f.Data))
+	}
+	return w
+}
+
+// ReadNodeDef decodes TOCLIENT_NODEDEF into the node definitions of the server
+func ReadNodeDef(r *network.Reader) (*blocktypes.NodeDefManager, error) {
+	compressed := r.String32()
+	if r.Err() != nil {
+		return nil, r.Err()
+	}
+	nodeDefs := blocktypes.NewNodeDefManager()
+	if err := nodeDefs.Deserialize([]byte(compressed)); err != nil {
+		return nil, err
+	}
+	return nodeDefs, nil
+}
+
+// BlockData is TOCLIENT_BLOCKDATA, one block of the map
+type BlockData struct {
+	Position [3]int16
+	Block    *meshbuilder.MapBlock // Placed at Position
+}
+
+// ReadBlockData decodes a block in the serialization version negotiated in TOCLIENT_HELLO
+func ReadBlockData(r *network.Reader, serializationVersion uint8) (BlockData, error) {
+	b := BlockData{Position: r.V3S16()}
+	data := r.Remaining()
+	if r.Err() != nil {
+		return b, r.Err()
+	}
+	if len(data) < 1 {
+		return b, ErrEmptyBlockData
+	}
+
+	// The block is followed by one byte of network specific data
+	block, err := meshbuilder.DeserializeMapBlock(data[:len(data)-1], serializationVersion, false)
+	if err != nil {
+		return b, err
+	}
+	pos := b.Position
+	block.SetCoordinates(int32(pos[0])*meshbuilder.ChunkSize, int32(pos[1])*meshbuilder.ChunkSize, int32(pos[2])*meshbuilder.ChunkSize)
+	b.Block = block
+	return b, nil
+}
+
+// ReadChatMessage decodes TOCLIENT_CHAT_MESSAGE
+func ReadChatMessage(r *network.Reader) (chat.Message, error) {
+	if version := r.U8(); r.Err() == nil && version != chatMessageVersion {
+		return chat.Message{}, fmt.Errorf("unsupported chat message version %d", version)
+	}
+	msg := chat.Message{
+		Type:   chat.Type(r.U8()),
+		Sender: r.WideString(),
+		Text:   r.WideString(),
+	}
+	msg.Timestamp = time.Unix(int64(r.U64()), 0)
+	return msg, r.Err()
+}
+
+// WriteChatMessage encodes TOCLIENT_CHAT_MESSAGE
+func WriteChatMessage(msg chat.Message) *network.Writer {
+	w := network.NewWriter(network.ToClientChatMessage)
+	w.U8(chatMessageVersion)
+	w.U8(uint8(msg.Type))
+	w.WideString(msg.Sender)
+	w.WideString(msg.Text)
+	w.U64(uint64(msg.Timestamp.Unix()))
+	return w
+}
+
+// MovePlayer is TOCLIENT_MOVE_PLAYER, which puts the player where the server wants it.
+// The position is in protocol units, angles in degrees.
+type MovePlayer struct {
+	Position [3]float32
+	Pitch    float32
+	Yaw      float32
+}
+
+func ReadMovePlayer(r *network.Reader) (MovePlayer, error) {
+	m := MovePlayer{
+		Position: r.V3F32(),
+		Pitch:    r.F32(),
+		Yaw:      r.F32(),
+	}
+	return m, r.Err()
+}
+
+func (m MovePlayer) Write() *network.Writer {
+	w := network.NewWriter(network.ToClientMovePlayer)
+	w.V3F32(m.Position)
+	w.F32(m.Pitch)
+	w.F32(m.Yaw)
+	return w
+}
+
+// ReadPrivileges decodes TOCLIENT_PRIVILEGES, the names of the privileges the player has
+func ReadPrivileges(r *network.Reader) ([]string, error) {
+	count := int(r.U16())
+	var privileges []string
+	for i := 0; i < count && r.Err() == nil; i++ {
+		privileges = append(privileges, r.String16())
+	}
+	return privileges, r.Err()
+}
+
+// WritePrivileges encodes TOCLIENT_PRIVILEGES
+func WritePrivileges(privileges []string) *network.Writer {
+	w := network.NewWriter(network.ToClientPrivileges)
+	w.U16(uint16(len(privileges)))
+	for _, name := range privileges {
+		w.String16(name)
+	}
+	return w
+}
+
+// ReadMovement decodes TOCLIENT_MOVEMENT, the movement settings of the server. Unlike positions they are in nodes.
+func ReadMovement(r *network.Reader) (player.MovementSettings, error) {
+	m := player.MovementSettings{
+		AccelerationDefault:  r.F32(),
+		AccelerationAir:      r.F32(),
+		AccelerationFast:     r.F32(),
+		SpeedWalk:            r.F32(),
+		SpeedCrouch:          r.F32(),
+		SpeedFast:            r.F32(),
+		SpeedClimb:           r.F32(),
+		SpeedJump:            r.F32(),
+		LiquidFluidity:       r.F32(),
+		LiquidFluiditySmooth: r.F32(),
+		LiquidSink:           r.F32(),
+		Gravity:              r.F32(),
+	}
+	return m, r.Err()
+}
+
+// WriteMovement encodes TOCLIENT_MOVEMENT
+func WriteMovement(m player.MovementSettings) *network.Writer {
+	w := network.NewWriter(network.ToClientMovement)
+	for _, v := range []float32{
+		m.AccelerationDefault, m.AccelerationAir, m.AccelerationFast,
+		m.SpeedWalk, m.SpeedCrouch, m.SpeedFast, m.SpeedClimb, m.SpeedJump,
+		m.LiquidFluidity, m.LiquidFluiditySmooth, m.LiquidSink, m.Gravity,
+	} {
+		w.F32(v)
+	}
+	return w
+}
+
+// ReadItemDef decodes TOCLIENT_ITEMDEF into the item definitions and aliases of the server
+func ReadItemDef(r *network.Reader) (*inventory.ItemDefManager, error) {
+	compressed := r.String32()
+	if r.Err() != nil {
+		return nil, r.Err()
+	}
+	itemDefs := inventory.NewItemDefManager()
+	if err := itemDefs.Deserialize([]byte(compressed)); err != nil {
+		return nil, err
+	}
+	return itemDefs, nil
+}
+
+// ReadInventory returns the text form of the inventory sent in TOCLIENT_INVENTORY, which fills the whole packet
+func ReadInventory(r *network.Reader) (string, error) {
+	data := r.Remaining()
+	return string(data), r.Err()
+}
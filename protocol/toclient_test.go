@@ -0,0 +1,107 @@
+package protocol
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"bettermt/main/chat"
+	"bettermt/main/network"
+)
+
+// body checks the command a packet starts with and returns a reader for the rest
+func body(t *testing.T, w *network.Writer, command uint16) *network.Reader {
+	t.Helper()
+	r := network.NewReader(w.Bytes())
+	if got := r.U16(); got != command {
+		t.Fatalf("command %#x, want %#x", got, command)
+	}
+	return r
+}
+
+func TestToClientRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		command uint16
+		value   interface{ Write() *network.Writer }
+		read    func(r *network.Reader) (any, error)
+	}{
+		{
+			"Hello", network.ToClientHello,
+			Hello{SerializationVersion: 29, ProtocolVersion: 42, AuthMechanisms: network.AuthMechanismFirstSRP, LegacyName: "Alice"},
+			func(r *network.Reader) (any, error) { return ReadHello(r) },
+		},
+		{
+			"AuthAccept", network.ToClientAuthAccept,
+			AuthAccept{Position: [3]float32{10, 20.5, -30}, MapSeed: 1234, SendInterval: 0.09, SudoAuthMechanisms: network.AuthMechanismSRP},
+			func(r *network.Reader) (any, error) { return ReadAuthAccept(r) },
+		},
+		{
+			"AccessDenied", network.ToClientAccessDenied,
+			AccessDenied{Code: network.AccessDeniedCustomString, Reason: "Go away", Reconnect: true},
+			func(r *network.Reader) (any, error) { return ReadAccessDenied(r) },
+		},
+		{
+			"SRPBytesSB", network.ToClientSRPBytesSB,
+			SRPBytesSB{Salt: []byte{1, 2, 3}, BytesB: []byte{4, 5, 6, 7}},
+			func(r *network.Reader) (any, error) { return ReadSRPBytesSB(r) },
+		},
+		{
+			"AnnounceMedia", network.ToClientAnnounceMedia,
+			AnnounceMedia{
+				Files:         []MediaAnnouncement{{Name: "a.png", SHA1: make([]byte, 20)}, {Name: "b.ogg", SHA1: []byte{0xFF, 1}}},
+				RemoteServers: []string{"http://a.example/", "http://b.example/"},
+			},
+			func(r *network.Reader) (any, error) { return ReadAnnounceMedia(r) },
+		},
+		{
+			"Media", network.ToClientMedia,
+			Media{Bunches: 3, Bunch: 1, Files: []MediaFile{{Name: "a.png", Data: []byte("png")}, {Name: "b.ogg", Data: []byte("ogg")}}},
+			func(r *network.Reader) (any, error) { return ReadMedia(r) },
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.read(body(t, test.value.Write(), test.command))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.value) {
+				t.Fatalf("got %+v, want %+v", got, test.value)
+			}
+		})
+	}
+}
+
+func TestAccessDeniedDefaultReason(t *testing.T) {
+	d, err := ReadAccessDenied(body(t, AccessDenied{Code: network.AccessDeniedWrongPassword}.Write(), network.ToClientAccessDenied))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Reason != network.AccessDeniedStrings[network.AccessDeniedWrongPassword] || d.Reconnect {
+		t.Fatalf("got %+v", d)
+	}
+}
+
+func TestChatMessageRoundTrip(t *testing.T) {
+	msg := chat.Message{Type: chat.TypeNormal, Sender: "Alice", Text: "héllo ☃", Timestamp: time.Unix(1700000000, 0)}
+	got, err := ReadChatMessage(body(t, WriteChatMessage(msg), network.ToClientChatMessage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, msg) {
+		t.Fatalf("got %+v, want %+v", got, msg)
+	}
+}
+
+func TestPrivilegesRoundTrip(t *testing.T) {
+	for _, privileges := range [][]string{nil, {"interact", "shout", "fast"}} {
+		got, err := ReadPrivileges(body(t, WritePrivileges(privileges), network.ToClientPrivileges))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, privileges) {
+			t.Fatalf("got %q, want %q", got, privileges)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package protocol
+
+import (
+	"bettermt/main/network"
+)
+
+// Maximum number of positions in one TOSERVER_GOTBLOCKS or TOSERVER_DELETEDBLOCKS packet
+const MaxBlocksPerPacket = 255
+
+// Init is TOSERVER_INIT, which opens the login sequence
+type Init struct {
+	MaxSerializationVersion uint8
+	CompressionModes        uint16 // Unused
+	MinProtocolVersion      uint16
+	MaxProtocolVersion      uint16
+	Name                    string
+}
+
+func ReadInit(r *network.Reader) (Init, error) {
+	i := Init{
+		MaxSerializationVersion: r.U8(),
+		CompressionModes:        r.U16(),
+		MinProtocolVersion:      r.U16(),
+		MaxProtocolVersion:      r.U16(),
+		Name:                    r.String16(),
+	}
+	return i, r.Err()
+}
+
+func (i Init) Write() *network.Writer {
+	w := network.NewWriter(network.ToServerInit)
+	w.U8(i.MaxSerializationVersion)
+	w.U16(i.CompressionModes)
+	w.U16(i.MinProtocolVersion)
+	w.U16(i.MaxProtocolVersion)
+	w.String16(i.Name)
+	return w
+}
+
+// FirstSRP is TOSERVER_FIRST_SRP, which registers a new account with its verifier
+type FirstSRP struct {
+	Salt          []byte
+	Verifier      []byte
+	EmptyPassword bool
+}
+
+func ReadFirstSRP(r *network.Reader) (FirstSRP, error) {
+	f := FirstSRP{
+		Salt:          []byte(r.String16()),
+		Verifier:      []byte(r.String16()),
+		EmptyPassword: r.Bool(),
+	}
+	return f, r.Err()
+}
+
+func (f FirstSRP) Write() *network.Writer {
+	w := network.NewWriter(network.ToServerFirstSRP)
+	w.String16(string(f.Salt))
+	w.String16(string(f.Verifier))
+	w.Bool(f.EmptyPassword)
+	return w
+}
+
+// SRPBytesA is TOSERVER_SRP_BYTES_A, which starts an SRP exchange
+type SRPBytesA struct {
+	BytesA []byte
+	// 1 if the verifier was derived from the password, 0 if from the legacy password hash
+	BasedOn uint8
+}
+
+func ReadSRPBytesA(r *network.Reader) (SRPBytesA, error) {
+	s := SRPBytesA{
+		BytesA:  []byte(r.String16()),
+		BasedOn: r.U8(),
+	}
+	return s, r.Err()
+}
+
+func (s SRPBytesA) Write() *network.Writer {
+	w := network.NewWriter(network.ToServerSRPBytesA)
+	w.String16(string(s.BytesA))
+	w.U8(s.BasedOn)
+	return w
+}
+
+// SRPBytesM is TOSERVER_SRP_BYTES_M, the client's proof of the password
+type SRPBytesM struct {
+	M []byte
+}
+
+func ReadSRPBytesM(r *network.Reader) (SRPBytesM, error) {
+	s := SRPBytesM{M: []byte(r.String16())}
+	return s, r.Err()
+}
+
+func (s SRPBytesM) Write() *network.Writer {
+	w := network.NewWriter(network.ToServerSRPBytesM)
+	w.String16(string(s.M))
+	return w
+}
+
+// Init2 is TOSERVER_INIT2, which asks for the game data after authentication
+type Init2 struct {
+	Language string
+}
+
+func ReadInit2(r *network.Reader) (Init2, error) {
+	var i Init2
+	// Older clients do not send a language
+	if r.Len() > 0 {
+		i.Language = r.String16()
+	}
+	return i, r.Err()
+}
+
+func (i Init2) Write() *network.Writer {
+	w := network.NewWriter(network.ToServerInit2)
+	w.String16(i.Language)
+	return w
+}
+
+// RequestMedia is TOSERVER_REQUEST_MEDIA, the names of the files the client is missing
+type RequestMedia struct {
+	Files []string
+}
+
+func ReadRequestMedia(r *network.Reader) (RequestMedia, error) {
+	var m RequestMedia
+	count := int(r.U16())
+	for i := 0; i < count && r.Err() == nil; i++ {
+		m.Files = append(m.Files, r.String16())
+	}
+	return m, r.Err()
+}
+
+func (m RequestMedia) Write() *network.Writer {
+	w := network.NewWriter(network.ToServerRequestMedia)
+	w.U16(uint16(len(m.Files)))
+	for _, name := range m.Files {
+		w.String16(name)
+	}
+	return w
+}
+
+// BlockList is the body of TOSERVER_GOTBLOCKS and TOSERVER_DELETEDBLOCKS
+type BlockList struct {
+	Positions [][3]int16
+}
+
+func ReadBlockList(r *network.Reader) (BlockList, error) {
+	var b BlockList
+	count := int(r.U8())
+	for i := 0; i < count && r.Err() == nil; i++ {
+		b.Positions = append(b.Positions, r.V3S16())
+	}
+	return b, r.Err()
+}
+
+// Write builds one packet of the given command, which holds at most MaxBlocksPerPacket positions
+func (b BlockList) Write(command uint16) *network.Writer {
+	w := network.NewWriter(command)
+	w.U8(uint8(len(b.Positions)))
+	for _, pos := range b.Positions {
+		w.V3S16(pos)
+	}
+	return w
+}
+
+// PlayerChatMessage is TOSERVER_CHAT_MESSAGE, a line typed by the player
+type PlayerChatMessage struct {
+	Text string
+}
+
+func ReadPlayerChatMessage(r *network.Reader) (PlayerChatMessage, error) {
+	m := PlayerChatMessage{Text: r.WideString()}
+	return m, r.Err()
+}
+
+func (m PlayerChatMessage) Write() *network.Writer {
+	w := network.NewWriter(network.ToServerChatMessage)
+	w.WideString(m.Text)
+	return w
+}
+
+// ClientReady is TOSERVER_CLIENT_READY, sent once the client is in game
+type ClientReady struct {
+	VersionMajor    uint8
+	VersionMinor    uint8
+	VersionPatch    uint8
+	VersionString   string
+	FormspecVersion uint16
+}
+
+func ReadClientReady(r *network.Reader) (ClientReady, error) {
+	c := ClientReady{
+		VersionMajor: r.U8(),
+		VersionMinor: r.U8(),
+		VersionPatch: r.U8(),
+	}
+	r.U8() // Reserved
+	c.VersionString = r.String16()
+	// Older clients do not send their formspec version
+	if r.Len() > 0 {
+		c.FormspecVersion = r.U16()
+	}
+	return c, r.Err()
+}
+
+func (c ClientReady) Write() *network.Writer {
+	w := network.NewWriter(network.ToServerClientReady)
+	w.U8(c.VersionMajor).U8(c.VersionMinor).U8(c.VersionPatch)
+	w.U8(0) // Reserved
+	w.String16(c.VersionString)
+	w.U16(c.FormspecVersion)
+	return w
+}
+
+// PlayerPos is TOSERVER_PLAYERPOS, the state of the local player sent at the server's send interval.
+// Positions and speeds are in protocol units, angles in degrees.
+type PlayerPos struct {
+	Position    [3]float32
+	Speed       [3]float32
+	Pitch       float32
+	Yaw         float32
+	Keys        uint32
+	FOV         float32 // In radians
+	WantedRange uint8   // In blocks
+	// Sent by newer clients only
+	CameraInverted    bool
+	MovementSpeed     float32
+	MovementDirection float32
+}
+
+func ReadPlayerPos(r *network.Reader) (PlayerPos, error) {
+	return readPlayerPosFields(r)
+}
+
+// readPlayerPosFields reads the player state that also ends TOSERVER_INTERACT
+func readPlayerPosFields(r *network.Reader) (PlayerPos, error) {
+	position := r.V3S32()
+	speed := r.V3S32()
+	p := PlayerPos{
+		Pitch:       float32(r.S32()) / 100,
+		Yaw:         float32(r.S32()) / 100,
+		Keys:        r.U32(),
+		FOV:         float32(r.U8()) / 80,
+		WantedRange: r.U8(),
+	}
+	for i := range position {
+		p.Position[i] = float32(position[i]) / 100
+		p.Speed[i] = float32(speed[i]) / 100
+	}
+	if r.Len() >= 1 {
+		p.CameraInverted = r.U8()&1 != 0
+	}
+	if r.Len() >= 8 {
+		p.MovementSpeed = r.F32()
+		p.MovementDirection = r.F32()
+	}
+	return p, r.Err()
+}
+
+func (p PlayerPos) Write() *network.Writer {
+	w := network.NewWriter(network.ToServerPlayerPos)
+	p.writeFields(w)
+	return w
+}
+
+func (p PlayerPos) writeFields(w *network.Writer) {
+	var position, speed [3]int32
+	for i := range position {
+		position[i] = int32(p.Position[i] * 100)
+		speed[i] = int32(p.Speed[i] * 100)
+	}
+	w.V3S32(position)
+	w.V3S32(speed)
+	w.S32(int32(p.Pitch * 100))
+	w.S32(int32(p.Yaw * 100))
+	w.U32(p.Keys)
+	w.U8(uint8(max(0, min(p.FOV*80, 255))))
+	w.U8(p.WantedRange)
+	var bits uint8
+	if p.CameraInverted {
+		bits |= 1
+	}
+	w.U8(bits)
+	w.F32(p.MovementSpeed)
+	w.F32(p.MovementDirection)
+}
+
+// PlayerItem is TOSERVER_PLAYERITEM, the hotbar slot the player wields
+type PlayerItem struct {
+	Index uint16
+}
+
+func ReadPlayerItem(r *network.Reader) (PlayerItem, error) {
+	p := PlayerItem{Index: r.U16()}
+	return p, r.Err()
+}
+
+func (p PlayerItem) Write() *network.Writer {
+	w := network.NewWriter(network.ToServerPlayerItem)
+	w.U16(p.Index)
+	return w
+}
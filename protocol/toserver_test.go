@@ -0,0 +1,117 @@
+package protocol
+
+import (
+	"testing"
+
+	"bettermt/main/network"
+)
+
+func TestPlayerPosEncoding(t *testing.T) {
+	p := PlayerPos{
+		Position:          [3]float32{1.234, -5.678, 1000},
+		Speed:             [3]float32{0.5, -0.019, 0},
+		Pitch:             -12.345,
+		Yaw:               359.99,
+		Keys:              0x1FF,
+		FOV:               1.2,
+		WantedRange:       7,
+		CameraInverted:    true,
+		MovementSpeed:     0.75,
+		MovementDirection: -1.5,
+	}
+	r := body(t, p.Write(), network.ToServerPlayerPos)
+	// Positions, speeds and angles are sent in hundredths, cut towards zero
+	if got := r.V3S32(); got != [3]int32{123, -567, 100000} {
+		t.Errorf("position %v", got)
+	}
+	if got := r.V3S32(); got != [3]int32{50, -1, 0} {
+		t.Errorf("speed %v", got)
+	}
+	if pitch, yaw := r.S32(), r.S32(); pitch != -1234 || yaw != 35999 {
+		t.Errorf("pitch %d, yaw %d", pitch, yaw)
+	}
+	if keys := r.U32(); keys != 0x1FF {
+		t.Errorf("keys %#x", keys)
+	}
+	if fov := r.U8(); fov != 96 {
+		t.Errorf("fov %d, want 1.2*80", fov)
+	}
+	if wanted := r.U8(); wanted != 7 {
+		t.Errorf("wanted range %d", wanted)
+	}
+	if bits := r.U8(); bits != 1 {
+		t.Errorf("camera bits %#x", bits)
+	}
+	if speed, direction := r.F32(), r.F32(); speed != 0.75 || direction != -1.5 {
+		t.Errorf("movement %v, %v", speed, direction)
+	}
+	if r.Err() != nil || r.Len() != 0 {
+		t.Errorf("%d bytes left, %v", r.Len(), r.Err())
+	}
+}
+
+func TestPlayerPosFOVClamped(t *testing.T) {
+	for _, test := range []struct {
+		fov  float32
+		want uint8
+	}{
+		{0, 0},
+		{3.1875, 255},
+		{3.2, 255},
+		{10, 255},
+		{-1, 0},
+	} {
+		r := body(t, PlayerPos{FOV: test.fov}.Write(), network.ToServerPlayerPos)
+		r.V3S32()
+		r.V3S32()
+		r.S32()
+		r.S32()
+		r.U32()
+		if got := r.U8(); got != test.want {
+			t.Errorf("fov %v sent as %d, want %d", test.fov, got, test.want)
+		}
+	}
+}
+
+func TestPlayerPosRoundTrip(t *testing.T) {
+	// Values in whole hundredths that floats hold exactly come back unchanged
+	want := PlayerPos{
+		Position:          [3]float32{10.25, -205.5, 3000},
+		Speed:             [3]float32{0.5, -1.75, 0},
+		Pitch:             45.5,
+		Yaw:               270.25,
+		Keys:              0x15,
+		FOV:               1.25,
+		WantedRange:       12,
+		CameraInverted:    true,
+		MovementSpeed:     1,
+		MovementDirection: 0.25,
+	}
+	data := want.Write().Bytes()
+	got, err := ReadPlayerPos(body(t, want.Write(), network.ToServerPlayerPos))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+
+	// Older clients end after the wanted range
+	old := network.NewReader(data[2 : len(data)-9])
+	got, err = ReadPlayerPos(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want.CameraInverted, want.MovementSpeed, want.MovementDirection = false, 0, 0
+	if got != want {
+		t.Fatalf("older client: got %+v, want %+v", got, want)
+	}
+}
+
+func TestMovePlayerRoundTrip(t *testing.T) {
+	want := MovePlayer{Position: [3]float32{100, 205.5, -30}, Pitch: -20, Yaw: 90.5}
+	got, err := ReadMovePlayer(body(t, want.Write(), network.ToClientMovePlayer))
+	if err != nil || got != want {
+		t.Fatalf("got %+v, %v, want %+v", got, err, want)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"bettermt/main/capture"
+	"bettermt/main/proxy"
+)
+
+// runProxy forwards clients to an upstream server and logs what passes, as `bettermt proxy`
+func runProxy(args []string) error {
+	flags := flag.NewFlagSet("proxy", flag.ExitOnError)
+	listen := flags.String("listen", ":30001", "Address clients connect to")
+	upstream := flags.String("upstream", "", "Server packets are forwarded to (host:port)")
+	quiet := flags.Bool("quiet", false, "Do not print forwarded packets")
+	record := flags.String("record", "", "Write every forwarded packet to a capture file")
+	drop := flags.Float64("drop", 0, "Fraction of packets dropped, from 0 to 1")
+	delay := flags.Duration("delay", 0, "Time every packet is held back")
+	jitter := flags.Duration("jitter", 0, "Random extra delay of up to this long")
+	flags.Parse(args)
+	if *upstream == "" {
+		return errors.New("usage: bettermt proxy --upstream host:port [--listen :30001]")
+	}
+
+	p := proxy.New(*upstream)
+	if !*quiet {
+		p.Log = os.Stdout
+	}
+	if *drop > 0 || *delay > 0 || *jitter > 0 {
+		p.Hook = proxy.NewFaults(*drop, *delay, *jitter, time.Now().UnixNano())
+	}
+	if *record != "" {
+		file, err := os.Create(*record)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		if p.Capture, err = capture.NewWriter(file); err != nil {
+			return err
+		}
+	}
+
+	if err := p.Listen(*listen); err != nil {
+		return err
+	}
+	defer p.Close()
+	fmt.Printf("Forwarding %s to %s\n", p.Addr(), *upstream)
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	<-interrupt
+	return nil
+}
@@ -0,0 +1,73 @@
+package proxy
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Hook decides what the proxy forwards
+type Hook interface {
+	// Forward is called for every packet in the order it was received. It returns the packets to send
+	// on in its place: the packet itself to pass it through, a modified copy to rewrite it, none to drop it.
+	Forward(s *Session, p Packet) []Packet
+}
+
+// HookFunc adapts a function to the Hook interface
+type HookFunc func(s *Session, p Packet) []Packet
+
+func (f HookFunc) Forward(s *Session, p Packet) []Packet {
+	return f(s, p)
+}
+
+// Chain passes packets through several hooks, each one seeing what the previous one forwarded
+type Chain []Hook
+
+func (c Chain) Forward(s *Session, p Packet) []Packet {
+	packets := []Packet{p}
+	for _, h := range c {
+		var next []Packet
+		for _, p := range packets {
+			next = append(next, h.Forward(s, p)...)
+		}
+		packets = next
+	}
+	return packets
+}
+
+// Faults drops and delays packets at random to test how peers cope with a bad network.
+// Since the proxy acknowledges packets itself, dropped reliable packets are never resent.
+type Faults struct {
+	// Fraction of packets dropped, from 0 to 1
+	DropRate float64
+	// Time every packet is held back, plus a random part of up to Jitter. Packets still leave in order
+	// on each channel, so jitter varies the gaps between them.
+	Delay  time.Duration
+	Jitter time.Duration
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// NewFaults creates a fault injector with its own random source
+func NewFaults(dropRate float64, delay, jitter time.Duration, seed int64) *Faults {
+	return &Faults{
+		DropRate: dropRate,
+		Delay:    delay,
+		Jitter:   jitter,
+		rng:      rand.New(rand.NewSource(seed)),
+	}
+}
+
+func (f *Faults) Forward(s *Session, p Packet) []Packet {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.DropRate > 0 && f.rng.Float64() < f.DropRate {
+		return nil
+	}
+	p.Delay += f.Delay
+	if f.Jitter > 0 {
+		p.Delay += time.Duration(f.rng.Int63n(int64(f.Jitter)))
+	}
+	return []Packet{p}
+}
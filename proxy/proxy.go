@@ -0,0 +1,238 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"bettermt/main/capture"
+	"bettermt/main/dissect"
+	"bettermt/main/network"
+)
+
+// Direction tells which way a packet travels through the proxy
+type Direction uint8
+
+const (
+	ToServer Direction = iota
+	ToClient
+)
+
+func (d Direction) String() string {
+	if d == ToClient {
+		return "server->client"
+	}
+	return "client->server"
+}
+
+// Packet is one payload passing through the proxy
+type Packet struct {
+	Direction Direction
+	Channel   uint8
+	Reliable  bool
+	Data      []byte
+	// How long to hold the packet back before sending it on. Packets of one direction and channel leave
+	// in order, so this also holds back the packets behind it.
+	Delay time.Duration
+}
+
+// Proxy sits between Minetest clients and an upstream server. Each side has its own reliable UDP
+// connection; payloads are passed from one to the other through an optional hook.
+type Proxy struct {
+	Upstream string
+	// Hook may rewrite, drop or delay packets, nil forwards everything unchanged
+	Hook Hook
+	// Log receives every forwarded packet in dissected form, it may be nil
+	Log io.Writer
+	// Capture records every forwarded packet as seen by the client, it may be nil
+	Capture *capture.Writer
+
+	listener *network.Listener
+	logMu    sync.Mutex
+
+	mu       sync.Mutex
+	sessions map[*Session]bool
+	nextID   int
+}
+
+// New creates a proxy that forwards clients to the upstream server at the given host:port address
+func New(upstream string) *Proxy {
+	return &Proxy{
+		Upstream: upstream,
+		sessions: make(map[*Session]bool),
+		nextID:   1,
+	}
+}
+
+// Listen starts accepting clients on a UDP address such as :30001
+func (p *Proxy) Listen(address string) error {
+	l, err := network.Listen(address)
+	if err != nil {
+		return err
+	}
+	p.listener = l
+	go p.acceptLoop()
+	return nil
+}
+
+// Addr returns the address clients connect to
+func (p *Proxy) Addr() net.Addr {
+	return p.listener.Addr()
+}
+
+// Close ends every session and stops accepting clients
+func (p *Proxy) Close() error {
+	p.mu.Lock()
+	sessions := make([]*Session, 0, len(p.sessions))
+	for s := range p.sessions {
+		sessions = append(sessions, s)
+	}
+	p.mu.Unlock()
+	for _, s := range sessions {
+		s.Close()
+	}
+	return p.listener.Close()
+}
+
+// acceptLoop opens an upstream connection for every new client
+func (p *Proxy) acceptLoop() {
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			return
+		}
+		upstream, err := network.Dial(p.Upstream)
+		if err != nil {
+			logf("cannot reach %s for %s: %v", p.Upstream, conn.RemoteAddr(), err)
+			conn.Close()
+			continue
+		}
+
+		p.mu.Lock()
+		s := &Session{
+			ID:        p.nextID,
+			proxy:     p,
+			client:    conn,
+			upstream:  upstream,
+			dissector: dissect.New(),
+			start:     time.Now(),
+			done:      make(chan struct{}),
+		}
+		for dir := range s.queues {
+			for ch := range s.queues[dir] {
+				s.queues[dir][ch] = newDelayQueue(s.forward, s.done)
+			}
+		}
+		p.nextID++
+		p.sessions[s] = true
+		p.mu.Unlock()
+
+		logf("session %d: %s connected", s.ID, conn.RemoteAddr())
+		go s.pump(conn, ToServer)
+		go s.pump(upstream, ToClient)
+	}
+}
+
+// removeSession forgets a closed session
+func (p *Proxy) removeSession(s *Session) {
+	p.mu.Lock()
+	delete(p.sessions, s)
+	p.mu.Unlock()
+}
+
+// Session is one client connected through the proxy along with its upstream connection
+type Session struct {
+	ID int
+
+	proxy     *Proxy
+	client    *network.Conn
+	upstream  *network.Conn
+	dissector *dissect.Dissector // Only used with logMu of the proxy held
+	start     time.Time
+	// Delayed packets waiting to be forwarded, by direction and channel
+	queues    [2][network.ChannelCount]*delayQueue
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// ClientAddr returns the address of the client
+func (s *Session) ClientAddr() net.Addr {
+	return s.client.RemoteAddr()
+}
+
+// Close disconnects both sides of the session
+func (s *Session) Close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.client.Close()
+		s.upstream.Close()
+		s.proxy.removeSession(s)
+		logf("session %d: closed", s.ID)
+	})
+}
+
+// pump receives packets from one side and forwards them to the other until either side disconnects
+func (s *Session) pump(from *network.Conn, dir Direction) {
+	defer s.Close()
+	for {
+		p, err := from.Recv()
+		if err != nil {
+			return
+		}
+		pkt := Packet{Direction: dir, Channel: p.Channel, Reliable: p.Reliable, Data: p.Data}
+		out := []Packet{pkt}
+		if s.proxy.Hook != nil {
+			out = s.proxy.Hook.Forward(s, pkt)
+		}
+		for _, o := range out {
+			if int(o.Channel) >= network.ChannelCount {
+				logf("session %d: dropping packet %s on invalid channel %d", s.ID, o.Direction, o.Channel)
+				continue
+			}
+			s.queues[o.Direction][o.Channel].push(o)
+		}
+	}
+}
+
+// forward logs a packet and sends it to the side it is addressed to
+func (s *Session) forward(p Packet) {
+	s.log(p)
+	to := s.upstream
+	if p.Direction == ToClient {
+		to = s.client
+	}
+	if err := to.Send(p.Channel, p.Data, p.Reliable); err != nil {
+		logf("session %d: failed to forward packet %s: %v", s.ID, p.Direction, err)
+	}
+}
+
+// log dissects a forwarded packet and records it, as seen by the client
+func (s *Session) log(p Packet) {
+	if s.proxy.Log == nil && s.proxy.Capture == nil {
+		return
+	}
+	rec := capture.Record{Time: time.Now(), Direction: capture.Outbound, Channel: p.Channel, Reliable: p.Reliable, Data: p.Data}
+	if p.Direction == ToClient {
+		rec.Direction = capture.Inbound
+	}
+	if s.proxy.Capture != nil {
+		if err := s.proxy.Capture.Write(rec); err != nil {
+			logf("failed to record packet: %v", err)
+		}
+	}
+	if s.proxy.Log == nil {
+		return
+	}
+
+	s.proxy.logMu.Lock()
+	defer s.proxy.logMu.Unlock()
+	header := fmt.Sprintf("[%d] %+.3fs %s ch%d", s.ID, rec.Time.Sub(s.start).Seconds(), p.Direction, p.Channel)
+	s.dissector.Dissect(rec).Write(s.proxy.Log, header)
+}
+
+// logf prints a proxy message
+func logf(format string, args ...interface{}) {
+	fmt.Printf("[proxy] "+format+"\n", args...)
+}
@@ -0,0 +1,83 @@
+package proxy
+
+import (
+	"sync"
+	"time"
+)
+
+// delayed is a packet waiting in a delayQueue
+type delayed struct {
+	packet Packet
+	due    time.Time
+}
+
+// delayQueue holds back the packets of one direction and channel. They leave in the order they were
+// pushed, each once its delay has passed and never before the packet ahead of it, so that differing
+// delays stretch the gaps between packets instead of reordering them.
+type delayQueue struct {
+	send func(Packet)
+	done <-chan struct{}
+
+	mu      sync.Mutex
+	pending []delayed
+	lastDue time.Time
+	wake    chan struct{}
+}
+
+// newDelayQueue starts a queue that passes packets to send until done is closed
+func newDelayQueue(send func(Packet), done <-chan struct{}) *delayQueue {
+	q := &delayQueue{send: send, done: done, wake: make(chan struct{}, 1)}
+	go q.run()
+	return q
+}
+
+// push queues a packet to be sent after its delay
+func (q *delayQueue) push(p Packet) {
+	q.mu.Lock()
+	due := time.Now().Add(p.Delay)
+	if due.Before(q.lastDue) {
+		due = q.lastDue
+	}
+	q.lastDue = due
+	q.pending = append(q.pending, delayed{packet: p, due: due})
+	q.mu.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run sends the packets as they become due
+func (q *delayQueue) run() {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		q.mu.Lock()
+		if len(q.pending) == 0 {
+			q.mu.Unlock()
+			select {
+			case <-q.wake:
+				continue
+			case <-q.done:
+				return
+			}
+		}
+		next := q.pending[0]
+		q.mu.Unlock()
+
+		if wait := time.Until(next.due); wait > 0 {
+			timer.Reset(wait)
+			select {
+			case <-timer.C:
+			case <-q.done:
+				return
+			}
+		}
+
+		q.mu.Lock()
+		q.pending = q.pending[1:]
+		q.mu.Unlock()
+		q.send(next.packet)
+	}
+}
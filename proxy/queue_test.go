@@ -0,0 +1,53 @@
+package proxy
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDelayQueueKeepsOrder(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+	type sent struct {
+		index int
+		at    time.Time
+	}
+	out := make(chan sent, 10)
+	q := newDelayQueue(func(p Packet) { out <- sent{int(p.Data[0]), time.Now()} }, done)
+
+	// Later packets with shorter delays wait for the ones ahead of them
+	delays := []time.Duration{60 * time.Millisecond, 0, 20 * time.Millisecond, 90 * time.Millisecond, 0}
+	start := time.Now()
+	for i, d := range delays {
+		q.push(Packet{Data: []byte{byte(i)}, Delay: d})
+	}
+
+	var earliest time.Duration
+	for i, d := range delays {
+		select {
+		case s := <-out:
+			if s.index != i {
+				t.Fatalf("packet %d sent in place of %d", s.index, i)
+			}
+			earliest = max(earliest, d)
+			if waited := s.at.Sub(start); waited < earliest {
+				t.Fatalf("packet %d sent after %v, before %v", i, waited, earliest)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("packet %d never sent", i)
+		}
+	}
+}
+
+func TestDelayQueueStopsWhenDone(t *testing.T) {
+	done := make(chan struct{})
+	out := make(chan Packet, 1)
+	q := newDelayQueue(func(p Packet) { out <- p }, done)
+	q.push(Packet{Delay: 50 * time.Millisecond})
+	close(done)
+	select {
+	case <-out:
+		t.Fatal("packet sent after the session closed")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
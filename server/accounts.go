@@ -0,0 +1,39 @@
+package server
+
+import (
+	"fmt"
+
+	"bettermt/main/auth"
+)
+
+// getAccount returns the registered account of a player
+func (s *Server) getAccount(name string) (account, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	acc, exists := s.accounts[name]
+	return acc, exists
+}
+
+// createAccount registers a player, failing if the name is taken
+func (s *Server) createAccount(name string, acc account) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.accounts[name]; exists {
+		return false
+	}
+	s.accounts[name] = acc
+	return true
+}
+
+// AddLegacyAccount registers a player migrated from a server that predates SRP, who logs in with a verifier
+// derived from the legacy hash of the password
+func (s *Server) AddLegacyAccount(name, password string) error {
+	salt, verifier, err := auth.CreateVerifier(name, auth.LegacyPassword(name, password))
+	if err != nil {
+		return err
+	}
+	if !s.createAccount(name, account{salt: salt, verifier: verifier, legacy: true}) {
+		return fmt.Errorf("account %s already exists", name)
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package server
+
+import (
+	"math"
+
+	"bettermt/main/blocktypes"
+	"bettermt/main/inventory"
+	"bettermt/main/network"
+	"bettermt/main/protocol"
+)
+
+// Farthest a player may dig or place from, in nodes. Larger than any item range to allow for lag.
+const maxInteractDistance = 10
+
+// handleInteract digs and places nodes for the player. Actions the server refuses resend the block
+// so the client undoes its prediction.
+func handleInteract(p *peer, r *network.Reader) error {
+	i, err := protocol.ReadInteract(r)
+	if err != nil {
+		return err
+	}
+	if p.getState() != peerReady {
+		return nil
+	}
+
+	p.mu.Lock()
+	p.position = i.Player.Position
+	p.wieldIndex = int(i.ItemIndex)
+	p.mu.Unlock()
+
+	if i.Pointed.Type != protocol.PointedNode {
+		return nil
+	}
+	switch i.Action {
+	case protocol.InteractDiggingCompleted:
+		if !p.dig(i.Pointed.Under) {
+			return p.sendBlock(blockOfNode(i.Pointed.Under))
+		}
+	case protocol.InteractPlace:
+		target, placed := p.place(i.Pointed)
+		if !placed {
+			return p.sendBlock(blockOfNode(target))
+		}
+	}
+	return nil
+}
+
+// dig removes a node and gives its item to the player, reporting whether the player could dig it
+func (p *peer) dig(pos [3]int16) bool {
+	s := p.server
+	if !p.inReach(pos) {
+		return false
+	}
+	def := s.nodeDefs.Get(s.getNode(pos))
+	if !def.Diggable {
+		return false
+	}
+	p.mu.Lock()
+	wielded := p.wieldedItem()
+	p.mu.Unlock()
+	if !inventory.GetDigParams(def.Groups, s.itemDefs.ToolCapabilities(wielded.Name)).Diggable {
+		return false
+	}
+
+	s.setNode(pos, blocktypes.ContentAir)
+	s.broadcastBlock(blockOfNode(pos))
+
+	if item, exists := s.itemDefs.Lookup(def.Name); exists {
+		p.mu.Lock()
+		addItem(p.inventory.List(inventory.MainList), item)
+		p.mu.Unlock()
+		p.sendInventory()
+	}
+	return true
+}
+
+// place puts the wielded node where the player points, returning the position it chose and whether it placed it
+func (p *peer) place(pointed protocol.PointedThing) ([3]int16, bool) {
+	s := p.server
+	target := pointed.Above
+	if s.nodeDefs.Get(s.getNode(pointed.Under)).BuildableTo {
+		target = pointed.Under
+	}
+	if !p.inReach(target) || !s.nodeDefs.Get(s.getNode(target)).BuildableTo {
+		return target, false
+	}
+
+	p.mu.Lock()
+	wielded := p.wieldedItem()
+	p.mu.Unlock()
+	if wielded.IsEmpty() || s.itemDefs.Get(wielded.Name).Type != inventory.ItemTypeNode {
+		return target, false
+	}
+	content, exists := s.nodeDefs.GetID(wielded.Name)
+	if !exists {
+		return target, false
+	}
+
+	s.setNode(target, content)
+	s.broadcastBlock(blockOfNode(target))
+
+	p.mu.Lock()
+	if stack := p.wieldedSlot(); stack != nil && stack.Name == wielded.Name {
+		stack.Count--
+		if stack.Count == 0 {
+			*stack = inventory.ItemStack{}
+		}
+	}
+	p.mu.Unlock()
+	p.sendInventory()
+	return target, true
+}
+
+// inReach reports whether a node is close enough to the player to act on
+func (p *peer) inReach(pos [3]int16) bool {
+	p.mu.Lock()
+	position := p.position
+	p.mu.Unlock()
+	var distSq float64
+	for i, v := range pos {
+		d := float64(position[i]/network.BS) - float64(v)
+		distSq += d * d
+	}
+	return math.Sqrt(distSq) <= maxInteractDistance
+}
+
+// wieldedSlot returns the hotbar slot the player wields, or nil if it has none. Call it with p.mu held.
+func (p *peer) wieldedSlot() *inventory.ItemStack {
+	main := p.inventory.List(inventory.MainList)
+	if main == nil || p.wieldIndex < 0 || p.wieldIndex >= len(main.Items) {
+		return nil
+	}
+	return &main.Items[p.wieldIndex]
+}
+
+// wieldedItem returns the stack the player wields. Call it with p.mu held.
+func (p *peer) wieldedItem() inventory.ItemStack {
+	if stack := p.wieldedSlot(); stack != nil {
+		return *stack
+	}
+	return inventory.ItemStack{}
+}
+
+// broadcastBlock sends a changed block to every client that holds it
+func (s *Server) broadcastBlock(pos [3]int16) {
+	for _, p := range s.readyPeers() {
+		if p.hasBlock(pos) {
+			p.sendBlock(pos)
+		}
+	}
+}
+
+// addItem puts one item into the first stack of it with room, or else the first empty slot.
+// The item is lost if the list is full.
+func addItem(list *inventory.List, item *inventory.ItemDefinition) {
+	if list == nil {
+		return
+	}
+	for i := range list.Items {
+		if stack := &list.Items[i]; stack.Name == item.Name && stack.Count < uint16(item.StackMax) {
+			stack.Count++
+			return
+		}
+	}
+	for i := range list.Items {
+		if list.Items[i].IsEmpty() {
+			list.Items[i] = inventory.ItemStack{Name: item.Name, Count: 1}
+			return
+		}
+	}
+}
+
+// blockOfNode returns the block a node position is in
+func blockOfNode(pos [3]int16) [3]int16 {
+	block, _, _, _ := nodeInBlock(pos)
+	return block
+}
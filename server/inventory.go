@@ -0,0 +1,103 @@
+package server
+
+import (
+	"bettermt/main/blocktypes"
+	"bettermt/main/formspec"
+	"bettermt/main/inventory"
+	"bettermt/main/network"
+	"bettermt/main/protocol"
+)
+
+// Size of the main list of a player, four rows of eight
+const mainListSize = 32
+
+// Lists of the player that only the server puts items into
+var outputLists = map[string]bool{"craftpreview": true, "craftresult": true}
+
+// newPlayerInventory creates the inventory a player joins with, holding a stack of every node of the world
+func (s *Server) newPlayerInventory() *inventory.Inventory {
+	inv := inventory.New()
+	main := inventory.NewList(inventory.MainList, mainListSize, 8)
+	slot := 0
+	for _, id := range s.nodeDefs.IDs() {
+		if id == blocktypes.ContentUnknown || id == blocktypes.ContentAir || id == blocktypes.ContentIgnore {
+			continue
+		}
+		def := s.itemDefs.Get(s.nodeDefs.Get(id).Name)
+		main.Items[slot] = inventory.ItemStack{Name: def.Name, Count: uint16(def.StackMax)}
+		slot++
+	}
+	inv.AddList(main)
+	inv.AddList(inventory.NewList("craft", 9, 3))
+	inv.AddList(inventory.NewList("craftpreview", 1, 0))
+	inv.AddList(inventory.NewList("craftresult", 1, 0))
+	return inv
+}
+
+// sendItemDefs sends the item definitions, which the client needs before the node definitions
+func (p *peer) sendItemDefs() error {
+	itemDefs, err := p.server.itemDefs.Serialize()
+	if err != nil {
+		return err
+	}
+	w := network.NewWriter(network.ToClientItemDef)
+	w.String32(string(itemDefs))
+	return p.send(w)
+}
+
+// sendInventory sends the whole inventory of the player
+func (p *peer) sendInventory() error {
+	p.mu.Lock()
+	data := p.inventory.Serialize()
+	p.mu.Unlock()
+
+	w := network.NewWriter(network.ToClientInventory)
+	w.Raw([]byte(data))
+	return p.send(w)
+}
+
+// handlePlayerItem remembers the hotbar slot the player wields
+func handlePlayerItem(p *peer, r *network.Reader) error {
+	item, err := protocol.ReadPlayerItem(r)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.wieldIndex = int(item.Index)
+	p.mu.Unlock()
+	return nil
+}
+
+// sendInventoryFormspec sends the formspec the inventory key opens, with the crafting grid
+func (p *peer) sendInventoryFormspec() error {
+	return p.send(protocol.WriteInventoryFormspec(formspec.DefaultInventory))
+}
+
+// handleInventoryAction moves items within the inventory of the player. Drops are refused, as the world has no
+// item entities, and so are moves into other inventories or into the crafting output. The inventory is sent
+// back either way, so that the client undoes what it predicted wrongly.
+func handleInventoryAction(p *peer, r *network.Reader) error {
+	text, err := protocol.ReadInventoryAction(r)
+	if err != nil {
+		return err
+	}
+	action, err := inventory.ParseAction(text)
+	if err != nil {
+		return err
+	}
+	if p.getState() != peerReady {
+		return nil
+	}
+	if m, ok := action.(inventory.Move); ok && p.ownsLocation(m.From.Location) && p.ownsLocation(m.To.Location) &&
+		!outputLists[m.To.List] {
+		p.mu.Lock()
+		m.Apply(p.inventory, p.server.itemDefs)
+		p.mu.Unlock()
+	}
+	return p.sendInventory()
+}
+
+// ownsLocation reports whether an inventory location is the inventory of the player
+func (p *peer) ownsLocation(location string) bool {
+	return location == inventory.LocationCurrentPlayer || location == "player:"+p.name
+}
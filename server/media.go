@@ -0,0 +1,101 @@
+package server
+
+import (
+	"crypto/sha1"
+	"io/fs"
+	"sort"
+
+	"bettermt/main/network"
+	"bettermt/main/protocol"
+)
+
+// Files are grouped into TOCLIENT_MEDIA packets of about this many bytes
+const mediaBunchSize = 5000
+
+// mediaStore holds the files offered to clients
+type mediaStore struct {
+	files  map[string][]byte
+	hashes map[string][]byte // SHA1 of each file
+}
+
+// loadMedia reads every file at the top level of fsys
+func loadMedia(fsys fs.FS) (*mediaStore, error) {
+	store := &mediaStore{files: make(map[string][]byte), hashes: make(map[string][]byte)}
+	if fsys == nil {
+		return store, nil
+	}
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		data, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		sum := sha1.Sum(data)
+		store.files[entry.Name()] = data
+		store.hashes[entry.Name()] = sum[:]
+	}
+	return store, nil
+}
+
+// names returns the names of all files, sorted
+func (m *mediaStore) names() []string {
+	names := make([]string, 0, len(m.files))
+	for name := range m.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sendMediaAnnouncement lists the media files with their hashes
+func (p *peer) sendMediaAnnouncement() error {
+	media := p.server.media
+	var announce protocol.AnnounceMedia
+	for _, name := range media.names() {
+		announce.Files = append(announce.Files, protocol.MediaAnnouncement{Name: name, SHA1: media.hashes[name]})
+	}
+	return p.send(announce.Write())
+}
+
+// handleRequestMedia sends the requested files in bunches
+func handleRequestMedia(p *peer, r *network.Reader) error {
+	request, err := protocol.ReadRequestMedia(r)
+	if err != nil {
+		return err
+	}
+
+	// Group the files so that no bunch is much larger than mediaBunchSize
+	media := p.server.media
+	var bunches [][]string
+	size := 0
+	for _, name := range request.Files {
+		data, exists := media.files[name]
+		if !exists {
+			logf("%s requested unknown media %q", p.name, name)
+			continue
+		}
+		if len(bunches) == 0 || size >= mediaBunchSize {
+			bunches = append(bunches, nil)
+			size = 0
+		}
+		bunches[len(bunches)-1] = append(bunches[len(bunches)-1], name)
+		size += len(data)
+	}
+
+	for i, bunch := range bunches {
+		m := protocol.Media{Bunches: uint16(len(bunches)), Bunch: uint16(i)}
+		for _, name := range bunch {
+			m.Files = append(m.Files, protocol.MediaFile{Name: name, Data: media.files[name]})
+		}
+		if err := p.send(m.Write()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
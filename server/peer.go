@@ -0,0 +1,348 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"bettermt/main/auth"
+	"bettermt/main/chat"
+	"bettermt/main/inventory"
+	"bettermt/main/network"
+	"bettermt/main/protocol"
+)
+
+// Longest player name accepted
+const maxNameLength = 20
+
+// Characters allowed in player names
+var validName = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// peerState is a step of the login sequence of one client
+type peerState int
+
+const (
+	peerInit peerState = iota
+	peerAuthenticating
+	peerJoining
+	peerReady
+)
+
+// handler processes the body of one command received from a client
+type handler func(p *peer, r *network.Reader) error
+
+// handlers maps client commands to the function that processes them
+var handlers = map[uint16]handler{
+	network.ToServerInit:            handleInit,
+	network.ToServerFirstSRP:        handleFirstSRP,
+	network.ToServerSRPBytesA:       handleSRPBytesA,
+	network.ToServerSRPBytesM:       handleSRPBytesM,
+	network.ToServerInit2:           handleInit2,
+	network.ToServerRequestMedia:    handleRequestMedia,
+	network.ToServerClientReady:     handleClientReady,
+	network.ToServerGotBlocks:       handleGotBlocks,
+	network.ToServerDeletedBlocks:   handleDeletedBlocks,
+	network.ToServerChatMessage:     handleChatMessage,
+	network.ToServerPlayerPos:       handlePlayerPos,
+	network.ToServerPlayerItem:      handlePlayerItem,
+	network.ToServerInteract:        handleInteract,
+	network.ToServerInventoryAction: handleInventoryAction,
+}
+
+// peerConn is the connection of a peer, a network.Conn outside of tests
+type peerConn interface {
+	network.Transport
+	RemoteAddr() net.Addr
+}
+
+// peer is the session of one connected client
+type peer struct {
+	server *Server
+	conn   peerConn
+
+	mu    sync.Mutex
+	state peerState
+
+	name                 string
+	serializationVersion uint8
+	protocolVersion      uint16
+	srp                  *auth.Verifier
+
+	// Blocks sent to the client and not deleted by it since
+	sentBlocks map[[3]int16]bool
+	position   [3]float32
+
+	inventory  *inventory.Inventory
+	wieldIndex int
+}
+
+func newPeer(s *Server, conn peerConn) *peer {
+	return &peer{
+		server:     s,
+		conn:       conn,
+		state:      peerInit,
+		sentBlocks: make(map[[3]int16]bool),
+		inventory:  s.newPlayerInventory(),
+	}
+}
+
+func (p *peer) getState() peerState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.state
+}
+
+func (p *peer) setState(state peerState) {
+	p.mu.Lock()
+	p.state = state
+	p.mu.Unlock()
+}
+
+// receiveLoop dispatches packets from the client until it disconnects
+func (p *peer) receiveLoop() {
+	defer func() {
+		p.server.removePeer(p)
+		if p.getState() == peerReady {
+			logf("%s left the game", p.name)
+			p.server.broadcastChat(chat.Message{Type: chat.TypeAnnounce, Text: "*** " + p.name + " left the game."})
+		}
+	}()
+	for {
+		pkt, err := p.conn.Recv()
+		if err != nil {
+			return
+		}
+		if err := p.handlePacket(pkt.Data); err != nil {
+			logf("error handling packet from %s: %v", p.conn.RemoteAddr(), err)
+		}
+	}
+}
+
+// handlePacket decodes the command ID of a packet and runs its handler
+func (p *peer) handlePacket(data []byte) error {
+	r := network.NewReader(data)
+	command := r.U16()
+	if r.Err() != nil {
+		return r.Err()
+	}
+	h, exists := handlers[command]
+	if !exists {
+		return nil
+	}
+	if err := h(p, r); err != nil {
+		return fmt.Errorf("%s: %w", network.ToServerCommands[command].Name, err)
+	}
+	return nil
+}
+
+// send transmits a packet on the channel its command is meant for
+func (p *peer) send(w *network.Writer) error {
+	return network.SendCommand(p.conn, network.ToClientCommands, w)
+}
+
+// deny refuses the client with a reason and disconnects it
+func (p *peer) deny(code uint8, reason string) error {
+	err := p.send(protocol.AccessDenied{Code: code, Reason: reason}.Write())
+	p.conn.Close()
+	return err
+}
+
+// handleInit negotiates versions and offers an authentication mechanism
+func handleInit(p *peer, r *network.Reader) error {
+	init, err := protocol.ReadInit(r)
+	if err != nil {
+		return err
+	}
+	name := init.Name
+
+	// Clients repeat INIT until they hear back
+	if p.getState() != peerInit {
+		return nil
+	}
+
+	serializationVersion := min(init.MaxSerializationVersion, network.SerializationVersionMax)
+	protocolVersion := min(init.MaxProtocolVersion, network.ProtocolVersionMax)
+	if serializationVersion < network.SerializationVersionMin ||
+		protocolVersion < network.ProtocolVersionMin || protocolVersion < init.MinProtocolVersion {
+		return p.deny(network.AccessDeniedWrongVersion, "")
+	}
+	if len(name) > maxNameLength || !validName.MatchString(name) {
+		return p.deny(network.AccessDeniedWrongCharsInName, "")
+	}
+	if p.server.nameInUse(name, p) {
+		return p.deny(network.AccessDeniedAlreadyConnected, "")
+	}
+
+	p.name = name
+	p.serializationVersion = serializationVersion
+	p.protocolVersion = protocolVersion
+	p.setState(peerAuthenticating)
+
+	// Unknown players register with their first login
+	mechanism := network.AuthMechanismSRP
+	if acc, exists := p.server.getAccount(name); !exists {
+		mechanism = network.AuthMechanismFirstSRP
+	} else if acc.legacy {
+		mechanism = network.AuthMechanismLegacyPassword
+	}
+
+	return p.send(protocol.Hello{
+		SerializationVersion: serializationVersion,
+		ProtocolVersion:      protocolVersion,
+		AuthMechanisms:       mechanism,
+		LegacyName:           name,
+	}.Write())
+}
+
+// handleFirstSRP registers a new account with the verifier the client created
+func handleFirstSRP(p *peer, r *network.Reader) error {
+	first, err := protocol.ReadFirstSRP(r)
+	if err != nil {
+		return err
+	}
+	if p.getState() != peerAuthenticating {
+		return p.deny(network.AccessDeniedUnexpectedData, "")
+	}
+	if !p.server.createAccount(p.name, account{salt: first.Salt, verifier: first.Verifier}) {
+		return p.deny(network.AccessDeniedUnexpectedData, "")
+	}
+	logf("registered %s", p.name)
+	return p.acceptAuth()
+}
+
+// handleSRPBytesA answers the first step of an SRP login with the salt and B
+func handleSRPBytesA(p *peer, r *network.Reader) error {
+	bytesA, err := protocol.ReadSRPBytesA(r)
+	if err != nil {
+		return err
+	}
+	// Verifiers are based on the legacy password hash for migrated accounts and on the password otherwise
+	acc, exists := p.server.getAccount(p.name)
+	if p.getState() != peerAuthenticating || !exists || (bytesA.BasedOn == 0) != acc.legacy {
+		return p.deny(network.AccessDeniedUnexpectedData, "")
+	}
+
+	srp, err := auth.NewVerifier(p.name, acc.salt, acc.verifier, bytesA.BytesA)
+	if err != nil {
+		return p.deny(network.AccessDeniedUnexpectedData, "")
+	}
+	p.srp = srp
+
+	return p.send(protocol.SRPBytesSB{Salt: acc.salt, BytesB: srp.BytesB()}.Write())
+}
+
+// handleSRPBytesM checks the client's proof of the password
+func handleSRPBytesM(p *peer, r *network.Reader) error {
+	bytesM, err := protocol.ReadSRPBytesM(r)
+	if err != nil {
+		return err
+	}
+	if p.getState() != peerAuthenticating || p.srp == nil {
+		return p.deny(network.AccessDeniedUnexpectedData, "")
+	}
+	if _, ok := p.srp.Verify(bytesM.M); !ok {
+		logf("%s sent a wrong password", p.name)
+		return p.deny(network.AccessDeniedWrongPassword, "")
+	}
+	p.srp = nil
+	return p.acceptAuth()
+}
+
+// acceptAuth lets an authenticated client join
+func (p *peer) acceptAuth() error {
+	p.position = p.server.spawnPosition()
+	p.setState(peerJoining)
+
+	return p.send(protocol.AuthAccept{
+		Position:           p.position,
+		SendInterval:       clientSendInterval,
+		SudoAuthMechanisms: network.AuthMechanismSRP,
+	}.Write())
+}
+
+// handleInit2 sends the definitions and media the client needs before entering the game
+func handleInit2(p *peer, r *network.Reader) error {
+	if p.getState() != peerJoining {
+		return nil
+	}
+
+	if err := p.sendItemDefs(); err != nil {
+		return err
+	}
+	nodeDefs, err := p.server.nodeDefs.Serialize()
+	if err != nil {
+		return err
+	}
+	w := network.NewWriter(network.ToClientNodeDef)
+	w.String32(string(nodeDefs))
+	if err := p.send(w); err != nil {
+		return err
+	}
+
+	if err := p.send(protocol.WriteMovement(p.server.cfg.Movement)); err != nil {
+		return err
+	}
+	if err := p.send(protocol.WritePrivileges(p.server.cfg.Privileges)); err != nil {
+		return err
+	}
+	if err := p.sendMediaAnnouncement(); err != nil {
+		return err
+	}
+	return p.sendTimeOfDay()
+}
+
+// handleClientReady puts the client in game and starts sending it blocks
+func handleClientReady(p *peer, r *network.Reader) error {
+	if p.getState() != peerJoining {
+		return nil
+	}
+	p.setState(peerReady)
+
+	logf("%s joined the game", p.name)
+	p.server.broadcastChat(chat.Message{Type: chat.TypeAnnounce, Text: "*** " + p.name + " joined the game."})
+	if err := p.sendInventoryFormspec(); err != nil {
+		return err
+	}
+	if err := p.sendInventory(); err != nil {
+		return err
+	}
+	return p.sendBlocks()
+}
+
+// sendTimeOfDay tells the client the current game time
+func (p *peer) sendTimeOfDay() error {
+	t := protocol.TimeOfDay{Time: uint16(p.server.getTimeOfDay()) % 24000, Speed: p.server.cfg.TimeSpeed}
+	return p.send(t.Write())
+}
+
+// handleChatMessage relays a chat message to every player
+func handleChatMessage(p *peer, r *network.Reader) error {
+	msg, err := protocol.ReadPlayerChatMessage(r)
+	if err != nil {
+		return err
+	}
+	if p.getState() != peerReady {
+		return nil
+	}
+	text := strings.TrimSpace(msg.Text)
+	if text == "" {
+		return nil
+	}
+	if strings.HasPrefix(text, "/") {
+		command, _, _ := strings.Cut(text[1:], " ")
+		return p.sendChat(chat.Message{Type: chat.TypeSystem, Text: "-!- Invalid command: " + command})
+	}
+	p.server.broadcastChat(chat.Message{Type: chat.TypeNormal, Sender: p.name, Text: text})
+	return nil
+}
+
+// sendChat sends one chat message to the client
+func (p *peer) sendChat(msg chat.Message) error {
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = time.Now()
+	}
+	return p.send(protocol.WriteChatMessage(msg))
+}
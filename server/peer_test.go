@@ -0,0 +1,120 @@
+package server
+
+import (
+	"net"
+	"slices"
+	"sync"
+	"testing"
+
+	"bettermt/main/network"
+	"bettermt/main/protocol"
+)
+
+// fakeConn keeps the packets a peer sends instead of sending them
+type fakeConn struct {
+	mu   sync.Mutex
+	sent [][]byte
+	done chan struct{}
+}
+
+func newFakeConn() *fakeConn {
+	return &fakeConn{done: make(chan struct{})}
+}
+
+func (c *fakeConn) Send(channel uint8, data []byte, reliable bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sent = append(c.sent, append([]byte(nil), data...))
+	return nil
+}
+
+func (c *fakeConn) Recv() (network.Packet, error) {
+	<-c.done
+	return network.Packet{}, network.ErrClosed
+}
+
+func (c *fakeConn) Close() error {
+	select {
+	case <-c.done:
+	default:
+		close(c.done)
+	}
+	return nil
+}
+
+func (c *fakeConn) Done() <-chan struct{} { return c.done }
+func (c *fakeConn) Err() error            { return nil }
+func (c *fakeConn) RemoteAddr() net.Addr  { return &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)} }
+
+// packet returns the body of the first packet sent with a command, or nil if there is none
+func (c *fakeConn) packet(command uint16) *network.Reader {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, data := range c.sent {
+		r := network.NewReader(data)
+		if r.U16() == command {
+			return r
+		}
+	}
+	return nil
+}
+
+// joiningPeer returns a peer of a new server that authenticated and is about to send INIT2
+func joiningPeer(t *testing.T, cfg Config) (*peer, *fakeConn) {
+	t.Helper()
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := newFakeConn()
+	p := newPeer(s, conn)
+	p.name = "tester"
+	p.setState(peerJoining)
+	return p, conn
+}
+
+func TestPrivilegesSentOnJoin(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		privileges []string
+		want       []string
+	}{
+		{"default", nil, DefaultPrivileges},
+		{"configured", []string{"interact", "shout"}, []string{"interact", "shout"}},
+		{"none", []string{}, nil},
+	} {
+		p, conn := joiningPeer(t, Config{TimeSpeed: DefaultTimeSpeed, Privileges: test.privileges})
+		if err := p.handlePacket(network.NewWriter(network.ToServerInit2).Bytes()); err != nil {
+			t.Fatal(err)
+		}
+		r := conn.packet(network.ToClientPrivileges)
+		if r == nil {
+			t.Fatalf("%s: no TOCLIENT_PRIVILEGES sent", test.name)
+		}
+		got, err := protocol.ReadPrivileges(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(got, test.want) {
+			t.Errorf("%s: sent privileges %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestDefaultPrivilegesAllowFastMovement(t *testing.T) {
+	// The fast movement settings of singleplayer are only used with the fast privilege
+	if !slices.Contains(DefaultPrivileges, "fast") {
+		t.Fatalf("default privileges %q without fast", DefaultPrivileges)
+	}
+}
+
+func TestNoPrivilegesBeforeAuthentication(t *testing.T) {
+	p, conn := joiningPeer(t, Config{})
+	p.setState(peerAuthenticating)
+	if err := p.handlePacket(network.NewWriter(network.ToServerInit2).Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if conn.packet(network.ToClientPrivileges) != nil {
+		t.Fatal("privileges sent to a client that did not log in")
+	}
+}
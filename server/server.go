@@ -0,0 +1,243 @@
+package server
+
+import (
+	"fmt"
+	"io/fs"
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	"bettermt/main/blocktypes"
+	"bettermt/main/chat"
+	"bettermt/main/inventory"
+	"bettermt/main/meshbuilder"
+	"bettermt/main/network"
+	"bettermt/main/player"
+)
+
+const (
+	// Default distance in blocks around the player that is sent to clients
+	DefaultViewRange = 4
+	// Default game time speed, 72 makes a day last 20 minutes
+	DefaultTimeSpeed = 72
+	// Time of day a new server starts at, in thousandths of an hour
+	startTimeOfDay = 6125
+
+	// How often the server updates its state
+	stepInterval = 100 * time.Millisecond
+	// How often the time of day is sent to every client
+	timeSendInterval = 5 * time.Second
+	// Interval clients should send their position at, in seconds
+	clientSendInterval = 0.09
+)
+
+// Config holds the settings of an embedded server
+type Config struct {
+	// Files offered to clients as media, may be nil
+	Media fs.FS
+	// Distance in blocks around the player that is sent to clients
+	ViewRange int16
+	// Speed of the game time relative to real time: 0 stops the time and negative values run it backwards.
+	// Callers pass DefaultTimeSpeed unless configured otherwise.
+	TimeSpeed float32
+	// Movement settings sent to clients, the defaults of Minetest if zero
+	Movement player.MovementSettings
+	// Privileges granted to every player, DefaultPrivileges if nil
+	Privileges []string
+}
+
+// DefaultPrivileges are the privileges of players in singleplayer, where the one player may do anything the
+// client supports
+var DefaultPrivileges = []string{"interact", "shout", "fast", "fly", "noclip", "give", "settime", "teleport", "debug"}
+
+// account is a registered player
+type account struct {
+	salt     []byte
+	verifier []byte
+	// Migrated from before SRP, the verifier is derived from the legacy password hash
+	legacy bool
+}
+
+// Server is a minimal game server speaking the Minetest protocol, used for singleplayer
+type Server struct {
+	cfg      Config
+	listener *network.Listener
+	nodeDefs *blocktypes.NodeDefManager
+	itemDefs *inventory.ItemDefManager
+	media    *mediaStore
+	done     chan struct{}
+
+	mu        sync.Mutex
+	accounts  map[string]account
+	peers     map[*peer]bool
+	blocks    map[[3]int16]*meshbuilder.MapBlock
+	timeOfDay float64
+}
+
+// New creates a server serving the built in world generator
+func New(cfg Config) (*Server, error) {
+	if cfg.ViewRange <= 0 {
+		cfg.ViewRange = DefaultViewRange
+	}
+	if cfg.Movement == (player.MovementSettings{}) {
+		cfg.Movement = player.DefaultMovementSettings()
+	}
+	if cfg.Privileges == nil {
+		cfg.Privileges = DefaultPrivileges
+	}
+	media, err := loadMedia(cfg.Media)
+	if err != nil {
+		return nil, err
+	}
+	nodeDefs := blocktypes.DefaultNodeDefManager()
+	return &Server{
+		cfg:       cfg,
+		nodeDefs:  nodeDefs,
+		itemDefs:  inventory.DefaultItemDefManager(nodeDefs),
+		media:     media,
+		done:      make(chan struct{}),
+		accounts:  make(map[string]account),
+		peers:     make(map[*peer]bool),
+		blocks:    make(map[[3]int16]*meshbuilder.MapBlock),
+		timeOfDay: startTimeOfDay,
+	}, nil
+}
+
+// Listen starts accepting clients on a UDP address such as 127.0.0.1:0
+func (s *Server) Listen(address string) error {
+	l, err := network.Listen(address)
+	if err != nil {
+		return err
+	}
+	s.listener = l
+	go s.acceptLoop()
+	go s.stepLoop()
+	return nil
+}
+
+// Addr returns the address clients connect to
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Close disconnects every client and stops the server
+func (s *Server) Close() error {
+	select {
+	case <-s.done:
+		return nil
+	default:
+		close(s.done)
+	}
+	s.mu.Lock()
+	peers := make([]*peer, 0, len(s.peers))
+	for p := range s.peers {
+		peers = append(peers, p)
+	}
+	s.mu.Unlock()
+	for _, p := range peers {
+		p.deny(network.AccessDeniedShutdown, "")
+	}
+	return s.listener.Close()
+}
+
+// acceptLoop starts a session for every new client
+func (s *Server) acceptLoop() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		p := newPeer(s, conn)
+		s.mu.Lock()
+		s.peers[p] = true
+		s.mu.Unlock()
+		go p.receiveLoop()
+	}
+}
+
+// stepLoop advances the time of day and sends it out regularly
+func (s *Server) stepLoop() {
+	ticker := time.NewTicker(stepInterval)
+	defer ticker.Stop()
+	lastStep := time.Now()
+	lastTimeSend := lastStep
+	for {
+		select {
+		case <-s.done:
+			return
+		case now := <-ticker.C:
+			s.advanceTime(now.Sub(lastStep))
+			lastStep = now
+
+			if now.Sub(lastTimeSend) >= timeSendInterval {
+				lastTimeSend = now
+				for _, p := range s.readyPeers() {
+					p.sendTimeOfDay()
+				}
+			}
+		}
+	}
+}
+
+// advanceTime moves the time of day on by the game time passing in a span of real time
+func (s *Server) advanceTime(elapsed time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.timeOfDay += elapsed.Seconds() * float64(s.cfg.TimeSpeed) * 24000 / 86400
+	s.timeOfDay = math.Mod(s.timeOfDay, 24000)
+	if s.timeOfDay < 0 {
+		s.timeOfDay += 24000
+	}
+}
+
+// removePeer forgets a disconnected client
+func (s *Server) removePeer(p *peer) {
+	s.mu.Lock()
+	delete(s.peers, p)
+	s.mu.Unlock()
+}
+
+// readyPeers returns the clients that are in game
+func (s *Server) readyPeers() []*peer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var peers []*peer
+	for p := range s.peers {
+		if p.getState() == peerReady {
+			peers = append(peers, p)
+		}
+	}
+	return peers
+}
+
+// nameInUse reports whether another client already logged in with a name
+func (s *Server) nameInUse(name string, except *peer) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for p := range s.peers {
+		if p != except && p.getState() > peerInit && p.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// broadcastChat sends a chat message to every client in game
+func (s *Server) broadcastChat(msg chat.Message) {
+	for _, p := range s.readyPeers() {
+		p.sendChat(msg)
+	}
+}
+
+// getTimeOfDay returns the time of day in thousandths of an hour
+func (s *Server) getTimeOfDay() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.timeOfDay
+}
+
+// logf prints a server message
+func logf(format string, args ...interface{}) {
+	fmt.Printf("[server] "+format+"\n", args...)
+}
@@ -0,0 +1,34 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeSpeed(t *testing.T) {
+	tests := []struct {
+		speed float32
+		start float64
+		want  float64
+	}{
+		{DefaultTimeSpeed, 6000, 6000 + 20*24000.0/1200}, // A day lasts 20 minutes
+		{0, 6000, 6000},
+		{-72, 6000, 6000 - 20*24000.0/1200},
+		{72, 23900, 300},  // Past midnight
+		{-72, 100, 23700}, // Back past midnight
+	}
+	for _, test := range tests {
+		s, err := New(Config{TimeSpeed: test.speed})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.cfg.TimeSpeed != test.speed {
+			t.Fatalf("time speed %v replaced by %v", test.speed, s.cfg.TimeSpeed)
+		}
+		s.timeOfDay = test.start
+		s.advanceTime(20 * time.Second)
+		if got := s.getTimeOfDay(); got < test.want-1e-6 || got > test.want+1e-6 {
+			t.Errorf("speed %v from %v: time of day %v, want %v", test.speed, test.start, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"math"
+	"sort"
+
+	"bettermt/main/meshbuilder"
+	"bettermt/main/network"
+	"bettermt/main/protocol"
+)
+
+// Network specific data following each block in TOCLIENT_BLOCKDATA
+const blockDataNetworkVersion = 2
+
+// Column players spawn in, in nodes
+const spawnX, spawnZ = 8, 8
+
+// getBlock returns the block at a block position, generating it on first use
+func (s *Server) getBlock(pos [3]int16) *meshbuilder.MapBlock {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loadBlock(pos)
+}
+
+// loadBlock is getBlock for callers holding s.mu
+func (s *Server) loadBlock(pos [3]int16) *meshbuilder.MapBlock {
+	block, exists := s.blocks[pos]
+	if !exists {
+		block = meshbuilder.NewMapBlock(int32(pos[0])*meshbuilder.ChunkSize, int32(pos[1])*meshbuilder.ChunkSize, int32(pos[2])*meshbuilder.ChunkSize)
+		s.blocks[pos] = block
+	}
+	return block
+}
+
+// serializeBlock encodes a block for a client, holding s.mu so players cannot change it meanwhile
+func (s *Server) serializeBlock(pos [3]int16, version uint8) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loadBlock(pos).Serialize(version, false)
+}
+
+// setNode replaces the node at a node position and returns the previous content
+func (s *Server) setNode(pos [3]int16, content uint16) uint16 {
+	block, x, y, z := nodeInBlock(pos)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	mb := s.loadBlock(block)
+	old, _ := mb.GetBlock(x, y, z)
+	mb.SetBlock(x, y, z, content)
+	return old
+}
+
+// getNode returns the content of the node at a node position
+func (s *Server) getNode(pos [3]int16) uint16 {
+	block, x, y, z := nodeInBlock(pos)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	content, _ := s.loadBlock(block).GetBlock(x, y, z)
+	return content
+}
+
+// spawnPosition returns the point on the ground of the spawn column, in protocol units
+func (s *Server) spawnPosition() [3]float32 {
+	for by := int16(4); by >= -4; by-- {
+		block := s.getBlock([3]int16{0, by, 0})
+		for y := meshbuilder.ChunkSize - 1; y >= 0; y-- {
+			if node, _ := block.GetBlock(spawnX, y, spawnZ); node != meshbuilder.BlockAir {
+				ground := float32(int32(by)*meshbuilder.ChunkSize + y)
+				return [3]float32{spawnX * network.BS, (ground + 0.5) * network.BS, spawnZ * network.BS}
+			}
+		}
+	}
+	return [3]float32{spawnX * network.BS, 0, spawnZ * network.BS}
+}
+
+// sendBlocks sends the blocks around the player the client does not have yet, nearest first
+func (p *peer) sendBlocks() error {
+	p.mu.Lock()
+	center := blockPosition(p.position)
+	viewRange := p.server.cfg.ViewRange
+	var positions [][3]int16
+	for x := center[0] - viewRange; x <= center[0]+viewRange; x++ {
+		for y := center[1] - viewRange; y <= center[1]+viewRange; y++ {
+			for z := center[2] - viewRange; z <= center[2]+viewRange; z++ {
+				pos := [3]int16{x, y, z}
+				if !p.sentBlocks[pos] {
+					positions = append(positions, pos)
+				}
+			}
+		}
+	}
+	p.mu.Unlock()
+	sort.Slice(positions, func(i, j int) bool {
+		return blockDistanceSq(positions[i], center) < blockDistanceSq(positions[j], center)
+	})
+
+	for _, pos := range positions {
+		if err := p.sendBlock(pos); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendBlock sends the current content of one block
+func (p *peer) sendBlock(pos [3]int16) error {
+	data, err := p.server.serializeBlock(pos, p.serializationVersion)
+	if err != nil {
+		return err
+	}
+	w := network.NewWriter(network.ToClientBlockData)
+	w.V3S16(pos)
+	w.Raw(data)
+	w.U8(blockDataNetworkVersion)
+	if err := p.send(w); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.sentBlocks[pos] = true
+	p.mu.Unlock()
+	return nil
+}
+
+// hasBlock reports whether the client holds a block
+func (p *peer) hasBlock(pos [3]int16) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.sentBlocks[pos]
+}
+
+// handleGotBlocks accepts the acknowledgements of sent blocks
+func handleGotBlocks(p *peer, r *network.Reader) error {
+	// Blocks are marked as sent right away, so acknowledgements need no bookkeeping
+	return nil
+}
+
+// handleDeletedBlocks forgets blocks the client dropped so they are sent again when needed
+func handleDeletedBlocks(p *peer, r *network.Reader) error {
+	deleted, err := protocol.ReadBlockList(r)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, pos := range deleted.Positions {
+		delete(p.sentBlocks, pos)
+	}
+	return nil
+}
+
+// handlePlayerPos moves the player and sends the blocks that came into range
+func handlePlayerPos(p *peer, r *network.Reader) error {
+	pos, err := protocol.ReadPlayerPos(r)
+	if err != nil {
+		return err
+	}
+	if p.getState() != peerReady {
+		return nil
+	}
+
+	p.mu.Lock()
+	moved := blockPosition(p.position) != blockPosition(pos.Position)
+	p.position = pos.Position
+	p.mu.Unlock()
+	if moved {
+		return p.sendBlocks()
+	}
+	return nil
+}
+
+// blockPosition returns the block a position in protocol units is in
+func blockPosition(pos [3]float32) [3]int16 {
+	var block [3]int16
+	for i, v := range pos {
+		node := int32(math.Floor(float64(v / network.BS)))
+		block[i] = int16(node >> 4)
+	}
+	return block
+}
+
+// nodeInBlock splits a node position into the position of its block and the offset inside it
+func nodeInBlock(pos [3]int16) (block [3]int16, x, y, z int32) {
+	for i, v := range pos {
+		block[i] = v >> 4
+	}
+	return block, int32(pos[0]) & 15, int32(pos[1]) & 15, int32(pos[2]) & 15
+}
+
+// blockDistanceSq returns the squared distance between two block positions
+func blockDistanceSq(a, b [3]int16) int {
+	dx, dy, dz := int(a[0])-int(b[0]), int(a[1])-int(b[1]), int(a[2])-int(b[2])
+	return dx*dx + dy*dy + dz*dz
+}
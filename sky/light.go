@@ -0,0 +1,111 @@
+package sky
+
+import (
+	"image/color"
+	"math"
+)
+
+// Brightness of the day around sunrise as in Minetest, by time of day in thousandths of an hour. The evening
+// mirrors the morning.
+var dayNightRatios = [...][2]float32{
+	{4375, 150},
+	{4625, 150},
+	{4875, 250},
+	{5125, 350},
+	{5375, 500},
+	{5625, 675},
+	{5875, 875},
+	{6125, 1000},
+	{6375, 1000},
+}
+
+// DayNightRatio returns the brightness of the day at a time of day, from 0.15 at night to 1 during the day
+func DayNightRatio(timeOfDay float32) float32 {
+	t := float32(wrap(float64(timeOfDay)))
+	if t > DayLength/2 {
+		t = DayLength - t
+	}
+	if t <= dayNightRatios[1][0] {
+		return dayNightRatios[0][1] / 1000
+	}
+	last := len(dayNightRatios) - 1
+	if t >= dayNightRatios[last-1][0] {
+		return dayNightRatios[last][1] / 1000
+	}
+	for i := 1; i < len(dayNightRatios); i++ {
+		if dayNightRatios[i][0] <= t {
+			continue
+		}
+		f := (t - dayNightRatios[i-1][0]) / (dayNightRatios[i][0] - dayNightRatios[i-1][0])
+		return (f*dayNightRatios[i][1] + (1-f)*dayNightRatios[i-1][1]) / 1000
+	}
+	return 1
+}
+
+// SunDirection returns the unit vector pointing from the player towards the sun. The sun rises in the east
+// (+x) at 6:00, is overhead at noon and its path leans towards the south (-z) by tilt degrees.
+func SunDirection(timeOfDay float32, tilt float32) [3]float32 {
+	angle := 2 * math.Pi * (float64(timeOfDay)/DayLength - 0.25)
+	tiltRad := float64(tilt) * math.Pi / 180
+	up := math.Sin(angle)
+	return [3]float32{
+		float32(math.Cos(angle)),
+		float32(up * math.Cos(tiltRad)),
+		float32(-up * math.Sin(tiltRad)),
+	}
+}
+
+// MoonDirection returns the unit vector pointing from the player towards the moon, which is opposite the sun
+func MoonDirection(timeOfDay float32, tilt float32) [3]float32 {
+	sun := SunDirection(timeOfDay, tilt)
+	return [3]float32{-sun[0], -sun[1], -sun[2]}
+}
+
+// Dawn returns how far into dawn or dusk a day/night ratio is, 0 at full day or night and 1 halfway between
+func Dawn(ratio float32) float32 {
+	const (
+		night = 0.15
+		day   = 1.0
+		mid   = (night + day) / 2
+	)
+	if ratio <= night || ratio >= day {
+		return 0
+	}
+	if ratio < mid {
+		return (ratio - night) / (mid - night)
+	}
+	return (day - ratio) / (day - mid)
+}
+
+// SkyColor returns the color overhead and at the horizon of a regular sky for a day/night ratio. The night
+// colors are darkened so the sky fades to black.
+func (c Colors) SkyColor(ratio float32) (sky, horizon color.NRGBA) {
+	const night = 0.15
+	dawn := Dawn(ratio)
+	day := (ratio - night) / (1 - night)
+	day = max(0, min(1, day))
+
+	sky = lerpColor(dimColor(c.NightSky, 0.15), c.DaySky, day)
+	horizon = lerpColor(dimColor(c.NightHorizon, 0.15), c.DayHorizon, day)
+	sky = lerpColor(sky, c.DawnSky, dawn*0.6)
+	horizon = lerpColor(horizon, c.DawnHorizon, dawn*0.6)
+	return sky, horizon
+}
+
+// lerpColor mixes a into b by f, from 0 for a to 1 for b
+func lerpColor(a, b color.NRGBA, f float32) color.NRGBA {
+	mix := func(x, y uint8) uint8 {
+		return uint8(float32(x) + (float32(y)-float32(x))*f + 0.5)
+	}
+	return color.NRGBA{R: mix(a.R, b.R), G: mix(a.G, b.G), B: mix(a.B, b.B), A: mix(a.A, b.A)}
+}
+
+// dimColor multiplies the channels of a color by f, keeping its alpha
+func dimColor(c color.NRGBA, f float32) color.NRGBA {
+	return color.NRGBA{
+		R: uint8(float32(c.R)*f + 0.5),
+		G: uint8(float32(c.G)*f + 0.5),
+		B: uint8(float32(c.B)*f + 0.5),
+		A: c.A,
+	}
+}
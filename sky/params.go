@@ -0,0 +1,149 @@
+package sky
+
+import "image/color"
+
+// Kinds of sky a server can select
+const (
+	TypeRegular = "regular" // Colors that follow the time of day
+	TypeSkybox  = "skybox"  // Six textures
+	TypePlain   = "plain"   // The background color
+)
+
+// Colors holds the colors of a regular sky. Each part of the day has a color overhead and one at the horizon.
+type Colors struct {
+	DaySky       color.NRGBA
+	DayHorizon   color.NRGBA
+	DawnSky      color.NRGBA
+	DawnHorizon  color.NRGBA
+	NightSky     color.NRGBA
+	NightHorizon color.NRGBA
+	Indoors      color.NRGBA
+}
+
+// Params are the sky settings of TOCLIENT_SET_SKY
+type Params struct {
+	BgColor     color.NRGBA
+	Type        string
+	Clouds      bool
+	FogSunTint  color.NRGBA
+	FogMoonTint color.NRGBA
+	FogTintType string
+	// Textures of a skybox in the order top, bottom, west, east, north, south
+	Textures      []string
+	Colors        Colors
+	BodyOrbitTilt float32 // Degrees the paths of the sun and moon lean towards the south
+	FogDistance   int16   // Negative to use the view range
+	FogStart      float32 // Fraction of the fog distance, negative for the default
+	FogColor      color.NRGBA
+}
+
+// SunParams are the settings of TOCLIENT_SET_SUN
+type SunParams struct {
+	Visible        bool
+	Texture        string
+	Tonemap        string
+	Sunrise        string
+	SunriseVisible bool
+	Scale          float32
+}
+
+// MoonParams are the settings of TOCLIENT_SET_MOON
+type MoonParams struct {
+	Visible bool
+	Texture string
+	Tonemap string
+	Scale   float32
+}
+
+// StarParams are the settings of TOCLIENT_SET_STARS
+type StarParams struct {
+	Visible    bool
+	Count      uint32
+	Color      color.NRGBA
+	Scale      float32
+	DayOpacity float32
+}
+
+// CloudParams are the settings of TOCLIENT_CLOUD_PARAMS
+type CloudParams struct {
+	Density      float32
+	ColorBright  color.NRGBA
+	ColorAmbient color.NRGBA
+	ColorShadow  color.NRGBA
+	Height       float32 // Nodes
+	Thickness    float32 // Nodes
+	Speed        [2]float32
+}
+
+// DefaultColors returns the colors of the regular sky of Minetest
+func DefaultColors() Colors {
+	return Colors{
+		DaySky:       color.NRGBA{R: 97, G: 181, B: 245, A: 255},
+		DayHorizon:   color.NRGBA{R: 144, G: 211, B: 246, A: 255},
+		DawnSky:      color.NRGBA{R: 180, G: 186, B: 250, A: 255},
+		DawnHorizon:  color.NRGBA{R: 186, G: 193, B: 240, A: 255},
+		NightSky:     color.NRGBA{R: 0, G: 107, B: 255, A: 255},
+		NightHorizon: color.NRGBA{R: 64, G: 144, B: 255, A: 255},
+		Indoors:      color.NRGBA{R: 100, G: 100, B: 100, A: 255},
+	}
+}
+
+// DefaultParams returns the sky a client shows until the server sets another
+func DefaultParams() Params {
+	return Params{
+		BgColor:     color.NRGBA{R: 255, G: 255, B: 255, A: 255},
+		Type:        TypeRegular,
+		Clouds:      true,
+		FogSunTint:  color.NRGBA{R: 244, G: 125, B: 29, A: 255},
+		FogMoonTint: color.NRGBA{R: 128, G: 153, B: 204, A: 255},
+		FogTintType: "default",
+		Colors:      DefaultColors(),
+		FogDistance: -1,
+		FogStart:    -1,
+	}
+}
+
+// DefaultSunParams returns the sun of Minetest
+func DefaultSunParams() SunParams {
+	return SunParams{
+		Visible:        true,
+		Texture:        "sun.png",
+		Tonemap:        "sun_tonemap.png",
+		Sunrise:        "sunrisebg.png",
+		SunriseVisible: true,
+		Scale:          1,
+	}
+}
+
+// DefaultMoonParams returns the moon of Minetest
+func DefaultMoonParams() MoonParams {
+	return MoonParams{
+		Visible: true,
+		Texture: "moon.png",
+		Tonemap: "moon_tonemap.png",
+		Scale:   1,
+	}
+}
+
+// DefaultStarParams returns the stars of Minetest
+func DefaultStarParams() StarParams {
+	return StarParams{
+		Visible: true,
+		Count:   1000,
+		Color:   color.NRGBA{R: 235, G: 235, B: 255, A: 105},
+		Scale:   1,
+	}
+}
+
+// DefaultCloudParams returns the clouds of Minetest
+func DefaultCloudParams() CloudParams {
+	return CloudParams{
+		Density:      0.4,
+		ColorBright:  color.NRGBA{R: 240, G: 240, B: 255, A: 229},
+		ColorAmbient: color.NRGBA{A: 255},
+		ColorShadow:  color.NRGBA{R: 204, G: 204, B: 204, A: 255},
+		Height:       120,
+		Thickness:    16,
+		Speed:        [2]float32{0, -2},
+	}
+}
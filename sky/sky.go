@@ -0,0 +1,188 @@
+// Package sky keeps the time of day and the sky settings sent by the server and derives the light and colors
+// of the sky from them.
+package sky
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Thousandths of an hour in a day
+const DayLength = 24000
+
+// Sky is the thread-safe state of the time of day and the sky settings. The time advances locally between
+// the updates of the server.
+type Sky struct {
+	mu        sync.Mutex
+	timeOfDay float64 // When it was last set
+	timeSpeed float32
+	timeSet   time.Time
+
+	// Day/night ratio forced by the server instead of the one of the time of day
+	ratioOverride bool
+	ratio         float32
+
+	params Params
+	sun    SunParams
+	moon   MoonParams
+	stars  StarParams
+	clouds CloudParams
+
+	version uint64
+}
+
+// New creates a sky at midnight that stays still until the server sets the time
+func New() *Sky {
+	return &Sky{
+		timeSet: time.Now(),
+		params:  DefaultParams(),
+		sun:     DefaultSunParams(),
+		moon:    DefaultMoonParams(),
+		stars:   DefaultStarParams(),
+		clouds:  DefaultCloudParams(),
+	}
+}
+
+// SetTime sets the time of day in thousandths of an hour and how many times faster than real time it passes
+func (s *Sky) SetTime(timeOfDay uint16, speed float32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.timeOfDay = float64(timeOfDay % DayLength)
+	s.timeSpeed = speed
+	s.timeSet = time.Now()
+}
+
+// TimeOfDay returns the current time of day in thousandths of an hour
+func (s *Sky) TimeOfDay() float32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.timeOfDayLocked()
+}
+
+func (s *Sky) timeOfDayLocked() float32 {
+	elapsed := time.Since(s.timeSet).Seconds()
+	t := s.timeOfDay + elapsed*float64(s.timeSpeed)*DayLength/86400
+	return float32(wrap(t))
+}
+
+// TimeSpeed returns how many times faster than real time the day passes
+func (s *Sky) TimeSpeed() float32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.timeSpeed
+}
+
+// OverrideDayNightRatio forces the brightness of the day to ratio, from 0 for night to 1 for day, or
+// returns to following the time of day if override is false
+func (s *Sky) OverrideDayNightRatio(override bool, ratio float32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ratioOverride = override
+	s.ratio = ratio
+	s.version++
+}
+
+// DayNightRatio returns the brightness of the day now, from 0 for night to 1 for day
+func (s *Sky) DayNightRatio() float32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ratioOverride {
+		return s.ratio
+	}
+	return DayNightRatio(s.timeOfDayLocked())
+}
+
+// SetParams changes the sky
+func (s *Sky) SetParams(p Params) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.params = p
+	s.version++
+}
+
+// Params returns the sky settings
+func (s *Sky) Params() Params {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.params
+	p.Textures = append([]string(nil), p.Textures...)
+	return p
+}
+
+// SetSun changes the sun
+func (s *Sky) SetSun(p SunParams) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sun = p
+	s.version++
+}
+
+// Sun returns the sun settings
+func (s *Sky) Sun() SunParams {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sun
+}
+
+// SetMoon changes the moon
+func (s *Sky) SetMoon(p MoonParams) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.moon = p
+	s.version++
+}
+
+// Moon returns the moon settings
+func (s *Sky) Moon() MoonParams {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.moon
+}
+
+// SetStars changes the stars
+func (s *Sky) SetStars(p StarParams) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stars = p
+	s.version++
+}
+
+// Stars returns the star settings
+func (s *Sky) Stars() StarParams {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stars
+}
+
+// SetClouds changes the clouds
+func (s *Sky) SetClouds(p CloudParams) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clouds = p
+	s.version++
+}
+
+// Clouds returns the cloud settings
+func (s *Sky) Clouds() CloudParams {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.clouds
+}
+
+// Version returns a number that changes whenever the settings change. The time of day passing does not
+// change it.
+func (s *Sky) Version() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.version
+}
+
+// wrap brings a time into the range of one day
+func wrap(t float64) float64 {
+	t = math.Mod(t, DayLength)
+	if t < 0 {
+		t += DayLength
+	}
+	return t
+}
@@ -0,0 +1,353 @@
+package sound
+
+import (
+	"io/fs"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"sync"
+
+	"bettermt/main/object"
+)
+
+// Longest time mixed at once, so that a stalled frame does not turn into a burst of sound
+const maxMixTime = 0.25
+
+// Manager is the thread-safe set of sounds playing. Sounds are mixed into the output as time passes in Step.
+type Manager struct {
+	mu      sync.Mutex
+	media   fs.FS
+	objects *object.Manager // Objects sounds follow, may be nil
+	output  Output
+	volume  float32
+
+	buffers  map[string]*Buffer  // Decoded files by name, nil for files that failed to decode
+	variants map[string][]string // Files of each sound name
+
+	sounds   []*playing
+	byServer map[int32]*playing // Sounds the server may stop and fade
+	removed  []int32            // Sounds of the server that ended on their own, to report
+
+	listener [3]float32
+	right    [3]float32 // Direction to the right of the listener
+	pending  float64    // Part of a frame due but not mixed yet
+	mix      []float32
+}
+
+// playing is one sound being played
+type playing struct {
+	buf    *Buffer
+	loop   bool
+	pitch  float32
+	loc    Location
+	pos    [3]float32 // Where the sound was last heard from, kept when its object goes away
+	cursor float64    // Frames played, including the fraction between two frames
+
+	gain       float32
+	fadeStep   float32 // Gain per second the sound moves towards the target, 0 while not fading
+	fadeTarget float32
+
+	serverID int32
+	report   bool // Tell the server once the sound ends
+	done     bool
+}
+
+// NewManager creates a manager loading sounds from media and mixing into output. Sounds attached to objects
+// follow the objects of objects, which may be nil.
+func NewManager(media fs.FS, objects *object.Manager, output Output) *Manager {
+	return &Manager{
+		media:    media,
+		objects:  objects,
+		output:   output,
+		volume:   1,
+		buffers:  make(map[string]*Buffer),
+		variants: make(map[string][]string),
+		byServer: make(map[int32]*playing),
+		right:    [3]float32{1, 0, 0},
+	}
+}
+
+// SetVolume sets the volume every sound is multiplied with, from 0 for silent to 1
+func (m *Manager) SetVolume(volume float32) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.volume = max(0, volume)
+}
+
+// SetListener places the ears the sounds are heard with at a position in nodes, with right pointing to the
+// right ear
+func (m *Manager) SetListener(position, right [3]float32) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.listener, m.right = position, right
+}
+
+// Play plays a sound of the client itself. Sounds without a file are ignored.
+func (m *Manager) Play(spec Spec, loc Location) error {
+	_, err := m.play(spec, loc)
+	return err
+}
+
+// PlayServer plays a sound the server sent with an ID. Unless the sound is ephemeral the server may stop and
+// fade it, and is told once it ends.
+func (m *Manager) PlayServer(id int32, spec Spec, loc Location, ephemeral bool) error {
+	s, err := m.play(spec, loc)
+	if ephemeral {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s == nil {
+		// A sound that cannot be played has already ended
+		m.removed = append(m.removed, id)
+		return err
+	}
+	s.serverID, s.report = id, true
+	if old, exists := m.byServer[id]; exists {
+		old.done, old.report = true, false
+	}
+	m.byServer[id] = s
+	return err
+}
+
+// play starts a sound and returns it, or nil if it has no file
+func (m *Manager) play(spec Spec, loc Location) (*playing, error) {
+	if spec.Name == "" {
+		return nil, nil
+	}
+	buf, err := m.buffer(spec.Name)
+	if buf == nil || buf.Frames() == 0 {
+		return nil, err
+	}
+
+	s := &playing{buf: buf, loop: spec.Loop, pitch: spec.Pitch, loc: loc, pos: loc.Position, gain: spec.Gain}
+	if s.pitch <= 0 {
+		s.pitch = 1
+	}
+	if spec.Fade > 0 {
+		s.gain, s.fadeStep, s.fadeTarget = 0, spec.Fade, spec.Gain
+	}
+	s.cursor = float64(max(0, spec.StartTime)) * float64(buf.SampleRate)
+	if frames := float64(buf.Frames()); s.cursor >= frames {
+		if !s.loop {
+			return nil, nil
+		}
+		s.cursor = math.Mod(s.cursor, frames)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sounds = append(m.sounds, s)
+	return s, nil
+}
+
+// buffer returns one of the variants of a sound picked at random, decoding it the first time. It returns nil
+// if there is no file of that name.
+func (m *Manager) buffer(name string) (*Buffer, error) {
+	m.mu.Lock()
+	files, known := m.variants[name]
+	m.mu.Unlock()
+	if !known {
+		files = variantFiles(m.media, name)
+		m.mu.Lock()
+		m.variants[name] = files
+		m.mu.Unlock()
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	file := files[rand.IntN(len(files))]
+	m.mu.Lock()
+	buf, decoded := m.buffers[file]
+	m.mu.Unlock()
+	if decoded {
+		return buf, nil
+	}
+	// Decoding takes a while, so it happens without holding the lock
+	buf, err := decodeFile(m.media, file)
+	m.mu.Lock()
+	m.buffers[file] = buf
+	m.mu.Unlock()
+	return buf, err
+}
+
+// Stop stops a sound of the server
+func (m *Manager) Stop(id int32) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, exists := m.byServer[id]; exists {
+		s.done, s.report = true, false
+		delete(m.byServer, id)
+	}
+}
+
+// Fade changes the gain of a sound of the server towards a target by step per second. Sounds faded out to
+// silence stop.
+func (m *Manager) Fade(id int32, step, gain float32) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, exists := m.byServer[id]
+	if !exists {
+		return
+	}
+	s.fadeStep, s.fadeTarget = abs(step), max(0, gain)
+	if s.fadeStep == 0 {
+		// Without a step the gain jumps to the target
+		s.gain = s.fadeTarget
+		s.done = s.gain <= 0
+	}
+}
+
+// RemovedSounds returns the IDs of the sounds of the server that ended since the last call, which the server
+// wants to be told about
+func (m *Manager) RemovedSounds() []int32 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	removed := m.removed
+	m.removed = nil
+	return removed
+}
+
+// Playing returns the number of sounds playing
+func (m *Manager) Playing() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.sounds)
+}
+
+// Close stops every sound and closes the output
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sounds = nil
+	clear(m.byServer)
+	return m.output.Close()
+}
+
+// Step mixes dtime seconds of the sounds playing into the output and drops the sounds that ended
+func (m *Manager) Step(dtime float32) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rate := m.output.SampleRate()
+	m.pending += float64(max(0, min(dtime, maxMixTime))) * float64(rate)
+	frames := int(m.pending)
+	m.pending -= float64(frames)
+	if frames == 0 {
+		return nil
+	}
+
+	m.mix = slices.Grow(m.mix[:0], 2*frames)[:2*frames]
+	clear(m.mix)
+	kept := m.sounds[:0]
+	for _, s := range m.sounds {
+		if !s.done {
+			m.fade(s, dtime)
+		}
+		if !s.done {
+			left, right := m.gains(s)
+			s.mixInto(m.mix, rate, left, right)
+		}
+		if !s.done {
+			kept = append(kept, s)
+			continue
+		}
+		if s.report {
+			m.removed = append(m.removed, s.serverID)
+			delete(m.byServer, s.serverID)
+		}
+	}
+	clear(m.sounds[len(kept):])
+	m.sounds = kept
+
+	for i, v := range m.mix {
+		m.mix[i] = max(-1, min(1, v))
+	}
+	return m.output.Write(m.mix)
+}
+
+// fade moves the gain of a fading sound on by dtime seconds
+func (m *Manager) fade(s *playing, dtime float32) {
+	if s.fadeStep == 0 {
+		return
+	}
+	if s.gain < s.fadeTarget {
+		s.gain = min(s.fadeTarget, s.gain+s.fadeStep*dtime)
+	} else {
+		s.gain = max(s.fadeTarget, s.gain-s.fadeStep*dtime)
+	}
+	if s.gain == s.fadeTarget {
+		s.fadeStep = 0
+		s.done = s.gain <= 0
+	}
+}
+
+// gains returns how loud a sound is on the left and right channel. Mono sounds in the world fall off with their
+// distance to the listener and are panned towards their side. Stereo sounds are played as they are, like OpenAL
+// does in Minetest.
+func (m *Manager) gains(s *playing) (left, right float32) {
+	gain := s.gain * m.volume
+	if s.buf.Channels == 2 {
+		return gain, gain
+	}
+
+	if s.loc.Type == LocationObject && m.objects != nil {
+		if o, exists := m.objects.Object(s.loc.ObjectID); exists {
+			s.pos = o.VisualPosition
+		}
+	}
+	var pan float32
+	if s.loc.Type != LocationLocal {
+		var d [3]float32
+		for i := range d {
+			d[i] = s.pos[i] - m.listener[i]
+		}
+		distance := float32(math.Sqrt(float64(d[0]*d[0] + d[1]*d[1] + d[2]*d[2])))
+		gain *= attenuation(distance)
+		if distance > 0 {
+			pan = (d[0]*m.right[0] + d[1]*m.right[1] + d[2]*m.right[2]) / distance
+		}
+	}
+	left, right = panning(pan)
+	return left * gain, right * gain
+}
+
+// mixInto adds the sound to interleaved stereo samples at a sample rate with a gain per channel, resampling it
+// for its pitch. The sound is done once it played to its end without looping.
+func (s *playing) mixInto(out []float32, rate int, left, right float32) {
+	samples, channels := s.buf.Samples, s.buf.Channels
+	frames := s.buf.Frames()
+	step := float64(s.buf.SampleRate) / float64(rate) * float64(s.pitch)
+	for i := 0; i < len(out); i += 2 {
+		if s.cursor >= float64(frames) {
+			if !s.loop {
+				s.done = true
+				return
+			}
+			s.cursor = math.Mod(s.cursor, float64(frames))
+		}
+		f := int(s.cursor)
+		t := float32(s.cursor - float64(f))
+		next := f + 1
+		if next == frames {
+			next = f
+			if s.loop {
+				next = 0
+			}
+		}
+		l := samples[f*channels] + (samples[next*channels]-samples[f*channels])*t
+		r := l
+		if channels == 2 {
+			r = samples[f*2+1] + (samples[next*2+1]-samples[f*2+1])*t
+		}
+		out[i] += l * left
+		out[i+1] += r * right
+		s.cursor += step
+	}
+}
+
+func abs(v float32) float32 {
+	return float32(math.Abs(float64(v)))
+}
@@ -0,0 +1,297 @@
+package sound
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+// Sample rate of the test buffers and output, low enough to count frames by hand
+const testRate = 1000
+
+// memFile is an in-memory file for a WAVOutput to record into
+type memFile struct {
+	data []byte
+	pos  int
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	if end := f.pos + len(p); end > len(f.data) {
+		f.data = append(f.data, make([]byte, end-len(f.data))...)
+	}
+	f.pos += copy(f.data[f.pos:], p)
+	return len(p), nil
+}
+
+func (f *memFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+		f.pos = int(offset)
+	case io.SeekCurrent:
+		f.pos += int(offset)
+	case io.SeekEnd:
+		f.pos = len(f.data) + int(offset)
+	}
+	return int64(f.pos), nil
+}
+
+// frames returns the stereo samples recorded so far
+func (f *memFile) frames() [][2]float32 {
+	pcm := f.data[wavHeaderSize:]
+	frames := make([][2]float32, len(pcm)/4)
+	for i := range frames {
+		for c := range 2 {
+			frames[i][c] = float32(int16(binary.LittleEndian.Uint16(pcm[4*i+2*c:]))) / math.MaxInt16
+		}
+	}
+	return frames
+}
+
+// newTestManager returns a manager mixing into a WAV file, with the listener at the origin facing +z
+func newTestManager(t *testing.T) (*Manager, *memFile) {
+	t.Helper()
+	file := &memFile{}
+	out, err := NewWAVOutput(file, testRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewManager(fstest.MapFS{}, nil, out), file
+}
+
+// addBuffer makes a buffer playable under a name without decoding any file
+func addBuffer(m *Manager, name string, buf *Buffer) {
+	m.variants[name] = []string{name + ".ogg"}
+	m.buffers[name+".ogg"] = buf
+}
+
+// constant returns a mono buffer holding the same value for some frames
+func constant(frames int, v float32) *Buffer {
+	b := &Buffer{SampleRate: testRate, Channels: 1, Samples: make([]float32, frames)}
+	for i := range b.Samples {
+		b.Samples[i] = v
+	}
+	return b
+}
+
+// near tells whether a recorded sample matches a value, allowing for the 16-bit quantization
+func near(got, want float32) bool {
+	return math.Abs(float64(got-want)) < 2e-4
+}
+
+func TestAttenuation(t *testing.T) {
+	center := float32(math.Sqrt(0.5))
+	for _, test := range []struct {
+		distance float32
+		gain     float32
+	}{
+		{0.5, 1}, // Closer than one node is as loud as one node away
+		{1, 1},
+		{2, 0.5},
+		{10, 0.1},
+	} {
+		m, file := newTestManager(t)
+		addBuffer(m, "hum", constant(1000, 0.5))
+		loc := Location{Type: LocationPosition, Position: [3]float32{0, 0, test.distance}}
+		if err := m.Play(Spec{Name: "hum", Gain: 1}, loc); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Step(0.125); err != nil {
+			t.Fatal(err)
+		}
+		frames := file.frames()
+		if len(frames) != 125 {
+			t.Fatalf("mixed %d frames, want 125", len(frames))
+		}
+		want := 0.5 * center * test.gain
+		for _, f := range frames {
+			if !near(f[0], want) || !near(f[1], want) {
+				t.Fatalf("at %v nodes: frame %v, want %v on both channels", test.distance, f, want)
+			}
+		}
+	}
+}
+
+func TestPanning(t *testing.T) {
+	center := float32(math.Sqrt(0.5))
+	for _, test := range []struct {
+		name        string
+		position    [3]float32
+		left, right float32
+	}{
+		{"left", [3]float32{-4, 0, 0}, 1, 0},
+		{"center", [3]float32{0, 0, 4}, center, center},
+		{"right", [3]float32{4, 0, 0}, 0, 1},
+		{"behind", [3]float32{0, 0, -4}, center, center},
+	} {
+		m, file := newTestManager(t)
+		addBuffer(m, "hum", constant(1000, 1))
+		if err := m.Play(Spec{Name: "hum", Gain: 1}, Location{Type: LocationPosition, Position: test.position}); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Step(0.0625); err != nil {
+			t.Fatal(err)
+		}
+		// 4 nodes away the sound is at a quarter of its gain, the power split between the channels
+		f := file.frames()[0]
+		if !near(f[0], test.left/4) || !near(f[1], test.right/4) {
+			t.Errorf("%s: frame %v, want %v", test.name, f, [2]float32{test.left / 4, test.right / 4})
+		}
+		if power := f[0]*f[0] + f[1]*f[1]; !near(power, 1.0/16) {
+			t.Errorf("%s: power %v, want %v", test.name, power, 1.0/16)
+		}
+	}
+}
+
+func TestLocalSoundsAreNotAttenuated(t *testing.T) {
+	m, file := newTestManager(t)
+	m.SetListener([3]float32{100, 0, 0}, [3]float32{1, 0, 0})
+	addBuffer(m, "click", constant(1000, 0.5))
+	if err := m.Play(Spec{Name: "click", Gain: 1}, Location{Type: LocationLocal, Position: [3]float32{-50, 0, 0}}); err != nil {
+		t.Fatal(err)
+	}
+	m.Step(0.0625)
+	if f, want := file.frames()[0], 0.5*float32(math.Sqrt(0.5)); !near(f[0], want) || !near(f[1], want) {
+		t.Fatalf("frame %v, want %v on both channels", f, want)
+	}
+}
+
+func TestLooping(t *testing.T) {
+	// A ramp of 10 frames, so that each frame tells where in the buffer the sound is
+	ramp := &Buffer{SampleRate: testRate, Channels: 1, Samples: make([]float32, 10)}
+	for i := range ramp.Samples {
+		ramp.Samples[i] = float32(i) / 10
+	}
+	center := float32(math.Sqrt(0.5))
+
+	for _, loop := range []bool{false, true} {
+		m, file := newTestManager(t)
+		addBuffer(m, "ramp", ramp)
+		if err := m.Play(Spec{Name: "ramp", Gain: 1, Loop: loop}, Location{}); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Step(0.03125); err != nil {
+			t.Fatal(err)
+		}
+		frames := file.frames()
+		if len(frames) != 31 {
+			t.Fatalf("mixed %d frames, want 31", len(frames))
+		}
+		for i, f := range frames {
+			want := ramp.Samples[i%10] * center
+			if !loop && i >= 10 {
+				want = 0
+			}
+			if !near(f[0], want) {
+				t.Fatalf("loop %v: frame %d is %v, want %v", loop, i, f[0], want)
+			}
+		}
+		if playing := m.Playing(); playing != map[bool]int{false: 0, true: 1}[loop] {
+			t.Fatalf("loop %v: %d sounds playing after 3 lengths", loop, playing)
+		}
+	}
+}
+
+func TestFade(t *testing.T) {
+	center := float32(math.Sqrt(0.5))
+	m, file := newTestManager(t)
+	addBuffer(m, "wind", constant(10000, 1))
+
+	// A fade in from silence reaches the gain after gain/fade seconds
+	if err := m.PlayServer(1, Spec{Name: "wind", Gain: 0.8, Fade: 4}, Location{}, false); err != nil {
+		t.Fatal(err)
+	}
+	var levels []float32
+	step := func() {
+		t.Helper()
+		if err := m.Step(0.125); err != nil {
+			t.Fatal(err)
+		}
+		frames := file.frames()
+		levels = append(levels, frames[len(frames)-1][0]/center)
+	}
+	step()
+	step()
+	m.Fade(1, -2, 0.2) // The sign of the step does not matter
+	step()
+	step()
+	m.Fade(1, 0, 0.6) // Without a step the gain jumps
+	step()
+	m.Fade(1, 4, 0)
+	step()
+	for i, want := range []float32{0.5, 0.8, 0.55, 0.3, 0.6, 0.1} {
+		if !near(levels[i], want) {
+			t.Fatalf("gains %v, want %v at step %d", levels, want, i)
+		}
+	}
+	if removed := m.RemovedSounds(); removed != nil {
+		t.Fatalf("removed %v before the fade out ended", removed)
+	}
+
+	// Fading out to silence ends the sound
+	step()
+	if m.Playing() != 0 {
+		t.Fatal("sound faded to silence still playing")
+	}
+	if removed := m.RemovedSounds(); !slices.Equal(removed, []int32{1}) {
+		t.Fatalf("removed %v, want [1]", removed)
+	}
+}
+
+func TestRemovedSounds(t *testing.T) {
+	m, _ := newTestManager(t)
+	addBuffer(m, "beep", constant(50, 1))
+	addBuffer(m, "hum", constant(1000, 1))
+
+	m.PlayServer(1, Spec{Name: "beep", Gain: 1}, Location{}, false)
+	m.PlayServer(2, Spec{Name: "beep", Gain: 1}, Location{}, true) // Ephemeral
+	m.PlayServer(3, Spec{Name: "hum", Gain: 1}, Location{}, false)
+	m.PlayServer(4, Spec{Name: "hum", Gain: 1}, Location{}, false)
+	m.PlayServer(5, Spec{Name: "missing", Gain: 1}, Location{}, false)
+	m.Stop(4)
+
+	// A sound without a file ends right away
+	if removed := m.RemovedSounds(); !slices.Equal(removed, []int32{5}) {
+		t.Fatalf("removed %v, want [5]", removed)
+	}
+	m.Step(0.0625)
+	if removed := m.RemovedSounds(); !slices.Equal(removed, []int32{1}) {
+		t.Fatalf("removed %v, want [1]: sounds that played to their end", removed)
+	}
+	if removed := m.RemovedSounds(); removed != nil {
+		t.Fatalf("removed %v reported twice", removed)
+	}
+	if playing := m.Playing(); playing != 1 {
+		t.Fatalf("%d sounds playing, want 1", playing)
+	}
+
+	// A sound replaced under the same ID is not reported
+	m.PlayServer(3, Spec{Name: "beep", Gain: 1}, Location{}, false)
+	m.Step(0.25)
+	if removed := m.RemovedSounds(); !slices.Equal(removed, []int32{3}) {
+		t.Fatalf("removed %v, want [3] once", removed)
+	}
+}
+
+func TestWAVHeader(t *testing.T) {
+	m, file := newTestManager(t)
+	addBuffer(m, "hum", constant(1000, 1))
+	m.Play(Spec{Name: "hum", Gain: 1}, Location{})
+	m.Step(0.25)
+	m.Step(0.125)
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+	h := file.data[:wavHeaderSize]
+	if string(h[:4]) != "RIFF" || string(h[8:16]) != "WAVEfmt " || string(h[36:40]) != "data" {
+		t.Fatalf("header %q", h)
+	}
+	if rate := binary.LittleEndian.Uint32(h[24:]); rate != testRate {
+		t.Fatalf("sample rate %d", rate)
+	}
+	if size := binary.LittleEndian.Uint32(h[40:]); size != 375*4 || int(size) != len(file.data)-wavHeaderSize {
+		t.Fatalf("data size %d, want %d", size, 375*4)
+	}
+}
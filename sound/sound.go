@@ -0,0 +1,115 @@
+// Package sound plays the sounds of the server and of the local player. Sounds are decoded from Ogg Vorbis
+// files of the media, attenuated and panned relative to a listener and mixed into an Output.
+package sound
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+
+	"bettermt/main/blocktypes"
+
+	"github.com/jfreymuth/oggvorbis"
+)
+
+// Largest number of variants of a sound, named name.0.ogg to name.9.ogg
+const maxVariants = 10
+
+// Spec is a sound with the parameters it is played with
+type Spec struct {
+	Name      string  // File name without .ogg, or the name of a group of variants one of which is picked
+	Gain      float32 // Volume from 0 for silent, 1 for as recorded
+	Pitch     float32 // Playback speed, 1 for as recorded
+	Fade      float32 // Gain per second the sound fades in with, 0 to start at its gain
+	Loop      bool
+	StartTime float32 // Seconds into the sound to start at
+}
+
+// NodeSpec returns the spec of a sound of a node or item definition
+func NodeSpec(s blocktypes.SoundSpec) Spec {
+	return Spec{Name: s.Name, Gain: s.Gain, Pitch: s.Pitch, Fade: s.Fade}
+}
+
+// LocationType is what a sound is played relative to
+type LocationType uint8
+
+const (
+	LocationLocal    LocationType = iota // At the listener, such as the footsteps of the player
+	LocationPosition                     // At a position in the world
+	LocationObject                       // Following an active object
+)
+
+// Location is where a sound is heard from
+type Location struct {
+	Type     LocationType
+	Position [3]float32 // In nodes
+	ObjectID uint16
+}
+
+// Buffer is a decoded sound
+type Buffer struct {
+	SampleRate int
+	Channels   int       // 1 for mono, 2 for stereo
+	Samples    []float32 // Interleaved by channel, from -1 to 1
+}
+
+// Frames returns the number of samples per channel
+func (b *Buffer) Frames() int {
+	return len(b.Samples) / b.Channels
+}
+
+// Decode reads a whole Ogg Vorbis file. Only mono and stereo files are supported.
+func Decode(r io.Reader) (*Buffer, error) {
+	samples, format, err := oggvorbis.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if format.Channels != 1 && format.Channels != 2 {
+		return nil, fmt.Errorf("%d channels not supported", format.Channels)
+	}
+	return &Buffer{SampleRate: format.SampleRate, Channels: format.Channels, Samples: samples}, nil
+}
+
+// decodeFile decodes a sound file of a filesystem
+func decodeFile(fsys fs.FS, name string) (*Buffer, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	b, err := Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("sound %q: %w", name, err)
+	}
+	return b, nil
+}
+
+// variantFiles returns the files of a sound that exist in fsys: name.ogg and name.0.ogg to name.9.ogg
+func variantFiles(fsys fs.FS, name string) []string {
+	var files []string
+	candidates := []string{name + ".ogg"}
+	for i := range maxVariants {
+		candidates = append(candidates, fmt.Sprintf("%s.%d.ogg", name, i))
+	}
+	for _, candidate := range candidates {
+		if file, err := fsys.Open(candidate); err == nil {
+			file.Close()
+			files = append(files, candidate)
+		}
+	}
+	return files
+}
+
+// attenuation returns the gain of a sound at a distance in nodes, falling off like OpenAL's clamped inverse
+// distance model with a reference distance of one node, as Minetest sets it up
+func attenuation(distance float32) float32 {
+	return 1 / max(1, distance)
+}
+
+// panning returns the gains of the left and right channel for a sound from a direction, where -1 is fully
+// left and 1 fully right. The power of both channels together stays the same.
+func panning(pan float32) (left, right float32) {
+	angle := float64(max(-1, min(1, pan))+1) * math.Pi / 4
+	return float32(math.Cos(angle)), float32(math.Sin(angle))
+}
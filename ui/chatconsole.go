@@ -0,0 +1,258 @@
+package ui
+
+import (
+	"fmt"
+
+	"bettermt/main/chat"
+
+	"github.com/g3n/engine/gui"
+	"github.com/g3n/engine/math32"
+	"github.com/g3n/engine/window"
+)
+
+const (
+	chatVisibleRows = 10
+	chatWidth       = 640
+	chatRowHeight   = 20
+	chatInputHeight = 24
+	chatPadding     = 4
+	chatFontSize    = 16
+	chatScrollStep  = 3   // Rows scrolled per mouse wheel step
+	chatMaxInput    = 500 // Longest line that can be typed
+	chatCollapsed   = " [+]"
+)
+
+// chatRow is one line of text on screen and the message it belongs to
+type chatRow struct {
+	id   uint64
+	text string
+}
+
+// ChatConsole shows the chat history with scrollback and a line to type messages in.
+// Long messages wrap over several rows; clicking one collapses it to a single row and back.
+type ChatConsole struct {
+	*gui.Panel
+
+	history      *chat.History
+	send         func(string) error
+	inputHistory *chat.InputHistory
+
+	rows   []*gui.Label
+	rowIDs []uint64
+	input  *gui.Edit
+
+	wrapped   map[uint64][]string // Wrapped lines by message ID
+	collapsed map[uint64]bool
+	scroll    int // Rows scrolled back from the newest
+	version   uint64
+	dirty     bool
+	open      bool
+}
+
+// NewChatConsole creates a console showing history; send is called with every line the player enters
+func NewChatConsole(history *chat.History, send func(string) error) *ChatConsole {
+	c := &ChatConsole{
+		Panel:        gui.NewPanel(chatWidth, chatVisibleRows*chatRowHeight+chatInputHeight),
+		history:      history,
+		send:         send,
+		inputHistory: chat.NewInputHistory(chat.DefaultInputHistoryLimit),
+		rowIDs:       make([]uint64, chatVisibleRows),
+		wrapped:      make(map[uint64][]string),
+		collapsed:    make(map[uint64]bool),
+		dirty:        true,
+	}
+
+	for i := 0; i < chatVisibleRows; i++ {
+		row := gui.NewLabel("")
+		row.SetFontSize(chatFontSize)
+		row.SetColor(math32.NewColor("White"))
+		row.SetPosition(chatPadding, float32(i*chatRowHeight))
+		index := i
+		row.Subscribe(gui.OnMouseDown, func(evname string, ev interface{}) {
+			c.toggleCollapsed(c.rowIDs[index])
+		})
+		c.rows = append(c.rows, row)
+		c.Add(row)
+	}
+
+	c.input = gui.NewEdit(chatWidth-2*chatPadding, "Type a message or /command")
+	c.input.MaxLength = chatMaxInput
+	c.input.SetFontSize(chatFontSize)
+	c.input.SetPosition(chatPadding, chatVisibleRows*chatRowHeight)
+	c.input.Subscribe(gui.OnKeyDown, c.onInputKey)
+	c.input.Subscribe(gui.OnKeyRepeat, c.onInputKey)
+	c.Add(c.input)
+
+	c.Subscribe(gui.OnScroll, c.onScroll)
+	gui.Manager().SubscribeID(gui.OnKeyUp, c, c.onKeyUp)
+
+	c.setOpen(false)
+	return c
+}
+
+// Open shows the input line with the given text and gives it the keyboard
+func (c *ChatConsole) Open(text string) {
+	c.setOpen(true)
+	c.input.SetText(text)
+	c.input.CursorEnd()
+	gui.Manager().SetKeyFocus(c.input)
+}
+
+// Close hides the input line and returns to the newest messages
+func (c *ChatConsole) Close() {
+	c.setOpen(false)
+	gui.Manager().SetKeyFocus(nil)
+}
+
+// IsOpen reports whether the player is typing in the console
+func (c *ChatConsole) IsOpen() bool {
+	return c.open
+}
+
+func (c *ChatConsole) setOpen(open bool) {
+	c.open = open
+	c.input.SetVisible(open)
+	if open {
+		c.SetColor4(&math32.Color4{R: 0, G: 0, B: 0, A: 0.4})
+	} else {
+		c.SetColor4(&math32.Color4{R: 0, G: 0, B: 0, A: 0})
+		c.scroll = 0
+		c.dirty = true
+	}
+}
+
+// Update redraws the rows when messages arrived or the view changed. Call it from the render thread.
+func (c *ChatConsole) Update() {
+	if version := c.history.Version(); version != c.version {
+		c.version = version
+		c.dirty = true
+	}
+	if !c.dirty {
+		return
+	}
+	c.dirty = false
+	c.layout()
+}
+
+// layout fills the rows with the lines at the current scroll position
+func (c *ChatConsole) layout() {
+	messages := c.history.Messages()
+
+	// Forget messages that dropped out of the history
+	kept := make(map[uint64][]string, len(messages))
+	var lines []chatRow
+	for _, m := range messages {
+		wrapped, exists := c.wrapped[m.ID]
+		if !exists {
+			wrapped = chat.Wrap(m.String(), chatWidth-2*chatPadding, c.measure)
+		}
+		kept[m.ID] = wrapped
+
+		if len(wrapped) > 1 && c.collapsed[m.ID] {
+			lines = append(lines, chatRow{m.ID, wrapped[0] + chatCollapsed})
+			continue
+		}
+		for _, line := range wrapped {
+			lines = append(lines, chatRow{m.ID, line})
+		}
+	}
+	c.wrapped = kept
+	for id := range c.collapsed {
+		if _, exists := kept[id]; !exists {
+			delete(c.collapsed, id)
+		}
+	}
+
+	c.scroll = max(0, min(c.scroll, len(lines)-chatVisibleRows))
+	end := len(lines) - c.scroll
+	visible := lines[max(0, end-chatVisibleRows):end]
+
+	// The newest line sits right above the input line
+	offset := chatVisibleRows - len(visible)
+	for i, row := range c.rows {
+		if i < offset {
+			row.SetText("")
+			c.rowIDs[i] = 0
+			continue
+		}
+		row.SetText(visible[i-offset].text)
+		c.rowIDs[i] = visible[i-offset].id
+	}
+}
+
+// measure returns the width of text drawn with the font of the rows
+func (c *ChatConsole) measure(text string) float32 {
+	font := c.rows[0].Font()
+	font.SetPointSize(c.rows[0].FontSize())
+	font.SetDPI(c.rows[0].FontDPI())
+	width, _ := font.MeasureText(text)
+	return float32(width)
+}
+
+// toggleCollapsed switches a message between wrapped and single row display
+func (c *ChatConsole) toggleCollapsed(id uint64) {
+	if id == 0 || len(c.wrapped[id]) < 2 {
+		return
+	}
+	c.collapsed[id] = !c.collapsed[id]
+	c.dirty = true
+}
+
+// scrollBy moves the view back in history by rows, or forward if negative
+func (c *ChatConsole) scrollBy(rows int) {
+	c.scroll = max(0, c.scroll+rows)
+	c.dirty = true
+}
+
+// onKeyUp opens the console on the chat and command keys. Opening on release keeps the key out of the input line.
+func (c *ChatConsole) onKeyUp(evname string, ev interface{}) {
+	if c.open {
+		return
+	}
+	switch ev.(*window.KeyEvent).Key {
+	case window.KeyT:
+		c.Open("")
+	case window.KeySlash:
+		c.Open("/")
+	}
+}
+
+// onInputKey handles the keys of the input line that are not about editing text
+func (c *ChatConsole) onInputKey(evname string, ev interface{}) {
+	switch ev.(*window.KeyEvent).Key {
+	case window.KeyEnter, window.KeyKPEnter:
+		c.submit()
+	case window.KeyEscape:
+		c.Close()
+	case window.KeyUp:
+		c.input.SetText(c.inputHistory.Prev(c.input.Text()))
+		c.input.CursorEnd()
+	case window.KeyDown:
+		c.input.SetText(c.inputHistory.Next())
+		c.input.CursorEnd()
+	case window.KeyPageUp:
+		c.scrollBy(chatVisibleRows - 1)
+	case window.KeyPageDown:
+		c.scrollBy(-(chatVisibleRows - 1))
+	}
+}
+
+// onScroll scrolls the history with the mouse wheel
+func (c *ChatConsole) onScroll(evname string, ev interface{}) {
+	if steps := ev.(*window.ScrollEvent).Yoffset; steps != 0 {
+		c.scrollBy(int(steps) * chatScrollStep)
+	}
+}
+
+// submit sends the typed line and closes the console
+func (c *ChatConsole) submit() {
+	line := c.input.Text()
+	c.inputHistory.Add(line)
+	c.Close()
+	if line == "" {
+		return
+	}
+	if err := c.send(line); err != nil {
+		c.history.Add(chat.Message{Type: chat.TypeSystem, Text: fmt.Sprintf("Could not send message: %v", err)})
+	}
+}
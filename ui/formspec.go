@@ -0,0 +1,345 @@
+package ui
+
+import (
+	"fmt"
+	"io/fs"
+
+	"bettermt/main/client"
+	"bettermt/main/formspec"
+	"bettermt/main/hud"
+	"bettermt/main/inventory"
+
+	"github.com/g3n/engine/gui"
+	"github.com/g3n/engine/math32"
+	"github.com/g3n/engine/window"
+)
+
+// Field sent when a formspec closes
+const quitField = "quit"
+
+// Color behind formspecs that set none, like Minetest's default bgcolor
+var defaultFormspecBg = math32.Color4{R: 0, G: 0, B: 0, A: 140.0 / 255}
+
+// FormspecView shows the formspecs of the server as modal windows and sends back what the player entered.
+// The requests live in the client's formspec.State; this only turns them into gui widgets.
+type FormspecView struct {
+	*gui.Panel
+	*imageCache
+
+	state  *formspec.State
+	client *client.Client
+
+	form     *formspecForm // Formspec shown, nil while none is
+	held     *heldItem     // Items picked up from a list and not yet put down
+	heldIcon *gui.Panel    // Draws the held items at the cursor
+	tooltip  *gui.Label
+
+	scaling  float32 // Multiplies the size of inventory slots, like gui_scaling
+	requests uint64  // Requests of the server handled so far
+	version  uint64
+	cursor   [2]float32
+}
+
+// heldItem is what the player picked up from a slot to put into another one
+type heldItem struct {
+	from  inventory.Slot
+	item  inventory.ItemStack
+	count uint16
+	drag  bool // Still dragged with the button that picked it up held
+}
+
+// NewFormspecView creates a view showing the formspecs the server sends to c, with textures loaded from textures
+func NewFormspecView(c *client.Client, textures fs.FS) *FormspecView {
+	v := &FormspecView{
+		Panel:      gui.NewPanel(0, 0),
+		imageCache: newImageCache(textures),
+		state:      c.Formspecs,
+		client:     c,
+		scaling:    1,
+		heldIcon:   gui.NewPanel(0, 0),
+		tooltip:    gui.NewLabel(""),
+	}
+	v.heldIcon.SetEnabled(false)
+	v.tooltip.SetFontSize(hudFontSize)
+	v.tooltip.SetColor(math32.NewColor("White"))
+	v.tooltip.SetBgColor4(&math32.Color4{R: 0.1, G: 0.1, B: 0.1, A: 0.9})
+	v.tooltip.SetPaddings(2, 4, 2, 4)
+	v.tooltip.SetEnabled(false)
+
+	// Clicks beside the formspec drop the held items
+	v.Subscribe(gui.OnMouseDown, v.onMouseDownOutside)
+	window.Get().SubscribeID(window.OnKeyDown, v, v.onKeyDown)
+	window.Get().SubscribeID(window.OnCursor, v, v.onCursor)
+	v.SetVisible(false)
+	return v
+}
+
+// Resize makes the view cover a screen of the given size and lays out the open formspec again
+func (v *FormspecView) Resize(width, height float32) {
+	v.SetSize(width, height)
+	if v.form != nil {
+		v.rebuild()
+	}
+}
+
+// SetScaling changes the factor inventory slots are scaled by, like gui_scaling
+func (v *FormspecView) SetScaling(scaling float32) {
+	v.scaling = scaling
+	if v.form != nil {
+		v.rebuild()
+	}
+}
+
+// IsOpen reports whether a formspec is shown, which then has the mouse and keyboard
+func (v *FormspecView) IsOpen() bool {
+	return v.form != nil
+}
+
+// Update opens and closes the formspecs the server asked for, lays out the open one again when the server
+// changed it, and redraws its lists when the inventory changed. Call it from the render thread.
+func (v *FormspecView) Update() error {
+	if request, requests := v.state.Request(); requests != v.requests {
+		v.requests = requests
+		if request.Formspec != "" {
+			return v.Show(request.FormName, request.Formspec)
+		}
+		if v.form != nil && v.form.name == request.FormName {
+			v.Close()
+		}
+	}
+	if version := v.state.Version(); version != v.version {
+		v.version = version
+		if v.form != nil && v.form.name == "" {
+			v.form.source = v.inventorySource()
+			v.rebuild()
+		}
+	}
+	if v.form != nil {
+		v.form.updateLists()
+	}
+	return nil
+}
+
+// Show opens a formspec in place of the one shown. The inventory formspec has an empty form name.
+func (v *FormspecView) Show(formName, source string) error {
+	if v.form != nil {
+		v.Close()
+	}
+	v.form = &formspecForm{view: v, name: formName, source: source}
+	if err := v.rebuild(); err != nil {
+		v.form = nil
+		return err
+	}
+	v.SetVisible(true)
+	gui.Manager().SetModal(v)
+	// Keys go to the formspec rather than to the chat console and the player
+	if !v.form.focusRequested() {
+		gui.Manager().SetKeyFocus(v)
+	}
+	return nil
+}
+
+// ShowInventory opens the inventory formspec of the player
+func (v *FormspecView) ShowInventory() error {
+	return v.Show("", v.inventorySource())
+}
+
+// inventorySource returns the inventory formspec the server gave, or the default one with a crafting grid
+func (v *FormspecView) inventorySource() string {
+	if spec := v.state.Inventory(); spec != "" {
+		return spec
+	}
+	return formspec.DefaultInventory
+}
+
+// Close closes the formspec shown, telling the server
+func (v *FormspecView) Close() {
+	if v.form == nil {
+		return
+	}
+	fields := v.form.values()
+	fields[quitField] = "true"
+	if err := v.client.SendFields(v.form.name, fields); err != nil {
+		fmt.Println("Could not send formspec fields:", err)
+	}
+	v.RemoveAll(false)
+	v.form.dispose()
+	v.form = nil
+	v.held = nil
+	v.SetVisible(false)
+	gui.Manager().SetModal(nil)
+	gui.Manager().SetKeyFocus(nil)
+}
+
+// submit sends the values of the fields along with the ones of the event, closing the formspec if quit is set
+func (v *FormspecView) submit(event map[string]string, quit bool) {
+	if v.form == nil {
+		return
+	}
+	if quit {
+		// Closing sends the fields with the quit field
+		for name, value := range event {
+			v.form.pending[name] = value
+		}
+		v.Close()
+		return
+	}
+	fields := v.form.values()
+	for name, value := range event {
+		fields[name] = value
+	}
+	if err := v.client.SendFields(v.form.name, fields); err != nil {
+		fmt.Println("Could not send formspec fields:", err)
+	}
+}
+
+// rebuild parses the formspec shown again and recreates its widgets for the size of the screen, keeping what
+// the player entered
+func (v *FormspecView) rebuild() error {
+	f, err := formspec.Parse(v.form.source)
+	if err != nil {
+		return err
+	}
+	if err := f.Prepend(v.state.Prepend()); err != nil {
+		return err
+	}
+	kept := v.form.snapshot()
+	v.RemoveAll(false)
+	v.form.dispose()
+	v.form.build(f, formspec.NewLayout(f, v.Width(), v.Height(), v.scaling), kept)
+	v.Add(v.form)
+	v.Add(v.heldIcon)
+	v.Add(v.tooltip)
+	v.tooltip.SetVisible(false)
+	v.drawHeld()
+	return nil
+}
+
+// pick takes up count items of a slot, or puts the held items down first if there are any
+func (v *FormspecView) pick(slot inventory.Slot, item inventory.ItemStack, count uint16, drag bool) {
+	if item.IsEmpty() || count == 0 {
+		return
+	}
+	v.held = &heldItem{from: slot, item: item, count: min(count, item.Count), drag: drag}
+	v.drawHeld()
+	v.form.redrawLists()
+}
+
+// put moves count of the held items into a slot, keeping the rest held
+func (v *FormspecView) put(to inventory.Slot, count uint16) {
+	if v.held == nil {
+		return
+	}
+	count = min(count, v.held.count)
+	if to != v.held.from {
+		m := inventory.Move{Count: count, From: v.held.from, To: to}
+		if err := v.client.MoveItem(m); err != nil {
+			fmt.Println("Could not move items:", err)
+		}
+		v.held.count -= count
+		v.held.item.Count -= count
+	}
+	if v.held.count == 0 || to == v.held.from {
+		v.held = nil
+	}
+	v.drawHeld()
+	v.form.redrawLists()
+}
+
+// moveSomewhere moves a whole stack to the next list of the list ring, as shift clicks do
+func (v *FormspecView) moveSomewhere(from inventory.Slot) {
+	to, ok := v.form.ringTarget(from)
+	if !ok {
+		return
+	}
+	m := inventory.Move{From: from, To: to, Somewhere: true}
+	if err := v.client.MoveItem(m); err != nil {
+		fmt.Println("Could not move items:", err)
+	}
+	v.form.redrawLists()
+}
+
+// drawHeld draws the held items at the cursor
+func (v *FormspecView) drawHeld() {
+	v.heldIcon.DisposeChildren(true)
+	if v.held == nil || v.form == nil {
+		v.heldIcon.SetVisible(false)
+		return
+	}
+	size := v.form.layout.ImgSize
+	item := v.held.item
+	item.Count = v.held.count
+	v.heldIcon.SetSize(size, size)
+	v.addItem(v.heldIcon, hud.Rect{Width: size, Height: size}, item, v.client.ItemDefs(), v.scaling)
+	v.heldIcon.SetVisible(true)
+	v.moveHeld()
+}
+
+// moveHeld centers the held items on the cursor
+func (v *FormspecView) moveHeld() {
+	v.heldIcon.SetPosition(v.cursor[0]-v.heldIcon.Width()/2, v.cursor[1]-v.heldIcon.Height()/2)
+}
+
+// showTooltip shows text next to the cursor, or hides the tooltip if text is empty
+func (v *FormspecView) showTooltip(text string) {
+	if text == "" {
+		v.tooltip.SetVisible(false)
+		return
+	}
+	v.tooltip.SetText(text)
+	v.tooltip.SetVisible(true)
+	v.moveTooltip()
+}
+
+func (v *FormspecView) moveTooltip() {
+	x := min(v.cursor[0]+16, v.Width()-v.tooltip.Width())
+	y := min(v.cursor[1]+16, v.Height()-v.tooltip.Height())
+	v.tooltip.SetPosition(max(0, x), max(0, y))
+}
+
+// onMouseDownOutside drops the held items into the world when the player clicks beside the formspec
+func (v *FormspecView) onMouseDownOutside(evname string, ev interface{}) {
+	if v.held == nil {
+		return
+	}
+	count := v.held.count
+	if ev.(*window.MouseEvent).Button == window.MouseButtonRight {
+		count = 1
+	}
+	if err := v.client.DropItem(inventory.Drop{Count: count, From: v.held.from}); err != nil {
+		fmt.Println("Could not drop items:", err)
+	}
+	v.held.count -= count
+	v.held.item.Count -= count
+	if v.held.count == 0 {
+		v.held = nil
+	}
+	v.drawHeld()
+	v.form.redrawLists()
+}
+
+// onKeyDown closes the formspec on escape, whichever widget has the keyboard
+func (v *FormspecView) onKeyDown(evname string, ev interface{}) {
+	if v.form == nil || ev.(*window.KeyEvent).Key != window.KeyEscape {
+		return
+	}
+	// Escape puts held items back first
+	if v.held != nil {
+		v.held = nil
+		v.drawHeld()
+		v.form.redrawLists()
+		return
+	}
+	v.Close()
+}
+
+func (v *FormspecView) onCursor(evname string, ev interface{}) {
+	cev := ev.(*window.CursorEvent)
+	v.cursor = [2]float32{cev.Xpos, cev.Ypos}
+	if v.held != nil {
+		v.moveHeld()
+	}
+	if v.tooltip.Visible() {
+		v.moveTooltip()
+	}
+}
@@ -0,0 +1,193 @@
+package ui
+
+import (
+	"strings"
+
+	"bettermt/main/formspec"
+	"bettermt/main/hud"
+	"bettermt/main/inventory"
+
+	"github.com/g3n/engine/gui"
+	"github.com/g3n/engine/math32"
+	"github.com/g3n/engine/window"
+)
+
+// Colors of slots without listcolors[], like Minetest's
+var (
+	defaultSlotBg      = math32.Color4{R: 0.5, G: 0.5, B: 0.5, A: 1}
+	defaultSlotBgHover = math32.Color4{R: 0.75, G: 0.75, B: 0.75, A: 1}
+)
+
+// formspecList is a list[], drawn from the inventory of the player if the list belongs to it. Lists of other
+// inventories are shown empty, but items are still put into them.
+type formspecList struct {
+	owned bool
+	slots []*formspecSlot
+}
+
+// formspecSlot is one slot of a list
+type formspecSlot struct {
+	*gui.Panel
+	slot inventory.Slot
+	item inventory.ItemStack // Shown in the slot, without the held items
+}
+
+func (f *formspecForm) addList(parent *gui.Panel, e *formspec.List, l formspec.Layout) {
+	list := &formspecList{owned: f.view.client.OwnsLocation(e.Location)}
+	bg, hover, border := defaultSlotBg, defaultSlotBgHover, math32.Color4{}
+	if f.listColors != nil {
+		if c, ok := formspec.ParseColor(f.listColors.SlotBg); ok {
+			bg = color4(c)
+		}
+		if c, ok := formspec.ParseColor(f.listColors.SlotBgHover); ok {
+			hover = color4(c)
+		}
+		if c, ok := formspec.ParseColor(f.listColors.SlotBorder); ok {
+			border = color4(c)
+		}
+	}
+
+	width, height := int(e.Size[0]), int(e.Size[1])
+	for row := range height {
+		for column := range width {
+			s := &formspecSlot{
+				Panel: gui.NewPanel(0, 0),
+				slot:  inventory.Slot{Location: e.Location, List: e.ListName, Index: e.Start + row*width + column},
+			}
+			place(s, hud.Rect(l.Slot(e.Pos, column, row)))
+			s.SetColor4(&bg)
+			if border.A > 0 {
+				s.SetBorders(1, 1, 1, 1)
+				s.SetBordersColor4(&border)
+			}
+			s.Subscribe(gui.OnCursorEnter, func(string, interface{}) {
+				s.SetColor4(&hover)
+				f.view.showTooltip(f.itemTooltip(s.item))
+			})
+			s.Subscribe(gui.OnCursorLeave, func(string, interface{}) {
+				s.SetColor4(&bg)
+				f.view.showTooltip("")
+			})
+			s.Subscribe(gui.OnMouseDown, func(_ string, ev interface{}) { f.onSlotDown(s, ev.(*window.MouseEvent)) })
+			s.Subscribe(gui.OnMouseUp, func(string, interface{}) { f.onSlotUp(s) })
+			parent.Add(s)
+			list.slots = append(list.slots, s)
+		}
+	}
+	f.lists = append(f.lists, list)
+}
+
+// addListRing adds a list to the ring shift clicks move items along, or the last two lists if it names none
+func (f *formspecForm) addListRing(e *formspec.ListRing) {
+	if e.Location != "" || e.ListName != "" {
+		f.ring = append(f.ring, inventory.Slot{Location: e.Location, List: e.ListName})
+		return
+	}
+	for _, list := range f.lists[max(0, len(f.lists)-2):] {
+		if len(list.slots) > 0 {
+			slot := list.slots[0].slot
+			f.ring = append(f.ring, inventory.Slot{Location: slot.Location, List: slot.List})
+		}
+	}
+}
+
+// ringTarget returns the list after the one of a slot in the list ring
+func (f *formspecForm) ringTarget(from inventory.Slot) (inventory.Slot, bool) {
+	for i, s := range f.ring {
+		if s.Location == from.Location && s.List == from.List {
+			return f.ring[(i+1)%len(f.ring)], true
+		}
+	}
+	return inventory.Slot{}, false
+}
+
+// itemTooltip returns the first line of the description of an item
+func (f *formspecForm) itemTooltip(item inventory.ItemStack) string {
+	if item.IsEmpty() {
+		return ""
+	}
+	text := item.Name
+	if itemDefs := f.view.client.ItemDefs(); itemDefs != nil {
+		if def := itemDefs.Get(item.Name); def != nil && def.Description != "" {
+			text = def.Description
+		}
+	}
+	text, _, _ = strings.Cut(text, "\n")
+	return text
+}
+
+// updateLists redraws the lists if the inventory of the player changed
+func (f *formspecForm) updateLists() {
+	if f.Panel == nil || len(f.lists) == 0 {
+		return
+	}
+	inv := f.view.client.Inventory()
+	if key := inv.Serialize(); key != f.invKey {
+		f.inv, f.invKey = inv, key
+		f.redrawLists()
+	}
+}
+
+// redrawLists draws the items of the inventory into the slots of the lists, leaving out the held items
+func (f *formspecForm) redrawLists() {
+	if f.Panel == nil {
+		return
+	}
+	itemDefs := f.view.client.ItemDefs()
+	for _, list := range f.lists {
+		for _, s := range list.slots {
+			s.DisposeChildren(true)
+			s.item = inventory.ItemStack{}
+			if !list.owned || f.inv == nil {
+				continue
+			}
+			if items := f.inv.List(s.slot.List); items != nil && s.slot.Index < len(items.Items) {
+				s.item = items.Items[s.slot.Index]
+			}
+			if held := f.view.held; held != nil && held.from == s.slot {
+				s.item.Count -= min(s.item.Count, held.count)
+				if s.item.Count == 0 {
+					s.item = inventory.ItemStack{}
+				}
+			}
+			if !s.item.IsEmpty() {
+				f.view.addItem(s.Panel, hud.Rect{Width: s.ContentWidth(), Height: s.ContentHeight()}, s.item, itemDefs, f.view.scaling)
+			}
+		}
+	}
+}
+
+// onSlotDown picks items up from a slot, all of them with the left button and half with the right one, or
+// puts the held items down, all of them with the left button and one with the right one. Shift clicks move
+// the stack to the next list of the list ring.
+func (f *formspecForm) onSlotDown(s *formspecSlot, ev *window.MouseEvent) {
+	v := f.view
+	if v.held == nil {
+		switch {
+		case ev.Mods&window.ModShift != 0:
+			v.moveSomewhere(s.slot)
+		case ev.Button == window.MouseButtonRight:
+			v.pick(s.slot, s.item, (s.item.Count+1)/2, false)
+		default:
+			v.pick(s.slot, s.item, s.item.Count, true)
+		}
+		return
+	}
+	count := v.held.count
+	if ev.Button == window.MouseButtonRight {
+		count = 1
+	}
+	v.put(s.slot, count)
+}
+
+// onSlotUp puts dragged items down into the slot the button is released over
+func (f *formspecForm) onSlotUp(s *formspecSlot) {
+	v := f.view
+	if v.held == nil || !v.held.drag {
+		return
+	}
+	v.held.drag = false
+	if s.slot != v.held.from {
+		v.put(s.slot, v.held.count)
+	}
+}
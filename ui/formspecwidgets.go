@@ -0,0 +1,855 @@
+package ui
+
+import (
+	"fmt"
+	"image"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"bettermt/main/chat"
+	"bettermt/main/formspec"
+	"bettermt/main/hud"
+	"bettermt/main/inventory"
+
+	"github.com/g3n/engine/gui"
+	"github.com/g3n/engine/math32"
+	"github.com/g3n/engine/window"
+)
+
+// Defaults of scrollbaroptions[]
+const (
+	defaultScrollbarMin = 0
+	defaultScrollbarMax = 1000
+)
+
+// Longest text a field takes
+const formspecMaxInput = 1 << 16
+
+// Color behind the screen when bgcolor[] asks for a fullscreen background without giving its color
+var defaultFullscreenBg = math32.Color4{R: 0, G: 0, B: 0, A: 140.0 / 255}
+
+// Tags of hypertext[], which is shown as plain text
+var hypertextTag = regexp.MustCompile(`<[^<>]*>`)
+
+// formspecForm is the window of one formspec, holding its widgets and what they hold
+type formspecForm struct {
+	*gui.Panel
+	view   *FormspecView
+	name   string // Form name, empty for the inventory formspec
+	source string
+	layout formspec.Layout
+
+	back  *gui.Panel // Backgrounds, below everything else
+	areas *gui.Panel // Tooltips of areas, below the widgets
+
+	inputs       map[string]func() string // Fields sent with every event
+	pending      map[string]string        // Values of the event that closes the formspec
+	checkboxes   map[string]*gui.CheckRadio
+	named        map[string]*gui.Panel // Widgets tooltips can refer to
+	closeOnEnter map[string]bool
+	scrollbars   map[string]*formspecScrollbar
+	scrolls      []formspecScroll
+	lists        []*formspecList
+	ring         []inventory.Slot // Lists of listring[] in order
+	focus        string
+	inv          *inventory.Inventory // Inventory the lists show
+	invKey       string
+
+	// State of the elements built so far that affects the ones after them
+	real             bool
+	styles           []*formspec.Style
+	listColors       *formspec.ListColors
+	scrollbarOptions []formspec.Property
+	tableColumns     []formspec.TableColumn
+	simpleFields     int
+	kept             map[string]string
+}
+
+// formspecScrollbar is a scrollbar[] with the scroll containers it moves
+type formspecScrollbar struct {
+	bar      *gui.ScrollBar
+	min, max int
+	last     int
+	moved    []func(value int)
+}
+
+// formspecScroll is a scroll container waiting for its scrollbar, which may come after it
+type formspecScroll struct {
+	scrollbar string
+	moved     func(value int)
+	wheel     *gui.Panel
+}
+
+func (s *formspecScrollbar) value() int {
+	return s.min + int(float32(s.bar.Value())*float32(s.max-s.min)+0.5)
+}
+
+func (s *formspecScrollbar) setValue(value int) {
+	if s.max > s.min {
+		s.bar.SetValue(float32(value-s.min) / float32(s.max-s.min))
+	}
+}
+
+// values returns the fields sent with every event and the ones of the event closing the formspec
+func (f *formspecForm) values() map[string]string {
+	fields := make(map[string]string, len(f.inputs)+len(f.pending))
+	for name, value := range f.inputs {
+		fields[name] = value()
+	}
+	for name, value := range f.pending {
+		fields[name] = value
+	}
+	return fields
+}
+
+// snapshot returns what the player entered, to fill in when the formspec is laid out again
+func (f *formspecForm) snapshot() map[string]string {
+	kept := f.values()
+	for name, checkbox := range f.checkboxes {
+		kept[name] = strconv.FormatBool(checkbox.Value())
+	}
+	for name, scrollbar := range f.scrollbars {
+		kept[name] = strconv.Itoa(scrollbar.value())
+	}
+	return kept
+}
+
+// dispose frees the widgets of the form
+func (f *formspecForm) dispose() {
+	if f == nil || f.Panel == nil {
+		return
+	}
+	f.DisposeChildren(true)
+	f.Dispose()
+	f.Panel = nil
+}
+
+// build creates the widgets of a formspec laid out by l, filling in the values kept from before
+func (f *formspecForm) build(spec *formspec.Formspec, l formspec.Layout, kept map[string]string) {
+	f.Panel = gui.NewPanel(l.Bounds.Width, l.Bounds.Height)
+	f.SetPosition(l.Bounds.X, l.Bounds.Y)
+	f.SetColor4(&defaultFormspecBg)
+	f.view.SetColor4(&math32.Color4{})
+	// Clicks on the formspec must not reach the view, which drops the held items
+	f.Subscribe(gui.OnMouseDown, func(string, interface{}) {})
+	f.Subscribe(gui.OnMouseUp, func(string, interface{}) { f.endDrag() })
+	f.layout = l
+	f.back = gui.NewPanel(l.Bounds.Width, l.Bounds.Height)
+	f.back.SetEnabled(false)
+	f.Add(f.back)
+	f.areas = gui.NewPanel(l.Bounds.Width, l.Bounds.Height)
+	f.Add(f.areas)
+
+	f.inputs = make(map[string]func() string)
+	f.pending = make(map[string]string)
+	f.checkboxes = make(map[string]*gui.CheckRadio)
+	f.named = make(map[string]*gui.Panel)
+	f.closeOnEnter = make(map[string]bool)
+	f.scrollbars = make(map[string]*formspecScrollbar)
+	f.scrolls, f.lists, f.ring, f.focus = nil, nil, nil, ""
+	f.inv, f.invKey = nil, ""
+	f.real = spec.Version >= 2
+	f.styles, f.listColors, f.scrollbarOptions, f.tableColumns = nil, nil, nil, nil
+	f.simpleFields = 0
+	f.kept = kept
+
+	var tooltips []*formspec.Tooltip
+	f.addElements(f.Panel, spec.Elements, l, &tooltips)
+	if f.simpleFields > 0 && !hasSize(spec) {
+		f.addProceed()
+	}
+	for _, s := range f.scrolls {
+		f.linkScroll(s)
+	}
+	for _, t := range tooltips {
+		f.addTooltip(t)
+	}
+	f.kept = nil
+	f.updateLists()
+}
+
+// addElements adds the widgets of elements to parent, in which l places them
+func (f *formspecForm) addElements(parent *gui.Panel, elements []formspec.Element, l formspec.Layout, tooltips *[]*formspec.Tooltip) {
+	for _, e := range elements {
+		l := l.WithReal(f.real)
+		switch e := e.(type) {
+		case *formspec.RealCoordinates:
+			f.real = e.Enabled
+		case *formspec.Container:
+			f.addElements(parent, e.Elements, l.Within(e.Pos), tooltips)
+		case *formspec.ScrollContainer:
+			f.addScrollContainer(parent, e, l, tooltips)
+		case *formspec.List:
+			f.addList(parent, e, l)
+		case *formspec.ListRing:
+			f.addListRing(e)
+		case *formspec.ListColors:
+			f.listColors = e
+		case *formspec.Tooltip:
+			// Tooltips of areas are in the coordinates of where they are
+			if e.Element == "" {
+				f.addAreaTooltip(e, l)
+			} else {
+				*tooltips = append(*tooltips, e)
+			}
+		case *formspec.Image:
+			if img := f.view.image(e.Texture); img != nil {
+				addImage(parent, img, hud.Rect(l.Image(e.Pos, e.Size)))
+			}
+		case *formspec.ItemImage:
+			f.addItemImage(parent, e.Item, l.Image(e.Pos, e.Size))
+		case *formspec.BgColor:
+			f.setBgColor(e)
+		case *formspec.Background:
+			if img := f.view.image(e.Texture); img != nil {
+				addImage(f.back, img, hud.Rect(l.Background(e))).SetBounded(false)
+			}
+		case *formspec.Box:
+			f.addBox(parent, e, l)
+		case *formspec.Label:
+			f.addLabel(parent, e, l)
+		case *formspec.Hypertext:
+			text := hypertextTag.ReplaceAllString(e.Text, "")
+			f.addText(parent, l.Widget(e.Pos, e.Size), text, f.textColor(e.Type(), e.Name))
+		case *formspec.Style:
+			f.styles = append(f.styles, e)
+		case *formspec.SetFocus:
+			f.focus = e.Name
+		case *formspec.Button:
+			f.addButton(parent, l.Button(e.Pos, e.Size), e.Type(), e.Name, e.Label, e.Exit)
+		case *formspec.ImageButton:
+			r := l.Widget(e.Pos, e.Size)
+			f.addImageButton(parent, r, f.view.image(e.Texture), e.Type(), e.Name, e.Label, e.DrawBorder, e.Exit)
+		case *formspec.ItemImageButton:
+			r := l.Widget(e.Pos, e.Size)
+			button := f.addImageButton(parent, r, nil, e.Type(), e.Name, e.Label, true, false)
+			f.addItemImage(button, e.Item, formspec.Rect{Width: button.ContentWidth(), Height: button.ContentHeight()})
+		case *formspec.Field:
+			f.addField(parent, e, l)
+		case *formspec.FieldCloseOnEnter:
+			f.closeOnEnter[e.Name] = e.Close
+		case *formspec.TextArea:
+			f.addTextArea(parent, e, l)
+		case *formspec.TextList:
+			f.addTextList(parent, e, l)
+		case *formspec.Table:
+			f.addTable(parent, e, l)
+		case *formspec.TableColumns:
+			f.tableColumns = e.Columns
+		case *formspec.TabHeader:
+			f.addTabHeader(parent, e, l)
+		case *formspec.Dropdown:
+			f.addDropdown(parent, e, l)
+		case *formspec.Checkbox:
+			f.addCheckbox(parent, e, l)
+		case *formspec.Scrollbar:
+			f.addScrollbar(parent, e, l)
+		case *formspec.ScrollbarOptions:
+			f.scrollbarOptions = e.Options
+		}
+	}
+}
+
+func hasSize(spec *formspec.Formspec) bool {
+	for _, e := range spec.Elements {
+		if _, ok := e.(*formspec.Size); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// focusRequested reports whether set_focus[] gave a widget the keyboard, or else the first field got it
+func (f *formspecForm) focusRequested() bool {
+	if p, ok := f.named[f.focus]; ok {
+		gui.Manager().SetKeyFocus(p)
+		return true
+	}
+	return false
+}
+
+// style returns the properties style_type[] and style[] give elements of a type and name. Styles of the name
+// override the ones of the type, and later styles override earlier ones. States other than the default one
+// are left out.
+func (f *formspecForm) style(elementType, name string) map[string]string {
+	elementType = strings.TrimSuffix(elementType, "_exit")
+	props := make(map[string]string)
+	for _, byType := range []bool{true, false} {
+		for _, s := range f.styles {
+			if s.ByType != byType {
+				continue
+			}
+			for _, selector := range s.Selectors {
+				target, state, _ := strings.Cut(strings.TrimSpace(selector), ":")
+				if state != "" && state != "default" {
+					continue
+				}
+				if byType && target == elementType || !byType && name != "" && target == name {
+					for _, p := range s.Properties {
+						props[p.Key] = p.Value
+					}
+				}
+			}
+		}
+	}
+	return props
+}
+
+// textColor returns the color the style gives the text of an element, white by default
+func (f *formspecForm) textColor(elementType, name string) *math32.Color {
+	if c, ok := formspec.ParseColor(f.style(elementType, name)["textcolor"]); ok {
+		c4 := color4(c)
+		return &math32.Color{R: c4.R, G: c4.G, B: c4.B}
+	}
+	return math32.NewColor("White")
+}
+
+func (f *formspecForm) fontSize() float64 {
+	return float64(hudFontSize * f.view.scaling)
+}
+
+func (f *formspecForm) setBgColor(e *formspec.BgColor) {
+	formBg, fullscreen := true, false
+	switch e.Fullscreen {
+	case "true":
+		formBg, fullscreen = false, true
+	case "both":
+		fullscreen = true
+	case "neither":
+		formBg = false
+	}
+	if c, ok := formspec.ParseColor(e.Color); ok {
+		f.SetColor4(ptr(color4(c)))
+	}
+	if !formBg {
+		f.SetColor4(&math32.Color4{})
+	}
+	if fullscreen {
+		bg := defaultFullscreenBg
+		if c, ok := formspec.ParseColor(e.FullscreenColor); ok {
+			bg = color4(c)
+		}
+		f.view.SetColor4(&bg)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func (f *formspecForm) addBox(parent *gui.Panel, e *formspec.Box, l formspec.Layout) {
+	c, ok := formspec.ParseColor(e.Color)
+	if !ok {
+		return
+	}
+	box := gui.NewPanel(0, 0)
+	place(box, hud.Rect(l.Area(e.Pos, e.Size)))
+	box.SetColor4(ptr(color4(c)))
+	box.SetEnabled(false)
+	parent.Add(box)
+}
+
+// addItemImage draws the image of an item given as an item string into r
+func (f *formspecForm) addItemImage(parent *gui.Panel, item string, r formspec.Rect) {
+	stack, err := inventory.ParseItemStack(item)
+	if err != nil || stack.IsEmpty() {
+		return
+	}
+	stack.Count = 1
+	f.view.addItem(parent, hud.Rect(r), stack, f.view.client.ItemDefs(), f.view.scaling)
+}
+
+func (f *formspecForm) addLabel(parent *gui.Panel, e *formspec.Label, l formspec.Layout) {
+	lines := strings.Split(e.Text, "\n")
+	if e.Vertical {
+		lines = strings.Split(e.Text, "")
+	}
+	color := f.textColor(e.Type(), "")
+	for i, line := range lines {
+		p := l.Label(e.Pos, i)
+		label := newHUDLabel(line, color, f.fontSize())
+		label.SetPosition(p[0], p[1]-label.Height()/2)
+		label.SetEnabled(false)
+		parent.Add(label)
+	}
+}
+
+// addText adds text wrapped to the width of r, leaving out the lines below it
+func (f *formspecForm) addText(parent *gui.Panel, r formspec.Rect, text string, color *math32.Color) *gui.Panel {
+	block := gui.NewPanel(0, 0)
+	place(block, hud.Rect(r))
+	block.SetEnabled(false)
+	measure := newHUDLabel("", color, f.fontSize())
+	var y float32
+	for _, line := range chat.Wrap(text, r.Width, func(s string) float32 { return measureText(measure, s) }) {
+		label := newHUDLabel(line, color, f.fontSize())
+		label.SetPosition(0, y)
+		block.Add(label)
+		y += label.Height()
+	}
+	measure.Dispose()
+	parent.Add(block)
+	return block
+}
+
+// measureText returns the width of text in the font of label
+func measureText(label *gui.Label, text string) float32 {
+	font := label.Font()
+	font.SetPointSize(label.FontSize())
+	font.SetDPI(label.FontDPI())
+	width, _ := font.MeasureText(text)
+	return float32(width)
+}
+
+// styleButton gives a button the colors of its style
+func (f *formspecForm) styleButton(b *gui.Button, elementType, name string) {
+	props := f.style(elementType, name)
+	styles := gui.StyleDefault().Button
+	if c, ok := formspec.ParseColor(props["bgcolor"]); ok {
+		bg := color4(c)
+		styles.Normal.BgColor, styles.Focus.BgColor = bg, bg
+		styles.Over.BgColor = math32.Color4{R: min(1, bg.R+0.1), G: min(1, bg.G+0.1), B: min(1, bg.B+0.1), A: bg.A}
+		styles.Pressed.BgColor = math32.Color4{R: bg.R * 0.8, G: bg.G * 0.8, B: bg.B * 0.8, A: bg.A}
+	}
+	if c, ok := formspec.ParseColor(props["textcolor"]); ok {
+		fg := color4(c)
+		styles.Normal.FgColor, styles.Over.FgColor, styles.Focus.FgColor, styles.Pressed.FgColor = fg, fg, fg, fg
+	}
+	b.SetStyles(&styles)
+}
+
+// addButton adds a button sending its name with its label when clicked
+func (f *formspecForm) addButton(parent *gui.Panel, r formspec.Rect, elementType, name, label string, exit bool) *gui.Button {
+	b := gui.NewButton(label)
+	b.Label.SetFontSize(f.fontSize())
+	f.styleButton(b, elementType, name)
+	place(b, hud.Rect(r))
+	b.Subscribe(gui.OnClick, func(string, interface{}) {
+		f.view.submit(map[string]string{name: label}, exit)
+	})
+	parent.Add(b)
+	f.named[name] = &b.Panel
+	return b
+}
+
+// addImageButton adds a picture with an optional border and label that works as a button
+func (f *formspecForm) addImageButton(parent *gui.Panel, r formspec.Rect, img *image.RGBA, elementType, name, label string, border, exit bool) *gui.Panel {
+	button := gui.NewPanel(0, 0)
+	place(button, hud.Rect(r))
+	bg := math32.Color4{}
+	if c, ok := formspec.ParseColor(f.style(elementType, name)["bgcolor"]); ok {
+		bg = color4(c)
+	}
+	button.SetColor4(&bg)
+	if border {
+		button.SetBorders(1, 1, 1, 1)
+		button.SetBordersColor4(&math32.Color4{R: 0.6, G: 0.6, B: 0.6, A: 1})
+	}
+	if img != nil {
+		addImage(button, img, hud.Rect{Width: button.ContentWidth(), Height: button.ContentHeight()})
+	}
+	if label != "" {
+		text := newHUDLabel(label, f.textColor(elementType, name), f.fontSize())
+		text.SetPosition((button.ContentWidth()-text.Width())/2, (button.ContentHeight()-text.Height())/2)
+		text.SetEnabled(false)
+		button.Add(text)
+	}
+
+	hover := math32.Color4{R: 1, G: 1, B: 1, A: 0.2}
+	button.Subscribe(gui.OnCursorEnter, func(string, interface{}) { button.SetColor4(&hover) })
+	button.Subscribe(gui.OnCursorLeave, func(string, interface{}) { button.SetColor4(&bg) })
+	button.Subscribe(gui.OnMouseDown, func(string, interface{}) {})
+	button.Subscribe(gui.OnMouseUp, func(string, interface{}) {
+		f.endDrag()
+		f.view.submit(map[string]string{name: label}, exit)
+	})
+	parent.Add(button)
+	f.named[name] = button
+	return button
+}
+
+// addProceed adds the button submitting a formspec of fields without position
+func (f *formspecForm) addProceed() {
+	r := f.layout.Proceed(f.simpleFields)
+	b := gui.NewButton("Proceed")
+	b.Label.SetFontSize(f.fontSize())
+	place(b, hud.Rect(r))
+	b.Subscribe(gui.OnClick, func(string, interface{}) { f.view.submit(nil, true) })
+	f.Add(b)
+}
+
+// keptValue returns the value entered before the formspec was laid out again, or def
+func (f *formspecForm) keptValue(name, def string) string {
+	if value, ok := f.kept[name]; ok {
+		return value
+	}
+	return def
+}
+
+func (f *formspecForm) addField(parent *gui.Panel, e *formspec.Field, l formspec.Layout) {
+	var r formspec.Rect
+	if e.Positioned || e.Password {
+		r = l.Button(e.Pos, e.Size)
+	} else {
+		r = f.layout.SimpleField(f.simpleFields)
+		f.simpleFields++
+	}
+	edit := f.addEdit(parent, r, e.Type(), e.Name, e.Label, f.keptValue(e.Name, e.Default), e.Password)
+	name := e.Name
+	edit.Subscribe(gui.OnKeyDown, func(_ string, ev interface{}) {
+		if key := ev.(*window.KeyEvent).Key; key != window.KeyEnter && key != window.KeyKPEnter {
+			return
+		}
+		close, given := f.closeOnEnter[name]
+		f.view.submit(map[string]string{"key_enter": "true", "key_enter_field": name}, !given || close)
+	})
+}
+
+// addTextArea adds a text area. g3n has no editor of several lines, so a named text area is edited in one line,
+// and one without name shows its text read-only.
+func (f *formspecForm) addTextArea(parent *gui.Panel, e *formspec.TextArea, l formspec.Layout) {
+	r := l.Widget(e.Pos, e.Size)
+	if e.Name != "" {
+		f.addEdit(parent, r, e.Type(), e.Name, e.Label, f.keptValue(e.Name, e.Default), false)
+		return
+	}
+	color := f.textColor(e.Type(), e.Name)
+	if e.Label != "" {
+		f.addFieldLabel(parent, r, e.Label, color)
+	}
+	f.addText(parent, r, e.Default, color)
+}
+
+func (f *formspecForm) addFieldLabel(parent *gui.Panel, r formspec.Rect, text string, color *math32.Color) {
+	label := newHUDLabel(text, color, f.fontSize())
+	label.SetPosition(r.X, r.Y-label.Height())
+	label.SetEnabled(false)
+	parent.Add(label)
+}
+
+// addEdit adds a line of text the player edits, sent with every event. Passwords are drawn as asterisks over
+// the edit, whose own text is transparent.
+func (f *formspecForm) addEdit(parent *gui.Panel, r formspec.Rect, elementType, name, label, value string, password bool) *gui.Edit {
+	edit := gui.NewEdit(int(r.Width), "")
+	edit.MaxLength = formspecMaxInput
+	edit.SetFontSize(f.fontSize())
+	edit.SetText(value)
+	edit.SetPosition(r.X, r.Y+(r.Height-edit.Height())/2)
+	if label != "" {
+		f.addFieldLabel(parent, r, label, f.textColor(elementType, name))
+	}
+	parent.Add(edit)
+	f.inputs[name] = edit.Text
+	f.named[name] = &edit.Panel
+
+	if password {
+		styles := gui.StyleDefault().Edit
+		for _, s := range []*gui.EditStyle{&styles.Normal, &styles.Over, &styles.Focus, &styles.Disabled} {
+			s.FgColor.A = 0
+		}
+		edit.SetStyles(&styles)
+		mask := newHUDLabel("", &math32.Color{}, f.fontSize())
+		mask.SetEnabled(false)
+		mask.SetPosition(edit.Position().X+styles.Normal.Border.Left+styles.Normal.Paddings.Left+1, edit.Position().Y+(edit.Height()-mask.Height())/2)
+		redraw := func() { mask.SetText(strings.Repeat("*", utf8.RuneCountInString(edit.Text()))) }
+		redraw()
+		edit.Subscribe(gui.OnChange, func(string, interface{}) { redraw() })
+		parent.Add(mask)
+	}
+	return edit
+}
+
+func (f *formspecForm) addTextList(parent *gui.Panel, e *formspec.TextList, l formspec.Layout) {
+	rows := make([]string, len(e.Items))
+	colors := make([]*math32.Color, len(e.Items))
+	for i, item := range e.Items {
+		// Items may start with a color; ## escapes a leading #
+		switch {
+		case strings.HasPrefix(item, "##"):
+			item = item[1:]
+		case strings.HasPrefix(item, "#") && len(item) >= 7:
+			if c, ok := formspec.ParseColor(item[:7]); ok {
+				c4 := color4(c)
+				colors[i] = &math32.Color{R: c4.R, G: c4.G, B: c4.B}
+				item = item[7:]
+			}
+		}
+		rows[i] = item
+	}
+	f.addRows(parent, l.Area(e.Pos, e.Size), e.Name, rows, colors, e.Selected, func(row int) string {
+		return fmt.Sprintf("CHG:%d", row)
+	})
+}
+
+// addTable adds a table as a list of its rows, with the cells of a row side by side. Columns of images, colors,
+// indents and tree levels only change how the text is shown.
+func (f *formspecForm) addTable(parent *gui.Panel, e *formspec.Table, l formspec.Layout) {
+	columns := f.tableColumns
+	f.tableColumns = nil
+	if len(columns) == 0 {
+		columns = []formspec.TableColumn{{Type: "text"}}
+	}
+	var rows []string
+	var colors []*math32.Color
+	for start := 0; start < len(e.Cells); start += len(columns) {
+		var texts []string
+		var color *math32.Color
+		for i, column := range columns {
+			if start+i >= len(e.Cells) {
+				break
+			}
+			cell := e.Cells[start+i]
+			switch column.Type {
+			case "color":
+				if c, ok := formspec.ParseColor(cell); ok {
+					c4 := color4(c)
+					color = &math32.Color{R: c4.R, G: c4.G, B: c4.B}
+				}
+			case "indent", "tree":
+				depth, _ := strconv.Atoi(cell)
+				texts = append(texts, strings.Repeat("  ", max(0, depth)))
+			case "image":
+			default:
+				texts = append(texts, cell)
+			}
+		}
+		rows = append(rows, strings.Join(texts, "  "))
+		colors = append(colors, color)
+	}
+	f.addRows(parent, l.Area(e.Pos, e.Size), e.Name, rows, colors, e.Selected, func(row int) string {
+		return fmt.Sprintf("CHG:%d:0", row)
+	})
+}
+
+// addRows adds a list of rows the player selects one of, sending event(row) with rows counted from 1
+func (f *formspecForm) addRows(parent *gui.Panel, r formspec.Rect, name string, rows []string, colors []*math32.Color, selected int, event func(row int) string) {
+	list := gui.NewVList(r.Width, r.Height)
+	list.SetPosition(r.X, r.Y)
+	for i, row := range rows {
+		label := gui.NewLabel(row)
+		label.SetFontSize(f.fontSize())
+		if colors[i] != nil {
+			label.SetColor(colors[i])
+		}
+		list.Add(label)
+	}
+	if selected > 0 && selected <= len(rows) {
+		list.SelectPos(selected-1, true)
+	}
+	list.Subscribe(gui.OnChange, func(string, interface{}) {
+		if sel := list.Selected(); len(sel) > 0 {
+			f.view.submit(map[string]string{name: event(list.ItemPosition(sel[0]) + 1)}, false)
+		}
+	})
+	parent.Add(list)
+	f.named[name] = &list.Panel
+}
+
+// addTabHeader adds a row of tabs sending the index of the one clicked, counting from 1
+func (f *formspecForm) addTabHeader(parent *gui.Panel, e *formspec.TabHeader, l formspec.Layout) {
+	r := l.TabHeader(e.Pos, e.Size)
+	x := r.X
+	for i, caption := range e.Captions {
+		b := gui.NewButton(caption)
+		b.Label.SetFontSize(f.fontSize())
+		f.styleButton(b, e.Type(), e.Name)
+		if i+1 == e.Current {
+			styles := gui.StyleDefault().Button
+			styles.Normal = styles.Pressed
+			b.SetStyles(&styles)
+		}
+		width := b.Width() + 8
+		if r.Width > 0 {
+			width = r.Width / float32(len(e.Captions))
+		}
+		place(b, hud.Rect{X: x, Y: r.Y, Width: width, Height: r.Height})
+		// Tabs sit above the formspec
+		b.SetBounded(false)
+		x += width
+		name, index := e.Name, strconv.Itoa(i+1)
+		b.Subscribe(gui.OnClick, func(string, interface{}) {
+			f.view.submit(map[string]string{name: index}, false)
+		})
+		parent.Add(b)
+	}
+}
+
+// addDropdown adds a dropdown, whose item or index is sent with every event
+func (f *formspecForm) addDropdown(parent *gui.Panel, e *formspec.Dropdown, l formspec.Layout) {
+	r := l.Dropdown(e.Pos, e.Size)
+	dd := gui.NewDropDown(r.Width, gui.NewImageLabel(""))
+	for _, item := range e.Items {
+		dd.Add(gui.NewImageLabel(item))
+	}
+	selected := e.Selected - 1
+	if kept, ok := f.kept[e.Name]; ok {
+		for i, item := range e.Items {
+			if kept == item || e.IndexEvent && kept == strconv.Itoa(i+1) {
+				selected = i
+			}
+		}
+	}
+	if selected >= 0 && selected < len(e.Items) {
+		dd.SelectPos(selected)
+	}
+	dd.SetPosition(r.X, r.Y+(r.Height-dd.Height())/2)
+
+	items, indexEvent := e.Items, e.IndexEvent
+	f.inputs[e.Name] = func() string {
+		pos := dd.SelectedPos()
+		switch {
+		case pos < 0 || pos >= len(items):
+			return ""
+		case indexEvent:
+			return strconv.Itoa(pos + 1)
+		}
+		return items[pos]
+	}
+	dd.Subscribe(gui.OnChange, func(string, interface{}) { f.view.submit(nil, false) })
+	parent.Add(dd)
+	f.named[e.Name] = &dd.Panel
+}
+
+// addCheckbox adds a checkbox sending whether it is checked when clicked
+func (f *formspecForm) addCheckbox(parent *gui.Panel, e *formspec.Checkbox, l formspec.Layout) {
+	p := l.Checkbox(e.Pos)
+	cb := gui.NewCheckBox(e.Label)
+	cb.Label.SetFontSize(f.fontSize())
+	cb.SetValue(f.keptValue(e.Name, strconv.FormatBool(e.Selected)) == "true")
+	color := f.textColor(e.Type(), e.Name)
+	cb.Label.SetColor(color)
+	cb.SetPosition(p[0], p[1]-cb.Height()/2)
+	name := e.Name
+	cb.Subscribe(gui.OnChange, func(string, interface{}) {
+		// Styles reset the color of the label when the checkbox changes
+		cb.Label.SetColor(color)
+		f.view.submit(map[string]string{name: strconv.FormatBool(cb.Value())}, false)
+	})
+	parent.Add(cb)
+	f.checkboxes[name] = cb
+	f.named[name] = &cb.Panel
+}
+
+// addScrollbar adds a scrollbar sending its value when moved, in the range scrollbaroptions[] gave
+func (f *formspecForm) addScrollbar(parent *gui.Panel, e *formspec.Scrollbar, l formspec.Layout) {
+	r := l.Area(e.Pos, e.Size)
+	s := &formspecScrollbar{min: defaultScrollbarMin, max: defaultScrollbarMax}
+	for _, option := range f.scrollbarOptions {
+		value, err := strconv.Atoi(option.Value)
+		switch {
+		case err != nil:
+		case option.Key == "min":
+			s.min = value
+		case option.Key == "max":
+			s.max = value
+		}
+	}
+	if e.Orientation == "vertical" {
+		s.bar = gui.NewVScrollBar(r.Width, r.Height)
+	} else {
+		s.bar = gui.NewHScrollBar(r.Width, r.Height)
+	}
+	s.bar.SetPosition(r.X, r.Y)
+	value, err := strconv.Atoi(f.keptValue(e.Name, ""))
+	if err != nil {
+		value = e.Value
+	}
+	s.setValue(value)
+	s.last = s.value()
+
+	name := e.Name
+	s.bar.Subscribe(gui.OnChange, func(string, interface{}) {
+		value := s.value()
+		if value == s.last {
+			return
+		}
+		s.last = value
+		for _, moved := range s.moved {
+			moved(value)
+		}
+		f.view.submit(map[string]string{name: fmt.Sprintf("CHG:%d", value)}, false)
+	})
+	parent.Add(s.bar)
+	f.scrollbars[name] = s
+	f.named[name] = &s.bar.Panel
+}
+
+// addScrollContainer adds a panel clipping its elements, which its scrollbar moves by its factor times the
+// size of a slot per step
+func (f *formspecForm) addScrollContainer(parent *gui.Panel, e *formspec.ScrollContainer, l formspec.Layout, tooltips *[]*formspec.Tooltip) {
+	r := l.Area(e.Pos, e.Size)
+	clip := gui.NewPanel(0, 0)
+	place(clip, hud.Rect(r))
+	content := gui.NewPanel(r.Width, r.Height)
+	clip.Add(content)
+	parent.Add(clip)
+	f.addElements(content, e.Elements, l.ScrollContent(), tooltips)
+
+	// The content grows to hold its elements, which it clips otherwise
+	width, height := r.Width, r.Height
+	for _, child := range content.Children() {
+		p := child.(gui.IPanel).GetPanel()
+		width = max(width, p.Position().X+p.Width())
+		height = max(height, p.Position().Y+p.Height())
+	}
+	padding := e.ContentPadding * l.ImgSize
+	content.SetSize(width+padding, height+padding)
+
+	vertical, step := e.Orientation != "horizontal", e.Factor*l.ImgSize
+	f.scrolls = append(f.scrolls, formspecScroll{
+		scrollbar: e.Scrollbar,
+		wheel:     clip,
+		moved: func(value int) {
+			if vertical {
+				content.SetPosition(0, -float32(value)*step)
+			} else {
+				content.SetPosition(-float32(value)*step, 0)
+			}
+		},
+	})
+}
+
+// linkScroll lets the scrollbar of a scroll container move it, also when the mouse wheel turns over it
+func (f *formspecForm) linkScroll(s formspecScroll) {
+	scrollbar, ok := f.scrollbars[s.scrollbar]
+	if !ok {
+		return
+	}
+	scrollbar.moved = append(scrollbar.moved, s.moved)
+	s.moved(scrollbar.value())
+	s.wheel.Subscribe(gui.OnScroll, func(_ string, ev interface{}) {
+		steps := int(ev.(*window.ScrollEvent).Yoffset)
+		value := min(max(scrollbar.value()-steps, scrollbar.min), scrollbar.max)
+		scrollbar.setValue(value)
+	})
+}
+
+// addTooltip shows the text of a tooltip while the cursor is over the element it names
+func (f *formspecForm) addTooltip(t *formspec.Tooltip) {
+	if p, ok := f.named[t.Element]; ok {
+		f.hoverTooltip(p, t.Text)
+	}
+}
+
+// addAreaTooltip shows the text of a tooltip while the cursor is over its area. The area is below the widgets,
+// which keep their clicks.
+func (f *formspecForm) addAreaTooltip(t *formspec.Tooltip, l formspec.Layout) {
+	area := gui.NewPanel(0, 0)
+	place(area, hud.Rect(l.Area(t.Pos, t.Size)))
+	f.areas.Add(area)
+	f.hoverTooltip(area, t.Text)
+}
+
+func (f *formspecForm) hoverTooltip(p *gui.Panel, text string) {
+	p.Subscribe(gui.OnCursorEnter, func(string, interface{}) { f.view.showTooltip(text) })
+	p.Subscribe(gui.OnCursorLeave, func(string, interface{}) { f.view.showTooltip("") })
+}
+
+// endDrag ends dragging the held items without putting them down, for a release beside the lists
+func (f *formspecForm) endDrag() {
+	if f.view.held != nil {
+		f.view.held.drag = false
+	}
+}
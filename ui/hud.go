@@ -0,0 +1,236 @@
+package ui
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io/fs"
+
+	"bettermt/main/client"
+	"bettermt/main/hud"
+	"bettermt/main/player"
+
+	"github.com/g3n/engine/camera"
+	"github.com/g3n/engine/gui"
+	"github.com/g3n/engine/math32"
+)
+
+const (
+	hudFontSize       = 16
+	crosshairSize     = 20
+	crosshairWidth    = 2
+	hotbarBottomSpace = 4 // Pixels between the built in hotbar and the bottom of the screen
+)
+
+// HUD draws the elements the server placed on the screen, the built in hotbar, the wielded item and the
+// crosshair.
+// The elements live in the client's hud.HUD; this only turns them into gui panels.
+type HUD struct {
+	*gui.Panel
+
+	state  *hud.HUD
+	client *client.Client
+	env    player.Environment
+	cam    *camera.Camera
+	*imageCache
+
+	tileColors map[string]color.RGBA // Average colors of textures, for the minimap
+	widgets    map[uint32]*hudWidget
+	hotbar     *hudWidget // Built in hotbar, shown while the server adds no hotbar element
+
+	wielded    *gui.Panel
+	wieldedKey string // Item and layout the wielded item was drawn with
+	crosshair  *gui.Panel
+	debug      *gui.Label
+
+	scaling float32 // Multiplies offsets and sizes given in pixels
+	version uint64
+	dirty   bool
+}
+
+// NewHUD creates a HUD showing the elements of c. Minimaps show the nodes of env, waypoints are projected
+// with cam and textures are loaded from textures.
+func NewHUD(c *client.Client, env player.Environment, cam *camera.Camera, textures fs.FS) *HUD {
+	h := &HUD{
+		Panel:      gui.NewPanel(0, 0),
+		scaling:    1,
+		state:      c.HUD,
+		client:     c,
+		env:        env,
+		cam:        cam,
+		imageCache: newImageCache(textures),
+		tileColors: make(map[string]color.RGBA),
+		widgets:    make(map[uint32]*hudWidget),
+		dirty:      true,
+	}
+	// The HUD covers the screen, so it must not take clicks meant for the world
+	h.SetEnabled(false)
+
+	h.wielded = gui.NewPanel(0, 0)
+	h.wielded.SetEnabled(false)
+
+	h.crosshair = gui.NewPanel(crosshairSize, crosshairSize)
+	h.crosshair.SetEnabled(false)
+	horizontal := gui.NewPanel(crosshairSize, crosshairWidth)
+	horizontal.SetPosition(0, (crosshairSize-crosshairWidth)/2)
+	vertical := gui.NewPanel(crosshairWidth, crosshairSize)
+	vertical.SetPosition((crosshairSize-crosshairWidth)/2, 0)
+	for _, line := range []*gui.Panel{horizontal, vertical} {
+		line.SetColor4(&math32.Color4{R: 1, G: 1, B: 1, A: 0.8})
+		line.SetEnabled(false)
+		h.crosshair.Add(line)
+	}
+
+	h.debug = newHUDLabel("", math32.NewColor("White"), hudFontSize)
+	h.debug.SetPosition(10, 10)
+	return h
+}
+
+// Resize makes the HUD cover a screen of the given size and lays out the elements again
+func (h *HUD) Resize(width, height float32) {
+	h.SetSize(width, height)
+	h.dirty = true
+}
+
+// SetScaling changes the factor offsets and sizes given in pixels are multiplied by, like hud_scaling
+func (h *HUD) SetScaling(scaling float32) {
+	h.scaling = scaling
+	h.dirty = true
+}
+
+// SetDebugText shows text in the top left corner while the server allows basic debug information
+func (h *HUD) SetDebugText(text string) {
+	if h.debug.Text() != text {
+		h.debug.SetText(text)
+	}
+}
+
+// Update rebuilds the elements that changed and moves the ones that follow the player. Call it from the
+// render thread.
+func (h *HUD) Update(dtime float32) {
+	if version := h.state.Version(); version != h.version {
+		h.version = version
+		h.dirty = true
+	}
+	layout := h.layout()
+	if h.dirty {
+		h.dirty = false
+		h.sync(layout)
+	}
+
+	flags := h.state.Flags()
+	for _, w := range h.widgets {
+		h.updateWidget(w, layout, dtime)
+	}
+	if h.hotbar.Visible() {
+		h.updateWidget(h.hotbar, layout, dtime)
+	}
+	h.updateWielded(layout, flags&hud.FlagWielditem != 0)
+	h.crosshair.SetVisible(flags&hud.FlagCrosshair != 0)
+	h.crosshair.SetPosition((layout.Width-crosshairSize)/2, (layout.Height-crosshairSize)/2)
+	h.debug.SetVisible(flags&hud.FlagBasicDebug != 0)
+}
+
+func (h *HUD) layout() hud.Layout {
+	return hud.Layout{Width: h.Width(), Height: h.Height(), Scale: h.scaling}
+}
+
+// sync creates panels for new and changed elements, drops the ones of removed elements and stacks them
+// in drawing order
+func (h *HUD) sync(layout hud.Layout) {
+	elements := h.state.Elements()
+	flags := h.state.Flags()
+	h.RemoveAll(false)
+
+	present := make(map[uint32]bool, len(elements))
+	hasHotbar := false
+	for _, e := range elements {
+		present[e.ID] = true
+		hasHotbar = hasHotbar || e.Type == hud.TypeHotbar
+		w, exists := h.widgets[e.ID]
+		if !exists || w.elem != e || w.layout != layout {
+			if exists {
+				w.dispose()
+			}
+			w = h.newWidget(e, layout)
+			h.widgets[e.ID] = w
+		}
+		switch e.Type {
+		case hud.TypeHotbar:
+			w.SetVisible(flags&hud.FlagHotbar != 0)
+		case hud.TypeMinimap:
+			w.SetVisible(flags&hud.FlagMinimap != 0)
+		}
+		h.Add(w)
+	}
+	for id, w := range h.widgets {
+		if !present[id] {
+			w.dispose()
+			delete(h.widgets, id)
+		}
+	}
+
+	// Servers that predate hotbar elements leave the hotbar to the client
+	if h.hotbar != nil {
+		h.hotbar.dispose()
+	}
+	h.hotbar = h.newWidget(hud.Element{
+		Type:   hud.TypeHotbar,
+		Pos:    [2]float32{0.5, 1},
+		Offset: [2]float32{0, -hotbarBottomSpace},
+		Align:  [2]float32{0, -1},
+	}, layout)
+	h.hotbar.SetVisible(flags&hud.FlagHotbar != 0 && !hasHotbar)
+	h.Add(h.hotbar)
+
+	h.Add(h.wielded)
+	h.Add(h.crosshair)
+	h.Add(h.debug)
+}
+
+// updateWielded draws the inventory image of the item the player holds in the lower right corner. The hand
+// has no image, so nothing is drawn for it.
+func (h *HUD) updateWielded(layout hud.Layout, shown bool) {
+	item, itemDefs := h.client.WieldedItem(), h.client.ItemDefs()
+	h.wielded.SetVisible(shown && !item.IsEmpty())
+	if key := fmt.Sprint(item.Name, layout, itemDefs != nil); key != h.wieldedKey {
+		h.wieldedKey = key
+		h.wielded.DisposeChildren(true)
+		r := layout.Wielded()
+		place(h.wielded, r)
+		if itemDefs != nil && !item.IsEmpty() {
+			if img := h.itemImage(itemDefs.Get(item.Name)); img != nil {
+				addImage(h.wielded, img, hud.Rect{Width: r.Width, Height: r.Height})
+			}
+		}
+	}
+}
+
+// newHUDLabel creates a label that lets clicks through
+func newHUDLabel(text string, color *math32.Color, size float64) *gui.Label {
+	label := gui.NewLabel(text)
+	label.SetFontSize(size)
+	label.SetColor(color)
+	label.SetEnabled(false)
+	return label
+}
+
+// colorFromNumber converts the 0xRRGGBB color of a text or waypoint element
+func colorFromNumber(number uint32) *math32.Color {
+	return &math32.Color{
+		R: float32(number>>16&0xff) / 255,
+		G: float32(number>>8&0xff) / 255,
+		B: float32(number&0xff) / 255,
+	}
+}
+
+// textureSize returns the size of an image as HUD layouts expect it
+func textureSize(img *image.RGBA) [2]float32 {
+	return [2]float32{float32(img.Bounds().Dx()), float32(img.Bounds().Dy())}
+}
+
+// place moves and stretches a panel to cover a rectangle of the screen
+func place(p gui.IPanel, r hud.Rect) {
+	p.GetPanel().SetPosition(r.X, r.Y)
+	p.GetPanel().SetSize(r.Width, r.Height)
+}
@@ -0,0 +1,373 @@
+package ui
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+
+	"bettermt/main/blocktypes"
+	"bettermt/main/hud"
+	"bettermt/main/inventory"
+
+	"github.com/g3n/engine/gls"
+	"github.com/g3n/engine/gui"
+	"github.com/g3n/engine/math32"
+	"github.com/g3n/engine/texture"
+)
+
+const (
+	// Nodes shown in every direction from the player on the minimap
+	minimapRadius = 32
+	// Nodes above and below the player searched for the surface shown on the minimap
+	minimapDepth = 32
+	// Seconds between redraws of the minimap
+	minimapInterval = 0.5
+	// Degrees a compass turns before its image is drawn again
+	compassStep = 1
+)
+
+// hudWidget holds the panels of one element. It covers the screen and places its children absolutely.
+type hudWidget struct {
+	*gui.Panel
+	elem   hud.Element
+	layout hud.Layout
+
+	label   *gui.Label // Text of texts and waypoints
+	image   *gui.Image // Image of image waypoints, compasses and minimaps
+	texture *texture.Texture2D
+	source  *image.RGBA // Unrotated compass image
+
+	slotsKey     string  // Items the slots of an inventory or hotbar were drawn with
+	compassAngle float32 // Angle the compass image was last drawn at
+	minimapAge   float32 // Seconds since the minimap was drawn
+}
+
+// newWidget creates the panels of an element. Elements that follow the player or the inventory are
+// filled in by updateWidget.
+func (h *HUD) newWidget(e hud.Element, layout hud.Layout) *hudWidget {
+	w := &hudWidget{Panel: gui.NewPanel(layout.Width, layout.Height), elem: e, layout: layout}
+	w.SetEnabled(false)
+	w.minimapAge = minimapInterval
+
+	switch e.Type {
+	case hud.TypeImage:
+		if img := h.image(e.Text); img != nil {
+			w.addImage(img, layout.Image(&e, textureSize(img)))
+		}
+	case hud.TypeText:
+		w.label = newHUDLabel(e.Text, colorFromNumber(e.Number), float64(hudFontSize*hud.TextScale(&e)*layout.Scale))
+		// Bold, italic and monospace styles need fonts g3n does not ship, so the style is ignored
+		r := layout.Text(&e, [2]float32{w.label.Width(), w.label.Height()})
+		w.label.SetPosition(r.X, r.Y)
+		w.Add(w.label)
+	case hud.TypeStatbar:
+		h.addStatbar(w, layout)
+	case hud.TypeWaypoint:
+		w.label = newHUDLabel("", colorFromNumber(e.Number), float64(hudFontSize*layout.Scale))
+		w.Add(w.label)
+	case hud.TypeImageWaypoint:
+		if img := h.image(e.Text); img != nil {
+			w.image = w.addImage(img, layout.Image(&e, textureSize(img)))
+		}
+	case hud.TypeCompass:
+		if img := h.image(e.Text); img != nil {
+			w.source = img
+			w.texture = texture.NewTexture2DFromRGBA(img)
+			w.texture.SetWrapS(gls.REPEAT)
+			w.image = gui.NewImageFromTex(w.texture)
+			w.image.SetEnabled(false)
+			place(w.image, layout.Fixed(&e))
+			w.compassAngle = float32(math.NaN())
+			w.Add(w.image)
+		}
+	case hud.TypeMinimap:
+		w.texture = texture.NewTexture2DFromRGBA(image.NewRGBA(image.Rect(0, 0, 2*minimapRadius+1, 2*minimapRadius+1)))
+		w.texture.SetMagFilter(gls.NEAREST)
+		w.image = gui.NewImageFromTex(w.texture)
+		w.image.SetEnabled(false)
+		place(w.image, layout.Fixed(&e))
+		w.Add(w.image)
+	}
+	return w
+}
+
+// updateWidget moves the elements that follow the player and redraws slots whose items changed
+func (h *HUD) updateWidget(w *hudWidget, layout hud.Layout, dtime float32) {
+	e := &w.elem
+	switch e.Type {
+	case hud.TypeWaypoint:
+		screen, visible := h.project(e.WorldPos, layout)
+		w.label.SetVisible(visible)
+		if !visible {
+			return
+		}
+		if text := hud.WaypointText(e, h.distance(e.WorldPos)); w.label.Text() != text {
+			w.label.SetText(text)
+		}
+		r := layout.Waypoint(e, screen, [2]float32{w.label.Width(), w.label.Height()})
+		w.label.SetPosition(r.X, r.Y)
+	case hud.TypeImageWaypoint:
+		if w.image == nil {
+			return
+		}
+		screen, visible := h.project(e.WorldPos, layout)
+		w.image.SetVisible(visible)
+		if visible {
+			place(w.image, layout.ImageWaypoint(e, screen, textureSize(h.image(e.Text))))
+		}
+	case hud.TypeCompass:
+		if w.image != nil {
+			h.updateCompass(w)
+		}
+	case hud.TypeMinimap:
+		if w.Visible() {
+			w.minimapAge += dtime
+			if w.minimapAge >= minimapInterval {
+				w.minimapAge = 0
+				h.drawMinimap(w)
+			}
+		}
+	case hud.TypeInventory:
+		var items []inventory.ItemStack
+		if list := h.client.Inventory().List(e.Text); list != nil {
+			items = list.Items[:min(int(e.Number), len(list.Items))]
+		}
+		// The selected slot counts from 1, 0 selects none
+		h.updateSlots(w, layout.Slots(e, len(items)), items, int(e.Item)-1, hud.Hotbar{})
+	case hud.TypeHotbar:
+		hotbar := h.state.Hotbar()
+		items := h.client.Hotbar()
+		slots := layout.Slots(e, hotbar.ItemCount)
+		h.updateSlots(w, slots, items, h.client.WieldIndex(), hotbar)
+	}
+}
+
+// dispose frees the panels and textures of the widget
+func (w *hudWidget) dispose() {
+	w.DisposeChildren(true)
+	w.Dispose()
+}
+
+// addImage adds a picture of img covering r
+func (w *hudWidget) addImage(img *image.RGBA, r hud.Rect) *gui.Image {
+	return addImage(w.Panel, img, r)
+}
+
+// addStatbar adds the icons of a statbar, over the background icons its second texture gives up to its item count
+func (h *HUD) addStatbar(w *hudWidget, layout hud.Layout) {
+	e := &w.elem
+	fg := h.image(e.Text)
+	if fg == nil {
+		return
+	}
+	vertical := e.Dir == hud.DirTopBottom || e.Dir == hud.DirBottomTop
+	if bg := h.image(e.Text2); bg != nil && e.Item > 0 {
+		for _, icon := range layout.Statbar(e, e.Item, textureSize(fg)) {
+			w.addImage(statbarIcon(bg, icon.Half, vertical), icon.Rect)
+		}
+	}
+	for _, icon := range layout.Statbar(e, e.Number, textureSize(fg)) {
+		w.addImage(statbarIcon(fg, icon.Half, vertical), icon.Rect)
+	}
+}
+
+// statbarIcon returns the part of a texture an icon shows: all of it, or for half icons the left half,
+// or the top half in vertical bars
+func statbarIcon(img *image.RGBA, half, vertical bool) *image.RGBA {
+	if !half {
+		return img
+	}
+	b := img.Bounds()
+	part := b
+	if vertical {
+		part.Max.Y = b.Min.Y + b.Dy()/2
+	} else {
+		part.Max.X = b.Min.X + b.Dx()/2
+	}
+	crop := image.NewRGBA(image.Rect(0, 0, part.Dx(), part.Dy()))
+	for y := 0; y < part.Dy(); y++ {
+		copy(crop.Pix[y*crop.Stride:], img.Pix[img.PixOffset(part.Min.X, part.Min.Y+y):img.PixOffset(part.Max.X, part.Min.Y+y)])
+	}
+	return crop
+}
+
+// project returns where a point in nodes is on the screen, and false if it is behind the camera
+func (h *HUD) project(pos [3]float32, layout hud.Layout) ([2]float32, bool) {
+	ndc := h.cam.Project(&math32.Vector3{X: pos[0], Y: pos[1], Z: pos[2]})
+	return layout.ProjectWaypoint([3]float32{ndc.X, ndc.Y, ndc.Z})
+}
+
+// distance returns how far a point in nodes is from the eyes of the player
+func (h *HUD) distance(pos [3]float32) float32 {
+	eye := h.client.Player.EyePosition()
+	dx, dy, dz := pos[0]-eye[0], pos[1]-eye[1], pos[2]-eye[2]
+	return float32(math.Sqrt(float64(dx*dx + dy*dy + dz*dz)))
+}
+
+// updateCompass turns or shifts the compass image with the direction the player looks in
+func (h *HUD) updateCompass(w *hudWidget) {
+	// The scene is mirrored, so turning right turns the world on screen the other way than in Minetest
+	angle, shift := hud.CompassAngle(&w.elem, -h.client.Player.State().Yaw)
+	w.texture.SetOffset(shift, 0)
+	if !math.IsNaN(float64(w.compassAngle)) && math.Abs(float64(angle-w.compassAngle)) < compassStep {
+		return
+	}
+	w.compassAngle = angle
+	if angle == 0 {
+		w.texture.SetFromRGBA(w.source)
+	} else {
+		w.texture.SetFromRGBA(rotateImage(w.source, angle))
+	}
+}
+
+// rotateImage turns an image around its center by angle degrees clockwise, leaving the corners transparent
+func rotateImage(src *image.RGBA, angle float32) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	sin, cos := math.Sincos(float64(angle) * math.Pi / 180)
+	cx, cy := float64(b.Dx())/2, float64(b.Dy())/2
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			// Sample the source at the point that lands here after turning
+			dx, dy := float64(x)+0.5-cx, float64(y)+0.5-cy
+			sx := int(math.Floor(cos*dx + sin*dy + cx))
+			sy := int(math.Floor(-sin*dx + cos*dy + cy))
+			if sx >= 0 && sx < b.Dx() && sy >= 0 && sy < b.Dy() {
+				dst.SetRGBA(x, y, src.RGBAAt(b.Min.X+sx, b.Min.Y+sy))
+			}
+		}
+	}
+	return dst
+}
+
+// drawMinimap draws the surface around the player seen from above, north up, with the player in the center
+func (h *HUD) drawMinimap(w *hudWidget) {
+	size := 2*minimapRadius + 1
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	pos := h.client.Player.Position()
+	px, py, pz := int32(math.Round(float64(pos[0]))), int32(math.Round(float64(pos[1]))), int32(math.Round(float64(pos[2])))
+	for row := 0; row < size; row++ {
+		for col := 0; col < size; col++ {
+			// The scene is mirrored, so east is on the left of the screen
+			x := px + minimapRadius - int32(col)
+			z := pz + minimapRadius - int32(row)
+			img.SetRGBA(col, row, h.surfaceColor(x, py, z))
+		}
+	}
+	img.SetRGBA(minimapRadius, minimapRadius, color.RGBA{R: 255, A: 255})
+	w.texture.SetFromRGBA(img)
+}
+
+// surfaceColor returns the color of the highest node of a column near height y, shaded by its height,
+// or transparent black if the column is not loaded or empty
+func (h *HUD) surfaceColor(x, y, z int32) color.RGBA {
+	for ny := y + minimapDepth; ny >= y-minimapDepth; ny-- {
+		def, loaded := h.env.Node(x, ny, z)
+		if !loaded {
+			continue
+		}
+		if def.Drawtype == blocktypes.DrawtypeAirlike {
+			continue
+		}
+		c := h.nodeColor(def)
+		// Lighten nodes above the player and darken the ones below
+		shade := 1 + float32(ny-y)/(2*minimapDepth)
+		return color.RGBA{
+			R: uint8(min(255, float32(c.R)*shade)),
+			G: uint8(min(255, float32(c.G)*shade)),
+			B: uint8(min(255, float32(c.B)*shade)),
+			A: 255,
+		}
+	}
+	return color.RGBA{}
+}
+
+// nodeColor returns the average color of the top texture of a node, tinted by the node color
+func (h *HUD) nodeColor(def *blocktypes.NodeDefinition) color.RGBA {
+	average, exists := h.tileColors[def.Tiles[0].Name]
+	if !exists {
+		average = color.RGBA{R: 128, G: 128, B: 128, A: 255}
+		if img := h.image(def.Tiles[0].Name); img != nil {
+			average = averageColor(img)
+		}
+		h.tileColors[def.Tiles[0].Name] = average
+	}
+	return multiplyColor(average, def.Color)
+}
+
+// averageColor returns the average color of the opaque pixels of an image
+func averageColor(img *image.RGBA) color.RGBA {
+	var r, g, b, n int
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := img.RGBAAt(x, y)
+			if c.A < 128 {
+				continue
+			}
+			r, g, b, n = r+int(c.R), g+int(c.G), b+int(c.B), n+1
+		}
+	}
+	if n == 0 {
+		return color.RGBA{R: 128, G: 128, B: 128, A: 255}
+	}
+	return color.RGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(b / n), A: 255}
+}
+
+func multiplyColor(c color.RGBA, tint color.NRGBA) color.RGBA {
+	return color.RGBA{
+		R: uint8(int(c.R) * int(tint.R) / 255),
+		G: uint8(int(c.G) * int(tint.G) / 255),
+		B: uint8(int(c.B) * int(tint.B) / 255),
+		A: c.A,
+	}
+}
+
+// updateSlots redraws the slots of an inventory or hotbar when the items in them changed
+func (h *HUD) updateSlots(w *hudWidget, slots []hud.Rect, items []inventory.ItemStack, selected int, hotbar hud.Hotbar) {
+	var key strings.Builder
+	fmt.Fprintf(&key, "%d %d %q %q", len(slots), selected, hotbar.Image, hotbar.SelectedImage)
+	for _, item := range items {
+		key.WriteString("\n" + item.String())
+	}
+	if key.String() == w.slotsKey {
+		return
+	}
+	w.slotsKey = key.String()
+	w.DisposeChildren(true)
+	if len(slots) == 0 {
+		return
+	}
+
+	// Hotbar images stretch over the whole bar and behind the wielded slot
+	if img := h.image(hotbar.Image); img != nil {
+		first, last := slots[0], slots[len(slots)-1]
+		w.addImage(img, hud.Rect{
+			X:      min(first.X, last.X),
+			Y:      min(first.Y, last.Y),
+			Width:  max(first.X, last.X) + last.Width - min(first.X, last.X),
+			Height: max(first.Y, last.Y) + last.Height - min(first.Y, last.Y),
+		})
+	}
+	itemDefs := h.client.ItemDefs()
+	for i, r := range slots {
+		if img := h.image(hotbar.SelectedImage); i == selected && img != nil {
+			w.addImage(img, r)
+		} else {
+			background := gui.NewPanel(r.Width, r.Height)
+			background.SetPosition(r.X, r.Y)
+			background.SetEnabled(false)
+			if i == selected {
+				background.SetColor4(&math32.Color4{R: 1, G: 1, B: 1, A: 0.5})
+			} else {
+				background.SetColor4(&math32.Color4{R: 0, G: 0, B: 0, A: 0.4})
+			}
+			w.Add(background)
+		}
+		if i < len(items) && !items[i].IsEmpty() {
+			h.addItem(w.Panel, r, items[i], itemDefs, w.layout.Scale)
+		}
+	}
+}
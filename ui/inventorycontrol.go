@@ -0,0 +1,72 @@
+package ui
+
+import (
+	"fmt"
+
+	"bettermt/main/client"
+
+	"github.com/g3n/engine/gui"
+	"github.com/g3n/engine/window"
+)
+
+// Key opening the inventory, like keymap_inventory
+const inventoryKey = window.KeyI
+
+// InventoryControl opens the inventory with its key and selects the wielded hotbar slot with the number keys
+// and the mouse wheel
+type InventoryControl struct {
+	// Input is ignored while false, such as when the chat console has the keyboard
+	Enabled bool
+
+	client    *client.Client
+	formspecs *FormspecView
+}
+
+// NewInventoryControl binds the inventory key to the inventory formspec of c shown in formspecs, and the
+// number keys and the mouse wheel to its hotbar
+func NewInventoryControl(c *client.Client, formspecs *FormspecView) *InventoryControl {
+	ic := &InventoryControl{Enabled: true, client: c, formspecs: formspecs}
+	gui.Manager().SubscribeID(gui.OnKeyDown, ic, ic.onKeyDown)
+	gui.Manager().SubscribeID(gui.OnScroll, ic, ic.onScroll)
+	return ic
+}
+
+// onKeyDown opens the inventory, or wields the slot of a number key: 1 to 9 for the first nine slots and 0
+// for the tenth
+func (ic *InventoryControl) onKeyDown(evname string, ev interface{}) {
+	if !ic.Enabled {
+		return
+	}
+	key := ev.(*window.KeyEvent).Key
+	switch {
+	case key == inventoryKey:
+		if err := ic.formspecs.ShowInventory(); err != nil {
+			fmt.Println("Could not open the inventory:", err)
+		}
+	case key >= window.Key1 && key <= window.Key9:
+		ic.wield(int(key - window.Key1))
+	case key == window.Key0:
+		ic.wield(9)
+	}
+}
+
+// onScroll wields the next hotbar slot when the wheel turns down and the previous one when it turns up,
+// wrapping around at the ends
+func (ic *InventoryControl) onScroll(evname string, ev interface{}) {
+	offset := ev.(*window.ScrollEvent).Yoffset
+	if !ic.Enabled || offset == 0 {
+		return
+	}
+	size := ic.client.HotbarSize()
+	step := 1
+	if offset > 0 {
+		step = -1
+	}
+	ic.wield((ic.client.WieldIndex() + step + size) % size)
+}
+
+func (ic *InventoryControl) wield(index int) {
+	if err := ic.client.SetWieldIndex(index); err != nil {
+		fmt.Println("Could not wield hotbar slot:", err)
+	}
+}
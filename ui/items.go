@@ -0,0 +1,120 @@
+package ui
+
+import (
+	"fmt"
+	"image"
+	"io/fs"
+	"strings"
+
+	"bettermt/main/blocktypes"
+	"bettermt/main/hud"
+	"bettermt/main/inventory"
+	"bettermt/main/media"
+
+	"github.com/g3n/engine/gls"
+	"github.com/g3n/engine/gui"
+	"github.com/g3n/engine/math32"
+	"github.com/g3n/engine/texture"
+)
+
+// Pixels of the inventory images drawn for nodes
+const nodeIconSize = 64
+
+// imageCache decodes textures for drawing on the CPU and keeps them by name
+type imageCache struct {
+	textures fs.FS
+	images   map[string]*image.RGBA // nil for textures that failed to load
+	icons    map[string]*image.RGBA // Inventory images drawn from the tiles of nodes, by item name
+}
+
+func newImageCache(textures fs.FS) *imageCache {
+	return &imageCache{textures: textures, images: make(map[string]*image.RGBA), icons: make(map[string]*image.RGBA)}
+}
+
+// image returns a texture decoded for drawing on the CPU, ignoring texture modifiers, or nil if it is missing
+func (c *imageCache) image(name string) *image.RGBA {
+	name, _, _ = strings.Cut(name, "^")
+	if name == "" || strings.HasPrefix(name, "[") || c.textures == nil {
+		return nil
+	}
+	if img, exists := c.images[name]; exists {
+		return img
+	}
+	img, err := media.DecodeImage(c.textures, name)
+	if err != nil {
+		img = nil
+	}
+	c.images[name] = img
+	return img
+}
+
+// itemImage returns the inventory image of an item, or for nodes without one a cube drawn from their tiles.
+// It returns nil if the item has neither.
+func (c *imageCache) itemImage(def *inventory.ItemDefinition) *image.RGBA {
+	if def == nil {
+		return nil
+	}
+	if img := c.image(def.InventoryImage); img != nil || def.Type != inventory.ItemTypeNode {
+		return img
+	}
+	if icon, exists := c.icons[def.Name]; exists {
+		return icon
+	}
+	nodeDefs := blocktypes.NodeDefs()
+	id, exists := nodeDefs.GetID(def.Name)
+	if !exists {
+		return nil
+	}
+	// Icons that fail are tried again, as the node definitions may not have arrived yet
+	icon := blocktypes.InventoryIcon(nodeDefs.Get(id), c.image, nodeIconSize)
+	if icon != nil {
+		c.icons[def.Name] = icon
+	}
+	return icon
+}
+
+// addItem draws an item stack into a slot of parent: its inventory image or else its name, its count and its
+// wear. Scale multiplies the size of the text.
+func (c *imageCache) addItem(parent *gui.Panel, r hud.Rect, item inventory.ItemStack, itemDefs *inventory.ItemDefManager, scale float32) {
+	var def *inventory.ItemDefinition
+	if itemDefs != nil {
+		def = itemDefs.Get(item.Name)
+	}
+	if img := c.itemImage(def); img != nil {
+		addImage(parent, img, r)
+	} else {
+		_, name, _ := strings.Cut(item.Name, ":")
+		if name == "" {
+			name = item.Name
+		}
+		label := newHUDLabel(name, math32.NewColor("White"), float64(hudFontSize*scale*0.75))
+		label.SetPosition(r.X+2, r.Y+2)
+		parent.Add(label)
+	}
+
+	if item.Count > 1 {
+		count := newHUDLabel(fmt.Sprint(item.Count), math32.NewColor("White"), float64(hudFontSize*scale))
+		count.SetPosition(r.X+r.Width-count.Width()-2, r.Y+r.Height-count.Height())
+		parent.Add(count)
+	}
+	if item.Wear > 0 {
+		// Green for new tools, turning red as they wear out
+		left := 1 - float32(item.Wear)/inventory.MaxWear
+		bar := gui.NewPanel(r.Width*left, max(2, r.Height/16))
+		bar.SetPosition(r.X, r.Y+r.Height-bar.Height()-1)
+		bar.SetColor4(&math32.Color4{R: min(1, 2*(1-left)), G: min(1, 2*left), A: 1})
+		bar.SetEnabled(false)
+		parent.Add(bar)
+	}
+}
+
+// addImage adds a picture of img covering r to parent
+func addImage(parent *gui.Panel, img *image.RGBA, r hud.Rect) *gui.Image {
+	tex := texture.NewTexture2DFromRGBA(img)
+	tex.SetMagFilter(gls.NEAREST)
+	picture := gui.NewImageFromTex(tex)
+	picture.SetEnabled(false)
+	place(picture, r)
+	parent.Add(picture)
+	return picture
+}
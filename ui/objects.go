@@ -0,0 +1,221 @@
+package ui
+
+import (
+	"image"
+	"io/fs"
+	"strings"
+
+	"bettermt/main/client"
+	"bettermt/main/hud"
+	"bettermt/main/media"
+	"bettermt/main/object"
+
+	"github.com/g3n/engine/camera"
+	"github.com/g3n/engine/core"
+	"github.com/g3n/engine/gui"
+	"github.com/g3n/engine/math32"
+)
+
+const (
+	nametagFontSize = 14
+	nametagHeight   = 0.3 // Nodes between the top of the selection box and the nametag
+)
+
+// Background of nametags whose object sets none, like in Minetest
+var defaultNametagBg = math32.Color4{A: 50.0 / 255}
+
+// Objects draws the active objects in range of the player with the visual their properties ask for, and their
+// nametags. The objects live in the client's object.Manager; this only turns them into scene nodes.
+type Objects struct {
+	core.Node
+
+	manager  *object.Manager
+	client   *client.Client
+	cam      *camera.Camera
+	textures fs.FS
+
+	images   map[string]*image.RGBA // Decoded textures by name, nil for textures that failed to load
+	visuals  map[uint16]*objectVisual
+	nametags *gui.Panel
+	version  uint64
+	synced   bool
+}
+
+// NewObjects creates the drawing of the objects of c. Nametags are projected with cam and textures and meshes
+// are loaded from textures.
+func NewObjects(c *client.Client, cam *camera.Camera, textures fs.FS) *Objects {
+	o := &Objects{
+		manager:  c.Objects,
+		client:   c,
+		cam:      cam,
+		textures: textures,
+		images:   make(map[string]*image.RGBA),
+		visuals:  make(map[uint16]*objectVisual),
+		nametags: gui.NewPanel(0, 0),
+	}
+	o.Node.Init(o)
+	// Nametags must not take clicks meant for the world
+	o.nametags.SetEnabled(false)
+	o.Add(o.nametags)
+	return o
+}
+
+// Resize tells the nametags the size of the screen they are projected onto
+func (o *Objects) Resize(width, height float32) {
+	o.nametags.SetSize(width, height)
+}
+
+// Update moves and animates the objects by dtime seconds, rebuilding the visuals of the ones that changed how
+// they look. Call it from the render thread.
+func (o *Objects) Update(dtime float32) {
+	o.manager.Step(dtime)
+	objects := o.manager.Objects()
+	byID := make(map[uint16]*object.Object, len(objects))
+	for i := range objects {
+		byID[objects[i].ID] = &objects[i]
+	}
+
+	version := o.manager.Version()
+	rebuild := !o.synced || version != o.version
+	o.version, o.synced = version, true
+	if rebuild {
+		for id, v := range o.visuals {
+			if _, exists := byID[id]; !exists {
+				o.removeVisual(id, v)
+			}
+		}
+	}
+
+	layout := hud.Layout{Width: o.nametags.Width(), Height: o.nametags.Height(), Scale: 1}
+	eye := o.client.Player.EyePosition()
+	for i := range objects {
+		obj := &objects[i]
+		v, exists := o.visuals[obj.ID]
+		if rebuild {
+			if key := visualKey(obj); !exists || v.key != key {
+				if exists {
+					o.removeVisual(obj.ID, v)
+				}
+				v = o.newVisual(obj)
+				v.key = key
+				o.visuals[obj.ID] = v
+				o.Add(v.node)
+				exists = true
+			}
+		}
+		if !exists {
+			continue
+		}
+		o.updateVisual(v, obj, byID, eye)
+		o.updateNametag(v, obj, layout)
+	}
+}
+
+// removeVisual takes the visual of an object out of the scene and frees it
+func (o *Objects) removeVisual(id uint16, v *objectVisual) {
+	o.Remove(v.node)
+	v.node.DisposeChildren(true)
+	if v.nametag != nil {
+		o.nametags.Remove(v.nametag)
+		v.nametag.Dispose()
+	}
+	delete(o.visuals, id)
+}
+
+// updateVisual places the visual of an object where it is drawn. Objects of the local player and the objects
+// attached to it are hidden, since the camera is inside them.
+func (o *Objects) updateVisual(v *objectVisual, obj *object.Object, byID map[uint16]*object.Object, eye [3]float32) {
+	visible := obj.Props.IsVisible && !obj.IsLocal
+	if parent, exists := byID[obj.Attachment.ParentID]; exists && obj.Attached() && parent.IsLocal {
+		visible = visible && obj.Attachment.ForceVisible
+	}
+	v.node.SetVisible(visible)
+	if !visible {
+		return
+	}
+
+	p := obj.VisualPosition
+	v.node.SetPosition(p[0], p[1], p[2])
+	if v.billboard {
+		// Sprites face the camera on their own
+		v.node.SetRotation(0, 0, 0)
+	} else {
+		q := objectRotation(obj.VisualRotation)
+		v.node.SetQuaternionQuat(&q)
+	}
+
+	if v.spriteTexture != nil {
+		col, row := obj.SpriteCell(eye)
+		if col != v.spriteCell[0] || row != v.spriteCell[1] {
+			v.spriteCell = [2]int{col, row}
+			div := obj.Props.SpriteDiv
+			v.spriteTexture.SetOffset(float32(col)/float32(max(1, div[0])), float32(row)/float32(max(1, div[1])))
+		}
+	}
+
+	if obj.Damaged != v.damaged {
+		v.damaged = obj.Damaged
+		glow := math32.Color{}
+		if v.damaged {
+			glow = math32.Color{R: 0.4, G: 0.4, B: 0.4}
+		}
+		for _, m := range v.materials {
+			m.SetEmissiveColor(&glow)
+		}
+	}
+}
+
+// updateNametag shows the nametag of an object above its selection box
+func (o *Objects) updateNametag(v *objectVisual, obj *object.Object, layout hud.Layout) {
+	text := obj.NametagText()
+	show := v.node.Visible() && text != "" && obj.Props.NametagColor.A > 0
+	var screen [2]float32
+	if show {
+		pos := obj.VisualPosition
+		pos[1] += obj.Props.SelectionBox.Max[1] + nametagHeight
+		ndc := o.cam.Project(&math32.Vector3{X: pos[0], Y: pos[1], Z: pos[2]})
+		screen, show = layout.ProjectWaypoint([3]float32{ndc.X, ndc.Y, ndc.Z})
+	}
+	if !show {
+		if v.nametag != nil {
+			v.nametag.SetVisible(false)
+		}
+		return
+	}
+
+	if v.nametag == nil {
+		v.nametag = newHUDLabel("", &math32.Color{R: 1, G: 1, B: 1}, nametagFontSize)
+		v.nametag.SetPaddings(0, 2, 0, 2)
+		o.nametags.Add(v.nametag)
+	}
+	style := nametagStyle{text: text, color: color4(obj.Props.NametagColor), bg: defaultNametagBg}
+	if obj.Props.HasNametagBgColor {
+		style.bg = color4(obj.Props.NametagBgColor)
+	}
+	if style != v.nametagStyle {
+		// Labels draw their text again on every change, so only changes are passed on
+		v.nametagStyle = style
+		v.nametag.SetText(style.text)
+		v.nametag.SetColor4(&style.color)
+		v.nametag.SetBgColor4(&style.bg)
+	}
+	v.nametag.SetPosition(screen[0]-v.nametag.Width()/2, screen[1]-v.nametag.Height())
+	v.nametag.SetVisible(true)
+}
+
+// image returns a texture decoded for drawing, ignoring texture modifiers, or nil if it is missing
+func (o *Objects) image(name string) *image.RGBA {
+	name, _, _ = strings.Cut(name, "^")
+	if name == "" || strings.HasPrefix(name, "[") || o.textures == nil {
+		return nil
+	}
+	if img, exists := o.images[name]; exists {
+		return img
+	}
+	img, err := media.DecodeImage(o.textures, name)
+	if err != nil {
+		img = nil
+	}
+	o.images[name] = img
+	return img
+}
@@ -0,0 +1,242 @@
+package ui
+
+import (
+	"fmt"
+	"image/color"
+	"strings"
+
+	"bettermt/main/inventory"
+	"bettermt/main/network"
+	"bettermt/main/object"
+
+	"github.com/g3n/engine/core"
+	"github.com/g3n/engine/geometry"
+	"github.com/g3n/engine/gls"
+	"github.com/g3n/engine/graphic"
+	"github.com/g3n/engine/gui"
+	"github.com/g3n/engine/loader/obj"
+	"github.com/g3n/engine/material"
+	"github.com/g3n/engine/math32"
+	"github.com/g3n/engine/texture"
+)
+
+// Textures of a cube visual in the order of the groups of geometry.NewBox (+X, -X, +Y, -Y, +Z, -Z), given as
+// indices into the textures of the object, which Minetest orders +Y, -Y, +X, -X, +Z, -Z
+var cubeFaceTextures = [6]int{2, 3, 0, 1, 4, 5}
+
+// objectVisual is the scene node drawing one object
+type objectVisual struct {
+	node *core.Node
+	key  string // visualKey of the properties the node was built from
+
+	billboard     bool                 // Turns to the camera on its own instead of with the object
+	spriteTexture *texture.Texture2D   // Texture divided into frames, nil for visuals without sprite frames
+	spriteCell    [2]int               // Frame the sprite texture shows
+	damaged       bool                 // Drawn with the damage flash
+	materials     []*material.Standard // Every material of the node, for the damage flash
+
+	nametag      *gui.Label // Nil until the nametag is first shown
+	nametagStyle nametagStyle
+}
+
+// nametagStyle is what a nametag label shows
+type nametagStyle struct {
+	text      string
+	color, bg math32.Color4
+}
+
+// visualKey returns a string that changes whenever the properties an object is built from change
+func visualKey(o *object.Object) string {
+	p := &o.Props
+	return fmt.Sprintf("%s|%q|%s|%v|%v|%s|%t|%t|%t", p.Visual, p.Textures, p.Mesh, p.VisualSize, p.SpriteDiv,
+		p.WieldItem, p.BackfaceCulling, p.UseTextureAlpha, p.Shaded)
+}
+
+// objectRotation turns pitch, yaw and roll in degrees into the rotation Minetest draws objects with: roll first,
+// then pitch, then yaw, all negated
+func objectRotation(rot [3]float32) math32.Quaternion {
+	var pitch, yaw, roll math32.Quaternion
+	pitch.SetFromAxisAngle(&math32.Vector3{X: 1}, -rot[0]*math32.Pi/180)
+	yaw.SetFromAxisAngle(&math32.Vector3{Y: 1}, -rot[1]*math32.Pi/180)
+	roll.SetFromAxisAngle(&math32.Vector3{Z: 1}, -rot[2]*math32.Pi/180)
+	yaw.Multiply(&pitch).Multiply(&roll)
+	return yaw
+}
+
+// color4 converts a color of the protocol for the GUI
+func color4(c color.NRGBA) math32.Color4 {
+	return math32.Color4{R: float32(c.R) / 255, G: float32(c.G) / 255, B: float32(c.B) / 255, A: float32(c.A) / 255}
+}
+
+// newVisual builds the node drawing an object with the visual of its properties. Objects with a visual that is
+// not known are not drawn.
+func (o *Objects) newVisual(obj *object.Object) *objectVisual {
+	v := &objectVisual{node: core.NewNode()}
+	p := &obj.Props
+	switch p.Visual {
+	case object.VisualCube:
+		o.addCube(v, p, p.VisualSize, p.Texture)
+	case object.VisualSprite:
+		o.addSprite(v, p)
+	case object.VisualUprightSprite:
+		o.addUprightSprite(v, p, p.VisualSize, p.Texture(0), p.Texture(1))
+	case object.VisualMesh:
+		o.addMesh(v, p)
+	case object.VisualWielditem, object.VisualItem:
+		o.addItem(v, p)
+	}
+	return v
+}
+
+// addCube draws a box of a size in nodes with one texture per face
+func (o *Objects) addCube(v *objectVisual, p *object.Properties, size [3]float32, texture func(int) string) {
+	mesh := graphic.NewMesh(geometry.NewBox(size[0], size[1], size[2]), nil)
+	for group, index := range cubeFaceTextures {
+		mesh.AddGroupMaterial(o.newMaterial(v, p, texture(index)), group)
+	}
+	v.node.Add(mesh)
+}
+
+// addSprite draws the current frame of a sprite texture facing the camera
+func (o *Objects) addSprite(v *objectVisual, p *object.Properties) {
+	mat := o.newMaterial(v, p, "")
+	// Minetest does not light sprites
+	mat.SetUseLights(material.UseLightNone)
+	mat.SetTransparent(true)
+	if tex := o.newTexture(p.Texture(0)); tex != nil {
+		tex.SetRepeat(1/float32(max(1, p.SpriteDiv[0])), 1/float32(max(1, p.SpriteDiv[1])))
+		mat.AddTexture(tex)
+		v.spriteTexture = tex
+		v.spriteCell = [2]int{-1, -1}
+	}
+	v.billboard = true
+	v.node.Add(graphic.NewSprite(p.VisualSize[0], p.VisualSize[1], mat))
+}
+
+// addUprightSprite draws an upright rectangle of a size in nodes with one texture on the front and one on the
+// back
+func (o *Objects) addUprightSprite(v *objectVisual, p *object.Properties, size [3]float32, front, back string) {
+	for i, name := range [...]string{front, back} {
+		mat := o.newMaterial(v, p, name)
+		mat.SetTransparent(true)
+		if i == 0 {
+			mat.SetSide(material.SideFront)
+		} else {
+			mat.SetSide(material.SideBack)
+		}
+		v.node.Add(graphic.NewMesh(geometry.NewPlane(size[0], size[1]), mat))
+	}
+}
+
+// addMesh draws the model of an object with its textures in the order of the model's materials. Models that
+// cannot be loaded are drawn as their selection box.
+func (o *Objects) addMesh(v *objectVisual, p *object.Properties) {
+	model, err := o.loadMesh(p.Mesh)
+	if err != nil {
+		box := p.SelectionBox
+		size := [3]float32{box.Max[0] - box.Min[0], box.Max[1] - box.Min[1], box.Max[2] - box.Min[2]}
+		o.addCube(v, p, size, func(int) string { return p.Texture(0) })
+		// The selection box need not be centered on the object
+		for _, child := range v.node.Children() {
+			child.GetNode().SetPosition((box.Min[0]+box.Max[0])/2, (box.Min[1]+box.Max[1])/2, (box.Min[2]+box.Max[2])/2)
+		}
+		return
+	}
+
+	index := 0
+	for _, child := range model.Children() {
+		mesh, ok := child.(*graphic.Mesh)
+		if !ok {
+			continue
+		}
+		groups := max(1, mesh.GetGeometry().GroupCount())
+		mesh.ClearMaterials()
+		if groups == 1 {
+			mesh.AddMaterial(o.newMaterial(v, p, p.Texture(index)), 0, 0)
+			index++
+			continue
+		}
+		for group := 0; group < groups; group++ {
+			mesh.AddGroupMaterial(o.newMaterial(v, p, p.Texture(index)), group)
+			index++
+		}
+	}
+	// Models are made in protocol units
+	model.SetScale(p.VisualSize[0]/network.BS, p.VisualSize[1]/network.BS, p.VisualSize[2]/network.BS)
+	v.node.Add(model)
+}
+
+// loadMesh loads an .obj model from the textures. Other model formats are not supported.
+func (o *Objects) loadMesh(name string) (*core.Node, error) {
+	if !strings.HasSuffix(name, ".obj") {
+		return nil, fmt.Errorf("mesh %q: unsupported format", name)
+	}
+	if o.textures == nil {
+		return nil, fmt.Errorf("mesh %q: no media", name)
+	}
+	file, err := o.textures.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	dec, err := obj.DecodeReader(file, strings.NewReader(""))
+	if err != nil {
+		return nil, fmt.Errorf("mesh %q: %w", name, err)
+	}
+	return dec.NewGroup()
+}
+
+// addItem draws an item the way Minetest draws dropped and wielded items: nodes drawn as cubes without a wield
+// image as a small cube and everything else as its image
+func (o *Objects) addItem(v *objectVisual, p *object.Properties) {
+	name, _, _ := strings.Cut(p.ItemName(), " ")
+	def := o.client.ItemDefs().Get(name)
+	size := [3]float32{p.VisualSize[0] / 2, p.VisualSize[1] / 2, p.VisualSize[2] / 2}
+
+	if def.Type == inventory.ItemTypeNode && def.WieldImage == "" {
+		nodes := o.client.NodeDefs()
+		if id, exists := nodes.GetID(def.Name); exists && nodes.IsDrawnAsCube(id) {
+			tiles := nodes.Get(id).Tiles
+			o.addCube(v, p, size, func(i int) string { return tiles[i%len(tiles)].Name })
+			return
+		}
+	}
+
+	image := def.WieldImage
+	if image == "" {
+		image = def.InventoryImage
+	}
+	o.addUprightSprite(v, p, size, image, image)
+}
+
+// newMaterial creates a material showing a texture the way the properties of an object ask for. Without a
+// texture, or one that cannot be loaded, the material is plain white.
+func (o *Objects) newMaterial(v *objectVisual, p *object.Properties, name string) *material.Standard {
+	mat := material.NewStandard(&math32.Color{R: 1, G: 1, B: 1})
+	if tex := o.newTexture(name); tex != nil {
+		mat.AddTexture(tex)
+	}
+	if !p.Shaded {
+		mat.SetUseLights(material.UseLightNone)
+	}
+	if !p.BackfaceCulling {
+		mat.SetSide(material.SideDouble)
+	}
+	if p.UseTextureAlpha {
+		mat.SetTransparent(true)
+	}
+	v.materials = append(v.materials, mat)
+	return mat
+}
+
+// newTexture creates a texture from an image of the media, or returns nil if it cannot be loaded. Every material
+// gets its own texture, since disposing a material disposes its textures.
+func (o *Objects) newTexture(name string) *texture.Texture2D {
+	img := o.image(name)
+	if img == nil {
+		return nil
+	}
+	tex := texture.NewTexture2DFromRGBA(img)
+	tex.SetMagFilter(gls.NEAREST)
+	return tex
+}
@@ -0,0 +1,249 @@
+package ui
+
+import (
+	"io/fs"
+	"strings"
+
+	"bettermt/main/client"
+	"bettermt/main/media"
+	"bettermt/main/particle"
+
+	"github.com/g3n/engine/camera"
+	"github.com/g3n/engine/core"
+	"github.com/g3n/engine/geometry"
+	"github.com/g3n/engine/gls"
+	"github.com/g3n/engine/graphic"
+	"github.com/g3n/engine/material"
+	"github.com/g3n/engine/math32"
+	"github.com/g3n/engine/renderer"
+	"github.com/g3n/engine/texture"
+)
+
+// Name of the shader program drawing particles
+const particleShader = "particle"
+
+// Floats per particle vertex: position, texture coordinates and color
+const particleVertexSize = 3 + 2 + 4
+
+// particleVertexShader passes the color of every vertex on, which the standard shader cannot
+const particleVertexShader = `
+#include <attributes>
+#include <material>
+
+uniform mat4 MVP;
+
+in vec4 ParticleColor;
+
+out vec2 FragTexcoord;
+out vec4 Color;
+
+void main() {
+    FragTexcoord = VertexTexcoord;
+    Color = ParticleColor;
+    gl_Position = MVP * vec4(VertexPosition, 1.0);
+}
+`
+
+// particleFragmentShader tints the texture by the vertex color, dropping what is almost transparent
+const particleFragmentShader = `
+precision highp float;
+
+#include <material>
+
+in vec2 FragTexcoord;
+in vec4 Color;
+
+out vec4 FragColor;
+
+void main() {
+    vec4 color = Color;
+    color.a *= MatOpacity;
+#if MAT_TEXTURES > 0
+    color *= texture(MatTexture[0], FragTexcoord * MatTexRepeat(0) + MatTexOffset(0));
+#endif
+    if (color.a < 0.01) {
+        discard;
+    }
+    FragColor = color;
+}
+`
+
+// Particles draws the particles of a client as quads facing the camera. Particles sharing a texture and a blend
+// mode are drawn together in one mesh, which is filled again every frame.
+type Particles struct {
+	core.Node
+
+	manager *particle.Manager
+	client  *client.Client
+	cam     *camera.Camera
+
+	textures fs.FS
+	batches  map[particleBatchKey]*particleBatch
+}
+
+// particleBatchKey is what particles drawn together share
+type particleBatchKey struct {
+	texture string
+	blend   particle.BlendMode
+}
+
+// particleBatch is the mesh drawing the particles with one texture and blend mode
+type particleBatch struct {
+	mesh     *graphic.Mesh
+	geom     *geometry.Geometry
+	vbo      *gls.VBO
+	width    int // Size of the texture in pixels, 0 without a texture
+	height   int
+	vertices math32.ArrayF32
+	indices  math32.ArrayU32
+}
+
+// NewParticles creates the drawing of the particles of c, facing cam, with textures loaded from textures. The
+// shader drawing them is added to r.
+func NewParticles(c *client.Client, cam *camera.Camera, textures fs.FS, r *renderer.Renderer) *Particles {
+	r.AddShader(particleShader+"_vertex", particleVertexShader)
+	r.AddShader(particleShader+"_fragment", particleFragmentShader)
+	r.AddProgram(particleShader, particleShader+"_vertex", particleShader+"_fragment")
+
+	p := &Particles{
+		manager:  c.Particles,
+		client:   c,
+		cam:      cam,
+		textures: textures,
+		batches:  make(map[particleBatchKey]*particleBatch),
+	}
+	p.Node.Init(p)
+	return p
+}
+
+// Update moves the particles on by dtime seconds through the world of the client and builds their quads for
+// the current camera. Call it from the render thread.
+func (p *Particles) Update(dtime float32) {
+	var env particle.Environment
+	if p.client.World != nil {
+		env = p.client.World
+	}
+	p.manager.Step(dtime, env)
+
+	// Quads are spanned by the right and up vectors of the camera
+	m := p.cam.MatrixWorld()
+	right := [3]float32{m[0], m[1], m[2]}
+	up := [3]float32{m[4], m[5], m[6]}
+	// Vertical particles only turn around the vertical axis
+	flatRight := horizontal(right)
+
+	brightness := p.client.Sky.DayNightRatio()
+
+	for _, b := range p.batches {
+		b.vertices = b.vertices[:0]
+	}
+	p.manager.Each(func(pt *particle.Particle) {
+		b := p.batch(particleBatchKey{texture: pt.Texture.Name, blend: pt.Texture.Blend})
+		light := min(1, brightness+float32(pt.Glow)/14)
+		color := [4]float32{
+			float32(pt.Color.R) / 255 * light,
+			float32(pt.Color.G) / 255 * light,
+			float32(pt.Color.B) / 255 * light,
+			float32(pt.Color.A) / 255 * pt.Alpha(),
+		}
+		r, u := right, up
+		if pt.Vertical {
+			r, u = flatRight, [3]float32{0, 1, 0}
+		}
+		size := pt.Dimensions()
+		b.addQuad(pt.Position, r, u, size, pt.TextureRect(b.width, b.height), color)
+	})
+
+	for _, b := range p.batches {
+		b.update()
+	}
+}
+
+// batch returns the batch of particles with a key, creating it the first time
+func (p *Particles) batch(key particleBatchKey) *particleBatch {
+	if b, exists := p.batches[key]; exists {
+		return b
+	}
+
+	mat := material.NewStandard(&math32.Color{R: 1, G: 1, B: 1})
+	mat.SetShader(particleShader)
+	mat.SetUseLights(material.UseLightNone)
+	mat.SetSide(material.SideDouble)
+	mat.SetTransparent(true)
+	mat.SetDepthMask(false)
+	switch key.blend {
+	case particle.BlendAdd, particle.BlendScreen:
+		// Screen brightens like adding, only less where the background is bright already
+		mat.SetBlending(material.BlendAdditive)
+	case particle.BlendSub:
+		mat.SetBlending(material.BlendSubtractive)
+	}
+
+	b := &particleBatch{vbo: gls.NewVBO(math32.NewArrayF32(0, 0))}
+	// Texture modifiers are not applied
+	name, _, _ := strings.Cut(key.texture, "^")
+	if name != "" && !strings.HasPrefix(name, "[") && p.textures != nil {
+		if img, err := media.DecodeImage(p.textures, name); err == nil {
+			tex := texture.NewTexture2DFromRGBA(img)
+			tex.SetMagFilter(gls.NEAREST)
+			// Texture coordinates of particles run down the image like its rows
+			tex.SetFlipY(false)
+			mat.AddTexture(tex)
+			b.width, b.height = img.Bounds().Dx(), img.Bounds().Dy()
+		}
+	}
+
+	b.vbo.AddAttrib(gls.VertexPosition)
+	b.vbo.AddAttrib(gls.VertexTexcoord)
+	b.vbo.AddCustomAttrib("ParticleColor", 4)
+	b.vbo.SetUsage(gls.DYNAMIC_DRAW)
+	b.geom = geometry.NewGeometry()
+	b.geom.AddVBO(b.vbo)
+	b.mesh = graphic.NewMesh(b.geom, mat)
+	// The quads move every frame, so their bounds are never up to date
+	b.mesh.SetCullable(false)
+	b.mesh.SetVisible(false)
+	p.batches[key] = b
+	p.Add(b.mesh)
+	return b
+}
+
+// addQuad adds a quad of a size in nodes centered on pos and spanned by right and up, showing the part rect of
+// the texture
+func (b *particleBatch) addQuad(pos, right, up [3]float32, size [2]float32, rect [4]float32, color [4]float32) {
+	corners := [4][2]float32{{-1, -1}, {1, -1}, {1, 1}, {-1, 1}}
+	texcoords := [4][2]float32{{rect[0], rect[3]}, {rect[2], rect[3]}, {rect[2], rect[1]}, {rect[0], rect[1]}}
+	for i, c := range corners {
+		x, y := c[0]*size[0]/2, c[1]*size[1]/2
+		b.vertices.Append(
+			pos[0]+right[0]*x+up[0]*y, pos[1]+right[1]*x+up[1]*y, pos[2]+right[2]*x+up[2]*y,
+			texcoords[i][0], texcoords[i][1],
+			color[0], color[1], color[2], color[3],
+		)
+	}
+}
+
+// update hands the quads added since the last update to the mesh, hiding it if there are none
+func (b *particleBatch) update() {
+	quads := len(b.vertices) / (4 * particleVertexSize)
+	b.mesh.SetVisible(quads > 0)
+	if quads == 0 {
+		return
+	}
+	// Every quad is two triangles of the same four corners
+	for i := len(b.indices) / 6; i < quads; i++ {
+		v := uint32(4 * i)
+		b.indices.Append(v, v+1, v+2, v, v+2, v+3)
+	}
+	b.vbo.SetBuffer(b.vertices)
+	b.geom.SetIndices(b.indices[:6*quads])
+}
+
+// horizontal returns v flattened onto the horizontal plane and scaled to a length of 1
+func horizontal(v [3]float32) [3]float32 {
+	l := math32.Sqrt(v[0]*v[0] + v[2]*v[2])
+	if l == 0 {
+		return [3]float32{1, 0, 0}
+	}
+	return [3]float32{v[0] / l, 0, v[2] / l}
+}
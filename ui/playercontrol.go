@@ -0,0 +1,140 @@
+package ui
+
+import (
+	"bettermt/main/player"
+
+	"github.com/g3n/engine/camera"
+	"github.com/g3n/engine/gui"
+	"github.com/g3n/engine/math32"
+	"github.com/g3n/engine/window"
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// Degrees turned per pixel of mouse movement, like mouse_sensitivity
+const mouseSensitivity = 0.2
+
+// PlayerControl moves the local player with the keyboard, turns it with the mouse and keeps the camera at its eyes.
+// While the cursor is captured the left and right mouse buttons dig and place.
+// The scene draws Minetest's left-handed coordinates as they are, which mirrors the world, so turning and
+// strafing are mirrored as well to follow the mouse and keys on screen.
+type PlayerControl struct {
+	// Input is ignored while false, such as when the chat console has the keyboard
+	Enabled bool
+
+	player *player.LocalPlayer
+	env    player.Environment
+	cam    *camera.Camera
+	keys   map[window.Key]bool
+	// Mouse buttons held since they were pressed with the cursor captured
+	buttons map[window.MouseButton]bool
+
+	captured    bool
+	lastCursor  math32.Vector2
+	cursorKnown bool
+}
+
+// NewPlayerControl binds the keyboard and mouse to a player moving through env and the camera to its eyes
+func NewPlayerControl(p *player.LocalPlayer, env player.Environment, cam *camera.Camera) *PlayerControl {
+	pc := &PlayerControl{
+		Enabled: true,
+		player:  p,
+		env:     env,
+		cam:     cam,
+		keys:    make(map[window.Key]bool),
+		buttons: make(map[window.MouseButton]bool),
+	}
+	gui.Manager().SubscribeID(gui.OnKeyDown, pc, pc.onKeyDown)
+	gui.Manager().SubscribeID(gui.OnKeyUp, pc, pc.onKeyUp)
+	gui.Manager().SubscribeID(gui.OnMouseDown, pc, pc.onMouseDown)
+	gui.Manager().SubscribeID(gui.OnMouseUp, pc, pc.onMouseUp)
+	window.Get().SubscribeID(window.OnCursor, pc, pc.onCursor)
+	return pc
+}
+
+// Update moves the player for dtime seconds and places the camera. Call it from the render thread.
+func (pc *PlayerControl) Update(dtime float32) {
+	if !pc.Enabled {
+		clear(pc.keys)
+		clear(pc.buttons)
+		pc.setCaptured(false)
+	}
+	pc.player.SetControls(player.Controls{
+		Forward:  pc.keys[window.KeyW],
+		Backward: pc.keys[window.KeyS],
+		Left:     pc.keys[window.KeyD],
+		Right:    pc.keys[window.KeyA],
+		Jump:     pc.keys[window.KeySpace],
+		Aux1:     pc.keys[window.KeyE],
+		Sneak:    pc.keys[window.KeyLeftShift],
+		Dig:      pc.buttons[window.MouseButtonLeft],
+		Place:    pc.buttons[window.MouseButtonRight],
+	})
+	pc.player.Step(dtime, pc.env)
+
+	eye := pc.player.EyePosition()
+	dir := pc.player.LookDirection()
+	pc.cam.SetPosition(eye[0], eye[1], eye[2])
+	pc.cam.LookAt(&math32.Vector3{X: eye[0] + dir[0], Y: eye[1] + dir[1], Z: eye[2] + dir[2]}, &math32.Vector3{Y: 1})
+	pc.cam.SetFov(pc.player.State().FOV)
+}
+
+// setCaptured hides the cursor and turns mouse movement into looking around, or gives the cursor back
+func (pc *PlayerControl) setCaptured(captured bool) {
+	if captured == pc.captured {
+		return
+	}
+	pc.captured = captured
+	pc.cursorKnown = false
+	mode := glfw.CursorNormal
+	if captured {
+		mode = glfw.CursorDisabled
+	}
+	if w, ok := window.Get().(*window.GlfwWindow); ok {
+		w.SetInputMode(glfw.CursorMode, mode)
+	}
+}
+
+func (pc *PlayerControl) onKeyDown(evname string, ev interface{}) {
+	if !pc.Enabled {
+		return
+	}
+	key := ev.(*window.KeyEvent).Key
+	if key == window.KeyEscape {
+		clear(pc.buttons)
+		pc.setCaptured(false)
+		return
+	}
+	pc.keys[key] = true
+}
+
+func (pc *PlayerControl) onKeyUp(evname string, ev interface{}) {
+	delete(pc.keys, ev.(*window.KeyEvent).Key)
+}
+
+// onMouseDown captures the cursor when the player clicks into the world. Clicks only dig and place once it is captured.
+func (pc *PlayerControl) onMouseDown(evname string, ev interface{}) {
+	if !pc.Enabled {
+		return
+	}
+	if !pc.captured {
+		pc.setCaptured(true)
+		return
+	}
+	pc.buttons[ev.(*window.MouseEvent).Button] = true
+}
+
+func (pc *PlayerControl) onMouseUp(evname string, ev interface{}) {
+	delete(pc.buttons, ev.(*window.MouseEvent).Button)
+}
+
+// onCursor turns the player by the distance the captured cursor moved
+func (pc *PlayerControl) onCursor(evname string, ev interface{}) {
+	cev := ev.(*window.CursorEvent)
+	cursor := math32.Vector2{X: cev.Xpos, Y: cev.Ypos}
+	if pc.captured && pc.cursorKnown {
+		dx, dy := cursor.X-pc.lastCursor.X, cursor.Y-pc.lastCursor.Y
+		pc.player.Turn(dy*mouseSensitivity, dx*mouseSensitivity)
+	}
+	pc.lastCursor = cursor
+	pc.cursorKnown = true
+}
@@ -0,0 +1,184 @@
+package util
+
+import (
+	"fmt"
+	"image/color"
+	"io/fs"
+	"strings"
+
+	"bettermt/main/sky"
+
+	"github.com/g3n/engine/core"
+	"github.com/g3n/engine/light"
+	"github.com/g3n/engine/math32"
+)
+
+const (
+	// Intensity of the ambient light at night and during the day
+	nightAmbient = 0.25
+	dayAmbient   = 0.7
+	// Intensity of the sun and the moon when they are high in the sky
+	sunIntensity  = 0.4
+	moonIntensity = 0.1
+)
+
+// Sky draws the sky of a sky.Sky and lights the scene for its time of day: an ambient light that follows the
+// day/night ratio and directional lights from the sun and the moon.
+type Sky struct {
+	core.Node
+
+	state    *sky.Sky
+	textures fs.FS
+
+	skybox       *Skybox // Shipped with the game, tinted by the time of day
+	serverSkybox *Skybox // Textures of a skybox sent by the server, nil while there is none
+	serverFaces  [6]string
+
+	ambient *light.Ambient
+	sun     *light.Directional
+	moon    *light.Directional
+
+	params     sky.Params
+	sunParams  sky.SunParams
+	moonParams sky.MoonParams
+	version    uint64
+	loaded     bool
+
+	clearColor math32.Color
+}
+
+// NewSky creates a sky following state whose default skybox has the faces in the order east, west, top,
+// bottom, north, south. Skyboxes of the server are loaded from textures.
+func NewSky(state *sky.Sky, textures fs.FS, faces [6]string) (*Sky, error) {
+	skybox, err := NewSkybox(textures, faces)
+	if err != nil {
+		return nil, err
+	}
+	s := &Sky{
+		state:    state,
+		textures: textures,
+		skybox:   skybox,
+		ambient:  light.NewAmbient(&math32.Color{R: 1, G: 1, B: 1}, dayAmbient),
+		sun:      light.NewDirectional(&math32.Color{R: 1, G: 1, B: 1}, sunIntensity),
+		moon:     light.NewDirectional(&math32.Color{R: 0.6, G: 0.7, B: 1}, moonIntensity),
+	}
+	s.Node.Init(s)
+	s.Add(skybox)
+	s.Add(s.ambient)
+	s.Add(s.sun)
+	s.Add(s.moon)
+	return s, nil
+}
+
+// ClearColor returns the color the screen should be cleared to behind the sky
+func (s *Sky) ClearColor() math32.Color {
+	return s.clearColor
+}
+
+// Update moves the sun and the moon to the current time of day and applies new settings of the server.
+// An error means a skybox of the server could not be loaded, the default one is shown instead.
+func (s *Sky) Update() error {
+	var err error
+	if version := s.state.Version(); version != s.version || !s.loaded {
+		s.version = version
+		s.loaded = true
+		s.params = s.state.Params()
+		s.sunParams = s.state.Sun()
+		s.moonParams = s.state.Moon()
+		err = s.updateSkybox()
+	}
+
+	timeOfDay := s.state.TimeOfDay()
+	ratio := s.state.DayNightRatio()
+	dawn := sky.Dawn(ratio)
+
+	s.ambient.SetIntensity(nightAmbient + (dayAmbient-nightAmbient)*ratio)
+
+	sunDir := sky.SunDirection(timeOfDay, s.params.BodyOrbitTilt)
+	s.sun.SetPosition(sunDir[0], sunDir[1], sunDir[2])
+	s.sun.SetColor(mixColor(math32.Color{R: 1, G: 1, B: 1}, toColor(s.params.FogSunTint), dawn))
+	s.sun.SetIntensity(sunIntensity * aboveHorizon(sunDir[1]))
+	s.sun.SetVisible(s.sunParams.Visible)
+
+	moonDir := sky.MoonDirection(timeOfDay, s.params.BodyOrbitTilt)
+	s.moon.SetPosition(moonDir[0], moonDir[1], moonDir[2])
+	s.moon.SetIntensity(moonIntensity * aboveHorizon(moonDir[1]))
+	s.moon.SetVisible(s.moonParams.Visible)
+
+	switch s.params.Type {
+	case sky.TypePlain, sky.TypeSkybox:
+		s.clearColor = toColor(s.params.BgColor)
+	default:
+		skyColor, horizon := s.params.Colors.SkyColor(ratio)
+		s.clearColor = toColor(horizon)
+		s.skybox.SetColor(skyTint(skyColor, s.params.Colors.DaySky))
+	}
+	return err
+}
+
+// updateSkybox shows the skybox the settings ask for, loading the textures of the server if they changed
+func (s *Sky) updateSkybox() error {
+	s.skybox.SetVisible(s.params.Type == sky.TypeRegular)
+	if s.params.Type != sky.TypeSkybox || len(s.params.Textures) < 6 {
+		if s.params.Type == sky.TypeSkybox {
+			// Without six textures the background color shows, like in Minetest
+			s.skybox.SetVisible(false)
+		}
+		if s.serverSkybox != nil {
+			s.serverSkybox.SetVisible(false)
+		}
+		return nil
+	}
+
+	// Minetest sends the faces as top, bottom, west, east, north, south
+	t := s.params.Textures
+	faces := [6]string{t[3], t[2], t[0], t[1], t[4], t[5]}
+	for i, name := range faces {
+		// Texture modifiers are not applied
+		faces[i], _, _ = strings.Cut(name, "^")
+	}
+	if s.serverSkybox != nil && faces == s.serverFaces {
+		s.serverSkybox.SetVisible(true)
+		return nil
+	}
+
+	if s.serverSkybox != nil {
+		s.Remove(s.serverSkybox)
+		s.serverSkybox.Dispose()
+		s.serverSkybox = nil
+	}
+	skybox, err := NewSkybox(s.textures, faces)
+	if err != nil {
+		s.skybox.SetVisible(true)
+		return fmt.Errorf("skybox: %w", err)
+	}
+	s.serverSkybox = skybox
+	s.serverFaces = faces
+	s.Add(skybox)
+	return nil
+}
+
+// aboveHorizon fades a light in as the height of its direction rises above the horizon
+func aboveHorizon(height float32) float32 {
+	return max(0, min(1, height*4))
+}
+
+// skyTint returns the color the default skybox, which shows a day sky, is multiplied by to match a sky color
+func skyTint(c, day color.NRGBA) *math32.Color {
+	ratio := func(v, d uint8) float32 {
+		if d == 0 {
+			return 1
+		}
+		return min(1, float32(v)/float32(d))
+	}
+	return &math32.Color{R: ratio(c.R, day.R), G: ratio(c.G, day.G), B: ratio(c.B, day.B)}
+}
+
+func toColor(c color.NRGBA) math32.Color {
+	return math32.Color{R: float32(c.R) / 255, G: float32(c.G) / 255, B: float32(c.B) / 255}
+}
+
+// mixColor mixes a into b by f, from 0 for a to 1 for b
+func mixColor(a, b math32.Color, f float32) *math32.Color {
+	return &math32.Color{R: a.R + (b.R-a.R)*f, G: a.G + (b.G-a.G)*f, B: a.B + (b.B-a.B)*f}
+}
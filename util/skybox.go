@@ -0,0 +1,87 @@
+package util
+
+import (
+	"io/fs"
+
+	"bettermt/main/media"
+
+	"github.com/g3n/engine/core"
+	"github.com/g3n/engine/geometry"
+	"github.com/g3n/engine/gls"
+	"github.com/g3n/engine/graphic"
+	"github.com/g3n/engine/material"
+	"github.com/g3n/engine/math32"
+	"github.com/g3n/engine/texture"
+)
+
+// Skybox is a cube drawn around the camera, like graphic.Skybox but with faces read from any filesystem
+type Skybox struct {
+	graphic.Graphic
+	uniMVm  gls.Uniform
+	uniMVPm gls.Uniform
+	uniNm   gls.Uniform
+}
+
+// NewSkybox creates a skybox from six images in the order east, west, top, bottom, north, south
+func NewSkybox(fsys fs.FS, faces [6]string) (*Skybox, error) {
+	skybox := new(Skybox)
+
+	geom := geometry.NewCube(1)
+	skybox.Graphic.Init(skybox, geom, gls.TRIANGLES)
+	skybox.Graphic.SetCullable(false)
+
+	for i, name := range faces {
+		rgba, err := media.DecodeImage(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		matFace := material.NewStandard(math32.NewColor("white"))
+		matFace.AddTexture(texture.NewTexture2DFromRGBA(rgba))
+		matFace.SetSide(material.SideBack)
+		matFace.SetUseLights(material.UseLightNone)
+
+		// Everything else is drawn over the skybox
+		matFace.SetDepthMask(false)
+
+		skybox.AddGroupMaterial(skybox, matFace, i)
+	}
+
+	skybox.uniMVm.Init("ModelViewMatrix")
+	skybox.uniMVPm.Init("MVP")
+	skybox.uniNm.Init("NormalMatrix")
+
+	// The skybox should always be rendered last among the opaque objects
+	skybox.SetRenderOrder(100)
+
+	return skybox, nil
+}
+
+// SetColor multiplies the faces of the skybox by a color, which darkens or tints them
+func (skybox *Skybox) SetColor(color *math32.Color) {
+	for _, m := range skybox.Materials() {
+		m.IMaterial().(*material.Standard).SetColor(color)
+	}
+}
+
+// RenderSetup updates the matrices of the skybox shader, keeping the camera at its center
+func (skybox *Skybox) RenderSetup(gs *gls.GLS, rinfo *core.RenderInfo) {
+	mvm := *skybox.ModelViewMatrix()
+
+	// Clear translation
+	mvm[12] = 0
+	mvm[13] = 0
+	mvm[14] = 0
+
+	location := skybox.uniMVm.Location(gs)
+	gs.UniformMatrix4fv(location, 1, false, &mvm[0])
+
+	var mvpm math32.Matrix4
+	mvpm.MultiplyMatrices(&rinfo.ProjMatrix, &mvm)
+	location = skybox.uniMVPm.Location(gs)
+	gs.UniformMatrix4fv(location, 1, false, &mvpm[0])
+
+	var nm math32.Matrix3
+	nm.GetNormalMatrix(&mvm)
+	location = skybox.uniNm.Location(gs)
+	gs.UniformMatrix3fv(location, 1, false, &nm[0])
+}